@@ -0,0 +1,105 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// radioAdopter adds the monitor interfaces that appear while chopper multi
+// runs, e.g. when a USB adapter is plugged in, to the radios sharing the
+// plan. See --auto-adopt.
+type radioAdopter struct {
+	bands     *bandSplit
+	api       *multiAPI
+	open      func(name string) (*radio, error)
+	supported func(r *radio) ([]int, error)
+	start     func(r *radio)
+
+	mu      sync.Mutex
+	adopted []*radio
+}
+
+// adopt hops on the interface of a link change if it is a new monitor
+// interface on a radio that is not hopping yet.
+func (a *radioAdopter) adopt(change nl80211util.LinkChange) {
+	if change.Removed || !change.Radiotap || change.Name == "" || a.bands.running(change.Name, -1) {
+		return
+	}
+
+	r, err := a.open(change.Name)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot adopt %v: %v\n", change.Name, err)
+		return
+	}
+	if a.bands.running("", r.phy) {
+		_, _ = fmt.Fprintf(stderr, "%v is on phy%v, which is already hopping\n", r.name, r.phy)
+		r.close()
+		return
+	}
+	supported, err := a.supported(r)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot adopt %v\n", err)
+		r.close()
+		return
+	}
+	a.bands.add(r, supported)
+
+	a.mu.Lock()
+	a.adopted = append(a.adopted, r)
+	a.mu.Unlock()
+	_, _ = fmt.Fprintf(stderr, "Adopted %v on phy%v\n", r.name, r.phy)
+	a.api.add(r)
+	a.bands.apply()
+	a.start(r)
+}
+
+// run adopts interfaces until the events are closed.
+func (a *radioAdopter) run(ctx context.Context, events *nl80211util.LinkEvents) {
+	for {
+		change, err := events.Next()
+		if errors.Is(err, nl80211util.ErrEventsLost) {
+			warnf("%v, plugged in interfaces may not be adopted", err)
+			continue
+		} else if err != nil {
+			if ctx.Err() == nil {
+				_, _ = fmt.Fprintf(stderr, "WARNING: interface notifications stopped: %v\n", err)
+			}
+			return
+		}
+		a.adopt(change)
+	}
+}
+
+// close closes the adopted radios.
+func (a *radioAdopter) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, r := range a.adopted {
+		r.close()
+	}
+	a.adopted = nil
+}
@@ -0,0 +1,75 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestRadioAdopterAdopt(t *testing.T) {
+	wlan0 := testRadio(t, "wlan0mon", 0, []int{1})
+	bands := &bandSplit{global: []int{1, 6, 36, 40}}
+	bands.add(wlan0, []int{1, 6, 36, 40})
+	bands.apply()
+	m := &multiAPI{radios: []*radio{wlan0}}
+
+	var opened, started []string
+	a := &radioAdopter{
+		bands: bands,
+		api:   m,
+		open: func(name string) (*radio, error) {
+			opened = append(opened, name)
+			return testRadio(t, name, 1, []int{1}), nil
+		},
+		supported: func(r *radio) ([]int, error) {
+			return []int{1, 6, 11}, nil
+		},
+		start: func(r *radio) {
+			started = append(started, r.name)
+		},
+	}
+
+	// Only new monitor interfaces are adopted
+	a.adopt(nl80211util.LinkChange{Ifindex: 3, Name: "eth0", Up: true})
+	a.adopt(nl80211util.LinkChange{Ifindex: 4, Name: "wlan0mon", Radiotap: true})
+	a.adopt(nl80211util.LinkChange{Ifindex: 5, Name: "wlan1mon", Radiotap: true, Removed: true})
+	if opened != nil {
+		t.Fatalf("opened %v", opened)
+	}
+
+	a.adopt(nl80211util.LinkChange{Ifindex: 5, Name: "wlan1mon", Radiotap: true})
+	a.adopt(nl80211util.LinkChange{Ifindex: 5, Name: "wlan1mon", Radiotap: true, Up: true})
+	if !reflect.DeepEqual(opened, []string{"wlan1mon"}) || !reflect.DeepEqual(started, []string{"wlan1mon"}) {
+		t.Fatalf("opened %v, started %v", opened, started)
+	}
+	radios := m.list()
+	if len(radios) != 2 {
+		t.Fatalf("radios %v", radios)
+	}
+	if got := radios[1].hopper.Channels(); !reflect.DeepEqual(got, []int{1, 6}) {
+		t.Errorf("plan of the adopted 2.4 GHz radio = %v", got)
+	}
+	if got := wlan0.hopper.Channels(); !reflect.DeepEqual(got, []int{36, 40}) {
+		t.Errorf("plan of the dual band radio = %v", got)
+	}
+}
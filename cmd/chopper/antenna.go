@@ -0,0 +1,109 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// antennaSelection is a parsed --antenna flag. Masks that were not given
+// keep their current value.
+type antennaSelection struct {
+	tx, rx       uint32
+	hasTX, hasRX bool
+}
+
+// parseAntenna parses the --antenna flag, e.g. "rx=0x1,tx=0x1".
+func parseAntenna(input string) (antennaSelection, error) {
+	var s antennaSelection
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		i := strings.Index(part, "=")
+		if i < 0 {
+			return antennaSelection{}, fmt.Errorf("invalid antenna selection %v, want rx=<mask> or tx=<mask>", part)
+		}
+		mask, err := strconv.ParseUint(part[i+1:], 0, 32)
+		if err != nil || mask == 0 {
+			return antennaSelection{}, fmt.Errorf("invalid antenna mask %v", part[i+1:])
+		}
+
+		switch part[:i] {
+		case "tx":
+			s.tx, s.hasTX = uint32(mask), true
+		case "rx":
+			s.rx, s.hasRX = uint32(mask), true
+		default:
+			return antennaSelection{}, fmt.Errorf("invalid antenna selection %v, want rx=<mask> or tx=<mask>", part)
+		}
+	}
+	if !s.hasTX && !s.hasRX {
+		return antennaSelection{}, fmt.Errorf("empty antenna selection")
+	}
+
+	return s, nil
+}
+
+// resolve returns the antennas to select given the current and available
+// ones reported by the radio.
+func (s antennaSelection) resolve(current, available nl80211util.Antenna) (nl80211util.Antenna, error) {
+	antenna := current
+	if s.hasTX {
+		antenna.TX = s.tx
+	}
+	if s.hasRX {
+		antenna.RX = s.rx
+	}
+
+	// Drivers that do not support antenna selection report no antennas
+	if available.TX == 0 && available.RX == 0 {
+		return nl80211util.Antenna{}, fmt.Errorf("the radio does not support antenna selection")
+	}
+	if antenna.TX&^available.TX != 0 {
+		return nl80211util.Antenna{}, fmt.Errorf("tx antennas %#x not available, the radio has %#x", antenna.TX, available.TX)
+	}
+	if antenna.RX&^available.RX != 0 {
+		return nl80211util.Antenna{}, fmt.Errorf("rx antennas %#x not available, the radio has %#x", antenna.RX, available.RX)
+	}
+
+	return antenna, nil
+}
+
+// selectAntennas applies the --antenna flag to the radio.
+func selectAntennas(client *nl80211util.Client, phy int, s antennaSelection) (nl80211util.Antenna, error) {
+	current, available, err := client.Antennas(phy)
+	if err != nil {
+		return nl80211util.Antenna{}, fmt.Errorf("cannot get antennas: %v", err)
+	}
+
+	antenna, err := s.resolve(current, available)
+	if err != nil {
+		return nl80211util.Antenna{}, err
+	}
+	if err := client.SetAntenna(phy, antenna); err != nil {
+		return nl80211util.Antenna{}, fmt.Errorf("cannot set antennas to %v: %v", antenna, err)
+	}
+
+	return antenna, nil
+}
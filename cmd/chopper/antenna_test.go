@@ -0,0 +1,64 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestAntennaSelection(t *testing.T) {
+	current := nl80211util.Antenna{TX: 0x3, RX: 0x3}
+	available := nl80211util.Antenna{TX: 0x3, RX: 0x7}
+
+	tests := []struct {
+		input  string
+		output nl80211util.Antenna
+		err    bool
+	}{
+		{"rx=0x1,tx=0x1", nl80211util.Antenna{TX: 0x1, RX: 0x1}, false},
+		{"rx=4", nl80211util.Antenna{TX: 0x3, RX: 0x4}, false},
+		{"tx=0x2", nl80211util.Antenna{TX: 0x2, RX: 0x3}, false},
+		{"tx=0x4", nl80211util.Antenna{}, true},
+		{"rx=0", nl80211util.Antenna{}, true},
+		{"rx", nl80211util.Antenna{}, true},
+		{"both=0x1", nl80211util.Antenna{}, true},
+		{"", nl80211util.Antenna{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			s, err := parseAntenna(tt.input)
+			var got nl80211util.Antenna
+			if err == nil {
+				got, err = s.resolve(current, available)
+			}
+			if (err != nil) != tt.err {
+				t.Fatalf("parseAntenna(%v):\n- want error: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if got != tt.output {
+				t.Fatalf("parseAntenna(%v):\n- want: %v\n-  got: %v", tt.input, tt.output, got)
+			}
+		})
+	}
+
+	s, _ := parseAntenna("rx=0x1")
+	if _, err := s.resolve(current, nl80211util.Antenna{}); err == nil {
+		t.Fatalf("resolve without available antennas: want error")
+	}
+}
@@ -0,0 +1,449 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	"github.com/giacomoferretti/chopper-go/pkg/telemetry"
+)
+
+// controlAPI is the HTTP API enabled by --http-addr and chopper agent.
+type controlAPI struct {
+	// healthHops is how many delays the hopper may go without a successful
+	// hop before /healthz reports it as wedged.
+	healthHops int
+	// delay is the delay until the hopper is created.
+	delay time.Duration
+	// delayFloor is the smallest delay accepted by POST /delay.
+	delayFloor time.Duration
+	// maxDwell is the longest dwell of strategies that extend the delay,
+	// the health window accounts for it.
+	maxDwell time.Duration
+	// stretch, if set, returns how many times the dwells are lengthened
+	// by --throttle, the health window accounts for it.
+	stretch func() float64
+	now     func() time.Time
+
+	// capabilities describes the radio for GET /capabilities if set.
+	capabilities func() (capabilityStatus, error)
+	// events are streamed by GET /events if set.
+	events *eventStream
+	// device is reported by GET /version and /healthz if set.
+	device *deviceInfo
+	// traffic is returned by GET /traffic if set.
+	traffic *trafficMonitor
+	// pause is held by POST /pause.
+	pause pauseState
+	// arbiter serves /slices in chopper arbiter if set.
+	arbiter *arbiter
+
+	mu      sync.Mutex
+	lastHop time.Time
+	// idled is the last time the hopper was seen paused
+	idled   time.Time
+	channel int
+	// hopper is set once the hopper is created
+	hopper *hopper.Hopper
+	// shared is the plan of hopper, edited by the /plan endpoints and
+	// locked by POST /pause
+	shared *sharedPlan
+}
+
+func newControlAPI(healthHops int, delay time.Duration, delayFloor time.Duration) *controlAPI {
+	return &controlAPI{
+		healthHops: healthHops,
+		delay:      delay,
+		delayFloor: delayFloor,
+		now:        time.Now,
+		// Give the first hop a full window
+		lastHop: time.Now(),
+	}
+}
+
+func (a *controlAPI) setHopper(h *hopper.Hopper, shared *sharedPlan) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.hopper = h
+	a.shared = shared
+}
+
+func (a *controlAPI) currentHopper() *hopper.Hopper {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.hopper
+}
+
+// hop records a successful hop, it is registered as an OnHop callback.
+func (a *controlAPI) hop(channel int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastHop = a.now()
+	a.channel = channel
+}
+
+func (a *controlAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", traced("GET /healthz", http.HandlerFunc(a.healthz)))
+	mux.Handle("/version", traced("GET /version", http.HandlerFunc(a.getVersion)))
+	mux.Handle("/capabilities", traced("GET /capabilities", http.HandlerFunc(a.getCapabilities)))
+	if a.events != nil {
+		// Not traced, the request lasts as long as the client is connected
+		mux.Handle("/events", a.events)
+	}
+	if a.traffic != nil {
+		mux.Handle("/traffic", traced("GET /traffic", http.HandlerFunc(a.traffic.getTraffic)))
+	}
+	mux.Handle("/delay", traced("/delay", http.HandlerFunc(a.handleDelay)))
+	mux.Handle("/stats", traced("GET /stats", http.HandlerFunc(a.getStats)))
+	mux.Handle("/pause", traced("/pause", http.HandlerFunc(a.handlePause)))
+	mux.Handle("/resume", traced("POST /resume", http.HandlerFunc(a.handleResume)))
+	mux.Handle("/plan", traced("GET /plan", http.HandlerFunc(a.getPlan)))
+	mux.Handle("/plan/add-channel", traced("POST /plan/add-channel", a.editPlan(addChannel)))
+	mux.Handle("/plan/remove-channel", traced("POST /plan/remove-channel", a.editPlan(removeChannel)))
+	mux.Handle("/plan/set-plan", traced("POST /plan/set-plan", a.editPlan(setPlan)))
+	if a.arbiter != nil {
+		mux.Handle("/slices", traced("/slices", http.HandlerFunc(a.arbiter.handleSlices)))
+		mux.Handle("/slices/", traced("/slices/<id>", http.HandlerFunc(a.arbiter.handleSlice)))
+	}
+	return mux
+}
+
+// traced records a span for every API call when telemetry is enabled.
+func traced(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exporter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		span := exporter.StartSpan(name, nil, telemetry.String("http.method", r.Method))
+		next.ServeHTTP(w, r)
+		span.End(nil)
+		exporter.Add("chopper.api.requests", 1, telemetry.String("http.route", r.URL.Path))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// getVersion returns the build information and the device, like --version
+// --json.
+func (a *controlAPI) getVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	info := currentBuild()
+	info.Device = a.device
+	writeJSON(w, http.StatusOK, info)
+}
+
+// getTraffic returns the traffic per channel, durations are in
+// nanoseconds.
+func (m *trafficMonitor) getTraffic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, m.snapshot())
+}
+
+func (a *controlAPI) getCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if a.capabilities == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{"capabilities unknown"})
+		return
+	}
+	status, err := a.capabilities()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+type healthStatus struct {
+	Status  string      `json:"status"`
+	LastHop time.Time   `json:"last_hop"`
+	Channel int         `json:"channel,omitempty"`
+	Device  *deviceInfo `json:"device,omitempty"`
+}
+
+// healthz reports unhealthy when no hop succeeded within the health window,
+// so that orchestrators can restart a wedged instance. A hopper idled on
+// purpose, e.g. by the geofence, is reported as paused and healthy.
+func (a *controlAPI) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	health := a.health()
+	if health.Status == "unhealthy" {
+		writeJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+// health returns the status reported by GET /healthz.
+func (a *controlAPI) health() healthStatus {
+	delay := a.delay
+	paused := false
+	if h := a.currentHopper(); h != nil {
+		delay = h.Delay()
+		paused = h.Paused()
+	}
+	if a.maxDwell > delay {
+		delay = a.maxDwell
+	}
+	if a.stretch != nil {
+		delay = time.Duration(float64(delay) * a.stretch())
+	}
+	window := time.Duration(a.healthHops) * delay
+
+	a.mu.Lock()
+	now := a.now()
+	if paused {
+		a.idled = now
+	}
+	// The window starts over once the hopper is resumed
+	since := a.lastHop
+	if a.idled.After(since) {
+		since = a.idled
+	}
+	health := healthStatus{Status: "ok", LastHop: a.lastHop, Channel: a.channel, Device: a.device}
+	wedged := now.Sub(since) > window
+	a.mu.Unlock()
+
+	switch {
+	case paused:
+		health.Status = "paused"
+	case wedged:
+		health.Status = "unhealthy"
+	}
+	return health
+}
+
+type planStatus struct {
+	Channels []int  `json:"channels"`
+	Plan     string `json:"plan"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writePlan(w http.ResponseWriter, h *hopper.Hopper) {
+	channels := h.Channels()
+	writeJSON(w, http.StatusOK, planStatus{Channels: channels, Plan: plan.Format(channels)})
+}
+
+// getStats returns the hopper counters, durations are in nanoseconds.
+func (a *controlAPI) getStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h := a.currentHopper()
+	if h == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{"not hopping"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.Stats())
+}
+
+type delayStatus struct {
+	DelayMs int64 `json:"delay_ms"`
+}
+
+// handleDelay returns the delay on GET and changes it on POST, with the
+// new delay in ms in the delay form value.
+func (a *controlAPI) handleDelay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h := a.currentHopper()
+	if h == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{"not hopping"})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		ms, err := strconv.Atoi(r.FormValue("delay"))
+		if err != nil || ms <= 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{fmt.Sprintf("invalid delay %q", r.FormValue("delay"))})
+			return
+		}
+		delay := time.Duration(ms) * time.Millisecond
+		if delay < a.delayFloor {
+			writeJSON(w, http.StatusBadRequest, apiError{fmt.Sprintf("delay is below the minimum of %v", a.delayFloor)})
+			return
+		}
+		_ = h.SetDelay(delay)
+	}
+
+	writeJSON(w, http.StatusOK, delayStatus{DelayMs: int64(h.Delay() / time.Millisecond)})
+}
+
+func (a *controlAPI) getPlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h := a.currentHopper()
+	if h == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{"not hopping"})
+		return
+	}
+
+	writePlan(w, h)
+}
+
+// editPlan wraps a plan command. Commands read their arguments from the
+// form and are applied by the hopper at the next hop.
+func (a *controlAPI) editPlan(command func(shared *sharedPlan, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h := a.currentHopper()
+		if h == nil {
+			writeJSON(w, http.StatusServiceUnavailable, apiError{"not hopping"})
+			return
+		}
+
+		a.mu.Lock()
+		shared := a.shared
+		a.mu.Unlock()
+		if err := command(shared, r); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		writePlan(w, h)
+	})
+}
+
+func formChannel(r *http.Request) (int, error) {
+	channel, err := plan.ParseChannel(r.FormValue("channel"))
+	if err != nil {
+		return 0, err
+	}
+	if plan.Frequency(channel) == 0 {
+		return 0, fmt.Errorf("invalid channel %q", r.FormValue("channel"))
+	}
+	return channel, nil
+}
+
+func addChannel(shared *sharedPlan, r *http.Request) error {
+	channel, err := formChannel(r)
+	if err != nil {
+		return err
+	}
+
+	return shared.AddChannel(channel)
+}
+
+func removeChannel(shared *sharedPlan, r *http.Request) error {
+	channel, err := formChannel(r)
+	if err != nil {
+		return err
+	}
+
+	return shared.RemoveChannel(channel)
+}
+
+// setPlan replaces the plan, using the syntax of --channels.
+func setPlan(shared *sharedPlan, r *http.Request) error {
+	input := r.FormValue("channels")
+
+	var channels []int
+	var err error
+	if plan.HasMultipliers(input) {
+		channels, err = plan.ParseMultipliers(input, plan.Default())
+	} else {
+		channels, err = plan.Parse(input)
+	}
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if plan.Frequency(channel) == 0 {
+			return fmt.Errorf("invalid channel %v", channel)
+		}
+	}
+
+	return shared.SetChannels(channels)
+}
+
+// serve serves the API with the checked flags on --http-addr.
+func (a *controlAPI) serve(options apiOptions) (func(), error) {
+	handler := a.handler()
+	if options.token != "" {
+		handler = requireToken(options.token, handler)
+	}
+	return serveAPI(httpAddr, handler, options.tls)
+}
+
+// serveAPI starts serving the API on addr, over TLS if tlsConfig is not
+// nil. The returned function shuts the server down.
+func serveAPI(addr string, handler http.Handler, tlsConfig *tls.Config) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return func() {
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}, nil
+}
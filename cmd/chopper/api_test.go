@@ -0,0 +1,327 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestHealthz(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		hop    bool
+		since  time.Duration
+		method string
+		want   int
+	}{
+		{"before first hop", false, 500 * time.Millisecond, http.MethodGet, http.StatusOK},
+		{"first hop never happened", false, 2 * time.Second, http.MethodGet, http.StatusServiceUnavailable},
+		{"recent hop", true, 900 * time.Millisecond, http.MethodGet, http.StatusOK},
+		{"wedged", true, 1500 * time.Millisecond, http.MethodGet, http.StatusServiceUnavailable},
+		{"head", true, 0, http.MethodHead, http.StatusOK},
+		{"post", true, 0, http.MethodPost, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			api := newControlAPI(1, time.Second, 0)
+			api.now = func() time.Time { return now }
+			api.lastHop = start
+
+			if tt.hop {
+				api.hop(6)
+			}
+			now = now.Add(tt.since)
+
+			rec := httptest.NewRecorder()
+			api.handler().ServeHTTP(rec, httptest.NewRequest(tt.method, "/healthz", nil))
+			if got := rec.Code; got != tt.want {
+				t.Fatalf("GET /healthz after %v:\n- want: %v\n-  got: %v", tt.since, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHealthzMaxDwell(t *testing.T) {
+	start := time.Now()
+	now := start
+	api := newControlAPI(1, time.Second, 0)
+	api.now = func() time.Time { return now }
+	api.maxDwell = 4 * time.Second
+	api.hop(6)
+	now = now.Add(3 * time.Second)
+
+	rec := httptest.NewRecorder()
+	api.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Code; got != http.StatusOK {
+		t.Fatalf("GET /healthz during a long dwell:\n- want: %v\n-  got: %v", http.StatusOK, got)
+	}
+}
+
+func TestHealthzThrottle(t *testing.T) {
+	start := time.Now()
+	now := start
+	factor := 4.0
+	api := newControlAPI(1, time.Second, 0)
+	api.now = func() time.Time { return now }
+	api.stretch = func() float64 { return factor }
+	api.hop(6)
+	now = now.Add(3 * time.Second)
+
+	rec := httptest.NewRecorder()
+	api.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Code; got != http.StatusOK {
+		t.Fatalf("GET /healthz during a throttled dwell:\n- want: %v\n-  got: %v", http.StatusOK, got)
+	}
+
+	factor = 1
+	rec = httptest.NewRecorder()
+	api.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Code; got != http.StatusServiceUnavailable {
+		t.Fatalf("GET /healthz without throttling:\n- want: %v\n-  got: %v", http.StatusServiceUnavailable, got)
+	}
+}
+
+func TestHealthzPaused(t *testing.T) {
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	now := start
+	api := newControlAPI(1, time.Second, 0)
+	api.now = func() time.Time { return now }
+	api.setHopper(h, newSharedPlan(h))
+	api.hop(6)
+
+	steps := []struct {
+		name   string
+		at     time.Duration
+		pause  bool
+		code   int
+		status string
+	}{
+		{"idle on purpose", 5 * time.Second, true, http.StatusOK, "paused"},
+		{"resumed", 5500 * time.Millisecond, false, http.StatusOK, "ok"},
+		{"wedged after resuming", 6500 * time.Millisecond, false, http.StatusServiceUnavailable, "unhealthy"},
+	}
+
+	for _, step := range steps {
+		now = start.Add(step.at)
+		if step.pause {
+			h.Pause()
+		} else {
+			h.Resume()
+		}
+
+		rec := httptest.NewRecorder()
+		api.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var health healthStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != step.code || health.Status != step.status {
+			t.Fatalf("%v: GET /healthz = %v %v, want %v %v", step.name, rec.Code, health.Status, step.code, step.status)
+		}
+	}
+}
+
+func TestPlanCommands(t *testing.T) {
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := newControlAPI(1, time.Second, 0)
+	handler := api.handler()
+
+	// Before the hopper is created
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plan", nil))
+	if want, got := http.StatusServiceUnavailable, rec.Code; want != got {
+		t.Fatalf("GET /plan without hopper:\n- want: %v\n-  got: %v", want, got)
+	}
+	api.setHopper(h, newSharedPlan(h))
+
+	tests := []struct {
+		path   string
+		form   url.Values
+		status int
+		output []int
+	}{
+		{"/plan/add-channel", url.Values{"channel": {"36"}}, http.StatusOK, []int{1, 6, 11, 36}},
+		{"/plan/remove-channel", url.Values{"channel": {"6"}}, http.StatusOK, []int{1, 11, 36}},
+		{"/plan/remove-channel", url.Values{"channel": {"6"}}, http.StatusBadRequest, []int{1, 11, 36}},
+		{"/plan/add-channel", url.Values{"channel": {"200"}}, http.StatusBadRequest, []int{1, 11, 36}},
+		{"/plan/set-plan", url.Values{"channels": {"1x2,6"}}, http.StatusOK, []int{1, 6, 1}},
+		{"/plan/set-plan", url.Values{"channels": {""}}, http.StatusBadRequest, []int{1, 6, 1}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if want, got := tt.status, rec.Code; want != got {
+			t.Fatalf("POST %v %v:\n- want: %v\n-  got: %v (%v)", tt.path, tt.form.Encode(), want, got, rec.Body)
+		}
+		if want, got := tt.output, h.Channels(); !reflect.DeepEqual(want, got) {
+			t.Fatalf("POST %v %v:\n- want: %v\n-  got: %v", tt.path, tt.form.Encode(), want, got)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plan", nil))
+	var status planStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "1,6,1", status.Plan; want != got {
+		t.Fatalf("GET /plan:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestPlanCheck(t *testing.T) {
+	frequencies := []nl80211util.WiphyFrequency{{Frequency: 2412}, {Frequency: 2437}, {Frequency: 2462}, {Frequency: 5180}}
+	for _, strict := range []bool{false, true} {
+		h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+			Channels: []int{1, 6, 11},
+			Delay:    100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		shared := newSharedPlan(h)
+		shared.check = func(channels []int) ([]int, error) {
+			return checkPlan(channels, frequencies, strict)
+		}
+		api := newControlAPI(1, time.Second, 0)
+		api.setHopper(h, shared)
+		handler := api.handler()
+
+		partial := []int{1, 36}
+		partialStatus := http.StatusOK
+		if strict {
+			partial = []int{1, 6, 11}
+			partialStatus = http.StatusBadRequest
+		}
+		tests := []struct {
+			path   string
+			form   url.Values
+			status int
+			output []int
+		}{
+			{"/plan/add-channel", url.Values{"channel": {"149"}}, http.StatusBadRequest, []int{1, 6, 11}},
+			{"/plan/set-plan", url.Values{"channels": {"14,149"}}, http.StatusBadRequest, []int{1, 6, 11}},
+			{"/plan/set-plan", url.Values{"channels": {"1,149,36"}}, partialStatus, partial},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want, got := tt.status, rec.Code; want != got {
+				t.Fatalf("strict %v, POST %v %v:\n- want: %v\n-  got: %v (%v)", strict, tt.path, tt.form.Encode(), want, got, rec.Body)
+			}
+			if want, got := tt.output, h.Channels(); !reflect.DeepEqual(want, got) {
+				t.Fatalf("strict %v, POST %v %v:\n- want: %v\n-  got: %v", strict, tt.path, tt.form.Encode(), want, got)
+			}
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+		Channels: []int{1},
+		Delay:    100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := newControlAPI(1, h.Delay(), 50*time.Millisecond)
+	api.setHopper(h, newSharedPlan(h))
+	handler := api.handler()
+
+	tests := []struct {
+		delay  string
+		status int
+		output time.Duration
+	}{
+		{"250", http.StatusOK, 250 * time.Millisecond},
+		{"10", http.StatusBadRequest, 250 * time.Millisecond},
+		{"fast", http.StatusBadRequest, 250 * time.Millisecond},
+		{"50", http.StatusOK, 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/delay", strings.NewReader(url.Values{"delay": {tt.delay}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if want, got := tt.status, rec.Code; want != got {
+			t.Fatalf("POST /delay delay=%v:\n- want: %v\n-  got: %v", tt.delay, want, got)
+		}
+		if want, got := tt.output, h.Delay(); want != got {
+			t.Fatalf("POST /delay delay=%v:\n- want: %v\n-  got: %v", tt.delay, want, got)
+		}
+	}
+}
+
+func TestGetTraffic(t *testing.T) {
+	m := newTrafficMonitor(func() (rxCounters, error) {
+		return rxCounters{}, nil
+	})
+	want := channelTraffic{Packets: 10, Bytes: 1000, Dwell: time.Second}
+	m.channels[11] = &want
+
+	rec := httptest.NewRecorder()
+	m.getTraffic(rec, httptest.NewRequest(http.MethodGet, "/traffic", nil))
+	var body map[string]channelTraffic
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if got := body["11"]; want != got {
+		t.Fatalf("GET /traffic:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
@@ -0,0 +1,411 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+const (
+	// arbiterTick is how often the arbiter ends slices and grants new ones,
+	// besides when slices are requested or cancelled.
+	arbiterTick = 50 * time.Millisecond
+	// maxFinishedSlices bounds the ended slices kept for GET /slices/<id>.
+	maxFinishedSlices = 100
+)
+
+// States of a time slice.
+const (
+	sliceQueued    = "queued"
+	sliceGranted   = "granted"
+	sliceActive    = "active"
+	sliceDone      = "done"
+	sliceCancelled = "cancelled"
+	sliceFailed    = "failed"
+)
+
+// timeSlice is a request of a client to keep the radio on a channel for a
+// while, returned by the /slices endpoints.
+type timeSlice struct {
+	ID         int        `json:"id"`
+	Client     string     `json:"client"`
+	Channel    int        `json:"channel"`
+	DurationMs int64      `json:"duration_ms"`
+	State      string     `json:"state"`
+	Start      *time.Time `json:"start,omitempty"`
+	End        *time.Time `json:"end,omitempty"`
+	Error      string     `json:"error,omitempty"`
+
+	duration time.Duration
+}
+
+// arbiter multiplexes the time slices requested by several clients onto the
+// radio, for chopper arbiter. Clients are served in turn, one slice each,
+// and the queued slices of other clients on the same channel share the
+// radio with it. The plan is hopped while no slice is queued.
+type arbiter struct {
+	maxSlice  time.Duration
+	maxQueued int
+	now       func() time.Time
+	wake      chan struct{}
+
+	mu   sync.Mutex
+	lock channelLock
+	// clients are the clients with queued slices, in the order they are
+	// served
+	clients []string
+	next    int
+	queues  map[string][]*timeSlice
+	// granted are the slices of the locked channel
+	granted  []*timeSlice
+	finished []*timeSlice
+	lastID   int
+}
+
+func newArbiter(maxSlice time.Duration, maxQueued int) *arbiter {
+	return &arbiter{
+		maxSlice:  maxSlice,
+		maxQueued: maxQueued,
+		now:       time.Now,
+		wake:      make(chan struct{}, 1),
+		queues:    make(map[string][]*timeSlice),
+		lock:      channelLock{reason: "arbiter"},
+	}
+}
+
+// request queues a slice of d on channel for client.
+func (a *arbiter) request(client string, channel int, d time.Duration) (timeSlice, error) {
+	if d <= 0 || d > a.maxSlice {
+		return timeSlice{}, fmt.Errorf("the duration must be between 0 and %v", a.maxSlice)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.queues[client]) >= a.maxQueued {
+		return timeSlice{}, fmt.Errorf("client %v already has %v queued slices", client, a.maxQueued)
+	}
+	if _, ok := a.queues[client]; !ok {
+		a.clients = append(a.clients, client)
+	}
+	a.lastID++
+	s := &timeSlice{
+		ID:         a.lastID,
+		Client:     client,
+		Channel:    channel,
+		DurationMs: d.Milliseconds(),
+		State:      sliceQueued,
+		duration:   d,
+	}
+	a.queues[client] = append(a.queues[client], s)
+	a.notify()
+	return *s, nil
+}
+
+// notify wakes run up to grant slices without waiting for the next tick.
+func (a *arbiter) notify() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// find returns the slice with id, if it is still known.
+func (a *arbiter) find(id int) *timeSlice {
+	for _, s := range a.granted {
+		if s.ID == id {
+			return s
+		}
+	}
+	for _, queue := range a.queues {
+		for _, s := range queue {
+			if s.ID == id {
+				return s
+			}
+		}
+	}
+	for _, s := range a.finished {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// get returns the slice with id.
+func (a *arbiter) get(id int) (timeSlice, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if s := a.find(id); s != nil {
+		return *s, true
+	}
+	return timeSlice{}, false
+}
+
+// list returns the granted slices, then the queued ones in serving order.
+func (a *arbiter) list() []timeSlice {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	slices := make([]timeSlice, 0, len(a.granted))
+	for _, s := range a.granted {
+		slices = append(slices, *s)
+	}
+	for i := range a.clients {
+		client := a.clients[(a.next+i)%len(a.clients)]
+		for _, s := range a.queues[client] {
+			slices = append(slices, *s)
+		}
+	}
+	return slices
+}
+
+// cancel ends a queued or granted slice early.
+func (a *arbiter) cancel(id int) (timeSlice, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.find(id)
+	if s == nil {
+		return timeSlice{}, false
+	}
+	switch s.State {
+	case sliceQueued:
+		a.queues[s.Client] = removeSlice(a.queues[s.Client], s)
+		a.dropIdle()
+	case sliceGranted, sliceActive:
+		a.granted = removeSlice(a.granted, s)
+	default:
+		return *s, true
+	}
+	a.finish(s, sliceCancelled, a.now())
+	a.notify()
+	return *s, true
+}
+
+func removeSlice(slices []*timeSlice, s *timeSlice) []*timeSlice {
+	for i := range slices {
+		if slices[i] == s {
+			return append(slices[:i], slices[i+1:]...)
+		}
+	}
+	return slices
+}
+
+// dropIdle forgets the clients without queued slices, keeping the turn of
+// the next one.
+func (a *arbiter) dropIdle() {
+	if len(a.clients) == 0 {
+		return
+	}
+
+	turn := a.next % len(a.clients)
+	clients := a.clients[:0]
+	a.next = 0
+	for i, client := range a.clients {
+		if len(a.queues[client]) == 0 {
+			delete(a.queues, client)
+			continue
+		}
+		if i < turn {
+			a.next++
+		}
+		clients = append(clients, client)
+	}
+	a.clients = clients
+}
+
+// finish records the end of a slice that left the queues.
+func (a *arbiter) finish(s *timeSlice, state string, now time.Time) {
+	s.State = state
+	s.End = &now
+	if len(a.finished) >= maxFinishedSlices {
+		a.finished = a.finished[1:]
+	}
+	a.finished = append(a.finished, s)
+}
+
+// hop starts the granted slices once the radio is on their channel, it is
+// registered as an OnHop callback.
+func (a *arbiter) hop(channel int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for _, s := range a.granted {
+		if s.State == sliceGranted && s.Channel == channel {
+			start := now
+			end := now.Add(s.duration)
+			s.State = sliceActive
+			s.Start = &start
+			s.End = &end
+		}
+	}
+}
+
+// schedule ends the slices whose time is up and, once the radio is free,
+// grants the next client its first slice along with the queued slices of
+// other clients on the same channel.
+func (a *arbiter) schedule(now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	granted := a.granted[:0]
+	for _, s := range a.granted {
+		if s.State == sliceActive && !now.Before(*s.End) {
+			a.finish(s, sliceDone, *s.End)
+			continue
+		}
+		granted = append(granted, s)
+	}
+	a.granted = granted
+	if len(a.granted) > 0 {
+		return nil
+	}
+
+	channel := 0
+	for i := range a.clients {
+		k := (a.next + i) % len(a.clients)
+		if queue := a.queues[a.clients[k]]; len(queue) > 0 {
+			channel = queue[0].Channel
+			a.next = k + 1
+			break
+		}
+	}
+	if channel == 0 {
+		if a.lock.channel != 0 {
+			_, _ = fmt.Fprintf(stderr, "Arbiter: no slices queued, resuming hopping\n")
+		}
+		return a.lock.release()
+	}
+
+	for _, client := range a.clients {
+		if queue := a.queues[client]; len(queue) > 0 && queue[0].Channel == channel {
+			queue[0].State = sliceGranted
+			a.granted = append(a.granted, queue[0])
+			a.queues[client] = queue[1:]
+		}
+	}
+	a.dropIdle()
+	if err := a.lock.lock(channel, now); err != nil {
+		for _, s := range a.granted {
+			s.Error = err.Error()
+			a.finish(s, sliceFailed, now)
+		}
+		a.granted = nil
+		return err
+	}
+	return nil
+}
+
+// run schedules the slices on the shared plan until ctx is done.
+func (a *arbiter) run(ctx context.Context, shared *sharedPlan) {
+	a.mu.Lock()
+	a.lock.plan = shared
+	a.mu.Unlock()
+
+	ticker := time.NewTicker(arbiterTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.wake:
+		}
+		if err := a.schedule(a.now()); err != nil {
+			warnf("arbiter: %v", err)
+		}
+	}
+}
+
+// handleSlices lists the granted and queued slices or, on POST, queues a
+// slice of the duration form value on channel for client.
+func (a *arbiter) handleSlices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.list())
+	case http.MethodPost:
+		client := r.FormValue("client")
+		if client == "" {
+			// Every connection of a tool comes from another port
+			client = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client = host
+			}
+		}
+		channel, err := formChannel(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		d, err := parsePause(r.FormValue("duration"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		s, err := a.request(client, channel, d)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, apiError{err.Error()})
+			return
+		}
+		_, _ = fmt.Fprintf(stderr, "Arbiter: %v requested %v on channel %v\n", client, d, plan.FormatChannel(channel))
+		writeJSON(w, http.StatusAccepted, s)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSlice returns the slice of /slices/<id> or, on DELETE, cancels it.
+func (a *arbiter) handleSlice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/slices/"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, apiError{"unknown slice"})
+		return
+	}
+
+	var s timeSlice
+	var ok bool
+	switch r.Method {
+	case http.MethodGet:
+		s, ok = a.get(id)
+	case http.MethodDelete:
+		s, ok = a.cancel(id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{"unknown slice"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,193 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArbiterSchedule(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	a := newArbiter(time.Minute, 2)
+	a.now = func() time.Time { return now }
+	a.lock.plan = newSharedPlan(p)
+
+	mustRequest := func(client string, channel int, d time.Duration) int {
+		s, err := a.request(client, channel, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.ID
+	}
+	state := func(id int) string {
+		s, _ := a.get(id)
+		return s.State
+	}
+
+	kismet1 := mustRequest("kismet", 6, 2*time.Second)
+	kismet2 := mustRequest("kismet", 36, time.Second)
+	if _, err := a.request("kismet", 1, time.Second); err == nil {
+		t.Fatal("request accepted more slices than --slice-queue")
+	}
+	if _, err := a.request("kismet", 1, 2*time.Minute); err == nil {
+		t.Fatal("request accepted a slice longer than --slice-max")
+	}
+	airodump := mustRequest("airodump", 11, time.Second)
+	// Shares the radio with the first slice of kismet
+	tshark := mustRequest("tshark", 6, 3*time.Second)
+
+	steps := []struct {
+		name   string
+		at     time.Duration
+		hop    int
+		output []int
+		states map[int]string
+	}{
+		{"grant the first client", 0, 0, []int{6}, map[int]string{kismet1: sliceGranted, tshark: sliceGranted, airodump: sliceQueued}},
+		{"radio on the channel", 100 * time.Millisecond, 6, []int{6}, map[int]string{kismet1: sliceActive, tshark: sliceActive}},
+		{"first slice done", 2100 * time.Millisecond, 0, []int{6}, map[int]string{kismet1: sliceDone, tshark: sliceActive}},
+		{"shared slice done", 3100 * time.Millisecond, 0, []int{11}, map[int]string{tshark: sliceDone, airodump: sliceGranted, kismet2: sliceQueued}},
+		{"next client", 3200 * time.Millisecond, 11, []int{11}, map[int]string{airodump: sliceActive}},
+		{"back to the first client", 4200 * time.Millisecond, 0, []int{36}, map[int]string{airodump: sliceDone, kismet2: sliceGranted}},
+		{"cancelled", 4300 * time.Millisecond, 0, []int{1, 6, 11}, map[int]string{kismet2: sliceCancelled}},
+	}
+
+	for _, step := range steps {
+		now = start.Add(step.at)
+		if step.hop != 0 {
+			a.hop(step.hop)
+		}
+		if step.name == "cancelled" {
+			a.cancel(kismet2)
+		}
+		if err := a.schedule(now); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(step.output, p.channels) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+		for id, want := range step.states {
+			if got := state(id); got != want {
+				t.Fatalf("%v: slice %v is %v, want %v", step.name, id, got, want)
+			}
+		}
+	}
+
+	s, _ := a.get(tshark)
+	if got := s.End.Sub(*s.Start); got != 3*time.Second {
+		t.Errorf("shared slice lasted %v, want 3s from the hop", got)
+	}
+	if slices := a.list(); len(slices) != 0 {
+		t.Errorf("list() = %v, want no slices", slices)
+	}
+	if len(a.clients) != 0 || len(a.queues) != 0 {
+		t.Errorf("clients %v and queues %v kept once served", a.clients, a.queues)
+	}
+}
+
+func TestArbiterAPI(t *testing.T) {
+	api := newControlAPI(1, time.Second, 0)
+	api.arbiter = newArbiter(time.Minute, 16)
+	handler := api.handler()
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/slices", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	bad := []url.Values{
+		{"client": {"kismet"}, "channel": {"200"}, "duration": {"5s"}},
+		{"client": {"kismet"}, "channel": {"6"}, "duration": {"soon"}},
+	}
+	for _, form := range bad {
+		if rec := post(form); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /slices %v = %v, want %v", form.Encode(), rec.Code, http.StatusBadRequest)
+		}
+	}
+	if rec := post(url.Values{"client": {"kismet"}, "channel": {"6"}, "duration": {"2m"}}); rec.Code != http.StatusConflict {
+		t.Errorf("POST /slices longer than --slice-max = %v, want %v", rec.Code, http.StatusConflict)
+	}
+
+	rec := post(url.Values{"client": {"kismet"}, "channel": {"6"}, "duration": {"5"}})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /slices = %v (%v)", rec.Code, rec.Body)
+	}
+	var s timeSlice
+	if err := json.NewDecoder(rec.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if want := (timeSlice{ID: s.ID, Client: "kismet", Channel: 6, DurationMs: 5000, State: sliceQueued}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("POST /slices:\n- want: %+v\n-  got: %+v", want, s)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slices", nil))
+	var slices []timeSlice
+	if err := json.NewDecoder(rec.Body).Decode(&slices); err != nil || len(slices) != 1 {
+		t.Fatalf("GET /slices = %v, %v", slices, err)
+	}
+
+	path := "/slices/" + strconv.Itoa(s.ID)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+	if err := json.NewDecoder(rec.Body).Decode(&s); err != nil || s.State != sliceCancelled {
+		t.Fatalf("DELETE %v = %+v, %v", path, s, err)
+	}
+
+	for _, path := range []string{path, "/slices/abc"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		want := http.StatusOK
+		if path == "/slices/abc" {
+			want = http.StatusNotFound
+		}
+		if rec.Code != want {
+			t.Errorf("GET %v = %v, want %v", path, rec.Code, want)
+		}
+	}
+
+	// Without a client name the connections of a host are one client
+	for _, addr := range []string{"192.0.2.1:40000", "192.0.2.1:40001"} {
+		req := httptest.NewRequest(http.MethodPost, "/slices", strings.NewReader("channel=6&duration=5"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = addr
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if err := json.NewDecoder(rec.Body).Decode(&s); err != nil || s.Client != "192.0.2.1" {
+			t.Errorf("POST /slices from %v: client %q, %v", addr, s.Client, err)
+		}
+	}
+	if clients := api.arbiter.clients; len(clients) != 1 {
+		t.Errorf("clients = %v, want one per host", clients)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// channelAsserter puts the radio back on the channel of the hopper when
+// another process moves it during the dwell, for supplicants that cannot
+// be stopped.
+type channelAsserter struct {
+	frequency func() (int, error)
+	retune    func(channel int) error
+
+	// mu is held while checking, so the hopper does not retune meanwhile
+	mu sync.Mutex
+	// channel is the channel of the hopper, 0 while it retunes
+	channel   int
+	conflicts int
+}
+
+func (a *channelAsserter) beforeHop(int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.channel = 0
+}
+
+func (a *channelAsserter) hop(channel int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.channel = channel
+}
+
+// check retunes the radio if it is not on the channel of the hopper.
+func (a *channelAsserter) check() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.channel == 0 {
+		return
+	}
+	frequency, err := a.frequency()
+	if err != nil {
+		warnf("cannot check the channel: %v", err)
+		return
+	}
+	// Drivers that do not report it cannot be checked
+	if frequency == 0 || frequency == plan.Frequency(a.channel) {
+		return
+	}
+
+	a.conflicts++
+	warnf("radio moved to %v by another process, setting channel %v again", describeFrequency(frequency), plan.FormatChannel(a.channel))
+	event := events.New(events.TypeConflict)
+	event.Channel = a.channel
+	event.Frequency = frequency
+	event.Conflicts = a.conflicts
+	emit(event)
+
+	if err := a.retune(a.channel); err != nil {
+		warnf("cannot set channel %v again: %v", plan.FormatChannel(a.channel), err)
+	}
+}
+
+// count returns the number of times the radio was moved.
+func (a *channelAsserter) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.conflicts
+}
+
+// run checks the channel every interval and on every announced change,
+// until ctx is done.
+func (a *channelAsserter) run(ctx context.Context, interval time.Duration, changes <-chan nl80211util.ChannelChange) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+			}
+		case <-ticker.C:
+		}
+		a.check()
+	}
+}
+
+// verifying wraps a hopper.Config.Verify to set the channel again when the
+// retune was undone, instead of failing the hop.
+func (a *channelAsserter) verifying(verify func(channel int) error) func(channel int) error {
+	return func(channel int) error {
+		if err := verify(channel); err == nil {
+			return nil
+		}
+		// The retune is done, check it like during the dwell
+		a.hop(channel)
+		a.check()
+		return verify(channel)
+	}
+}
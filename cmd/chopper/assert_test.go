@@ -0,0 +1,81 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChannelAsserter(t *testing.T) {
+	frequency := 2412
+	var retuned []int
+	a := &channelAsserter{
+		frequency: func() (int, error) { return frequency, nil },
+		retune: func(channel int) error {
+			retuned = append(retuned, channel)
+			return nil
+		},
+	}
+
+	// Nothing to assert before the first hop
+	a.check()
+	a.hop(1)
+	a.check()
+
+	frequency = 2437
+	a.check()
+	// The hopper moving the radio is not a conflict
+	a.beforeHop(6)
+	a.check()
+	a.hop(6)
+	a.check()
+
+	if want := []int{1}; !reflect.DeepEqual(want, retuned) {
+		t.Errorf("retunes:\n- want: %v\n-  got: %v", want, retuned)
+	}
+	if want, got := 1, a.count(); want != got {
+		t.Errorf("conflicts:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestChannelAsserterVerifying(t *testing.T) {
+	frequency := 2412
+	a := &channelAsserter{
+		frequency: func() (int, error) { return frequency, nil },
+		retune: func(channel int) error {
+			frequency = 2437
+			return nil
+		},
+	}
+	verify := a.verifying(verifyTuning(a.frequency))
+
+	a.beforeHop(6)
+	if err := verify(6); err != nil {
+		t.Fatalf("verify: unexpected error %v", err)
+	}
+	if want, got := 1, a.count(); want != got {
+		t.Errorf("conflicts:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// A retune that does not stick still fails the hop
+	a.retune = func(int) error { return errors.New("busy") }
+	if err := verify(11); err == nil {
+		t.Error("verify: undone retune was accepted")
+	}
+}
@@ -0,0 +1,126 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	handler := requireToken("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+	}{
+		{"valid", "/stats", "Bearer secret", http.StatusNoContent},
+		{"missing", "/stats", "", http.StatusUnauthorized},
+		{"wrong", "/plan/set-plan", "Bearer secreT", http.StatusUnauthorized},
+		{"basic", "/stats", "Basic c2VjcmV0", http.StatusUnauthorized},
+		{"healthz", "/healthz", "", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("GET %v:\n- want: %v\n-  got: %v", tt.path, tt.status, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("no WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		file   string
+		output string
+		err    bool
+	}{
+		{"flag", "secret", "", "secret", false},
+		{"none", "", "", "", false},
+		{"file", "", file, "from-file", false},
+		{"file over environment", "secret", file, "from-file", false},
+		{"empty file", "", empty, "", true},
+		{"missing file", "", filepath.Join(dir, "missing"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := apiToken(tt.token, tt.file)
+			if (err != nil) != tt.err {
+				t.Fatalf("apiToken:\n- want error: %v\n-  got: %v", tt.err, err)
+			}
+			if got != tt.output {
+				t.Fatalf("apiToken:\n- want: %v\n-  got: %v", tt.output, got)
+			}
+		})
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		addr   string
+		output bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{"localhost:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.168.1.10:7777", false},
+		{"sensor1:7777", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isLoopback(tt.addr); got != tt.output {
+				t.Fatalf("isLoopback(%v):\n- want: %v\n-  got: %v", tt.addr, tt.output, got)
+			}
+		})
+	}
+}
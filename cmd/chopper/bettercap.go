@@ -0,0 +1,194 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// bettercapEvent is an event of the bettercap REST API.
+type bettercapEvent struct {
+	Tag  string          `json:"tag"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// bettercapHandshake is the data of a wifi.client.handshake event.
+type bettercapHandshake struct {
+	AP      string `json:"ap"`
+	Station string `json:"station"`
+	Full    bool   `json:"full"`
+}
+
+// bettercapClient polls the events of a bettercap REST API. Credentials in
+// the endpoint URL are sent with basic authentication.
+type bettercapClient struct {
+	endpoint string
+	client   *http.Client
+	last     time.Time
+}
+
+func newBettercapClient(endpoint string) *bettercapClient {
+	return &bettercapClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (b *bettercapClient) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, b.endpoint+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bettercap: %v", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("bettercap: %v", err)
+	}
+	return nil
+}
+
+// events returns the events received since the last call.
+func (b *bettercapClient) events(ctx context.Context) ([]bettercapEvent, error) {
+	var events []bettercapEvent
+	if err := b.get(ctx, "/api/events?n=100", &events); err != nil {
+		return nil, err
+	}
+
+	fresh := events[:0]
+	for _, event := range events {
+		if event.Time.After(b.last) {
+			fresh = append(fresh, event)
+		}
+	}
+	for _, event := range fresh {
+		if event.Time.After(b.last) {
+			b.last = event.Time
+		}
+	}
+	return fresh, nil
+}
+
+// apChannel returns the channel of an access point known to bettercap.
+func (b *bettercapClient) apChannel(ctx context.Context, mac string) (int, error) {
+	var session struct {
+		APs []struct {
+			MAC       string `json:"mac"`
+			Frequency int    `json:"frequency"`
+		} `json:"aps"`
+	}
+	if err := b.get(ctx, "/api/session/wifi", &session); err != nil {
+		return 0, err
+	}
+
+	for _, ap := range session.APs {
+		if strings.EqualFold(ap.MAC, mac) {
+			if channel := plan.ChannelOf(ap.Frequency); channel != 0 {
+				return channel, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("bettercap: unknown channel for %v", mac)
+}
+
+// watchBettercap locks hopping to the channel of an access point while
+// bettercap captures a handshake from it, until the handshake is complete
+// or nothing is captured for timeout.
+func watchBettercap(ctx context.Context, b *bettercapClient, shared *sharedPlan, interval time.Duration, timeout time.Duration) {
+	l := &channelLock{plan: shared, reason: "bettercap", timeout: timeout}
+
+	// Skip the events received before starting
+	if _, err := b.events(ctx); err != nil && ctx.Err() == nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	warn := func(err error) {
+		if err != nil && ctx.Err() == nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		events, err := b.events(ctx)
+		if err != nil {
+			warn(err)
+			continue
+		}
+
+		for _, event := range events {
+			if event.Tag != "wifi.client.handshake" {
+				continue
+			}
+
+			var handshake bettercapHandshake
+			if err := json.Unmarshal(event.Data, &handshake); err != nil {
+				warn(fmt.Errorf("bettercap: %v", err))
+				continue
+			}
+
+			if handshake.Full {
+				if l.channel != 0 {
+					_, _ = fmt.Fprintf(stderr, "bettercap: captured a full handshake from %v, resuming hopping\n", handshake.AP)
+				}
+				warn(l.release())
+				continue
+			}
+
+			channel, err := b.apChannel(ctx, handshake.AP)
+			if err != nil {
+				warn(err)
+				continue
+			}
+			if l.channel != channel {
+				_, _ = fmt.Fprintf(stderr, "bettercap: capturing a handshake from %v, locking on channel %v\n", handshake.AP, plan.FormatChannel(channel))
+			}
+			warn(l.lock(channel, time.Now()))
+		}
+
+		if l.channel != 0 && !time.Now().Before(l.until) {
+			_, _ = fmt.Fprintf(stderr, "bettercap: handshake capture timed out, resuming hopping\n")
+		}
+		warn(l.expire(time.Now()))
+	}
+}
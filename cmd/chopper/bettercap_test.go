@@ -0,0 +1,72 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBettercapClient(t *testing.T) {
+	events := `[
+		{"tag": "wifi.ap.new", "time": "2021-01-01T10:00:00Z", "data": {}},
+		{"tag": "wifi.client.handshake", "time": "2021-01-01T10:00:01Z", "data": {"ap": "00:11:22:33:44:01", "station": "02:00:00:00:00:01", "full": false}}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/events":
+			_, _ = w.Write([]byte(events))
+		case "/api/session/wifi":
+			_, _ = w.Write([]byte(`{"aps": [{"mac": "00:11:22:33:44:01", "frequency": 5180}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	b := newBettercapClient("http://user:pass@" + server.Listener.Addr().String())
+	ctx := context.Background()
+
+	got, err := b.events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Tag != "wifi.client.handshake" {
+		t.Fatalf("events:\n- want: 2 events\n-  got: %v", got)
+	}
+	if got, err := b.events(ctx); err != nil || len(got) != 0 {
+		t.Fatalf("events again:\n- want: no events\n-  got: %v, %v", got, err)
+	}
+
+	channel, err := b.apChannel(ctx, "00:11:22:33:44:01")
+	if err != nil || channel != 36 {
+		t.Fatalf("apChannel:\n- want: 36\n-  got: %v, %v", channel, err)
+	}
+	if _, err := b.apChannel(ctx, "00:11:22:33:44:02"); err == nil {
+		t.Fatalf("apChannel of an unknown AP: want error")
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// capabilityStatus lists the channels the radio can monitor and the
+// interfaces it can run.
+type capabilityStatus struct {
+	PHY      int   `json:"phy"`
+	Channels []int `json:"channels"`
+	// Radar channels are also in Channels, as monitoring them is passive.
+	Radar    []int `json:"radar,omitempty"`
+	Disabled []int `json:"disabled,omitempty"`
+
+	Iftypes       []string `json:"iftypes,omitempty"`
+	ActiveMonitor bool     `json:"active_monitor"`
+	FourAddrAP    bool     `json:"four_addr_ap"`
+	WDS           bool     `json:"wds"`
+}
+
+// radioCapabilities queries the channels and interface types of phy.
+func radioCapabilities(client *nl80211util.Client, phy int) (capabilityStatus, error) {
+	frequencies, err := client.WiphyFrequencies(phy)
+	if err != nil {
+		return capabilityStatus{}, err
+	}
+	capabilities, err := client.WiphyCapabilities(phy)
+	if err != nil {
+		return capabilityStatus{}, err
+	}
+
+	status := capabilitiesOf(phy, frequencies)
+	status.addInterfaces(capabilities)
+	return status, nil
+}
+
+// addInterfaces reports the interface types and features of the radio.
+func (s *capabilityStatus) addInterfaces(c nl80211util.WiphyCapabilities) {
+	for _, iftype := range c.Iftypes {
+		s.Iftypes = append(s.Iftypes, iftype.String())
+	}
+	s.ActiveMonitor = c.ActiveMonitor()
+	s.FourAddrAP = c.FourAddrAP()
+	s.WDS = c.SupportsIftype(nl80211util.InterfaceTypeWDS)
+}
+
+// capabilitiesOf sorts the frequencies of a radio into usable and disabled
+// channels.
+func capabilitiesOf(phy int, frequencies []nl80211util.WiphyFrequency) capabilityStatus {
+	status := capabilityStatus{PHY: phy, Channels: []int{}}
+	for _, f := range frequencies {
+		channel := plan.ChannelOf(f.Frequency)
+		if channel == 0 {
+			continue
+		}
+		if f.Disabled {
+			status.Disabled = append(status.Disabled, channel)
+			continue
+		}
+		status.Channels = append(status.Channels, channel)
+		if f.Radar {
+			status.Radar = append(status.Radar, channel)
+		}
+	}
+	return status
+}
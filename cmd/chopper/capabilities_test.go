@@ -0,0 +1,57 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestCapabilitiesOf(t *testing.T) {
+	got := capabilitiesOf(1, []nl80211util.WiphyFrequency{
+		{Frequency: 2412},
+		{Frequency: 2484, Disabled: true},
+		{Frequency: 5260, Radar: true, NoIR: true},
+		{Frequency: 5262},
+	})
+	want := capabilityStatus{PHY: 1, Channels: []int{1, 52}, Radar: []int{52}, Disabled: []int{14}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("capabilitiesOf:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
+
+func TestCapabilityStatusAddInterfaces(t *testing.T) {
+	status := capabilityStatus{PHY: 1, Channels: []int{1}}
+	status.addInterfaces(nl80211util.WiphyCapabilities{
+		Iftypes: []nl80211util.InterfaceType{
+			nl80211util.InterfaceTypeStation,
+			nl80211util.InterfaceTypeAPVLAN,
+			nl80211util.InterfaceTypeMonitor,
+		},
+	})
+	want := capabilityStatus{
+		PHY:        1,
+		Channels:   []int{1},
+		Iftypes:    []string{"managed", "AP/VLAN", "monitor"},
+		FourAddrAP: true,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("addInterfaces:\n- want: %+v\n-  got: %+v", want, status)
+	}
+}
@@ -0,0 +1,133 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// captureSocket is a raw AF_PACKET socket bound to a monitor interface.
+type captureSocket struct {
+	fd int
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// openCapture opens a capture socket on ifindex. Reads return after at most
+// timeout even if no frame was received.
+func openCapture(ifindex int, timeout time.Duration) (*captureSocket, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, err
+	}
+
+	err = unix.Bind(fd, &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ALL),
+		Ifindex:  ifindex,
+	})
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	return &captureSocket{fd: fd}, nil
+}
+
+// Read reads a single frame into b. It returns 0 and no error on timeout.
+func (c *captureSocket) Read(b []byte) (int, error) {
+	n, _, err := unix.Recvfrom(c.fd, b, 0)
+	if err == unix.EAGAIN || err == unix.EINTR {
+		return 0, nil
+	}
+
+	return n, err
+}
+
+// EnableTimestamps makes ReadTimestamp return the time the kernel received
+// each frame.
+func (c *captureSocket) EnableTimestamps() error {
+	return unix.SetsockoptInt(c.fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
+}
+
+// ReadTimestamp reads a single frame into b and returns its length before
+// truncation to len(b) and the time it was received. It returns 0 and no
+// error on timeout. Without EnableTimestamps the time is the time of the
+// read.
+func (c *captureSocket) ReadTimestamp(b []byte) (int, time.Time, error) {
+	var oob [64]byte
+	n, oobn, _, _, err := unix.Recvmsg(c.fd, b, oob[:], unix.MSG_TRUNC)
+	if err == unix.EAGAIN || err == unix.EINTR {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	ts := time.Now()
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return n, ts, nil
+	}
+	for _, m := range messages {
+		if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SO_TIMESTAMPNS && len(m.Data) >= int(unsafe.Sizeof(unix.Timespec{})) {
+			timespec := (*unix.Timespec)(unsafe.Pointer(&m.Data[0]))
+			ts = time.Unix(timespec.Unix())
+		}
+	}
+	return n, ts, nil
+}
+
+// SetFilter attaches a classic BPF filter to the socket.
+func (c *captureSocket) SetFilter(filter []bpf.RawInstruction) error {
+	if len(filter) == 0 {
+		return nil
+	}
+
+	instructions := make([]unix.SockFilter, len(filter))
+	for i, ins := range filter {
+		instructions[i] = unix.SockFilter{
+			Code: ins.Op,
+			Jt:   ins.Jt,
+			Jf:   ins.Jf,
+			K:    ins.K,
+		}
+	}
+
+	return unix.SetsockoptSockFprog(c.fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &unix.SockFprog{
+		Len:    uint16(len(instructions)),
+		Filter: &instructions[0],
+	})
+}
+
+func (c *captureSocket) Close() error {
+	return unix.Close(c.fd)
+}
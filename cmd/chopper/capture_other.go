@@ -0,0 +1,54 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/bpf"
+)
+
+// captureSocket captures frames with AF_PACKET, which only Linux has.
+type captureSocket struct{}
+
+func openCapture(ifindex int, timeout time.Duration) (*captureSocket, error) {
+	return nil, fmt.Errorf("capture: %w", errPlatform)
+}
+
+func (c *captureSocket) Read(b []byte) (int, error) {
+	return 0, errPlatform
+}
+
+func (c *captureSocket) EnableTimestamps() error {
+	return errPlatform
+}
+
+func (c *captureSocket) ReadTimestamp(b []byte) (int, time.Time, error) {
+	return 0, time.Time{}, errPlatform
+}
+
+func (c *captureSocket) SetFilter(filter []bpf.RawInstruction) error {
+	return errPlatform
+}
+
+func (c *captureSocket) Close() error {
+	return nil
+}
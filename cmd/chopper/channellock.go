@@ -0,0 +1,303 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+// planner is the part of hopper.Hopper used to lock the plan.
+type planner interface {
+	Channels() []int
+	SetChannels(channels []int) error
+}
+
+// sharedPlan owns the plan of a hopper on behalf of the features changing
+// it. Edits change the base plan, --targets steers it, a filter such as the
+// geofence leaves channels out of it, and locks move the hopper to a channel
+// until the last one is released, the latest deciding the channel meanwhile.
+type sharedPlan struct {
+	mu sync.Mutex
+	h  planner
+	// check, if set, checks the edits of the plan against the radio and
+	// returns the channels to keep
+	check func(channels []int) ([]int, error)
+	// base is the plan hopped once no lock is held
+	base []int
+	// steer, if set, derives the plan to hop from base
+	steer func(base []int) []int
+	// keep, if set, tells the channels of base that may be hopped, idle
+	// is paused while it keeps none
+	keep func(channel int) bool
+	idle pausablePlanner
+	// locks are the locked channels, the latest last
+	locks []heldChannel
+}
+
+type heldChannel struct {
+	reason  string
+	channel int
+}
+
+func newSharedPlan(h planner) *sharedPlan {
+	return &sharedPlan{h: h, base: h.Channels()}
+}
+
+// refresh takes the plan of the hopper as the base while nothing else
+// decides it, so that the changes of other features are kept.
+func (s *sharedPlan) refresh() {
+	if len(s.locks) == 0 && s.keep == nil && s.steer == nil {
+		s.base = s.h.Channels()
+	}
+}
+
+// planned returns the steered base plan, before filtering.
+func (s *sharedPlan) planned() []int {
+	if s.steer != nil {
+		return s.steer(s.base)
+	}
+	return s.base
+}
+
+// apply sets the steered and filtered base plan on the hopper, unless a
+// lock is held.
+func (s *sharedPlan) apply() error {
+	if len(s.locks) > 0 {
+		return nil
+	}
+
+	channels := s.planned()
+	if s.keep != nil {
+		planned := channels
+		channels = nil
+		for _, channel := range planned {
+			if s.keep(channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	if len(channels) == 0 && s.idle != nil {
+		s.idle.Pause()
+		return nil
+	}
+	if err := s.h.SetChannels(channels); err != nil {
+		return err
+	}
+	if s.idle != nil {
+		s.idle.Resume()
+	}
+	return nil
+}
+
+// Channels returns the base plan.
+func (s *sharedPlan) Channels() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	channels := make([]int, len(s.base))
+	copy(channels, s.base)
+	return channels
+}
+
+// SetChannels replaces the base plan.
+func (s *sharedPlan) SetChannels(channels []int) error {
+	if len(channels) == 0 {
+		return hopper.ErrNoChannels
+	}
+	if s.check != nil {
+		var err error
+		if channels, err = s.check(channels); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.base = make([]int, len(channels))
+	copy(s.base, channels)
+	return s.apply()
+}
+
+// AddChannel appends a channel to the base plan.
+func (s *sharedPlan) AddChannel(channel int) error {
+	if s.check != nil {
+		if _, err := s.check([]int{channel}); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	channels := make([]int, len(s.base), len(s.base)+1)
+	copy(channels, s.base)
+	s.base = append(channels, channel)
+	return s.apply()
+}
+
+// RemoveChannel removes every occurrence of a channel from the base plan.
+// The last channel cannot be removed.
+func (s *sharedPlan) RemoveChannel(channel int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	channels := make([]int, 0, len(s.base))
+	for _, c := range s.base {
+		if c != channel {
+			channels = append(channels, c)
+		}
+	}
+	if len(channels) == len(s.base) {
+		return fmt.Errorf("channel %v is not in the plan", channel)
+	}
+	if len(channels) == 0 {
+		return hopper.ErrNoChannels
+	}
+
+	s.base = channels
+	return s.apply()
+}
+
+// steerTo hops on steer(base) instead of the base plan, nil hopping on the
+// base plan again, and returns the steered plan.
+func (s *sharedPlan) steerTo(steer func(base []int) []int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	s.steer = steer
+	return s.planned(), s.apply()
+}
+
+// filter hops only on the channels of the base plan that keep accepts, all
+// of them if keep is nil, pausing idle while none is left. It returns how
+// many are left.
+func (s *sharedPlan) filter(keep func(channel int) bool, idle pausablePlanner) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	if keep == nil {
+		// The base stays in charge once filtered
+		keep = func(int) bool { return true }
+	}
+	s.keep = keep
+	s.idle = idle
+
+	left := 0
+	for _, channel := range s.planned() {
+		if keep(channel) {
+			left++
+		}
+	}
+	return left, s.apply()
+}
+
+// without returns the locks other than the one of reason.
+func (s *sharedPlan) without(reason string) []heldChannel {
+	locks := make([]heldChannel, 0, len(s.locks))
+	for _, held := range s.locks {
+		if held.reason != reason {
+			locks = append(locks, held)
+		}
+	}
+	return locks
+}
+
+// lock locks hopping to channel on behalf of reason, replacing the channel
+// it held before if any.
+func (s *sharedPlan) lock(reason string, channel int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	if err := s.h.SetChannels([]int{channel}); err != nil {
+		return err
+	}
+	s.locks = append(s.without(reason), heldChannel{reason: reason, channel: channel})
+	return nil
+}
+
+// unlock drops the lock of reason, the hopper going back to the channel of
+// the previous lock or, if none is left, to the plan.
+func (s *sharedPlan) unlock(reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks := s.without(reason)
+	if len(locks) == len(s.locks) {
+		return nil
+	}
+	s.locks = locks
+	if n := len(s.locks); n > 0 {
+		return s.h.SetChannels([]int{s.locks[n-1].channel})
+	}
+	return s.apply()
+}
+
+// channelLock locks hopping to a channel through a sharedPlan and releases
+// it after a timeout.
+type channelLock struct {
+	plan    *sharedPlan
+	reason  string
+	timeout time.Duration
+
+	channel int
+	until   time.Time
+}
+
+// lock locks hopping to channel until timeout from now, extending the lock
+// if it is already held on that channel.
+func (l *channelLock) lock(channel int, now time.Time) error {
+	if l.channel == channel {
+		l.until = now.Add(l.timeout)
+		return nil
+	}
+
+	if err := l.plan.lock(l.reason, channel); err != nil {
+		return err
+	}
+	l.channel = channel
+	l.until = now.Add(l.timeout)
+	return nil
+}
+
+// release drops the lock if it is held.
+func (l *channelLock) release() error {
+	if l.channel == 0 {
+		return nil
+	}
+
+	l.channel = 0
+	return l.plan.unlock(l.reason)
+}
+
+// expire releases the lock if it timed out.
+func (l *channelLock) expire(now time.Time) error {
+	if l.channel != 0 && !now.Before(l.until) {
+		return l.release()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakePlanner struct {
+	channels []int
+}
+
+func (p *fakePlanner) Channels() []int {
+	return p.channels
+}
+
+func (p *fakePlanner) SetChannels(channels []int) error {
+	p.channels = channels
+	return nil
+}
+
+func TestChannelLock(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	l := &channelLock{plan: newSharedPlan(p), reason: "test", timeout: 30 * time.Second}
+	start := time.Now()
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"lock", func() error { return l.lock(6, start) }, []int{6}},
+		{"extend", func() error { return l.lock(6, start.Add(20*time.Second)) }, []int{6}},
+		{"not expired", func() error { return l.expire(start.Add(40 * time.Second)) }, []int{6}},
+		{"move", func() error { return l.lock(36, start.Add(45*time.Second)) }, []int{36}},
+		{"expired", func() error { return l.expire(start.Add(75 * time.Second)) }, []int{1, 6, 11}},
+		{"relock", func() error { return l.lock(11, start.Add(80*time.Second)) }, []int{11}},
+		{"release", l.release, []int{1, 6, 11}},
+		{"release unlocked", l.release, []int{1, 6, 11}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(step.output, p.channels) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+}
+
+func TestSharedPlan(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	shared := newSharedPlan(p)
+	pause := &channelLock{plan: shared, reason: "pause", timeout: 30 * time.Second}
+	eapol := &channelLock{plan: shared, reason: "eapol", timeout: 10 * time.Second}
+	start := time.Now()
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"pause", func() error { return pause.lock(6, start) }, []int{6}},
+		{"handshake", func() error { return eapol.lock(6, start.Add(25*time.Second)) }, []int{6}},
+		{"pause expired", func() error { return pause.expire(start.Add(30 * time.Second)) }, []int{6}},
+		{"handshake expired", func() error { return eapol.expire(start.Add(35 * time.Second)) }, []int{1, 6, 11}},
+		{"handshake elsewhere", func() error { return eapol.lock(11, start.Add(40*time.Second)) }, []int{11}},
+		{"latest wins", func() error { return pause.lock(1, start.Add(41*time.Second)) }, []int{1}},
+		{"back to the handshake", pause.release, []int{11}},
+		{"released twice", pause.release, []int{11}},
+		{"last released", eapol.release, []int{1, 6, 11}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(step.output, p.channels) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	flag "github.com/spf13/pflag"
+)
+
+// killedFile records the processes stopped by check --kill, so that
+// check --restore can start them again.
+func killedFile() string {
+	return filepath.Join(lockDir, ProgramName+"-killed.json")
+}
+
+// killedProcess is a process stopped by check --kill.
+type killedProcess struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+	// Unit is the systemd service the process belonged to, if any.
+	Unit string `json:"unit,omitempty"`
+}
+
+// systemdUnit returns the systemd service of a process, read from its
+// cgroup.
+func systemdUnit(pid int) string {
+	b, err := ioutil.ReadFile(filepath.Join(procDir, fmt.Sprint(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		for _, part := range strings.Split(line, "/") {
+			if strings.HasSuffix(part, ".service") {
+				return part
+			}
+		}
+	}
+	return ""
+}
+
+// interferingProcesses returns the processes that may interfere with the
+// interface, or with any interface if name is empty.
+func interferingProcesses(name string) ([]competingManager, error) {
+	names := make(map[string]bool)
+	for n := range managerNames {
+		names[n] = true
+	}
+	for n := range interferingNames {
+		names[n] = true
+	}
+
+	if name == "" {
+		return findProcesses(names, nil), nil
+	}
+
+	iface, err := nl80211util.MonitorInterface(name)
+	if err != nil {
+		return nil, err
+	}
+	return findProcesses(names, append(phyInterfaces(iface.PHY), iface.Name)), nil
+}
+
+// restoreProcess starts a killed process again, through systemd when it was
+// a service.
+func restoreProcess(p killedProcess) error {
+	if p.Unit != "" {
+		return exec.Command("systemctl", "start", p.Unit).Run()
+	}
+
+	if len(p.Args) == 0 || p.Args[0] == "" {
+		return fmt.Errorf("unknown command line for %v", p.Name)
+	}
+	cmd := exec.Command(p.Args[0], p.Args[1:]...)
+	detach(cmd)
+	return cmd.Start()
+}
+
+// runCheck implements the check subcommand and returns the exit code.
+func runCheck(args []string) int {
+	var (
+		ifaceName string
+		kill      bool
+		restore   bool
+		colorMode string
+	)
+
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.StringVarP(&ifaceName, "interface", "i", "", "only list processes that may use this interface")
+	flags.BoolVar(&kill, "kill", false, "stop the listed processes")
+	flags.BoolVar(&restore, "restore", false, "start the processes stopped by --kill again")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if restore {
+		return runRestore()
+	}
+
+	processes, err := interferingProcesses(ifaceName)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(processes) == 0 {
+		fmt.Println("No interfering processes found.")
+		return 0
+	}
+
+	fmt.Println(paint(colorStdout, ansiBold, fmt.Sprintf("%-8s %s", "PID", "NAME")))
+	for _, p := range processes {
+		fmt.Printf("%-8d %s\n", p.PID, p.Name)
+	}
+	if !kill {
+		return 0
+	}
+
+	var killed []killedProcess
+	if b, err := ioutil.ReadFile(killedFile()); err == nil {
+		_ = json.Unmarshal(b, &killed)
+	}
+
+	code := 0
+	for _, p := range processes {
+		record := killedProcess{Name: p.Name, Args: p.Args, Unit: systemdUnit(p.PID)}
+
+		if record.Unit != "" {
+			err = exec.Command("systemctl", "stop", record.Unit).Run()
+		} else {
+			err = stopProcess(p.PID)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot stop %v: %v\n", p, err)
+			code = 1
+			continue
+		}
+
+		fmt.Printf("Stopped %v\n", p)
+		killed = append(killed, record)
+	}
+
+	b, err := json.MarshalIndent(killed, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(killedFile(), b, 0600)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot save stopped processes, --restore will not work: %v\n", err)
+	}
+	fmt.Printf("Run '%v check --restore' to start them again.\n", ProgramName)
+
+	return code
+}
+
+func runRestore() int {
+	b, err := ioutil.ReadFile(killedFile())
+	if os.IsNotExist(err) {
+		fmt.Println("Nothing to restore.")
+		return 0
+	} else if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	var killed []killedProcess
+	if err := json.Unmarshal(b, &killed); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v: %v\n", killedFile(), err)
+		return 1
+	}
+
+	// Services may be listed once per process
+	started := make(map[string]bool)
+	var failed []killedProcess
+	for _, p := range killed {
+		if p.Unit != "" && started[p.Unit] {
+			continue
+		}
+		if err := restoreProcess(p); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot restore %v: %v\n", p.Name, err)
+			failed = append(failed, p)
+			continue
+		}
+		started[p.Unit] = true
+		fmt.Printf("Restored %v\n", p.Name)
+	}
+
+	if len(failed) > 0 {
+		if b, err := json.MarshalIndent(failed, "", "  "); err == nil {
+			_ = ioutil.WriteFile(killedFile(), b, 0600)
+		}
+		return 1
+	}
+
+	_ = os.Remove(killedFile())
+	return 0
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(dir string) { procDir = dir }(procDir)
+	procDir = dir
+
+	tests := []struct {
+		pid    int
+		cgroup string
+		want   string
+	}{
+		{1, "0::/init.scope\n", ""},
+		{100, "0::/system.slice/NetworkManager.service\n", "NetworkManager.service"},
+		{200, "12:pids:/system.slice/wpa_supplicant.service\n1:name=systemd:/system.slice/wpa_supplicant.service\n", "wpa_supplicant.service"},
+		{300, "0::/user.slice/user-1000.slice/session-2.scope\n", ""},
+	}
+
+	for _, tt := range tests {
+		if err := os.MkdirAll(filepath.Join(dir, strconv.Itoa(tt.pid)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(tt.pid), "cgroup"), []byte(tt.cgroup), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range tests {
+		if got := systemdUnit(tt.pid); got != tt.want {
+			t.Fatalf("systemdUnit(%v):\n- want: %q\n-  got: %q", tt.pid, tt.want, got)
+		}
+	}
+}
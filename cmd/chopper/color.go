@@ -0,0 +1,253 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiReverse   = "\x1b[7m"
+	ansiRed       = "\x1b[31m"
+	ansiYellow    = "\x1b[33m"
+	ansiClearLine = "\r\x1b[K"
+)
+
+var (
+	// stderr is where human-readable messages go. When stderr is a
+	// terminal it is replaced by a terminal that colors them and keeps
+	// the status line out of their way.
+	stderr io.Writer = os.Stderr
+
+	// term is the terminal behind stderr, nil when stderr is not one.
+	term *terminal
+
+	// colorStdout is set when the tables printed on stdout should be
+	// colored.
+	colorStdout bool
+)
+
+// useColor decides whether to color f given the --color mode. auto
+// colors terminals unless NO_COLOR is set or TERM is dumb.
+func useColor(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		return isTerminal(f), nil
+	default:
+		return false, fmt.Errorf("unknown color mode %v", mode)
+	}
+}
+
+// setupColor applies --color to stdout and stderr.
+func setupColor(mode string) error {
+	var err error
+	colorStdout, err = useColor(mode, os.Stdout)
+	if err != nil {
+		return err
+	}
+	color, _ := useColor(mode, os.Stderr)
+	if isTerminal(os.Stderr) {
+		term = &terminal{out: os.Stderr, color: color, width: func() int {
+			return terminalWidth(os.Stderr)
+		}}
+		stderr = term
+	}
+	return nil
+}
+
+// paint wraps s in the given ANSI code if color is set.
+func paint(color bool, code, s string) string {
+	if !color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// isDFS reports whether channel needs radar detection in most regulatory
+// domains.
+func isDFS(channel int) bool {
+	return plan.BandOf(channel) == plan.Band5GHz && channel >= 52 && channel <= 144
+}
+
+// channelLabel returns channel followed by * if it is a DFS channel.
+func channelLabel(channel int) string {
+	if isDFS(channel) {
+		return plan.FormatChannel(channel) + "*"
+	}
+	return plan.FormatChannel(channel)
+}
+
+// terminal writes messages to a terminal, coloring errors and warnings
+// and redrawing the status line below them.
+type terminal struct {
+	mu    sync.Mutex
+	out   io.Writer
+	color bool
+	width func() int
+
+	// plan returns the channels shown in the status line.
+	plan    func() []int
+	status  string
+	partial bool
+}
+
+// Write writes p above the status line. Lines starting with ERROR: are
+// printed in red and lines starting with WARNING: in yellow.
+func (t *terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var buf bytes.Buffer
+	if t.status != "" && !t.partial {
+		buf.WriteString(ansiClearLine)
+	}
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		buf.WriteString(t.colorLine(string(line)))
+	}
+	t.partial = p[len(p)-1] != '\n'
+	if t.status != "" && !t.partial {
+		buf.WriteString(t.status)
+	}
+	if _, err := t.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *terminal) colorLine(line string) string {
+	if !t.color || t.partial {
+		return line
+	}
+	text := strings.TrimSuffix(line, "\n")
+	switch {
+	case strings.HasPrefix(text, "ERROR:"):
+		text = paint(true, ansiRed+ansiBold, text)
+	case strings.HasPrefix(text, "WARNING:"):
+		text = paint(true, ansiYellow, text)
+	default:
+		return line
+	}
+	return text + line[len(strings.TrimSuffix(line, "\n")):]
+}
+
+// hop redraws the status line with channel as the current channel.
+func (t *terminal) hop(channel int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.plan == nil {
+		return
+	}
+	t.status = formatStatus(t.plan(), channel, t.width(), t.color)
+	if !t.partial {
+		_, _ = io.WriteString(t.out, ansiClearLine+t.status)
+	}
+}
+
+// clear removes the status line.
+func (t *terminal) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != "" && !t.partial {
+		_, _ = io.WriteString(t.out, ansiClearLine)
+	}
+	t.status = ""
+}
+
+// formatStatus returns a status line listing every channel of the plan
+// once, with current highlighted and DFS channels marked with *. If the
+// line does not fit in width columns only the channels around current
+// are shown.
+func formatStatus(channels []int, current, width int, color bool) string {
+	var labels []string
+	at := -1
+	seen := make(map[int]bool)
+	for _, channel := range channels {
+		if seen[channel] {
+			continue
+		}
+		seen[channel] = true
+		if channel == current {
+			at = len(labels)
+		}
+		labels = append(labels, channelLabel(channel))
+	}
+
+	prefix := fmt.Sprintf("ch %-4s ", channelLabel(current))
+	if at < 0 {
+		return paint(color, ansiBold, prefix)
+	}
+
+	// Grow a window around current while it fits.
+	from, to := at, at+1
+	size := len(prefix) + len(labels[at]) + 2
+	for grown := true; grown; {
+		grown = false
+		if to < len(labels) && size+len(labels[to])+1+8 < width {
+			size += len(labels[to]) + 1
+			to++
+			grown = true
+		}
+		if from > 0 && size+len(labels[from-1])+1+8 < width {
+			size += len(labels[from-1]) + 1
+			from--
+			grown = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(paint(color, ansiBold, prefix))
+	if from > 0 {
+		b.WriteString("... ")
+	}
+	for i := from; i < to; i++ {
+		if i > from {
+			b.WriteString(" ")
+		}
+		if i != at {
+			b.WriteString(labels[i])
+		} else if color {
+			b.WriteString(paint(true, ansiReverse+ansiBold, labels[i]))
+		} else {
+			b.WriteString("[" + labels[i] + "]")
+		}
+	}
+	if to < len(labels) {
+		b.WriteString(" ...")
+	}
+	return b.String()
+}
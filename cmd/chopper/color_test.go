@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChannelLabel(t *testing.T) {
+	tests := []struct {
+		channel int
+		output  string
+	}{
+		{1, "1"},
+		{36, "36"},
+		{52, "52*"},
+		{144, "144*"},
+		{149, "149"},
+		{1000 + 53, "6g53"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			if got := channelLabel(tt.channel); got != tt.output {
+				t.Fatalf("channelLabel(%v):\n- want: %v\n-  got: %v", tt.channel, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestFormatStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []int
+		current  int
+		width    int
+		output   string
+	}{
+		{"all", []int{1, 6, 11, 52}, 6, 80, "ch 6    1 [6] 11 52*"},
+		{"duplicates", []int{1, 6, 1, 11, 1}, 1, 80, "ch 1    [1] 6 11"},
+		{"unknown", []int{1, 6, 11}, 3, 80, "ch 3    "},
+		{"window", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, 6, 28, "ch 6    ... 4 5 [6] 7 8 ..."},
+		{"start", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, 1, 28, "ch 1    [1] 2 3 4 5 ..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatStatus(tt.channels, tt.current, tt.width, false); got != tt.output {
+				t.Fatalf("formatStatus(%v, %v, %v):\n- want: %q\n-  got: %q", tt.channels, tt.current, tt.width, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	var out bytes.Buffer
+	term := &terminal{out: &out, color: true, width: func() int { return 80 }}
+	term.plan = func() []int { return []int{1, 6} }
+
+	_, _ = term.Write([]byte("WARNING: first\n"))
+	term.hop(6)
+	_, _ = term.Write([]byte("ERROR: second\nplain\n"))
+	term.clear()
+
+	status := ansiBold + "ch 6    " + ansiReset + "1 " + ansiReverse + ansiBold + "6" + ansiReset
+	want := ansiYellow + "WARNING: first" + ansiReset + "\n" +
+		ansiClearLine + status +
+		ansiClearLine + ansiRed + ansiBold + "ERROR: second" + ansiReset + "\nplain\n" + status +
+		ansiClearLine
+	if got := out.String(); got != want {
+		t.Fatalf("terminal output:\n- want: %q\n-  got: %q", want, got)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// phyConflicts returns the other interfaces on the radio of iface. active
+// holds the ones in use, an associated station or a running AP, whose
+// connection hopping would break.
+func phyConflicts(interfaces []nl80211util.Interface, iface *nl80211util.Interface) (active, idle []nl80211util.Interface) {
+	for _, other := range interfaces {
+		if other.PHY != iface.PHY || other.Index == iface.Index {
+			continue
+		}
+
+		// Interfaces report a frequency only while on a channel
+		if other.Type != nl80211util.InterfaceTypeMonitor && other.Frequency != 0 {
+			active = append(active, other)
+		} else {
+			idle = append(idle, other)
+		}
+	}
+
+	return active, idle
+}
+
+// checkPhyInterfaces warns about the other interfaces on the radio of iface
+// and reports whether it is safe to continue.
+func checkPhyInterfaces(client *nl80211util.Client, iface *nl80211util.Interface, force bool) bool {
+	interfaces, err := client.Interfaces()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot list the interfaces of phy%v: %v\n", iface.PHY, err)
+		return true
+	}
+
+	active, idle := phyConflicts(interfaces, iface)
+	for _, other := range idle {
+		_, _ = fmt.Fprintf(stderr, "WARNING: %v (%v) is on phy%v, its channel will change with every hop.\n", other.Name, other.Type, iface.PHY)
+	}
+	if len(active) == 0 {
+		return true
+	}
+
+	_, _ = fmt.Fprintf(stderr, "WARNING: ********************************************************\n")
+	_, _ = fmt.Fprintf(stderr, "WARNING: these interfaces share phy%v with %v and are in use:\n", iface.PHY, iface.Name)
+	for _, other := range active {
+		_, _ = fmt.Fprintf(stderr, "WARNING:   %v (%v on %v MHz)\n", other.Name, other.Type, other.Frequency)
+	}
+	_, _ = fmt.Fprintf(stderr, "WARNING: hopping will break their connections. Bring them down\n")
+	_, _ = fmt.Fprintf(stderr, "WARNING: or use another radio.\n")
+	_, _ = fmt.Fprintf(stderr, "WARNING: ********************************************************\n")
+
+	if !force {
+		_, _ = fmt.Fprintf(stderr, "ERROR: refusing to start, pass --force to continue anyway\n")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestPhyConflicts(t *testing.T) {
+	monitor := nl80211util.Interface{Index: 3, Name: "mon0", PHY: 0, Type: nl80211util.InterfaceTypeMonitor}
+	connected := nl80211util.Interface{Index: 2, Name: "wlan0", PHY: 0, Type: nl80211util.InterfaceTypeStation, Frequency: 2437}
+	disconnected := nl80211util.Interface{Index: 4, Name: "wlan2", PHY: 0, Type: nl80211util.InterfaceTypeStation}
+	ap := nl80211util.Interface{Index: 5, Name: "ap0", PHY: 0, Type: nl80211util.InterfaceTypeAP, Frequency: 5180}
+	otherMonitor := nl80211util.Interface{Index: 6, Name: "mon1", PHY: 0, Type: nl80211util.InterfaceTypeMonitor, Frequency: 2412}
+	otherPhy := nl80211util.Interface{Index: 7, Name: "wlan1", PHY: 1, Type: nl80211util.InterfaceTypeStation, Frequency: 2412}
+
+	interfaces := []nl80211util.Interface{connected, monitor, disconnected, ap, otherMonitor, otherPhy}
+	active, idle := phyConflicts(interfaces, &monitor)
+
+	if want := []nl80211util.Interface{connected, ap}; !reflect.DeepEqual(want, active) {
+		t.Fatalf("phyConflicts active:\n- want: %v\n-  got: %v", want, active)
+	}
+	if want := []nl80211util.Interface{disconnected, otherMonitor}; !reflect.DeepEqual(want, idle) {
+		t.Fatalf("phyConflicts idle:\n- want: %v\n-  got: %v", want, idle)
+	}
+}
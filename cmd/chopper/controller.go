@@ -0,0 +1,513 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+)
+
+// streamRetry is how long the controller waits before reconnecting to the
+// event stream of an agent.
+const streamRetry = 5 * time.Second
+
+// agentClient talks to the API of a chopper agent.
+type agentClient struct {
+	name  string
+	base  string
+	token string
+	// client has a timeout, stream is used for GET /events which lasts as
+	// long as the agent runs.
+	client *http.Client
+	stream *http.Client
+}
+
+func newAgentClient(rawurl, token string, tlsConfig *tls.Config) (*agentClient, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid agent URL %q", rawurl)
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	return &agentClient{
+		name:   u.Host,
+		base:   strings.TrimSuffix(u.String(), "/"),
+		token:  token,
+		client: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+		stream: &http.Client{Transport: transport},
+	}, nil
+}
+
+func (a *agentClient) request(ctx context.Context, method, path string, form url.Values) (*http.Request, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, a.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	return req, nil
+}
+
+// call sends a request and decodes the JSON reply into v.
+func (a *agentClient) call(ctx context.Context, method, path string, form url.Values, v interface{}) error {
+	req, err := a.request(ctx, method, path, form)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%v %v: %v", method, path, apiErr.Error)
+		}
+		return fmt.Errorf("%v %v: %v", method, path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (a *agentClient) capabilities(ctx context.Context) (capabilityStatus, error) {
+	var status capabilityStatus
+	err := a.call(ctx, http.MethodGet, "/capabilities", nil, &status)
+	return status, err
+}
+
+func (a *agentClient) setPlan(ctx context.Context, channels []int) error {
+	var status planStatus
+	return a.call(ctx, http.MethodPost, "/plan/set-plan", url.Values{"channels": {plan.Format(channels)}}, &status)
+}
+
+func (a *agentClient) plan(ctx context.Context) (planStatus, error) {
+	var status planStatus
+	err := a.call(ctx, http.MethodGet, "/plan", nil, &status)
+	return status, err
+}
+
+func (a *agentClient) stats(ctx context.Context) (hopper.Stats, error) {
+	var stats hopper.Stats
+	err := a.call(ctx, http.MethodGet, "/stats", nil, &stats)
+	return stats, err
+}
+
+// events passes the events of the agent to fn until ctx is done or the
+// stream breaks.
+func (a *agentClient) events(ctx context.Context, fn func(events.Event)) error {
+	req, err := a.request(ctx, http.MethodGet, "/events", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.stream.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /events: %v", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event events.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		fn(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// distributePlan splits the global plan among agents, each channel going
+// to the least loaded agent supporting it. Channels keep their weight and
+// their order in the global plan. Channels no agent supports are returned
+// in missing.
+func distributePlan(global []int, supported [][]int) (plans [][]int, missing []int) {
+	return distribute(global, supported, make([]int, len(supported)))
+}
+
+// distribute is distributePlan where agents of lower rank are preferred to
+// less loaded ones.
+func distribute(global []int, supported [][]int, rank []int) (plans [][]int, missing []int) {
+	weight := make(map[int]int)
+	var order []int
+	for _, channel := range global {
+		if weight[channel] == 0 {
+			order = append(order, channel)
+		}
+		weight[channel]++
+	}
+
+	supports := make([]map[int]bool, len(supported))
+	for i, channels := range supported {
+		supports[i] = make(map[int]bool)
+		for _, channel := range channels {
+			supports[i][channel] = true
+		}
+	}
+
+	owner := make(map[int]int)
+	load := make([]int, len(supported))
+	for _, channel := range order {
+		best := -1
+		for i := range supported {
+			if !supports[i][channel] {
+				continue
+			}
+			if best < 0 || rank[i] < rank[best] || rank[i] == rank[best] && load[i] < load[best] {
+				best = i
+			}
+		}
+		if best < 0 {
+			missing = append(missing, channel)
+			continue
+		}
+		owner[channel] = best
+		load[best] += weight[channel]
+	}
+
+	plans = make([][]int, len(supported))
+	for _, channel := range global {
+		if i, ok := owner[channel]; ok {
+			plans[i] = append(plans[i], channel)
+		}
+	}
+	return plans, missing
+}
+
+// unionChannels returns all the channels supported by any agent, sorted.
+func unionChannels(supported [][]int) []int {
+	seen := make(map[int]bool)
+	var channels []int
+	for _, list := range supported {
+		for _, channel := range list {
+			if !seen[channel] {
+				seen[channel] = true
+				channels = append(channels, channel)
+			}
+		}
+	}
+	sort.Ints(channels)
+	return channels
+}
+
+// agentView is what the controller knows about an agent.
+type agentView struct {
+	plan    string
+	channel int
+	stats   hopper.Stats
+	err     error
+}
+
+// controllerView aggregates the state of all the agents.
+type controllerView struct {
+	mu     sync.Mutex
+	agents map[string]*agentView
+}
+
+func (v *controllerView) agent(name string) *agentView {
+	view, ok := v.agents[name]
+	if !ok {
+		view = &agentView{}
+		v.agents[name] = view
+	}
+	return view
+}
+
+func (v *controllerView) hop(name string, channel int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.agent(name).channel = channel
+}
+
+func (v *controllerView) update(name string, plan string, stats hopper.Stats, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	view := v.agent(name)
+	view.err = err
+	if err == nil {
+		view.plan = plan
+		view.stats = stats
+	}
+}
+
+// poll updates the plan and counters of every agent.
+func (v *controllerView) poll(ctx context.Context, agents []*agentClient) {
+	for _, agent := range agents {
+		status, err := agent.plan(ctx)
+		var stats hopper.Stats
+		if err == nil {
+			stats, err = agent.stats(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		v.update(agent.name, status.Plan, stats, err)
+	}
+}
+
+// write prints a table with a line per agent and the totals.
+func (v *controllerView) write(w io.Writer, names []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	_, _ = fmt.Fprintln(w, paint(colorStdout, ansiBold, fmt.Sprintf("%-22s%-9s%-9s%-10s%-10s%s", "AGENT", "CHANNEL", "HOPS", "FAILURES", "P50", "PLAN")))
+	var hops, failures int
+	for _, name := range names {
+		view := v.agent(name)
+		if view.err != nil {
+			_, _ = fmt.Fprintf(w, "%-22s%s\n", name, paint(colorStdout, ansiRed, "unreachable: "+view.err.Error()))
+			continue
+		}
+		channel := "-"
+		if view.channel != 0 {
+			channel = channelLabel(view.channel)
+		}
+		_, _ = fmt.Fprintf(w, "%-22s%-9s%-9d%-10d%-10v%s\n", name, channel, view.stats.Hops, view.stats.Failures,
+			view.stats.Latency.P50.Round(10*time.Microsecond), view.plan)
+		hops += view.stats.Hops
+		failures += view.stats.Failures
+	}
+	_, _ = fmt.Fprintf(w, "%-22s%-9s%-9d%d\n\n", "total", "", hops, failures)
+}
+
+// clientTLSConfig returns the TLS configuration used to connect to agents.
+func clientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %v", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// runController distributes a plan across agents and reports their state
+// until ctx is done.
+func runController(ctx context.Context, args []string) int {
+	var (
+		agentURLs []string
+		chans     string
+		caFile    string
+		certFile  string
+		keyFile   string
+		token     string
+		tokenFile string
+		interval  time.Duration
+		output    string
+		colorMode string
+	)
+
+	flags := flag.NewFlagSet("controller", flag.ExitOnError)
+	flags.StringArrayVar(&agentURLs, "agent", nil, "agent to control, e.g. https://sensor1:7777 (repeatable)")
+	flags.StringVarP(&chans, "channels", "c", "", "global channel plan shared among the agents, with the syntax of chopper -c (default: every channel an agent supports)")
+	flags.StringVar(&caFile, "tls-ca", "", "verify agents with the CAs in this PEM file instead of the system ones")
+	flags.StringVar(&certFile, "tls-cert", "", "client certificate presented to agents using --tls-client-ca")
+	flags.StringVar(&keyFile, "tls-key", "", "private key of --tls-cert")
+	flags.StringVar(&token, "api-token", os.Getenv("CHOPPER_API_TOKEN"), "bearer token of the agents")
+	flags.StringVar(&tokenFile, "api-token-file", "", "read the bearer token from this file")
+	flags.DurationVar(&interval, "interval", 5*time.Second, "interval between status updates")
+	flags.StringVarP(&output, "output", "o", "text", "output format: text (a status table every interval) or json (the events of all agents)")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(agentURLs) == 0 {
+		flags.Usage()
+		return 1
+	}
+	if output != "text" && output != "json" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown output format %v\n", output)
+		return 1
+	}
+	token, err := apiToken(token, tokenFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot read API token: %v\n", err)
+		return 1
+	}
+	tlsConfig, err := clientTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	var agents []*agentClient
+	var supported [][]int
+	for _, rawurl := range agentURLs {
+		agent, err := newAgentClient(rawurl, token, tlsConfig)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		capabilities, err := agent.capabilities(ctx)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: skipping agent %v: %v\n", agent.name, err)
+			continue
+		}
+		agents = append(agents, agent)
+		supported = append(supported, capabilities.Channels)
+	}
+	if len(agents) == 0 {
+		_, _ = fmt.Fprintf(stderr, "ERROR: no agent is reachable\n")
+		return 1
+	}
+
+	global := unionChannels(supported)
+	if chans != "" {
+		if plan.HasMultipliers(chans) {
+			global, err = plan.ParseMultipliers(chans, global)
+		} else {
+			global, err = plan.Parse(chans)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+
+	plans, missing := distributePlan(global, supported)
+	if len(missing) > 0 {
+		_, _ = fmt.Fprintf(stderr, "WARNING: no agent supports channels %v\n", plan.Format(missing))
+	}
+	for i, agent := range agents {
+		if len(plans[i]) == 0 {
+			_, _ = fmt.Fprintf(stderr, "WARNING: no channel left for agent %v, leaving its plan unchanged\n", agent.name)
+			continue
+		}
+		if err := agent.setPlan(ctx, plans[i]); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot set the plan of agent %v: %v\n", agent.name, err)
+			return 1
+		}
+		_, _ = fmt.Fprintf(stderr, "Agent %v: %v\n", agent.name, plan.Format(plans[i]))
+	}
+
+	view := &controllerView{agents: make(map[string]*agentView)}
+	encoder := events.NewEncoder(os.Stdout)
+	var wg sync.WaitGroup
+	for _, agent := range agents {
+		agent := agent
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Across reconnects, to count the hops lost meanwhile
+			var gaps events.Gaps
+			for {
+				err := agent.events(ctx, func(event events.Event) {
+					event.Agent = agent.name
+					if gap := gaps.Observe(event); gap.Restarted {
+						warnf("agent %v restarted, %v hop events lost", agent.name, gap.Missed)
+					} else if gap.Missed > 0 {
+						warnf("agent %v: %v hop events lost", agent.name, gap.Missed)
+					}
+					if event.Type == events.TypeHop {
+						view.hop(agent.name, event.Channel)
+					}
+					if output == "json" {
+						_ = encoder.Encode(event)
+					}
+				})
+				if ctx.Err() != nil {
+					return
+				}
+				_, _ = fmt.Fprintf(stderr, "WARNING: lost the event stream of agent %v: %v\n", agent.name, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(streamRetry):
+				}
+			}
+		}()
+	}
+
+	names := make([]string, len(agents))
+	for i, agent := range agents {
+		names[i] = agent.name
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		view.poll(ctx, agents)
+		if output == "text" && ctx.Err() == nil {
+			view.write(os.Stdout, names)
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,192 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+func TestDistributePlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		global    []int
+		supported [][]int
+		plans     [][]int
+		missing   []int
+	}{
+		{
+			"balanced",
+			[]int{1, 6, 11, 36},
+			[][]int{{1, 6, 11, 36}, {1, 6, 11, 36}},
+			[][]int{{1, 11}, {6, 36}},
+			nil,
+		},
+		{
+			"capabilities",
+			[]int{1, 36, 40, 6},
+			[][]int{{1, 6, 11}, {36, 40}},
+			[][]int{{1, 6}, {36, 40}},
+			nil,
+		},
+		{
+			"weights",
+			[]int{1, 6, 1, 11, 1},
+			[][]int{{1, 6, 11}, {1, 6, 11}},
+			[][]int{{1, 1, 1}, {6, 11}},
+			nil,
+		},
+		{
+			"missing",
+			[]int{1, 149},
+			[][]int{{1}, {1, 6}},
+			[][]int{{1}, nil},
+			[]int{149},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans, missing := distributePlan(tt.global, tt.supported)
+			if !reflect.DeepEqual(plans, tt.plans) {
+				t.Fatalf("distributePlan plans:\n- want: %v\n-  got: %v", tt.plans, plans)
+			}
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Fatalf("distributePlan missing:\n- want: %v\n-  got: %v", tt.missing, missing)
+			}
+		})
+	}
+}
+
+func TestUnionChannels(t *testing.T) {
+	got := unionChannels([][]int{{6, 1}, {36, 1, 11}})
+	if want := []int{1, 6, 11, 36}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unionChannels:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+// fakeAgent serves the agent API without a radio.
+func fakeAgent(t *testing.T, stream *eventStream) (*httptest.Server, *[]string) {
+	var plans []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/capabilities", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, capabilityStatus{Channels: []int{1, 6, 11}})
+	})
+	mux.HandleFunc("/plan/set-plan", func(w http.ResponseWriter, r *http.Request) {
+		plans = append(plans, r.FormValue("channels"))
+		writeJSON(w, http.StatusOK, planStatus{Plan: r.FormValue("channels")})
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, hopper.Stats{Hops: 42, Failures: 1})
+	})
+	mux.Handle("/events", stream)
+	server := httptest.NewServer(requireToken("secret", mux))
+	t.Cleanup(server.Close)
+	return server, &plans
+}
+
+func TestAgentClient(t *testing.T) {
+	stream := newEventStream()
+	server, plans := fakeAgent(t, stream)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	unauthorized, err := newAgentClient(server.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unauthorized.capabilities(ctx); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Fatalf("capabilities without token: %v", err)
+	}
+
+	agent, err := newAgentClient(server.URL+"/", "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capabilities, err := agent.capabilities(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 6, 11}; !reflect.DeepEqual(capabilities.Channels, want) {
+		t.Fatalf("capabilities:\n- want: %v\n-  got: %v", want, capabilities.Channels)
+	}
+	if err := agent.setPlan(ctx, []int{1, 1, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1,1,6"}; !reflect.DeepEqual(*plans, want) {
+		t.Fatalf("plans:\n- want: %v\n-  got: %v", want, *plans)
+	}
+	stats, err := agent.stats(ctx)
+	if err != nil || stats.Hops != 42 {
+		t.Fatalf("stats: %+v, %v", stats, err)
+	}
+
+	received := make(chan events.Event, 1)
+	go func() {
+		_ = agent.events(ctx, func(event events.Event) {
+			received <- event
+		})
+	}()
+	hop := events.New(events.TypeHop)
+	hop.Channel = 6
+	for {
+		if err := stream.Encode(hop); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-received:
+			if event.Channel != 6 {
+				t.Fatalf("event: %+v", event)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+			// Not subscribed yet
+		case <-ctx.Done():
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func TestControllerViewWrite(t *testing.T) {
+	view := &controllerView{agents: make(map[string]*agentView)}
+	view.update("sensor1:7777", "1,6", hopper.Stats{Hops: 10, Failures: 1, Latency: hopper.Latency{P50: 1500 * time.Microsecond}}, nil)
+	view.hop("sensor1:7777", 52)
+	view.update("sensor2:7777", "", hopper.Stats{}, fmt.Errorf("connection refused"))
+
+	var out bytes.Buffer
+	view.write(&out, []string{"sensor1:7777", "sensor2:7777"})
+	want := "AGENT                 CHANNEL  HOPS     FAILURES  P50       PLAN\n" +
+		"sensor1:7777          52*      10       1         1.5ms     1,6\n" +
+		"sensor2:7777          unreachable: connection refused\n" +
+		"total                          10       1\n\n"
+	if got := out.String(); got != want {
+		t.Fatalf("write:\n- want: %q\n-  got: %q", want, got)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+const (
+	// alertDeauthFlood is the alert raised by deauthMonitor.
+	alertDeauthFlood = "deauth_flood"
+	// deauthCooldown is the minimum time between alerts on a channel.
+	deauthCooldown = time.Minute
+)
+
+// deauthCounts are the frames counted on a channel.
+type deauthCounts struct {
+	deauth   int
+	disassoc int
+	// targets counts the frames by BSSID
+	targets  map[string]int
+	listened time.Duration
+}
+
+// deauthMonitor counts deauthentication and disassociation frames per
+// channel, over the time spent listening on it, and raises an alert when
+// their rate reaches threshold frames per second. Rates are computed once
+// window was spent on a channel, so that short dwells are accumulated.
+type deauthMonitor struct {
+	threshold float64
+	window    time.Duration
+
+	mu        sync.Mutex
+	channel   int
+	since     time.Time
+	counts    map[int]*deauthCounts
+	lastAlert map[int]time.Time
+}
+
+func newDeauthMonitor(threshold float64, window time.Duration) *deauthMonitor {
+	return &deauthMonitor{
+		threshold: threshold,
+		window:    window,
+		counts:    make(map[int]*deauthCounts),
+		lastAlert: make(map[int]time.Time),
+	}
+}
+
+func (m *deauthMonitor) countsOf(channel int) *deauthCounts {
+	c, ok := m.counts[channel]
+	if !ok {
+		c = &deauthCounts{targets: make(map[string]int)}
+		m.counts[channel] = c
+	}
+	return c
+}
+
+// observe is a frameObserver.
+func (m *deauthMonitor) observe(channel int, packet dot11.Packet) {
+	f := packet.Frame
+	if f.Type != dot11.TypeManagement || (f.Subtype != dot11.SubtypeDeauthentication && f.Subtype != dot11.SubtypeDisassociation) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.countsOf(channel)
+	if f.Subtype == dot11.SubtypeDeauthentication {
+		c.deauth++
+	} else {
+		c.disassoc++
+	}
+	c.targets[f.BSSID().String()]++
+}
+
+// leave records that the radio leaves its channel at now and returns the
+// alert it raises, if any.
+func (m *deauthMonitor) leave(now time.Time) (events.Event, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.channel == 0 {
+		return events.Event{}, false
+	}
+	channel := m.channel
+	c := m.countsOf(channel)
+	c.listened += now.Sub(m.since)
+	m.channel = 0
+	if c.listened < m.window {
+		return events.Event{}, false
+	}
+
+	delete(m.counts, channel)
+	frames := c.deauth + c.disassoc
+	rate := float64(frames) / c.listened.Seconds()
+	if frames == 0 || rate < m.threshold || now.Sub(m.lastAlert[channel]) < deauthCooldown {
+		return events.Event{}, false
+	}
+	m.lastAlert[channel] = now
+
+	event := events.New(events.TypeAlert)
+	event.Time = now.UTC()
+	event.Alert = alertDeauthFlood
+	event.Channel = channel
+	event.Frequency = plan.Frequency(channel)
+	event.Frames = frames
+	event.Rate = rate
+	for bssid, n := range c.targets {
+		if n > c.targets[event.BSSID] || (n == c.targets[event.BSSID] && bssid < event.BSSID) {
+			event.BSSID = bssid
+		}
+	}
+	_, _ = fmt.Fprintf(stderr, "WARNING: deauthentication flood on channel %v: %.1f frames/s (%v deauthentication, %v disassociation), mostly %v\n",
+		plan.FormatChannel(channel), rate, c.deauth, c.disassoc, describeMAC(event.BSSID))
+	return event, true
+}
+
+// arrive records that the radio is on channel since now.
+func (m *deauthMonitor) arrive(channel int, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.channel = channel
+	m.since = now
+}
+
+// beforeHop is registered as a BeforeHop callback.
+func (m *deauthMonitor) beforeHop(int) {
+	if event, ok := m.leave(time.Now()); ok {
+		emit(event)
+		countAlert(event)
+	}
+}
+
+// hop is registered as an OnHop callback.
+func (m *deauthMonitor) hop(channel int) {
+	m.arrive(channel, time.Now())
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+)
+
+// testDeauth returns a deauthentication frame sent by bssid, or a
+// disassociation frame if disassoc is set.
+func testDeauth(bssid []byte, disassoc bool) dot11.Packet {
+	subtype := byte(dot11.SubtypeDeauthentication)
+	if disassoc {
+		subtype = dot11.SubtypeDisassociation
+	}
+	b := append([]byte{}, radiotapHeader...)
+	b = append(b, subtype<<4, 0, 0, 0)
+	b = append(b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	b = append(b, bssid...)
+	b = append(b, bssid...)
+	b = append(b, 0, 0, 7, 0)
+	packet, err := dot11.Decode(b)
+	if err != nil {
+		panic(err)
+	}
+	return packet
+}
+
+func TestDeauthMonitor(t *testing.T) {
+	m := newDeauthMonitor(10, 2*time.Second)
+	start := time.Unix(1633089600, 0)
+	other := []byte{0x02, 0, 0, 0, 0, 0x99}
+
+	// A dwell shorter than the window is accumulated
+	m.arrive(6, start)
+	for i := 0; i < 15; i++ {
+		m.observe(6, testDeauth(testAP, false))
+	}
+	m.observe(6, testDeauth(other, true))
+	m.observe(6, testBeaconPacket(t))
+	if _, ok := m.leave(start.Add(time.Second)); ok {
+		t.Fatal("alert before the window")
+	}
+
+	m.arrive(11, start.Add(time.Second))
+	m.observe(11, testDeauth(testAP, false))
+	if _, ok := m.leave(start.Add(3 * time.Second)); ok {
+		t.Fatal("alert below the threshold")
+	}
+
+	m.arrive(6, start.Add(3*time.Second))
+	for i := 0; i < 8; i++ {
+		m.observe(6, testDeauth(testAP, false))
+	}
+	event, ok := m.leave(start.Add(4 * time.Second))
+	if !ok {
+		t.Fatal("no alert")
+	}
+	if event.Type != events.TypeAlert || event.Alert != alertDeauthFlood || event.Channel != 6 || event.Frames != 24 || event.Rate != 12 || event.BSSID != "00:11:22:33:44:55" {
+		t.Fatalf("alert: %+v", event)
+	}
+
+	// Alerts on a channel are rate limited
+	m.arrive(6, start.Add(4*time.Second))
+	for i := 0; i < 100; i++ {
+		m.observe(6, testDeauth(testAP, false))
+	}
+	if _, ok := m.leave(start.Add(7 * time.Second)); ok {
+		t.Fatal("alert during the cooldown")
+	}
+	m.arrive(6, start.Add(6*time.Minute))
+	for i := 0; i < 100; i++ {
+		m.observe(6, testDeauth(testAP, false))
+	}
+	if _, ok := m.leave(start.Add(6*time.Minute + 3*time.Second)); !ok {
+		t.Fatal("no alert after the cooldown")
+	}
+
+	if _, ok := m.leave(start.Add(7 * time.Minute)); ok {
+		t.Fatal("alert without a channel")
+	}
+}
+
+func testBeaconPacket(t *testing.T) dot11.Packet {
+	packet, err := dot11.Decode(testBeacon(testAP, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+// stepDelay returns delay changed by step, not going below floor or zero.
+func stepDelay(delay time.Duration, step time.Duration, floor time.Duration) time.Duration {
+	delay += step
+	if delay < floor {
+		delay = floor
+	}
+	if delay <= 0 {
+		delay = time.Millisecond
+	}
+
+	return delay
+}
+
+// delayFloor returns the smallest delay allowed at runtime.
+func delayFloor() time.Duration {
+	if ignoreMinDelay {
+		return 0
+	}
+	return time.Duration(minDelay) * time.Millisecond
+}
+
+// watchDelaySignals increases the delay by step on SIGUSR1 and decreases it
+// on SIGUSR2 until ctx is done.
+func watchDelaySignals(ctx context.Context, h *hopper.Hopper, step time.Duration) {
+	if slowerSignal == nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, slowerSignal, fasterSignal)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				change := step
+				if sig == fasterSignal {
+					change = -step
+				}
+
+				delay := stepDelay(h.Delay(), change, delayFloor())
+				_ = h.SetDelay(delay)
+				_, _ = fmt.Fprintf(stderr, "Delay set to %v\n", delay)
+			}
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStepDelay(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name   string
+		delay  time.Duration
+		step   time.Duration
+		floor  time.Duration
+		output time.Duration
+	}{
+		{"increase", 100 * ms, 10 * ms, 50 * ms, 110 * ms},
+		{"decrease", 100 * ms, -10 * ms, 50 * ms, 90 * ms},
+		{"floor", 55 * ms, -10 * ms, 50 * ms, 50 * ms},
+		{"no floor", 5 * ms, -10 * ms, 0, ms},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := tt.output, stepDelay(tt.delay, tt.step, tt.floor); want != got {
+				t.Fatalf("stepDelay(%v, %v, %v):\n- want: %v\n-  got: %v", tt.delay, tt.step, tt.floor, want, got)
+			}
+		})
+	}
+}
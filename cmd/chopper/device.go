@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deviceInfo identifies the hardware and firmware behind a network
+// interface, for bug reports.
+type deviceInfo struct {
+	Interface string `json:"interface"`
+	Driver    string `json:"driver,omitempty"`
+	Firmware  string `json:"firmware,omitempty"`
+	// Bus is the subsystem of the device: usb, pci, sdio...
+	Bus     string `json:"bus,omitempty"`
+	Vendor  string `json:"vendor_id,omitempty"`
+	Product string `json:"product_id,omitempty"`
+}
+
+// describeDevice gathers what sysfs and ethtool know about the device of a
+// network interface. Missing information is left empty.
+func describeDevice(name string) deviceInfo {
+	info := deviceInfo{Interface: name, Driver: driverOf(name)}
+	info.Firmware, _ = firmwareVersion(name)
+
+	device := filepath.Join(sysDir, "class", "net", name, "device")
+	if subsystem, err := os.Readlink(filepath.Join(device, "subsystem")); err == nil {
+		info.Bus = filepath.Base(subsystem)
+	}
+	// USB network devices are bound to an interface of the USB device,
+	// which holds the IDs. The parent only exists past the symlink.
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	info.Vendor = readID(filepath.Join(device, "vendor"), filepath.Join(device, "..", "idVendor"))
+	info.Product = readID(filepath.Join(device, "device"), filepath.Join(device, "..", "idProduct"))
+	return info
+}
+
+// readID returns the first of the hexadecimal IDs in paths that exists,
+// without its 0x prefix.
+func readID(paths ...string) string {
+	for _, path := range paths {
+		id, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		return strings.TrimPrefix(strings.TrimSpace(string(id)), "0x")
+	}
+	return ""
+}
+
+func (d deviceInfo) String() string {
+	parts := []string{d.Interface}
+	if d.Driver != "" {
+		parts = append(parts, "driver "+d.Driver)
+	}
+	if d.Firmware != "" {
+		parts = append(parts, "firmware "+d.Firmware)
+	}
+	if d.Vendor != "" || d.Product != "" {
+		bus := d.Bus
+		if bus == "" {
+			bus = "id"
+		}
+		parts = append(parts, fmt.Sprintf("%v %v:%v", bus, d.Vendor, d.Product))
+	}
+	return strings.Join(parts, ", ")
+}
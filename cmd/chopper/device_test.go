@@ -0,0 +1,92 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeNetDevice creates the sysfs entries of interface name, bound to the
+// device dir of bus with driver.
+func fakeNetDevice(t *testing.T, name, dir, bus, driver string) string {
+	device := filepath.Join(sysDir, "devices", dir)
+	for _, path := range []string{
+		filepath.Join(sysDir, "class", "net", name),
+		filepath.Join(sysDir, "bus", bus, "drivers", driver),
+		device,
+	} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(sysDir, "class", "net", name, "device"): device,
+		filepath.Join(device, "subsystem"):                    filepath.Join(sysDir, "bus", bus),
+		filepath.Join(device, "driver"):                       filepath.Join(sysDir, "bus", bus, "drivers", driver),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return device
+}
+
+func writeSysfs(t *testing.T, path, value string) {
+	if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribeDevice(t *testing.T) {
+	defer func(sys string) { sysDir = sys }(sysDir)
+	sysDir = t.TempDir()
+
+	pci := fakeNetDevice(t, "wlan0", "pci0000:00/0000:00:1c.0/0000:02:00.0", "pci", "ath9k")
+	writeSysfs(t, filepath.Join(pci, "vendor"), "0x168c")
+	writeSysfs(t, filepath.Join(pci, "device"), "0x002a")
+
+	usb := fakeNetDevice(t, "wlan1", "usb1/1-1/1-1:1.0", "usb", "rtl8xxxu")
+	writeSysfs(t, filepath.Join(usb, "..", "idVendor"), "0bda")
+	writeSysfs(t, filepath.Join(usb, "..", "idProduct"), "8179")
+
+	tests := []struct {
+		name string
+		want deviceInfo
+		text string
+	}{
+		{"wlan0", deviceInfo{Interface: "wlan0", Driver: "ath9k", Bus: "pci", Vendor: "168c", Product: "002a"}, "wlan0, driver ath9k, pci 168c:002a"},
+		{"wlan1", deviceInfo{Interface: "wlan1", Driver: "rtl8xxxu", Bus: "usb", Vendor: "0bda", Product: "8179"}, "wlan1, driver rtl8xxxu, usb 0bda:8179"},
+		{"mon0", deviceInfo{Interface: "mon0"}, "mon0"},
+	}
+	for _, test := range tests {
+		got := describeDevice(test.name)
+		if got != test.want {
+			t.Errorf("describeDevice(%v):\n- want: %+v\n-  got: %+v", test.name, test.want, got)
+		}
+		if got.String() != test.text {
+			t.Errorf("%v: got %q, want %q", test.name, got, test.text)
+		}
+	}
+
+	firmware := deviceInfo{Interface: "wlan0", Driver: "brcmfmac", Firmware: "7.45.189"}
+	if got, want := firmware.String(), "wlan0, driver brcmfmac, firmware 7.45.189"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
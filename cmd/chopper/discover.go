@@ -0,0 +1,267 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/net/bpf"
+)
+
+// channelReport is what discover observed on a single channel.
+type channelReport struct {
+	Channel int
+	Beacons int
+	APs     map[string]bool
+	Clients map[string]bool
+}
+
+func (r *channelReport) active() bool {
+	return len(r.APs) > 0 || len(r.Clients) > 0
+}
+
+// discovery aggregates the frames seen during a sweep.
+type discovery struct {
+	reports map[int]*channelReport
+}
+
+func newDiscovery() *discovery {
+	return &discovery{
+		reports: make(map[int]*channelReport),
+	}
+}
+
+func (d *discovery) report(channel int) *channelReport {
+	r, ok := d.reports[channel]
+	if !ok {
+		r = &channelReport{
+			Channel: channel,
+			APs:     make(map[string]bool),
+			Clients: make(map[string]bool),
+		}
+		d.reports[channel] = r
+	}
+
+	return r
+}
+
+// observe accounts a frame received while tuned to the given channel.
+func (d *discovery) observe(tuned int, frame dot11.Frame) {
+	switch frame.Type {
+	case dot11.TypeManagement:
+		if frame.Subtype != dot11.SubtypeBeacon {
+			return
+		}
+
+		beacon, err := dot11.ParseBeacon(frame.Body)
+		if err != nil {
+			return
+		}
+
+		// 2.4 GHz beacons leak into adjacent channels, trust the AP
+		channel := beacon.Channel()
+		if channel == 0 {
+			channel = tuned
+		}
+
+		r := d.report(channel)
+		r.Beacons++
+		r.APs[frame.BSSID().String()] = true
+	case dot11.TypeData:
+		// Skip WDS, IBSS and group addressed frames
+		client := frame.Station()
+		if client == nil || dot11.IsGroup(client) {
+			return
+		}
+		d.report(tuned).Clients[client.String()] = true
+	}
+}
+
+// sorted returns all reports ordered by channel.
+func (d *discovery) sorted() []*channelReport {
+	ret := make([]*channelReport, 0, len(d.reports))
+	for _, r := range d.reports {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Channel < ret[j].Channel
+	})
+
+	return ret
+}
+
+// suggestPlan returns the active channels, busiest first.
+func (d *discovery) suggestPlan() []int {
+	active := make([]*channelReport, 0, len(d.reports))
+	for _, r := range d.sorted() {
+		if r.active() {
+			active = append(active, r)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return len(active[i].APs)+len(active[i].Clients) > len(active[j].APs)+len(active[j].Clients)
+	})
+
+	ret := make([]int, 0, len(active))
+	for _, r := range active {
+		ret = append(ret, r.Channel)
+	}
+
+	return ret
+}
+
+// runDiscover implements the discover subcommand and returns the exit code.
+func runDiscover(ctx context.Context, args []string) int {
+	var (
+		ifaceName string
+		chans     string
+		dwell     int
+		sweeps    int
+		suggest   bool
+		filter    string
+		rawFilter string
+		force     bool
+		colorMode string
+	)
+
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	flags.StringVarP(&ifaceName, "interface", "i", "", "interface name (must be in monitor mode)")
+	flags.StringVarP(&chans, "channels", "c", "", "comma-separated list of channels to sweep (default: 1-13)")
+	flags.IntVarP(&dwell, "dwell", "d", 250, "time spent on each channel in ms")
+	flags.IntVar(&sweeps, "sweeps", 1, "number of full sweeps")
+	flags.BoolVar(&suggest, "suggest", false, "print a suggested hop plan focused on active channels")
+	flags.StringVar(&filter, "filter", "", "only wake up for matching frames: beacons, mgmt, data or bssid=<address>")
+	flags.StringVar(&rawFilter, "bpf", "", "classic BPF filter for the capture socket, as printed by tcpdump -ddd")
+	flags.BoolVar(&force, "force", false, "start even if other processes may manage the interface")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if ifaceName == "" {
+		flags.Usage()
+		return 1
+	}
+	channels := parseChannels(chans, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13})
+
+	// Connect to nl80211
+	client, err := nl80211util.Dial()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	iface, err := client.MonitorInterface(ifaceName)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	lock, err := lockInterface(iface.Name)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer lock.Close()
+	if !checkManagers(iface.Name, iface.PHY, force) {
+		return 1
+	}
+	if !checkPhyInterfaces(client, iface, force) {
+		return 1
+	}
+
+	capture, err := openCapture(iface.Index, 50*time.Millisecond)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot open capture socket: %v\n", err)
+		return 1
+	}
+	defer capture.Close()
+
+	var instructions []bpf.RawInstruction
+	if rawFilter != "" {
+		instructions, err = parseRawFilter(rawFilter)
+	} else if filter != "" {
+		instructions, err = buildFilter(filter)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if err := capture.SetFilter(instructions); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot attach filter: %v\n", err)
+		return 1
+	}
+
+	d := newDiscovery()
+	buf := make([]byte, 65536)
+	for sweep := 0; sweep < sweeps && ctx.Err() == nil; sweep++ {
+		for _, channel := range channels {
+			if ctx.Err() != nil {
+				break
+			}
+
+			if err := client.SetFrequency(iface.Index, plan.Frequency(channel)); err != nil {
+				_, _ = fmt.Fprintf(stderr, "WARNING: cannot set channel %v: %v\n", channel, err)
+				continue
+			}
+
+			deadline := time.Now().Add(time.Duration(dwell) * time.Millisecond)
+			for time.Now().Before(deadline) {
+				n, err := capture.Read(buf)
+				if err != nil {
+					_, _ = fmt.Fprintf(stderr, "ERROR: cannot read frame: %v\n", err)
+					return 1
+				}
+				if n == 0 {
+					continue
+				}
+
+				packet, err := dot11.Decode(buf[:n])
+				if err != nil {
+					continue
+				}
+				d.observe(channel, packet.Frame)
+			}
+		}
+	}
+
+	fmt.Println(paint(colorStdout, ansiBold, fmt.Sprintf("%-8s%-10s%-6s%s", "CHANNEL", "BEACONS", "APS", "CLIENTS")))
+	for _, r := range d.sorted() {
+		fmt.Printf("%-8s%-10d%-6d%d\n", channelLabel(r.Channel), r.Beacons, len(r.APs), len(r.Clients))
+	}
+
+	if suggest {
+		if suggested := d.suggestPlan(); len(suggested) > 0 {
+			fmt.Printf("\nSuggested hop plan: -c %s\n", plan.Format(suggested))
+		} else {
+			fmt.Printf("\nNo active channels found.\n")
+		}
+	}
+
+	return 0
+}
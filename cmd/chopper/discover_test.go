@@ -0,0 +1,94 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+// radiotapHeader is a minimal radiotap header with no fields present.
+var radiotapHeader = []byte{0, 0, 8, 0, 0, 0, 0, 0}
+
+func testBeacon(bssid []byte, channel byte) []byte {
+	b := append([]byte{}, radiotapHeader...)
+	b = append(b, 0x80, 0x00, 0, 0)
+	b = append(b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	b = append(b, bssid...)
+	b = append(b, bssid...)
+	b = append(b, 0, 0)
+	b = append(b, make([]byte, 12)...)
+	b = append(b, 0, 4, 't', 'e', 's', 't')
+	if channel != 0 {
+		b = append(b, dot11.ElementDSParameterSet, 1, channel)
+	}
+	return b
+}
+
+func testData(flags byte, addr1 []byte, addr2 []byte, addr3 []byte) []byte {
+	b := append([]byte{}, radiotapHeader...)
+	b = append(b, 0x08, flags, 0, 0)
+	b = append(b, addr1...)
+	b = append(b, addr2...)
+	b = append(b, addr3...)
+	b = append(b, 0, 0)
+	return append(b, 0xaa, 0xaa, 0x03)
+}
+
+func TestDiscovery(t *testing.T) {
+	ap1 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x01}
+	ap2 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x02}
+	client := []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	frames := []struct {
+		tuned int
+		frame []byte
+	}{
+		{tuned: 1, frame: testBeacon(ap1, 1)},
+		{tuned: 2, frame: testBeacon(ap1, 1)},
+		{tuned: 6, frame: testBeacon(ap2, 6)},
+		{tuned: 6, frame: testData(0x01, ap2, client, ap2)},
+		{tuned: 6, frame: testData(0x02, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, ap2, ap2)},
+		{tuned: 11, frame: testData(0x02, client, ap2, ap2)},
+	}
+
+	d := newDiscovery()
+	for _, f := range frames {
+		packet, err := dot11.Decode(f.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.observe(f.tuned, packet.Frame)
+	}
+
+	reports := d.sorted()
+	if want, got := 3, len(reports); want != got {
+		t.Fatalf("reports:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 2, d.reports[1].Beacons; want != got {
+		t.Fatalf("beacons on 1:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 1, len(d.reports[6].Clients); want != got {
+		t.Fatalf("clients on 6:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	if want, got := []int{6, 1, 11}, d.suggestPlan(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("suggestPlan:\n- want: %v\n-  got: %v", want, got)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// maxDossierVisits bounds the channel history kept for each BSS, the
+// oldest visits are dropped first.
+const maxDossierVisits = 1000
+
+// dossierVisit is an uninterrupted stay of a BSS on a channel.
+type dossierVisit struct {
+	Channel   int       `json:"channel"`
+	Frequency int       `json:"frequency"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Beacons   int       `json:"beacons"`
+	// MinSignal and MaxSignal are in dBm, 0 if the driver does not report
+	// the signal.
+	MinSignal int `json:"min_signal,omitempty"`
+	MaxSignal int `json:"max_signal,omitempty"`
+}
+
+// dossierEntry is the channel history of a BSS.
+type dossierEntry struct {
+	BSSID    string         `json:"bssid"`
+	SSID     string         `json:"ssid"`
+	Vendor   string         `json:"vendor,omitempty"`
+	Channels []dossierVisit `json:"channels"`
+}
+
+// dossier records every channel the beacons of each BSS were seen on, to
+// document access points that steer clients between bands or switch
+// channels during an engagement.
+type dossier struct {
+	minSignal minSignal
+	// isTarget limits the dossier to the --targets if set
+	isTarget func(bssid string, ssid string) bool
+	// out is told when a BSS moves to another channel
+	out io.Writer
+
+	mu      sync.Mutex
+	entries map[string]*dossierEntry
+}
+
+func newDossier(out io.Writer) *dossier {
+	return &dossier{out: out, entries: make(map[string]*dossierEntry)}
+}
+
+// observe is a frameObserver.
+func (d *dossier) observe(tuned int, packet dot11.Packet) {
+	d.add(tuned, packet, time.Now())
+}
+
+func (d *dossier) add(tuned int, packet dot11.Packet, now time.Time) {
+	f := packet.Frame
+	if f.Type != dot11.TypeManagement || f.Subtype != dot11.SubtypeBeacon || !d.minSignal.accepts(packet) {
+		return
+	}
+	beacon, err := dot11.ParseBeacon(f.Body)
+	if err != nil {
+		return
+	}
+	bssid := f.BSSID().String()
+	if d.isTarget != nil && !d.isTarget(bssid, beacon.SSID()) {
+		return
+	}
+
+	// 2.4 GHz beacons leak into adjacent channels, trust the AP
+	channel := tuned
+	if plan.BandOf(tuned) == plan.Band2GHz && beacon.Channel() != 0 {
+		channel = beacon.Channel()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.entries[bssid]
+	if !ok {
+		if len(d.entries) >= maxInventory {
+			return
+		}
+		e = &dossierEntry{BSSID: bssid, Vendor: vendorOf(bssid)}
+		d.entries[bssid] = e
+	}
+	e.SSID = beacon.SSID()
+
+	var last *dossierVisit
+	if n := len(e.Channels); n > 0 {
+		last = &e.Channels[n-1]
+	}
+	if last == nil || last.Channel != channel {
+		if last != nil {
+			_, _ = fmt.Fprintf(d.out, "Dossier: %v (%v) moved from channel %v to %v\n", describeMAC(bssid), e.SSID, plan.FormatChannel(last.Channel), plan.FormatChannel(channel))
+		}
+		if len(e.Channels) >= maxDossierVisits {
+			e.Channels = e.Channels[1:]
+		}
+		e.Channels = append(e.Channels, dossierVisit{Channel: channel, Frequency: plan.Frequency(channel), FirstSeen: now.UTC()})
+		last = &e.Channels[len(e.Channels)-1]
+	}
+	last.LastSeen = now.UTC()
+	last.Beacons++
+	if packet.Radiotap.HasSignal {
+		signal := packet.Radiotap.Signal
+		if last.MinSignal == 0 || signal < last.MinSignal {
+			last.MinSignal = signal
+		}
+		if last.MaxSignal == 0 || signal > last.MaxSignal {
+			last.MaxSignal = signal
+		}
+	}
+}
+
+// sorted returns copies of the entries by BSSID.
+func (d *dossier) sorted() []dossierEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ret := make([]dossierEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		c := *e
+		c.Channels = append([]dossierVisit(nil), e.Channels...)
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].BSSID < ret[j].BSSID
+	})
+	return ret
+}
+
+// writeJSON writes the dossier as a JSON array.
+func (d *dossier) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.sorted())
+}
+
+// writeCSV writes the dossier as CSV with a header, a row per visit.
+func (d *dossier) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"bssid", "ssid", "vendor", "channel", "frequency", "first_seen", "last_seen", "beacons", "min_signal", "max_signal"})
+	for _, e := range d.sorted() {
+		for _, v := range e.Channels {
+			minSignal, maxSignal := "", ""
+			if v.MaxSignal != 0 {
+				minSignal, maxSignal = strconv.Itoa(v.MinSignal), strconv.Itoa(v.MaxSignal)
+			}
+			_ = cw.Write([]string{e.BSSID, e.SSID, e.Vendor, plan.FormatChannel(v.Channel), strconv.Itoa(v.Frequency),
+				v.FirstSeen.Format(time.RFC3339), v.LastSeen.Format(time.RFC3339), strconv.Itoa(v.Beacons), minSignal, maxSignal})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeFile writes the dossier to path, as CSV if it ends in .csv and as
+// JSON otherwise.
+func (d *dossier) writeFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = d.writeCSV(f)
+	} else {
+		err = d.writeJSON(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDossier(t *testing.T) {
+	var out bytes.Buffer
+	d := newDossier(&out)
+	start := time.Unix(1633089600, 0)
+	other := []byte{0x02, 0, 0, 0, 0, 0x99}
+	d.isTarget = func(bssid string, ssid string) bool {
+		return bssid == "00:11:22:33:44:55"
+	}
+
+	// Leaked into channel 5, the beacon tells channel 6
+	d.add(5, testBeaconSignal(t, testAP, 6, -70), start)
+	d.add(6, testBeaconSignal(t, testAP, 6, -50), start.Add(time.Second))
+	d.add(36, testBeaconSignal(t, testAP, 0, -60), start.Add(time.Minute))
+	d.add(6, testBeaconSignal(t, testAP, 6, -55), start.Add(2*time.Minute))
+	d.add(6, testBeaconSignal(t, other, 6, -40), start)
+
+	entries := d.sorted()
+	if len(entries) != 1 {
+		t.Fatalf("entries: %+v", entries)
+	}
+	visits := entries[0].Channels
+	if len(visits) != 3 || visits[0].Channel != 6 || visits[1].Channel != 36 || visits[2].Channel != 6 {
+		t.Fatalf("visits: %+v", visits)
+	}
+	if v := visits[0]; v.Beacons != 2 || v.MinSignal != -70 || v.MaxSignal != -50 || !v.FirstSeen.Equal(start) || !v.LastSeen.Equal(start.Add(time.Second)) {
+		t.Fatalf("first visit: %+v", v)
+	}
+	if want := "Dossier: 00:11:22:33:44:55 (test) moved from channel 6 to 36\nDossier: 00:11:22:33:44:55 (test) moved from channel 36 to 6\n"; out.String() != want {
+		t.Fatalf("output:\n- want: %v\n-  got: %v", want, out.String())
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dossier.csv")
+	if err := d.writeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || lines[2] != "00:11:22:33:44:55,test,,36,5180,2021-10-01T12:01:00Z,2021-10-01T12:01:00Z,1,-60,-60" {
+		t.Fatalf("csv:\n%v", string(data))
+	}
+
+	path = filepath.Join(dir, "dossier.json")
+	if err := d.writeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []dossierEntry
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || len(decoded[0].Channels) != 3 || decoded[0].Channels[1].Frequency != 5180 {
+		t.Fatalf("json: %+v", decoded)
+	}
+}
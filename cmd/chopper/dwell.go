@@ -0,0 +1,55 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+// dwellModes are the values of --dwell.
+var dwellModes = []string{"fixed", "jitter", "traffic"}
+
+// dwellController returns the hopper.DwellController of --dwell, nil for
+// fixed dwells. traffic returns the packet rate of the last visit to a
+// channel, and dwells are kept above floor.
+func dwellController(mode string, jitter float64, seed int64, traffic func(channel int) float64, floor time.Duration) (hopper.DwellController, error) {
+	var controller hopper.DwellController
+	switch mode {
+	case "fixed":
+		return nil, nil
+	case "jitter":
+		if jitter <= 0 || jitter >= 1 {
+			return nil, fmt.Errorf("--jitter must be above 0 and below 1, not %v", jitter)
+		}
+		controller = hopper.JitteredDwell{Rand: rand.New(rand.NewSource(seed)), Jitter: jitter}
+	case "traffic":
+		controller = &hopper.ProportionalDwell{Traffic: traffic}
+	default:
+		return nil, fmt.Errorf("unknown dwell %v, expected one of %v", mode, dwellModes)
+	}
+
+	return hopper.DwellFunc(func(channel int, delay time.Duration) time.Duration {
+		if dwell := controller.Dwell(channel, delay); dwell > floor {
+			return dwell
+		}
+		return floor
+	}), nil
+}
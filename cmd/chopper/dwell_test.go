@@ -0,0 +1,50 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDwellController(t *testing.T) {
+	if c, err := dwellController("fixed", 0, 1, nil, 0); c != nil || err != nil {
+		t.Fatalf("dwellController(fixed) = %v, %v", c, err)
+	}
+	for _, jitter := range []float64{0, 1, -0.5} {
+		if _, err := dwellController("jitter", jitter, 1, nil, 0); err == nil {
+			t.Errorf("dwellController(jitter) accepted --jitter %v", jitter)
+		}
+	}
+	if _, err := dwellController("random", 0, 1, nil, 0); err == nil {
+		t.Error("dwellController accepted an unknown mode")
+	}
+
+	// Quiet channels are not left before the minimum delay
+	rates := map[int]float64{1: 100, 6: 0}
+	c, err := dwellController("traffic", 0, 1, func(channel int) float64 { return rates[channel] }, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Dwell(1, 100*time.Millisecond)
+	if got := c.Dwell(6, 100*time.Millisecond); got != 50*time.Millisecond {
+		t.Errorf("dwell on a quiet channel = %v, want the 50ms floor", got)
+	}
+	if got := c.Dwell(1, 100*time.Millisecond); got != 200*time.Millisecond {
+		t.Errorf("dwell on a busy channel = %v, want 200ms", got)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// eapolFrame is a message of a 4-way handshake seen by the capture, or a
+// frame carrying a PMKID.
+type eapolFrame struct {
+	channel int
+	ap      string
+	station string
+	// message is the number of the handshake message, 0 for other frames.
+	message int
+	pmkid   []byte
+}
+
+// decodeEAPOL returns the handshake message in a captured packet.
+func decodeEAPOL(channel int, packet dot11.Packet) (eapolFrame, bool) {
+	if packet.Frame.Type != dot11.TypeData || packet.Frame.Protected {
+		return eapolFrame{}, false
+	}
+	ap, station := packet.Frame.BSSID(), packet.Frame.Station()
+	if ap == nil || station == nil {
+		return eapolFrame{}, false
+	}
+	key, err := dot11.ParseEAPOLKey(packet.Frame.Body)
+	if err != nil || key.Message() == 0 {
+		return eapolFrame{}, false
+	}
+	frame := eapolFrame{channel: channel, ap: ap.String(), station: station.String(), message: key.Message()}
+	if frame.message == 1 {
+		frame.pmkid, _ = key.PMKID()
+	}
+	return frame, true
+}
+
+// handshakeLock locks hopping to the channel of a handshake from its first
+// message until the four messages are captured or the lock times out. If
+// pmkid is set it also locks for pmkid on the channel of every PMKID.
+type handshakeLock struct {
+	l     *channelLock
+	pmkid time.Duration
+	seen  map[string]uint8
+}
+
+// newHandshakeLock returns a lock following handshakes for timeout, unless
+// timeout is 0, and PMKIDs for pmkid, unless pmkid is 0.
+func newHandshakeLock(shared *sharedPlan, timeout time.Duration, pmkid time.Duration) *handshakeLock {
+	l := &channelLock{plan: shared, reason: "eapol", timeout: timeout}
+	return &handshakeLock{l: l, pmkid: pmkid, seen: make(map[string]uint8)}
+}
+
+// observe records a handshake message or PMKID seen at now.
+func (k *handshakeLock) observe(frame eapolFrame, now time.Time) error {
+	if frame.pmkid != nil && k.pmkid > 0 {
+		if k.l.channel != frame.channel {
+			_, _ = fmt.Fprintf(stderr, "EAPOL: PMKID from %v, locking on channel %v\n", describeMAC(frame.ap), plan.FormatChannel(frame.channel))
+		}
+		if err := k.hold(frame.channel, now, k.pmkid); err != nil {
+			return err
+		}
+	}
+	if k.l.timeout == 0 || frame.message == 0 {
+		return nil
+	}
+
+	pair := frame.ap + " " + frame.station
+	if frame.message == 1 {
+		// Retransmissions of message 1 restart the handshake
+		k.seen[pair] = 0
+		if k.l.channel != frame.channel {
+			_, _ = fmt.Fprintf(stderr, "EAPOL: handshake between %v and %v, locking on channel %v\n", describeMAC(frame.ap), describeMAC(frame.station), plan.FormatChannel(frame.channel))
+		}
+	} else if _, ok := k.seen[pair]; !ok || k.l.channel != frame.channel {
+		// Only the handshakes that started while locked are followed
+		return nil
+	}
+
+	k.seen[pair] |= 1 << (frame.message - 1)
+	if k.seen[pair] == 0xf {
+		_, _ = fmt.Fprintf(stderr, "EAPOL: captured a full handshake between %v and %v, resuming hopping\n", describeMAC(frame.ap), describeMAC(frame.station))
+		return k.release()
+	}
+	return k.hold(frame.channel, now, k.l.timeout)
+}
+
+// hold locks hopping to channel for at least d from now, without shortening
+// the lock already held on it.
+func (k *handshakeLock) hold(channel int, now time.Time, d time.Duration) error {
+	until := now.Add(d)
+	if k.l.channel == channel && k.l.until.After(until) {
+		until = k.l.until
+	}
+	if err := k.l.lock(channel, now); err != nil {
+		return err
+	}
+	k.l.until = until
+	return nil
+}
+
+// release restores the plan and forgets the handshakes in progress.
+func (k *handshakeLock) release() error {
+	k.seen = make(map[string]uint8)
+	return k.l.release()
+}
+
+// expire releases the lock if no message was seen for the timeout.
+func (k *handshakeLock) expire(now time.Time) error {
+	if k.l.channel == 0 || now.Before(k.l.until) {
+		return nil
+	}
+	_, _ = fmt.Fprintf(stderr, "EAPOL: handshake capture timed out, resuming hopping\n")
+	return k.release()
+}
+
+// watchHandshakes locks hopping on the handshakes and PMKIDs seen by the
+// capture.
+func watchHandshakes(ctx context.Context, frames <-chan eapolFrame, shared *sharedPlan, timeout time.Duration, pmkid time.Duration) {
+	k := newHandshakeLock(shared, timeout, pmkid)
+	warn := func(err error) {
+		if err != nil && ctx.Err() == nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+		}
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame := <-frames:
+			warn(k.observe(frame, time.Now()))
+		case now := <-ticker.C:
+			warn(k.expire(now))
+		}
+	}
+}
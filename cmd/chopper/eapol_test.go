@@ -0,0 +1,164 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+var (
+	testAP      = []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testStation = []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+)
+
+// testEAPOLFrame returns a radiotap frame carrying an EAPOL-Key frame with
+// keyInfo, sent by the AP if fromAP is set.
+func testEAPOLFrame(keyInfo uint16, fromAP bool) []byte {
+	return testEAPOLFrameData(keyInfo, fromAP, nil)
+}
+
+// testEAPOLFrameData is testEAPOLFrame with key data.
+func testEAPOLFrameData(keyInfo uint16, fromAP bool, keyData []byte) []byte {
+	b := append([]byte{}, radiotapHeader...)
+	if fromAP {
+		b = append(b, 0x88, 0x02, 0, 0)
+		b = append(b, testStation...)
+		b = append(b, testAP...)
+		b = append(b, testAP...)
+	} else {
+		b = append(b, 0x88, 0x01, 0, 0)
+		b = append(b, testAP...)
+		b = append(b, testStation...)
+		b = append(b, testAP...)
+	}
+	b = append(b, 0, 0, 0, 0)
+
+	key := make([]byte, 95)
+	key[0] = 2
+	binary.BigEndian.PutUint16(key[1:], keyInfo)
+	binary.BigEndian.PutUint16(key[93:], uint16(len(keyData)))
+	key = append(key, keyData...)
+	b = append(b, 0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x88, 0x8e, 2, 3, 0, byte(len(key)))
+	return append(b, key...)
+}
+
+func TestDecodeEAPOL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		frame eapolFrame
+		ok    bool
+	}{
+		{"message_1", testEAPOLFrame(0x008a, true), eapolFrame{channel: 6, ap: "00:11:22:33:44:55", station: "02:00:00:00:00:01", message: 1}, true},
+		{"message_4", testEAPOLFrame(0x030a, false), eapolFrame{channel: 6, ap: "00:11:22:33:44:55", station: "02:00:00:00:00:01", message: 4}, true},
+		{"group", testEAPOLFrame(0x0382, true), eapolFrame{}, false},
+		{"beacon", testBeacon(testAP, 6), eapolFrame{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := dot11.Decode(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frame, ok := decodeEAPOL(6, packet)
+			if !reflect.DeepEqual(frame, tt.frame) || ok != tt.ok {
+				t.Fatalf("decodeEAPOL:\n- want: %+v, %v\n-  got: %+v, %v", tt.frame, tt.ok, frame, ok)
+			}
+		})
+	}
+}
+
+func TestHandshakeLock(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	k := newHandshakeLock(newSharedPlan(p), 5*time.Second, 0)
+	start := time.Now()
+	message := func(channel int, station string, n int) eapolFrame {
+		return eapolFrame{channel: channel, ap: "00:11:22:33:44:55", station: station, message: n}
+	}
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"message 2 without 1", func() error { return k.observe(message(6, "a", 2), start) }, []int{1, 6, 11}},
+		{"message 1", func() error { return k.observe(message(6, "a", 1), start) }, []int{6}},
+		{"message 2", func() error { return k.observe(message(6, "a", 2), start.Add(4*time.Second)) }, []int{6}},
+		{"extended", func() error { return k.expire(start.Add(6 * time.Second)) }, []int{6}},
+		{"other station", func() error { return k.observe(message(6, "b", 3), start.Add(6*time.Second)) }, []int{6}},
+		{"message 3", func() error { return k.observe(message(6, "a", 3), start.Add(7*time.Second)) }, []int{6}},
+		{"message 4", func() error { return k.observe(message(6, "a", 4), start.Add(7*time.Second)) }, []int{1, 6, 11}},
+		{"relock", func() error { return k.observe(message(11, "b", 1), start.Add(8*time.Second)) }, []int{11}},
+		{"not expired", func() error { return k.expire(start.Add(12 * time.Second)) }, []int{11}},
+		{"expired", func() error { return k.expire(start.Add(13 * time.Second)) }, []int{1, 6, 11}},
+		{"forgotten", func() error { return k.observe(message(11, "b", 2), start.Add(13*time.Second)) }, []int{1, 6, 11}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(p.channels, step.output) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+}
+
+func TestHandshakeLockPMKID(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	k := newHandshakeLock(newSharedPlan(p), 0, 30*time.Second)
+	start := time.Now()
+	pmkid := eapolFrame{channel: 6, ap: "00:11:22:33:44:55", station: "a", message: 1, pmkid: make([]byte, 16)}
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"message 1 without PMKID", func() error { return k.observe(eapolFrame{channel: 11, message: 1}, start) }, []int{1, 6, 11}},
+		{"PMKID", func() error { return k.observe(pmkid, start) }, []int{6}},
+		{"not expired", func() error { return k.expire(start.Add(29 * time.Second)) }, []int{6}},
+		{"expired", func() error { return k.expire(start.Add(30 * time.Second)) }, []int{1, 6, 11}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(p.channels, step.output) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+
+	// A handshake does not shorten the lock of a PMKID
+	k = newHandshakeLock(newSharedPlan(p), 5*time.Second, 30*time.Second)
+	if err := k.observe(pmkid, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.observe(eapolFrame{channel: 6, ap: pmkid.ap, station: "a", message: 2}, start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.expire(start.Add(10 * time.Second)); err != nil || !reflect.DeepEqual(p.channels, []int{6}) {
+		t.Fatalf("lock shortened: %v, %v", p.channels, err)
+	}
+}
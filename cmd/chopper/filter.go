@@ -0,0 +1,146 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/bpf"
+)
+
+// filterSnapLength is the number of bytes accepted filters keep of a frame.
+const filterSnapLength = 0x40000
+
+var errInvalidRawFilter = errors.New("invalid raw filter, expected tcpdump -ddd output")
+
+// radiotapSkip loads the radiotap header length (little endian) in X, so
+// that indirect loads are relative to the start of the 802.11 header.
+var radiotapSkip = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 3, Size: 1},
+	bpf.ALUOpConstant{Op: bpf.ALUOpShiftLeft, Val: 8},
+	bpf.TAX{},
+	bpf.LoadAbsolute{Off: 2, Size: 1},
+	bpf.ALUOpX{Op: bpf.ALUOpOr},
+	bpf.TAX{},
+}
+
+// frameTypeFilter accepts frames whose first frame control byte, masked
+// with mask, equals value.
+func frameTypeFilter(mask uint32, value uint32) []bpf.Instruction {
+	filter := append([]bpf.Instruction{}, radiotapSkip...)
+	return append(filter,
+		bpf.LoadIndirect{Off: 0, Size: 1},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: mask},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: value, SkipFalse: 1},
+		bpf.RetConstant{Val: filterSnapLength},
+		bpf.RetConstant{Val: 0},
+	)
+}
+
+// bssidFilter accepts frames that carry addr in any of the first three
+// address fields.
+func bssidFilter(addr net.HardwareAddr) []bpf.Instruction {
+	hi := binary.BigEndian.Uint32(addr[0:4])
+	lo := uint32(binary.BigEndian.Uint16(addr[4:6]))
+
+	filter := append([]bpf.Instruction{}, radiotapSkip...)
+	for _, offset := range []uint32{4, 10, 16} {
+		filter = append(filter,
+			bpf.LoadIndirect{Off: offset, Size: 4},
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: hi, SkipFalse: 3},
+			bpf.LoadIndirect{Off: offset + 4, Size: 2},
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: lo, SkipFalse: 1},
+			bpf.RetConstant{Val: filterSnapLength},
+		)
+	}
+
+	return append(filter, bpf.RetConstant{Val: 0})
+}
+
+// buildFilter builds a filter from a simple description: "beacons",
+// "mgmt", "data" or "bssid=<address>".
+func buildFilter(spec string) ([]bpf.RawInstruction, error) {
+	var filter []bpf.Instruction
+
+	switch {
+	case spec == "beacons":
+		filter = frameTypeFilter(0xfc, 0x80)
+	case spec == "mgmt":
+		filter = frameTypeFilter(0x0c, 0x00)
+	case spec == "data":
+		filter = frameTypeFilter(0x0c, 0x08)
+	case strings.HasPrefix(spec, "bssid="):
+		addr, err := net.ParseMAC(strings.TrimPrefix(spec, "bssid="))
+		if err != nil {
+			return nil, err
+		}
+		if len(addr) != 6 {
+			return nil, fmt.Errorf("invalid bssid %v", addr)
+		}
+		filter = bssidFilter(addr)
+	default:
+		return nil, fmt.Errorf("unknown filter %v", spec)
+	}
+
+	return bpf.Assemble(filter)
+}
+
+// parseRawFilter parses the output of tcpdump -ddd, with instructions
+// separated by newlines or commas.
+func parseRawFilter(input string) ([]bpf.RawInstruction, error) {
+	parts := strings.FieldsFunc(input, func(r rune) bool {
+		return r == '\n' || r == ','
+	})
+	if len(parts) == 0 {
+		return nil, errInvalidRawFilter
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || count != len(parts)-1 {
+		return nil, errInvalidRawFilter
+	}
+
+	ret := make([]bpf.RawInstruction, 0, count)
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) != 4 {
+			return nil, errInvalidRawFilter
+		}
+
+		var values [4]uint64
+		for i, field := range fields {
+			values[i], err = strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, errInvalidRawFilter
+			}
+		}
+
+		ret = append(ret, bpf.RawInstruction{
+			Op: uint16(values[0]),
+			Jt: uint8(values[1]),
+			Jf: uint8(values[2]),
+			K:  uint32(values[3]),
+		})
+	}
+
+	return ret, nil
+}
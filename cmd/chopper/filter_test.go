@@ -0,0 +1,150 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/bpf"
+)
+
+func TestBuildFilter(t *testing.T) {
+	ap := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	other := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}
+	client := []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	beacon := testBeacon(ap, 6)
+	data := testData(0x01, ap, client, other)
+	otherBeacon := testBeacon(other, 6)
+
+	tests := []struct {
+		spec     string
+		accepted [][]byte
+		dropped  [][]byte
+	}{
+		{
+			spec:     "beacons",
+			accepted: [][]byte{beacon, otherBeacon},
+			dropped:  [][]byte{data},
+		},
+		{
+			spec:     "mgmt",
+			accepted: [][]byte{beacon},
+			dropped:  [][]byte{data},
+		},
+		{
+			spec:     "data",
+			accepted: [][]byte{data},
+			dropped:  [][]byte{beacon},
+		},
+		{
+			spec:     "bssid=00:11:22:33:44:55",
+			accepted: [][]byte{beacon, data},
+			dropped:  [][]byte{otherBeacon},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			raw, err := buildFilter(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			instructions, ok := bpf.Disassemble(raw)
+			if !ok {
+				t.Fatalf("buildFilter(%v): cannot disassemble", tt.spec)
+			}
+			vm, err := bpf.NewVM(instructions)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, frame := range tt.accepted {
+				if n, err := vm.Run(frame); err != nil || n == 0 {
+					t.Fatalf("buildFilter(%v) dropped %v", tt.spec, frame)
+				}
+			}
+			for _, frame := range tt.dropped {
+				if n, err := vm.Run(frame); err != nil || n != 0 {
+					t.Fatalf("buildFilter(%v) accepted %v", tt.spec, frame)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildFilterInvalid(t *testing.T) {
+	for _, spec := range []string{"", "probes", "bssid=nope"} {
+		if _, err := buildFilter(spec); err == nil {
+			t.Fatalf("buildFilter(%v): expected error", spec)
+		}
+	}
+}
+
+func TestParseRawFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output []bpf.RawInstruction
+		err    error
+	}{
+		{
+			name:  "newlines",
+			input: "2\n48 0 0 3\n6 0 0 262144\n",
+			output: []bpf.RawInstruction{
+				{Op: 48, Jt: 0, Jf: 0, K: 3},
+				{Op: 6, Jt: 0, Jf: 0, K: 262144},
+			},
+		},
+		{
+			name:  "commas",
+			input: "1,6 0 0 0",
+			output: []bpf.RawInstruction{
+				{Op: 6, Jt: 0, Jf: 0, K: 0},
+			},
+		},
+		{
+			name:  "wrong_count",
+			input: "2,6 0 0 0",
+			err:   errInvalidRawFilter,
+		},
+		{
+			name:  "missing_field",
+			input: "1,6 0 0",
+			err:   errInvalidRawFilter,
+		},
+		{
+			name:  "empty",
+			input: "",
+			err:   errInvalidRawFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseRawFilter(tt.input)
+			if err != tt.err {
+				t.Fatalf("parseRawFilter(%q) error:\n- want: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if want, got := tt.output, result; err == nil && !reflect.DeepEqual(want, got) {
+				t.Fatalf("parseRawFilter(%q):\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+const (
+	// gpsdStale is how long gpsd may stay silent before the fix is taken
+	// for lost, it reports every second while it has one.
+	gpsdStale = 5 * time.Second
+	// gpsdRetry is the time between two connection attempts to gpsd.
+	gpsdRetry = 5 * time.Second
+)
+
+// gpsdWatch asks gpsd to stream its reports as JSON.
+const gpsdWatch = "?WATCH={\"enable\":true,\"json\":true};\n"
+
+// position is a GPS fix in decimal degrees.
+type position struct {
+	Lat float64
+	Lon float64
+}
+
+// gpsdReport is the part of the gpsd reports used by chopper.
+type gpsdReport struct {
+	Class string   `json:"class"`
+	Mode  int      `json:"mode"`
+	Lat   *float64 `json:"lat"`
+	Lon   *float64 `json:"lon"`
+}
+
+// parseGPSDReport parses a gpsd report. It returns ok for the TPV reports,
+// with fix set when they carry a 2D or 3D fix.
+func parseGPSDReport(line []byte) (p position, fix bool, ok bool) {
+	var report gpsdReport
+	if err := json.Unmarshal(line, &report); err != nil || report.Class != "TPV" {
+		return position{}, false, false
+	}
+	if report.Mode < 2 || report.Lat == nil || report.Lon == nil {
+		return position{}, false, true
+	}
+	return position{Lat: *report.Lat, Lon: *report.Lon}, true, true
+}
+
+// watchGPSD calls update with every fix reported by the gpsd at addr, and
+// with no fix when gpsd loses it, goes silent or cannot be reached, until
+// ctx is done.
+func watchGPSD(ctx context.Context, addr string, update func(p position, fix bool)) {
+	for {
+		err := readGPSD(ctx, addr, update)
+		if ctx.Err() != nil {
+			return
+		}
+		update(position{}, false)
+		warnf("gpsd: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(gpsdRetry):
+		}
+	}
+}
+
+// readGPSD reads the reports of one connection to gpsd.
+func readGPSD(ctx context.Context, addr string, update func(p position, fix bool)) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if _, err := io.WriteString(conn, gpsdWatch); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(gpsdStale))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		if p, fix, ok := parseGPSDReport(scanner.Bytes()); ok {
+			update(p, fix)
+		}
+	}
+}
+
+// polygon is a geofence, its vertices in order.
+type polygon []position
+
+// parsePolygon parses a geofence file: a "latitude,longitude" vertex per
+// line in decimal degrees, at least three, with empty lines and lines
+// starting with # ignored.
+func parsePolygon(r io.Reader) (polygon, error) {
+	var p polygon
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %v: expected latitude,longitude", n)
+		}
+		lat, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return nil, fmt.Errorf("line %v: invalid latitude %q", n, fields[0])
+		}
+		lon, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("line %v: invalid longitude %q", n, fields[1])
+		}
+		p = append(p, position{Lat: lat, Lon: lon})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p) < 3 {
+		return nil, fmt.Errorf("a geofence needs at least 3 vertices, not %v", len(p))
+	}
+	return p, nil
+}
+
+// contains reports whether pos is inside the polygon, by ray casting. The
+// coordinates are taken as planar, which is accurate enough for fences of
+// a few kilometers away from the poles and the antimeridian.
+func (p polygon) contains(pos position) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Lat > pos.Lat) != (b.Lat > pos.Lat) &&
+			pos.Lon < (b.Lon-a.Lon)*(pos.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// parseFenceChannels parses --fence-channels: all, or a comma-separated
+// list of dfs and bands (2.4, 5 and 6). It returns whether a channel may
+// only be hopped inside the geofence.
+func parseFenceChannels(input string) (func(channel int) bool, error) {
+	var dfs bool
+	bands := make(map[plan.Band]bool)
+	for _, term := range strings.Split(input, ",") {
+		switch strings.TrimSpace(term) {
+		case "all":
+			return func(int) bool { return true }, nil
+		case "dfs":
+			dfs = true
+		case "2.4":
+			bands[plan.Band2GHz] = true
+		case "5":
+			bands[plan.Band5GHz] = true
+		case "6":
+			bands[plan.Band6GHz] = true
+		default:
+			return nil, fmt.Errorf("invalid fenced channels %q, expected all, dfs, 2.4, 5 or 6", term)
+		}
+	}
+	return func(channel int) bool {
+		return bands[plan.BandOf(channel)] || (dfs && isDFS(channel))
+	}, nil
+}
+
+// pausablePlanner is the part of hopper.Hopper used by the geofence.
+type pausablePlanner interface {
+	planner
+	Pause()
+	Resume()
+}
+
+// geofence hops on the whole plan inside the polygon and leaves out the
+// fenced channels outside of it, or without a fix, pausing the hopper when
+// none are left. It filters the shared plan, so that edits of the plan are
+// fenced too and channel locks are left alone.
+type geofence struct {
+	polygon polygon
+	fenced  func(channel int) bool
+
+	mu     sync.Mutex
+	plan   *sharedPlan
+	idle   pausablePlanner
+	inside bool
+}
+
+// start applies the plan outside the fence until the first fix, pausing
+// idle while no channel is left.
+func (g *geofence) start(shared *sharedPlan, idle pausablePlanner) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.plan = shared
+	g.idle = idle
+	return g.apply(false)
+}
+
+// update is called with every position reported by gpsd.
+func (g *geofence) update(p position, fix bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	inside := fix && g.polygon.contains(p)
+	if g.plan == nil || inside == g.inside {
+		return
+	}
+	if inside {
+		_, _ = fmt.Fprintf(stderr, "Geofence: inside the area at %.6f,%.6f, hopping on all channels\n", p.Lat, p.Lon)
+	} else if fix {
+		_, _ = fmt.Fprintf(stderr, "Geofence: outside the area at %.6f,%.6f\n", p.Lat, p.Lon)
+	} else {
+		_, _ = fmt.Fprintf(stderr, "Geofence: no GPS fix, acting as outside the area\n")
+	}
+	if err := g.apply(inside); err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: geofence: %v\n", err)
+	}
+}
+
+func (g *geofence) apply(inside bool) error {
+	g.inside = inside
+	var keep func(channel int) bool
+	if !inside {
+		keep = func(channel int) bool { return !g.fenced(channel) }
+	}
+	left, err := g.plan.filter(keep, g.idle)
+	if err != nil {
+		return err
+	}
+	if left == 0 {
+		_, _ = fmt.Fprintf(stderr, "Geofence: no channel may be hopped here, idling\n")
+	}
+	return nil
+}
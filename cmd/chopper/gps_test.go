@@ -0,0 +1,259 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func TestParseGPSDReport(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   position
+		fix   bool
+		ok    bool
+	}{
+		{`{"class":"TPV","device":"/dev/ttyUSB0","mode":3,"lat":45.4642,"lon":9.19,"alt":120.5}`, position{45.4642, 9.19}, true, true},
+		{`{"class":"TPV","mode":1}`, position{}, false, true},
+		{`{"class":"TPV","mode":2}`, position{}, false, true},
+		{`{"class":"SKY","satellites":[]}`, position{}, false, false},
+		{`{"class":"VERSION","release":"3.22"}`, position{}, false, false},
+		{`garbage`, position{}, false, false},
+	}
+
+	for _, tt := range tests {
+		pos, fix, ok := parseGPSDReport([]byte(tt.input))
+		if pos != tt.pos || fix != tt.fix || ok != tt.ok {
+			t.Errorf("parseGPSDReport(%v) = %v, %v, %v, want %v, %v, %v", tt.input, pos, fix, ok, tt.pos, tt.fix, tt.ok)
+		}
+	}
+}
+
+// testFence is a square around Milan's cathedral.
+const testFence = `# Piazza del Duomo
+45.4630,9.1880
+45.4630 9.1930
+45.4660,9.1930
+
+45.4660,9.1880
+`
+
+func TestPolygon(t *testing.T) {
+	p, err := parsePolygon(strings.NewReader(testFence))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 4 {
+		t.Fatalf("parsePolygon: %v", p)
+	}
+
+	tests := []struct {
+		pos  position
+		want bool
+	}{
+		{position{45.4641, 9.1919}, true},
+		{position{45.4700, 9.1919}, false},
+		{position{45.4641, 9.1800}, false},
+		{position{-45.4641, -9.1919}, false},
+	}
+	for _, tt := range tests {
+		if got := p.contains(tt.pos); got != tt.want {
+			t.Errorf("contains(%v) = %v, want %v", tt.pos, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"45.46,9.18\n45.47,9.19\n", "45.46\n45.47,9.19\n45.48,9.18\n", "91,9.18\n45.47,9.19\n45.48,9.18\n", "45.46,181\n45.47,9.19\n45.48,9.18\n"} {
+		if _, err := parsePolygon(strings.NewReader(input)); err == nil {
+			t.Errorf("parsePolygon(%q) accepted an invalid fence", input)
+		}
+	}
+}
+
+func TestParseFenceChannels(t *testing.T) {
+	channels := []int{1, 6, 36, 52, 100, 149, plan.Channel6GHz(37)}
+	tests := []struct {
+		input  string
+		fenced []int
+	}{
+		{"all", channels},
+		{"dfs", []int{52, 100}},
+		{"5", []int{36, 52, 100, 149}},
+		{"dfs, 6", []int{52, 100, plan.Channel6GHz(37)}},
+		{"2.4", []int{1, 6}},
+	}
+	for _, tt := range tests {
+		fenced, err := parseFenceChannels(tt.input)
+		if err != nil {
+			t.Fatalf("parseFenceChannels(%q): %v", tt.input, err)
+		}
+		var got []int
+		for _, channel := range channels {
+			if fenced(channel) {
+				got = append(got, channel)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.fenced) {
+			t.Errorf("parseFenceChannels(%q) fences %v, want %v", tt.input, got, tt.fenced)
+		}
+	}
+	if _, err := parseFenceChannels("dfs,7"); err == nil {
+		t.Error("parseFenceChannels accepted an unknown band")
+	}
+}
+
+// fakePausable is a fakePlanner that can be paused.
+type fakePausable struct {
+	fakePlanner
+	paused bool
+}
+
+func (p *fakePausable) Pause()  { p.paused = true }
+func (p *fakePausable) Resume() { p.paused = false }
+
+func TestGeofence(t *testing.T) {
+	area, err := parsePolygon(strings.NewReader(testFence))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inside := position{45.4641, 9.1919}
+	outside := position{45.4700, 9.1919}
+
+	dfs, _ := parseFenceChannels("dfs")
+	p := &fakePausable{fakePlanner: fakePlanner{channels: []int{1, 36, 52, 100}}}
+	shared := newSharedPlan(p)
+	g := &geofence{polygon: area, fenced: dfs}
+	if err := g.start(shared, p); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 36}; !reflect.DeepEqual(p.channels, want) || p.paused {
+		t.Fatalf("before the first fix: %v, paused %v", p.channels, p.paused)
+	}
+	g.update(inside, true)
+	if want := []int{1, 36, 52, 100}; !reflect.DeepEqual(p.channels, want) {
+		t.Fatalf("inside: %v", p.channels)
+	}
+	g.update(position{}, false)
+	if want := []int{1, 36}; !reflect.DeepEqual(p.channels, want) {
+		t.Fatalf("without a fix: %v", p.channels)
+	}
+
+	// Edits of the plan are fenced and kept across transitions
+	if err := shared.AddChannel(120); err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.RemoveChannel(36); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1}; !reflect.DeepEqual(p.channels, want) {
+		t.Fatalf("edited outside: %v", p.channels)
+	}
+	g.update(inside, true)
+	if want := []int{1, 52, 100, 120}; !reflect.DeepEqual(p.channels, want) {
+		t.Fatalf("edited inside: %v", p.channels)
+	}
+
+	// Locks are left alone, the fence applies once released
+	l := &channelLock{plan: shared, reason: "test", timeout: time.Minute}
+	if err := l.lock(100, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	g.update(outside, true)
+	if want := []int{100}; !reflect.DeepEqual(p.channels, want) {
+		t.Fatalf("locked outside: %v", p.channels)
+	}
+	if err := l.release(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1}; !reflect.DeepEqual(p.channels, want) {
+		t.Fatalf("released outside: %v", p.channels)
+	}
+
+	all, _ := parseFenceChannels("all")
+	p = &fakePausable{fakePlanner: fakePlanner{channels: []int{1, 6, 11}}}
+	g = &geofence{polygon: area, fenced: all}
+	if err := g.start(newSharedPlan(p), p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.paused {
+		t.Fatal("the hopper is not idle before the first fix")
+	}
+	g.update(inside, true)
+	if p.paused || !reflect.DeepEqual(p.channels, []int{1, 6, 11}) {
+		t.Fatalf("inside: %v, paused %v", p.channels, p.paused)
+	}
+	g.update(outside, true)
+	if !p.paused {
+		t.Fatal("the hopper is not idle outside the fence")
+	}
+}
+
+func TestWatchGPSD(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	watch := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		watch <- line
+		_, _ = conn.Write([]byte(`{"class":"VERSION","release":"3.22"}` + "\n" +
+			`{"class":"TPV","mode":3,"lat":45.4641,"lon":9.1919}` + "\n" +
+			`{"class":"TPV","mode":1}` + "\n"))
+	}()
+
+	type update struct {
+		pos position
+		fix bool
+	}
+	updates := make(chan update, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchGPSD(ctx, l.Addr().String(), func(p position, fix bool) {
+		updates <- update{p, fix}
+	})
+
+	if got := <-watch; got != gpsdWatch {
+		t.Fatalf("gpsd received %q", got)
+	}
+	// The fix, its loss, then the end of the connection
+	want := []update{{position{45.4641, 9.1919}, true}, {position{}, false}, {position{}, false}}
+	for _, w := range want {
+		select {
+		case got := <-updates:
+			if got != w {
+				t.Fatalf("update:\n- want: %+v\n-  got: %+v", w, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no update, want %+v", w)
+		}
+	}
+}
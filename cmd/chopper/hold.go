@@ -0,0 +1,283 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// holdPoll is how often a regular hold file is checked for changes.
+const holdPoll = 250 * time.Millisecond
+
+// holdCommand is a line of the hold file: HOLD, HOLD <channel> or RELEASE.
+type holdCommand struct {
+	hold bool
+	// channel is the channel to hold, 0 for the current one
+	channel int
+}
+
+// parseHoldCommand parses a line of the hold file. Blank lines and lines
+// starting with # are returned as nil.
+func parseHoldCommand(line string) (*holdCommand, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	fields := strings.Fields(line)
+	switch strings.ToUpper(fields[0]) {
+	case "HOLD":
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid hold command %q, expected HOLD [channel]", line)
+		}
+		command := &holdCommand{hold: true}
+		if len(fields) == 2 {
+			channel, err := plan.ParseChannel(fields[1])
+			if err != nil || channel == 0 {
+				return nil, fmt.Errorf("invalid channel in %q", line)
+			}
+			command.channel = channel
+		}
+		return command, nil
+	case "RELEASE":
+		if len(fields) > 1 {
+			return nil, fmt.Errorf("invalid hold command %q, RELEASE takes no channel", line)
+		}
+		return &holdCommand{}, nil
+	}
+	return nil, fmt.Errorf("unknown hold command %q, expected HOLD or RELEASE", line)
+}
+
+// holdFile obeys the HOLD and RELEASE commands that capture tools write to
+// a FIFO or a regular file, for tools that cannot use the API. A HOLD
+// without a channel freezes hopping on the current channel.
+type holdFile struct {
+	path string
+	fifo bool
+	// created tells whether chopper created the FIFO, removed by Close
+	created bool
+
+	mu   sync.Mutex
+	lock channelLock
+	// channel is the current channel of the hopper
+	channel int
+	// pending is a HOLD of the current channel received before the first hop
+	pending bool
+}
+
+func newHoldFile(path string, shared *sharedPlan, timeout time.Duration) *holdFile {
+	return &holdFile{path: path, lock: channelLock{plan: shared, reason: "hold", timeout: timeout}}
+}
+
+func (f *holdFile) hop(channel int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.channel = channel
+	if f.pending {
+		f.pending = false
+		f.warn(f.hold(channel, time.Now()))
+	}
+}
+
+func (f *holdFile) warn(err error) {
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: hold file: %v\n", err)
+	}
+}
+
+// apply runs a command.
+func (f *holdFile) apply(command *holdCommand, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !command.hold {
+		f.pending = false
+		if f.lock.channel != 0 {
+			_, _ = fmt.Fprintf(stderr, "Hold file: released, resuming hopping\n")
+		}
+		return f.lock.release()
+	}
+
+	channel := command.channel
+	if channel == 0 {
+		channel = f.lock.channel
+	}
+	if channel == 0 {
+		channel = f.channel
+	}
+	if channel == 0 {
+		f.pending = true
+		return nil
+	}
+	return f.hold(channel, now)
+}
+
+func (f *holdFile) hold(channel int, now time.Time) error {
+	if f.lock.channel != channel {
+		_, _ = fmt.Fprintf(stderr, "Hold file: holding channel %v\n", plan.FormatChannel(channel))
+	}
+	return f.lock.lock(channel, now)
+}
+
+// expire releases a hold older than the timeout, 0 holding until RELEASE.
+func (f *holdFile) expire(now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.lock.timeout == 0 || f.lock.channel == 0 || now.Before(f.lock.until) {
+		return nil
+	}
+	_, _ = fmt.Fprintf(stderr, "Hold file: no RELEASE after %v, resuming hopping\n", f.lock.timeout)
+	return f.lock.release()
+}
+
+// open checks the hold file, creating a FIFO if the path does not exist.
+func (f *holdFile) open() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := mkfifo(f.path, 0600); err != nil {
+			return fmt.Errorf("cannot create %v: %v", f.path, err)
+		}
+		f.fifo = true
+		f.created = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch mode := info.Mode(); {
+	case mode&os.ModeNamedPipe != 0:
+		f.fifo = true
+		return nil
+	case mode.IsRegular():
+		return nil
+	}
+	return fmt.Errorf("%v is neither a FIFO nor a regular file", f.path)
+}
+
+// Close removes the FIFO if open created it.
+func (f *holdFile) Close() error {
+	if !f.created {
+		return nil
+	}
+	return os.Remove(f.path)
+}
+
+// run obeys the commands written to the hold file until ctx is done.
+func (f *holdFile) run(ctx context.Context) {
+	ticker := time.NewTicker(holdPoll)
+	defer ticker.Stop()
+
+	var lines <-chan string
+	var last string
+	if f.fifo {
+		lines = f.readFIFO(ctx)
+	} else {
+		last = f.poll("")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			f.handle(line)
+		case now := <-ticker.C:
+			if !f.fifo {
+				last = f.poll(last)
+			}
+			f.warn(f.expire(now))
+		}
+	}
+}
+
+// handle parses and applies a line.
+func (f *holdFile) handle(line string) {
+	command, err := parseHoldCommand(line)
+	if err == nil && command != nil {
+		err = f.apply(command, time.Now())
+	}
+	f.warn(err)
+}
+
+// poll applies the last command of the regular hold file if its contents
+// changed since last, returning them.
+func (f *holdFile) poll(last string) string {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		warnf("hold file: %v", err)
+		return last
+	}
+	contents := string(b)
+	if contents == last {
+		return last
+	}
+
+	lines := strings.Split(contents, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if command, err := parseHoldCommand(lines[i]); err != nil || command != nil {
+			f.handle(lines[i])
+			break
+		}
+	}
+	return contents
+}
+
+// readFIFO returns the lines written to the FIFO until ctx is done. The
+// FIFO is opened for writing too, so it does not reach EOF when a writer
+// closes it.
+func (f *holdFile) readFIFO(ctx context.Context) <-chan string {
+	lines := make(chan string)
+	file, err := os.OpenFile(f.path, os.O_RDWR, 0)
+	if err != nil {
+		f.warn(err)
+		close(lines)
+		return lines
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHoldCommand(t *testing.T) {
+	tests := []struct {
+		input  string
+		output *holdCommand
+		ok     bool
+	}{
+		{"HOLD", &holdCommand{hold: true}, true},
+		{"  hold 6\n", &holdCommand{hold: true, channel: 6}, true},
+		{"HOLD 36+500k", &holdCommand{hold: true, channel: 36 + 500<<16}, true},
+		{"RELEASE", &holdCommand{}, true},
+		{"", nil, true},
+		{"# sniffer started", nil, true},
+		{"HOLD 6 11", nil, false},
+		{"HOLD 0", nil, false},
+		{"HOLD abc", nil, false},
+		{"RELEASE 6", nil, false},
+		{"STOP", nil, false},
+	}
+
+	for _, test := range tests {
+		output, err := parseHoldCommand(test.input)
+		if (err == nil) != test.ok {
+			t.Errorf("parseHoldCommand(%q) error = %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(output, test.output) {
+			t.Errorf("parseHoldCommand(%q) = %+v, want %+v", test.input, output, test.output)
+		}
+	}
+}
+
+func TestHoldFileApply(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	f := newHoldFile("", newSharedPlan(p), time.Minute)
+	start := time.Now()
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"hold before the first hop", func() error { return f.apply(&holdCommand{hold: true}, start) }, []int{1, 6, 11}},
+		{"first hop", func() error { f.hop(6); return nil }, []int{6}},
+		{"hold the current channel", func() error { return f.apply(&holdCommand{hold: true}, start) }, []int{6}},
+		{"hold another channel", func() error { return f.apply(&holdCommand{hold: true, channel: 11}, start) }, []int{11}},
+		{"release", func() error { return f.apply(&holdCommand{}, start) }, []int{1, 6, 11}},
+		{"hold again", func() error { return f.apply(&holdCommand{hold: true}, start) }, []int{6}},
+		{"not expired", func() error { return f.expire(start.Add(30 * time.Second)) }, []int{6}},
+		{"expired", func() error { return f.expire(start.Add(time.Minute)) }, []int{1, 6, 11}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(step.output, p.channels) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+
+	// Without a timeout holds last until RELEASE
+	f = newHoldFile("", newSharedPlan(p), 0)
+	f.hop(1)
+	if err := f.apply(&holdCommand{hold: true}, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.expire(start.Add(24 * time.Hour)); err != nil || !reflect.DeepEqual(p.channels, []int{1}) {
+		t.Fatalf("hold without a timeout expired: %v, %v", p.channels, err)
+	}
+}
+
+func TestHoldFileSharedLock(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	shared := newSharedPlan(p)
+	f := newHoldFile("", shared, 0)
+	k := newHandshakeLock(shared, 5*time.Second, 0)
+	start := time.Now()
+	message := eapolFrame{channel: 6, ap: "00:11:22:33:44:55", station: "a", message: 1}
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"handshake", func() error { return k.observe(message, start) }, []int{6}},
+		{"hold", func() error { return f.apply(&holdCommand{hold: true, channel: 11}, start.Add(time.Second)) }, []int{11}},
+		{"handshake expired", func() error { return k.expire(start.Add(6 * time.Second)) }, []int{11}},
+		{"release", func() error { return f.apply(&holdCommand{}, start.Add(7*time.Second)) }, []int{1, 6, 11}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(step.output, p.channels) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+}
+
+// waitChannels waits for the plan of p to become want.
+func waitChannels(t *testing.T, f *holdFile, p *fakePlanner, want []int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		got := p.channels
+		f.mu.Unlock()
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("plan is %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHoldFileFIFO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hold")
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	f := newHoldFile(path, newSharedPlan(p), time.Minute)
+	if err := f.open(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("open did not create a FIFO: %v, %v", info, err)
+	}
+	f.hop(6)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.run(ctx)
+
+	write := func(line string) {
+		w, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+		if _, err := w.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("HOLD")
+	waitChannels(t, f, p, []int{6})
+	// Writers come and go without closing the FIFO for chopper
+	write("HOLD 11")
+	waitChannels(t, f, p, []int{11})
+	write("RELEASE")
+	waitChannels(t, f, p, []int{1, 6, 11})
+
+	cancel()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Close did not remove the FIFO: %v", err)
+	}
+}
+
+func TestHoldFileRegular(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hold")
+	if err := os.WriteFile(path, []byte("HOLD 6\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	f := newHoldFile(path, newSharedPlan(p), time.Minute)
+	if err := f.open(); err != nil {
+		t.Fatal(err)
+	}
+	if f.fifo {
+		t.Fatal("open took a regular file for a FIFO")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.run(ctx)
+
+	// The file is obeyed from the start
+	waitChannels(t, f, p, []int{6})
+	// The last command wins
+	if err := os.WriteFile(path, []byte("HOLD 6\nRELEASE\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitChannels(t, f, p, []int{1, 6, 11})
+
+	cancel()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Close removed a file it did not create: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// execHook runs a shell command on every hop.
+type execHook struct {
+	// point is "before" or "after" the retune, exported as CHOPPER_HOOK.
+	point   string
+	command string
+	timeout time.Duration
+	// block makes run wait for the command. Otherwise the command runs in
+	// the background and hops are skipped while it is still running.
+	block bool
+
+	running int32
+	skipped int64
+}
+
+// hookEnv returns the environment describing the hop to channel.
+func hookEnv(channel int) []string {
+	return []string{
+		"CHOPPER_CHANNEL=" + plan.FormatChannel(channel),
+		"CHOPPER_FREQ=" + strconv.Itoa(plan.Frequency(channel)),
+		// Only 20 MHz channels are supported for now
+		"CHOPPER_WIDTH=20",
+	}
+}
+
+// run runs the command for a hop to channel.
+func (e *execHook) run(channel int) {
+	if !atomic.CompareAndSwapInt32(&e.running, 0, 1) {
+		// Report the first skipped hop, then every 100
+		if n := atomic.AddInt64(&e.skipped, 1); n%100 == 1 {
+			_, _ = fmt.Fprintf(stderr, "WARNING: hook %q is still running, skipped %v hops\n", e.command, n)
+		}
+		return
+	}
+
+	now := time.Now()
+	if e.block {
+		e.exec(channel, now)
+		return
+	}
+	go e.exec(channel, now)
+}
+
+func (e *execHook) exec(channel int, now time.Time) {
+	defer atomic.StoreInt32(&e.running, 0)
+
+	// CHOPPER_TIME is in nanoseconds, like pcapng timestamps
+	env := append(hookEnv(channel), "CHOPPER_HOOK="+e.point, "CHOPPER_TIME="+strconv.FormatInt(now.UnixNano(), 10))
+	if err := runHook(e.command, env, e.timeout); err != nil {
+		warnf("hook %q on channel %v: %v", e.command, plan.FormatChannel(channel), err)
+	}
+}
+
+// runHook runs command with sh and env added to the environment, killing
+// it and its children after timeout. Its output goes to stderr, stdout is
+// reserved for events.
+func runHook(command string, env []string, timeout time.Duration) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = stderr
+	cmd.Stderr = stderr
+	ownProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	if timeout <= 0 {
+		return <-done
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		_ = killProcessGroup(cmd)
+		<-done
+		return fmt.Errorf("killed after %v", timeout)
+	}
+}
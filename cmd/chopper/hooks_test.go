@@ -0,0 +1,95 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func TestHookEnv(t *testing.T) {
+	tests := []struct {
+		channel int
+		output  string
+	}{
+		{6, "CHOPPER_CHANNEL=6 CHOPPER_FREQ=2437 CHOPPER_WIDTH=20"},
+		{36, "CHOPPER_CHANNEL=36 CHOPPER_FREQ=5180 CHOPPER_WIDTH=20"},
+		{plan.Channel6GHz(37), "CHOPPER_CHANNEL=6g37 CHOPPER_FREQ=6135 CHOPPER_WIDTH=20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			if got := strings.Join(hookEnv(tt.channel), " "); got != tt.output {
+				t.Fatalf("hookEnv(%v):\n- want: %v\n-  got: %v", tt.channel, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chopper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	if err := runHook(`echo "$CHOPPER_CHANNEL $CHOPPER_FREQ" > `+out, hookEnv(11), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "11 2462\n", string(b); want != got {
+		t.Fatalf("hook output:\n- want: %q\n-  got: %q", want, got)
+	}
+
+	if err := runHook("exit 3", nil, time.Second); err == nil {
+		t.Fatalf("runHook with a failing command: want error")
+	}
+
+	start := time.Now()
+	if err := runHook("sleep 5 & wait", nil, 50*time.Millisecond); err == nil {
+		t.Fatalf("runHook with a slow command: want error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("runHook did not kill the command after the timeout, took %v", elapsed)
+	}
+}
+
+func TestExecHookSkips(t *testing.T) {
+	e := &execHook{command: "sleep 0.2", timeout: time.Second}
+	e.run(1)
+	e.run(6)
+	if want, got := int64(1), e.skipped; want != got {
+		t.Fatalf("skipped hops:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	e.block = true
+	time.Sleep(500 * time.Millisecond)
+	e.run(11)
+	if want, got := int64(1), e.skipped; want != got {
+		t.Fatalf("skipped hops after completion:\n- want: %v\n-  got: %v", want, got)
+	}
+}
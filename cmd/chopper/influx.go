@@ -0,0 +1,151 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/influx"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// influxSink turns events into measurements: chopper_hop, chopper_error,
+// chopper_bss, chopper_alert, chopper_spectral and chopper_conflict.
+type influxSink struct {
+	w *influx.Writer
+}
+
+func (s influxSink) Encode(event events.Event) error {
+	tags := map[string]string{"interface": event.Interface}
+	var point influx.Point
+	switch event.Type {
+	case events.TypeHop:
+		channelTags(tags, event.Channel)
+		point = influx.Point{Measurement: "chopper_hop", Fields: map[string]interface{}{
+			"frequency": event.Frequency,
+		}}
+	case events.TypeError:
+		point = influx.Point{Measurement: "chopper_error", Fields: map[string]interface{}{
+			"message": event.Error,
+		}}
+	case events.TypeBSS:
+		tags["bssid"] = event.BSSID
+		tags["ssid"] = event.SSID
+		channelTags(tags, plan.ChannelOf(event.Frequency))
+		point = influx.Point{Measurement: "chopper_bss", Fields: map[string]interface{}{
+			"frequency": event.Frequency,
+			"signal":    event.Signal,
+		}}
+	case events.TypeAlert:
+		tags["alert"] = event.Alert
+		channelTags(tags, event.Channel)
+		point = influx.Point{Measurement: "chopper_alert", Fields: map[string]interface{}{
+			"frames": event.Frames,
+			"rate":   event.Rate,
+			"bssid":  event.BSSID,
+		}}
+	case events.TypeSpectral:
+		channelTags(tags, event.Channel)
+		point = influx.Point{Measurement: "chopper_spectral", Fields: map[string]interface{}{
+			"samples":   event.Samples,
+			"signal":    event.Signal,
+			"noise":     event.Noise,
+			"magnitude": event.Magnitude,
+		}}
+	case events.TypeConflict:
+		channelTags(tags, event.Channel)
+		point = influx.Point{Measurement: "chopper_conflict", Fields: map[string]interface{}{
+			"frequency": event.Frequency,
+			"conflicts": event.Conflicts,
+		}}
+	case events.TypePower:
+		tags["throttle"] = event.Throttle
+		point = influx.Point{Measurement: "chopper_power", Fields: map[string]interface{}{
+			"battery":     event.Battery,
+			"temperature": event.Temperature,
+		}}
+	default:
+		return nil
+	}
+	point.Tags = tags
+	point.Time = event.Time
+	return s.w.Write(point)
+}
+
+// channelTags adds the channel and band tags if channel is known.
+func channelTags(tags map[string]string, channel int) {
+	if channel == 0 {
+		return
+	}
+	tags["channel"] = plan.FormatChannel(channel)
+	tags["band"] = plan.BandOf(channel).String()
+}
+
+// surveyPoints returns a chopper_survey measurement per frequency. The
+// times are the cumulative counters reported by the driver.
+func surveyPoints(iface string, at time.Time, surveys []nl80211util.SurveyInfo) []influx.Point {
+	points := make([]influx.Point, 0, len(surveys))
+	for _, s := range surveys {
+		tags := map[string]string{"interface": iface, "frequency": strconv.Itoa(s.Frequency)}
+		channelTags(tags, plan.ChannelOf(s.Frequency))
+		points = append(points, influx.Point{
+			Measurement: "chopper_survey",
+			Tags:        tags,
+			Fields: map[string]interface{}{
+				"noise":     s.Noise,
+				"in_use":    s.InUse,
+				"active_ms": s.Time,
+				"busy_ms":   s.TimeBusy,
+				"rx_ms":     s.TimeRx,
+			},
+			Time: at,
+		})
+	}
+	return points
+}
+
+// startInflux opens the line protocol destination and flushes it every
+// interval in the background. The returned function stops it after a final
+// flush.
+func startInflux(url, token string, interval time.Duration) (*influx.Writer, func(), error) {
+	w, err := influx.Open(url, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.Run(ctx, interval, func(err error) {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot write to InfluxDB: %v\n", err)
+		})
+	}()
+
+	return w, func() {
+		cancel()
+		wg.Wait()
+		_ = w.Close()
+	}, nil
+}
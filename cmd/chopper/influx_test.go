@@ -0,0 +1,105 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/influx"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestInfluxSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.lp")
+	w, err := influx.Open(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	sink := influxSink{w}
+
+	at := time.Unix(1633089600, 0)
+	hop := events.New(events.TypeHop)
+	hop.Time = at
+	hop.Interface = "wlan0mon"
+	hop.Channel = 36
+	hop.Frequency = 5180
+	failure := events.New(events.TypeError)
+	failure.Time = at
+	failure.Interface = "wlan0mon"
+	failure.Error = "device busy"
+	alert := events.New(events.TypeAlert)
+	alert.Time = at
+	alert.Interface = "wlan0mon"
+	alert.Alert = alertDeauthFlood
+	alert.Channel = 6
+	alert.Frames = 120
+	alert.Rate = 24
+	alert.BSSID = "00:11:22:33:44:55"
+	spectral := events.New(events.TypeSpectral)
+	spectral.Time = at
+	spectral.Interface = "wlan0mon"
+	spectral.Channel = 11
+	spectral.Samples = 40
+	spectral.Signal = -74.5
+	spectral.Noise = -95
+	spectral.Magnitude = 310
+	conflict := events.New(events.TypeConflict)
+	conflict.Time = at
+	conflict.Interface = "wlan0mon"
+	conflict.Channel = 1
+	conflict.Frequency = 2437
+	conflict.Conflicts = 3
+	power := events.New(events.TypePower)
+	power.Time = at
+	power.Interface = "wlan0mon"
+	power.Battery = 18
+	power.Temperature = 61.5
+	power.Throttle = "slow"
+	for _, event := range []events.Event{hop, events.New(events.TypeCycle), failure, alert, spectral, conflict, power} {
+		if err := sink.Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	surveys := []nl80211util.SurveyInfo{{Frequency: 2412, Noise: -92, InUse: true, Time: 1000, TimeBusy: 200, TimeRx: 150}}
+	if err := w.Write(surveyPoints("wlan0mon", at, surveys)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "chopper_hop,band=5GHz,channel=36,interface=wlan0mon frequency=5180i 1633089600000000000\n" +
+		"chopper_error,interface=wlan0mon message=\"device busy\" 1633089600000000000\n" +
+		"chopper_alert,alert=deauth_flood,band=2.4GHz,channel=6,interface=wlan0mon bssid=\"00:11:22:33:44:55\",frames=120i,rate=24 1633089600000000000\n" +
+		"chopper_spectral,band=2.4GHz,channel=11,interface=wlan0mon magnitude=310i,noise=-95,samples=40i,signal=-74.5 1633089600000000000\n" +
+		"chopper_conflict,band=2.4GHz,channel=1,interface=wlan0mon conflicts=3i,frequency=2437i 1633089600000000000\n" +
+		"chopper_power,interface=wlan0mon,throttle=slow battery=18i,temperature=61.5 1633089600000000000\n" +
+		"chopper_survey,band=2.4GHz,channel=1,frequency=2412,interface=wlan0mon active_ms=1000i,busy_ms=200i,in_use=true,noise=-92i,rx_ms=150i 1633089600000000000\n"
+	if string(data) != want {
+		t.Fatalf("line protocol:\n- want: %v\n-  got: %v", want, string(data))
+	}
+}
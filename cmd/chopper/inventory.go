@@ -0,0 +1,201 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// maxInventory bounds the networks remembered by the inventory.
+const maxInventory = 65536
+
+// inventoryEntry is a BSS seen during the run.
+type inventoryEntry struct {
+	BSSID     string `json:"bssid"`
+	SSID      string `json:"ssid"`
+	Channel   int    `json:"channel"`
+	Frequency int    `json:"frequency"`
+	Security  string `json:"security"`
+	Vendor    string `json:"vendor,omitempty"`
+	// MaxSignal is the strongest beacon in dBm, 0 if the driver does not
+	// report it.
+	MaxSignal int       `json:"max_signal,omitempty"`
+	Beacons   int       `json:"beacons"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// inventory aggregates the beacons seen by the capture.
+type inventory struct {
+	minSignal minSignal
+
+	mu      sync.Mutex
+	entries map[string]*inventoryEntry
+}
+
+func newInventory() *inventory {
+	return &inventory{entries: make(map[string]*inventoryEntry)}
+}
+
+// observe is a frameObserver.
+func (inv *inventory) observe(tuned int, packet dot11.Packet) {
+	inv.add(tuned, packet, time.Now())
+}
+
+func (inv *inventory) add(tuned int, packet dot11.Packet, now time.Time) {
+	f := packet.Frame
+	if f.Type != dot11.TypeManagement || f.Subtype != dot11.SubtypeBeacon || !inv.minSignal.accepts(packet) {
+		return
+	}
+	beacon, err := dot11.ParseBeacon(f.Body)
+	if err != nil {
+		return
+	}
+
+	// 2.4 GHz beacons leak into adjacent channels, trust the AP
+	channel := tuned
+	if plan.BandOf(tuned) == plan.Band2GHz && beacon.Channel() != 0 {
+		channel = beacon.Channel()
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	bssid := f.BSSID().String()
+	e, ok := inv.entries[bssid]
+	if !ok {
+		if len(inv.entries) >= maxInventory {
+			return
+		}
+		e = &inventoryEntry{BSSID: bssid, Vendor: vendorOf(bssid), FirstSeen: now.UTC()}
+		inv.entries[bssid] = e
+	}
+	e.SSID = beacon.SSID()
+	e.Channel = channel
+	e.Frequency = plan.Frequency(channel)
+	e.Security = beacon.Security()
+	e.Beacons++
+	e.LastSeen = now.UTC()
+	if packet.Radiotap.HasSignal && (e.MaxSignal == 0 || packet.Radiotap.Signal > e.MaxSignal) {
+		e.MaxSignal = packet.Radiotap.Signal
+	}
+}
+
+// sorted returns the entries by channel, strongest first.
+func (inv *inventory) sorted() []inventoryEntry {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	ret := make([]inventoryEntry, 0, len(inv.entries))
+	for _, e := range inv.entries {
+		ret = append(ret, *e)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.Channel != b.Channel {
+			return a.Channel < b.Channel
+		}
+		if a.MaxSignal != b.MaxSignal {
+			// 0 is unknown and sorts last
+			return a.MaxSignal != 0 && (b.MaxSignal == 0 || a.MaxSignal > b.MaxSignal)
+		}
+		return a.BSSID < b.BSSID
+	})
+	return ret
+}
+
+// writeTable prints the inventory for humans.
+func (inv *inventory) writeTable(w io.Writer) {
+	entries := inv.sorted()
+	_, _ = fmt.Fprintf(w, "Inventory: %v networks\n", len(entries))
+	if len(entries) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, paint(term != nil && term.color, ansiBold, fmt.Sprintf("%-19s%-33s%-17s%-9s%-11s%-8s%-10s%s", "BSSID", "SSID", "VENDOR", "CHANNEL", "SECURITY", "SIGNAL", "BEACONS", "SEEN")))
+	for _, e := range entries {
+		ssid := e.SSID
+		if ssid == "" {
+			ssid = "<hidden>"
+		}
+		signal := "-"
+		if e.MaxSignal != 0 {
+			signal = strconv.Itoa(e.MaxSignal)
+		}
+		vendor := e.Vendor
+		if vendor == "" {
+			vendor = "-"
+		} else if len(vendor) > 16 {
+			vendor = vendor[:16]
+		}
+		_, _ = fmt.Fprintf(w, "%-19s%-33s%-17s%-9s%-11s%-8s%-10d%v - %v\n", e.BSSID, ssid, vendor, channelLabel(e.Channel), e.Security, signal, e.Beacons,
+			e.FirstSeen.Local().Format("15:04:05"), e.LastSeen.Local().Format("15:04:05"))
+	}
+}
+
+// writeJSON writes the inventory as a JSON array.
+func (inv *inventory) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv.sorted())
+}
+
+// writeCSV writes the inventory as CSV with a header.
+func (inv *inventory) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"bssid", "ssid", "vendor", "channel", "frequency", "security", "max_signal", "beacons", "first_seen", "last_seen"})
+	for _, e := range inv.sorted() {
+		signal := ""
+		if e.MaxSignal != 0 {
+			signal = strconv.Itoa(e.MaxSignal)
+		}
+		_ = cw.Write([]string{e.BSSID, e.SSID, e.Vendor, plan.FormatChannel(e.Channel), strconv.Itoa(e.Frequency), e.Security, signal,
+			strconv.Itoa(e.Beacons), e.FirstSeen.Format(time.RFC3339), e.LastSeen.Format(time.RFC3339)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeFile writes the inventory to path, as CSV if it ends in .csv and
+// as JSON otherwise.
+func (inv *inventory) writeFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = inv.writeCSV(f)
+	} else {
+		err = inv.writeJSON(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
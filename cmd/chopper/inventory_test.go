@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// testBeaconSignal returns a beacon from bssid received at signal dBm.
+func testBeaconSignal(t *testing.T, bssid []byte, channel byte, signal int8) dot11.Packet {
+	b := testBeacon(bssid, channel)
+	// Radiotap header with the antenna signal field
+	b = append([]byte{0, 0, 9, 0, 0x20, 0, 0, 0, byte(signal)}, b[len(radiotapHeader):]...)
+	packet, err := dot11.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestInventory(t *testing.T) {
+	inv := newInventory()
+	start := time.Unix(1633089600, 0)
+	other := []byte{0x02, 0, 0, 0, 0, 0x99}
+
+	// Leaked into channel 5, the beacon tells channel 6
+	inv.add(5, testBeaconSignal(t, testAP, 6, -70), start)
+	inv.add(6, testBeaconSignal(t, testAP, 6, -50), start.Add(time.Second))
+	inv.add(6, testBeaconSignal(t, testAP, 6, -60), start.Add(2*time.Second))
+	inv.add(36, testBeaconSignal(t, other, 0, -80), start.Add(3*time.Second))
+	inv.add(6, testDeauth(other, false), start)
+	inv.add(plan.Channel6GHz(37), testBeaconSignal(t, []byte{0x02, 0, 0, 0, 0, 0x42}, 1, -40), start)
+
+	entries := inv.sorted()
+	if len(entries) != 3 {
+		t.Fatalf("entries: %+v", entries)
+	}
+	e := entries[0]
+	if e.BSSID != "00:11:22:33:44:55" || e.SSID != "test" || e.Channel != 6 || e.Frequency != 2437 || e.Security != "Open" {
+		t.Fatalf("entry: %+v", e)
+	}
+	if e.MaxSignal != -50 || e.Beacons != 3 || !e.FirstSeen.Equal(start) || !e.LastSeen.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("entry: %+v", e)
+	}
+	if entries[1].Channel != 36 || entries[2].Channel != plan.Channel6GHz(37) || entries[1].Vendor != "random" {
+		t.Fatalf("channels: %v, %v", entries[1].Channel, entries[2].Channel)
+	}
+
+	var buf bytes.Buffer
+	inv.writeTable(&buf)
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 5 || lines[0] != "Inventory: 3 networks" || !strings.HasPrefix(lines[2], "00:11:22:33:44:55  test") {
+		t.Fatalf("table:\n%v", buf.String())
+	}
+}
+
+func TestInventoryFile(t *testing.T) {
+	inv := newInventory()
+	inv.add(6, testBeaconSignal(t, testAP, 6, -50), time.Unix(1633089600, 0))
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "inventory.csv")
+	if err := inv.writeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "bssid,ssid,vendor,channel,frequency,security,max_signal,beacons,first_seen,last_seen\n" +
+		"00:11:22:33:44:55,test,,6,2437,Open,-50,1,2021-10-01T12:00:00Z,2021-10-01T12:00:00Z\n"
+	if string(data) != want {
+		t.Fatalf("csv:\n- want: %v\n-  got: %v", want, string(data))
+	}
+
+	path = filepath.Join(dir, "inventory.json")
+	if err := inv.writeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []inventoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].BSSID != "00:11:22:33:44:55" || entries[0].MaxSignal != -50 {
+		t.Fatalf("json: %+v", entries)
+	}
+}
@@ -0,0 +1,163 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// kismetFields are the device fields requested from Kismet.
+var kismetFields = []string{
+	"kismet.device.base.phyname",
+	"kismet.device.base.frequency",
+}
+
+// kismetDevice is a device as returned by Kismet with kismetFields.
+type kismetDevice struct {
+	PHY       string  `json:"kismet.device.base.phyname"`
+	Frequency float64 `json:"kismet.device.base.frequency"`
+}
+
+// kismetClient polls the device list of a Kismet server to learn which
+// channels carry devices. Credentials in the endpoint URL are sent with
+// basic authentication.
+type kismetClient struct {
+	endpoint string
+	apiKey   string
+	window   time.Duration
+	client   *http.Client
+
+	mu       sync.Mutex
+	activity map[int]float64
+}
+
+func newKismetClient(endpoint string, apiKey string, window time.Duration) *kismetClient {
+	return &kismetClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   apiKey,
+		window:   window,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// devices returns the Wi-Fi devices seen by Kismet within the window.
+func (k *kismetClient) devices(ctx context.Context) ([]kismetDevice, error) {
+	fields, err := json.Marshal(map[string][]string{"fields": kismetFields})
+	if err != nil {
+		return nil, err
+	}
+
+	// Negative timestamps are relative to the current time
+	u := fmt.Sprintf("%s/devices/last-time/%d/devices.json", k.endpoint, -int64(k.window/time.Second))
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(url.Values{"json": {string(fields)}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if k.apiKey != "" {
+		req.AddCookie(&http.Cookie{Name: "KISMET", Value: k.apiKey})
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kismet: %v", resp.Status)
+	}
+
+	var devices []kismetDevice
+	if err := json.NewDecoder(resp.Body).Decode(&devices); err != nil {
+		return nil, fmt.Errorf("kismet: %v", err)
+	}
+
+	wifi := devices[:0]
+	for _, device := range devices {
+		if device.PHY == "IEEE802.11" {
+			wifi = append(wifi, device)
+		}
+	}
+	return wifi, nil
+}
+
+// kismetActivity scores each channel by the number of devices on it.
+func kismetActivity(devices []kismetDevice) map[int]float64 {
+	activity := make(map[int]float64)
+	for _, device := range devices {
+		// Kismet reports frequencies in kHz
+		if channel := plan.ChannelOf(int(device.Frequency / 1000)); channel != 0 {
+			activity[channel]++
+		}
+	}
+
+	return activity
+}
+
+// update fetches the device list and updates the channel activity.
+func (k *kismetClient) update(ctx context.Context) error {
+	devices, err := k.devices(ctx)
+	if err != nil {
+		return err
+	}
+
+	activity := kismetActivity(devices)
+	k.mu.Lock()
+	k.activity = activity
+	k.mu.Unlock()
+	return nil
+}
+
+// run updates the channel activity every interval until ctx is done, so
+// that hopping never waits for Kismet.
+func (k *kismetClient) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.update(ctx); err != nil && ctx.Err() == nil {
+				_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+			}
+		}
+	}
+}
+
+// Activity returns the last channel activity, see hopper.Scored.
+func (k *kismetClient) Activity() (map[int]float64, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.activity, nil
+}
@@ -0,0 +1,77 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKismetClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/devices/last-time/-300/devices.json" {
+			http.NotFound(w, r)
+			return
+		}
+		if cookie, err := r.Cookie("KISMET"); err != nil || cookie.Value != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var fields map[string][]string
+		if err := json.Unmarshal([]byte(r.FormValue("json")), &fields); err != nil || !reflect.DeepEqual(kismetFields, fields["fields"]) {
+			http.Error(w, "bad fields", http.StatusBadRequest)
+			return
+		}
+
+		_, _ = w.Write([]byte(`[
+			{"kismet.device.base.phyname": "IEEE802.11", "kismet.device.base.frequency": 2437000},
+			{"kismet.device.base.phyname": "IEEE802.11", "kismet.device.base.frequency": 2437000},
+			{"kismet.device.base.phyname": "IEEE802.11", "kismet.device.base.frequency": 5180000},
+			{"kismet.device.base.phyname": "IEEE802.11", "kismet.device.base.frequency": 0},
+			{"kismet.device.base.phyname": "Bluetooth", "kismet.device.base.frequency": 2402000}
+		]`))
+	}))
+	defer server.Close()
+
+	k := newKismetClient(server.URL+"/", "secret", 5*time.Minute)
+	if err := k.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	activity, err := k.Activity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{6: 2, 36: 1}; !reflect.DeepEqual(want, activity) {
+		t.Fatalf("Activity:\n- want: %v\n-  got: %v", want, activity)
+	}
+
+	k.apiKey = "wrong"
+	if err := k.update(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("update with a wrong API key:\n- want: 401 error\n-  got: %v", err)
+	}
+}
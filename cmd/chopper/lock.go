@@ -0,0 +1,57 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockDir holds the per-interface lock files.
+var lockDir = "/run"
+
+var errInterfaceLocked = errors.New("another chopper instance is using this interface")
+
+// lockInterface takes an advisory lock on the interface so that two
+// instances do not fight over its channel. The lock is released when the
+// returned file is closed or the process exits.
+func lockInterface(name string) (*os.File, error) {
+	path := filepath.Join(lockDir, fmt.Sprintf("%s-%s.lock", ProgramName, name))
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	busy, err := tryLock(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if busy {
+		_ = f.Close()
+		return nil, fmt.Errorf("%v: %w (%v)", name, errInterfaceLocked, path)
+	}
+
+	// Record the owner, to help finding it
+	_ = f.Truncate(0)
+	_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+
+	return f, nil
+}
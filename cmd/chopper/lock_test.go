@@ -0,0 +1,57 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLockInterface(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chopper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(dir string) { lockDir = dir }(lockDir)
+	lockDir = dir
+
+	first, err := lockInterface("wlan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lockInterface("wlan0"); !errors.Is(err, errInterfaceLocked) {
+		t.Fatalf("second lockInterface(wlan0):\n- want: %v\n-  got: %v", errInterfaceLocked, err)
+	}
+
+	other, err := lockInterface("wlan1")
+	if err != nil {
+		t.Fatalf("lockInterface(wlan1): %v", err)
+	}
+	_ = other.Close()
+
+	_ = first.Close()
+	again, err := lockInterface("wlan0")
+	if err != nil {
+		t.Fatalf("lockInterface(wlan0) after release: %v", err)
+	}
+	_ = again.Close()
+}
@@ -0,0 +1,1411 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/influx"
+	"github.com/giacomoferretti/chopper-go/pkg/logrotate"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	"github.com/giacomoferretti/chopper-go/pkg/session"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	showHelp       bool
+	showVersion    bool
+	versionJSON    bool
+	interfaceName  string
+	channelsString string
+	delay          int
+	minDelay       int
+	ignoreMinDelay bool
+	timeout        int
+	scanMode       bool
+	schedScan      bool
+	schedInterval  int
+	strategy       string
+	rerankCycles   int
+	runSelfTest    bool
+	outputFormat   string
+	logFile        string
+	logMaxSize     int
+	logMaxAge      time.Duration
+	logMaxFiles    int
+	otlpEndpoint   string
+	otlpInterval   time.Duration
+	httpAddr       string
+	rpcSocket      string
+	healthHops     int
+	force          bool
+	strict         bool
+	verifyHops     bool
+	assertChannel  bool
+	assertInterval time.Duration
+	compensate     bool
+	maxGap         time.Duration
+	dwellMode      string
+	jitter         float64
+	forceVisits    bool
+	startChannel   string
+	interleave     bool
+	planName       string
+	planFile       string
+	planFormat     string
+	dryRun         bool
+	delayStep      int
+	noAck          bool
+	asyncAck       bool
+	txPowerString  string
+	antennaString  string
+	createMonitor  string
+	monitorFlags   string
+	phyName        string
+	targetsFile    string
+	scheduleFile   string
+	targetsMode    string
+	kismetURL      string
+	kismetAPIKey   string
+	kismetWindow   time.Duration
+	kismetInterval time.Duration
+	bettercapURL   string
+	bettercapLock  time.Duration
+	execOnHop      string
+	execBeforeHop  string
+	execTimeout    time.Duration
+	execBlock      bool
+	webhookURL     string
+	webhookEvents  string
+	webhookBatch   int
+	webhookFlush   time.Duration
+	colorMode      string
+	warnInterval   time.Duration
+	dbFile         string
+	dbSurvey       time.Duration
+	influxURL      string
+	influxToken    string
+	influxInterval time.Duration
+	agentMode      bool
+	arbiterMode    bool
+	sliceMax       time.Duration
+	sliceQueue     int
+	listenAddr     string
+	tlsCert        string
+	tlsKey         string
+	tlsClientCA    string
+	apiTokenValue  string
+	apiTokenFile   string
+	pcapDirectory  string
+	pcapPath       string
+	eapolLock      time.Duration
+	pmkidLock      time.Duration
+	holdPath       string
+	holdTimeout    time.Duration
+	pmkidFile      string
+	deauthRate     float64
+	deauthWindow   time.Duration
+	inventoryTable bool
+	qbss           bool
+	minRSSI        int
+	inventoryFile  string
+	dossierFile    string
+	geofenceFile   string
+	gpsdAddr       string
+	fenceChannels  string
+	throttling     bool
+	batteryLow     int
+	batteryCrit    int
+	tempHigh       float64
+	tempCrit       float64
+	throttleFactor float64
+	ouiFile        string
+	spectralTable  bool
+	spectralFile   string
+	quirksString   string
+	nexmonFlag     string
+	rxStats        bool
+	escalateRate   float64
+	escalateMax    time.Duration
+	focusTop       int
+	sweepDelay     int
+	sweepEvery     int
+	seed           int64
+	netlinkBuffer  int
+	netlinkStrict  bool
+)
+
+const (
+	ProgramName = "chopper"
+	Version     = "1.0.0"
+
+	// DefaultMinDelay is the smallest dwell time (in ms) allowed without
+	// --i-know-what-im-doing. Many drivers become unstable below this.
+	DefaultMinDelay = 50
+)
+
+// clampDelay returns the delay to use given the requested one and the
+// configured floor. The second return value reports whether it was clamped.
+func clampDelay(delay int, floor int, force bool) (int, bool) {
+	if force || delay >= floor {
+		return delay, false
+	}
+
+	return floor, true
+}
+
+func isFlagPassed(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// interruptContext returns a context that is cancelled on SIGINT.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// parseChannels parses the channels flag, falling back to def. def is also
+// the set of channels standing for "rest" in plans with multipliers.
+func parseChannels(input string, def []int) []int {
+	var channels []int
+	var err error
+	if plan.HasMultipliers(input) {
+		channels, err = plan.ParseMultipliers(input, def)
+	} else {
+		channels, err = plan.Parse(input)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+	}
+	if len(channels) <= 0 {
+		return def
+	}
+
+	return channels
+}
+
+// asyncTuner retunes without blocking on the kernel reply, see
+// hopper.AsyncTuner.
+type asyncTuner struct {
+	client  *nl80211util.Client
+	ifindex int
+}
+
+func (t asyncTuner) SetChannel(channel int) error {
+	return t.client.SetFrequencyKHz(t.ifindex, plan.FrequencyKHz(channel))
+}
+
+func (t asyncTuner) SetChannelAsync(channel int) (<-chan error, error) {
+	return t.client.SetFrequencyKHzAsync(t.ifindex, plan.FrequencyKHz(channel))
+}
+
+// startRotation rotates the plan to start at the given channel or, if start
+// is "random", at a random position.
+func startRotation(channels []int, start string, rng *rand.Rand) ([]int, error) {
+	switch start {
+	case "":
+		return channels, nil
+	case "random":
+		return plan.Rotate(channels, rng.Intn(len(channels))), nil
+	}
+
+	channel, err := plan.ParseChannel(start)
+	if err != nil || channel == 0 {
+		return nil, fmt.Errorf("invalid start channel %v", start)
+	}
+	return plan.StartAt(channels, channel)
+}
+
+func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "discover":
+			ctx, stop := interruptContext()
+			code := runDiscover(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		case "recommend":
+			ctx, stop := interruptContext()
+			code := runRecommend(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "controller":
+			ctx, stop := interruptContext()
+			code := runController(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		case "multi":
+			ctx, stop := interruptContext()
+			code := runMulti(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		case "rpcd":
+			os.Exit(runRPCD(os.Args[2:]))
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "simulate":
+			ctx, stop := interruptContext()
+			code := runSimulate(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		case "watch":
+			ctx, stop := interruptContext()
+			code := runWatch(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		case "agent":
+			// The agent hops like chopper does, with the API over TLS
+			agentMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "arbiter":
+			// The arbiter hops like chopper does, lending the radio to the
+			// clients of its API
+			arbiterMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+
+	// Command arguments
+	flag.BoolVarP(&showHelp, "help", "h", false, "show this help message")
+	flag.BoolVarP(&showVersion, "version", "V", false, "show version")
+	flag.BoolVar(&versionJSON, "json", false, "with --version, print the build information as JSON (with the device of -i, if given)")
+	flag.StringVarP(&interfaceName, "interface", "i", "", "interface name (must be in monitor mode)")
+	flag.StringVarP(&channelsString, "channels", "c", "", "comma-separated list of channels, 1x3 visits 1 three times per cycle and rest adds the other channels (default: 1,8,2,9,3,10,4,11,5,12,6,13,7)")
+	flag.IntVarP(&delay, "delay", "d", 100, "delay between each hop")
+	flag.IntVar(&minDelay, "min-delay", DefaultMinDelay, "minimum allowed delay between each hop")
+	flag.BoolVar(&ignoreMinDelay, "i-know-what-im-doing", false, "allow delays below --min-delay")
+	flag.IntVarP(&timeout, "timeout", "t", 0, "exit the program after X seconds")
+	flag.BoolVar(&scanMode, "scan", false, "offload hopping to hardware scans and print discovered networks")
+	flag.BoolVar(&schedScan, "sched-scan", false, "let the firmware scan in the background and print discovered networks")
+	flag.IntVar(&schedInterval, "sched-interval", 10000, "interval between scheduled scans in ms")
+	flag.StringVar(&strategy, "strategy", "sequential", "hopping strategy: sequential, shuffle (a random order every cycle), ranked (busy channels first), quietest (idle, low-noise channels first), escalate (longer dwells on channels with traffic), focus (fast sweeps, then the busiest channels) or kismet (channels with devices seen by Kismet first)")
+	flag.Float64Var(&escalateRate, "escalate-threshold", 20, "packets per second after which the escalate strategy doubles the dwell on a channel")
+	flag.DurationVar(&escalateMax, "escalate-max", 2*time.Second, "longest dwell of the escalate strategy")
+	flag.IntVar(&focusTop, "focus-top", 3, "number of busiest channels the focus strategy rotates over")
+	flag.IntVar(&sweepDelay, "sweep-delay", DefaultMinDelay, "delay between each hop during the sweeps of the focus strategy")
+	flag.IntVar(&sweepEvery, "sweep-every", 10, "focused cycles between the sweeps of the focus strategy")
+	flag.Int64Var(&seed, "seed", 0, "seed of --strategy shuffle and --start-channel random, to repeat the same orders across runs and sensors (0 picks a new one)")
+	flag.IntVar(&rerankCycles, "rerank", 5, "re-rank channels every X cycles when using the ranked, quietest or kismet strategy")
+	flag.BoolVar(&runSelfTest, "self-test", false, "verify during the first cycle that frames are received on active channels")
+	flag.StringVarP(&outputFormat, "output", "o", "text", "output format: text or json (NDJSON events on stdout)")
+	flag.StringVar(&logFile, "log-file", "", "append hop events (NDJSON) to this file")
+	flag.IntVar(&logMaxSize, "log-max-size", 0, "rotate the log file after X MB (0 disables)")
+	flag.DurationVar(&logMaxAge, "log-max-age", 0, "rotate the log file after this long, e.g. 24h (0 disables)")
+	flag.IntVar(&logMaxFiles, "log-max-files", 5, "number of rotated log files to keep (0 keeps all)")
+	flag.BoolVar(&force, "force", false, "start even if other processes may manage the interface")
+	flag.StringVar(&startChannel, "start-channel", "", "start the rotation at this channel, or at a random one with \"random\"")
+	flag.BoolVar(&compensate, "compensate-latency", false, "count the retune in the delay, so every channel takes the same time per cycle")
+	flag.StringVar(&dwellMode, "dwell", "fixed", "time spent on each channel: fixed (the delay), jitter (the delay changed randomly by up to --jitter) or traffic (proportional to the packets of the last visit)")
+	flag.Float64Var(&jitter, "jitter", 0.2, "largest change of the delay with --dwell jitter, as a fraction of it")
+	flag.DurationVar(&maxGap, "max-gap", 0, "warn when a channel of the plan is not visited for longer than this, e.g. 5s (0 disables)")
+	flag.BoolVar(&forceVisits, "force-visits", false, "visit a channel at the next hop once it exceeds --max-gap")
+	flag.BoolVar(&assertChannel, "assert", false, "set the channel again when another process changes it during the delay")
+	flag.DurationVar(&assertInterval, "assert-interval", 50*time.Millisecond, "how often --assert checks the channel")
+	flag.BoolVar(&verifyHops, "verify", false, "check the frequency of the radio after every retune, measuring the time until it is verified")
+	flag.BoolVar(&strict, "strict", false, "abort if a channel is invalid or unsupported by the radio instead of skipping it")
+	flag.BoolVar(&interleave, "interleave", false, "alternate 2.4 GHz and 5 GHz channels so neither band goes dark for long")
+	flag.StringVar(&planName, "plan", "", "use a bundled channel plan: "+strings.Join(plan.Names(), ", "))
+	flag.StringVar(&planFile, "plan-file", "", "hop the plan of this file (JSON or YAML, as printed by --dry-run) with its channels, dwells, strategy and seed")
+	flag.BoolVar(&dryRun, "dry-run", false, "print the plan that would be hopped and exit, without opening the radio")
+	flag.StringVar(&planFormat, "plan-format", "json", "format of the plan printed by --dry-run: "+strings.Join(plan.FileFormats, " or "))
+	flag.IntVar(&delayStep, "delay-step", 10, "change the delay by X ms on SIGUSR1 (increase) and SIGUSR2 (decrease)")
+	flag.BoolVar(&noAck, "no-ack", false, "do not wait for the kernel to confirm channel changes (lowest latency, errors are not reported)")
+	flag.IntVar(&netlinkBuffer, "netlink-rcvbuf", 0, "receive buffer of the netlink sockets in bytes, capped by net.core.rmem_max (0 keeps the kernel default)")
+	flag.BoolVar(&netlinkStrict, "netlink-strict", false, "enable strict checking of netlink requests by the kernel (NETLINK_GET_STRICT_CHK, Linux 4.20+)")
+	flag.BoolVar(&asyncAck, "async-ack", false, "start dwelling as soon as a channel change is sent, waiting for the kernel reply meanwhile")
+	flag.StringVar(&txPowerString, "txpower", "", "set the TX power after every hop: auto, fixed:<dBm>, limit:<dBm> or <dBm>, optionally per channel, e.g. 5,36=limit:10")
+	flag.StringVar(&antennaString, "antenna", "", "select the antennas to use as bitmasks, e.g. rx=0x1,tx=0x1")
+	flag.StringVar(&createMonitor, "create-monitor", "", "create a monitor interface with this name on the radio of --interface and hop on it, removing it on exit")
+	flag.StringVar(&monitorFlags, "monitor-flags", "", "flags of the interface created by --create-monitor: otherbss, control, fcsfail, plcpfail, cook, active")
+	flag.StringVar(&phyName, "phy", "", "hop on this radio, e.g. phy0, using its monitor interface or creating one")
+	flag.StringVar(&geofenceFile, "geofence", "", "only hop on the fenced channels while the gpsd position is inside the polygon of this file, a latitude,longitude vertex per line")
+	flag.StringVar(&gpsdAddr, "gpsd", "localhost:2947", "gpsd address used by --geofence")
+	flag.StringVar(&fenceChannels, "fence-channels", "all", "channels hopped only inside the geofence: all (idle outside), or a comma-separated list of dfs, 2.4, 5 and 6")
+	flag.BoolVar(&throttling, "throttle", false, "dwell longer on low battery or high temperature, and idle when they are critical")
+	flag.IntVar(&batteryLow, "battery-low", 20, "battery percent below which --throttle dwells longer, 0 to disable")
+	flag.IntVar(&batteryCrit, "battery-critical", 5, "battery percent below which --throttle idles, 0 to disable")
+	flag.Float64Var(&tempHigh, "temp-high", 75, "temperature in °C above which --throttle dwells longer, 0 to disable")
+	flag.Float64Var(&tempCrit, "temp-critical", 90, "temperature in °C above which --throttle idles, 0 to disable")
+	flag.Float64Var(&throttleFactor, "throttle-factor", 4, "how many times longer --throttle dwells on low battery or high temperature")
+	flag.StringVar(&scheduleFile, "schedule", "", "switch plans at the times of this crontab-like file, reloaded when it changes, using -c or --plan until an entry fires")
+	flag.StringVar(&targetsFile, "targets", "", "file listing BSSIDs and SSIDs of interest, reloaded when it changes")
+	flag.StringVar(&targetsMode, "targets-mode", "bias", "what to do when targets are seen: bias (visit their channels more often) or lock (only hop on their channels)")
+	flag.StringVar(&execOnHop, "exec-on-hop", "", "run this shell command after every hop, with CHOPPER_CHANNEL, CHOPPER_FREQ and CHOPPER_WIDTH set")
+	flag.StringVar(&execBeforeHop, "exec-before-hop", "", "like --exec-on-hop, but run before changing channel, with CHOPPER_CHANNEL set to the next channel")
+	flag.DurationVar(&execTimeout, "exec-timeout", 5*time.Second, "kill hook commands running for longer than this (0 disables)")
+	flag.BoolVar(&execBlock, "exec-block", false, "wait for hook commands before dwelling instead of running them in the background")
+	flag.StringVar(&dbFile, "db", "", "store hops, errors, discovered networks and survey snapshots in this SQLite database")
+	flag.DurationVar(&dbSurvey, "db-survey-interval", 10*time.Second, "interval between survey snapshots stored with --db (0 disables)")
+	flag.StringVar(&influxURL, "influx-url", "", "write hop and survey measurements in InfluxDB line protocol to a file, udp://host:port or an HTTP write URL, e.g. http://localhost:8086/api/v2/write?org=o&bucket=b")
+	flag.StringVar(&influxToken, "influx-token", os.Getenv("INFLUX_TOKEN"), "InfluxDB API token")
+	flag.DurationVar(&influxInterval, "influx-interval", 10*time.Second, "interval between InfluxDB writes and survey measurements")
+	flag.StringVar(&pcapDirectory, "pcap-dir", "", "capture frames to a pcapng file per channel in this directory")
+	flag.StringVar(&pcapPath, "pcap-file", "", "capture frames to this pcapng file, with an interface per channel")
+	flag.DurationVar(&eapolLock, "eapol-lock", 0, "lock the channel when the capture sees a WPA handshake start, until it is complete or no message is seen for this long")
+	flag.DurationVar(&pmkidLock, "pmkid-lock", 0, "lock the channel for this long when the capture sees a PMKID")
+	flag.StringVar(&holdPath, "hold-file", "", "hold the channel while a capture tool writes HOLD [channel] to this FIFO or file, until it writes RELEASE; a FIFO is created if it does not exist")
+	flag.DurationVar(&holdTimeout, "hold-timeout", 5*time.Minute, "resume hopping when no RELEASE follows a HOLD for this long (0 waits forever)")
+	flag.StringVar(&pmkidFile, "pmkid-file", "", "append the PMKIDs seen by the capture to this file, in hashcat 22000 format")
+	flag.Float64Var(&deauthRate, "deauth-threshold", 0, "alert when deauthentication and disassociation frames on a channel reach this many per second")
+	flag.DurationVar(&deauthWindow, "deauth-window", 5*time.Second, "time spent on a channel over which --deauth-threshold is measured")
+	flag.BoolVar(&qbss, "qbss", false, "collect the channel utilization and station counts that access points advertise in the BSS Load element of their beacons, print them at exit and rank channels with them in the ranked, quietest and focus strategies")
+	flag.IntVar(&minRSSI, "min-rssi", 0, "ignore the beacons received below this signal in dBm, e.g. -75, in --targets, the inventory and --qbss (0 accepts all)")
+	flag.BoolVar(&inventoryTable, "inventory", false, "print the networks whose beacons were captured at exit")
+	flag.StringVar(&ouiFile, "oui-file", "", "Wireshark manuf or IEEE oui.txt file naming the vendors of addresses, in addition to the bundled ones")
+	flag.StringVar(&inventoryFile, "inventory-file", "", "write the networks whose beacons were captured to this file at exit, as CSV if it ends in .csv or JSON")
+	flag.StringVar(&dossierFile, "dossier-file", "", "write every channel each network (each target with --targets) was seen on, with timestamps and signal, to this file at exit, as CSV if it ends in .csv or JSON")
+	flag.BoolVar(&spectralTable, "spectral", false, "run the spectral scan of ath9k and ath10k radios, emit spectral events per dwell and print a summary per channel at exit")
+	flag.StringVar(&spectralFile, "spectral-file", "", "run the spectral scan and write its raw FFT samples to this file")
+	flag.StringVar(&quirksString, "quirks", "auto", "driver workarounds: auto (known quirks of the driver), none, updown (interface down and up around retunes) and min-delay=<ms>, applied in order")
+	flag.StringVar(&nexmonFlag, "nexmon", "auto", "set channels with nexutil for the nexmon firmware of brcmfmac radios: auto (when detected), on or off")
+	flag.BoolVar(&rxStats, "rx-stats", false, "count the packets and bytes received on each channel from the interface statistics and print them at exit")
+	registerHTTPFlags()
+	flag.StringVar(&rpcSocket, "rpc-socket", "", "serve a JSON-RPC API on this unix socket, for chopper rpcd (OpenWrt ubus), e.g. "+defaultRPCSocket)
+	flag.DurationVar(&warnInterval, "warn-interval", 30*time.Second, "print a repeated warning once per interval with its count (0 prints every one)")
+	flag.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	flag.Parse()
+
+	if showHelp {
+		flag.Usage()
+		os.Exit(0)
+	} else if showVersion {
+		info := currentBuild()
+		if interfaceName != "" {
+			device := describeDevice(interfaceName)
+			info.Device = &device
+		}
+		if err := printVersion(os.Stdout, info, versionJSON); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	warnings.interval = warnInterval
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// Check arguments
+	if interfaceName == "" && phyName == "" && !dryRun {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if interfaceName != "" && phyName != "" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --interface and --phy cannot be used together\n")
+		os.Exit(1)
+	}
+	apiOptions, err := checkAPIFlags()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if !validPlanFormat(planFormat) {
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown plan format %v, expected one of %v\n", planFormat, strings.Join(plan.FileFormats, ", "))
+		os.Exit(1)
+	}
+	var resolved *plan.Resolved
+	if planFile != "" {
+		if name := planFileConflict(); name != "" {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --%v cannot be used with --plan-file, the plan file decides it\n", name)
+			os.Exit(1)
+		}
+		if resolved, err = loadPlanFile(planFile); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot load plan file: %v\n", err)
+			os.Exit(1)
+		}
+		if shortest := shortestDwell(resolved); shortest < time.Duration(minDelay)*time.Millisecond && !ignoreMinDelay {
+			_, _ = fmt.Fprintf(stderr, "ERROR: the plan file dwells %v, below the minimum of %vms. Pass --i-know-what-im-doing to override.\n", shortest, minDelay)
+			os.Exit(1)
+		}
+		if resolved.Strategy != "" {
+			strategy = resolved.Strategy
+		}
+		seed = resolved.Seed
+		delay = int(resolved.Hops[0].Dwell.Milliseconds())
+	}
+	if clamped, ok := clampDelay(delay, minDelay, ignoreMinDelay); ok {
+		_, _ = fmt.Fprintf(stderr, "WARNING: delay %vms is below the minimum of %vms, using %vms.\n", delay, minDelay, clamped)
+		_, _ = fmt.Fprintf(stderr, "WARNING: pass --i-know-what-im-doing to override.\n")
+		delay = clamped
+	} else if isFlagPassed("delay") && delay < 10 {
+		_, _ = fmt.Fprintf(stderr, "WARNING: the delay is very small, why are you doing this?\n")
+	}
+	if isFlagPassed("timeout") {
+		if timeout <= 0 {
+			_, _ = fmt.Fprintf(stderr, "WARNING: timeout cannot be 0, running until SIGINT.\n")
+		} else {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+			defer cancel()
+		}
+	}
+	if strategy != "sequential" && strategy != "shuffle" && strategy != "ranked" && strategy != "quietest" && strategy != "escalate" && strategy != "focus" && strategy != "kismet" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown strategy %v\n", strategy)
+		os.Exit(1)
+	}
+	if dwellMode != "fixed" && (strategy == "escalate" || strategy == "focus") {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --strategy %v decides the dwell, it cannot be used with --dwell %v\n", strategy, dwellMode)
+		os.Exit(1)
+	}
+	if strategy == "focus" {
+		if focusTop < 1 || sweepEvery < 1 {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --focus-top and --sweep-every must be at least 1\n")
+			os.Exit(1)
+		}
+		if clamped, ok := clampDelay(sweepDelay, minDelay, ignoreMinDelay); ok {
+			_, _ = fmt.Fprintf(stderr, "WARNING: sweep delay %vms is below the minimum of %vms, using %vms.\n", sweepDelay, minDelay, clamped)
+			sweepDelay = clamped
+		}
+	}
+	var fence *geofence
+	if geofenceFile != "" {
+		if scheduleFile != "" || targetsFile != "" {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --geofence cannot be used with --schedule or --targets\n")
+			os.Exit(1)
+		}
+		fenced, err := parseFenceChannels(fenceChannels)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		f, err := os.Open(geofenceFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot read geofence: %v\n", err)
+			os.Exit(1)
+		}
+		area, err := parsePolygon(f)
+		_ = f.Close()
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: geofence: %v\n", err)
+			os.Exit(1)
+		}
+		fence = &geofence{polygon: area, fenced: fenced}
+	}
+	var power *throttle
+	if throttling {
+		power = &throttle{
+			batteryLow:      batteryLow,
+			batteryCritical: batteryCrit,
+			tempHigh:        tempHigh,
+			tempCritical:    tempCrit,
+			factor:          throttleFactor,
+		}
+		if err := power.check(); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if minRSSI > 0 {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --min-rssi is in dBm and must be negative, e.g. -75\n")
+		os.Exit(1)
+	}
+	if pcapDirectory != "" && pcapPath != "" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --pcap-dir and --pcap-file cannot be used together\n")
+		os.Exit(1)
+	}
+	if ouiFile != "" {
+		if err := loadVendors(ouiFile); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot load --oui-file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if targetsMode != "bias" && targetsMode != "lock" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown targets mode %v\n", targetsMode)
+		os.Exit(1)
+	}
+	switch outputFormat {
+	case "text":
+	case "json":
+		jsonStdout = true
+		eventSinks = append(eventSinks, events.NewEncoder(os.Stdout))
+	default:
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown output format %v\n", outputFormat)
+		os.Exit(1)
+	}
+	if logFile != "" {
+		writer, err := logrotate.Open(logFile, logrotate.Options{
+			MaxSize:    int64(logMaxSize) * 1024 * 1024,
+			MaxAge:     logMaxAge,
+			MaxBackups: logMaxFiles,
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot open log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer writer.Close()
+
+		eventSinks = append(eventSinks, events.NewEncoder(writer))
+	}
+	var db *session.DB
+	if dbFile != "" {
+		var err error
+		db, err = session.Open(dbFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot open database: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		eventSinks = append(eventSinks, db)
+	}
+	var influxWriter *influx.Writer
+	stopInflux := func() {}
+	if influxURL != "" {
+		var err error
+		influxWriter, stopInflux, err = startInflux(influxURL, influxToken, influxInterval)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot open InfluxDB output: %v\n", err)
+			os.Exit(1)
+		}
+		defer stopInflux()
+
+		eventSinks = append(eventSinks, influxSink{influxWriter})
+	}
+	var apiEvents *eventStream
+	if httpAddr != "" {
+		apiEvents = newEventStream()
+		eventSinks = append(eventSinks, apiEvents)
+	}
+	stopWebhook := func() {}
+	if webhookURL != "" {
+		var err error
+		stopWebhook, err = startWebhook(webhookURL, webhookEvents, webhookBatch, webhookFlush)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		defer stopWebhook()
+	}
+	if rerankCycles <= 0 {
+		rerankCycles = 1
+	}
+	var channels []int
+	if strict {
+		var err error
+		if channels, err = strictChannels(channelsString, plan.Default()); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		channels = parseChannels(channelsString, plan.Default())
+	}
+	if resolved != nil {
+		channels = resolved.Channels()
+	}
+	if planName != "" {
+		if channelsString != "" {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --plan and --channels cannot be used together\n")
+			os.Exit(1)
+		}
+
+		named, err := plan.Named(planName)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		channels = named
+	}
+	var sched *scheduleWatcher
+	if scheduleFile != "" {
+		if targetsFile != "" {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --schedule and --targets cannot be used together\n")
+			os.Exit(1)
+		}
+
+		var err error
+		if sched, err = newScheduleWatcher(scheduleFile, time.Now()); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot load schedule: %v\n", err)
+			os.Exit(1)
+		}
+		if e := sched.entries.active(time.Now()); e != nil {
+			channels = e.channels
+			_, _ = fmt.Fprintf(stderr, "Schedule: hopping on %v (%v)\n", plan.Format(channels), e.spec)
+		}
+	}
+	if interleave {
+		channels = plan.Interleave(channels)
+	}
+	var txPower txPowerPlan
+	if txPowerString != "" {
+		var err error
+		if txPower, err = parseTxPower(txPowerString); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var antennas antennaSelection
+	if antennaString != "" {
+		var err error
+		if antennas, err = parseAntenna(antennaString); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	channels, err = startRotation(channels, startChannel, rng)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if dryRun {
+		// Unsupported channels are only dropped once the radio is open
+		if err := dryRunPlan(resolved, channels, delay, strategy, seed).Encode(os.Stdout, planFormat); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Connect to nl80211
+	if netlinkBuffer < 0 {
+		_, _ = fmt.Fprintf(stderr, "ERROR: invalid netlink receive buffer %v\n", netlinkBuffer)
+		os.Exit(1)
+	}
+	client, err := nl80211util.DialOptions(nl80211util.SocketOptions{
+		ReadBuffer:  netlinkBuffer,
+		StrictCheck: netlinkStrict,
+	})
+	if errors.Is(err, nl80211util.ErrUnsupported) {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v, %v simulate hops without a radio\n", err, ProgramName)
+		os.Exit(1)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	// Check interface
+	iface, closeInterface, err := openInterface(client)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeInterface()
+	// Events report the interface actually hopping
+	interfaceName = iface.Name
+	// os.Exit skips deferred calls
+	exit := func(code int) {
+		warnings.flush(stderr)
+		closeInterface()
+		os.Exit(code)
+	}
+
+	lock, err := lockInterface(iface.Name)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		exit(1)
+	}
+	defer lock.Close()
+	if !checkManagers(iface.Name, iface.PHY, force) {
+		exit(1)
+	}
+	if !checkPhyInterfaces(client, iface, force) {
+		exit(1)
+	}
+	// checkChannels, if set, checks the plans set while hopping
+	var checkChannels func(channels []int) ([]int, error)
+	if frequencies, err := client.WiphyFrequencies(iface.PHY); err != nil {
+		if strict {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot check the plan: %v\n", err)
+			exit(1)
+		}
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot check the plan: %v\n", err)
+	} else {
+		check := func(channels []int) ([]int, error) {
+			return checkPlan(channels, frequencies, strict)
+		}
+		if channels, err = check(channels); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			exit(1)
+		}
+		checkChannels = check
+		if sched != nil {
+			if err := sched.setCheck(check); err != nil {
+				_, _ = fmt.Fprintf(stderr, "ERROR: schedule: %v\n", err)
+				exit(1)
+			}
+		}
+	}
+	device := describeDevice(iface.Name)
+	driver := device.Driver
+	if driver == "" {
+		driver = "unknown"
+	}
+	driverFixes, err := parseQuirks(quirksString, driver)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		exit(1)
+	}
+	nexmon, err := nexmonMode(nexmonFlag, device.Driver, device.Firmware)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		exit(1)
+	}
+	if nexmon {
+		if noAck || asyncAck {
+			_, _ = fmt.Fprintf(stderr, "ERROR: the nexmon mode cannot be used with --no-ack or --async-ack, pass --nexmon off to disable it\n")
+			exit(1)
+		}
+		// nexutil replaces the down and up of the stock firmware
+		driverFixes.UpDown = false
+		if driverFixes.MinDelay < nexmonDelay {
+			driverFixes.MinDelay = nexmonDelay
+		}
+		_, _ = fmt.Fprintf(stderr, "Using the nexmon mode, channels are set with %v\n", nexutil)
+	}
+	if driverFixes.UpDown && asyncAck {
+		_, _ = fmt.Fprintf(stderr, "ERROR: the updown quirk cannot be used with --async-ack, pass --quirks no-updown to disable it\n")
+		exit(1)
+	}
+	if driverFixes != (quirks{}) {
+		_, _ = fmt.Fprintf(stderr, "Driver %v: applying quirks %v\n", driver, driverFixes)
+	}
+	if driverFixes.MinDelay > minDelay && !isFlagPassed("min-delay") {
+		minDelay = driverFixes.MinDelay
+		if clamped, ok := clampDelay(delay, minDelay, ignoreMinDelay); ok {
+			_, _ = fmt.Fprintf(stderr, "WARNING: delay %vms is below the minimum of %vms for %v, using %vms.\n", delay, minDelay, driver, clamped)
+			delay = clamped
+		}
+	}
+	if antennaString != "" {
+		antenna, err := selectAntennas(client, iface.PHY, antennas)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			exit(1)
+		}
+		_, _ = fmt.Fprintf(stderr, "Using antennas %v\n", antenna)
+	}
+
+	if schedScan {
+		if err := runSchedScanMode(ctx, client, iface.Index, channels, time.Duration(schedInterval)*time.Millisecond); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			exit(1)
+		}
+		return
+	} else if scanMode {
+		if err := runScanMode(ctx, client, iface.Index, channels, time.Duration(delay)*time.Millisecond); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	var beforeHop []func(channel int)
+	var onHop []func(channel int)
+	var onCycle []func(cycle int) error
+	if emitting() {
+		onHop = append(onHop, emitHop)
+		onCycle = append(onCycle, func(cycle int) error {
+			emitCycle(cycle)
+			return nil
+		})
+	}
+
+	config := hopper.Config{
+		Channels: channels,
+		Delay:    time.Duration(delay) * time.Millisecond,
+		BeforeHop: func(channel int) {
+			for _, fn := range beforeHop {
+				fn(channel)
+			}
+		},
+		OnHop: func(channel int) {
+			for _, fn := range onHop {
+				fn(channel)
+			}
+		},
+		OnCycle: func(cycle int) error {
+			for _, fn := range onCycle {
+				if err := fn(cycle); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		OnError: func(err error) {
+			warnf("%v", err)
+			emitError(err)
+		},
+		CompensateLatency: compensate,
+		MaxGap:            maxGap,
+		ForceVisits:       forceVisits,
+		OnStarved: func(channel int, gap time.Duration) {
+			warnf("channel %v was not visited for %v, more than --max-gap %v", plan.FormatChannel(channel), gap.Round(time.Millisecond), maxGap)
+			countStarved(channel)
+		},
+	}
+	if forceVisits && maxGap <= 0 {
+		_, _ = fmt.Fprintf(stderr, "WARNING: --force-visits has no effect without --max-gap.\n")
+	}
+	setFrequency := client.SetFrequencyKHz
+	if noAck {
+		_, _ = fmt.Fprintf(stderr, "WARNING: --no-ack is set, failures to change channel will not be reported.\n")
+		setFrequency = client.SetFrequencyKHzNoAck
+	}
+	var tuner hopper.Tuner = hopper.TunerFunc(func(channel int) error {
+		return setFrequency(iface.Index, plan.FrequencyKHz(channel))
+	})
+	if asyncAck {
+		if noAck {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --async-ack and --no-ack cannot be used together\n")
+			exit(1)
+		}
+		tuner = asyncTuner{client: client, ifindex: iface.Index}
+	}
+	if txPowerString != "" {
+		// Some drivers reset the TX power when retuning
+		onHop = append(onHop, func(channel int) {
+			power, ok := txPower.forChannel(channel)
+			if !ok {
+				return
+			}
+			if err := client.SetTxPower(iface.Index, power); err != nil {
+				config.OnError(fmt.Errorf("cannot set TX power to %v on channel %v: %v", power, channel, err))
+			}
+		})
+	}
+	survey := func() ([]nl80211util.SurveyInfo, error) {
+		return client.Survey(iface.Index)
+	}
+	stopTelemetry := func() {}
+	if otlpEndpoint != "" {
+		stopTelemetry = startTelemetry(context.Background(), otlpEndpoint, otlpInterval)
+		if !asyncAck {
+			// Hop latency is measured by the tuner, which async retunes bypass
+			tuner = tracedTuner(setFrequency, iface.Index)
+		}
+		survey = tracedSurvey(client, iface.Index)
+	}
+	if verifyHops {
+		frequency := func() (int, error) {
+			return client.InterfaceFrequency(iface.Index)
+		}
+		if freq, err := frequency(); err != nil || freq == 0 {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --verify: %v does not report its frequency\n", iface.Name)
+			exit(1)
+		}
+		config.Verify = verifyTuning(frequency)
+		if otlpEndpoint != "" {
+			config.Verify = tracedVerify(config.Verify, iface.Index)
+		}
+	}
+	if driverFixes.UpDown {
+		tuner = newUpDownTuner(tuner, client, iface.Index)
+	}
+	if nexmon {
+		tuner = nexmonTuner{command: nexutil, iface: iface.Name}
+	}
+	var asserter *channelAsserter
+	if assertChannel {
+		asserter = &channelAsserter{
+			frequency: func() (int, error) {
+				return client.InterfaceFrequency(iface.Index)
+			},
+			retune: func(channel int) error {
+				return client.SetFrequencyKHz(iface.Index, plan.FrequencyKHz(channel))
+			},
+		}
+		if nexmon {
+			asserter.retune = nexmonTuner{command: nexutil, iface: iface.Name}.SetChannel
+		}
+		if freq, err := asserter.frequency(); err != nil || freq == 0 {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --assert: %v does not report its frequency\n", iface.Name)
+			exit(1)
+		}
+		if assertInterval <= 0 {
+			_, _ = fmt.Fprintf(stderr, "ERROR: invalid --assert-interval %v\n", assertInterval)
+			exit(1)
+		}
+		beforeHop = append(beforeHop, asserter.beforeHop)
+		onHop = append(onHop, asserter.hop)
+		if config.Verify != nil {
+			config.Verify = asserter.verifying(config.Verify)
+		}
+
+		// Switches are announced, monitor retunes only show up when polled
+		var changes <-chan nl80211util.ChannelChange
+		if notifications, err := client.ChannelEvents(); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot subscribe to channel changes, polling only: %v\n", err)
+		} else {
+			defer notifications.Close()
+			changes = channelChanges(ctx, notifications)
+		}
+		go asserter.run(ctx, assertInterval, changes)
+	}
+	// Set once the hopper is created, resuming restarts its cycle
+	var h *hopper.Hopper
+	tuner = newSuspendTuner(tuner, newSuspendDetector(suspendedTime), func(slept time.Duration) error {
+		_, _ = fmt.Fprintf(stderr, "Resumed after %v asleep, reconnecting\n", slept.Round(time.Second))
+		if err := resumeRadio(client, iface, resumeTimeout); err != nil {
+			return err
+		}
+		h.Restart()
+		return nil
+	})
+	// Warn when retunes start eating into the dwell, not on every cycle
+	slowRetunes := false
+	onCycle = append(onCycle, func(cycle int) error {
+		msg, slow := checkLatency(h.Stats(), h.Delay())
+		if slow && !slowRetunes {
+			warnf("%v", msg)
+		}
+		slowRetunes = slow
+		return nil
+	})
+	stopSurveys := func() {}
+	if db != nil && dbSurvey > 0 {
+		stopSurveys = startSurveySnapshots(survey, dbSurvey, db.Survey)
+	}
+	stopInfluxSurveys := func() {}
+	if influxWriter != nil {
+		stopInfluxSurveys = startSurveySnapshots(survey, influxInterval, func(at time.Time, info []nl80211util.SurveyInfo) error {
+			return influxWriter.Write(surveyPoints(iface.Name, at, info)...)
+		})
+	}
+
+	var loads *bssLoadMonitor
+	if qbss {
+		loads = newBSSLoadMonitor(bssLoadWindow)
+		loads.minSignal = minSignal(minRSSI)
+		onHop = append(onHop, loads.hop)
+	}
+
+	if strategy == "shuffle" {
+		config.Strategy = hopper.Shuffled{Rand: rng}
+	}
+	if strategy == "ranked" && loads != nil {
+		config.Strategy = &hopper.Scored{
+			Activity: loads.busy,
+			Every:    rerankCycles,
+		}
+	} else if strategy == "ranked" {
+		config.Strategy = &hopper.Ranked{
+			Survey: survey,
+			Every:  rerankCycles,
+		}
+	}
+	if strategy == "quietest" && loads != nil {
+		config.Strategy = &hopper.Scored{
+			Activity: loads.quiet,
+			Every:    rerankCycles,
+		}
+	} else if strategy == "quietest" {
+		config.Strategy = &hopper.Quietest{
+			Survey: survey,
+			Every:  rerankCycles,
+		}
+	}
+	if strategy == "kismet" {
+		kismet := newKismetClient(kismetURL, kismetAPIKey, kismetWindow)
+		if err := kismet.update(ctx); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot query Kismet: %v\n", err)
+			exit(1)
+		}
+		go kismet.run(ctx, kismetInterval)
+
+		config.Strategy = &hopper.Scored{
+			Activity: kismet.Activity,
+			Every:    rerankCycles,
+		}
+	}
+
+	if runSelfTest {
+		test, err := newSelfTest(iface.Index)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot start self-test: %v\n", err)
+			exit(1)
+		}
+		defer test.Close()
+
+		onHop = append(onHop, test.setChannel)
+		onCycle = append(onCycle, func(cycle int) error {
+			// Check the self-test after the first cycle
+			if cycle != 1 {
+				return nil
+			}
+
+			err := test.report(channels)
+			_ = test.Close()
+			if err != nil {
+				return fmt.Errorf("self-test: %v", err)
+			}
+			return nil
+		})
+	}
+
+	if execBeforeHop != "" {
+		hook := &execHook{point: "before", command: execBeforeHop, timeout: execTimeout, block: execBlock}
+		beforeHop = append(beforeHop, hook.run)
+	}
+	if execOnHop != "" {
+		hook := &execHook{point: "after", command: execOnHop, timeout: execTimeout, block: execBlock}
+		onHop = append(onHop, hook.run)
+	}
+
+	var targets *targetWatcher
+	if targetsFile != "" {
+		targets, err = newTargetWatcher(targetsFile, targetsMode, iface.Index)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot watch targets: %v\n", err)
+			exit(1)
+		}
+		defer targets.Close()
+		targets.minSignal = minSignal(minRSSI)
+		_, _ = fmt.Fprintf(stderr, "Targets: loaded %v targets from %v\n", targets.targets.len(), targetsFile)
+
+		onHop = append(onHop, targets.setChannel)
+	}
+
+	var capture *channelCapture
+	var handshakes chan eapolFrame
+	var networks *inventory
+	var history *dossier
+	if pcapDirectory != "" || pcapPath != "" || eapolLock > 0 || pmkidLock > 0 || pmkidFile != "" || deauthRate > 0 || inventoryTable || inventoryFile != "" || qbss || dossierFile != "" {
+		var out channelWriter = discardFrames{}
+		var err error
+		if pcapDirectory != "" {
+			out, err = newPcapDir(pcapDirectory, iface.Name)
+		} else if pcapPath != "" {
+			out, err = newPcapMerged(pcapPath, iface.Name)
+		}
+		var hashes io.Writer
+		if err == nil && pmkidFile != "" {
+			var f *os.File
+			if f, err = os.OpenFile(pmkidFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err == nil {
+				defer f.Close()
+				hashes = f
+			}
+		}
+		if eapolLock > 0 || pmkidLock > 0 {
+			handshakes = make(chan eapolFrame, 16)
+		}
+		observers := []frameObserver{observeCapture(handshakes, eapolLock > 0, func(event events.Event) {
+			reportPMKID(event, hashes)
+		})}
+		var deauth *deauthMonitor
+		if deauthRate > 0 {
+			deauth = newDeauthMonitor(deauthRate, deauthWindow)
+			observers = append(observers, deauth.observe)
+		}
+		if inventoryTable || inventoryFile != "" {
+			networks = newInventory()
+			networks.minSignal = minSignal(minRSSI)
+			observers = append(observers, networks.observe)
+		}
+		if loads != nil {
+			observers = append(observers, loads.observe)
+		}
+		if dossierFile != "" {
+			history = newDossier(stderr)
+			history.minSignal = minSignal(minRSSI)
+			if targets != nil {
+				history.isTarget = targets.isTarget
+			}
+			observers = append(observers, history.observe)
+		}
+		if err == nil {
+			capture, err = startChannelCapture(iface.Index, out, observers)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot start capture: %v\n", err)
+			exit(1)
+		}
+
+		beforeHop = append(beforeHop, capture.beforeHop)
+		onHop = append(onHop, capture.hop)
+		if deauth != nil {
+			beforeHop = append(beforeHop, deauth.beforeHop)
+			onHop = append(onHop, deauth.hop)
+		}
+	}
+
+	var traffic *trafficMonitor
+	if rxStats || strategy == "escalate" || strategy == "focus" || dwellMode == "traffic" {
+		traffic = newTrafficMonitor(func() (rxCounters, error) {
+			return readRxCounters(iface.Name)
+		})
+		beforeHop = append(beforeHop, traffic.beforeHop)
+		onHop = append(onHop, traffic.hop)
+		onCycle = append(onCycle, traffic.cycle)
+	}
+	if strategy == "escalate" {
+		config.Strategy = &hopper.Escalating{
+			Active: traffic.active(escalateRate),
+			Max:    escalateMax,
+		}
+	}
+	floor := time.Duration(minDelay) * time.Millisecond
+	if ignoreMinDelay {
+		floor = 0
+	}
+	var lastRate func(channel int) float64
+	if traffic != nil {
+		lastRate = traffic.lastRate
+	}
+	if config.Dwell, err = dwellController(dwellMode, jitter, seed, lastRate, floor); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		exit(1)
+	}
+	if strategy == "focus" {
+		activity := traffic.lastRate
+		if loads != nil {
+			activity = loads.utilization
+		}
+		config.Strategy = &hopper.Focus{
+			Activity: activity,
+			Top:      focusTop,
+			Sweep:    time.Duration(sweepDelay) * time.Millisecond,
+			Every:    sweepEvery,
+		}
+	}
+	if resolved != nil {
+		config.Dwell = planDwell(resolved, config.Dwell, config.Strategy)
+	}
+	if power != nil {
+		config.Dwell = power.dwell(config.Dwell, config.Strategy)
+	}
+
+	var api *controlAPI
+	if httpAddr != "" {
+		if healthHops <= 0 {
+			healthHops = 1
+		}
+		api = newControlAPI(healthHops, time.Duration(delay)*time.Millisecond, delayFloor())
+		api.events = apiEvents
+		api.device = &device
+		api.traffic = traffic
+		if strategy == "escalate" {
+			api.maxDwell = escalateMax
+		}
+		if power != nil {
+			api.stretch = power.stretch
+		}
+		api.capabilities = func() (capabilityStatus, error) {
+			return radioCapabilities(client, iface.PHY)
+		}
+		onHop = append(onHop, api.hop)
+		if arbiterMode {
+			api.arbiter = newArbiter(sliceMax, sliceQueue)
+			onHop = append(onHop, api.arbiter.hop)
+		}
+
+		shutdown, err := api.serve(apiOptions)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot start HTTP API: %v\n", err)
+			exit(1)
+		}
+		defer shutdown()
+	}
+	var rpc *rpcServer
+	if rpcSocket != "" {
+		rpc = newRPCServer(delayFloor())
+		onHop = append(onHop, rpc.hop)
+
+		shutdown, err := rpc.listen(rpcSocket)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot start JSON-RPC API: %v\n", err)
+			exit(1)
+		}
+		defer shutdown()
+	}
+	if term != nil && !(jsonOutput() && isTerminal(os.Stdout)) {
+		// The status line would be mixed with events on the terminal.
+		onHop = append(onHop, term.hop)
+	}
+
+	h, err = hopper.New(tuner, config)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		exit(1)
+	}
+	// The features locking the channel share the plan to restore
+	shared := newSharedPlan(h)
+	shared.check = checkChannels
+	if api != nil {
+		api.setHopper(h, shared)
+	}
+	if rpc != nil {
+		rpc.setHopper(h, shared)
+	}
+	if term != nil {
+		term.plan = h.Channels
+	}
+	if targets != nil {
+		targets.start(shared)
+	}
+	if sched != nil {
+		go sched.watch(ctx, shared, interleave)
+	}
+	if bettercapURL != "" {
+		go watchBettercap(ctx, newBettercapClient(bettercapURL), shared, time.Second, bettercapLock)
+	}
+	if handshakes != nil {
+		go watchHandshakes(ctx, handshakes, shared, eapolLock, pmkidLock)
+	}
+	if api != nil && api.arbiter != nil {
+		go api.arbiter.run(ctx, shared)
+	}
+	// The geofence and the throttle may both idle the hopper
+	idle := newSharedPause(h)
+	if fence != nil {
+		// Nothing fenced is hopped before the first fix
+		if err := fence.start(shared, idle.planner("geofence")); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: geofence: %v\n", err)
+			exit(1)
+		}
+		go watchGPSD(ctx, gpsdAddr, fence.update)
+	}
+	if power != nil {
+		go power.run(ctx, idle.planner("throttle"))
+	}
+	if holdPath != "" {
+		hold := newHoldFile(holdPath, shared, holdTimeout)
+		if err := hold.open(); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --hold-file: %v\n", err)
+			exit(1)
+		}
+		defer hold.Close()
+		onHop = append(onHop, hold.hop)
+		go hold.run(ctx)
+	}
+	var spectrum *spectralMonitor
+	var spectralRun *spectralScan
+	if spectralTable || spectralFile != "" {
+		var raw io.Writer
+		if spectralFile != "" {
+			f, err := os.Create(spectralFile)
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+				exit(1)
+			}
+			defer f.Close()
+			raw = f
+		}
+		spectrum = newSpectralMonitor()
+		spectralRun, err = startSpectralScan(iface.PHY, spectrum, raw)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot start spectral scan: %v\n", err)
+			exit(1)
+		}
+
+		beforeHop = append(beforeHop, spectrum.beforeHop)
+		onHop = append(onHop, spectralRun.hop)
+	}
+	watchDelaySignals(ctx, h, time.Duration(delayStep)*time.Millisecond)
+
+	start := events.New(events.TypeStart)
+	start.Channels = channels
+	start.DelayMs = int64(delay)
+	emit(start)
+
+	err = h.Run(ctx)
+	if term != nil {
+		term.clear()
+	}
+	if err != nil {
+		emitError(err)
+	}
+	if capture != nil {
+		frames, retuning, err := capture.Close()
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot write capture: %v\n", err)
+		}
+		_, _ = fmt.Fprintf(stderr, "Captured %v frames, dropped %v received while retuning\n", frames, retuning)
+	}
+	if spectralRun != nil {
+		if err := spectralRun.Close(); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+		}
+		if spectralTable {
+			spectrum.writeTable(stderr)
+		}
+	}
+	if traffic != nil && rxStats {
+		traffic.writeTable(stderr)
+	}
+	if networks != nil && inventoryTable {
+		networks.writeTable(stderr)
+	}
+	if loads != nil {
+		loads.writeTable(stderr)
+	}
+	if history != nil {
+		if err := history.writeFile(dossierFile); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot write dossier: %v\n", err)
+		}
+	}
+	if networks != nil && inventoryFile != "" {
+		if err := networks.writeFile(inventoryFile); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot write inventory: %v\n", err)
+		}
+	}
+	if maxGap > 0 {
+		if n := h.Stats().Starved; n > 0 {
+			_, _ = fmt.Fprintf(stderr, "Channels went unvisited for more than %v %v times\n", maxGap, n)
+		}
+	}
+	if asserter != nil {
+		if n := asserter.count(); n > 0 {
+			_, _ = fmt.Fprintf(stderr, "Set the channel again %v times after other processes changed it\n", n)
+		}
+	}
+	warnings.flush(stderr)
+	stopSurveys()
+	stopInfluxSurveys()
+	emit(events.New(events.TypeStop))
+	stopTelemetry()
+	stopWebhook()
+	stopInflux()
+
+	if err != nil {
+		var hopErr *hopper.HopError
+		if errors.As(err, &hopErr) {
+			_, _ = fmt.Fprintf(stderr, "Cannot set channel %v\n", hopErr.Channel)
+			_, _ = fmt.Fprintf(stderr, "Device: %v\n", device)
+			err = hopErr.Err
+		}
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		exit(1)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestClampDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   int
+		floor   int
+		force   bool
+		output  int
+		clamped bool
+	}{
+		{
+			name:   "above_floor",
+			delay:  100,
+			floor:  50,
+			output: 100,
+		},
+		{
+			name:   "equal_floor",
+			delay:  50,
+			floor:  50,
+			output: 50,
+		},
+		{
+			name:    "below_floor",
+			delay:   10,
+			floor:   50,
+			output:  50,
+			clamped: true,
+		},
+		{
+			name:   "below_floor_forced",
+			delay:  10,
+			floor:  50,
+			force:  true,
+			output: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, clamped := clampDelay(tt.delay, tt.floor, tt.force)
+
+			if want, got := tt.output, result; want != got {
+				t.Fatalf("clampDelay(%v, %v, %v):\n- want: %v\n-  got: %v", tt.delay, tt.floor, tt.force, want, got)
+			}
+			if want, got := tt.clamped, clamped; want != got {
+				t.Fatalf("clampDelay(%v, %v, %v) clamped:\n- want: %v\n-  got: %v", tt.delay, tt.floor, tt.force, want, got)
+			}
+		})
+	}
+}
+
+func TestStartRotation(t *testing.T) {
+	channels := []int{1, 6, 11}
+
+	tests := []struct {
+		start  string
+		output []int
+		err    bool
+	}{
+		{"", []int{1, 6, 11}, false},
+		{"6", []int{6, 11, 1}, false},
+		{"3", nil, true},
+		{"six", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := startRotation(channels, tt.start, nil)
+		if (err != nil) != tt.err {
+			t.Fatalf("startRotation(%v, %q): unexpected error %v", channels, tt.start, err)
+		}
+		if !reflect.DeepEqual(tt.output, got) {
+			t.Fatalf("startRotation(%v, %q):\n- want: %v\n-  got: %v", channels, tt.start, tt.output, got)
+		}
+	}
+
+	got, err := startRotation(channels, "random", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(channels) {
+		t.Fatalf("startRotation(%v, random): %v is not a rotation", channels, got)
+	}
+}
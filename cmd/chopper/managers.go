@@ -0,0 +1,193 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	procDir = "/proc"
+	sysDir  = "/sys"
+)
+
+// managerNames are daemons known to retune interfaces behind our back.
+var managerNames = map[string]bool{
+	"wpa_supplicant": true,
+	"NetworkManager": true,
+	"iwd":            true,
+	"hostapd":        true,
+	"connmand":       true,
+}
+
+// interferingNames are processes that do not retune the radio but still
+// disturb monitor mode, listed by the check subcommand.
+var interferingNames = map[string]bool{
+	"dhclient":     true,
+	"dhcpcd":       true,
+	"avahi-daemon": true,
+}
+
+// competingManager is a running daemon that may manage the phy.
+type competingManager struct {
+	Name string
+	PID  int
+	Args []string
+	// Interface is set when the daemon was explicitly started on an
+	// interface of the phy.
+	Interface string
+}
+
+func (m competingManager) String() string {
+	if m.Interface != "" {
+		return fmt.Sprintf("%v (pid %v) on %v", m.Name, m.PID, m.Interface)
+	}
+	return fmt.Sprintf("%v (pid %v)", m.Name, m.PID)
+}
+
+// phyInterfaces returns the network interfaces sharing the given phy.
+func phyInterfaces(phy int) []string {
+	entries, err := ioutil.ReadDir(filepath.Join(sysDir, "class", "ieee80211", fmt.Sprintf("phy%d", phy), "device", "net"))
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// interfaceArgs returns the interfaces a process was started on: the ones
+// passed with -i, like wpa_supplicant, and existing interfaces passed as
+// positional arguments, like dhclient.
+func interfaceArgs(args []string) []string {
+	var ifaces []string
+	for i, arg := range args {
+		if arg == "-i" && i+1 < len(args) {
+			ifaces = append(ifaces, args[i+1])
+		} else if strings.HasPrefix(arg, "-i") && len(arg) > 2 {
+			ifaces = append(ifaces, arg[2:])
+		} else if i > 0 && !strings.HasPrefix(arg, "-") && isInterface(arg) {
+			ifaces = append(ifaces, arg)
+		}
+	}
+	return ifaces
+}
+
+func isInterface(name string) bool {
+	if strings.ContainsRune(name, '/') {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(sysDir, "class", "net", name))
+	return err == nil
+}
+
+// findManagers inspects the running processes for daemons that may manage
+// one of the given interfaces.
+func findManagers(ifaces []string) []competingManager {
+	return findProcesses(managerNames, ifaces)
+}
+
+// findProcesses returns the running processes with one of the given names.
+// When ifaces is not empty, processes bound to other interfaces are ignored
+// and all the others are assumed to use every interface.
+func findProcesses(names map[string]bool, ifaces []string) []competingManager {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+
+	ours := make(map[string]bool)
+	for _, iface := range ifaces {
+		ours[iface] = true
+	}
+
+	var found []competingManager
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		comm, err := ioutil.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(string(comm))
+		if !names[name] {
+			continue
+		}
+
+		cmdline, _ := ioutil.ReadFile(filepath.Join(procDir, entry.Name(), "cmdline"))
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		manager := competingManager{Name: name, PID: pid, Args: args}
+		if len(ifaces) == 0 {
+			found = append(found, manager)
+			continue
+		}
+
+		if bound := interfaceArgs(args); len(bound) > 0 {
+			for _, iface := range bound {
+				if ours[iface] {
+					manager.Interface = iface
+				}
+			}
+			if manager.Interface == "" {
+				continue
+			}
+		}
+		found = append(found, manager)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].PID < found[j].PID
+	})
+	return found
+}
+
+// checkManagers warns about competing channel managers and reports whether
+// it is safe to continue.
+func checkManagers(iface string, phy int, force bool) bool {
+	ifaces := append(phyInterfaces(phy), iface)
+	managers := findManagers(ifaces)
+	if len(managers) == 0 {
+		return true
+	}
+
+	_, _ = fmt.Fprintf(stderr, "WARNING: ********************************************************\n")
+	_, _ = fmt.Fprintf(stderr, "WARNING: these processes may change the channel of phy%v:\n", phy)
+	for _, m := range managers {
+		_, _ = fmt.Fprintf(stderr, "WARNING:   %v\n", m)
+	}
+	_, _ = fmt.Fprintf(stderr, "WARNING: stop them or mark the interface as unmanaged, otherwise\n")
+	_, _ = fmt.Fprintf(stderr, "WARNING: the channel will keep snapping back.\n")
+	_, _ = fmt.Fprintf(stderr, "WARNING: ********************************************************\n")
+
+	if !force {
+		_, _ = fmt.Fprintf(stderr, "ERROR: refusing to start, pass --force to continue anyway\n")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeProcess creates /proc/<pid>/{comm,cmdline} under dir.
+func fakeProcess(t *testing.T, dir string, pid string, args ...string) {
+	path := filepath.Join(dir, pid)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "comm"), []byte(filepath.Base(args[0])+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "cmdline"), []byte(strings.Join(args, "\x00")+"\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindManagers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(proc, sys string) { procDir, sysDir = proc, sys }(procDir, sysDir)
+	procDir = filepath.Join(dir, "proc")
+	sysDir = filepath.Join(dir, "sys")
+	for _, iface := range []string{"eth0", "wlan0", "wlan1"} {
+		if err := os.MkdirAll(filepath.Join(sysDir, "class", "net", iface), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fakeProcess(t, procDir, "1", "/sbin/init")
+	fakeProcess(t, procDir, "100", "/usr/sbin/NetworkManager", "--no-daemon")
+	fakeProcess(t, procDir, "200", "/sbin/wpa_supplicant", "-B", "-i", "wlan1", "-c", "/etc/wpa.conf")
+	fakeProcess(t, procDir, "300", "/sbin/wpa_supplicant", "-iwlan0", "-c", "/etc/wpa.conf")
+	fakeProcess(t, procDir, "400", "/sbin/wpa_supplicant", "-u", "-s")
+
+	fakeProcess(t, procDir, "500", "/sbin/dhclient", "-v", "wlan0")
+	fakeProcess(t, procDir, "600", "/sbin/dhclient", "-v", "eth0")
+
+	tests := []struct {
+		name   string
+		names  map[string]bool
+		ifaces []string
+		want   []string
+	}{
+		{"managers", managerNames, []string{"wlan0", "wlan0mon"}, []string{
+			"NetworkManager (pid 100)",
+			"wpa_supplicant (pid 300) on wlan0",
+			"wpa_supplicant (pid 400)",
+		}},
+		{"other phy", managerNames, []string{"wlan1"}, []string{
+			"NetworkManager (pid 100)",
+			"wpa_supplicant (pid 200) on wlan1",
+			"wpa_supplicant (pid 400)",
+		}},
+		{"dhclient", interferingNames, []string{"wlan0"}, []string{
+			"dhclient (pid 500) on wlan0",
+		}},
+		{"any interface", interferingNames, nil, []string{
+			"dhclient (pid 500)",
+			"dhclient (pid 600)",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range findProcesses(tt.names, tt.ifaces) {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Fatalf("findProcesses(%v):\n- want: %v\n-  got: %v", tt.ifaces, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInterfaceArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"wpa_supplicant", "-u"}, nil},
+		{[]string{"wpa_supplicant", "-i", "wlan0"}, []string{"wlan0"}},
+		{[]string{"wpa_supplicant", "-iwlan0", "-N", "-i", "wlan1"}, []string{"wlan0", "wlan1"}},
+		{[]string{"wpa_supplicant", "-i"}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := interfaceArgs(tt.args); !reflect.DeepEqual(tt.want, got) {
+			t.Fatalf("interfaceArgs(%v):\n- want: %v\n-  got: %v", tt.args, tt.want, got)
+		}
+	}
+}
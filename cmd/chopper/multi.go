@@ -0,0 +1,531 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+)
+
+// defaultRadioDelay is the delay in ms of the radios that do not set one.
+const defaultRadioDelay = 100
+
+// radioConfig is a line of a radios file: an interface and how it hops.
+type radioConfig struct {
+	Interface string
+	Channels  []int
+	Delay     int
+	Strategy  string
+	Seed      int64
+}
+
+// parseRadios parses a radios file: one monitor interface per line followed
+// by its options, a subset of the ones of chopper. Empty lines and lines
+// starting with # are ignored.
+func parseRadios(r io.Reader) ([]radioConfig, error) {
+	var radios []radioConfig
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		radio := radioConfig{Interface: fields[0]}
+		if strings.HasPrefix(radio.Interface, "-") {
+			return nil, fmt.Errorf("line %v: expected an interface before the options", n)
+		}
+		if seen[radio.Interface] {
+			return nil, fmt.Errorf("line %v: %v is listed twice", n, radio.Interface)
+		}
+		seen[radio.Interface] = true
+
+		var chans, planName string
+		flags := flag.NewFlagSet(radio.Interface, flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		flags.StringVarP(&chans, "channels", "c", "", "")
+		flags.StringVar(&planName, "plan", "", "")
+		flags.IntVarP(&radio.Delay, "delay", "d", defaultRadioDelay, "")
+		flags.StringVar(&radio.Strategy, "strategy", "sequential", "")
+		flags.Int64Var(&radio.Seed, "seed", 0, "")
+		if err := flags.Parse(fields[1:]); err != nil {
+			return nil, fmt.Errorf("line %v: %v", n, err)
+		}
+		if flags.NArg() > 0 {
+			return nil, fmt.Errorf("line %v: unexpected %q", n, flags.Arg(0))
+		}
+		if radio.Strategy != "sequential" && radio.Strategy != "shuffle" {
+			return nil, fmt.Errorf("line %v: unknown strategy %v, radios support sequential and shuffle", n, radio.Strategy)
+		}
+
+		switch {
+		case chans != "" && planName != "":
+			return nil, fmt.Errorf("line %v: --plan and --channels cannot be used together", n)
+		case planName != "":
+			var err error
+			if radio.Channels, err = plan.Named(planName); err != nil {
+				return nil, fmt.Errorf("line %v: %v", n, err)
+			}
+		case chans != "":
+			var err error
+			if radio.Channels, err = schedulePlan(chans); err != nil {
+				return nil, fmt.Errorf("line %v: %v", n, err)
+			}
+		default:
+			radio.Channels = plan.Default()
+		}
+		radios = append(radios, radio)
+	}
+
+	return radios, scanner.Err()
+}
+
+// radio is a hopper running on one of the interfaces of chopper multi.
+type radio struct {
+	name   string
+	phy    int
+	client *nl80211util.Client
+	lock   *os.File
+	api    *controlAPI
+	hopper *hopper.Hopper
+}
+
+func (r *radio) close() {
+	if r.lock != nil {
+		_ = r.lock.Close()
+	}
+	_ = r.client.Close()
+}
+
+// openRadio prepares the hopper of config, with the delay raised to the
+// minimum of the driver.
+func openRadio(config radioConfig, healthHops int, force bool) (*radio, error) {
+	client, err := nl80211util.Dial()
+	if err != nil {
+		return nil, err
+	}
+	r := &radio{name: config.Interface, client: client}
+
+	iface, err := client.MonitorInterface(config.Interface)
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	r.phy = iface.PHY
+	if r.lock, err = lockInterface(iface.Name); err != nil {
+		r.close()
+		return nil, err
+	}
+	if !checkManagers(iface.Name, iface.PHY, force) {
+		r.close()
+		return nil, errors.New("other processes may manage the interface, pass --force to ignore them")
+	}
+
+	device := describeDevice(iface.Name)
+	fixes := driverQuirks[device.Driver]
+	floor := DefaultMinDelay
+	if fixes.MinDelay > floor {
+		floor = fixes.MinDelay
+	}
+	if clamped, ok := clampDelay(config.Delay, floor, false); ok {
+		_, _ = fmt.Fprintf(stderr, "WARNING: %v: delay %vms is below the minimum of %vms, using %vms.\n", r.name, config.Delay, floor, clamped)
+		config.Delay = clamped
+	}
+
+	var tuner hopper.Tuner = hopper.TunerFunc(func(channel int) error {
+		return client.SetFrequencyKHz(iface.Index, plan.FrequencyKHz(channel))
+	})
+	if fixes.UpDown {
+		tuner = newUpDownTuner(tuner, client, iface.Index)
+	}
+
+	delay := time.Duration(config.Delay) * time.Millisecond
+	r.api = newControlAPI(healthHops, delay, time.Duration(floor)*time.Millisecond)
+	r.api.device = &device
+	r.api.capabilities = func() (capabilityStatus, error) {
+		return radioCapabilities(client, iface.PHY)
+	}
+
+	hopConfig := hopper.Config{
+		Channels: config.Channels,
+		Delay:    delay,
+		OnHop:    r.api.hop,
+		OnError: func(err error) {
+			warnf("%v: %v", r.name, err)
+		},
+	}
+	if config.Strategy == "shuffle" {
+		seed := config.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		hopConfig.Strategy = hopper.Shuffled{Rand: rand.New(rand.NewSource(seed))}
+	}
+	if r.hopper, err = hopper.New(tuner, hopConfig); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.api.setHopper(r.hopper, newSharedPlan(r.hopper))
+	return r, nil
+}
+
+// radioStatus is the status of a radio returned by GET /radios.
+type radioStatus struct {
+	Interface string    `json:"interface"`
+	PHY       int       `json:"phy"`
+	Status    string    `json:"status"`
+	Channel   int       `json:"channel,omitempty"`
+	LastHop   time.Time `json:"last_hop"`
+	Plan      string    `json:"plan"`
+	DelayMs   int64     `json:"delay_ms"`
+	Hops      int       `json:"hops"`
+	Failures  int       `json:"failures"`
+}
+
+// multiAPI serves the status of every radio and, under /radios/<name>/, the
+// API of each one.
+type multiAPI struct {
+	mu       sync.Mutex
+	radios   []*radio
+	handlers map[string]http.Handler
+}
+
+// add adds an adopted radio, replacing the one with the same name.
+func (m *multiAPI) add(r *radio) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replaced := false
+	for i, other := range m.radios {
+		if other.name == r.name {
+			m.radios[i] = r
+			replaced = true
+		}
+	}
+	if !replaced {
+		m.radios = append(m.radios, r)
+	}
+	// Handlers are only built once the API is served
+	if m.handlers != nil {
+		m.handlers[r.name] = r.api.handler()
+	}
+}
+
+func (m *multiAPI) list() []*radio {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	radios := make([]*radio, len(m.radios))
+	copy(radios, m.radios)
+	return radios
+}
+
+func (m *multiAPI) status() []radioStatus {
+	radios := m.list()
+	statuses := make([]radioStatus, 0, len(radios))
+	for _, r := range radios {
+		health := r.api.health()
+		stats := r.hopper.Stats()
+		statuses = append(statuses, radioStatus{
+			Interface: r.name,
+			PHY:       r.phy,
+			Status:    health.Status,
+			Channel:   health.Channel,
+			LastHop:   health.LastHop,
+			Plan:      plan.Format(r.hopper.Channels()),
+			DelayMs:   r.hopper.Delay().Milliseconds(),
+			Hops:      stats.Hops,
+			Failures:  stats.Failures,
+		})
+	}
+	return statuses
+}
+
+func (m *multiAPI) getRadios(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, m.status())
+}
+
+// healthz reports unhealthy when any radio is, paused radios are healthy.
+func (m *multiAPI) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := m.status()
+	for _, status := range statuses {
+		if status.Status == "unhealthy" {
+			writeJSON(w, http.StatusServiceUnavailable, statuses)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// radioAPI dispatches /radios/<name>/ to the API of the radio, which may
+// have been adopted after the server started.
+func (m *multiAPI) radioAPI(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/radios/")
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		name = name[:i]
+	}
+
+	m.mu.Lock()
+	handler, ok := m.handlers[name]
+	m.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.StripPrefix("/radios/"+name, handler).ServeHTTP(w, r)
+}
+
+func (m *multiAPI) handler() http.Handler {
+	m.mu.Lock()
+	m.handlers = make(map[string]http.Handler)
+	for _, r := range m.radios {
+		m.handlers[r.name] = r.api.handler()
+	}
+	m.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", traced("GET /healthz", http.HandlerFunc(m.healthz)))
+	mux.Handle("/version", traced("GET /version", http.HandlerFunc(new(controlAPI).getVersion)))
+	mux.Handle("/radios", traced("GET /radios", http.HandlerFunc(m.getRadios)))
+	mux.Handle("/radios/", http.HandlerFunc(m.radioAPI))
+	return mux
+}
+
+// writeRadios writes the hop counters of every radio.
+func writeRadios(w io.Writer, statuses []radioStatus) {
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Interface < statuses[j].Interface })
+	_, _ = fmt.Fprintf(w, "%v\n", paint(colorStdout, ansiBold, fmt.Sprintf("%-12s %-5s %8s %8s  %v", "INTERFACE", "PHY", "HOPS", "FAILURES", "PLAN")))
+	for _, s := range statuses {
+		_, _ = fmt.Fprintf(w, "%-12s %-5v %8v %8v  %v\n", s.Interface, fmt.Sprintf("phy%v", s.PHY), s.Hops, s.Failures, s.Plan)
+	}
+}
+
+func runMulti(ctx context.Context, args []string) int {
+	var (
+		httpAddr   string
+		token      string
+		tokenFile  string
+		healthHops int
+		force      bool
+		colorMode  string
+		split      string
+		autoAdopt  bool
+	)
+
+	flags := flag.NewFlagSet("multi", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: %v multi [options] <radios file>\n\n", ProgramName)
+		_, _ = fmt.Fprintf(stderr, "The radios file lists a monitor interface per line with its -c, --plan, -d,\n--strategy (sequential or shuffle) and --seed.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&httpAddr, "http-addr", "", "serve the status of every radio and their APIs under /radios/<interface>/ on this address")
+	flags.StringVar(&token, "api-token", os.Getenv("CHOPPER_API_TOKEN"), "require this bearer token on API requests")
+	flags.StringVar(&tokenFile, "api-token-file", "", "read the API token from this file")
+	flags.IntVar(&healthHops, "health-hops", 10, "report a radio unhealthy after X delays without a successful hop")
+	flags.BoolVar(&force, "force", false, "start even if other processes may manage the interfaces")
+	flags.DurationVar(&warnings.interval, "warn-interval", 30*time.Second, "print a repeated warning once per interval with its count (0 prints every one)")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	flags.StringVar(&split, "split", "", "split this plan among the radios by band instead of using their own, and among the remaining ones when a radio stops")
+	flags.BoolVar(&autoAdopt, "auto-adopt", false, "hop on the monitor interfaces that appear while running, with a share of the --split plan")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+	if healthHops <= 0 {
+		healthHops = 1
+	}
+	token, err := apiToken(token, tokenFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot read API token: %v\n", err)
+		return 1
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	configs, err := parseRadios(f)
+	_ = f.Close()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	if len(configs) == 0 {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v lists no radio\n", flags.Arg(0))
+		return 1
+	}
+	if autoAdopt && split == "" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --auto-adopt needs a --split plan to share\n")
+		return 1
+	}
+	var global []int
+	if split != "" {
+		if global, err = schedulePlan(split); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: --split: %v\n", err)
+			return 1
+		}
+	}
+
+	var radios []*radio
+	defer func() {
+		for _, r := range radios {
+			r.close()
+		}
+	}()
+	phys := make(map[int]string)
+	for _, config := range configs {
+		r, err := openRadio(config, healthHops, force)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v: %v\n", config.Interface, err)
+			return 1
+		}
+		radios = append(radios, r)
+		// Interfaces of a radio share its channel
+		if other, ok := phys[r.phy]; ok {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v and %v are on the same radio phy%v\n", other, r.name, r.phy)
+			return 1
+		}
+		phys[r.phy] = r.name
+		if global == nil {
+			_, _ = fmt.Fprintf(stderr, "%v: hopping on %v every %v\n", r.name, plan.Format(r.hopper.Channels()), r.hopper.Delay())
+		}
+	}
+	var bands *bandSplit
+	if global != nil {
+		if bands, err = newBandSplit(global, radios); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		bands.apply()
+	}
+
+	m := &multiAPI{radios: radios}
+	if httpAddr != "" {
+		handler := m.handler()
+		if token != "" {
+			handler = requireToken(token, handler)
+		}
+		shutdown, err := serveAPI(httpAddr, handler, nil)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot start HTTP API: %v\n", err)
+			return 1
+		}
+		defer shutdown()
+	}
+
+	// A failing radio stops the others, like a single chopper exits, unless
+	// its channels can be moved to them
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	code := 0
+	start := func(r *radio) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.hopper.Run(ctx); err != nil {
+				var hopErr *hopper.HopError
+				if errors.As(err, &hopErr) {
+					_, _ = fmt.Fprintf(stderr, "%v: device: %v\n", r.name, r.api.device)
+				}
+				_, _ = fmt.Fprintf(stderr, "ERROR: %v: %v\n", r.name, err)
+				if bands != nil && bands.fail(r) {
+					return
+				}
+				mu.Lock()
+				code = 1
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	for _, r := range radios {
+		start(r)
+	}
+	if autoAdopt {
+		events, err := radios[0].client.LinkEvents()
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot watch interfaces: %v\n", err)
+			cancel()
+			wg.Wait()
+			return 1
+		}
+		adopter := &radioAdopter{
+			bands: bands,
+			api:   m,
+			open: func(name string) (*radio, error) {
+				return openRadio(radioConfig{Interface: name, Channels: global, Delay: defaultRadioDelay, Strategy: "sequential"}, healthHops, force)
+			},
+			supported: supportedChannels,
+			start:     start,
+		}
+		defer adopter.close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			adopter.run(ctx, events)
+		}()
+		go func() {
+			<-ctx.Done()
+			_ = events.Close()
+		}()
+	}
+	wg.Wait()
+	warnings.flush(stderr)
+
+	writeRadios(os.Stdout, m.status())
+	return code
+}
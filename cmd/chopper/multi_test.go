@@ -0,0 +1,124 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func TestParseRadios(t *testing.T) {
+	input := `
+# 2.4 GHz on the internal card, 5 GHz on the USB one
+wlan0mon -c 1,6,11 -d 200
+wlan1mon --plan eu-5-nondfs --strategy shuffle --seed 7
+wlan2mon
+`
+	radios, err := parseRadios(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eu5, _ := plan.Named("eu-5-nondfs")
+	want := []radioConfig{
+		{Interface: "wlan0mon", Channels: []int{1, 6, 11}, Delay: 200, Strategy: "sequential"},
+		{Interface: "wlan1mon", Channels: eu5, Delay: 100, Strategy: "shuffle", Seed: 7},
+		{Interface: "wlan2mon", Channels: plan.Default(), Delay: 100, Strategy: "sequential"},
+	}
+	if !reflect.DeepEqual(want, radios) {
+		t.Errorf("parseRadios:\n- want: %+v\n-  got: %+v", want, radios)
+	}
+
+	for _, input := range []string{
+		"-c 1,6,11",
+		"wlan0mon\nwlan0mon -c 1",
+		"wlan0mon --txpower 10",
+		"wlan0mon --strategy ranked",
+		"wlan0mon -c 1 --plan us-5",
+		"wlan0mon 1,6,11",
+	} {
+		if _, err := parseRadios(strings.NewReader(input)); err == nil {
+			t.Errorf("parseRadios(%q) did not fail", input)
+		}
+	}
+}
+
+func testRadio(t *testing.T, name string, phy int, channels []int) *radio {
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+		Channels: channels,
+		Delay:    100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newControlAPI(10, 100*time.Millisecond, 0)
+	api.setHopper(h, newSharedPlan(h))
+	return &radio{name: name, phy: phy, api: api, hopper: h}
+}
+
+func TestMultiAPI(t *testing.T) {
+	wlan0 := testRadio(t, "wlan0mon", 0, []int{1, 6, 11})
+	wlan1 := testRadio(t, "wlan1mon", 1, []int{36, 40})
+	m := &multiAPI{radios: []*radio{wlan0, wlan1}}
+	handler := m.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/radios", nil))
+	var statuses []radioStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[1].Interface != "wlan1mon" || statuses[1].Plan != "36,40" || statuses[1].DelayMs != 100 {
+		t.Errorf("GET /radios: %v", rec.Body.String())
+	}
+
+	// Each radio is controlled under its own prefix
+	req := httptest.NewRequest(http.MethodPost, "/radios/wlan1mon/plan/set-plan", strings.NewReader("channels=44,48"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !reflect.DeepEqual(wlan1.hopper.Channels(), []int{44, 48}) {
+		t.Errorf("POST /radios/wlan1mon/plan/set-plan: %v %v, plan %v", rec.Code, rec.Body.String(), wlan1.hopper.Channels())
+	}
+	if !reflect.DeepEqual(wlan0.hopper.Channels(), []int{1, 6, 11}) {
+		t.Errorf("plan of the other radio changed to %v", wlan0.hopper.Channels())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	// A wedged radio makes the whole process unhealthy
+	wlan0.api.now = func() time.Time { return time.Now().Add(time.Minute) }
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"unhealthy"`) {
+		t.Errorf("GET /healthz with a wedged radio = %v %v", rec.Code, rec.Body.String())
+	}
+}
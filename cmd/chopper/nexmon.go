@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+const (
+	// nexmonDelay is the minimum delay in ms of the nexmon mode, the
+	// firmware drops frames for a while after every chanspec change.
+	nexmonDelay = 250
+	// nexutil is the tool of the nexmon project talking to the firmware.
+	nexutil = "nexutil"
+)
+
+// detectNexmon reports whether the interface runs the nexmon firmware
+// patches: brcmfmac with a firmware version tagged by nexmon, or with
+// nexutil installed since the stock firmware has no usable monitor mode.
+func detectNexmon(driver, firmware string, lookPath func(string) (string, error)) bool {
+	if driver != "brcmfmac" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(firmware), "nexmon") {
+		return true
+	}
+	_, err := lookPath(nexutil)
+	return err == nil
+}
+
+// nexmonMode resolves --nexmon for the driver and firmware version of the
+// interface.
+func nexmonMode(mode, driver, firmware string) (bool, error) {
+	switch mode {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	case "auto":
+		return detectNexmon(driver, firmware, exec.LookPath), nil
+	}
+	return false, fmt.Errorf("invalid --nexmon %v, want auto, on or off", mode)
+}
+
+// nexmonChanspec returns the nexutil chanspec of a 20 MHz channel.
+func nexmonChanspec(channel int) (string, error) {
+	if plan.Offset(channel) != 0 {
+		return "", fmt.Errorf("channel %v: frequency offsets are not supported by nexmon", plan.FormatChannel(channel))
+	}
+	switch plan.BandOf(channel) {
+	case plan.Band2GHz, plan.Band5GHz:
+		return fmt.Sprintf("%d/20", channel), nil
+	}
+	return "", fmt.Errorf("channel %v is not supported by nexmon", plan.FormatChannel(channel))
+}
+
+// nexmonTuner sets the chanspec through nexutil, since the patched
+// firmware ignores or rejects nl80211 channel changes of its monitor
+// interface.
+type nexmonTuner struct {
+	command string
+	iface   string
+}
+
+func (t nexmonTuner) SetChannel(channel int) error {
+	chanspec, err := nexmonChanspec(channel)
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.Command(t.command, "-I", t.iface, "-k", chanspec).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %v", err, msg)
+		}
+		return err
+	}
+	return nil
+}
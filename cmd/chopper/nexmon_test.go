@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func TestDetectNexmon(t *testing.T) {
+	found := func(string) (string, error) { return "/usr/bin/nexutil", nil }
+	missing := func(string) (string, error) { return "", errors.New("not found") }
+
+	tests := []struct {
+		driver   string
+		firmware string
+		lookPath func(string) (string, error)
+		want     bool
+	}{
+		{"brcmfmac", "7.45.189 (nexmon.org: 2.2.2)", missing, true},
+		{"brcmfmac", "7.45.189", found, true},
+		{"brcmfmac", "7.45.189", missing, false},
+		{"ath9k", "nexmon", found, false},
+	}
+	for _, test := range tests {
+		if got := detectNexmon(test.driver, test.firmware, test.lookPath); got != test.want {
+			t.Errorf("detectNexmon(%q, %q) = %v, want %v", test.driver, test.firmware, got, test.want)
+		}
+	}
+
+	if _, err := nexmonMode("always", "brcmfmac", ""); err == nil {
+		t.Error("nexmonMode accepted an invalid mode")
+	}
+	if on, err := nexmonMode("on", "ath9k", ""); err != nil || !on {
+		t.Errorf("nexmonMode(on) = %v, %v", on, err)
+	}
+}
+
+func TestNexmonChanspec(t *testing.T) {
+	for channel, want := range map[int]string{6: "6/20", 36: "36/20"} {
+		if got, err := nexmonChanspec(channel); err != nil || got != want {
+			t.Errorf("nexmonChanspec(%v) = %q, %v, want %q", channel, got, err, want)
+		}
+	}
+	if _, err := nexmonChanspec(plan.Channel6GHz(37)); err == nil {
+		t.Error("nexmonChanspec accepted a 6 GHz channel")
+	}
+	if _, err := nexmonChanspec(plan.WithOffset(6, 500)); err == nil {
+		t.Error("nexmonChanspec accepted a frequency offset")
+	}
+}
+
+func TestNexmonTuner(t *testing.T) {
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	command := filepath.Join(dir, "nexutil")
+	script := "#!/bin/sh\necho \"$@\" > " + args + "\n[ \"$4\" = 13/20 ] && echo 'invalid chanspec' && exit 1\nexit 0\n"
+	if err := os.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tuner := nexmonTuner{command: command, iface: "mon0"}
+	if err := tuner.SetChannel(6); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != "-I mon0 -k 6/20" {
+		t.Errorf("nexutil called with %q", got)
+	}
+
+	err = tuner.SetChannel(13)
+	if err == nil || !strings.Contains(err.Error(), "invalid chanspec") {
+		t.Errorf("got %v, want the output of nexutil", err)
+	}
+}
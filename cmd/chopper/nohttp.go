@@ -0,0 +1,157 @@
+//go:build nohttp
+// +build nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// The stubs below stand in for the HTTP API, chopper controller, chopper
+// multi, chopper agent, Kismet, bettercap, webhooks and OTLP telemetry in
+// builds with -tags nohttp. Their flags are not registered, so apart from
+// the subcommands and the kismet strategy none of them can be reached.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+var errNoHTTP = errors.New("not supported by this build (-tags nohttp)")
+
+func registerHTTPFlags() {}
+
+type apiOptions struct{}
+
+func checkAPIFlags() (apiOptions, error) {
+	if agentMode {
+		return apiOptions{}, fmt.Errorf("chopper agent is %v", errNoHTTP)
+	}
+	if arbiterMode {
+		return apiOptions{}, fmt.Errorf("chopper arbiter is %v", errNoHTTP)
+	}
+	return apiOptions{}, nil
+}
+
+type controlAPI struct {
+	maxDwell     time.Duration
+	stretch      func() float64
+	capabilities func() (capabilityStatus, error)
+	events       *eventStream
+	device       *deviceInfo
+	traffic      *trafficMonitor
+	arbiter      *arbiter
+}
+
+type arbiter struct{}
+
+func newArbiter(time.Duration, int) *arbiter {
+	return &arbiter{}
+}
+
+func (a *arbiter) hop(int) {}
+
+func (a *arbiter) run(context.Context, *sharedPlan) {}
+
+func newControlAPI(int, time.Duration, time.Duration) *controlAPI {
+	return &controlAPI{}
+}
+
+func (a *controlAPI) setHopper(*hopper.Hopper, *sharedPlan) {}
+
+func (a *controlAPI) hop(int) {}
+
+func (a *controlAPI) serve(apiOptions) (func(), error) {
+	return nil, errNoHTTP
+}
+
+type eventStream struct{}
+
+func newEventStream() *eventStream {
+	return &eventStream{}
+}
+
+func (s *eventStream) Encode(events.Event) error {
+	return nil
+}
+
+func runController(context.Context, []string) int {
+	_, _ = fmt.Fprintf(stderr, "ERROR: chopper controller is %v\n", errNoHTTP)
+	return 1
+}
+
+func runMulti(context.Context, []string) int {
+	_, _ = fmt.Fprintf(stderr, "ERROR: chopper multi is %v\n", errNoHTTP)
+	return 1
+}
+
+type kismetClient struct{}
+
+func newKismetClient(string, string, time.Duration) *kismetClient {
+	return &kismetClient{}
+}
+
+func (k *kismetClient) update(context.Context) error {
+	return errNoHTTP
+}
+
+func (k *kismetClient) run(context.Context, time.Duration) {}
+
+func (k *kismetClient) Activity() (map[int]float64, error) {
+	return nil, errNoHTTP
+}
+
+type bettercapClient struct{}
+
+func newBettercapClient(string) *bettercapClient {
+	return &bettercapClient{}
+}
+
+func watchBettercap(context.Context, *bettercapClient, *sharedPlan, time.Duration, time.Duration) {}
+
+func startWebhook(string, string, int, time.Duration) (func(), error) {
+	return nil, errNoHTTP
+}
+
+func startTelemetry(context.Context, string, time.Duration) func() {
+	return func() {}
+}
+
+func countAlert(events.Event) {}
+
+func countStarved(int) {}
+
+func tracedTuner(setFrequency func(ifindex int, frequency int) error, ifindex int) hopper.Tuner {
+	return hopper.TunerFunc(func(channel int) error {
+		return setFrequency(ifindex, plan.FrequencyKHz(channel))
+	})
+}
+
+func tracedVerify(verify func(channel int) error, ifindex int) func(channel int) error {
+	return verify
+}
+
+func tracedSurvey(client *nl80211util.Client, ifindex int) func() ([]nl80211util.SurveyInfo, error) {
+	return func() ([]nl80211util.SurveyInfo, error) {
+		return client.Survey(ifindex)
+	}
+}
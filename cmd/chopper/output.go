@@ -0,0 +1,102 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+var (
+	// jsonStdout is set by --output json. stdout then only carries events
+	// and all human-readable text goes to stderr.
+	jsonStdout bool
+
+	// eventSinks receive every event: stdout with --output json, the hop
+	// log with --log-file and the webhook with --webhook-url.
+	eventSinks []eventSink
+
+	// hopSequence numbers the hop events of the interface.
+	hopSequence events.HopSequence
+)
+
+// eventSink is implemented by events.Encoder and events.Webhook.
+type eventSink interface {
+	Encode(event events.Event) error
+}
+
+// filteredSink only passes events of the given types to sink.
+type filteredSink struct {
+	sink  eventSink
+	types map[string]bool
+}
+
+func (f filteredSink) Encode(event events.Event) error {
+	if !f.types[event.Type] {
+		return nil
+	}
+	return f.sink.Encode(event)
+}
+
+func jsonOutput() bool {
+	return jsonStdout
+}
+
+// emitting reports whether events are written anywhere.
+func emitting() bool {
+	return len(eventSinks) > 0
+}
+
+// emit writes an event to all the sinks.
+func emit(event events.Event) {
+	event.Interface = interfaceName
+	for _, sink := range eventSinks {
+		if err := sink.Encode(event); err != nil {
+			warnf("cannot write event: %v", err)
+		}
+	}
+}
+
+func emitHop(channel int) {
+	event := events.New(events.TypeHop)
+	event.Channel = channel
+	event.Frequency = plan.Frequency(channel)
+	hopSequence.Next(&event)
+	emit(event)
+}
+
+func emitCycle(cycle int) {
+	event := events.New(events.TypeCycle)
+	event.Cycle = cycle
+	emit(event)
+}
+
+func emitError(err error) {
+	event := events.New(events.TypeError)
+	event.Error = err.Error()
+	emit(event)
+}
+
+func emitBSS(bss nl80211util.BSS) {
+	event := events.New(events.TypeBSS)
+	event.BSSID = bss.BSSID.String()
+	event.SSID = bss.SSID
+	event.Frequency = bss.Frequency
+	event.Signal = bss.Signal
+	emit(event)
+}
@@ -0,0 +1,181 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPause is the longest pause accepted by POST /pause, so that a
+// forgotten pause does not stop hopping for good.
+const maxPause = time.Hour
+
+// pauseState freezes the hopper on a channel until the pause expires or
+// is lifted, restoring the plan afterwards.
+type pauseState struct {
+	mu    sync.Mutex
+	lock  channelLock
+	timer *time.Timer
+	// generation tells the timer of a replaced pause from the current one
+	generation int
+}
+
+// pauseStatus is returned by the /pause and /resume endpoints.
+type pauseStatus struct {
+	Paused  bool       `json:"paused"`
+	Channel int        `json:"channel,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+func (p *pauseState) status() pauseStatus {
+	if p.lock.channel == 0 {
+		return pauseStatus{}
+	}
+	until := p.lock.until
+	return pauseStatus{Paused: true, Channel: p.lock.channel, Until: &until}
+}
+
+// pause locks the shared plan to channel for d from now, replacing the
+// remaining time of a pause on the same channel.
+func (p *pauseState) pause(shared *sharedPlan, channel int, d time.Duration, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lock.channel != 0 && p.lock.channel != channel {
+		return fmt.Errorf("already paused on channel %v", p.lock.channel)
+	}
+	p.lock.plan = shared
+	p.lock.reason = "pause"
+	p.lock.timeout = d
+	if err := p.lock.lock(channel, now); err != nil {
+		return err
+	}
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.generation++
+	generation := p.generation
+	p.timer = time.AfterFunc(d, func() { p.expire(generation) })
+	return nil
+}
+
+// expire lifts the pause started as generation, unless it was replaced
+// since.
+func (p *pauseState) expire(generation int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if generation != p.generation || p.lock.channel == 0 {
+		return
+	}
+	p.timer = nil
+	if err := p.lock.release(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot resume hopping: %v\n", err)
+		return
+	}
+	_, _ = fmt.Fprintf(stderr, "Pause expired, resuming hopping\n")
+}
+
+// resume lifts the pause, if any.
+func (p *pauseState) resume() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	return p.lock.release()
+}
+
+// parsePause parses the duration of a pause: a Go duration such as 90s or
+// 5m, or a number of seconds.
+func parsePause(input string) (time.Duration, error) {
+	d, err := time.ParseDuration(input)
+	if err != nil {
+		seconds, err := strconv.Atoi(input)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", input)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d <= 0 || d > maxPause {
+		return 0, fmt.Errorf("the duration must be between 0 and %v", maxPause)
+	}
+	return d, nil
+}
+
+// handlePause returns whether hopping is paused or, on POST, pauses it on
+// the current channel for the duration form value.
+func (a *controlAPI) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if a.currentHopper() == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{"not hopping"})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		d, err := parsePause(r.FormValue("duration"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		a.mu.Lock()
+		channel := a.channel
+		shared := a.shared
+		a.mu.Unlock()
+		if channel == 0 {
+			writeJSON(w, http.StatusServiceUnavailable, apiError{"no channel set yet"})
+			return
+		}
+		if err := a.pause.pause(shared, channel, d, a.now()); err != nil {
+			writeJSON(w, http.StatusConflict, apiError{err.Error()})
+			return
+		}
+		_, _ = fmt.Fprintf(stderr, "Paused on channel %v for %v\n", channel, d)
+	}
+
+	a.pause.mu.Lock()
+	status := a.pause.status()
+	a.pause.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleResume lifts a pause before it expires.
+func (a *controlAPI) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := a.pause.resume(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, pauseStatus{})
+}
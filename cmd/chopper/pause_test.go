@@ -0,0 +1,128 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+func TestParsePause(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"30", 30 * time.Second},
+		{"1h", time.Hour},
+	}
+	for _, test := range tests {
+		if got, err := parsePause(test.input); err != nil || got != test.want {
+			t.Errorf("parsePause(%q) = %v, %v, want %v", test.input, got, err, test.want)
+		}
+	}
+
+	for _, input := range []string{"", "0", "-5s", "2h", "soon"} {
+		if _, err := parsePause(input); err == nil {
+			t.Errorf("parsePause(%q) did not fail", input)
+		}
+	}
+}
+
+func postForm(handler http.Handler, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPause(t *testing.T) {
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+		Channels: []int{1, 6, 11},
+		Delay:    100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newControlAPI(10, 100*time.Millisecond, 0)
+	handler := api.handler()
+
+	// Nothing to freeze before the hopper runs
+	if rec := postForm(handler, "/pause", url.Values{"duration": {"1m"}}); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /pause without hopper = %v", rec.Code)
+	}
+	api.setHopper(h, newSharedPlan(h))
+	api.hop(6)
+
+	if rec := postForm(handler, "/pause", url.Values{"duration": {"2h"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /pause over the maximum = %v", rec.Code)
+	}
+
+	rec := postForm(handler, "/pause", url.Values{"duration": {"50ms"}})
+	var status pauseStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || !status.Paused || status.Channel != 6 || status.Until == nil {
+		t.Errorf("POST /pause: %v %v", rec.Code, rec.Body.String())
+	}
+	if !reflect.DeepEqual(h.Channels(), []int{6}) {
+		t.Errorf("plan %v while paused, want [6]", h.Channels())
+	}
+
+	// The hopper is frozen on 6, a pause elsewhere conflicts
+	api.hop(11)
+	if rec := postForm(handler, "/pause", url.Values{"duration": {"1m"}}); rec.Code != http.StatusConflict {
+		t.Errorf("POST /pause on another channel = %v", rec.Code)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !reflect.DeepEqual(h.Channels(), []int{1, 6, 11}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("plan %v after the pause expired", h.Channels())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Resuming early restores the plan and stops the timer
+	api.hop(1)
+	postForm(handler, "/pause", url.Values{"duration": {"1m"}})
+	if rec := postForm(handler, "/resume", nil); rec.Code != http.StatusOK {
+		t.Errorf("POST /resume = %v", rec.Code)
+	}
+	if !reflect.DeepEqual(h.Channels(), []int{1, 6, 11}) {
+		t.Errorf("plan %v after resuming", h.Channels())
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	if strings.TrimSpace(rec.Body.String()) != `{"paused":false}` {
+		t.Errorf("GET /pause after resuming: %v", rec.Body.String())
+	}
+}
@@ -0,0 +1,472 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/pcapng"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+const (
+	// pcapSnapLen is the largest frame stored.
+	pcapSnapLen = 65535
+	// timelineLength is the number of hops remembered to attribute frames
+	// read late to the channel they were received on.
+	timelineLength = 256
+	// lateFrames is how long after a hop frames received before it may
+	// still be read.
+	lateFrames = time.Second
+)
+
+// hopSegment is a time span the radio spent tuned to channel. to is zero
+// while the radio is still on it.
+type hopSegment struct {
+	channel int
+	from    time.Time
+	to      time.Time
+	// frames is the number of frames attributed to the segment.
+	frames uint64
+	// reported is set once the segment is returned by ended.
+	reported bool
+}
+
+// hopTimeline records when the radio was on each channel, so that frames
+// can be attributed by the time the kernel received them rather than by
+// when they are read.
+type hopTimeline struct {
+	mu       sync.Mutex
+	segments []hopSegment
+}
+
+// leave records that the radio starts retuning at now.
+func (t *hopTimeline) leave(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if n := len(t.segments); n > 0 && t.segments[n-1].to.IsZero() {
+		t.segments[n-1].to = now
+	}
+}
+
+// arrive records that the radio is tuned to channel since now.
+func (t *hopTimeline) arrive(channel int, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if n := len(t.segments); n > 0 && t.segments[n-1].to.IsZero() {
+		t.segments[n-1].to = now
+	}
+	t.segments = append(t.segments, hopSegment{channel: channel, from: now})
+	if len(t.segments) > timelineLength {
+		t.segments = t.segments[len(t.segments)-timelineLength:]
+	}
+}
+
+// channelAt returns the channel the radio was tuned to at ts. It returns
+// false while the radio was retuning or if ts is too old.
+func (t *hopTimeline) channelAt(ts time.Time) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.find(ts)
+	if i < 0 {
+		return 0, false
+	}
+	return t.segments[i].channel, true
+}
+
+// attribute counts a frame received at ts and returns the segment it was
+// received in.
+func (t *hopTimeline) attribute(ts time.Time) (hopSegment, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.find(ts)
+	if i < 0 {
+		return hopSegment{}, false
+	}
+	t.segments[i].frames++
+	return t.segments[i], true
+}
+
+// ended returns the segments that ended before, once.
+func (t *hopTimeline) ended(before time.Time) []hopSegment {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var segments []hopSegment
+	for i := range t.segments {
+		s := &t.segments[i]
+		if !s.reported && !s.to.IsZero() && s.to.Before(before) {
+			s.reported = true
+			segments = append(segments, *s)
+		}
+	}
+	return segments
+}
+
+// find returns the index of the segment containing ts, or -1.
+func (t *hopTimeline) find(ts time.Time) int {
+	for i := len(t.segments) - 1; i >= 0; i-- {
+		s := t.segments[i]
+		if ts.Before(s.from) {
+			continue
+		}
+		if !s.to.IsZero() && !ts.Before(s.to) {
+			return -1
+		}
+		return i
+	}
+	return -1
+}
+
+// channelWriter stores frames by channel, annotated with the hops.
+type channelWriter interface {
+	// write stores a frame, with comment if not empty.
+	write(channel int, ts time.Time, data []byte, length int, comment string) error
+	// dwell annotates the time spent on a channel.
+	dwell(s hopSegment) error
+	flush() error
+	close() error
+}
+
+// discardFrames is the channelWriter of a capture that only observes
+// frames.
+type discardFrames struct{}
+
+func (discardFrames) write(int, time.Time, []byte, int, string) error { return nil }
+func (discardFrames) dwell(hopSegment) error                          { return nil }
+func (discardFrames) flush() error                                    { return nil }
+func (discardFrames) close() error                                    { return nil }
+
+// pcapFile is an open pcapng file.
+type pcapFile struct {
+	f   *os.File
+	buf *bufio.Writer
+	w   *pcapng.Writer
+}
+
+func createPcapFile(path string) (*pcapFile, error) {
+	// Appending to an existing capture starts a new section
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	w, err := pcapng.NewWriter(buf, fmt.Sprintf("%s v%s", ProgramName, Version))
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &pcapFile{f: f, buf: buf, w: w}, nil
+}
+
+func (p *pcapFile) close() error {
+	if err := p.buf.Flush(); err != nil {
+		_ = p.f.Close()
+		return err
+	}
+	return p.f.Close()
+}
+
+// channelInterface describes the interface of the frames of a channel.
+func channelInterface(iface string, channel int) pcapng.Interface {
+	return pcapng.Interface{
+		LinkType:    pcapng.LinkTypeIEEE80211Radiotap,
+		SnapLen:     pcapSnapLen,
+		Name:        iface,
+		Description: fmt.Sprintf("channel %v, %v MHz", plan.FormatChannel(channel), plan.Frequency(channel)),
+	}
+}
+
+// hopComment is stored with the first frame received after a hop.
+func hopComment(s hopSegment) string {
+	return fmt.Sprintf("hop to channel %v, %v MHz at %v", plan.FormatChannel(s.channel), plan.Frequency(s.channel), s.from.UTC().Format(time.RFC3339Nano))
+}
+
+// dwellStatistics describes the time spent on a channel.
+func dwellStatistics(s hopSegment) pcapng.Statistics {
+	return pcapng.Statistics{
+		Start:    s.from,
+		End:      s.to,
+		Received: s.frames,
+		Comment:  fmt.Sprintf("channel %v, %v MHz for %v", plan.FormatChannel(s.channel), plan.Frequency(s.channel), s.to.Sub(s.from).Round(time.Microsecond)),
+	}
+}
+
+// pcapDir writes the frames of each channel to their own file,
+// <iface>-<channel>.pcapng.
+type pcapDir struct {
+	dir   string
+	iface string
+	files map[int]*pcapFile
+}
+
+func newPcapDir(dir, iface string) (*pcapDir, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &pcapDir{dir: dir, iface: iface, files: make(map[int]*pcapFile)}, nil
+}
+
+// file returns the file of channel, creating it if needed.
+func (d *pcapDir) file(channel int) (*pcapFile, error) {
+	if file, ok := d.files[channel]; ok {
+		return file, nil
+	}
+	path := filepath.Join(d.dir, fmt.Sprintf("%s-%s.pcapng", d.iface, plan.FormatChannel(channel)))
+	file, err := createPcapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.w.AddInterface(channelInterface(d.iface, channel)); err != nil {
+		_ = file.close()
+		return nil, err
+	}
+	d.files[channel] = file
+	return file, nil
+}
+
+func (d *pcapDir) write(channel int, ts time.Time, data []byte, length int, comment string) error {
+	file, err := d.file(channel)
+	if err != nil {
+		return err
+	}
+	return file.w.WritePacket(0, ts, data, length, comment)
+}
+
+func (d *pcapDir) dwell(s hopSegment) error {
+	file, err := d.file(s.channel)
+	if err != nil {
+		return err
+	}
+	return file.w.WriteStatistics(0, dwellStatistics(s))
+}
+
+func (d *pcapDir) flush() error {
+	for _, file := range d.files {
+		if err := file.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *pcapDir) close() error {
+	var first error
+	for _, file := range d.files {
+		if err := file.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// pcapMerged writes all the frames to a single file, with an interface
+// per channel so that every frame references the channel it was received
+// on.
+type pcapMerged struct {
+	file  *pcapFile
+	iface string
+	ids   map[int]int
+}
+
+func newPcapMerged(path, iface string) (*pcapMerged, error) {
+	file, err := createPcapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &pcapMerged{file: file, iface: iface, ids: make(map[int]int)}, nil
+}
+
+// id returns the interface of channel, describing it if needed.
+func (s *pcapMerged) id(channel int) (int, error) {
+	if id, ok := s.ids[channel]; ok {
+		return id, nil
+	}
+	id, err := s.file.w.AddInterface(channelInterface(s.iface, channel))
+	if err != nil {
+		return 0, err
+	}
+	s.ids[channel] = id
+	return id, nil
+}
+
+func (s *pcapMerged) write(channel int, ts time.Time, data []byte, length int, comment string) error {
+	id, err := s.id(channel)
+	if err != nil {
+		return err
+	}
+	return s.file.w.WritePacket(id, ts, data, length, comment)
+}
+
+func (s *pcapMerged) dwell(segment hopSegment) error {
+	id, err := s.id(segment.channel)
+	if err != nil {
+		return err
+	}
+	return s.file.w.WriteStatistics(id, dwellStatistics(segment))
+}
+
+func (s *pcapMerged) flush() error {
+	return s.file.buf.Flush()
+}
+
+func (s *pcapMerged) close() error {
+	return s.file.close()
+}
+
+// channelCapture captures frames on the hopping interface and stores them
+// by the channel they were received on. Frames received while retuning are
+// dropped. The first frame of every dwell carries a comment marking the hop
+// and every dwell is annotated with interface statistics once it ends.
+type channelCapture struct {
+	capture   *captureSocket
+	timeline  hopTimeline
+	out       channelWriter
+	observers []frameObserver
+	done      chan struct{}
+	wg        sync.WaitGroup
+
+	frames   int64
+	retuning int64
+}
+
+// frameObserver is called by the capture with every frame attributed to a
+// channel that can be decoded.
+type frameObserver func(channel int, packet dot11.Packet)
+
+// startChannelCapture starts capturing to out, passing the frames to the
+// observers.
+func startChannelCapture(ifindex int, out channelWriter, observers []frameObserver) (*channelCapture, error) {
+	capture, err := openCapture(ifindex, 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	if err := capture.EnableTimestamps(); err != nil {
+		_ = capture.Close()
+		return nil, err
+	}
+
+	c := &channelCapture{capture: capture, out: out, observers: observers, done: make(chan struct{})}
+	c.wg.Add(1)
+	go c.loop()
+	return c, nil
+}
+
+// beforeHop is registered as a BeforeHop callback.
+func (c *channelCapture) beforeHop(int) {
+	c.timeline.leave(time.Now())
+}
+
+// hop is registered as an OnHop callback.
+func (c *channelCapture) hop(channel int) {
+	c.timeline.arrive(channel, time.Now())
+}
+
+func (c *channelCapture) loop() {
+	defer c.wg.Done()
+
+	buf := make([]byte, pcapSnapLen)
+	lastFlush := time.Now()
+	warned := false
+	// annotated records the dwells whose first frame was already written
+	annotated := make(map[time.Time]bool)
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		n, ts, err := c.capture.ReadTimestamp(buf)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot read frame: %v\n", err)
+			return
+		}
+		if n > 0 {
+			if segment, ok := c.timeline.attribute(ts); ok {
+				captured := n
+				if captured > len(buf) {
+					captured = len(buf)
+				}
+				comment := ""
+				if !annotated[segment.from] {
+					comment = hopComment(segment)
+					annotated[segment.from] = true
+				}
+				err = c.out.write(segment.channel, ts, buf[:captured], n, comment)
+				if len(c.observers) > 0 {
+					if packet, err := dot11.Decode(buf[:captured]); err == nil {
+						for _, observe := range c.observers {
+							observe(segment.channel, packet)
+						}
+					}
+				}
+				atomic.AddInt64(&c.frames, 1)
+			} else {
+				atomic.AddInt64(&c.retuning, 1)
+			}
+		}
+		if err == nil {
+			for _, segment := range c.timeline.ended(time.Now().Add(-lateFrames)) {
+				delete(annotated, segment.from)
+				if err = c.out.dwell(segment); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && time.Since(lastFlush) >= time.Second {
+			err = c.out.flush()
+			lastFlush = time.Now()
+		}
+		if err != nil && !warned {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot write capture: %v\n", err)
+			warned = true
+		}
+	}
+}
+
+// Close stops capturing and closes the capture files. It reports how many
+// frames were written and how many were dropped as they were received
+// while retuning.
+func (c *channelCapture) Close() (frames, retuning int64, err error) {
+	close(c.done)
+	c.wg.Wait()
+	_ = c.capture.Close()
+
+	now := time.Now()
+	c.timeline.leave(now)
+	for _, segment := range c.timeline.ended(now.Add(time.Nanosecond)) {
+		if err = c.out.dwell(segment); err != nil {
+			break
+		}
+	}
+	if closeErr := c.out.close(); err == nil {
+		err = closeErr
+	}
+	return atomic.LoadInt64(&c.frames), atomic.LoadInt64(&c.retuning), err
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/pcapng"
+)
+
+func TestHopTimeline(t *testing.T) {
+	base := time.Unix(1633089600, 0)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	var timeline hopTimeline
+	timeline.arrive(1, at(0))
+	timeline.leave(at(100))
+	timeline.arrive(6, at(102))
+	timeline.leave(at(200))
+	timeline.arrive(11, at(203))
+
+	tests := []struct {
+		ms      int
+		channel int
+		ok      bool
+	}{
+		{-1, 0, false},
+		{0, 1, true},
+		{99, 1, true},
+		{100, 0, false},
+		{101, 0, false},
+		{102, 6, true},
+		{199, 6, true},
+		{201, 0, false},
+		{203, 11, true},
+		{10000, 11, true},
+	}
+
+	for _, tt := range tests {
+		channel, ok := timeline.channelAt(at(tt.ms))
+		if channel != tt.channel || ok != tt.ok {
+			t.Fatalf("channelAt(%vms):\n- want: %v, %v\n-  got: %v, %v", tt.ms, tt.channel, tt.ok, channel, ok)
+		}
+	}
+}
+
+func TestHopTimelineEnded(t *testing.T) {
+	base := time.Unix(1633089600, 0)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	var timeline hopTimeline
+	timeline.arrive(1, at(0))
+	timeline.attribute(at(10))
+	timeline.attribute(at(20))
+	timeline.leave(at(100))
+	timeline.arrive(6, at(102))
+
+	if ended := timeline.ended(at(100)); len(ended) != 0 {
+		t.Fatalf("ended too early: %v", ended)
+	}
+	ended := timeline.ended(at(1100))
+	if len(ended) != 1 || ended[0].channel != 1 || ended[0].frames != 2 || !ended[0].to.Equal(at(100)) {
+		t.Fatalf("ended: %+v", ended)
+	}
+	if ended := timeline.ended(at(1100)); len(ended) != 0 {
+		t.Fatalf("ended twice: %v", ended)
+	}
+
+	want := pcapng.Statistics{Start: at(0), End: at(100), Received: 2, Comment: "channel 1, 2412 MHz for 100ms"}
+	if got := dwellStatistics(ended[0]); got != want {
+		t.Fatalf("dwellStatistics:\n- want: %+v\n-  got: %+v", want, got)
+	}
+	if got, want := hopComment(ended[0]), "hop to channel 1, 2412 MHz at 2021-10-01T12:00:00Z"; got != want {
+		t.Fatalf("hopComment:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestHopTimelineLength(t *testing.T) {
+	base := time.Unix(1633089600, 0)
+	var timeline hopTimeline
+	for i := 0; i < timelineLength+10; i++ {
+		timeline.arrive(1+i%11, base.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(timeline.segments) != timelineLength {
+		t.Fatalf("segments:\n- want: %v\n-  got: %v", timelineLength, len(timeline.segments))
+	}
+	if _, ok := timeline.channelAt(base); ok {
+		t.Fatal("a forgotten hop was used")
+	}
+}
+
+// pcapngBlocks returns the block types of a pcapng file and the interface
+// ids of its packets.
+func pcapngBlocks(t *testing.T, path string) (types []uint32, ids []uint32) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(data) >= 12 {
+		typ := binary.LittleEndian.Uint32(data)
+		length := binary.LittleEndian.Uint32(data[4:])
+		types = append(types, typ)
+		if typ == 6 {
+			ids = append(ids, binary.LittleEndian.Uint32(data[8:]))
+		}
+		data = data[length:]
+	}
+	if len(data) != 0 {
+		t.Fatalf("%v has %v trailing bytes", path, len(data))
+	}
+	return types, ids
+}
+
+func TestPcapDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "captures")
+	out, err := newPcapDir(dir, "wlan0mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, channel := range []int{1, 6, 1} {
+		if err := out.write(channel, now, []byte{0, 0, 8, 0, 0, 0, 0, 0}, 8, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	types, _ := pcapngBlocks(t, filepath.Join(dir, "wlan0mon-1.pcapng"))
+	if want := []uint32{0x0a0d0d0a, 1, 6, 6}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("channel 1 blocks:\n- want: %x\n-  got: %x", want, types)
+	}
+	types, _ = pcapngBlocks(t, filepath.Join(dir, "wlan0mon-6.pcapng"))
+	if want := []uint32{0x0a0d0d0a, 1, 6}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("channel 6 blocks:\n- want: %x\n-  got: %x", want, types)
+	}
+}
+
+func TestPcapMerged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	out, err := newPcapMerged(path, "wlan0mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, channel := range []int{1, 6, 1, 11} {
+		if err := out.write(channel, now, []byte{0, 0, 8, 0, 0, 0, 0, 0}, 8, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.dwell(hopSegment{channel: 6, from: now, to: now.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.dwell(hopSegment{channel: 36, from: now, to: now.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	types, ids := pcapngBlocks(t, path)
+	if want := []uint32{0x0a0d0d0a, 1, 6, 1, 6, 6, 1, 6, 5, 1, 5}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("blocks:\n- want: %x\n-  got: %x", want, types)
+	}
+	if want := []uint32{0, 1, 0, 2}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("interface ids:\n- want: %v\n-  got: %v", want, ids)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// planFileFlags are the flags deciding what a --plan-file records.
+var planFileFlags = []string{"channels", "plan", "schedule", "start-channel", "interleave", "strategy", "seed", "delay"}
+
+// planFileConflict returns the first flag passed that --plan-file
+// replaces, "" if there is none.
+func planFileConflict() string {
+	for _, name := range planFileFlags {
+		if isFlagPassed(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// validPlanFormat reports whether format is one of plan.FileFormats.
+func validPlanFormat(format string) bool {
+	for _, f := range plan.FileFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPlanFile reads a --plan-file. Only 20 MHz hops are supported, and
+// dwells are decided per channel, so a channel listed more than once must
+// have the same dwell everywhere.
+func loadPlanFile(path string) (*plan.Resolved, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := plan.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dwells := make(map[int]time.Duration)
+	for _, hop := range r.Hops {
+		channel := plan.FormatChannel(hop.Channel)
+		if hop.Width != 20 {
+			return nil, fmt.Errorf("channel %v is %v MHz wide, only 20 MHz hops are supported", channel, hop.Width)
+		}
+		if dwell, ok := dwells[hop.Channel]; ok && dwell != hop.Dwell {
+			return nil, fmt.Errorf("channel %v has dwells of %v and %v, it can only have one", channel, dwell, hop.Dwell)
+		}
+		dwells[hop.Channel] = hop.Dwell
+	}
+	return r, nil
+}
+
+// shortestDwell returns the shortest dwell of the hops of r.
+func shortestDwell(r *plan.Resolved) time.Duration {
+	shortest := r.Hops[0].Dwell
+	for _, hop := range r.Hops {
+		if hop.Dwell < shortest {
+			shortest = hop.Dwell
+		}
+	}
+	return shortest
+}
+
+// planDwell scales the delay on every channel of r by its dwell relative to
+// the first hop, whose dwell is the delay, so changes of the delay keep the
+// proportions. dwell is returned as is when all the hops dwell the same.
+func planDwell(r *plan.Resolved, dwell hopper.DwellController, strategy hopper.Strategy) hopper.DwellController {
+	base := r.Hops[0].Dwell
+	ratios := make(map[int]float64)
+	uniform := true
+	for _, hop := range r.Hops {
+		ratios[hop.Channel] = float64(hop.Dwell) / float64(base)
+		uniform = uniform && hop.Dwell == base
+	}
+	if uniform {
+		return dwell
+	}
+
+	if dwell == nil {
+		if d, ok := strategy.(hopper.DwellStrategy); ok {
+			dwell = d
+		} else {
+			dwell = hopper.FixedDwell{}
+		}
+	}
+	return hopper.DwellFunc(func(channel int, delay time.Duration) time.Duration {
+		// Channels not in the plan dwell for the delay
+		if ratio, ok := ratios[channel]; ok {
+			delay = time.Duration(float64(delay) * ratio)
+		}
+		return dwell.Dwell(channel, delay)
+	})
+}
+
+// dryRunPlan returns the plan --dry-run prints: the one of --plan-file, or
+// channels for the delay in ms each, with the seed actually used.
+func dryRunPlan(resolved *plan.Resolved, channels []int, delay int, strategy string, seed int64) *plan.Resolved {
+	if resolved == nil {
+		return plan.NewResolved(channels, time.Duration(delay)*time.Millisecond, strategy, seed)
+	}
+
+	r := *resolved
+	r.Strategy = strategy
+	r.Seed = seed
+	return &r
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func writePlanFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPlanFile(t *testing.T) {
+	r, err := loadPlanFile(writePlanFile(t, `version: 1
+strategy: shuffle
+seed: 9
+hops:
+  - channel: 1
+    dwell_ms: 100
+  - channel: 36
+    dwell_ms: 300
+  - channel: 1
+    dwell_ms: 100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Channels(); !reflect.DeepEqual(got, []int{1, 36, 1}) {
+		t.Errorf("channels = %v", got)
+	}
+	if r.Strategy != "shuffle" || r.Seed != 9 {
+		t.Errorf("strategy %q, seed %v", r.Strategy, r.Seed)
+	}
+	if got := shortestDwell(r); got != 100*time.Millisecond {
+		t.Errorf("shortestDwell() = %v", got)
+	}
+
+	for content, want := range map[string]string{
+		"version: 1\nhops:\n  - channel: 36\n    width: 80\n    dwell_ms: 100\n":                    "only 20 MHz",
+		"version: 1\nhops:\n  - channel: 1\n    dwell_ms: 100\n  - channel: 1\n    dwell_ms: 200\n": "dwells of 100ms and 200ms",
+		"version: 1\n": "no hops",
+	} {
+		if _, err := loadPlanFile(writePlanFile(t, content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loadPlanFile(%q) error = %v, want %q", content, err, want)
+		}
+	}
+	if _, err := loadPlanFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("loadPlanFile(missing) did not fail")
+	}
+}
+
+func TestPlanDwell(t *testing.T) {
+	r := plan.NewResolved([]int{1, 6, 11}, 100*time.Millisecond, "", 0)
+	if got := planDwell(r, nil, nil); got != nil {
+		t.Errorf("planDwell() with uniform dwells = %v, want nil", got)
+	}
+
+	r.Hops[1].Dwell = 300 * time.Millisecond
+	dwell := planDwell(r, nil, nil)
+	for channel, want := range map[int]time.Duration{1: 200 * time.Millisecond, 6: 600 * time.Millisecond, 36: 200 * time.Millisecond} {
+		// The delay was doubled at runtime
+		if got := dwell.Dwell(channel, 200*time.Millisecond); got != want {
+			t.Errorf("Dwell(%v) = %v, want %v", channel, got, want)
+		}
+	}
+
+	// The other dwell controllers see the scaled delay
+	halved := hopper.DwellFunc(func(channel int, delay time.Duration) time.Duration {
+		return delay / 2
+	})
+	if got := planDwell(r, halved, nil).Dwell(6, 100*time.Millisecond); got != 150*time.Millisecond {
+		t.Errorf("Dwell(6) = %v, want 150ms", got)
+	}
+}
+
+func TestDryRunPlan(t *testing.T) {
+	got := dryRunPlan(nil, []int{1, 6}, 150, "sequential", 3)
+	want := plan.NewResolved([]int{1, 6}, 150*time.Millisecond, "sequential", 3)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dryRunPlan():\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	loaded := plan.NewResolved([]int{11}, 200*time.Millisecond, "", 0)
+	got = dryRunPlan(loaded, []int{11}, 200, "shuffle", 42)
+	if got.Strategy != "shuffle" || got.Seed != 42 || !reflect.DeepEqual(got.Hops, loaded.Hops) {
+		t.Errorf("dryRunPlan() = %+v", got)
+	}
+	if loaded.Seed != 0 {
+		t.Errorf("dryRunPlan() changed the loaded plan")
+	}
+
+	if !validPlanFormat("yaml") || validPlanFormat("toml") {
+		t.Errorf("validPlanFormat() is wrong")
+	}
+}
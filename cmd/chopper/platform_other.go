@@ -0,0 +1,74 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// errPlatform is returned by what needs Linux, such as capturing frames.
+var errPlatform = fmt.Errorf("not supported on %v", runtime.GOOS)
+
+// There are no signals to change the delay.
+var slowerSignal, fasterSignal os.Signal
+
+// isTerminal reports whether f is a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func terminalWidth(f *os.File) int {
+	return 80
+}
+
+func firmwareVersion(name string) (string, error) {
+	return "", errPlatform
+}
+
+// suspendedTime is always 0: suspends are not detected.
+func suspendedTime() (time.Duration, error) {
+	return 0, nil
+}
+
+// tryLock does not lock: there is no interface to share.
+func tryLock(f *os.File) (busy bool, err error) {
+	return false, nil
+}
+
+func mkfifo(path string, mode uint32) error {
+	return fmt.Errorf("named pipes are %w", errPlatform)
+}
+
+func stopProcess(pid int) error {
+	return errPlatform
+}
+
+func detach(cmd *exec.Cmd) {}
+
+func ownProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// maxPMKIDNetworks bounds the SSIDs and PMKIDs remembered by pmkidDetector.
+const maxPMKIDNetworks = 4096
+
+// pmkidDetector finds the PMKIDs sent by APs in the first message of a
+// handshake, or by stations in association requests. It is used by the
+// capture goroutine only.
+type pmkidDetector struct {
+	// ssids are the SSIDs of the beacons seen, by BSSID
+	ssids map[string]string
+	seen  map[string]bool
+}
+
+func newPMKIDDetector() *pmkidDetector {
+	return &pmkidDetector{ssids: make(map[string]string), seen: make(map[string]bool)}
+}
+
+// observe returns the PMKID in a captured packet, if it was not seen yet.
+func (d *pmkidDetector) observe(channel int, packet dot11.Packet) (eapolFrame, bool) {
+	f := packet.Frame
+	var frame eapolFrame
+	switch {
+	case f.Type == dot11.TypeManagement && f.Subtype == dot11.SubtypeBeacon:
+		beacon, err := dot11.ParseBeacon(f.Body)
+		if err == nil && beacon.SSID() != "" && (len(d.ssids) < maxPMKIDNetworks || d.ssids[f.Addr3.String()] != "") {
+			d.ssids[f.Addr3.String()] = beacon.SSID()
+		}
+		return frame, false
+	case f.Type == dot11.TypeManagement && (f.Subtype == dot11.SubtypeAssociationRequest || f.Subtype == dot11.SubtypeReassociationRequest):
+		elements, err := dot11.ParseAssociationRequest(f.Body, f.Subtype == dot11.SubtypeReassociationRequest)
+		if err != nil {
+			return frame, false
+		}
+		rsn, ok := dot11.FindElement(elements, dot11.ElementRSN)
+		pmkids := dot11.RSNPMKIDs(rsn)
+		if !ok || len(pmkids) == 0 {
+			return frame, false
+		}
+		if ssid := dot11.SSID(elements); ssid != "" {
+			d.ssids[f.Addr1.String()] = ssid
+		}
+		frame = eapolFrame{channel: channel, ap: f.Addr1.String(), station: f.Addr2.String(), pmkid: pmkids[0]}
+	default:
+		var ok bool
+		if frame, ok = decodeEAPOL(channel, packet); !ok || frame.pmkid == nil {
+			return frame, false
+		}
+	}
+
+	key := frame.ap + " " + frame.station + " " + hex.EncodeToString(frame.pmkid)
+	if d.seen[key] || len(d.seen) >= maxPMKIDNetworks {
+		return frame, false
+	}
+	d.seen[key] = true
+	return frame, true
+}
+
+// pmkidEvent returns the event reporting a PMKID.
+func (d *pmkidDetector) pmkidEvent(frame eapolFrame) events.Event {
+	event := events.New(events.TypePMKID)
+	event.Channel = frame.channel
+	event.Frequency = plan.Frequency(frame.channel)
+	event.BSSID = frame.ap
+	event.SSID = d.ssids[frame.ap]
+	event.Station = frame.station
+	event.PMKID = hex.EncodeToString(frame.pmkid)
+	return event
+}
+
+// hashcatLine returns a PMKID in the hashcat 22000 format. The event must
+// have the SSID.
+func hashcatLine(event events.Event) string {
+	mac := func(addr string) string {
+		return strings.Replace(addr, ":", "", -1)
+	}
+	return fmt.Sprintf("WPA*01*%s*%s*%s*%s***\n", event.PMKID, mac(event.BSSID), mac(event.Station), hex.EncodeToString([]byte(event.SSID)))
+}
+
+// reportPMKID prints a PMKID, appends it to w in hashcat format if w is not
+// nil and the SSID is known, and emits its event.
+func reportPMKID(event events.Event, w io.Writer) {
+	ssid := event.SSID
+	if ssid == "" {
+		ssid = "unknown SSID"
+	}
+	_, _ = fmt.Fprintf(stderr, "PMKID: %v (%v) for %v on channel %v\n", describeMAC(event.BSSID), ssid, describeMAC(event.Station), plan.FormatChannel(event.Channel))
+	if w != nil && event.SSID != "" {
+		if _, err := io.WriteString(w, hashcatLine(event)); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot write PMKID: %v\n", err)
+		}
+	}
+	emit(event)
+}
+
+// observeCapture returns the function observing the frames of the capture.
+// PMKIDs are passed to report and, like handshake messages if eapol is set,
+// sent to handshakes if it is not nil.
+func observeCapture(handshakes chan<- eapolFrame, eapol bool, report func(events.Event)) frameObserver {
+	detector := newPMKIDDetector()
+	send := func(frame eapolFrame) {
+		// Frames are dropped rather than delaying the capture
+		select {
+		case handshakes <- frame:
+		default:
+		}
+	}
+
+	return func(channel int, packet dot11.Packet) {
+		frame, found := detector.observe(channel, packet)
+		if found {
+			report(detector.pmkidEvent(frame))
+		}
+		if handshakes == nil {
+			return
+		}
+		if eapol {
+			if message, ok := decodeEAPOL(channel, packet); ok {
+				send(message)
+				return
+			}
+		}
+		if found {
+			send(frame)
+		}
+	}
+}
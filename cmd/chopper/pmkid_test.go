@@ -0,0 +1,129 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+)
+
+// testPMKIDKeyData is the key data of a message 1 with a PMKID.
+var testPMKIDKeyData = append([]byte{dot11.ElementVendorSpecific, 20, 0x00, 0x0f, 0xac, 0x04}, bytes.Repeat([]byte{0xab}, 16)...)
+
+func TestPMKIDDetector(t *testing.T) {
+	d := newPMKIDDetector()
+	packet, err := dot11.Decode(testBeacon(testAP, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.observe(6, packet); ok {
+		t.Fatal("PMKID in a beacon")
+	}
+	packet, err = dot11.Decode(testEAPOLFrame(0x008a, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.observe(6, packet); ok {
+		t.Fatal("PMKID in a message 1 without key data")
+	}
+
+	packet, err = dot11.Decode(testEAPOLFrameData(0x008a, true, testPMKIDKeyData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, ok := d.observe(6, packet)
+	if !ok {
+		t.Fatal("PMKID not found")
+	}
+	if _, ok := d.observe(6, packet); ok {
+		t.Fatal("PMKID reported twice")
+	}
+
+	event := d.pmkidEvent(frame)
+	if event.Type != events.TypePMKID || event.BSSID != "00:11:22:33:44:55" || event.Station != "02:00:00:00:00:01" || event.Channel != 6 || event.Frequency != 2437 {
+		t.Fatalf("pmkidEvent: %+v", event)
+	}
+	if event.SSID != "test" || event.PMKID != "abababababababababababababababab" {
+		t.Fatalf("pmkidEvent: ssid %q, pmkid %q", event.SSID, event.PMKID)
+	}
+
+	want := "WPA*01*abababababababababababababababab*001122334455*020000000001*74657374***\n"
+	if got := hashcatLine(event); got != want {
+		t.Fatalf("hashcatLine:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestPMKIDDetectorAssociation(t *testing.T) {
+	pmkid := bytes.Repeat([]byte{0xcd}, 16)
+	rsn := []byte{1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 2, 0, 0, 1, 0}
+	rsn = append(rsn, pmkid...)
+
+	b := append(append([]byte{}, radiotapHeader...), 0x00, 0x00, 0, 0)
+	b = append(b, testAP...)
+	b = append(b, testStation...)
+	b = append(b, testAP...)
+	b = append(b, 0, 0, 0x11, 0x04, 0x0a, 0x00, 0, 4, 'c', 'a', 'f', 'e', dot11.ElementRSN, byte(len(rsn)))
+	b = append(b, rsn...)
+	packet, err := dot11.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newPMKIDDetector()
+	frame, ok := d.observe(36, packet)
+	if !ok || !bytes.Equal(frame.pmkid, pmkid) || frame.ap != "00:11:22:33:44:55" || frame.station != "02:00:00:00:00:01" {
+		t.Fatalf("observe: %+v, %v", frame, ok)
+	}
+	if event := d.pmkidEvent(frame); event.SSID != "cafe" {
+		t.Fatalf("pmkidEvent ssid: %q", event.SSID)
+	}
+}
+
+func TestObserveCapture(t *testing.T) {
+	handshakes := make(chan eapolFrame, 4)
+	var reported []events.Event
+	observer := observeCapture(handshakes, false, func(event events.Event) {
+		reported = append(reported, event)
+	})
+	observe := func(channel int, b []byte) {
+		packet, err := dot11.Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		observer(channel, packet)
+	}
+
+	// Handshake messages are only sent with eapol set
+	observe(6, testEAPOLFrame(0x008a, true))
+	observe(6, testEAPOLFrameData(0x008a, true, testPMKIDKeyData))
+	if len(reported) != 1 || len(handshakes) != 1 {
+		t.Fatalf("observeCapture: %v reported, %v sent", len(reported), len(handshakes))
+	}
+	if frame := <-handshakes; frame.pmkid == nil {
+		t.Fatalf("observeCapture sent %+v", frame)
+	}
+
+	observer = observeCapture(handshakes, true, func(events.Event) {})
+	observe(6, testEAPOLFrame(0x008a, true))
+	observe(6, testEAPOLFrame(0x010a, false))
+	if len(handshakes) != 2 {
+		t.Fatalf("observeCapture: %v sent", len(handshakes))
+	}
+}
@@ -0,0 +1,330 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+const (
+	// throttleInterval is how often the battery and temperature are read.
+	throttleInterval = 30 * time.Second
+	// Once throttled, the battery must charge this many percent above the
+	// threshold, or cool down this many degrees below it, to recover.
+	batteryHysteresis = 5
+	thermalHysteresis = 5
+)
+
+// powerReading is the state of the power supplies and thermal zones.
+type powerReading struct {
+	// Battery is the charge of the emptiest battery in percent, -1 if
+	// there is none.
+	Battery int
+	// Discharging is set when a battery is powering the sensor.
+	Discharging bool
+	// Temperature is the hottest thermal zone in degrees Celsius, 0 if
+	// none is known.
+	Temperature float64
+}
+
+// readPower reads the power_supply and thermal classes of sysfs.
+func readPower() powerReading {
+	reading := powerReading{Battery: -1}
+
+	supplies, _ := filepath.Glob(filepath.Join(sysDir, "class", "power_supply", "*"))
+	for _, supply := range supplies {
+		if readAttribute(filepath.Join(supply, "type")) != "Battery" {
+			continue
+		}
+		capacity, err := strconv.Atoi(readAttribute(filepath.Join(supply, "capacity")))
+		if err != nil {
+			continue
+		}
+		if reading.Battery < 0 || capacity < reading.Battery {
+			reading.Battery = capacity
+		}
+		if readAttribute(filepath.Join(supply, "status")) == "Discharging" {
+			reading.Discharging = true
+		}
+	}
+
+	zones, _ := filepath.Glob(filepath.Join(sysDir, "class", "thermal", "thermal_zone*"))
+	for _, zone := range zones {
+		// Millidegrees Celsius
+		milli, err := strconv.Atoi(readAttribute(filepath.Join(zone, "temp")))
+		if err != nil || milli <= 0 {
+			continue
+		}
+		if temperature := float64(milli) / 1000; temperature > reading.Temperature {
+			reading.Temperature = temperature
+		}
+	}
+
+	return reading
+}
+
+// readAttribute returns the trimmed content of a sysfs attribute, empty if
+// it cannot be read.
+func readAttribute(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// throttleLevel is how much hopping is slowed down to save the sensor.
+type throttleLevel int
+
+const (
+	throttleNormal throttleLevel = iota
+	// throttleSlow multiplies the dwells
+	throttleSlow
+	// throttleIdle pauses the hopper
+	throttleIdle
+)
+
+func (l throttleLevel) String() string {
+	switch l {
+	case throttleSlow:
+		return "slow"
+	case throttleIdle:
+		return "idle"
+	default:
+		return "normal"
+	}
+}
+
+// throttle lengthens the dwells when the battery is low or the sensor is
+// hot, and idles the hopper when they are critical. Thresholds set to 0 are
+// disabled.
+type throttle struct {
+	batteryLow      int
+	batteryCritical int
+	tempHigh        float64
+	tempCritical    float64
+	// factor multiplies the dwells while slow
+	factor float64
+
+	mu    sync.Mutex
+	h     pausablePlanner
+	level throttleLevel
+}
+
+// check validates the thresholds.
+func (t *throttle) check() error {
+	if t.batteryLow < 0 || t.batteryLow > 100 || t.batteryCritical < 0 || t.batteryCritical > 100 {
+		return fmt.Errorf("battery thresholds must be between 0 and 100")
+	}
+	if t.batteryLow > 0 && t.batteryCritical >= t.batteryLow {
+		return fmt.Errorf("--battery-critical must be below --battery-low")
+	}
+	if t.tempHigh < 0 || t.tempCritical < 0 {
+		return fmt.Errorf("temperature thresholds cannot be negative")
+	}
+	if t.tempCritical > 0 && t.tempHigh >= t.tempCritical {
+		return fmt.Errorf("--temp-high must be below --temp-critical")
+	}
+	if t.factor < 1 {
+		return fmt.Errorf("--throttle-factor must be at least 1, not %v", t.factor)
+	}
+	return nil
+}
+
+// levelOf returns the level for a reading, keeping the current one until
+// the reading is past its hysteresis.
+func (t *throttle) levelOf(r powerReading) throttleLevel {
+	level := throttleNormal
+	raise := func(l throttleLevel, over bool) {
+		if over && l > level {
+			level = l
+		}
+	}
+	batteryBelow := func(l throttleLevel, threshold int) bool {
+		if t.level >= l {
+			threshold += batteryHysteresis
+		}
+		return threshold > 0 && r.Discharging && r.Battery >= 0 && r.Battery <= threshold
+	}
+	hotterThan := func(l throttleLevel, threshold float64) bool {
+		if t.level >= l {
+			threshold -= thermalHysteresis
+		}
+		return threshold > 0 && r.Temperature >= threshold
+	}
+
+	if t.batteryLow > 0 {
+		raise(throttleSlow, batteryBelow(throttleSlow, t.batteryLow))
+	}
+	if t.batteryCritical > 0 {
+		raise(throttleIdle, batteryBelow(throttleIdle, t.batteryCritical))
+	}
+	if t.tempHigh > 0 {
+		raise(throttleSlow, hotterThan(throttleSlow, t.tempHigh))
+	}
+	if t.tempCritical > 0 {
+		raise(throttleIdle, hotterThan(throttleIdle, t.tempCritical))
+	}
+	return level
+}
+
+// update applies the level of a reading, and reports whether it changed.
+func (t *throttle) update(r powerReading) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	level := t.levelOf(r)
+	if level == t.level {
+		return false
+	}
+	t.level = level
+
+	var state []string
+	if r.Battery >= 0 {
+		state = append(state, fmt.Sprintf("battery %v%%", r.Battery))
+	}
+	if r.Temperature > 0 {
+		state = append(state, fmt.Sprintf("%.1f°C", r.Temperature))
+	}
+	switch level {
+	case throttleNormal:
+		_, _ = fmt.Fprintf(stderr, "Throttle: %v, hopping normally\n", strings.Join(state, ", "))
+	case throttleSlow:
+		_, _ = fmt.Fprintf(stderr, "Throttle: %v, dwelling %v times longer\n", strings.Join(state, ", "), t.factor)
+	case throttleIdle:
+		_, _ = fmt.Fprintf(stderr, "Throttle: %v, idling\n", strings.Join(state, ", "))
+	}
+	if t.h != nil {
+		if level == throttleIdle {
+			t.h.Pause()
+		} else {
+			t.h.Resume()
+		}
+	}
+
+	event := events.New(events.TypePower)
+	if r.Battery >= 0 {
+		event.Battery = r.Battery
+	}
+	event.Temperature = r.Temperature
+	event.Throttle = level.String()
+	emit(event)
+	return true
+}
+
+// dwell wraps the dwell of the hopper, or of strategy if dwell is nil,
+// multiplying it while slow.
+func (t *throttle) dwell(dwell hopper.DwellController, strategy hopper.Strategy) hopper.DwellController {
+	if dwell == nil {
+		if d, ok := strategy.(hopper.DwellStrategy); ok {
+			dwell = d
+		} else {
+			dwell = hopper.FixedDwell{}
+		}
+	}
+
+	return hopper.DwellFunc(func(channel int, delay time.Duration) time.Duration {
+		d := dwell.Dwell(channel, delay)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.level == throttleSlow {
+			d = time.Duration(float64(d) * t.factor)
+		}
+		return d
+	})
+}
+
+// stretch returns how many times longer than usual the dwells are.
+func (t *throttle) stretch() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.level == throttleSlow {
+		return t.factor
+	}
+	return 1
+}
+
+// run reads the sensors every throttleInterval until ctx is done.
+func (t *throttle) run(ctx context.Context, h pausablePlanner) {
+	t.mu.Lock()
+	t.h = h
+	t.mu.Unlock()
+
+	ticker := time.NewTicker(throttleInterval)
+	defer ticker.Stop()
+	for {
+		t.update(readPower())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sharedPause pauses a hopper on behalf of several features, resuming it
+// only once none of them wants it idle.
+type sharedPause struct {
+	mu     sync.Mutex
+	h      pausablePlanner
+	paused map[string]bool
+}
+
+func newSharedPause(h pausablePlanner) *sharedPause {
+	return &sharedPause{h: h, paused: make(map[string]bool)}
+}
+
+// planner returns h with Pause and Resume acting on behalf of reason.
+func (s *sharedPause) planner(reason string) pausablePlanner {
+	return pauseHolder{pausablePlanner: s.h, shared: s, reason: reason}
+}
+
+func (s *sharedPause) set(reason string, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if paused {
+		s.paused[reason] = true
+	} else {
+		delete(s.paused, reason)
+	}
+	if len(s.paused) > 0 {
+		s.h.Pause()
+	} else {
+		s.h.Resume()
+	}
+}
+
+type pauseHolder struct {
+	pausablePlanner
+	shared *sharedPause
+	reason string
+}
+
+func (p pauseHolder) Pause()  { p.shared.set(p.reason, true) }
+func (p pauseHolder) Resume() { p.shared.set(p.reason, false) }
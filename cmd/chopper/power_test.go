@@ -0,0 +1,172 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+func writeAttributes(t *testing.T, dir string, attributes map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range attributes {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadPower(t *testing.T) {
+	defer func(sys string) { sysDir = sys }(sysDir)
+	sysDir = t.TempDir()
+
+	if r := readPower(); r != (powerReading{Battery: -1}) {
+		t.Fatalf("readPower without sensors = %+v", r)
+	}
+
+	class := filepath.Join(sysDir, "class")
+	writeAttributes(t, filepath.Join(class, "power_supply", "AC"), map[string]string{"type": "Mains", "online": "0"})
+	writeAttributes(t, filepath.Join(class, "power_supply", "BAT0"), map[string]string{"type": "Battery", "capacity": "42", "status": "Discharging"})
+	writeAttributes(t, filepath.Join(class, "power_supply", "BAT1"), map[string]string{"type": "Battery", "capacity": "87", "status": "Unknown"})
+	writeAttributes(t, filepath.Join(class, "thermal", "thermal_zone0"), map[string]string{"temp": "48500"})
+	writeAttributes(t, filepath.Join(class, "thermal", "thermal_zone1"), map[string]string{"temp": "61000"})
+	writeAttributes(t, filepath.Join(class, "thermal", "thermal_zone2"), map[string]string{"temp": "-273000"})
+
+	want := powerReading{Battery: 42, Discharging: true, Temperature: 61}
+	if r := readPower(); r != want {
+		t.Fatalf("readPower:\n- want: %+v\n-  got: %+v", want, r)
+	}
+}
+
+type recordedEvents []events.Event
+
+func (r *recordedEvents) Encode(event events.Event) error {
+	*r = append(*r, event)
+	return nil
+}
+
+func TestThrottle(t *testing.T) {
+	var recorded recordedEvents
+	defer func(sinks []eventSink) { eventSinks = sinks }(eventSinks)
+	eventSinks = []eventSink{&recorded}
+
+	p := &fakePausable{fakePlanner: fakePlanner{channels: []int{1, 6, 11}}}
+	th := &throttle{batteryLow: 20, batteryCritical: 5, tempHigh: 75, tempCritical: 90, factor: 4, h: p}
+	if err := th.check(); err != nil {
+		t.Fatal(err)
+	}
+	dwell := th.dwell(nil, hopper.Sequential{})
+
+	tests := []struct {
+		reading powerReading
+		level   throttleLevel
+	}{
+		{powerReading{Battery: 80, Discharging: true, Temperature: 50}, throttleNormal},
+		{powerReading{Battery: 19, Discharging: true, Temperature: 50}, throttleSlow},
+		// Hysteresis
+		{powerReading{Battery: 24, Discharging: true, Temperature: 50}, throttleSlow},
+		{powerReading{Battery: 26, Discharging: true, Temperature: 50}, throttleNormal},
+		{powerReading{Battery: 4, Discharging: true, Temperature: 50}, throttleIdle},
+		{powerReading{Battery: 8, Discharging: true, Temperature: 50}, throttleIdle},
+		{powerReading{Battery: 11, Discharging: true, Temperature: 50}, throttleSlow},
+		// Charging
+		{powerReading{Battery: 11, Temperature: 50}, throttleNormal},
+		{powerReading{Battery: -1, Temperature: 80}, throttleSlow},
+		{powerReading{Battery: -1, Temperature: 72}, throttleSlow},
+		{powerReading{Battery: -1, Temperature: 95}, throttleIdle},
+		{powerReading{Battery: -1, Temperature: 69}, throttleNormal},
+	}
+	changes := 0
+	for i, tt := range tests {
+		before := th.level
+		if th.update(tt.reading) {
+			changes++
+		}
+		if th.level != tt.level {
+			t.Fatalf("%v: update(%+v) from %v = %v, want %v", i, tt.reading, before, th.level, tt.level)
+		}
+		if p.paused != (tt.level == throttleIdle) {
+			t.Fatalf("%v: paused %v at level %v", i, p.paused, th.level)
+		}
+		want := time.Second
+		if tt.level == throttleSlow {
+			want = 4 * time.Second
+		}
+		if got := dwell.Dwell(1, time.Second); got != want {
+			t.Fatalf("%v: dwell %v at level %v, want %v", i, got, th.level, want)
+		}
+	}
+
+	if len(recorded) != changes {
+		t.Fatalf("%v events for %v changes", len(recorded), changes)
+	}
+	last := recorded[len(recorded)-1]
+	if last.Type != events.TypePower || last.Throttle != "normal" || last.Temperature != 69 || last.Battery != 0 {
+		t.Fatalf("last event: %+v", last)
+	}
+}
+
+func TestThrottleCheck(t *testing.T) {
+	for _, th := range []*throttle{
+		{batteryLow: 10, batteryCritical: 20, factor: 2},
+		{batteryLow: 120, factor: 2},
+		{tempHigh: 90, tempCritical: 80, factor: 2},
+		{batteryLow: 20, factor: 0.5},
+	} {
+		if err := th.check(); err == nil {
+			t.Errorf("check accepted %+v", th)
+		}
+	}
+	th := &throttle{batteryCritical: 10, tempHigh: 70, factor: 1}
+	if err := th.check(); err != nil {
+		t.Errorf("check: %v", err)
+	}
+}
+
+func TestThrottleStrategyDwell(t *testing.T) {
+	th := &throttle{factor: 2, level: throttleSlow}
+	strategy := &hopper.Escalating{Active: func(int) bool { return true }, Max: 3}
+	dwell := th.dwell(nil, strategy)
+	if got, want := dwell.Dwell(6, time.Second), 2*strategy.Dwell(6, time.Second); got != want {
+		t.Fatalf("dwell = %v, want %v", got, want)
+	}
+}
+
+func TestSharedPause(t *testing.T) {
+	p := &fakePausable{}
+	idle := newSharedPause(p)
+	fence, power := idle.planner("geofence"), idle.planner("throttle")
+
+	fence.Pause()
+	power.Pause()
+	fence.Resume()
+	if !p.paused {
+		t.Fatal("the geofence resumed the hopper idled by the throttle")
+	}
+	power.Resume()
+	if p.paused {
+		t.Fatal("the hopper is still paused")
+	}
+}
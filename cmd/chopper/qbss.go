@@ -0,0 +1,191 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// bssLoadWindow is how long the BSS Load of an access point counts after
+// its last beacon.
+const bssLoadWindow = 2 * time.Minute
+
+// bssLoad is the last BSS Load element of an access point.
+type bssLoad struct {
+	channel int
+	load    dot11.BSSLoad
+	seen    time.Time
+}
+
+// channelLoad is the load of a channel reported by its access points.
+type channelLoad struct {
+	// Utilization is the average of the access points, from 0 to 1.
+	Utilization float64
+	// Stations is the sum of the access points.
+	Stations int
+	APs      int
+}
+
+// bssLoadMonitor collects the BSS Load elements (QBSS Load) of captured
+// beacons: the medium busy time measured by access points is a better
+// measure of activity than the frames captured during a short dwell.
+type bssLoadMonitor struct {
+	window    time.Duration
+	minSignal minSignal
+	now       func() time.Time
+
+	mu    sync.Mutex
+	loads map[string]*bssLoad
+	// visited are the channels the capture listened to
+	visited map[int]bool
+}
+
+func newBSSLoadMonitor(window time.Duration) *bssLoadMonitor {
+	return &bssLoadMonitor{window: window, now: time.Now, loads: make(map[string]*bssLoad), visited: make(map[int]bool)}
+}
+
+// hop records a visit, it is registered as an OnHop callback.
+func (m *bssLoadMonitor) hop(channel int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.visited[channel] = true
+}
+
+// observe is a frameObserver.
+func (m *bssLoadMonitor) observe(tuned int, packet dot11.Packet) {
+	m.add(tuned, packet, m.now())
+}
+
+func (m *bssLoadMonitor) add(tuned int, packet dot11.Packet, now time.Time) {
+	f := packet.Frame
+	if f.Type != dot11.TypeManagement || (f.Subtype != dot11.SubtypeBeacon && f.Subtype != dot11.SubtypeProbeResponse) || !m.minSignal.accepts(packet) {
+		return
+	}
+	beacon, err := dot11.ParseBeacon(f.Body)
+	if err != nil {
+		return
+	}
+	load, ok := beacon.BSSLoad()
+	if !ok {
+		return
+	}
+
+	// 2.4 GHz beacons leak into adjacent channels, trust the AP
+	channel := tuned
+	if plan.BandOf(tuned) == plan.Band2GHz && beacon.Channel() != 0 {
+		channel = beacon.Channel()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bssid := f.BSSID().String()
+	if _, ok := m.loads[bssid]; !ok && len(m.loads) >= maxInventory {
+		return
+	}
+	m.loads[bssid] = &bssLoad{channel: channel, load: load, seen: now}
+}
+
+// channels returns the load of the channels with access points heard
+// within the window.
+func (m *bssLoadMonitor) channels() map[int]channelLoad {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	ret := make(map[int]channelLoad)
+	for bssid, l := range m.loads {
+		if now.Sub(l.seen) > m.window {
+			delete(m.loads, bssid)
+			continue
+		}
+		c := ret[l.channel]
+		c.Utilization += l.load.Utilization
+		c.Stations += l.load.Stations
+		c.APs++
+		ret[l.channel] = c
+	}
+	for channel, c := range ret {
+		c.Utilization /= float64(c.APs)
+		ret[channel] = c
+	}
+	return ret
+}
+
+// utilization returns the utilization of channel, 0 without access
+// points. It is the activity of the focus strategy.
+func (m *bssLoadMonitor) utilization(channel int) float64 {
+	return m.channels()[channel].Utilization
+}
+
+// busy returns the utilization of the channels, for the ranked strategy.
+func (m *bssLoadMonitor) busy() (map[int]float64, error) {
+	activity := make(map[int]float64)
+	for channel, c := range m.channels() {
+		activity[channel] = c.Utilization
+	}
+	return activity, nil
+}
+
+// quiet returns the idle time of the visited channels, for the quietest
+// strategy. Visited channels without access points reporting their load
+// count as idle.
+func (m *bssLoadMonitor) quiet() (map[int]float64, error) {
+	loads := m.channels()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	activity := make(map[int]float64, len(m.visited))
+	for channel := range m.visited {
+		activity[channel] = 1 - loads[channel].Utilization
+	}
+	return activity, nil
+}
+
+// writeTable prints the load of the channels, busiest first.
+func (m *bssLoadMonitor) writeTable(w io.Writer) {
+	loads := m.channels()
+	channels := make([]int, 0, len(loads))
+	for channel := range loads {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		if loads[channels[i]].Utilization != loads[channels[j]].Utilization {
+			return loads[channels[i]].Utilization > loads[channels[j]].Utilization
+		}
+		return channels[i] < channels[j]
+	})
+
+	_, _ = fmt.Fprintf(w, "BSS load: %v channels\n", len(channels))
+	if len(channels) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, paint(term != nil && term.color, ansiBold, fmt.Sprintf("%-9s%-13s%-10s%s", "CHANNEL", "UTILIZATION", "STATIONS", "APS")))
+	for _, channel := range channels {
+		c := loads[channel]
+		_, _ = fmt.Fprintf(w, "%-9s%-13s%-10d%d\n", channelLabel(channel), fmt.Sprintf("%.0f%%", c.Utilization*100), c.Stations, c.APs)
+	}
+}
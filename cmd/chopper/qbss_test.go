@@ -0,0 +1,96 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+// testLoadBeacon returns a beacon from bssid with a BSS Load element.
+func testLoadBeacon(t *testing.T, bssid []byte, channel byte, stations byte, utilization byte) dot11.Packet {
+	b := append(testBeacon(bssid, channel), dot11.ElementBSSLoad, 5, stations, 0, utilization, 0, 0)
+	packet, err := dot11.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestBSSLoadMonitor(t *testing.T) {
+	start := time.Unix(1633089600, 0)
+	now := start
+	m := newBSSLoadMonitor(time.Minute)
+	m.now = func() time.Time { return now }
+
+	other := []byte{0x02, 0, 0, 0, 0, 0x99}
+	stale := []byte{0x02, 0, 0, 0, 0, 0x42}
+	ap36 := []byte{0x02, 0, 0, 0, 0, 0x36}
+	m.add(1, testLoadBeacon(t, stale, 1, 1, 255), start)
+	now = start.Add(90 * time.Second)
+	// Leaked into channel 5, the beacon tells channel 6
+	m.add(5, testLoadBeacon(t, testAP, 6, 10, 102), now)
+	m.add(6, testLoadBeacon(t, other, 6, 4, 51), now)
+	m.add(36, testLoadBeacon(t, ap36, 36, 2, 0), now)
+	// The newest beacon of an AP replaces the previous one
+	m.add(36, testLoadBeacon(t, ap36, 36, 3, 204), now)
+	// Beacons without the element are ignored
+	packet, err := dot11.Decode(testBeacon(other, 11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.add(11, packet, now)
+
+	want := map[int]channelLoad{
+		6:  {Utilization: 0.3, Stations: 14, APs: 2},
+		36: {Utilization: 0.8, Stations: 3, APs: 1},
+	}
+	got := m.channels()
+	if len(got) != len(want) {
+		t.Fatalf("channels() = %+v, want %+v", got, want)
+	}
+	for channel, w := range want {
+		g := got[channel]
+		if g.Stations != w.Stations || g.APs != w.APs || g.Utilization < w.Utilization-1e-9 || g.Utilization > w.Utilization+1e-9 {
+			t.Errorf("channel %v: %+v, want %+v", channel, g, w)
+		}
+	}
+
+	busy, _ := m.busy()
+	if busy[36] <= busy[6] || busy[1] != 0 {
+		t.Errorf("busy() = %v", busy)
+	}
+	m.hop(1)
+	m.hop(36)
+	quiet, _ := m.quiet()
+	// Channel 1 was visited and no AP reported its load
+	if len(quiet) != 2 || quiet[1] != 1 || quiet[36] < 0.19 || quiet[36] > 0.21 {
+		t.Errorf("quiet() = %v", quiet)
+	}
+
+	var buf bytes.Buffer
+	m.writeTable(&buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want := []string{"BSS load: 2 channels", "CHANNEL  UTILIZATION  STATIONS  APS", "36       80%          3         1", "6        30%          14        2"}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("table:\n%v", buf.String())
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// quirks are the workarounds applied for the driver of the interface.
+type quirks struct {
+	// UpDown brings the interface down before every retune and up again
+	// after it.
+	UpDown bool
+	// MinDelay raises the minimum delay between hops, in ms.
+	MinDelay int
+}
+
+// driverQuirks are the quirks applied by --quirks auto, by driver name.
+var driverQuirks = map[string]quirks{
+	// The nexmon firmware patches only apply a new channel to a
+	// reinitialized interface
+	"brcmfmac": {UpDown: true},
+	// Out-of-tree Realtek drivers drop frames or hang on short dwells
+	"88XXau":    {MinDelay: 150},
+	"rtl88xxau": {MinDelay: 150},
+	"8812au":    {MinDelay: 150},
+	"8814au":    {MinDelay: 150},
+	"88x2bu":    {MinDelay: 150},
+	"8821cu":    {MinDelay: 150},
+	"8188eu":    {MinDelay: 150},
+}
+
+func (q quirks) String() string {
+	var parts []string
+	if q.UpDown {
+		parts = append(parts, "updown")
+	}
+	if q.MinDelay > 0 {
+		parts = append(parts, fmt.Sprintf("min-delay=%v", q.MinDelay))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
+
+// driverOf returns the name of the kernel driver of a network interface,
+// or an empty string if it has no device.
+func driverOf(name string) string {
+	target, err := os.Readlink(filepath.Join(sysDir, "class", "net", name, "device", "driver"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// parseQuirks parses a comma-separated list of quirks, applied in order:
+// auto adds the quirks of driver, none clears them, updown and no-updown
+// toggle UpDown and min-delay=<ms> sets MinDelay.
+func parseQuirks(input string, driver string) (quirks, error) {
+	var q quirks
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "auto":
+			known := driverQuirks[driver]
+			q.UpDown = q.UpDown || known.UpDown
+			if known.MinDelay > q.MinDelay {
+				q.MinDelay = known.MinDelay
+			}
+		case part == "none":
+			q = quirks{}
+		case part == "updown":
+			q.UpDown = true
+		case part == "no-updown":
+			q.UpDown = false
+		case strings.HasPrefix(part, "min-delay="):
+			delay, err := strconv.Atoi(strings.TrimPrefix(part, "min-delay="))
+			if err != nil || delay < 0 {
+				return quirks{}, fmt.Errorf("invalid quirk %v", part)
+			}
+			q.MinDelay = delay
+		default:
+			return quirks{}, fmt.Errorf("unknown quirk %v, want auto, none, updown, no-updown or min-delay=<ms>", part)
+		}
+	}
+	return q, nil
+}
+
+// upDownTuner brings the interface down around every retune, for the
+// updown quirk.
+type upDownTuner struct {
+	tuner   hopper.Tuner
+	ifindex int
+	// setLink brings the interface up or down, replaced in tests.
+	setLink func(ifindex int, up bool) error
+}
+
+func newUpDownTuner(tuner hopper.Tuner, client *nl80211util.Client, ifindex int) upDownTuner {
+	setLink := func(ifindex int, up bool) error {
+		if up {
+			return client.SetLinkUp(ifindex)
+		}
+		return client.SetLinkDown(ifindex)
+	}
+	return upDownTuner{tuner: tuner, ifindex: ifindex, setLink: setLink}
+}
+
+func (t upDownTuner) SetChannel(channel int) error {
+	if err := t.setLink(t.ifindex, false); err != nil {
+		return fmt.Errorf("cannot bring the interface down: %v", err)
+	}
+	err := t.tuner.SetChannel(channel)
+	if upErr := t.setLink(t.ifindex, true); upErr != nil && err == nil {
+		err = fmt.Errorf("cannot bring the interface up: %v", upErr)
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+func TestDriverOf(t *testing.T) {
+	defer func(sys string) { sysDir = sys }(sysDir)
+	sysDir = t.TempDir()
+
+	device := filepath.Join(sysDir, "class", "net", "wlan0", "device")
+	if err := os.MkdirAll(device, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../bus/sdio/drivers/brcmfmac", filepath.Join(device, "driver")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := driverOf("wlan0"); got != "brcmfmac" {
+		t.Errorf("driverOf(wlan0) = %q, want brcmfmac", got)
+	}
+	if got := driverOf("mon0"); got != "" {
+		t.Errorf("driverOf(mon0) = %q, want none", got)
+	}
+}
+
+func TestParseQuirks(t *testing.T) {
+	tests := []struct {
+		input  string
+		driver string
+		want   quirks
+	}{
+		{"auto", "iwlwifi", quirks{}},
+		{"auto", "brcmfmac", quirks{UpDown: true}},
+		{"auto", "88XXau", quirks{MinDelay: 150}},
+		{"auto,no-updown", "brcmfmac", quirks{}},
+		{"auto,min-delay=300", "8812au", quirks{MinDelay: 300}},
+		{"none", "brcmfmac", quirks{}},
+		{"updown, min-delay=80", "", quirks{UpDown: true, MinDelay: 80}},
+	}
+	for _, test := range tests {
+		got, err := parseQuirks(test.input, test.driver)
+		if err != nil {
+			t.Errorf("parseQuirks(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseQuirks(%q, %q) = %v, want %v", test.input, test.driver, got, test.want)
+		}
+	}
+
+	for _, input := range []string{"", "fast", "min-delay=-1", "min-delay=x"} {
+		if _, err := parseQuirks(input, ""); err == nil {
+			t.Errorf("parseQuirks(%q) succeeded", input)
+		}
+	}
+}
+
+func TestQuirksString(t *testing.T) {
+	if got := (quirks{}).String(); got != "none" {
+		t.Errorf("got %q, want none", got)
+	}
+	if got := (quirks{UpDown: true, MinDelay: 150}).String(); got != "updown,min-delay=150" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUpDownTuner(t *testing.T) {
+	var calls []string
+	tuner := upDownTuner{
+		tuner: hopper.TunerFunc(func(channel int) error {
+			calls = append(calls, "tune")
+			if channel == 13 {
+				return errors.New("invalid channel")
+			}
+			return nil
+		}),
+		ifindex: 3,
+		setLink: func(ifindex int, up bool) error {
+			if ifindex != 3 {
+				t.Errorf("ifindex %v, want 3", ifindex)
+			}
+			if up {
+				calls = append(calls, "up")
+			} else {
+				calls = append(calls, "down")
+			}
+			return nil
+		},
+	}
+
+	if err := tuner.SetChannel(6); err != nil {
+		t.Fatal(err)
+	}
+	// The interface is brought up again when retuning fails
+	if err := tuner.SetChannel(13); err == nil {
+		t.Error("retuning to 13 succeeded")
+	}
+	want := []string{"down", "tune", "up", "down", "tune", "up"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+}
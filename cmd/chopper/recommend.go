@@ -0,0 +1,340 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+)
+
+// Weights of the recommendation score, lower scores are better. A fully
+// busy channel weighs as much as ten APs on it.
+const (
+	weightBusy    = 100.0
+	weightAP      = 10.0
+	weightOverlap = 5.0
+	weightNoise   = 2.0
+	weightDFS     = 5.0
+	// quietNoise is the noise floor in dBm below which noise is not
+	// penalized.
+	quietNoise = -95
+)
+
+// channelSurvey accumulates the survey counters of a channel across dwells.
+type channelSurvey struct {
+	time    uint64
+	busy    uint64
+	noise   int
+	samples int
+}
+
+// measure accounts the survey counters taken before and after a dwell on
+// channel.
+func (s *channelSurvey) measure(channel int, before, after []nl80211util.SurveyInfo) {
+	frequency := plan.Frequency(channel)
+	var old nl80211util.SurveyInfo
+	for _, info := range before {
+		if info.Frequency == frequency {
+			old = info
+		}
+	}
+
+	for _, info := range after {
+		if info.Frequency != frequency {
+			continue
+		}
+		if info.Time > old.Time && info.TimeBusy >= old.TimeBusy {
+			s.time += info.Time - old.Time
+			s.busy += info.TimeBusy - old.TimeBusy
+		}
+		if info.Noise != 0 {
+			s.noise += info.Noise
+			s.samples++
+		}
+	}
+}
+
+// channelQuality is what recommend measured on a single channel.
+type channelQuality struct {
+	Channel int
+	// Busy is the fraction of time the medium was busy, or -1 if the
+	// driver does not report survey counters.
+	Busy float64
+	// Noise is the average noise floor in dBm, or 0 if unknown.
+	Noise int
+	APs   int
+	// Overlap counts the APs on overlapping 2.4 GHz channels.
+	Overlap int
+}
+
+// score rates the channel for a new AP, lower is better.
+func (q channelQuality) score() float64 {
+	score := float64(q.APs)*weightAP + float64(q.Overlap)*weightOverlap
+	if q.Busy > 0 {
+		score += q.Busy * weightBusy
+	}
+	if q.Noise != 0 && q.Noise > quietNoise {
+		score += float64(q.Noise-quietNoise) * weightNoise
+	}
+	if isDFS(q.Channel) {
+		score += weightDFS
+	}
+	return score
+}
+
+// reasons explains the score of the channel.
+func (q channelQuality) reasons() string {
+	var reasons []string
+	switch {
+	case q.Busy < 0:
+		reasons = append(reasons, "busy time unknown")
+	case q.Busy < 0.05:
+		reasons = append(reasons, "idle")
+	default:
+		reasons = append(reasons, fmt.Sprintf("%.0f%% busy", q.Busy*100))
+	}
+	if q.Noise != 0 && q.Noise > quietNoise {
+		reasons = append(reasons, fmt.Sprintf("noise %d dBm", q.Noise))
+	}
+	switch q.APs {
+	case 0:
+		reasons = append(reasons, "no APs")
+	case 1:
+		reasons = append(reasons, "1 AP")
+	default:
+		reasons = append(reasons, fmt.Sprintf("%d APs", q.APs))
+	}
+	if q.Overlap > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d on overlapping channels", q.Overlap))
+	}
+	if isDFS(q.Channel) {
+		reasons = append(reasons, "DFS")
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// overlaps reports whether two distinct 2.4 GHz channels overlap. The
+// spectral mask is 22 MHz wide, so 1, 6 and 11 are the closest channels
+// that do not.
+func overlaps(a, b int) bool {
+	if a == b || plan.BandOf(a) != plan.Band2GHz || plan.BandOf(b) != plan.Band2GHz {
+		return false
+	}
+	distance := plan.Frequency(a) - plan.Frequency(b)
+	if distance < 0 {
+		distance = -distance
+	}
+	return distance < 25
+}
+
+// rankChannels returns the quality of the channels grouped by band, best
+// first.
+func rankChannels(channels []int, d *discovery, surveys map[int]*channelSurvey) map[plan.Band][]channelQuality {
+	ranked := make(map[plan.Band][]channelQuality)
+	for _, channel := range channels {
+		q := channelQuality{Channel: channel, Busy: -1}
+		if r, ok := d.reports[channel]; ok {
+			q.APs = len(r.APs)
+		}
+		for other, r := range d.reports {
+			if overlaps(channel, other) {
+				q.Overlap += len(r.APs)
+			}
+		}
+		if s, ok := surveys[channel]; ok {
+			if s.time > 0 {
+				q.Busy = float64(s.busy) / float64(s.time)
+			}
+			if s.samples > 0 {
+				q.Noise = s.noise / s.samples
+			}
+		}
+
+		band := plan.BandOf(channel)
+		ranked[band] = append(ranked[band], q)
+	}
+
+	for _, qualities := range ranked {
+		sort.SliceStable(qualities, func(i, j int) bool {
+			return qualities[i].score() < qualities[j].score()
+		})
+	}
+	return ranked
+}
+
+// writeRecommendations prints the ranked channels of every band.
+func writeRecommendations(ranked map[plan.Band][]channelQuality) {
+	for _, band := range []plan.Band{plan.Band2GHz, plan.Band5GHz, plan.Band6GHz} {
+		qualities := ranked[band]
+		if len(qualities) == 0 {
+			continue
+		}
+
+		fmt.Printf("%v: use channel %s\n", band, channelLabel(qualities[0].Channel))
+		fmt.Println(paint(colorStdout, ansiBold, fmt.Sprintf("%-6s%-8s%-8s%-7s%-7s%-5s%s", "RANK", "CHANNEL", "SCORE", "BUSY", "NOISE", "APS", "REASONS")))
+		for i, q := range qualities {
+			busy, noise := "-", "-"
+			if q.Busy >= 0 {
+				busy = fmt.Sprintf("%.0f%%", q.Busy*100)
+			}
+			if q.Noise != 0 {
+				noise = fmt.Sprintf("%d", q.Noise)
+			}
+			fmt.Printf("%-6d%-8s%-8.1f%-7s%-7s%-5d%s\n", i+1, channelLabel(q.Channel), q.score(), busy, noise, q.APs, q.reasons())
+		}
+		fmt.Println()
+	}
+}
+
+// runRecommend implements the recommend subcommand and returns the exit
+// code.
+func runRecommend(ctx context.Context, args []string) int {
+	var (
+		ifaceName string
+		chans     string
+		dwell     int
+		sweeps    int
+		force     bool
+		colorMode string
+	)
+
+	flags := flag.NewFlagSet("recommend", flag.ExitOnError)
+	flags.StringVarP(&ifaceName, "interface", "i", "", "interface name (must be in monitor mode)")
+	flags.StringVarP(&chans, "channels", "c", "", "comma-separated list of channels to sweep (default: all the channels of the radio)")
+	flags.IntVarP(&dwell, "dwell", "d", 500, "time spent on each channel in ms")
+	flags.IntVar(&sweeps, "sweeps", 2, "number of full sweeps")
+	flags.BoolVar(&force, "force", false, "start even if other processes may manage the interface")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if ifaceName == "" {
+		flags.Usage()
+		return 1
+	}
+
+	// Connect to nl80211
+	client, err := nl80211util.Dial()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	iface, err := client.MonitorInterface(ifaceName)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	supported := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13}
+	if frequencies, err := client.WiphyFrequencies(iface.PHY); err == nil {
+		supported = capabilitiesOf(iface.PHY, frequencies).Channels
+	} else {
+		_, _ = fmt.Fprintf(stderr, "WARNING: cannot list the channels of phy%d: %v\n", iface.PHY, err)
+	}
+	channels := parseChannels(chans, supported)
+
+	lock, err := lockInterface(iface.Name)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer lock.Close()
+	if !checkManagers(iface.Name, iface.PHY, force) {
+		return 1
+	}
+	if !checkPhyInterfaces(client, iface, force) {
+		return 1
+	}
+
+	capture, err := openCapture(iface.Index, 50*time.Millisecond)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot open capture socket: %v\n", err)
+		return 1
+	}
+	defer capture.Close()
+
+	d := newDiscovery()
+	surveys := make(map[int]*channelSurvey)
+	surveyFailed := false
+	buf := make([]byte, 65536)
+	for sweep := 0; sweep < sweeps && ctx.Err() == nil; sweep++ {
+		for _, channel := range channels {
+			if ctx.Err() != nil {
+				break
+			}
+
+			if err := client.SetFrequency(iface.Index, plan.Frequency(channel)); err != nil {
+				_, _ = fmt.Fprintf(stderr, "WARNING: cannot set channel %v: %v\n", channel, err)
+				continue
+			}
+			before, err := client.Survey(iface.Index)
+			if err != nil && !surveyFailed {
+				_, _ = fmt.Fprintf(stderr, "WARNING: cannot read the channel survey, busy time and noise are unknown: %v\n", err)
+				surveyFailed = true
+			}
+
+			deadline := time.Now().Add(time.Duration(dwell) * time.Millisecond)
+			for time.Now().Before(deadline) {
+				n, err := capture.Read(buf)
+				if err != nil {
+					_, _ = fmt.Fprintf(stderr, "ERROR: cannot read frame: %v\n", err)
+					return 1
+				}
+				if n == 0 {
+					continue
+				}
+
+				packet, err := dot11.Decode(buf[:n])
+				if err != nil {
+					continue
+				}
+				d.observe(channel, packet.Frame)
+			}
+
+			if surveyFailed {
+				continue
+			}
+			after, err := client.Survey(iface.Index)
+			if err != nil {
+				continue
+			}
+			s, ok := surveys[channel]
+			if !ok {
+				s = &channelSurvey{}
+				surveys[channel] = s
+			}
+			s.measure(channel, before, after)
+		}
+	}
+
+	writeRecommendations(rankChannels(channels, d, surveys))
+	return 0
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func TestChannelSurveyMeasure(t *testing.T) {
+	s := &channelSurvey{}
+	s.measure(6,
+		[]nl80211util.SurveyInfo{{Frequency: 2437, Time: 1000, TimeBusy: 100}, {Frequency: 2412, Time: 10}},
+		[]nl80211util.SurveyInfo{{Frequency: 2437, Time: 1500, TimeBusy: 200, Noise: -90}, {Frequency: 2412, Time: 900, TimeBusy: 900}})
+	// Counters that went backwards are skipped
+	s.measure(6,
+		[]nl80211util.SurveyInfo{{Frequency: 2437, Time: 1500, TimeBusy: 200}},
+		[]nl80211util.SurveyInfo{{Frequency: 2437, Time: 100, TimeBusy: 10, Noise: -94}})
+
+	if s.time != 500 || s.busy != 100 {
+		t.Errorf("time %v busy %v, want 500 and 100", s.time, s.busy)
+	}
+	if s.noise != -184 || s.samples != 2 {
+		t.Errorf("noise %v over %v samples, want -184 over 2", s.noise, s.samples)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want bool
+	}{
+		{1, 1, false},
+		{1, 4, true},
+		{1, 5, true},
+		{1, 6, false},
+		{6, 2, true},
+		{13, 14, true},
+		{10, 14, false},
+		{36, 40, false},
+		{1, 36, false},
+	}
+	for _, test := range tests {
+		if got := overlaps(test.a, test.b); got != test.want {
+			t.Errorf("overlaps(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestRankChannels(t *testing.T) {
+	d := newDiscovery()
+	ap1 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x01}
+	ap2 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x02}
+	ap3 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x03}
+	for _, beacon := range []struct {
+		bssid   []byte
+		channel byte
+	}{{ap1, 1}, {ap2, 1}, {ap3, 36}} {
+		packet, err := dot11.Decode(testBeacon(beacon.bssid, beacon.channel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.observe(int(beacon.channel), packet.Frame)
+	}
+
+	surveys := map[int]*channelSurvey{
+		6:  {time: 1000, busy: 500, noise: -92, samples: 1},
+		11: {time: 1000, busy: 20, noise: -96, samples: 1},
+		40: {time: 1000, busy: 0},
+	}
+	ranked := rankChannels([]int{1, 3, 6, 11, 36, 40, 52}, d, surveys)
+
+	order := func(band plan.Band) []int {
+		var channels []int
+		for _, q := range ranked[band] {
+			channels = append(channels, q.Channel)
+		}
+		return channels
+	}
+	if got, want := order(plan.Band2GHz), []int{11, 3, 1, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("2.4 GHz ranking %v, want %v", got, want)
+	}
+	// 52 is quiet but DFS, 36 has an AP
+	if got, want := order(plan.Band5GHz), []int{40, 52, 36}; !reflect.DeepEqual(got, want) {
+		t.Errorf("5 GHz ranking %v, want %v", got, want)
+	}
+
+	best := ranked[plan.Band2GHz][0]
+	if best.Busy != 0.02 || best.Noise != -96 || best.Overlap != 0 {
+		t.Errorf("channel 11 quality %+v", best)
+	}
+	if got, want := best.reasons(), "idle, no APs"; got != want {
+		t.Errorf("reasons %q, want %q", got, want)
+	}
+	if got, want := ranked[plan.Band2GHz][1].reasons(), "busy time unknown, no APs, 2 on overlapping channels"; got != want {
+		t.Errorf("reasons %q, want %q", got, want)
+	}
+	if got, want := ranked[plan.Band2GHz][3].reasons(), "50% busy, noise -92 dBm, no APs"; got != want {
+		t.Errorf("reasons %q, want %q", got, want)
+	}
+	if got, want := ranked[plan.Band5GHz][1].reasons(), "busy time unknown, no APs, DFS"; got != want {
+		t.Errorf("reasons %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// rpcMethods are the methods served on --rpc-socket, with an example of
+// their parameters in the format of rpcd exec plugins.
+var rpcMethods = map[string]map[string]interface{}{
+	"status":         {},
+	"set_plan":       {"channels": "1,6,11"},
+	"add_channel":    {"channel": "6"},
+	"remove_channel": {"channel": "6"},
+	"set_delay":      {"delay": 100},
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcParams are the parameters of every method. Channels may be given as
+// strings or numbers.
+type rpcParams struct {
+	Channels interface{} `json:"channels"`
+	Channel  interface{} `json:"channel"`
+	Delay    int         `json:"delay"`
+}
+
+// rpcStatus is returned by every method.
+type rpcStatus struct {
+	Channel  int       `json:"channel"`
+	LastHop  time.Time `json:"last_hop"`
+	Channels []int     `json:"channels"`
+	Plan     string    `json:"plan"`
+	DelayMs  int64     `json:"delay_ms"`
+	Hops     int       `json:"hops"`
+	Failures int       `json:"failures"`
+}
+
+// rpcServer is the JSON-RPC 2.0 interface enabled by --rpc-socket, a
+// request per line on a unix socket. It is what chopper rpcd talks to, so
+// that OpenWrt's ubus, and LuCI through it, can show and control chopper.
+type rpcServer struct {
+	// delayFloor is the smallest delay accepted by set_delay.
+	delayFloor time.Duration
+
+	mu      sync.Mutex
+	lastHop time.Time
+	channel int
+	// hopper is set once the hopper is created
+	hopper *hopper.Hopper
+	// shared is the plan of hopper, edited by the plan methods
+	shared *sharedPlan
+}
+
+func newRPCServer(delayFloor time.Duration) *rpcServer {
+	return &rpcServer{delayFloor: delayFloor}
+}
+
+func (s *rpcServer) setHopper(h *hopper.Hopper, shared *sharedPlan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hopper = h
+	s.shared = shared
+}
+
+// hop records a successful hop, it is registered as an OnHop callback.
+func (s *rpcServer) hop(channel int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastHop = time.Now()
+	s.channel = channel
+}
+
+func (s *rpcServer) status(h *hopper.Hopper) rpcStatus {
+	channels := h.Channels()
+	stats := h.Stats()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return rpcStatus{
+		Channel:  s.channel,
+		LastHop:  s.lastHop,
+		Channels: channels,
+		Plan:     plan.Format(channels),
+		DelayMs:  int64(h.Delay() / time.Millisecond),
+		Hops:     stats.Hops,
+		Failures: stats.Failures,
+	}
+}
+
+// rpcChannel parses a channel given as a string or a number.
+func rpcChannel(value interface{}) (int, error) {
+	if value == nil {
+		return 0, errors.New("missing channel")
+	}
+	input := fmt.Sprint(value)
+	channel, err := plan.ParseChannel(input)
+	if err != nil {
+		return 0, err
+	}
+	if plan.Frequency(channel) == 0 {
+		return 0, fmt.Errorf("invalid channel %q", input)
+	}
+	return channel, nil
+}
+
+// rpcPlan parses a plan given as a bundled plan name or channel list, like
+// --schedule, or as an array of channels.
+func rpcPlan(value interface{}) ([]int, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, errors.New("missing channels")
+	case []interface{}:
+		channels := make([]int, 0, len(v))
+		for _, c := range v {
+			channel, err := rpcChannel(c)
+			if err != nil {
+				return nil, err
+			}
+			channels = append(channels, channel)
+		}
+		if len(channels) == 0 {
+			return nil, hopper.ErrNoChannels
+		}
+		return channels, nil
+	}
+
+	channels, err := schedulePlan(fmt.Sprint(value))
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if plan.Frequency(channel) == 0 {
+			return nil, fmt.Errorf("invalid channel %v", channel)
+		}
+	}
+	return channels, nil
+}
+
+// call runs a method, changes to the plan are applied at the next hop.
+func (s *rpcServer) call(method string, raw json.RawMessage) (interface{}, error) {
+	if _, ok := rpcMethods[method]; !ok {
+		return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("unknown method %q", method)}
+	}
+	var params rpcParams
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+
+	s.mu.Lock()
+	h, shared := s.hopper, s.shared
+	s.mu.Unlock()
+	if h == nil {
+		return nil, &rpcError{rpcServerError, "not hopping"}
+	}
+
+	var err error
+	switch method {
+	case "set_plan":
+		var channels []int
+		if channels, err = rpcPlan(params.Channels); err == nil {
+			err = shared.SetChannels(channels)
+		}
+	case "add_channel":
+		var channel int
+		if channel, err = rpcChannel(params.Channel); err == nil {
+			err = shared.AddChannel(channel)
+		}
+	case "remove_channel":
+		var channel int
+		if channel, err = rpcChannel(params.Channel); err == nil {
+			err = shared.RemoveChannel(channel)
+		}
+	case "set_delay":
+		delay := time.Duration(params.Delay) * time.Millisecond
+		if params.Delay <= 0 {
+			err = fmt.Errorf("invalid delay %v", params.Delay)
+		} else if delay < s.delayFloor {
+			err = fmt.Errorf("delay is below the minimum of %v", s.delayFloor)
+		} else {
+			err = h.SetDelay(delay)
+		}
+	}
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, err.Error()}
+	}
+	return s.status(h), nil
+}
+
+// serveConn answers the requests of a connection until it is closed.
+// Notifications, requests without an id, get no response.
+func (s *rpcServer) serveConn(conn io.ReadWriter) {
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req rpcRequest
+		response := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			response.Error = &rpcError{rpcParseError, err.Error()}
+		} else if req.JSONRPC != "2.0" || req.Method == "" {
+			response.ID = req.ID
+			response.Error = &rpcError{rpcInvalidRequest, "not a JSON-RPC 2.0 request"}
+		} else {
+			result, err := s.call(req.Method, req.Params)
+			if len(req.ID) == 0 {
+				continue
+			}
+			response.ID = req.ID
+			response.Result = result
+			if err != nil {
+				var rpcErr *rpcError
+				if !errors.As(err, &rpcErr) {
+					rpcErr = &rpcError{rpcServerError, err.Error()}
+				}
+				response.Error = rpcErr
+			}
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// listen serves the API on the unix socket at path, replacing a stale
+// socket left by a crashed instance. The returned function stops serving
+// and removes the socket.
+func (s *rpcServer) listen(path string) (func(), error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%v is in use by another instance", path)
+		}
+		_ = os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				s.serveConn(conn)
+			}()
+		}
+	}()
+
+	return func() {
+		listener.Close()
+		wg.Wait()
+	}, nil
+}
+
+// rpcCall sends a request to the API on the unix socket at path and returns
+// its result.
+func rpcCall(path string, method string, params json.RawMessage, timeout time.Duration) (json.RawMessage, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method, Params: params}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+// startRPC serves a hopper on 1, 6 and 11 on a socket in a temporary
+// directory.
+func startRPC(t *testing.T) (string, *hopper.Hopper) {
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), hopper.Config{
+		Channels: []int{1, 6, 11},
+		Delay:    100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newRPCServer(50 * time.Millisecond)
+	s.setHopper(h, newSharedPlan(h))
+	s.hop(6)
+
+	path := filepath.Join(t.TempDir(), "chopper.sock")
+	shutdown, err := s.listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(shutdown)
+	return path, h
+}
+
+func callStatus(t *testing.T, path, method, params string) rpcStatus {
+	t.Helper()
+
+	result, err := rpcCall(path, method, json.RawMessage(params), time.Second)
+	if err != nil {
+		t.Fatalf("%v %v: %v", method, params, err)
+	}
+	var status rpcStatus
+	if err := json.Unmarshal(result, &status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestRPCServer(t *testing.T) {
+	path, h := startRPC(t)
+
+	status := callStatus(t, path, "status", "")
+	if status.Channel != 6 || status.Plan != "1,6,11" || status.DelayMs != 100 {
+		t.Fatalf("status: %+v", status)
+	}
+
+	tests := []struct {
+		method string
+		params string
+		want   []int
+	}{
+		{"set_plan", `{"channels": "1x2,6"}`, []int{1, 6, 1}},
+		{"set_plan", `{"channels": [36, "40"]}`, []int{36, 40}},
+		{"add_channel", `{"channel": 44}`, []int{36, 40, 44}},
+		{"remove_channel", `{"channel": "40"}`, []int{36, 44}},
+	}
+	for _, tt := range tests {
+		status := callStatus(t, path, tt.method, tt.params)
+		if !reflect.DeepEqual(status.Channels, tt.want) {
+			t.Fatalf("%v %v:\n- want: %v\n-  got: %v", tt.method, tt.params, tt.want, status.Channels)
+		}
+	}
+	if status := callStatus(t, path, "set_delay", `{"delay": 250}`); status.DelayMs != 250 || h.Delay() != 250*time.Millisecond {
+		t.Fatalf("set_delay: %+v", status)
+	}
+
+	failures := []struct {
+		method string
+		params string
+		code   int
+	}{
+		{"set_delay", `{"delay": 10}`, rpcInvalidParams},
+		{"set_plan", `{}`, rpcInvalidParams},
+		{"add_channel", `{"channel": 15}`, rpcInvalidParams},
+		{"remove_channel", `{"channel": 1}`, rpcInvalidParams},
+		{"set_delay", `{"delay": "fast"}`, rpcInvalidParams},
+		{"reboot", `{}`, rpcMethodNotFound},
+	}
+	for _, tt := range failures {
+		_, err := rpcCall(path, tt.method, json.RawMessage(tt.params), time.Second)
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != tt.code {
+			t.Fatalf("%v %v:\n- want code: %v\n-       got: %v", tt.method, tt.params, tt.code, err)
+		}
+	}
+
+	if _, err := newRPCServer(0).listen(path); err == nil {
+		t.Fatal("listen on a socket in use did not fail")
+	}
+}
+
+func TestRPCServerRequests(t *testing.T) {
+	path, _ := startRPC(t)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	// The notification gets no response, so the first line answers the
+	// invalid JSON
+	_, err = conn.Write([]byte(`{"jsonrpc": "2.0", "method": "set_delay", "params": {"delay": 300}}` + "\n" +
+		"not json\n" +
+		`{"method": "status", "id": 7}` + "\n" +
+		`{"jsonrpc": "2.0", "method": "status", "id": "a"}` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	var responses []rpcResponse
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response rpcResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, response)
+	}
+
+	if e := responses[0].Error; e == nil || e.Code != rpcParseError || string(responses[0].ID) != "null" {
+		t.Errorf("invalid JSON: %+v", responses[0])
+	}
+	if e := responses[1].Error; e == nil || e.Code != rpcInvalidRequest || string(responses[1].ID) != "7" {
+		t.Errorf("request without version: %+v", responses[1])
+	}
+	status, ok := responses[2].Result.(map[string]interface{})
+	if !ok || status["delay_ms"] != 300.0 || string(responses[2].ID) != `"a"` {
+		t.Errorf("status after the notification: %+v", responses[2])
+	}
+}
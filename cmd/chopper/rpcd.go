@@ -0,0 +1,95 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// defaultRPCSocket is where chopper rpcd looks for --rpc-socket.
+const defaultRPCSocket = "/var/run/chopper.sock"
+
+// rpcdCall forwards a call of rpcd to the socket and writes the result,
+// or an object with the error, since rpcd expects an object either way.
+func rpcdCall(socket, method string, stdin io.Reader, stdout io.Writer, timeout time.Duration) error {
+	params, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	if len(params) > 0 && !json.Valid(params) {
+		err = fmt.Errorf("invalid arguments %q", params)
+	}
+
+	var result json.RawMessage
+	if err == nil {
+		result, err = rpcCall(socket, method, params, timeout)
+	}
+	if err != nil {
+		_ = json.NewEncoder(stdout).Encode(rpcdError{err.Error()})
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "%s\n", result)
+	return err
+}
+
+// rpcdError is written by chopper rpcd when a call fails.
+type rpcdError struct {
+	Error string `json:"error"`
+}
+
+// runRPCD implements the rpcd exec plugin protocol of OpenWrt, "list" to
+// describe the methods and "call <method>" with the arguments on stdin, on
+// top of the API served on --rpc-socket. It makes chopper a ubus object once
+// installed as /usr/libexec/rpcd/chopper.
+func runRPCD(args []string) int {
+	var (
+		socket  string
+		timeout time.Duration
+	)
+
+	flags := flag.NewFlagSet("rpcd", flag.ExitOnError)
+	flags.StringVar(&socket, "socket", defaultRPCSocket, "--rpc-socket of the running chopper")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "give up on chopper after this long")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: %v rpcd [--socket path] list | call <method>\n", ProgramName)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	switch {
+	case flags.NArg() == 1 && flags.Arg(0) == "list":
+		if err := json.NewEncoder(os.Stdout).Encode(rpcMethods); err != nil {
+			return 1
+		}
+		return 0
+	case flags.NArg() == 2 && flags.Arg(0) == "call":
+		if err := rpcdCall(socket, flags.Arg(1), os.Stdin, os.Stdout, timeout); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	flags.Usage()
+	return 1
+}
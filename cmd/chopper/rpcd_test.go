@@ -0,0 +1,65 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRPCDCall(t *testing.T) {
+	path, _ := startRPC(t)
+
+	var out bytes.Buffer
+	if err := rpcdCall(path, "set_plan", strings.NewReader(`{"channels": "1,11"}`), &out, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	var status rpcStatus
+	if err := json.Unmarshal(out.Bytes(), &status); err != nil || status.Plan != "1,11" {
+		t.Fatalf("call set_plan: %v, %v", out.String(), err)
+	}
+
+	// rpcd calls status without arguments
+	out.Reset()
+	if err := rpcdCall(path, "status", strings.NewReader(""), &out, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	failures := []struct {
+		socket string
+		method string
+		args   string
+	}{
+		{path, "set_delay", `{"delay": 1}`},
+		{path, "status", `{"channels`},
+		{filepath.Join(t.TempDir(), "missing.sock"), "status", ""},
+	}
+	for _, tt := range failures {
+		out.Reset()
+		if err := rpcdCall(tt.socket, tt.method, strings.NewReader(tt.args), &out, time.Second); err == nil {
+			t.Fatalf("call %v %v did not fail", tt.method, tt.args)
+		}
+		var result rpcdError
+		if err := json.Unmarshal(out.Bytes(), &result); err != nil || result.Error == "" {
+			t.Fatalf("call %v %v: %v", tt.method, tt.args, out.String())
+		}
+	}
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+// minSignal is the weakest signal, in dBm, of the beacons that --min-rssi
+// lets the targets, the inventory and --qbss see, 0 accepting all of them.
+// In dense areas it keeps distant networks from steering the hopper.
+type minSignal int
+
+// accepts reports whether packet is strong enough. Frames without a signal,
+// from drivers that do not report it, are accepted.
+func (m minSignal) accepts(packet dot11.Packet) bool {
+	return m == 0 || !packet.Radiotap.HasSignal || packet.Radiotap.Signal >= int(m)
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+func TestMinSignal(t *testing.T) {
+	weak := testBeaconSignal(t, testAP, 6, -80)
+	near := testBeaconSignal(t, testAP, 6, -60)
+	packet, err := dot11.Decode(testBeacon(testAP, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		min    minSignal
+		packet dot11.Packet
+		want   bool
+	}{
+		{0, weak, true},
+		{-75, weak, false},
+		{-75, near, true},
+		{-60, near, true},
+		// Without a signal the frame cannot be judged
+		{-75, packet, true},
+	}
+	for _, tt := range tests {
+		if got := tt.min.accepts(tt.packet); got != tt.want {
+			t.Errorf("minSignal(%v).accepts(%v dBm) = %v, want %v", tt.min, tt.packet.Radiotap.Signal, got, tt.want)
+		}
+	}
+}
+
+func TestInventoryMinSignal(t *testing.T) {
+	inv := newInventory()
+	inv.minSignal = -75
+	inv.add(6, testBeaconSignal(t, testAP, 6, -80), time.Now())
+	if entries := inv.sorted(); len(entries) != 0 {
+		t.Fatalf("the inventory kept a distant network: %+v", entries)
+	}
+	inv.add(6, testBeaconSignal(t, testAP, 6, -70), time.Now())
+	if entries := inv.sorted(); len(entries) != 1 || entries[0].MaxSignal != -70 {
+		t.Fatalf("entries: %+v", entries)
+	}
+}
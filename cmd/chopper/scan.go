@@ -0,0 +1,83 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// printNewScanResults prints results whose BSSID is not in seen yet.
+func printNewScanResults(results []nl80211util.BSS, seen map[string]bool) {
+	for _, result := range results {
+		if seen[result.BSSID.String()] {
+			continue
+		}
+		seen[result.BSSID.String()] = true
+
+		emitBSS(result)
+		if jsonOutput() {
+			continue
+		}
+		fmt.Printf("%v  %-5s %4v MHz  %7.2f dBm  %v\n", result.BSSID, channelLabel(plan.ChannelOf(result.Frequency)), result.Frequency, result.Signal, result.SSID)
+	}
+}
+
+// runScanMode repeatedly asks the firmware to scan the given channels and
+// prints every newly discovered BSS.
+func runScanMode(ctx context.Context, client *nl80211util.Client, ifindex int, channels []int, pause time.Duration) error {
+	events, err := client.ScanEvents()
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	frequencies := plan.Frequencies(channels)
+	seen := make(map[string]bool)
+	for ctx.Err() == nil {
+		if err := client.TriggerScan(ifindex, frequencies); err != nil {
+			return fmt.Errorf("cannot trigger scan: %v", err)
+		}
+
+		if err := events.WaitScan(ifindex); err == nl80211util.ErrScanAborted {
+			_, _ = fmt.Fprintf(stderr, "WARNING: scan aborted, retrying.\n")
+			continue
+		} else if err == nl80211util.ErrEventsLost {
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v, reading the results anyway.\n", err)
+		} else if err != nil {
+			return fmt.Errorf("cannot wait for scan: %v", err)
+		}
+
+		results, err := client.ScanResults(ifindex)
+		if err != nil {
+			return fmt.Errorf("cannot get scan results: %v", err)
+		}
+
+		printNewScanResults(results, seen)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(pause):
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// schedScanPoll is how often we wake up to check if we should stop.
+const schedScanPoll = time.Second
+
+// runSchedScanMode lets the firmware sweep the given channels on its own
+// and logs the results every time it reports some.
+func runSchedScanMode(ctx context.Context, client *nl80211util.Client, ifindex int, channels []int, interval time.Duration) error {
+	events, err := client.ScanEvents()
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	if err := client.StartSchedScan(ifindex, plan.Frequencies(channels), interval); err != nil {
+		return fmt.Errorf("cannot start scheduled scan: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for ctx.Err() == nil {
+		command, err := events.Wait(ifindex, time.Now().Add(schedScanPoll),
+			nl80211.CommandSchedScanResults, nl80211.CommandSchedScanStopped)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			continue
+		} else if err == nl80211util.ErrEventsLost {
+			// The results may have been reported meanwhile
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v, reading the results anyway.\n", err)
+		} else if err != nil {
+			_ = client.StopSchedScan(ifindex)
+			return fmt.Errorf("cannot wait for scheduled scan: %v", err)
+		}
+
+		if command == nl80211.CommandSchedScanStopped {
+			return errors.New("scheduled scan stopped by the kernel")
+		}
+
+		results, err := client.ScanResults(ifindex)
+		if err != nil {
+			_ = client.StopSchedScan(ifindex)
+			return fmt.Errorf("cannot get scan results: %v", err)
+		}
+
+		printNewScanResults(results, seen)
+	}
+
+	return client.StopSchedScan(ifindex)
+}
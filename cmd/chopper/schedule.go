@@ -0,0 +1,357 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// scheduleLookback is how far back the entry in effect at startup is
+// searched for.
+const scheduleLookback = 366 * 24 * time.Hour
+
+// schedulePollInterval is how often the schedule file is checked for
+// changes and for entries firing, like the targets file.
+const schedulePollInterval = time.Second
+
+// cronField is the set of values matched by a field of a schedule entry.
+type cronField struct {
+	values uint64
+	// any is set for *, which cron treats specially for the days
+	any bool
+}
+
+func (f cronField) has(value int) bool {
+	return f.values&(1<<uint(value)) != 0
+}
+
+// parseCronField parses a cron field: *, N, N-M and lists of them, each
+// with an optional /step.
+func parseCronField(input string, min, max int) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(input, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return cronField{}, fmt.Errorf("invalid step in %q", input)
+			}
+			part = part[:i]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+			f.any = f.any || step == 1
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return cronField{}, fmt.Errorf("invalid range in %q", input)
+			}
+		default:
+			var err error
+			if start, err = strconv.Atoi(part); err != nil {
+				return cronField{}, fmt.Errorf("invalid value in %q", input)
+			}
+			end = start
+			// N/step runs from N to the end, like cron
+			if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return cronField{}, fmt.Errorf("%q is out of range %v-%v", input, min, max)
+		}
+
+		for value := start; value <= end; value += step {
+			f.values |= 1 << uint(value)
+		}
+	}
+	return f, nil
+}
+
+// scheduleEntry is a line of a schedule file: the plan hopped on from the
+// time matching the cron fields until the next entry.
+type scheduleEntry struct {
+	minute, hour, day, month, weekday cronField
+
+	spec     string
+	channels []int
+}
+
+// matches reports whether the entry fires during the minute of t, in the
+// location of t.
+func (e scheduleEntry) matches(t time.Time) bool {
+	if !e.minute.has(t.Minute()) || !e.hour.has(t.Hour()) || !e.month.has(int(t.Month())) {
+		return false
+	}
+
+	day, weekday := e.day.has(t.Day()), e.weekday.has(int(t.Weekday()))
+	// Like cron, when both days are restricted either one is enough
+	if !e.day.any && !e.weekday.any {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// schedule is the content of a schedule file, in file order.
+type schedule []scheduleEntry
+
+// parseSchedule parses a schedule file: one entry per line with the five
+// fields of a crontab (minute, hour, day of month, month, day of week) and
+// a bundled plan name or a channel list, like --plan and -c. Empty lines and
+// lines starting with # are ignored.
+func parseSchedule(r io.Reader) (schedule, error) {
+	var s schedule
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("line %v: expected 5 time fields and a plan", n)
+		}
+
+		var e scheduleEntry
+		var err error
+		for i, f := range []struct {
+			field    *cronField
+			min, max int
+		}{
+			{&e.minute, 0, 59},
+			{&e.hour, 0, 23},
+			{&e.day, 1, 31},
+			{&e.month, 1, 12},
+			{&e.weekday, 0, 7},
+		} {
+			if *f.field, err = parseCronField(fields[i], f.min, f.max); err != nil {
+				return nil, fmt.Errorf("line %v: %v", n, err)
+			}
+		}
+		// Sunday is both 0 and 7
+		if e.weekday.has(7) {
+			e.weekday.values |= 1
+		}
+
+		e.spec = fields[5]
+		if e.channels, err = schedulePlan(e.spec); err != nil {
+			return nil, fmt.Errorf("line %v: %v", n, err)
+		}
+		s = append(s, e)
+	}
+
+	return s, scanner.Err()
+}
+
+// schedulePlan returns the channels of a bundled plan or a channel list.
+func schedulePlan(spec string) ([]int, error) {
+	if channels, err := plan.Named(spec); err == nil {
+		return channels, nil
+	}
+
+	var channels []int
+	var err error
+	if plan.HasMultipliers(spec) {
+		channels, err = plan.ParseMultipliers(spec, plan.Default())
+	} else {
+		channels, err = plan.Parse(spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("empty plan %q", spec)
+	}
+	return channels, nil
+}
+
+// at returns the entry firing during the minute of t, the last one if several
+// do, or nil.
+func (s schedule) at(t time.Time) *scheduleEntry {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i].matches(t) {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// active returns the entry in effect at now: the one that fired last, or nil
+// if none did in the last year.
+func (s schedule) active(now time.Time) *scheduleEntry {
+	if len(s) == 0 {
+		return nil
+	}
+
+	t := now.Truncate(time.Minute)
+	for limit := now.Add(-scheduleLookback); !t.Before(limit); t = t.Add(-time.Minute) {
+		if e := s.at(t); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// scheduleWatcher follows a schedule file, reloading it when it changes.
+type scheduleWatcher struct {
+	path    string
+	entries schedule
+	modTime time.Time
+	size    int64
+	// minute is the last minute checked for entries firing
+	minute time.Time
+	// check validates the plan of each entry once the radio is known,
+	// files failing it are rejected like invalid ones
+	check func(channels []int) ([]int, error)
+}
+
+func newScheduleWatcher(path string, now time.Time) (*scheduleWatcher, error) {
+	w := &scheduleWatcher{path: path, minute: now.Truncate(time.Minute)}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// reload reads the schedule file if it changed since the last call and
+// reports whether it did. The previous entries are kept if it is invalid.
+func (w *scheduleWatcher) reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+	// Only warn once about an invalid file
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	entries, err := parseSchedule(f)
+	if err != nil {
+		return false, err
+	}
+	if err := w.checkEntries(entries); err != nil {
+		return false, err
+	}
+	w.entries = entries
+	return true, nil
+}
+
+// setCheck sets the check of the plans and applies it to the loaded entries.
+func (w *scheduleWatcher) setCheck(check func(channels []int) ([]int, error)) error {
+	w.check = check
+	return w.checkEntries(w.entries)
+}
+
+// checkEntries replaces the plans of entries with the ones returned by the
+// check, if any.
+func (w *scheduleWatcher) checkEntries(entries schedule) error {
+	if w.check == nil {
+		return nil
+	}
+	for i := range entries {
+		channels, err := w.check(entries[i].channels)
+		if err != nil {
+			return fmt.Errorf("%v: %v", entries[i].spec, err)
+		}
+		entries[i].channels = channels
+	}
+	return nil
+}
+
+// step returns the entry to switch to at now, if any: the one in effect
+// after the file was reloaded, or the one firing at a new minute.
+func (w *scheduleWatcher) step(now time.Time) (*scheduleEntry, error) {
+	changed, err := w.reload()
+
+	minute := now.Truncate(time.Minute)
+	if changed {
+		w.minute = minute
+		return w.entries.active(now), nil
+	}
+	if !minute.After(w.minute) {
+		return nil, err
+	}
+	w.minute = minute
+	return w.entries.at(minute), err
+}
+
+// watch switches the plan of h when an entry fires or the file changes,
+// until ctx is done. Plans are interleaved if interleave is set.
+func (w *scheduleWatcher) watch(ctx context.Context, h planner, interleave bool) {
+	ticker := time.NewTicker(schedulePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		e, err := w.step(time.Now())
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: schedule: %v\n", err)
+		}
+		if e == nil {
+			continue
+		}
+		if err := switchSchedule(h, e, interleave); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: schedule: %v\n", err)
+		}
+	}
+}
+
+// switchSchedule hops on the plan of e, unless h already is.
+func switchSchedule(h planner, e *scheduleEntry, interleave bool) error {
+	channels := e.channels
+	if interleave {
+		channels = plan.Interleave(channels)
+	}
+	if reflect.DeepEqual(channels, h.Channels()) {
+		return nil
+	}
+	if err := h.SetChannels(channels); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stderr, "Schedule: hopping on %v (%v)\n", plan.Format(channels), e.spec)
+	return nil
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		input string
+		min   int
+		max   int
+		want  []int
+	}{
+		{"5", 0, 59, []int{5}},
+		{"1-5", 0, 7, []int{1, 2, 3, 4, 5}},
+		{"*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"8-18/4,22", 0, 23, []int{8, 12, 16, 22}},
+		{"10/20", 0, 59, []int{10, 30, 50}},
+	}
+	for _, test := range tests {
+		f, err := parseCronField(test.input, test.min, test.max)
+		if err != nil {
+			t.Errorf("parseCronField(%q): %v", test.input, err)
+			continue
+		}
+		var got []int
+		for value := test.min; value <= test.max; value++ {
+			if f.has(value) {
+				got = append(got, value)
+			}
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("parseCronField(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+
+	for _, input := range []string{"60", "5-1", "*/0", "a", "1-b", ""} {
+		if _, err := parseCronField(input, 0, 59); err == nil {
+			t.Errorf("parseCronField(%q) did not fail", input)
+		}
+	}
+}
+
+const testSchedule = `
+# business hours on 2.4 GHz, everything at night
+0 8 * * 1-5    1x3,6x3,11x3,rest
+0 19 * * *     1,6,11,36,52,100
+30 12 1 * *    non-overlapping
+`
+
+func TestParseSchedule(t *testing.T) {
+	s, err := parseSchedule(strings.NewReader(testSchedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 3 {
+		t.Fatalf("parsed %v entries, want 3", len(s))
+	}
+	if !reflect.DeepEqual(s[1].channels, []int{1, 6, 11, 36, 52, 100}) || s[2].spec != "non-overlapping" || len(s[2].channels) == 0 {
+		t.Errorf("entries: %+v", s)
+	}
+
+	for _, input := range []string{
+		"0 8 * * 1-5",
+		"0 24 * * * 1,6,11",
+		"0 8 * * * no-such-plan",
+	} {
+		if _, err := parseSchedule(strings.NewReader(input)); err == nil {
+			t.Errorf("parseSchedule(%q) did not fail", input)
+		}
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	s, err := parseSchedule(strings.NewReader(testSchedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Friday 1 October 2021
+	day := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at   time.Duration
+		want string
+	}{
+		// Thursday night
+		{7 * time.Hour, "1,6,11,36,52,100"},
+		{8 * time.Hour, "1x3,6x3,11x3,rest"},
+		// The first day of the month wins over business hours
+		{12*time.Hour + 45*time.Minute, "non-overlapping"},
+		{19*time.Hour + time.Second, "1,6,11,36,52,100"},
+		// Sunday morning
+		{2*24*time.Hour + 10*time.Hour, "1,6,11,36,52,100"},
+	}
+	for _, test := range tests {
+		e := s.active(day.Add(test.at))
+		if e == nil || e.spec != test.want {
+			t.Errorf("active at %v: %+v, want %v", day.Add(test.at), e, test.want)
+		}
+	}
+
+	if e := s.at(day.Add(8*time.Hour + time.Minute)); e != nil {
+		t.Errorf("an entry fired at 08:01: %+v", e)
+	}
+	if e := (schedule{}).active(day); e != nil {
+		t.Errorf("empty schedule has an active entry: %+v", e)
+	}
+}
+
+func TestScheduleDays(t *testing.T) {
+	// Either day restriction is enough, and 7 is Sunday
+	s, err := parseSchedule(strings.NewReader("0 0 15 * 7 1,6,11"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		day  int
+		want bool
+	}{
+		{15, true}, // Friday
+		{17, true}, // Sunday
+		{18, false},
+	} {
+		at := time.Date(2021, 10, test.day, 0, 0, 0, 0, time.UTC)
+		if got := s[0].matches(at); got != test.want {
+			t.Errorf("matches on %v = %v, want %v", at, got, test.want)
+		}
+	}
+}
+
+func TestScheduleWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule")
+	if err := ioutil.WriteFile(path, []byte(testSchedule), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2021, 10, 4, 7, 59, 30, 0, time.UTC)
+	w, err := newScheduleWatcher(path, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := w.step(start.Add(10 * time.Second)); e != nil || err != nil {
+		t.Errorf("step in the same minute: %+v, %v", e, err)
+	}
+	if e, _ := w.step(start.Add(30 * time.Second)); e == nil || e.spec != "1x3,6x3,11x3,rest" {
+		t.Errorf("step at 08:00: %+v", e)
+	}
+	if e, _ := w.step(start.Add(40 * time.Second)); e != nil {
+		t.Errorf("entry fired twice: %+v", e)
+	}
+
+	// A changed file applies the entry in effect right away
+	if err := ioutil.WriteFile(path, []byte("0 6 * * * 1,6,11\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := w.step(start.Add(50 * time.Second)); err != nil || e == nil || e.spec != "1,6,11" {
+		t.Errorf("step after a change: %+v, %v", e, err)
+	}
+
+	// An invalid file keeps the previous entries and warns once
+	if err := ioutil.WriteFile(path, []byte("0 6 * *\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.step(start.Add(55 * time.Second)); err == nil {
+		t.Error("invalid file did not fail")
+	}
+	if _, err := w.step(start.Add(56 * time.Second)); err != nil {
+		t.Errorf("invalid file warned twice: %v", err)
+	}
+	if len(w.entries) != 1 || w.entries[0].spec != "1,6,11" {
+		t.Errorf("entries after an invalid file: %+v", w.entries)
+	}
+}
+
+func TestScheduleWatcherCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule")
+	if err := ioutil.WriteFile(path, []byte("0 6 * * * 1,6,14\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 10, 4, 7, 59, 30, 0, time.UTC)
+	w, err := newScheduleWatcher(path, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drop14 := func(channels []int) ([]int, error) {
+		var kept []int
+		for _, channel := range channels {
+			if channel != 14 {
+				kept = append(kept, channel)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, errors.New("no channels left")
+		}
+		return kept, nil
+	}
+	if err := w.setCheck(drop14); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 6}; !reflect.DeepEqual(w.entries[0].channels, want) {
+		t.Errorf("checked channels:\n- want: %v\n-  got: %v", want, w.entries[0].channels)
+	}
+
+	// Reloaded files are checked too, and rejected if the check fails
+	if err := ioutil.WriteFile(path, []byte("0 6 * * * 1,6,11\n0 7 * * * 14\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.step(start.Add(50 * time.Second)); err == nil {
+		t.Error("file failing the check was accepted")
+	}
+	if len(w.entries) != 1 || w.entries[0].spec != "1,6,14" {
+		t.Errorf("entries after a failed check: %+v", w.entries)
+	}
+}
+
+func TestSwitchSchedule(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	e := &scheduleEntry{spec: "1,36,6,40", channels: []int{1, 36, 6, 40}}
+
+	if err := switchSchedule(p, e, false); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.channels, []int{1, 36, 6, 40}) {
+		t.Errorf("plan %v after switching", p.channels)
+	}
+
+	e = &scheduleEntry{spec: "1,6,36,40", channels: []int{1, 6, 36, 40}}
+	if err := switchSchedule(p, e, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.channels, []int{1, 36, 6, 40}) {
+		t.Errorf("plan %v after switching with interleave", p.channels)
+	}
+
+	// A switch while locked is hopped once the lock is released
+	shared := newSharedPlan(p)
+	l := &channelLock{plan: shared, reason: "test", timeout: time.Minute}
+	if err := l.lock(6, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	e = &scheduleEntry{spec: "1,11", channels: []int{1, 11}}
+	if err := switchSchedule(shared, e, false); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.channels, []int{6}) {
+		t.Errorf("plan %v after switching while locked", p.channels)
+	}
+	if err := l.release(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.channels, []int{1, 11}) {
+		t.Errorf("plan %v after releasing the lock", p.channels)
+	}
+}
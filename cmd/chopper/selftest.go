@@ -0,0 +1,175 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+var errSelfTestNoFrames = errors.New("no frames received on any channel, the adapter is probably not delivering frames in monitor mode")
+
+// selfTest verifies during the first cycle that frames are actually received
+// after each retune. Frames are captured in the background and accounted to
+// the channel set by the last call to setChannel.
+type selfTest struct {
+	capture   *captureSocket
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	mu    sync.Mutex
+	tuned int
+	// frames counts the frames received while tuned to a channel
+	frames map[int]int
+	// advertised holds the channels seen in beacons' DS Parameter Set
+	advertised map[int]bool
+}
+
+func newSelfTest(ifindex int) (*selfTest, error) {
+	capture, err := openCapture(ifindex, 10*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &selfTest{
+		capture:    capture,
+		done:       make(chan struct{}),
+		frames:     make(map[int]int),
+		advertised: make(map[int]bool),
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+
+	return s, nil
+}
+
+func (s *selfTest) loop() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65536)
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		n, err := s.capture.Read(buf)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: self-test: %v\n", err)
+			return
+		}
+		if n == 0 {
+			continue
+		}
+
+		packet, err := dot11.Decode(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.tuned != 0 {
+			s.observe(s.tuned, packet)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *selfTest) setChannel(channel int) {
+	s.mu.Lock()
+	s.tuned = channel
+	s.mu.Unlock()
+}
+
+func (s *selfTest) observe(tuned int, packet dot11.Packet) {
+	s.frames[tuned]++
+
+	if packet.Frame.Type == dot11.TypeManagement && packet.Frame.Subtype == dot11.SubtypeBeacon {
+		if beacon, err := dot11.ParseBeacon(packet.Frame.Body); err == nil && beacon.Channel() != 0 {
+			s.advertised[beacon.Channel()] = true
+		}
+	}
+}
+
+// result returns the channels known to be active (because some beacon
+// advertised them) on which nothing was received.
+func (s *selfTest) result(channels []int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, n := range s.frames {
+		total += n
+	}
+	if total == 0 {
+		return nil, errSelfTestNoFrames
+	}
+
+	silent := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, channel := range channels {
+		if seen[channel] {
+			continue
+		}
+		seen[channel] = true
+
+		if s.advertised[channel] && s.frames[channel] == 0 {
+			silent = append(silent, channel)
+		}
+	}
+	sort.Ints(silent)
+
+	return silent, nil
+}
+
+// report prints the outcome of the self-test and returns an error if the
+// adapter looks broken.
+func (s *selfTest) report(channels []int) error {
+	silent, err := s.result(channels)
+	if err != nil {
+		return err
+	}
+
+	if len(silent) > 0 {
+		_, _ = fmt.Fprintf(stderr, "WARNING: self-test: no frames received on active channels %v, the adapter may not be retuning.\n", plan.Format(silent))
+	} else {
+		_, _ = fmt.Fprintf(stderr, "Self-test passed.\n")
+	}
+
+	return nil
+}
+
+// Close stops the capture. It is safe to call it more than once.
+func (s *selfTest) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		err = s.capture.Close()
+	})
+
+	return err
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+func TestSelfTestResult(t *testing.T) {
+	ap1 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x01}
+	ap6 := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x06}
+
+	s := &selfTest{
+		frames:     make(map[int]int),
+		advertised: make(map[int]bool),
+	}
+	if _, err := s.result([]int{1, 6, 11}); err != errSelfTestNoFrames {
+		t.Fatalf("result with no frames:\n- want: %v\n-  got: %v", errSelfTestNoFrames, err)
+	}
+
+	// Channel 6 leaks into 5 but nothing is received once tuned to 6
+	for _, f := range []struct {
+		tuned int
+		frame []byte
+	}{
+		{tuned: 1, frame: testBeacon(ap1, 1)},
+		{tuned: 5, frame: testBeacon(ap6, 6)},
+	} {
+		packet, err := dot11.Decode(f.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.observe(f.tuned, packet)
+	}
+
+	silent, err := s.result([]int{1, 5, 6, 11, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []int{6}, silent; !reflect.DeepEqual(want, got) {
+		t.Fatalf("result:\n- want: %v\n-  got: %v", want, got)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+)
+
+// errSimulationDone stops the hopper after the requested cycles.
+var errSimulationDone = errors.New("simulation done")
+
+// simulation hops without a radio, on any platform, to try plans and
+// strategies or to develop against the events.
+type simulation struct {
+	channels []int
+	delay    time.Duration
+	strategy hopper.Strategy
+	dwell    hopper.DwellController
+	// cycles stops the simulation after that many cycles, 0 never does
+	cycles int
+	// fast runs on a simulated clock, as fast as possible
+	fast bool
+	json bool
+}
+
+// run hops until ctx is done or the cycles are over, writing the hops to
+// out.
+func (s simulation) run(ctx context.Context, out io.Writer) error {
+	var clock hopper.Clock = hopper.RealClock()
+	if s.fast {
+		clock = hopper.NewSimulatedClock(time.Now())
+	}
+	enc := events.NewEncoder(out)
+	write := func(event events.Event, at time.Time) {
+		event.Time = at.UTC()
+		_ = enc.Encode(event)
+	}
+
+	hops := 0
+	var sequence events.HopSequence
+	// The simulated clock moves to the end of the dwell before OnHop
+	var hopped time.Time
+	config := hopper.Config{
+		Channels: s.channels,
+		Delay:    s.delay,
+		Strategy: s.strategy,
+		Dwell:    s.dwell,
+		Clock:    clock,
+		BeforeHop: func(int) {
+			hopped = clock.Now()
+		},
+		OnHop: func(channel int) {
+			hops++
+			if s.json {
+				event := events.New(events.TypeHop)
+				event.Channel = channel
+				event.Frequency = plan.Frequency(channel)
+				sequence.Next(&event)
+				write(event, hopped)
+				return
+			}
+			_, _ = fmt.Fprintf(out, "%v %v\n", hopped.Format("15:04:05.000"), describeFrequency(plan.Frequency(channel)))
+		},
+		OnCycle: func(cycle int) error {
+			if s.json {
+				event := events.New(events.TypeCycle)
+				event.Cycle = cycle
+				write(event, clock.Now())
+			}
+			if s.cycles > 0 && cycle >= s.cycles {
+				return errSimulationDone
+			}
+			return nil
+		},
+	}
+	h, err := hopper.New(hopper.TunerFunc(func(int) error { return nil }), config)
+	if err != nil {
+		return err
+	}
+
+	start := clock.Now()
+	if s.json {
+		event := events.New(events.TypeStart)
+		event.Channels = s.channels
+		event.DelayMs = s.delay.Milliseconds()
+		write(event, start)
+	}
+	err = h.Run(ctx)
+	if errors.Is(err, errSimulationDone) {
+		err = nil
+	}
+	if s.json {
+		write(events.New(events.TypeStop), clock.Now())
+	} else {
+		_, _ = fmt.Fprintf(out, "Simulated %v hops in %v\n", hops, clock.Now().Sub(start).Round(time.Millisecond))
+	}
+	return err
+}
+
+// runSimulate implements the simulate subcommand and returns the exit
+// code.
+func runSimulate(ctx context.Context, args []string) int {
+	var (
+		channelsString string
+		planName       string
+		delay          int
+		strategy       string
+		dwellMode      string
+		jitter         float64
+		seed           int64
+		outputFormat   string
+		s              simulation
+	)
+
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: %v simulate [options]\n\n", ProgramName)
+		_, _ = fmt.Fprintf(stderr, "Hop without a radio, on any system, printing the hops or their events.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVarP(&channelsString, "channels", "c", "", "comma-separated list of channels, with the syntax of chopper -c")
+	flags.StringVar(&planName, "plan", "", "use a named channel plan")
+	flags.IntVarP(&delay, "delay", "d", 100, "delay between each hop in milliseconds")
+	flags.StringVar(&strategy, "strategy", "sequential", "order of the channels: sequential or shuffle")
+	flags.StringVar(&dwellMode, "dwell", "fixed", "time spent on each channel: fixed or jitter")
+	flags.Float64Var(&jitter, "jitter", 0.2, "with --dwell jitter, the largest change of the delay as a fraction of it")
+	flags.Int64Var(&seed, "seed", 0, "seed of the shuffle and jitter, 0 for a random one")
+	flags.IntVar(&s.cycles, "cycles", 0, "stop after this many cycles, 0 runs until interrupted")
+	flags.BoolVar(&s.fast, "fast", false, "run on a simulated clock as fast as possible, needs --cycles")
+	flags.StringVar(&outputFormat, "output", "text", "output format: text or json (chopper events)")
+	_ = flags.Parse(args)
+
+	if delay <= 0 || s.cycles < 0 || (s.fast && s.cycles == 0) {
+		flags.Usage()
+		return 1
+	}
+	switch outputFormat {
+	case "text":
+	case "json":
+		s.json = true
+	default:
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown output format %v, expected text or json\n", outputFormat)
+		return 1
+	}
+
+	s.channels = parseChannels(channelsString, plan.Default())
+	if planName != "" {
+		named, err := plan.Named(planName)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		s.channels = named
+	}
+	s.delay = time.Duration(delay) * time.Millisecond
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	switch strategy {
+	case "sequential":
+	case "shuffle":
+		s.strategy = hopper.Shuffled{Rand: rand.New(rand.NewSource(seed))}
+	default:
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown strategy %v, expected sequential or shuffle\n", strategy)
+		return 1
+	}
+	if dwellMode == "traffic" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --dwell traffic needs frames, which a simulation has none of\n")
+		return 1
+	}
+	var err error
+	if s.dwell, err = dwellController(dwellMode, jitter, seed, nil, 0); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if err := s.run(ctx, os.Stdout); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	return 0
+}
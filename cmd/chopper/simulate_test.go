@@ -0,0 +1,90 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+)
+
+func TestSimulation(t *testing.T) {
+	var out bytes.Buffer
+	s := simulation{channels: []int{1, 6, 11}, delay: 250 * time.Millisecond, cycles: 2, fast: true, json: true}
+	if err := s.run(context.Background(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	var hops []int
+	var times []time.Time
+	var gaps events.Gaps
+	previous := 0
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var event events.Event
+		if err := dec.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, event.Type)
+		if event.Type == events.TypeHop {
+			hops = append(hops, event.Channel)
+			times = append(times, event.Time)
+			if event.Seq != uint64(len(hops)) || event.Previous != previous {
+				t.Fatalf("hop %v: seq %v, previous %v", len(hops), event.Seq, event.Previous)
+			}
+			previous = event.Channel
+		}
+		if gap := gaps.Observe(event); gap != (events.Gap{}) {
+			t.Fatalf("gap before %+v: %+v", event, gap)
+		}
+	}
+
+	if want := []int{1, 6, 11, 1, 6, 11}; !reflect.DeepEqual(hops, want) {
+		t.Fatalf("hops: %v, want %v", hops, want)
+	}
+	if types[0] != events.TypeStart || types[len(types)-1] != events.TypeStop {
+		t.Fatalf("events: %v", types)
+	}
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d != s.delay {
+			t.Fatalf("hop %v after %v, want %v", i, d, s.delay)
+		}
+	}
+}
+
+func TestSimulationText(t *testing.T) {
+	var out bytes.Buffer
+	s := simulation{channels: []int{36, 40}, delay: 100 * time.Millisecond, cycles: 1, fast: true}
+	if err := s.run(context.Background(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], " channel 36 (5180 MHz)") || !strings.HasSuffix(lines[1], " channel 40 (5200 MHz)") {
+		t.Fatalf("output:\n%v", out.String())
+	}
+	if want := "Simulated 2 hops in 200ms"; lines[2] != want {
+		t.Fatalf("summary %q, want %q", lines[2], want)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	"github.com/giacomoferretti/chopper-go/pkg/spectral"
+)
+
+// spectralPoll is how often the relay file is read when it is empty.
+const spectralPoll = 50 * time.Millisecond
+
+// spectralMonitor aggregates FFT samples per channel, by the frequency
+// they report, for the current dwell and the whole run.
+type spectralMonitor struct {
+	mu    sync.Mutex
+	dwell map[int]*spectral.Summary
+	total map[int]*spectral.Summary
+}
+
+func newSpectralMonitor() *spectralMonitor {
+	return &spectralMonitor{
+		dwell: make(map[int]*spectral.Summary),
+		total: make(map[int]*spectral.Summary),
+	}
+}
+
+func summaryOf(summaries map[int]*spectral.Summary, channel int) *spectral.Summary {
+	s, ok := summaries[channel]
+	if !ok {
+		s = &spectral.Summary{}
+		summaries[channel] = s
+	}
+	return s
+}
+
+// observe accounts samples.
+func (m *spectralMonitor) observe(samples []spectral.Sample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sample := range samples {
+		channel := plan.ChannelOf(sample.Frequency)
+		if channel == 0 {
+			continue
+		}
+		summaryOf(m.dwell, channel).Add(sample)
+		summaryOf(m.total, channel).Add(sample)
+	}
+}
+
+// flush returns a spectral event per channel sampled since the last flush.
+func (m *spectralMonitor) flush(now time.Time) []events.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ret := make([]events.Event, 0, len(m.dwell))
+	for channel, s := range m.dwell {
+		event := events.New(events.TypeSpectral)
+		event.Time = now.UTC()
+		event.Channel = channel
+		event.Frequency = plan.Frequency(channel)
+		event.Samples = s.Samples
+		event.Signal = s.Signal()
+		event.Noise = s.Noise()
+		event.Magnitude = s.MaxMagnitude
+		ret = append(ret, event)
+	}
+	m.dwell = make(map[int]*spectral.Summary)
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Channel < ret[j].Channel
+	})
+	return ret
+}
+
+// beforeHop is registered as a BeforeHop callback.
+func (m *spectralMonitor) beforeHop(int) {
+	for _, event := range m.flush(time.Now()) {
+		emit(event)
+	}
+}
+
+// writeTable writes the summary of every sampled channel.
+func (m *spectralMonitor) writeTable(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channels := make([]int, 0, len(m.total))
+	for channel := range m.total {
+		channels = append(channels, channel)
+	}
+	sort.Ints(channels)
+
+	_, _ = fmt.Fprintf(w, "Spectral scan: %v channels\n", len(channels))
+	if len(channels) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, paint(term != nil && term.color, ansiBold, fmt.Sprintf("%-9s%-10s%-9s%-12s%-8s%s", "CHANNEL", "SAMPLES", "SIGNAL", "MAX SIGNAL", "NOISE", "MAGNITUDE")))
+	for _, channel := range channels {
+		s := m.total[channel]
+		_, _ = fmt.Fprintf(w, "%-9s%-10d%-9.1f%-12d%-8.1f%d\n", channelLabel(channel), s.Samples, s.Signal(), s.MaxSignal, s.Noise(), s.MaxMagnitude)
+	}
+}
+
+// spectralScan runs the spectral scan of a radio in the background.
+type spectralScan struct {
+	scanner *spectral.Scanner
+	samples io.ReadCloser
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// startSpectralScan enables the spectral scan of phy and passes its
+// samples to m. The raw records are copied to raw if it is not nil.
+func startSpectralScan(phy int, m *spectralMonitor, raw io.Writer) (*spectralScan, error) {
+	scanner, err := spectral.Open(phy)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := scanner.Samples()
+	if err != nil {
+		return nil, err
+	}
+	if err := scanner.Start(); err != nil {
+		_ = samples.Close()
+		return nil, err
+	}
+
+	s := &spectralScan{
+		scanner: scanner,
+		samples: samples,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	var r io.Reader = samples
+	if raw != nil {
+		r = io.TeeReader(samples, raw)
+	}
+	go s.read(spectral.NewReader(r), m)
+	return s, nil
+}
+
+func (s *spectralScan) read(r *spectral.Reader, m *spectralMonitor) {
+	defer close(s.done)
+
+	warned := false
+	for {
+		samples, err := r.Read()
+		m.observe(samples)
+		if err != nil && err != io.EOF && !warned {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot read spectral samples: %v\n", err)
+			warned = true
+		}
+
+		// Read again right away while samples are pending
+		var wait time.Duration
+		if err != nil {
+			wait = spectralPoll
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// hop is registered as an OnHop callback, the scan is restarted on every
+// channel.
+func (s *spectralScan) hop(int) {
+	if err := s.scanner.Trigger(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+	}
+}
+
+// Close disables the scan.
+func (s *spectralScan) Close() error {
+	close(s.stop)
+	<-s.done
+	err := s.scanner.Stop()
+	if closeErr := s.samples.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
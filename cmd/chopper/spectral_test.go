@@ -0,0 +1,66 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/spectral"
+)
+
+func TestSpectralMonitor(t *testing.T) {
+	m := newSpectralMonitor()
+	m.observe([]spectral.Sample{
+		{Frequency: 2437, RSSI: 20, Noise: -95, MaxMagnitude: 100},
+		{Frequency: 2437, RSSI: 10, Noise: -93, MaxMagnitude: 300},
+		{Frequency: 5180, RSSI: 5, Noise: -100, MaxMagnitude: 50},
+		// Not the center of a channel
+		{Frequency: 2410, RSSI: 5, Noise: -100},
+	})
+
+	now := time.Unix(1633089600, 0)
+	got := m.flush(now)
+	if len(got) != 2 {
+		t.Fatalf("got %v events, want 2", len(got))
+	}
+	want := events.Event{Version: events.SchemaVersion, Type: events.TypeSpectral, Time: now.UTC(), Channel: 6, Frequency: 2437, Samples: 2, Signal: -79, Noise: -94, Magnitude: 300}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("event %+v, want %+v", got[0], want)
+	}
+	if got[1].Channel != 36 || got[1].Samples != 1 {
+		t.Errorf("event %+v, want channel 36", got[1])
+	}
+	if got := m.flush(now); len(got) != 0 {
+		t.Errorf("got %v events after flush, want none", len(got))
+	}
+
+	m.observe([]spectral.Sample{{Frequency: 2437, RSSI: 40, Noise: -95, MaxMagnitude: 10}})
+	var out bytes.Buffer
+	m.writeTable(&out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[0] != "Spectral scan: 2 channels" {
+		t.Fatalf("table:\n%v", out.String())
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "6 3 -71.0 -55 -94.3 300" {
+		t.Errorf("channel 6 row %q", lines[2])
+	}
+}
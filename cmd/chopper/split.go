@@ -0,0 +1,160 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// bandsOf returns how many bands the channels span.
+func bandsOf(channels []int) int {
+	bands := make(map[plan.Band]bool)
+	for _, channel := range channels {
+		if band := plan.BandOf(channel); band != plan.BandUnknown {
+			bands[band] = true
+		}
+	}
+	return len(bands)
+}
+
+// distributeBands is distributePlan preferring the radios that support the
+// fewest bands: 2.4 GHz channels go to the 2.4 GHz only radios, leaving the
+// dual band ones to the 5 and 6 GHz channels.
+func distributeBands(global []int, supported [][]int) (plans [][]int, missing []int) {
+	rank := make([]int, len(supported))
+	for i, channels := range supported {
+		rank[i] = bandsOf(channels)
+	}
+	return distribute(global, supported, rank)
+}
+
+// bandSplit spreads a global plan over the radios of chopper multi, and
+// again over the remaining ones when a radio fails.
+type bandSplit struct {
+	global []int
+
+	mu        sync.Mutex
+	radios    []*radio
+	supported [][]int
+	failed    []bool
+}
+
+// newBandSplit queries the channels every radio supports.
+func newBandSplit(global []int, radios []*radio) (*bandSplit, error) {
+	s := &bandSplit{global: global}
+	for _, r := range radios {
+		supported, err := supportedChannels(r)
+		if err != nil {
+			return nil, err
+		}
+		s.add(r, supported)
+	}
+	return s, nil
+}
+
+// supportedChannels queries the channels the radio can tune to.
+func supportedChannels(r *radio) ([]int, error) {
+	status, err := radioCapabilities(r.client, r.phy)
+	if err != nil {
+		return nil, fmt.Errorf("%v: cannot query capabilities: %v", r.name, err)
+	}
+	return status.Channels, nil
+}
+
+// add adds a radio supporting channels, which gets its share of the plan at
+// the next apply.
+func (s *bandSplit) add(r *radio, supported []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.radios = append(s.radios, r)
+	s.supported = append(s.supported, supported)
+	s.failed = append(s.failed, false)
+}
+
+// running reports whether a radio that has not failed uses the interface
+// name or the radio phy.
+func (s *bandSplit) running(name string, phy int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.radios {
+		if !s.failed[i] && (r.name == name || r.phy == phy) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the share of the global plan of every radio still running.
+// Radios left without channels keep their plan.
+func (s *bandSplit) apply() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	supported := make([][]int, len(s.radios))
+	for i := range s.radios {
+		if !s.failed[i] {
+			supported[i] = s.supported[i]
+		}
+	}
+
+	plans, missing := distributeBands(s.global, supported)
+	if len(missing) > 0 {
+		_, _ = fmt.Fprintf(stderr, "WARNING: no radio supports channels %v\n", plan.Format(missing))
+	}
+	for i, r := range s.radios {
+		if s.failed[i] {
+			continue
+		}
+		if len(plans[i]) == 0 {
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v gets no channel of the plan, keeping %v\n", r.name, plan.Format(r.hopper.Channels()))
+			continue
+		}
+		_ = r.hopper.SetChannels(plans[i])
+		_, _ = fmt.Fprintf(stderr, "%v: hopping on %v\n", r.name, plan.Format(plans[i]))
+	}
+}
+
+// fail moves the channels of a failed radio to the others, reporting
+// whether any is left.
+func (s *bandSplit) fail(r *radio) bool {
+	s.mu.Lock()
+	left := 0
+	for i, other := range s.radios {
+		if other == r {
+			s.failed[i] = true
+		}
+		if !s.failed[i] {
+			left++
+		}
+	}
+	s.mu.Unlock()
+
+	if left == 0 {
+		return false
+	}
+	_, _ = fmt.Fprintf(stderr, "WARNING: %v stopped, splitting the plan among the %v remaining radios\n", r.name, left)
+	s.apply()
+	return true
+}
@@ -0,0 +1,70 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistributeBands(t *testing.T) {
+	only2GHz := []int{1, 6, 11}
+	dualBand := []int{1, 6, 11, 36, 40, 44, 48}
+
+	plans, missing := distributeBands([]int{1, 36, 6, 40, 11, 44, 149}, [][]int{dualBand, only2GHz})
+	want := [][]int{{36, 40, 44}, {1, 6, 11}}
+	if !reflect.DeepEqual(plans, want) || !reflect.DeepEqual(missing, []int{149}) {
+		t.Fatalf("distributeBands = %v, %v, want %v, [149]", plans, missing, want)
+	}
+
+	// Radios supporting the same bands share the load
+	plans, _ = distributeBands([]int{36, 40, 44, 48}, [][]int{dualBand, dualBand, only2GHz})
+	if want := [][]int{{36, 44}, {40, 48}, nil}; !reflect.DeepEqual(plans, want) {
+		t.Fatalf("distributeBands = %v, want %v", plans, want)
+	}
+}
+
+func TestBandSplitFail(t *testing.T) {
+	wlan0 := testRadio(t, "wlan0mon", 0, []int{1})
+	wlan1 := testRadio(t, "wlan1mon", 1, []int{1})
+	s := &bandSplit{
+		global:    []int{1, 6, 36, 40},
+		radios:    []*radio{wlan0, wlan1},
+		supported: [][]int{{1, 6, 11}, {1, 6, 11, 36, 40}},
+		failed:    make([]bool, 2),
+	}
+	s.apply()
+	if got := wlan0.hopper.Channels(); !reflect.DeepEqual(got, []int{1, 6}) {
+		t.Errorf("plan of the 2.4 GHz radio = %v", got)
+	}
+	if got := wlan1.hopper.Channels(); !reflect.DeepEqual(got, []int{36, 40}) {
+		t.Errorf("plan of the dual band radio = %v", got)
+	}
+
+	if !s.fail(wlan0) {
+		t.Fatal("fail reported no radio left")
+	}
+	if got := wlan1.hopper.Channels(); !reflect.DeepEqual(got, []int{1, 6, 36, 40}) {
+		t.Errorf("plan after the 2.4 GHz radio stopped = %v", got)
+	}
+	if s.fail(wlan1) {
+		t.Error("fail reported radios left after all stopped")
+	}
+}
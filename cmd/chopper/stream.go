@@ -0,0 +1,106 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+)
+
+// streamBuffer is the number of events queued per subscriber. Events are
+// dropped for subscribers that fall further behind.
+const streamBuffer = 256
+
+// eventStream serves the events to the clients of GET /events, one JSON
+// event per line.
+type eventStream struct {
+	mu          sync.Mutex
+	subscribers map[chan events.Event]struct{}
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{subscribers: make(map[chan events.Event]struct{})}
+}
+
+// Encode passes the event to all the subscribers. It never blocks.
+func (s *eventStream) Encode(event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *eventStream) subscribe() chan events.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan events.Event, streamBuffer)
+	s.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (s *eventStream) unsubscribe(ch chan events.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers, ch)
+}
+
+// ServeHTTP streams events until the client disconnects.
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, apiError{"streaming not supported"})
+		return
+	}
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			if err := enc.Encode(event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
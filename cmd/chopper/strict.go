@@ -0,0 +1,100 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// strictChannels parses the channels flag like parseChannels, but fails on
+// tokens that are not channels instead of skipping them.
+func strictChannels(input string, def []int) ([]int, error) {
+	if input == "" {
+		return def, nil
+	}
+	if plan.HasMultipliers(input) {
+		// Terms with multipliers are already parsed strictly
+		return plan.ParseMultipliers(input, def)
+	}
+
+	var channels []int
+	for _, part := range strings.Split(input, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		channel, err := plan.ParseChannel(part)
+		if err != nil {
+			return nil, err
+		}
+		if channel == 0 || plan.Frequency(channel) == 0 {
+			return nil, fmt.Errorf("invalid channel %q", strings.TrimSpace(part))
+		}
+		channels = append(channels, channel)
+	}
+	if len(channels) <= 0 {
+		return nil, fmt.Errorf("no channels in %q", input)
+	}
+	return channels, nil
+}
+
+// checkPlan drops the channels the radio cannot tune to: unknown ones and
+// those disabled by the regulatory domain. In strict mode any of them is an
+// error, otherwise they are skipped with a warning.
+func checkPlan(channels []int, frequencies []nl80211util.WiphyFrequency, strict bool) ([]int, error) {
+	status := capabilitiesOf(0, frequencies)
+	usable := make(map[int]bool, len(status.Channels))
+	for _, channel := range status.Channels {
+		usable[channel] = true
+	}
+	disabled := make(map[int]bool, len(status.Disabled))
+	for _, channel := range status.Disabled {
+		disabled[channel] = true
+	}
+
+	var kept []int
+	var problems []string
+	// Plans with multipliers repeat channels
+	reported := make(map[int]bool)
+	for _, channel := range channels {
+		switch {
+		case usable[channel]:
+			kept = append(kept, channel)
+			continue
+		case reported[channel]:
+			continue
+		case disabled[channel]:
+			problems = append(problems, fmt.Sprintf("channel %v is disabled by the regulatory domain", channel))
+		default:
+			problems = append(problems, fmt.Sprintf("channel %v is not supported by the radio", channel))
+		}
+		reported[channel] = true
+	}
+	if len(problems) > 0 && strict {
+		return nil, fmt.Errorf("invalid plan: %v", strings.Join(problems, ", "))
+	}
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(stderr, "WARNING: %v, skipping it\n", problem)
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("no channel of %v is supported by the radio", plan.Format(channels))
+	}
+	return kept, nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestStrictChannels(t *testing.T) {
+	def := []int{1, 6, 11}
+	tests := []struct {
+		input string
+		want  []int
+		err   bool
+	}{
+		{"", def, false},
+		{"1,36", []int{1, 36}, false},
+		{"1x2,rest", []int{1, 6, 1, 11}, false},
+		{"1x2,foo", nil, true},
+		{",", nil, true},
+		{"1,foo", nil, true},
+		{"1,200", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := strictChannels(tt.input, def)
+		if (err != nil) != tt.err {
+			t.Errorf("strictChannels(%q): unexpected error %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("strictChannels(%q):\n- want: %v\n-  got: %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCheckPlan(t *testing.T) {
+	frequencies := []nl80211util.WiphyFrequency{
+		{Frequency: 2412},
+		{Frequency: 2437},
+		{Frequency: 2484, Disabled: true},
+		{Frequency: 5180},
+	}
+
+	got, err := checkPlan([]int{1, 6, 36}, frequencies, true)
+	if err != nil {
+		t.Fatalf("checkPlan: unexpected error %v", err)
+	}
+	if want := []int{1, 6, 36}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checkPlan:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	channels := []int{1, 14, 6, 149, 14}
+	if _, err := checkPlan(channels, frequencies, true); err == nil {
+		t.Errorf("checkPlan: strict mode accepted %v", channels)
+	} else if !strings.Contains(err.Error(), "channel 14 is disabled") || !strings.Contains(err.Error(), "channel 149 is not supported") {
+		t.Errorf("checkPlan: unexpected error %v", err)
+	}
+
+	got, err = checkPlan(channels, frequencies, false)
+	if err != nil {
+		t.Fatalf("checkPlan: unexpected error %v", err)
+	}
+	if want := []int{1, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checkPlan:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	if _, err := checkPlan([]int{14, 149}, frequencies, false); err == nil {
+		t.Errorf("checkPlan: accepted a plan without supported channels")
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// startSurveySnapshots passes the survey counters to store every interval,
+// in the background. The returned function stops it after a final
+// snapshot.
+func startSurveySnapshots(survey func() ([]nl80211util.SurveyInfo, error), interval time.Duration, store func(at time.Time, info []nl80211util.SurveyInfo) error) func() {
+	snapshot := func() {
+		info, err := survey()
+		if err == nil {
+			err = store(time.Now(), info)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot store survey: %v\n", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				snapshot()
+				return
+			case <-ticker.C:
+				snapshot()
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
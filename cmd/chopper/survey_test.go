@@ -0,0 +1,50 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestSurveySnapshots(t *testing.T) {
+	var calls, stored int32
+	survey := func() ([]nl80211util.SurveyInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		return []nl80211util.SurveyInfo{{Frequency: 2412}}, nil
+	}
+	store := func(at time.Time, info []nl80211util.SurveyInfo) error {
+		if len(info) == 1 && info[0].Frequency == 2412 {
+			atomic.AddInt32(&stored, 1)
+		}
+		return nil
+	}
+
+	stop := startSurveySnapshots(survey, time.Hour, store)
+	stop()
+
+	// Stopping takes a final snapshot.
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("survey calls:\n- want: 1\n-  got: %v", got)
+	}
+	if got := atomic.LoadInt32(&stored); got != 1 {
+		t.Fatalf("stored snapshots:\n- want: 1\n-  got: %v", got)
+	}
+}
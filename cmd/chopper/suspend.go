@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// suspendThreshold is how much the time spent suspended must grow between
+// two hops to be taken for a suspend and not for clock noise.
+const suspendThreshold = time.Second
+
+// resumeTimeout is how long the interface may take to come back after the
+// system resumed, e.g. while a USB adapter is enumerated again.
+const resumeTimeout = 30 * time.Second
+
+// suspendDetector reports the system suspends that happened since it was
+// last checked.
+type suspendDetector struct {
+	read func() (time.Duration, error)
+	last time.Duration
+}
+
+func newSuspendDetector(read func() (time.Duration, error)) *suspendDetector {
+	d := &suspendDetector{read: read}
+	d.last, _ = read()
+	return d
+}
+
+// check returns how long the system slept since the last call, if it did.
+func (d *suspendDetector) check() (time.Duration, bool) {
+	suspended, err := d.read()
+	if err != nil {
+		return 0, false
+	}
+	slept := suspended - d.last
+	d.last = suspended
+	return slept, slept >= suspendThreshold
+}
+
+// suspendTuner recovers from system suspends before retuning: a hop after
+// a resume would otherwise fail on a stale socket or a missing interface.
+type suspendTuner struct {
+	tuner    hopper.Tuner
+	detector *suspendDetector
+	// recover prepares the radio after the system slept
+	recover func(slept time.Duration) error
+}
+
+func (t suspendTuner) SetChannel(channel int) error {
+	if slept, ok := t.detector.check(); ok {
+		if err := t.recover(slept); err != nil {
+			return err
+		}
+	}
+	return t.tuner.SetChannel(channel)
+}
+
+// asyncSuspendTuner is a suspendTuner of an AsyncTuner.
+type asyncSuspendTuner struct {
+	suspendTuner
+}
+
+func (t asyncSuspendTuner) SetChannelAsync(channel int) (<-chan error, error) {
+	if slept, ok := t.detector.check(); ok {
+		if err := t.recover(slept); err != nil {
+			return nil, err
+		}
+	}
+	return t.tuner.(hopper.AsyncTuner).SetChannelAsync(channel)
+}
+
+// newSuspendTuner wraps tuner in a suspendTuner, keeping it asynchronous
+// if it is.
+func newSuspendTuner(tuner hopper.Tuner, detector *suspendDetector, recover func(time.Duration) error) hopper.Tuner {
+	t := suspendTuner{tuner: tuner, detector: detector, recover: recover}
+	if _, ok := tuner.(hopper.AsyncTuner); ok {
+		return asyncSuspendTuner{t}
+	}
+	return t
+}
+
+// resumeRadio reconnects client after the system resumed and waits for
+// iface to be back in monitor mode, updating it in place.
+func resumeRadio(client *nl80211util.Client, iface *nl80211util.Interface, timeout time.Duration) error {
+	if err := client.Redial(); err != nil {
+		return fmt.Errorf("cannot reconnect after resume: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		fresh, err := client.MonitorInterface(iface.Name)
+		if err == nil {
+			if fresh.Index != iface.Index {
+				_, _ = fmt.Fprintf(stderr, "WARNING: %v was recreated while suspended, restart %v if hopping fails\n", iface.Name, ProgramName)
+			}
+			*iface = *fresh
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%v did not come back after resume: %v", iface.Name, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
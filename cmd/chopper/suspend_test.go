@@ -0,0 +1,101 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+func TestSuspendedTime(t *testing.T) {
+	if _, err := suspendedTime(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuspendTuner(t *testing.T) {
+	suspended := 5 * time.Second
+	detector := newSuspendDetector(func() (time.Duration, error) { return suspended, nil })
+
+	var tuned []int
+	var slept []time.Duration
+	fail := false
+	tuner := newSuspendTuner(hopper.TunerFunc(func(channel int) error {
+		tuned = append(tuned, channel)
+		return nil
+	}), detector, func(d time.Duration) error {
+		slept = append(slept, d)
+		if fail {
+			return errors.New("wlan0mon did not come back")
+		}
+		return nil
+	})
+	if _, ok := tuner.(hopper.AsyncTuner); ok {
+		t.Fatal("synchronous tuner became asynchronous")
+	}
+
+	_ = tuner.SetChannel(1)
+	// Clock noise is not a suspend
+	suspended += 10 * time.Millisecond
+	_ = tuner.SetChannel(6)
+	suspended += time.Hour
+	_ = tuner.SetChannel(11)
+	_ = tuner.SetChannel(1)
+	if !reflect.DeepEqual(tuned, []int{1, 6, 11, 1}) || !reflect.DeepEqual(slept, []time.Duration{time.Hour}) {
+		t.Errorf("tuned %v, recovered after %v", tuned, slept)
+	}
+
+	// A failed recovery fails the hop
+	fail = true
+	suspended += time.Minute
+	if err := tuner.SetChannel(6); err == nil || len(tuned) != 4 {
+		t.Errorf("SetChannel after a failed recovery: %v, tuned %v", err, tuned)
+	}
+}
+
+type fakeAsyncTuner struct {
+	hopper.TunerFunc
+}
+
+func (fakeAsyncTuner) SetChannelAsync(channel int) (<-chan error, error) {
+	reply := make(chan error, 1)
+	reply <- nil
+	return reply, nil
+}
+
+func TestSuspendTunerAsync(t *testing.T) {
+	suspended := time.Duration(0)
+	detector := newSuspendDetector(func() (time.Duration, error) { return suspended, nil })
+	recovered := 0
+	tuner := newSuspendTuner(fakeAsyncTuner{}, detector, func(time.Duration) error {
+		recovered++
+		return nil
+	})
+
+	async, ok := tuner.(hopper.AsyncTuner)
+	if !ok {
+		t.Fatal("asynchronous tuner became synchronous")
+	}
+	suspended = time.Minute
+	if _, err := async.SetChannelAsync(6); err != nil || recovered != 1 {
+		t.Errorf("SetChannelAsync after a suspend: %v, recovered %v times", err, recovered)
+	}
+}
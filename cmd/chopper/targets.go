@@ -0,0 +1,325 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// targetBias is how many times per cycle target channels are visited with
+// --targets-mode bias.
+const targetBias = 3
+
+// targetPollInterval is how often the targets file is checked for changes.
+// Polling also catches editors that replace the file instead of writing it.
+const targetPollInterval = time.Second
+
+// targetList is the content of a targets file.
+type targetList struct {
+	bssids map[string]bool
+	ssids  map[string]bool
+}
+
+// parseTargets parses a targets file: one BSSID or SSID per line, with
+// empty lines and lines starting with # ignored.
+func parseTargets(r io.Reader) (targetList, error) {
+	t := targetList{bssids: make(map[string]bool), ssids: make(map[string]bool)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if addr, err := net.ParseMAC(line); err == nil && len(addr) == 6 {
+			t.bssids[addr.String()] = true
+		} else {
+			t.ssids[line] = true
+		}
+	}
+
+	return t, scanner.Err()
+}
+
+func (t targetList) len() int {
+	return len(t.bssids) + len(t.ssids)
+}
+
+// targetPlan returns the plan to hop on given the base plan and the
+// channels on which targets were seen.
+func targetPlan(base []int, seen []int, mode string) []int {
+	if len(seen) == 0 {
+		return base
+	}
+	if mode == "lock" {
+		return seen
+	}
+
+	terms := make([]string, 0, len(seen)+1)
+	for _, channel := range seen {
+		terms = append(terms, fmt.Sprintf("%sx%d", plan.FormatChannel(channel), targetBias))
+	}
+	terms = append(terms, plan.Rest)
+
+	channels, err := plan.ParseMultipliers(strings.Join(terms, ","), base)
+	if err != nil {
+		return base
+	}
+	return channels
+}
+
+// targetWatcher captures frames in the background and steers the hopper
+// to the channels on which targets are seen. The targets file is reloaded
+// when it changes.
+type targetWatcher struct {
+	path    string
+	mode    string
+	capture *captureSocket
+	done    chan struct{}
+	wg      sync.WaitGroup
+	// minSignal is set before start
+	minSignal minSignal
+
+	mu       sync.Mutex
+	tuned    int
+	targets  targetList
+	modTime  time.Time
+	size     int64
+	channels map[string]int
+}
+
+func newTargetWatcher(path string, mode string, ifindex int) (*targetWatcher, error) {
+	w := &targetWatcher{
+		path:     path,
+		mode:     mode,
+		done:     make(chan struct{}),
+		channels: make(map[string]int),
+	}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	capture, err := openCapture(ifindex, 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	w.capture = capture
+
+	return w, nil
+}
+
+// reload reads the targets file if it changed since the last call and
+// reports whether it did.
+func (w *targetWatcher) reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+
+	w.mu.Lock()
+	unchanged := info.ModTime().Equal(w.modTime) && info.Size() == w.size
+	w.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	targets, err := parseTargets(f)
+	if err != nil {
+		return false, err
+	}
+
+	w.mu.Lock()
+	w.targets = targets
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	// Forget targets that were removed from the file
+	for key := range w.channels {
+		if !targets.bssids[key] && !targets.ssids[key] {
+			delete(w.channels, key)
+		}
+	}
+	w.mu.Unlock()
+
+	return true, nil
+}
+
+// isTarget reports whether a BSS is listed in the targets file.
+func (w *targetWatcher) isTarget(bssid string, ssid string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.targets.bssids[bssid] || w.targets.ssids[ssid]
+}
+
+func (w *targetWatcher) setChannel(channel int) {
+	w.mu.Lock()
+	w.tuned = channel
+	w.mu.Unlock()
+}
+
+// observe accounts a beacon received while tuned to a channel. It reports
+// whether a target was seen on a new channel.
+func (w *targetWatcher) observe(tuned int, packet dot11.Packet) bool {
+	frame := packet.Frame
+	if !w.minSignal.accepts(packet) {
+		return false
+	}
+	if frame.Type != dot11.TypeManagement || frame.Subtype != dot11.SubtypeBeacon {
+		return false
+	}
+	beacon, err := dot11.ParseBeacon(frame.Body)
+	if err != nil {
+		return false
+	}
+
+	// 2.4 GHz beacons leak into adjacent channels, trust the AP
+	channel := tuned
+	if plan.BandOf(tuned) == plan.Band2GHz && beacon.Channel() != 0 {
+		channel = beacon.Channel()
+	}
+
+	changed := false
+	for _, key := range []string{frame.BSSID().String(), beacon.SSID()} {
+		if !w.targets.bssids[key] && !w.targets.ssids[key] {
+			continue
+		}
+		if w.channels[key] != channel {
+			w.channels[key] = channel
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// seen returns the channels on which targets were seen.
+func (w *targetWatcher) seen() []int {
+	unique := make(map[int]bool)
+	for _, channel := range w.channels {
+		unique[channel] = true
+	}
+
+	channels := make([]int, 0, len(unique))
+	for channel := range unique {
+		channels = append(channels, channel)
+	}
+	sort.Ints(channels)
+	return channels
+}
+
+// start captures frames in the background and steers the shared plan
+// until the watcher is closed.
+func (w *targetWatcher) start(shared *sharedPlan) {
+	w.wg.Add(1)
+	go w.loop(shared)
+}
+
+func (w *targetWatcher) loop(shared *sharedPlan) {
+	defer w.wg.Done()
+
+	current := []int{}
+	update := func() {
+		w.mu.Lock()
+		seen := w.seen()
+		w.mu.Unlock()
+
+		if reflect.DeepEqual(seen, current) {
+			return
+		}
+		current = seen
+		// Steering the base plan keeps the edits made through the API
+		channels, err := shared.steerTo(func(base []int) []int {
+			return targetPlan(base, seen, w.mode)
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: targets: %v\n", err)
+			return
+		}
+		_, _ = fmt.Fprintf(stderr, "Targets: hopping on %v\n", plan.Format(channels))
+	}
+
+	buf := make([]byte, 65536)
+	lastPoll := time.Now()
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		if time.Since(lastPoll) >= targetPollInterval {
+			lastPoll = time.Now()
+			changed, err := w.reload()
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "WARNING: targets: %v\n", err)
+			} else if changed {
+				w.mu.Lock()
+				n := w.targets.len()
+				w.mu.Unlock()
+				_, _ = fmt.Fprintf(stderr, "Targets: loaded %v targets from %v\n", n, w.path)
+				update()
+			}
+		}
+
+		n, err := w.capture.Read(buf)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: targets: %v\n", err)
+			return
+		}
+		if n == 0 {
+			continue
+		}
+
+		packet, err := dot11.Decode(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		w.mu.Lock()
+		changed := w.tuned != 0 && w.observe(w.tuned, packet)
+		w.mu.Unlock()
+		if changed {
+			update()
+		}
+	}
+}
+
+// Close stops the capture.
+func (w *targetWatcher) Close() error {
+	close(w.done)
+	w.wg.Wait()
+	return w.capture.Close()
+}
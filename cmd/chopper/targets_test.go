@@ -0,0 +1,179 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+)
+
+func TestParseTargets(t *testing.T) {
+	input := `
+# recon results
+00:11:22:33:44:01
+00-11-22-33-44-AA
+  Corp WiFi
+`
+	targets, err := parseTargets(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBSSIDs := map[string]bool{"00:11:22:33:44:01": true, "00:11:22:33:44:aa": true}
+	if !reflect.DeepEqual(wantBSSIDs, targets.bssids) {
+		t.Fatalf("parseTargets bssids:\n- want: %v\n-  got: %v", wantBSSIDs, targets.bssids)
+	}
+	wantSSIDs := map[string]bool{"Corp WiFi": true}
+	if !reflect.DeepEqual(wantSSIDs, targets.ssids) {
+		t.Fatalf("parseTargets ssids:\n- want: %v\n-  got: %v", wantSSIDs, targets.ssids)
+	}
+}
+
+func TestTargetPlan(t *testing.T) {
+	base := []int{1, 6, 11}
+
+	tests := []struct {
+		name   string
+		seen   []int
+		mode   string
+		output []int
+	}{
+		{"none", nil, "bias", []int{1, 6, 11}},
+		{"lock", []int{6, 36}, "lock", []int{6, 36}},
+		{"bias", []int{6}, "bias", []int{6, 1, 6, 6, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := targetPlan(base, tt.seen, tt.mode)
+			if !reflect.DeepEqual(tt.output, got) {
+				t.Fatalf("targetPlan(%v, %v, %v):\n- want: %v\n-  got: %v", base, tt.seen, tt.mode, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestTargetSteering(t *testing.T) {
+	p := &fakePlanner{channels: []int{1, 6, 11}}
+	shared := newSharedPlan(p)
+	steer := func(base []int) []int { return targetPlan(base, []int{6}, "lock") }
+	l := &channelLock{plan: shared, reason: "test", timeout: time.Minute}
+
+	steps := []struct {
+		name   string
+		action func() error
+		output []int
+	}{
+		{"target seen", func() error { _, err := shared.steerTo(steer); return err }, []int{6}},
+		{"locked", func() error { return l.lock(11, time.Now()) }, []int{11}},
+		{"target lost while locked", func() error {
+			_, err := shared.steerTo(func(base []int) []int { return targetPlan(base, nil, "lock") })
+			return err
+		}, []int{11}},
+		{"edited while locked", func() error { return shared.AddChannel(36) }, []int{11}},
+		{"released", l.release, []int{1, 6, 11, 36}},
+	}
+
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(step.output, p.channels) {
+			t.Fatalf("%v:\n- want: %v\n-  got: %v", step.name, step.output, p.channels)
+		}
+	}
+}
+
+func TestTargetWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chopper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "targets.txt")
+	if err := ioutil.WriteFile(path, []byte("00:11:22:33:44:01\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &targetWatcher{path: path, channels: make(map[string]int)}
+	if changed, err := w.reload(); err != nil || !changed {
+		t.Fatalf("first reload: changed %v, error %v", changed, err)
+	}
+	if changed, err := w.reload(); err != nil || changed {
+		t.Fatalf("reload without changes: changed %v, error %v", changed, err)
+	}
+
+	observe := func(tuned int, frame []byte) bool {
+		packet, err := dot11.Decode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.observe(tuned, packet)
+	}
+
+	target := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x01}
+	other := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x02}
+	if observe(6, testBeacon(other, 6)) {
+		t.Fatalf("observe reported a non-target")
+	}
+	if !observe(2, testBeacon(target, 1)) {
+		t.Fatalf("observe did not report a target")
+	}
+	if observe(1, testBeacon(target, 1)) {
+		t.Fatalf("observe reported a target on a known channel")
+	}
+	if want, got := []int{1}, w.seen(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("seen:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// Appending an SSID adds it, removing the BSSID forgets its channel
+	if err := ioutil.WriteFile(path, []byte("test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := w.reload(); err != nil || !changed {
+		t.Fatalf("reload after change: changed %v, error %v", changed, err)
+	}
+	if got := w.seen(); len(got) != 0 {
+		t.Fatalf("seen after removing the target:\n- want: []\n-  got: %v", got)
+	}
+	if !observe(6, testBeacon(other, 6)) {
+		t.Fatalf("observe did not report a target SSID")
+	}
+	if want, got := []int{6}, w.seen(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("seen:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// Outside 2.4 GHz the channel of the beacon is not a plan channel
+	if !observe(1037, testBeacon(other, 37)) {
+		t.Fatalf("observe did not report a target on 6 GHz")
+	}
+	if want, got := []int{1037}, w.seen(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("seen on 6 GHz:\n- want: %v\n-  got: %v", want, got)
+	}
+}
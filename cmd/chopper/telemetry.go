@@ -0,0 +1,128 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	"github.com/giacomoferretti/chopper-go/pkg/telemetry"
+)
+
+// exporter is set by --otlp-endpoint.
+var exporter *telemetry.Exporter
+
+// startTelemetry starts exporting to the collector in the background. The
+// returned function stops the exporter after a final flush.
+func startTelemetry(ctx context.Context, endpoint string, interval time.Duration) func() {
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = ProgramName
+	}
+	exporter = telemetry.NewExporter(endpoint, service)
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		exporter.Run(ctx, interval, func(err error) {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot export telemetry: %v\n", err)
+		})
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// countAlert counts the alerts emitted when telemetry is enabled.
+func countAlert(event events.Event) {
+	if exporter != nil {
+		exporter.Add("chopper.alerts", 1, telemetry.String("alert", event.Alert), telemetry.Int("wifi.channel", event.Channel))
+	}
+}
+
+// countStarved counts the channels unvisited for more than --max-gap.
+func countStarved(channel int) {
+	if exporter != nil {
+		exporter.Add("chopper.coverage.starved", 1, telemetry.Int("wifi.channel", channel))
+	}
+}
+
+// tracedTuner records a trace for every hop, with a child span for the
+// netlink call, and the hop latency and error metrics. setFrequency takes
+// the frequency in kHz.
+func tracedTuner(setFrequency func(ifindex int, frequency int) error, ifindex int) hopper.Tuner {
+	return hopper.TunerFunc(func(channel int) error {
+		freq := plan.Frequency(channel)
+		attrs := []telemetry.Attribute{telemetry.Int("wifi.channel", channel)}
+
+		hop := exporter.StartSpan("hop", nil, append(attrs, telemetry.Int("wifi.frequency", freq))...)
+		call := exporter.StartClientSpan("nl80211.set_frequency", hop, telemetry.Int("ifindex", ifindex))
+		err := setFrequency(ifindex, plan.FrequencyKHz(channel))
+		call.End(err)
+		latency := hop.End(err)
+
+		exporter.Add("chopper.hops", 1, attrs...)
+		exporter.Record("chopper.hop.latency", float64(latency)/float64(time.Millisecond), attrs...)
+		if err != nil {
+			exporter.Add("chopper.hop.errors", 1, attrs...)
+		}
+
+		return err
+	})
+}
+
+// tracedVerify traces the query verifying every retune, see verifyTuning,
+// and records its latency.
+func tracedVerify(verify func(channel int) error, ifindex int) func(channel int) error {
+	return func(channel int) error {
+		span := exporter.StartClientSpan("nl80211.get_interface", nil, telemetry.Int("ifindex", ifindex))
+		err := verify(channel)
+		latency := span.End(err)
+
+		attrs := []telemetry.Attribute{telemetry.Int("wifi.channel", channel)}
+		exporter.Record("chopper.hop.verify.latency", float64(latency)/float64(time.Millisecond), attrs...)
+		if err != nil {
+			exporter.Add("chopper.hop.verify.errors", 1, attrs...)
+		}
+		return err
+	}
+}
+
+// tracedSurvey traces the channel survey used by the ranked strategy.
+func tracedSurvey(client *nl80211util.Client, ifindex int) func() ([]nl80211util.SurveyInfo, error) {
+	return func() ([]nl80211util.SurveyInfo, error) {
+		span := exporter.StartClientSpan("nl80211.survey", nil, telemetry.Int("ifindex", ifindex))
+		info, err := client.Survey(ifindex)
+		span.End(err)
+
+		return info, err
+	}
+}
@@ -0,0 +1,124 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader loads a certificate and key pair again when either file
+// changes, so that renewed certificates are used without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.certificate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// certificate returns the current certificate, reloading it if the files
+// changed. The previous certificate is kept if reloading fails.
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var modTimes [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			if r.cert != nil {
+				return r.cert, nil
+			}
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.cert != nil && modTimes == r.modTimes {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot reload TLS certificate, keeping the previous one: %v\n", err)
+			r.modTimes = modTimes
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+	return r.cert, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// serverTLSConfig returns the TLS configuration of the API, or nil if
+// neither certFile nor keyFile is set. If clientCAFile is set, clients must
+// present a certificate signed by one of its CAs.
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, fmt.Errorf("--tls-client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+	}
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load TLS certificate: %v", err)
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	if clientCAFile != "" {
+		data, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %v", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
@@ -0,0 +1,203 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir.
+func writeTestCert(t *testing.T, dir string, serial int64) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "chopper test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	if config, err := serverTLSConfig("", "", ""); config != nil || err != nil {
+		t.Fatalf("serverTLSConfig without files: %v, %v", config, err)
+	}
+	if _, err := serverTLSConfig("cert.pem", "", ""); err == nil {
+		t.Fatal("no error without --tls-key")
+	}
+	if _, err := serverTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), "missing.key", ""); err == nil {
+		t.Fatal("no error with missing files")
+	}
+}
+
+func TestServeAPITLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeTestCert(t, dir, 1)
+	config, err := serverTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	api := newControlAPI(10, 100*time.Millisecond, 0)
+	shutdown, err := serveAPI(addr, api.handler(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	get := func(trusted *x509.Certificate) (*http.Response, error) {
+		pool := x509.NewCertPool()
+		pool.AddCert(trusted)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		return client.Get("https://" + addr + "/version")
+	}
+
+	resp, err := get(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info buildInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if err != nil || info.Program != ProgramName {
+		t.Fatalf("GET /version: %+v, %v", info, err)
+	}
+
+	// A renewed certificate is used for new connections
+	time.Sleep(10 * time.Millisecond)
+	_, _, renewed := writeTestCert(t, dir, 2)
+	if resp, err := get(renewed); err != nil {
+		t.Fatalf("GET /version with the renewed certificate: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if _, err := get(cert); err == nil {
+		t.Fatal("the old certificate is still served")
+	}
+}
+
+func TestServeAPIClientCertificates(t *testing.T) {
+	serverCert, serverKey, cert := writeTestCert(t, t.TempDir(), 1)
+	clientCert, clientKey, client := writeTestCert(t, t.TempDir(), 2)
+	otherCert, otherKey, _ := writeTestCert(t, t.TempDir(), 3)
+
+	config, err := serverTLSConfig(serverCert, serverKey, clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := serverTLSConfig("", "", clientCert); err == nil {
+		t.Fatal("no error with --tls-client-ca alone")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	api := newControlAPI(10, 100*time.Millisecond, 0)
+	shutdown, err := serveAPI(addr, api.handler(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	get := func(certificates []tls.Certificate) error {
+		pool := x509.NewCertPool()
+		pool.AddCert(cert)
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certificates}}
+		resp, err := (&http.Client{Transport: transport}).Get("https://" + addr + "/version")
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get(nil); err == nil {
+		t.Fatal("request without a client certificate succeeded")
+	}
+	pair, err := tls.LoadX509KeyPair(otherCert, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get([]tls.Certificate{pair}); err == nil {
+		t.Fatal("request with an untrusted client certificate succeeded")
+	}
+	pair, err = tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get([]tls.Certificate{pair}); err != nil {
+		t.Fatalf("request with the client certificate %v: %v", client.Subject, err)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rxCounters are the receive counters of a network interface.
+type rxCounters struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// readRxCounters reads the receive counters of an interface from sysfs.
+func readRxCounters(name string) (rxCounters, error) {
+	var c rxCounters
+	for _, counter := range []struct {
+		file  string
+		value *uint64
+	}{{"rx_packets", &c.Packets}, {"rx_bytes", &c.Bytes}} {
+		data, err := ioutil.ReadFile(filepath.Join(sysDir, "class", "net", name, "statistics", counter.file))
+		if err != nil {
+			return rxCounters{}, err
+		}
+		if *counter.value, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return rxCounters{}, fmt.Errorf("invalid %v: %v", counter.file, err)
+		}
+	}
+	return c, nil
+}
+
+// channelTraffic is what the interface received on a channel.
+type channelTraffic struct {
+	Packets uint64        `json:"packets"`
+	Bytes   uint64        `json:"bytes"`
+	Dwell   time.Duration `json:"dwell"`
+}
+
+// rate returns the packets received per second of dwell.
+func (t channelTraffic) rate() float64 {
+	if t.Dwell <= 0 {
+		return 0
+	}
+	return float64(t.Packets) / t.Dwell.Seconds()
+}
+
+// trafficMonitor attributes the receive counters of the interface to the
+// channels, sampling them when the radio arrives on a channel and when it
+// leaves it. No capture socket is needed.
+type trafficMonitor struct {
+	read func() (rxCounters, error)
+
+	mu       sync.Mutex
+	channel  int
+	since    time.Time
+	start    rxCounters
+	channels map[int]*channelTraffic
+	// last is the traffic of the last visit to each channel
+	last   map[int]channelTraffic
+	warned bool
+}
+
+func newTrafficMonitor(read func() (rxCounters, error)) *trafficMonitor {
+	return &trafficMonitor{
+		read:     read,
+		channels: make(map[int]*channelTraffic),
+		last:     make(map[int]channelTraffic),
+	}
+}
+
+// sample reads the counters, warning once if they cannot be read.
+func (m *trafficMonitor) sample() (rxCounters, bool) {
+	c, err := m.read()
+	if err != nil {
+		if !m.warned {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot read interface statistics: %v\n", err)
+			m.warned = true
+		}
+		return rxCounters{}, false
+	}
+	return c, true
+}
+
+// arrive records that the radio is on channel since now.
+func (m *trafficMonitor) arrive(channel int, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start, ok := m.sample()
+	if !ok {
+		return
+	}
+	m.channel = channel
+	m.since = now
+	m.start = start
+}
+
+// leave attributes the counters since the radio arrived to its channel and
+// returns them.
+func (m *trafficMonitor) leave(now time.Time) (int, channelTraffic, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channel := m.channel
+	if channel == 0 {
+		return 0, channelTraffic{}, false
+	}
+	m.channel = 0
+	end, ok := m.sample()
+	// Counters go backwards when the interface is recreated
+	if !ok || end.Packets < m.start.Packets || end.Bytes < m.start.Bytes {
+		return 0, channelTraffic{}, false
+	}
+
+	dwell := channelTraffic{
+		Packets: end.Packets - m.start.Packets,
+		Bytes:   end.Bytes - m.start.Bytes,
+		Dwell:   now.Sub(m.since),
+	}
+	total, ok := m.channels[channel]
+	if !ok {
+		total = &channelTraffic{}
+		m.channels[channel] = total
+	}
+	total.Packets += dwell.Packets
+	total.Bytes += dwell.Bytes
+	total.Dwell += dwell.Dwell
+	m.last[channel] = dwell
+	return channel, dwell, true
+}
+
+// beforeHop is registered as a BeforeHop callback.
+func (m *trafficMonitor) beforeHop(int) {
+	m.leave(time.Now())
+}
+
+// hop is registered as an OnHop callback.
+func (m *trafficMonitor) hop(channel int) {
+	m.arrive(channel, time.Now())
+}
+
+// cycle is registered as an OnCycle callback, so that the last channel of
+// a cycle is accounted for before the strategy picks the next rotation.
+func (m *trafficMonitor) cycle(int) error {
+	m.leave(time.Now())
+	return nil
+}
+
+// lastRate returns the packets per second received during the last visit
+// to channel, for the focus strategy.
+func (m *trafficMonitor) lastRate(channel int) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.last[channel].rate()
+}
+
+// active reports whether the last visit to channel received at least
+// threshold packets per second, for the escalate strategy.
+func (m *trafficMonitor) active(threshold float64) func(channel int) bool {
+	return func(channel int) bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		last, ok := m.last[channel]
+		return ok && last.Packets > 0 && last.rate() >= threshold
+	}
+}
+
+// snapshot returns the traffic of every visited channel.
+func (m *trafficMonitor) snapshot() map[int]channelTraffic {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ret := make(map[int]channelTraffic, len(m.channels))
+	for channel, t := range m.channels {
+		ret[channel] = *t
+	}
+	return ret
+}
+
+// writeTable writes the traffic of every visited channel, busiest first.
+func (m *trafficMonitor) writeTable(w io.Writer) {
+	traffic := m.snapshot()
+	channels := make([]int, 0, len(traffic))
+	for channel := range traffic {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		if traffic[channels[i]].Packets != traffic[channels[j]].Packets {
+			return traffic[channels[i]].Packets > traffic[channels[j]].Packets
+		}
+		return channels[i] < channels[j]
+	})
+
+	_, _ = fmt.Fprintf(w, "Traffic: %v channels\n", len(channels))
+	if len(channels) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, paint(term != nil && term.color, ansiBold, fmt.Sprintf("%-9s%-10s%-12s%-10s%s", "CHANNEL", "PACKETS", "BYTES", "DWELL", "PACKETS/S")))
+	for _, channel := range channels {
+		t := traffic[channel]
+		_, _ = fmt.Fprintf(w, "%-9s%-10d%-12d%-10v%.1f\n", channelLabel(channel), t.Packets, t.Bytes, t.Dwell.Round(time.Millisecond), t.rate())
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRxCounters(t *testing.T) {
+	defer func(sys string) { sysDir = sys }(sysDir)
+	sysDir = t.TempDir()
+
+	statistics := filepath.Join(sysDir, "class", "net", "wlan0mon", "statistics")
+	if err := os.MkdirAll(statistics, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSysfs(t, filepath.Join(statistics, "rx_packets"), "1200")
+	writeSysfs(t, filepath.Join(statistics, "rx_bytes"), "345678")
+
+	got, err := readRxCounters("wlan0mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (rxCounters{Packets: 1200, Bytes: 345678}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := readRxCounters("wlan1mon"); err == nil {
+		t.Error("read the counters of a missing interface")
+	}
+}
+
+func TestTrafficMonitor(t *testing.T) {
+	counters := []rxCounters{
+		{Packets: 100, Bytes: 10000},
+		{Packets: 150, Bytes: 16000},
+		{Packets: 150, Bytes: 16000},
+		{Packets: 160, Bytes: 17000},
+		{Packets: 160, Bytes: 17000},
+		// The interface was recreated
+		{Packets: 5, Bytes: 500},
+	}
+	m := newTrafficMonitor(func() (rxCounters, error) {
+		if len(counters) == 0 {
+			return rxCounters{}, errors.New("no statistics")
+		}
+		c := counters[0]
+		counters = counters[1:]
+		return c, nil
+	})
+
+	start := time.Unix(1633089600, 0)
+	m.arrive(6, start)
+	channel, dwell, ok := m.leave(start.Add(500 * time.Millisecond))
+	if !ok || channel != 6 || dwell != (channelTraffic{Packets: 50, Bytes: 6000, Dwell: 500 * time.Millisecond}) {
+		t.Errorf("leave = %v, %+v, %v", channel, dwell, ok)
+	}
+	m.arrive(11, start)
+	m.leave(start.Add(time.Second))
+	m.arrive(6, start)
+	if _, _, ok := m.leave(start.Add(time.Second)); ok {
+		t.Error("counters going backwards were attributed")
+	}
+	// Unreadable counters are skipped
+	m.arrive(1, start)
+	if _, _, ok := m.leave(start.Add(time.Second)); ok {
+		t.Error("unreadable counters were attributed")
+	}
+
+	want := map[int]channelTraffic{
+		6:  {Packets: 50, Bytes: 6000, Dwell: 500 * time.Millisecond},
+		11: {Packets: 10, Bytes: 1000, Dwell: time.Second},
+	}
+	got := m.snapshot()
+	if len(got) != len(want) || got[6] != want[6] || got[11] != want[11] {
+		t.Errorf("snapshot %+v, want %+v", got, want)
+	}
+
+	active := m.active(50)
+	if !active(6) || active(11) || active(1) {
+		t.Errorf("active: 6 %v, 11 %v, 1 %v, want only 6", active(6), active(11), active(1))
+	}
+	if m.lastRate(6) != 100 || m.lastRate(1) != 0 {
+		t.Errorf("lastRate: 6 %v, 1 %v, want 100 and 0", m.lastRate(6), m.lastRate(1))
+	}
+
+	var out bytes.Buffer
+	m.writeTable(&out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || strings.Join(strings.Fields(lines[2]), " ") != "6 50 6000 500ms 100.0" {
+		t.Errorf("table:\n%v", out.String())
+	}
+
+}
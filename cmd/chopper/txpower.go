@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// txPowerPlan holds the transmit power to apply after each hop: a default
+// and per-channel overrides.
+type txPowerPlan struct {
+	def      *nl80211util.TxPower
+	channels map[int]nl80211util.TxPower
+}
+
+// forChannel returns the transmit power to use on channel, if any.
+func (p txPowerPlan) forChannel(channel int) (nl80211util.TxPower, bool) {
+	if power, ok := p.channels[channel]; ok {
+		return power, true
+	}
+	if p.def != nil {
+		return *p.def, true
+	}
+	return nl80211util.TxPower{}, false
+}
+
+// parseTxPower parses the --txpower flag: a comma-separated list of
+// settings, each optionally prefixed by "<channel>=" to apply it only on
+// that channel. A setting is "auto", "fixed:<dBm>", "limit:<dBm>" or a bare
+// number of dBm, which is fixed.
+func parseTxPower(input string) (txPowerPlan, error) {
+	p := txPowerPlan{channels: make(map[int]nl80211util.TxPower)}
+
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		channel := 0
+		if i := strings.Index(part, "="); i >= 0 {
+			var err error
+			channel, err = plan.ParseChannel(part[:i])
+			if err != nil || channel == 0 {
+				return txPowerPlan{}, fmt.Errorf("invalid txpower channel %v", part[:i])
+			}
+			part = part[i+1:]
+		}
+
+		power, err := parseTxPowerSetting(part)
+		if err != nil {
+			return txPowerPlan{}, err
+		}
+
+		if channel == 0 {
+			if p.def != nil {
+				return txPowerPlan{}, fmt.Errorf("more than one default txpower")
+			}
+			p.def = &power
+		} else {
+			p.channels[channel] = power
+		}
+	}
+
+	return p, nil
+}
+
+// parseTxPowerSetting parses a single --txpower setting.
+func parseTxPowerSetting(input string) (nl80211util.TxPower, error) {
+	if input == "auto" {
+		return nl80211util.TxPower{Setting: nl80211util.TxPowerAutomatic}, nil
+	}
+
+	setting := nl80211util.TxPowerFixed
+	level := input
+	if i := strings.Index(input, ":"); i >= 0 {
+		switch input[:i] {
+		case "fixed":
+		case "limit":
+			setting = nl80211util.TxPowerLimited
+		default:
+			return nl80211util.TxPower{}, fmt.Errorf("invalid txpower mode %v", input[:i])
+		}
+		level = input[i+1:]
+	}
+
+	dbm, err := strconv.ParseFloat(level, 64)
+	if err != nil || math.IsNaN(dbm) || math.IsInf(dbm, 0) {
+		return nl80211util.TxPower{}, fmt.Errorf("invalid txpower %v", input)
+	}
+
+	return nl80211util.TxPower{Setting: setting, Level: int(math.Round(dbm * 100))}, nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+func TestParseTxPower(t *testing.T) {
+	auto := nl80211util.TxPower{Setting: nl80211util.TxPowerAutomatic}
+	fixed := func(mbm int) nl80211util.TxPower {
+		return nl80211util.TxPower{Setting: nl80211util.TxPowerFixed, Level: mbm}
+	}
+	ptr := func(power nl80211util.TxPower) *nl80211util.TxPower {
+		return &power
+	}
+	limit := func(mbm int) nl80211util.TxPower {
+		return nl80211util.TxPower{Setting: nl80211util.TxPowerLimited, Level: mbm}
+	}
+
+	tests := []struct {
+		input   string
+		channel int
+		want    *nl80211util.TxPower
+		err     bool
+	}{
+		{"auto", 6, ptr(auto), false},
+		{"20", 6, ptr(fixed(2000)), false},
+		{"fixed:5.5", 6, ptr(fixed(550)), false},
+		{"limit:10", 6, ptr(limit(1000)), false},
+		{"1=5,6=limit:3", 6, ptr(limit(300)), false},
+		{"1=5,6=limit:3", 11, nil, false},
+		{"auto,36=3", 36, ptr(fixed(300)), false},
+		{"auto,36=3", 40, ptr(auto), false},
+		{"auto,6g37=1", plan.Channel6GHz(37), ptr(fixed(100)), false},
+		{"max:10", 0, nil, true},
+		{"fixed:loud", 0, nil, true},
+		{"auto,10", 0, nil, true},
+		{"0=10", 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := parseTxPower(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("parseTxPower(%v):\n- want error: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if tt.err {
+				return
+			}
+
+			var got *nl80211util.TxPower
+			if power, ok := p.forChannel(tt.channel); ok {
+				got = &power
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Fatalf("parseTxPower(%v) on %v:\n- want: %v\n-  got: %v", tt.input, tt.channel, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+)
+
+// validationTarget is a channel to validate, with the term of the plan
+// naming it.
+type validationTarget struct {
+	term    string
+	channel int
+	width   nl80211util.ChannelWidth
+}
+
+// validationResult is the outcome of validating a target.
+type validationResult struct {
+	Channel   string `json:"channel"`
+	Frequency int    `json:"frequency,omitempty"`
+	Width     string `json:"width"`
+	OK        bool   `json:"ok"`
+	Reason    string `json:"reason,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// parseWidth parses a channel width in MHz: 20, 40, 80, 80+80 or 160.
+func parseWidth(input string) (nl80211util.ChannelWidth, error) {
+	switch strings.TrimSuffix(strings.TrimSpace(input), "MHz") {
+	case "20":
+		return nl80211util.Width20, nil
+	case "40":
+		return nl80211util.Width40, nil
+	case "80":
+		return nl80211util.Width80, nil
+	case "80+80":
+		return nl80211util.Width80P80, nil
+	case "160":
+		return nl80211util.Width160, nil
+	}
+	return 0, fmt.Errorf("invalid width %v, expected 20, 40, 80, 80+80 or 160", input)
+}
+
+// validationTargets returns the channels of -c, or of a plan file with the
+// width of each hop, once each. Terms that are not channels are kept with
+// channel 0, to be reported.
+func validationTargets(channels string, planData []byte, width nl80211util.ChannelWidth, supported []int) ([]validationTarget, error) {
+	var targets []validationTarget
+	seen := make(map[validationTarget]bool)
+	add := func(t validationTarget) {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	switch {
+	case planData != nil:
+		r, err := plan.Decode(planData)
+		if err != nil {
+			return nil, err
+		}
+		for _, hop := range r.Hops {
+			w, err := parseWidth(fmt.Sprint(hop.Width))
+			if err != nil {
+				return nil, fmt.Errorf("channel %v: %v", plan.FormatChannel(hop.Channel), err)
+			}
+			add(validationTarget{term: plan.FormatChannel(hop.Channel), channel: hop.Channel, width: w})
+		}
+	case plan.HasMultipliers(channels):
+		parsed, err := plan.ParseMultipliers(channels, supported)
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range parsed {
+			add(validationTarget{term: plan.FormatChannel(channel), channel: channel, width: width})
+		}
+	default:
+		for _, part := range strings.Split(channels, ",") {
+			term := strings.TrimSpace(part)
+			if term == "" {
+				continue
+			}
+			channel, err := plan.ParseChannel(term)
+			if err != nil || plan.Frequency(channel) == 0 {
+				channel = 0
+			} else {
+				term = plan.FormatChannel(channel)
+			}
+			add(validationTarget{term: term, channel: channel, width: width})
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no channels to validate")
+	}
+	return targets, nil
+}
+
+// validateTargets checks every target against the radio.
+func validateTargets(phy *nl80211util.Phy, targets []validationTarget, opts nl80211util.ValidateOptions) []validationResult {
+	results := make([]validationResult, 0, len(targets))
+	for _, t := range targets {
+		result := validationResult{Channel: t.term, Width: t.width.String(), OK: true}
+		if t.channel == 0 {
+			result.OK = false
+			result.Reason = nl80211util.ReasonNotChannel.String()
+			result.Detail = "the plan syntax does not name a channel"
+			results = append(results, result)
+			continue
+		}
+
+		result.Frequency = plan.Frequency(t.channel)
+		opts.Width = t.width
+		var e *nl80211util.ChannelError
+		if err := phy.Validate(result.Frequency, opts); errors.As(err, &e) {
+			result.OK = false
+			result.Reason = e.Reason.String()
+			result.Detail = e.Detail
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// printValidation prints the results as a table, or as a JSON array.
+func printValidation(w io.Writer, results []validationResult, asJSON bool) error {
+	if asJSON {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	_, _ = fmt.Fprintln(w, paint(colorStdout, ansiBold, fmt.Sprintf("%-9s%-6s%-10s%s", "CHANNEL", "FREQ", "WIDTH", "RESULT")))
+	for _, r := range results {
+		frequency := "-"
+		if r.Frequency != 0 {
+			frequency = fmt.Sprint(r.Frequency)
+		}
+		result := "ok"
+		if !r.OK {
+			result = paint(colorStdout, ansiRed, fmt.Sprintf("rejected, %v: %v", r.Reason, r.Detail))
+		}
+		if _, err := fmt.Fprintf(w, "%-9s%-6s%-10s%s\n", r.Channel, frequency, r.Width, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runValidate implements chopper validate: it explains why the radio
+// rejects each channel of a plan. It exits with 1 if any is rejected.
+func runValidate(args []string) int {
+	var (
+		ifaceName string
+		phyFlag   string
+		chans     string
+		planFlag  string
+		planPath  string
+		widthFlag string
+		noDFS     bool
+		transmit  bool
+		output    string
+		colorMode string
+	)
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.StringVarP(&ifaceName, "interface", "i", "", "interface of the radio to validate against, in any mode")
+	flags.StringVar(&phyFlag, "phy", "", "radio to validate against, e.g. phy0")
+	flags.StringVarP(&chans, "channels", "c", "", "comma-separated list of channels, in the syntax of chopper -c (default: the default plan of chopper)")
+	flags.StringVar(&planFlag, "plan", "", "validate a bundled channel plan: "+strings.Join(plan.Names(), ", "))
+	flags.StringVar(&planPath, "plan-file", "", "validate the hops of a plan file, each with its width")
+	flags.StringVar(&widthFlag, "width", "20", "channel width to validate: 20, 40, 80, 80+80 or 160")
+	flags.BoolVar(&noDFS, "no-dfs", false, "reject the channels needing radar detection (DFS)")
+	flags.BoolVar(&transmit, "transmit", false, "reject the channels that cannot be transmitted on (NO_IR, or DFS not cleared), not needed to monitor")
+	flags.StringVar(&output, "output", "text", "output format: text or json")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if (ifaceName == "") == (phyFlag == "") {
+		_, _ = fmt.Fprintf(stderr, "ERROR: pass one of --interface and --phy\n")
+		flags.Usage()
+		return 1
+	}
+	if output != "text" && output != "json" {
+		_, _ = fmt.Fprintf(stderr, "ERROR: unknown output format %v\n", output)
+		return 1
+	}
+	sources := 0
+	for _, s := range []string{chans, planFlag, planPath} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		_, _ = fmt.Fprintf(stderr, "ERROR: --channels, --plan and --plan-file cannot be used together\n")
+		return 1
+	}
+	width, err := parseWidth(widthFlag)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	var planData []byte
+	if planPath != "" {
+		if planData, err = os.ReadFile(planPath); err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: cannot read plan file: %v\n", err)
+			return 1
+		}
+	}
+	if planFlag != "" {
+		named, err := plan.Named(planFlag)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		chans = plan.Format(named)
+	}
+	if chans == "" && planData == nil {
+		chans = plan.Format(plan.Default())
+	}
+
+	// Connect to nl80211
+	client, err := nl80211util.Dial()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	var index int
+	if ifaceName != "" {
+		iface, err := client.InterfaceByName(ifaceName)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		index = iface.PHY
+	} else if index, err = parsePhy(phyFlag); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	phy, err := client.Phy(index)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot describe phy%d: %v\n", index, err)
+		return 1
+	}
+
+	var supported []int
+	for _, c := range phy.Channels() {
+		if channel := plan.ChannelOf(c.Frequency); channel != 0 && !c.Disabled {
+			supported = append(supported, channel)
+		}
+	}
+	targets, err := validationTargets(chans, planData, width, supported)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	results := validateTargets(phy, targets, nl80211util.ValidateOptions{NoDFS: noDFS, Transmit: transmit})
+	if err := printValidation(os.Stdout, results, output == "json"); err != nil {
+		return 1
+	}
+	for _, r := range results {
+		if !r.OK {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestParseWidth(t *testing.T) {
+	for input, want := range map[string]nl80211util.ChannelWidth{
+		"20":    nl80211util.Width20,
+		"40MHz": nl80211util.Width40,
+		"80":    nl80211util.Width80,
+		"80+80": nl80211util.Width80P80,
+		" 160 ": nl80211util.Width160,
+	} {
+		if got, err := parseWidth(input); err != nil || got != want {
+			t.Errorf("parseWidth(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseWidth("320"); err == nil {
+		t.Errorf("parseWidth(320) did not fail")
+	}
+}
+
+func TestValidationTargets(t *testing.T) {
+	w20 := nl80211util.Width20
+	got, err := validationTargets("1, 6g37, foo, 6, 1, 200", nil, w20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []validationTarget{
+		{term: "1", channel: 1, width: w20},
+		{term: "6g37", channel: 1037, width: w20},
+		{term: "foo", width: w20},
+		{term: "6", channel: 6, width: w20},
+		{term: "200", width: w20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validationTargets():\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	got, err = validationTargets("1x3,rest", nil, w20, []int{1, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].channel != 1 || got[1].channel != 6 {
+		t.Errorf("validationTargets(1x3,rest) = %+v", got)
+	}
+
+	data := []byte(`{"version": 1, "hops": [{"channel": 36, "width": 80, "dwell_ms": 100}, {"channel": 1, "dwell_ms": 100}]}`)
+	got, err = validationTargets("", data, w20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []validationTarget{
+		{term: "36", channel: 36, width: nl80211util.Width80},
+		{term: "1", channel: 1, width: w20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validationTargets(plan file):\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	if _, err := validationTargets(" , ", nil, w20, nil); err == nil {
+		t.Errorf("validationTargets() without channels did not fail")
+	}
+}
+
+func TestValidateTargets(t *testing.T) {
+	phy := &nl80211util.Phy{Bands: []nl80211util.Band{
+		{ID: nl80211util.Band2GHz, Channels: []nl80211util.Channel{
+			{WiphyFrequency: nl80211util.WiphyFrequency{Frequency: 2412}},
+			{WiphyFrequency: nl80211util.WiphyFrequency{Frequency: 2484, Disabled: true}},
+		}},
+		{ID: nl80211util.Band5GHz, Channels: []nl80211util.Channel{
+			{WiphyFrequency: nl80211util.WiphyFrequency{Frequency: 5260, Radar: true}},
+		}},
+	}}
+	targets, err := validationTargets("1,14,52,36,6g1,bar", nil, nl80211util.Width20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := validateTargets(phy, targets, nl80211util.ValidateOptions{NoDFS: true})
+	var reasons []string
+	for _, r := range results {
+		if r.OK {
+			reasons = append(reasons, "ok")
+		} else {
+			reasons = append(reasons, r.Reason)
+		}
+	}
+	want := []string{"ok", "disabled by the regulatory domain", "DFS", "unsupported by the hardware", "unsupported by the hardware", "not a channel"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("reasons:\n- want: %q\n-  got: %q", want, reasons)
+	}
+	if results[4].Detail != "the radio does not support the 6GHz band" {
+		t.Errorf("6g1: %v", results[4].Detail)
+	}
+
+	var b bytes.Buffer
+	if err := printValidation(&b, results[:2], false); err != nil {
+		t.Fatal(err)
+	}
+	wantTable := "CHANNEL  FREQ  WIDTH     RESULT\n" +
+		"1        2412  20MHz     ok\n" +
+		"14       2484  20MHz     rejected, disabled by the regulatory domain: the regulatory domain disables it\n"
+	if b.String() != wantTable {
+		t.Errorf("table:\n%s\nwant:\n%s", b.String(), wantTable)
+	}
+
+	b.Reset()
+	if err := printValidation(&b, results[5:], true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"reason": "not a channel"`) || strings.Contains(b.String(), "frequency") {
+		t.Errorf("json:\n%s", b.String())
+	}
+}
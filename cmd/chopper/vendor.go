@@ -0,0 +1,59 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net"
+	"os"
+
+	"github.com/giacomoferretti/chopper-go/pkg/oui"
+)
+
+// vendors annotates the addresses in the inventory and the logs.
+var vendors = oui.Bundled()
+
+// loadVendors adds the OUIs of a Wireshark manuf or IEEE oui.txt file to
+// the bundled ones.
+func loadVendors(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return vendors.Read(f)
+}
+
+// vendorOf returns the vendor of addr, "random" for locally administered
+// addresses, or an empty string.
+func vendorOf(addr string) string {
+	mac, err := net.ParseMAC(addr)
+	if err != nil {
+		return ""
+	}
+	if oui.LocallyAdministered(mac) {
+		return "random"
+	}
+	return vendors.Lookup(mac)
+}
+
+// describeMAC returns addr followed by its vendor, if known.
+func describeMAC(addr string) string {
+	if vendor := vendorOf(addr); vendor != "" {
+		return addr + " [" + vendor + "]"
+	}
+	return addr
+}
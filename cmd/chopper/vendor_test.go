@@ -0,0 +1,55 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/oui"
+)
+
+func TestDescribeMAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manuf")
+	if err := os.WriteFile(path, []byte("00:11:22\tCimsys\tCIMSYS Inc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := vendors
+	defer func() { vendors = saved }()
+	vendors = oui.Bundled()
+	if err := loadVendors(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr   string
+		output string
+	}{
+		{"00:11:22:33:44:55", "00:11:22:33:44:55 [CIMSYS Inc]"},
+		{"b8:27:eb:00:00:01", "b8:27:eb:00:00:01 [Raspberry Pi]"},
+		{"02:00:00:00:00:01", "02:00:00:00:00:01 [random]"},
+		{"00:00:5e:00:53:01", "00:00:5e:00:53:01"},
+		{"invalid", "invalid"},
+	}
+
+	for _, tt := range tests {
+		if got := describeMAC(tt.addr); got != tt.output {
+			t.Fatalf("describeMAC(%v):\n- want: %v\n-  got: %v", tt.addr, tt.output, got)
+		}
+	}
+}
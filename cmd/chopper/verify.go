@@ -0,0 +1,59 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// maxLatencyShare is the percentage of the dwell that retunes can take
+// before a warning is printed.
+const maxLatencyShare = 25
+
+// verifyTuning returns a hopper.Config.Verify checking that the radio
+// reports the frequency of the channel after a retune.
+func verifyTuning(frequency func() (int, error)) func(channel int) error {
+	return func(channel int) error {
+		got, err := frequency()
+		if err != nil {
+			return fmt.Errorf("cannot verify retune: %v", err)
+		}
+		if want := plan.Frequency(channel); got != want {
+			return fmt.Errorf("radio is on %v MHz instead of %v MHz after retune", got, want)
+		}
+		return nil
+	}
+}
+
+// checkLatency returns a warning if the 90th percentile of the retunes, to
+// their verification when enabled, takes more than maxLatencyShare of the
+// dwell.
+func checkLatency(stats hopper.Stats, dwell time.Duration) (string, bool) {
+	latency, what := stats.Latency.P90, "acknowledge"
+	if stats.Verified.P90 > 0 {
+		latency, what = stats.Verified.P90, "complete"
+	}
+	if dwell <= 0 || latency*100 <= dwell*maxLatencyShare {
+		return "", false
+	}
+	return fmt.Sprintf("retunes take %v to %v (p90), %v%% of the %v dwell; consider a longer --delay",
+		latency.Round(10*time.Microsecond), what, int64(latency*100/dwell), dwell), true
+}
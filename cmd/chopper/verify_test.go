@@ -0,0 +1,66 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/hopper"
+)
+
+func TestVerifyTuning(t *testing.T) {
+	frequency := 2437
+	var err error
+	verify := verifyTuning(func() (int, error) {
+		return frequency, err
+	})
+
+	if err := verify(6); err != nil {
+		t.Errorf("verify(6): unexpected error %v", err)
+	}
+	if err := verify(1); err == nil || !strings.Contains(err.Error(), "2437 MHz instead of 2412 MHz") {
+		t.Errorf("verify(1): unexpected error %v", err)
+	}
+	err = errors.New("no such device")
+	if err := verify(6); err == nil {
+		t.Error("verify(6): query error was ignored")
+	}
+}
+
+func TestCheckLatency(t *testing.T) {
+	dwell := 100 * time.Millisecond
+	tests := []struct {
+		stats hopper.Stats
+		warn  string
+	}{
+		{hopper.Stats{Latency: hopper.Latency{P90: 10 * time.Millisecond}}, ""},
+		{hopper.Stats{Latency: hopper.Latency{P90: 40 * time.Millisecond}}, "retunes take 40ms to acknowledge (p90), 40% of the 100ms dwell"},
+		{hopper.Stats{
+			Latency:  hopper.Latency{P90: 10 * time.Millisecond},
+			Verified: hopper.Latency{P90: 30 * time.Millisecond},
+		}, "retunes take 30ms to complete (p90), 30% of the 100ms dwell"},
+	}
+	for _, tt := range tests {
+		msg, ok := checkLatency(tt.stats, dwell)
+		if ok != (tt.warn != "") || !strings.HasPrefix(msg, tt.warn) {
+			t.Errorf("checkLatency(%+v): %q, %v", tt.stats.Latency, msg, ok)
+		}
+	}
+}
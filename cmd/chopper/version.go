@@ -0,0 +1,126 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// commit and buildDate can be set when building chopper:
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Otherwise the commit is taken from the version control information
+// recorded by the Go toolchain, if any.
+var (
+	commit    string
+	buildDate string
+)
+
+// buildInfo identifies a chopper build.
+type buildInfo struct {
+	Program    string `json:"program"`
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+	CommitDate string `json:"commit_date,omitempty"`
+	BuildDate  string `json:"build_date,omitempty"`
+	GoVersion  string `json:"go_version"`
+	Platform   string `json:"platform"`
+	// Device is the device of the interface, if one was given.
+	Device *deviceInfo `json:"device,omitempty"`
+}
+
+// currentBuild returns the build information of the running binary.
+func currentBuild() buildInfo {
+	info := buildInfo{
+		Program:   ProgramName,
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.fromSettings(bi.Settings)
+	}
+	if commit != "" {
+		info.Commit = commit
+		info.Modified = false
+		info.CommitDate = ""
+	}
+	if buildDate != "" {
+		info.BuildDate = buildDate
+	}
+	return info
+}
+
+// fromSettings fills the commit from the vcs.* settings.
+func (b *buildInfo) fromSettings(settings []debug.BuildSetting) {
+	for _, s := range settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Commit = s.Value
+		case "vcs.time":
+			b.CommitDate = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+}
+
+// printVersion writes the build information as text or, with asJSON, as a
+// JSON object.
+func printVersion(w io.Writer, info buildInfo, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(info)
+	}
+
+	if _, err := fmt.Fprintf(w, "%s v%s\n", info.Program, info.Version); err != nil {
+		return err
+	}
+	if info.Commit != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		if _, err := fmt.Fprintf(w, "commit: %s%s\n", info.Commit, modified); err != nil {
+			return err
+		}
+	}
+	if info.CommitDate != "" {
+		if _, err := fmt.Fprintf(w, "date:   %s\n", info.CommitDate); err != nil {
+			return err
+		}
+	}
+	if info.BuildDate != "" {
+		if _, err := fmt.Fprintf(w, "built:  %s\n", info.BuildDate); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "go:     %s %s\n", info.GoVersion, info.Platform); err != nil {
+		return err
+	}
+	if info.Device != nil {
+		if _, err := fmt.Fprintf(w, "device: %v\n", info.Device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"runtime/debug"
+	"testing"
+)
+
+func TestBuildInfoFromSettings(t *testing.T) {
+	var info buildInfo
+	info.fromSettings([]debug.BuildSetting{
+		{Key: "GOOS", Value: "linux"},
+		{Key: "vcs.revision", Value: "abc123"},
+		{Key: "vcs.time", Value: "2021-10-01T12:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	})
+
+	want := buildInfo{Commit: "abc123", CommitDate: "2021-10-01T12:00:00Z", Modified: true}
+	if info != want {
+		t.Fatalf("fromSettings:\n- want: %+v\n-  got: %+v", want, info)
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	info := buildInfo{
+		Program:   "chopper",
+		Version:   "1.0.0",
+		Commit:    "abc123",
+		Modified:  true,
+		BuildDate: "2021-10-02T08:00:00Z",
+		GoVersion: "go1.17",
+		Platform:  "linux/arm64",
+	}
+	tests := []struct {
+		name   string
+		info   buildInfo
+		json   bool
+		output string
+	}{
+		{"text", info, false, "chopper v1.0.0\ncommit: abc123 (modified)\nbuilt:  2021-10-02T08:00:00Z\ngo:     go1.17 linux/arm64\n"},
+		{"minimal", buildInfo{Program: "chopper", Version: "1.0.0", GoVersion: "go1.17", Platform: "linux/arm64"}, false, "chopper v1.0.0\ngo:     go1.17 linux/arm64\n"},
+		{"device", buildInfo{Program: "chopper", Version: "1.0.0", GoVersion: "go1.17", Platform: "linux/arm64", Device: &deviceInfo{Interface: "wlan0", Driver: "ath9k"}}, false, "chopper v1.0.0\ngo:     go1.17 linux/arm64\ndevice: wlan0, driver ath9k\n"},
+		{"json", info, true, `{"program":"chopper","version":"1.0.0","commit":"abc123","modified":true,"build_date":"2021-10-02T08:00:00Z","go_version":"go1.17","platform":"linux/arm64"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printVersion(&out, tt.info, tt.json); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.output {
+				t.Fatalf("printVersion:\n- want: %q\n-  got: %q", tt.output, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+// parsePhy parses a radio name such as "phy0", or a bare index.
+func parsePhy(input string) (int, error) {
+	phy, err := strconv.Atoi(strings.TrimPrefix(input, "phy"))
+	if err != nil || phy < 0 {
+		return 0, fmt.Errorf("invalid phy %v", input)
+	}
+	return phy, nil
+}
+
+// phyMonitor returns the first monitor interface on phy, if any.
+func phyMonitor(interfaces []nl80211util.Interface, phy int) *nl80211util.Interface {
+	for i := range interfaces {
+		iface := &interfaces[i]
+		if iface.PHY == phy && iface.Type == nl80211util.InterfaceTypeMonitor {
+			return iface
+		}
+	}
+
+	return nil
+}
+
+// openInterface returns the interface to hop on. With --phy a monitor
+// interface of that radio is used, or created if there is none. With
+// --create-monitor a new monitor interface is always added, and cleanup
+// removes it.
+func openInterface(client *nl80211util.Client) (*nl80211util.Interface, func(), error) {
+	if createMonitor == "" && isFlagPassed("monitor-flags") {
+		_, _ = fmt.Fprintf(stderr, "WARNING: --monitor-flags only applies to created interfaces.\n")
+	}
+
+	if phyName != "" {
+		return openPhy(client)
+	}
+	if createMonitor == "" {
+		iface, err := client.MonitorInterface(interfaceName)
+		return iface, func() {}, err
+	}
+
+	parent, err := client.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return createMonitorInterface(client, parent.PHY, createMonitor)
+}
+
+// openPhy implements openInterface for --phy.
+func openPhy(client *nl80211util.Client) (*nl80211util.Interface, func(), error) {
+	phy, err := parsePhy(phyName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	interfaces, err := client.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	monitor := phyMonitor(interfaces, phy)
+	if monitor != nil && createMonitor == "" {
+		_, _ = fmt.Fprintf(stderr, "Using monitor interface %v on phy%v\n", monitor.Name, phy)
+		return monitor, func() {}, nil
+	}
+
+	name := createMonitor
+	if name == "" {
+		name = fmt.Sprintf("phy%vmon", phy)
+	}
+	return createMonitorInterface(client, phy, name)
+}
+
+// createMonitorInterface adds a monitor interface with --monitor-flags to
+// phy. cleanup removes it.
+func createMonitorInterface(client *nl80211util.Client, phy int, name string) (*nl80211util.Interface, func(), error) {
+	flags, err := nl80211util.ParseMonitorFlags(monitorFlags)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The kernel only answers EINVAL or EOPNOTSUPP
+	capabilities, err := client.WiphyCapabilities(phy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := capabilities.CheckMonitor(flags); err != nil {
+		return nil, nil, fmt.Errorf("cannot create %v on phy%v: %v", name, phy, err)
+	}
+
+	iface, err := client.CreateMonitorInterface(phy, name, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, _ = fmt.Fprintf(stderr, "Created monitor interface %v on phy%v (flags: %v)\n", iface.Name, iface.PHY, flags)
+
+	cleanup := func() {
+		if err := client.DeleteInterface(iface.Index); err != nil {
+			_, _ = fmt.Fprintf(stderr, "WARNING: cannot remove %v: %v\n", iface.Name, err)
+		}
+	}
+	return iface, cleanup, nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestParsePhy(t *testing.T) {
+	tests := []struct {
+		input  string
+		output int
+		err    bool
+	}{
+		{"phy0", 0, false},
+		{"phy12", 12, false},
+		{"3", 3, false},
+		{"phy", 0, true},
+		{"wlan0", 0, true},
+		{"phy-1", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parsePhy(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("parsePhy(%v):\n- want error: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if got != tt.output {
+				t.Fatalf("parsePhy(%v):\n- want: %v\n-  got: %v", tt.input, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestPhyMonitor(t *testing.T) {
+	interfaces := []nl80211util.Interface{
+		{Name: "wlan0", PHY: 0, Type: nl80211util.InterfaceTypeStation},
+		{Name: "wlan1", PHY: 1, Type: nl80211util.InterfaceTypeMonitor},
+		{Name: "mon0", PHY: 0, Type: nl80211util.InterfaceTypeMonitor},
+		{Name: "mon1", PHY: 0, Type: nl80211util.InterfaceTypeMonitor},
+	}
+
+	if monitor := phyMonitor(interfaces, 0); monitor == nil || monitor.Name != "mon0" {
+		t.Fatalf("phyMonitor:\n- want: mon0\n-  got: %v", monitor)
+	}
+	if monitor := phyMonitor(interfaces, 2); monitor != nil {
+		t.Fatalf("phyMonitor on missing phy:\n- want: %v\n-  got: %v", nil, monitor)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxWarnings is how many distinct warnings are remembered before the
+// quiet ones are forgotten.
+const maxWarnings = 100
+
+// warnings collapses the repeated warnings printed by warnf, its interval
+// is set by --warn-interval.
+var warnings = newWarnThrottle(30 * time.Second)
+
+// warnThrottle prints a warning the first time it is seen. Identical
+// warnings within interval are only counted, and printed once the interval
+// is over with the count, so that an error repeating every hop prints a
+// line per interval instead of one per hop.
+type warnThrottle struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]*repeatedWarning
+}
+
+type repeatedWarning struct {
+	printed time.Time
+	// count is the number of times the warning was seen since printed.
+	count int
+}
+
+func newWarnThrottle(interval time.Duration) *warnThrottle {
+	return &warnThrottle{
+		interval: interval,
+		now:      time.Now,
+		seen:     make(map[string]*repeatedWarning),
+	}
+}
+
+// warnf prints a warning to stderr, throttled by warnings. Events keep
+// every occurrence, see emitError.
+func warnf(format string, args ...interface{}) {
+	warnings.print(stderr, fmt.Sprintf(format, args...))
+}
+
+func (t *warnThrottle) print(w io.Writer, msg string) {
+	if t.interval <= 0 {
+		_, _ = fmt.Fprintf(w, "WARNING: %v\n", msg)
+		return
+	}
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.seen[msg]
+	if !ok {
+		if len(t.seen) >= maxWarnings {
+			t.forget(now)
+		}
+		t.seen[msg] = &repeatedWarning{printed: now}
+		_, _ = fmt.Fprintf(w, "WARNING: %v\n", msg)
+		return
+	}
+
+	r.count++
+	if elapsed := now.Sub(r.printed); elapsed >= t.interval {
+		_, _ = fmt.Fprintf(w, "WARNING: %v (%v times in the last %v)\n", msg, r.count, elapsed.Round(time.Second))
+		r.printed = now
+		r.count = 0
+	}
+}
+
+// forget drops the warnings that were not repeated for interval, they are
+// printed in full again if they come back.
+func (t *warnThrottle) forget(now time.Time) {
+	for msg, r := range t.seen {
+		if r.count == 0 && now.Sub(r.printed) >= t.interval {
+			delete(t.seen, msg)
+		}
+	}
+}
+
+// flush prints the count of the warnings repeated since they were last
+// printed, at exit.
+func (t *warnThrottle) flush(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var repeated []string
+	for msg, r := range t.seen {
+		if r.count > 0 {
+			repeated = append(repeated, msg)
+		}
+	}
+	sort.Strings(repeated)
+	for _, msg := range repeated {
+		_, _ = fmt.Fprintf(w, "WARNING: %v (%v more times)\n", msg, t.seen[msg].count)
+		t.seen[msg].count = 0
+	}
+}
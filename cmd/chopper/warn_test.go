@@ -0,0 +1,95 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarnThrottle(t *testing.T) {
+	now := time.Unix(1633089600, 0)
+	w := newWarnThrottle(30 * time.Second)
+	w.now = func() time.Time { return now }
+
+	var out bytes.Buffer
+	// A bad channel failing every 100ms hop for a minute
+	for i := 0; i < 600; i++ {
+		w.print(&out, "cannot set TX power on channel 14")
+		if i == 5 {
+			w.print(&out, "cannot write event")
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+	w.flush(&out)
+
+	want := []string{
+		"WARNING: cannot set TX power on channel 14",
+		"WARNING: cannot write event",
+		"WARNING: cannot set TX power on channel 14 (300 times in the last 30s)",
+		"WARNING: cannot set TX power on channel 14 (299 more times)",
+	}
+	if got := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("warnings:\n- want: %q\n-  got: %q", want, got)
+	}
+
+	// Nothing left to summarize
+	out.Reset()
+	w.flush(&out)
+	if out.Len() != 0 {
+		t.Fatalf("second flush: %q", out.String())
+	}
+}
+
+func TestWarnThrottleForget(t *testing.T) {
+	now := time.Unix(1633089600, 0)
+	w := newWarnThrottle(time.Second)
+	w.now = func() time.Time { return now }
+
+	var out bytes.Buffer
+	for i := 0; i < maxWarnings; i++ {
+		w.print(&out, fmt.Sprintf("warning %v", i))
+	}
+	now = now.Add(500 * time.Millisecond)
+	w.print(&out, "warning 0")
+	now = now.Add(500 * time.Millisecond)
+	w.print(&out, "another warning")
+	if len(w.seen) != 2 {
+		t.Fatalf("%v warnings remembered, want the repeated one and the new one", len(w.seen))
+	}
+
+	out.Reset()
+	w.flush(&out)
+	if want, got := "WARNING: warning 0 (1 more times)\n", out.String(); want != got {
+		t.Fatalf("flush:\n- want: %q\n-  got: %q", want, got)
+	}
+}
+
+func TestWarnThrottleDisabled(t *testing.T) {
+	w := newWarnThrottle(0)
+
+	var out bytes.Buffer
+	for i := 0; i < 3; i++ {
+		w.print(&out, "hook failed")
+	}
+	if want, got := strings.Repeat("WARNING: hook failed\n", 3), out.String(); want != got {
+		t.Fatalf("warnings:\n- want: %q\n-  got: %q", want, got)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+	flag "github.com/spf13/pflag"
+)
+
+// channelWatcher reports the channel changes of the interfaces of a radio.
+type channelWatcher struct {
+	phy int
+	out io.Writer
+	now func() time.Time
+	// suspects lists the processes that may have retuned the radio
+	suspects func() []competingManager
+
+	// frequencies are the last known frequencies by interface index
+	frequencies map[int]int
+	names       map[int]string
+}
+
+func newChannelWatcher(phy int, out io.Writer) *channelWatcher {
+	return &channelWatcher{
+		phy: phy,
+		out: out,
+		now: time.Now,
+		suspects: func() []competingManager {
+			return findManagers(phyInterfaces(phy))
+		},
+		frequencies: make(map[int]int),
+		names:       make(map[int]string),
+	}
+}
+
+// describeFrequency formats a frequency with its channel, if any.
+func describeFrequency(frequency int) string {
+	if frequency == 0 {
+		return "no channel"
+	}
+	if channel := plan.ChannelOf(frequency); channel != 0 {
+		return fmt.Sprintf("channel %v (%v MHz)", plan.FormatChannel(channel), frequency)
+	}
+	return fmt.Sprintf("%v MHz", frequency)
+}
+
+// poll records the frequencies of the interfaces of the radio, reporting
+// the ones that changed since the last poll.
+func (w *channelWatcher) poll(interfaces []nl80211util.Interface) {
+	for _, iface := range interfaces {
+		if iface.PHY != w.phy {
+			continue
+		}
+		w.names[iface.Index] = iface.Name
+		w.update(iface.Index, iface.Frequency, "")
+	}
+}
+
+// notify records a change announced by nl80211.
+func (w *channelWatcher) notify(change nl80211util.ChannelChange) {
+	if _, ok := w.names[change.Ifindex]; !ok {
+		// Not one of ours, or created since the last poll
+		if change.PHY != w.phy {
+			return
+		}
+		w.names[change.Ifindex] = fmt.Sprintf("ifindex %v", change.Ifindex)
+	}
+	w.update(change.Ifindex, change.Frequency, change.Reason())
+}
+
+// update records the frequency of an interface. Polled changes, without a
+// reason, are only reported when the frequency differs.
+func (w *channelWatcher) update(ifindex int, frequency int, reason string) {
+	previous, known := w.frequencies[ifindex]
+	w.frequencies[ifindex] = frequency
+
+	stamp := w.now().Format("15:04:05.000")
+	name := w.names[ifindex]
+	if !known {
+		_, _ = fmt.Fprintf(w.out, "%v %v: on %v\n", stamp, name, describeFrequency(frequency))
+		return
+	}
+	if frequency == previous && reason == "" {
+		return
+	}
+	if reason == "" {
+		reason = "polled"
+	}
+	_, _ = fmt.Fprintf(w.out, "%v %v: %v -> %v (%v)\n", stamp, name, describeFrequency(previous), describeFrequency(frequency), reason)
+
+	if suspects := w.suspects(); len(suspects) > 0 {
+		names := make([]string, 0, len(suspects))
+		for _, s := range suspects {
+			names = append(names, s.String())
+		}
+		_, _ = fmt.Fprintf(w.out, "%v %v: may have been moved by %v\n", stamp, name, strings.Join(names, ", "))
+	}
+}
+
+// channelChanges forwards the changes announced on events until it fails or
+// ctx is done, then closes the returned channel.
+func channelChanges(ctx context.Context, events *nl80211util.ChannelEvents) <-chan nl80211util.ChannelChange {
+	changes := make(chan nl80211util.ChannelChange)
+	go func() {
+		defer close(changes)
+		for {
+			change, err := events.Next()
+			if errors.Is(err, nl80211util.ErrEventsLost) {
+				// Polling catches up with the lost changes
+				warnf("%v", err)
+				continue
+			} else if err != nil {
+				if ctx.Err() == nil {
+					_, _ = fmt.Fprintf(stderr, "WARNING: channel notifications stopped: %v\n", err)
+				}
+				return
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes
+}
+
+// runWatch implements "chopper watch": it never sets channels, but reports
+// the changes made to the interfaces of a radio by other processes.
+func runWatch(ctx context.Context, args []string) int {
+	var (
+		ifaceName string
+		phy       int
+		interval  time.Duration
+		colorMode string
+	)
+
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: %v watch (-i <interface> | --phy <n>) [options]\n\n", ProgramName)
+		_, _ = fmt.Fprintf(stderr, "Report the channel changes of the interfaces of a radio without retuning it.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVarP(&ifaceName, "interface", "i", "", "watch the radio of this interface")
+	flags.IntVar(&phy, "phy", -1, "watch this radio")
+	flags.DurationVar(&interval, "interval", 100*time.Millisecond, "how often to poll the channels, which cfg80211 does not announce for monitor interfaces")
+	flags.StringVar(&colorMode, "color", "auto", "color the output: auto (only on terminals), always or never")
+	_ = flags.Parse(args)
+
+	if err := setupColor(colorMode); err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if (ifaceName == "") == (phy < 0) || interval <= 0 {
+		flags.Usage()
+		return 1
+	}
+
+	client, err := nl80211util.Dial()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	if ifaceName != "" {
+		iface, err := client.InterfaceByName(ifaceName)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		phy = iface.PHY
+	}
+
+	events, err := client.ChannelEvents()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "ERROR: cannot subscribe to channel changes: %v\n", err)
+		return 1
+	}
+	changes := channelChanges(ctx, events)
+	defer events.Close()
+
+	_, _ = fmt.Fprintf(stderr, "Watching phy%d, press Ctrl-C to stop\n", phy)
+	w := newChannelWatcher(phy, os.Stdout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		interfaces, err := client.Interfaces()
+		if err != nil {
+			warnf("cannot poll interfaces: %v", err)
+		} else {
+			w.poll(interfaces)
+		}
+
+		select {
+		case <-ctx.Done():
+			warnings.flush(stderr)
+			return 0
+		case change, ok := <-changes:
+			if ok {
+				w.notify(change)
+			} else {
+				changes = nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func TestChannelWatcher(t *testing.T) {
+	var out bytes.Buffer
+	w := newChannelWatcher(0, &out)
+	w.now = func() time.Time { return time.Date(2021, 10, 4, 8, 0, 0, 0, time.UTC) }
+	w.suspects = func() []competingManager { return nil }
+
+	interfaces := []nl80211util.Interface{
+		{Index: 3, Name: "wlan0", PHY: 0, Frequency: 2412},
+		{Index: 4, Name: "wlan1", PHY: 1, Frequency: 5180},
+	}
+	w.poll(interfaces)
+	w.poll(interfaces)
+
+	interfaces[0].Frequency = 2437
+	w.poll(interfaces)
+
+	w.suspects = func() []competingManager {
+		return []competingManager{{Name: "wpa_supplicant", PID: 42, Interface: "wlan0"}}
+	}
+	w.notify(nl80211util.ChannelChange{Command: nl80211.CommandChSwitchNotify, Ifindex: 3, PHY: 0, Frequency: 5180})
+	// Other radios are ignored
+	w.notify(nl80211util.ChannelChange{Command: nl80211.CommandChSwitchNotify, Ifindex: 4, PHY: 1, Frequency: 5200})
+
+	want := "08:00:00.000 wlan0: on channel 1 (2412 MHz)\n" +
+		"08:00:00.000 wlan0: channel 1 (2412 MHz) -> channel 6 (2437 MHz) (polled)\n" +
+		"08:00:00.000 wlan0: channel 6 (2437 MHz) -> channel 36 (5180 MHz) (channel switch)\n" +
+		"08:00:00.000 wlan0: may have been moved by wpa_supplicant (pid 42) on wlan0\n"
+	if got := out.String(); got != want {
+		t.Fatalf("output:\n- want: %q\n-  got: %q", want, got)
+	}
+}
+
+func TestDescribeFrequency(t *testing.T) {
+	tests := []struct {
+		frequency int
+		want      string
+	}{
+		{0, "no channel"},
+		{2484, "channel 14 (2484 MHz)"},
+		{5955, "channel 6g1 (5955 MHz)"},
+		{2300, "2300 MHz"},
+	}
+	for _, tt := range tests {
+		if got := describeFrequency(tt.frequency); got != tt.want {
+			t.Errorf("describeFrequency(%v):\n- want: %v\n-  got: %v", tt.frequency, tt.want, got)
+		}
+	}
+}
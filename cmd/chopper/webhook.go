@@ -0,0 +1,102 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+)
+
+// eventTypes are the event types that can be selected with
+// --webhook-events.
+var eventTypes = []string{
+	events.TypeStart,
+	events.TypeHop,
+	events.TypeCycle,
+	events.TypeBSS,
+	events.TypePMKID,
+	events.TypeAlert,
+	events.TypeSpectral,
+	events.TypeConflict,
+	events.TypePower,
+	events.TypeError,
+	events.TypeStop,
+}
+
+// parseEventTypes parses a comma-separated list of event types.
+func parseEventTypes(input string) (map[string]bool, error) {
+	valid := make(map[string]bool, len(eventTypes))
+	for _, typ := range eventTypes {
+		valid[typ] = true
+	}
+
+	types := make(map[string]bool)
+	for _, typ := range strings.Split(input, ",") {
+		typ = strings.TrimSpace(typ)
+		if typ == "" {
+			continue
+		}
+		if !valid[typ] {
+			return nil, fmt.Errorf("unknown event type %v, want one of %v", typ, strings.Join(eventTypes, ", "))
+		}
+		types[typ] = true
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no event types")
+	}
+
+	return types, nil
+}
+
+// startWebhook sends the events of the comma-separated types to url in the
+// background, in batches of up to batch events sent at least every
+// interval. The returned function stops the webhook after sending the
+// remaining events.
+func startWebhook(url, types string, batch int, interval time.Duration) (func(), error) {
+	selected, err := parseEventTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	webhook := events.NewWebhook(url, events.WebhookOptions{
+		BatchSize: batch,
+		Interval:  interval,
+	})
+	eventSinks = append(eventSinks, filteredSink{sink: webhook, types: selected})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		webhook.Run(ctx, func(err error) {
+			_, _ = fmt.Fprintf(stderr, "WARNING: %v\n", err)
+		})
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}, nil
+}
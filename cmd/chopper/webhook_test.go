@@ -0,0 +1,71 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+)
+
+func TestParseEventTypes(t *testing.T) {
+	tests := []struct {
+		input  string
+		output map[string]bool
+		err    bool
+	}{
+		{"hop,error", map[string]bool{"hop": true, "error": true}, false},
+		{" start , stop,", map[string]bool{"start": true, "stop": true}, false},
+		{"hop,hops", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseEventTypes(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("parseEventTypes(%v):\n- want error: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if !reflect.DeepEqual(tt.output, got) {
+				t.Fatalf("parseEventTypes(%v):\n- want: %v\n-  got: %v", tt.input, tt.output, got)
+			}
+		})
+	}
+}
+
+type sliceSink []events.Event
+
+func (s *sliceSink) Encode(event events.Event) error {
+	*s = append(*s, event)
+	return nil
+}
+
+func TestFilteredSink(t *testing.T) {
+	var got sliceSink
+	f := filteredSink{sink: &got, types: map[string]bool{events.TypeHop: true}}
+	for _, typ := range []string{events.TypeStart, events.TypeHop, events.TypeCycle, events.TypeHop} {
+		_ = f.Encode(events.New(typ))
+	}
+
+	if len(got) != 2 || got[0].Type != events.TypeHop || got[1].Type != events.TypeHop {
+		t.Fatalf("filteredSink:\n- want: 2 hop events\n-  got: %v", got)
+	}
+}
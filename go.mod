@@ -1,14 +1,12 @@
-module chopper
+module github.com/giacomoferretti/chopper-go
 
 go 1.16
 
 require (
+	github.com/mattn/go-sqlite3 v1.14.9
 	github.com/mdlayher/genetlink v1.0.0
 	github.com/mdlayher/netlink v1.4.1
-	github.com/mdlayher/wifi v0.0.0-20200527114002-84f0b9457fdd
-	github.com/mitchellh/go-homedir v1.1.0
-	github.com/spf13/cobra v1.2.1
 	github.com/spf13/pflag v1.0.5
-	github.com/spf13/viper v1.8.1
-	github.com/xlab/nl80211 v0.0.0-20161228032351-a871c772539d
+	golang.org/x/net v0.0.0-20210525063256-abc453219eb5
+	golang.org/x/sys v0.0.0-20210525143221-35b2ab0089ea
 )
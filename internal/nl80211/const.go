@@ -0,0 +1,2002 @@
+// Code generated by gen from linux/nl80211.h; DO NOT EDIT.
+
+package nl80211
+
+// Macros.
+const (
+	GenlName               = "nl80211"
+	MulticastGroupConfig   = "config"
+	MulticastGroupScan     = "scan"
+	MulticastGroupReg      = "regulatory"
+	MulticastGroupMlme     = "mlme"
+	MulticastGroupVendor   = "vendor"
+	MulticastGroupNan      = "nan"
+	MulticastGroupTestmode = "testmode"
+	EdmgBwConfigMin        = 4
+	EdmgBwConfigMax        = 15
+	EdmgChannelsMin        = 1
+	EdmgChannelsMax        = 60
+)
+
+// enum nl80211_commands
+const (
+	CommandUnspec                   = 0
+	CommandGetWiphy                 = 1
+	CommandSetWiphy                 = 2
+	CommandNewWiphy                 = 3
+	CommandDelWiphy                 = 4
+	CommandGetInterface             = 5
+	CommandSetInterface             = 6
+	CommandNewInterface             = 7
+	CommandDelInterface             = 8
+	CommandGetKey                   = 9
+	CommandSetKey                   = 10
+	CommandNewKey                   = 11
+	CommandDelKey                   = 12
+	CommandGetBeacon                = 13
+	CommandSetBeacon                = 14
+	CommandStartAp                  = 15
+	CommandNewBeacon                = 15
+	CommandStopAp                   = 16
+	CommandDelBeacon                = 16
+	CommandGetStation               = 17
+	CommandSetStation               = 18
+	CommandNewStation               = 19
+	CommandDelStation               = 20
+	CommandGetMpath                 = 21
+	CommandSetMpath                 = 22
+	CommandNewMpath                 = 23
+	CommandDelMpath                 = 24
+	CommandSetBss                   = 25
+	CommandSetReg                   = 26
+	CommandReqSetReg                = 27
+	CommandGetMeshConfig            = 28
+	CommandSetMeshConfig            = 29
+	CommandSetMgmtExtraIe           = 30
+	CommandGetReg                   = 31
+	CommandGetScan                  = 32
+	CommandTriggerScan              = 33
+	CommandNewScanResults           = 34
+	CommandScanAborted              = 35
+	CommandRegChange                = 36
+	CommandAuthenticate             = 37
+	CommandAssociate                = 38
+	CommandDeauthenticate           = 39
+	CommandDisassociate             = 40
+	CommandMichaelMicFailure        = 41
+	CommandRegBeaconHint            = 42
+	CommandJoinIbss                 = 43
+	CommandLeaveIbss                = 44
+	CommandTestmode                 = 45
+	CommandConnect                  = 46
+	CommandRoam                     = 47
+	CommandDisconnect               = 48
+	CommandSetWiphyNetns            = 49
+	CommandGetSurvey                = 50
+	CommandNewSurveyResults         = 51
+	CommandSetPmksa                 = 52
+	CommandDelPmksa                 = 53
+	CommandFlushPmksa               = 54
+	CommandRemainOnChannel          = 55
+	CommandCancelRemainOnChannel    = 56
+	CommandSetTxBitrateMask         = 57
+	CommandRegisterFrame            = 58
+	CommandRegisterAction           = 58
+	CommandFrame                    = 59
+	CommandAction                   = 59
+	CommandFrameTxStatus            = 60
+	CommandActionTxStatus           = 60
+	CommandSetPowerSave             = 61
+	CommandGetPowerSave             = 62
+	CommandSetCqm                   = 63
+	CommandNotifyCqm                = 64
+	CommandSetChannel               = 65
+	CommandSetWdsPeer               = 66
+	CommandFrameWaitCancel          = 67
+	CommandJoinMesh                 = 68
+	CommandLeaveMesh                = 69
+	CommandUnprotDeauthenticate     = 70
+	CommandUnprotDisassociate       = 71
+	CommandNewPeerCandidate         = 72
+	CommandGetWowlan                = 73
+	CommandSetWowlan                = 74
+	CommandStartSchedScan           = 75
+	CommandStopSchedScan            = 76
+	CommandSchedScanResults         = 77
+	CommandSchedScanStopped         = 78
+	CommandSetRekeyOffload          = 79
+	CommandPmksaCandidate           = 80
+	CommandTdlsOper                 = 81
+	CommandTdlsMgmt                 = 82
+	CommandUnexpectedFrame          = 83
+	CommandProbeClient              = 84
+	CommandRegisterBeacons          = 85
+	CommandUnexpected4addrFrame     = 86
+	CommandSetNoackMap              = 87
+	CommandChSwitchNotify           = 88
+	CommandStartP2pDevice           = 89
+	CommandStopP2pDevice            = 90
+	CommandConnFailed               = 91
+	CommandSetMcastRate             = 92
+	CommandSetMacAcl                = 93
+	CommandRadarDetect              = 94
+	CommandGetProtocolFeatures      = 95
+	CommandUpdateFtIes              = 96
+	CommandFtEvent                  = 97
+	CommandCritProtocolStart        = 98
+	CommandCritProtocolStop         = 99
+	CommandGetCoalesce              = 100
+	CommandSetCoalesce              = 101
+	CommandChannelSwitch            = 102
+	CommandVendor                   = 103
+	CommandSetQosMap                = 104
+	CommandAddTxTs                  = 105
+	CommandDelTxTs                  = 106
+	CommandGetMpp                   = 107
+	CommandJoinOcb                  = 108
+	CommandLeaveOcb                 = 109
+	CommandChSwitchStartedNotify    = 110
+	CommandTdlsChannelSwitch        = 111
+	CommandTdlsCancelChannelSwitch  = 112
+	CommandWiphyRegChange           = 113
+	CommandAbortScan                = 114
+	CommandStartNan                 = 115
+	CommandStopNan                  = 116
+	CommandAddNanFunction           = 117
+	CommandDelNanFunction           = 118
+	CommandChangeNanConfig          = 119
+	CommandNanMatch                 = 120
+	CommandSetMulticastToUnicast    = 121
+	CommandUpdateConnectParams      = 122
+	CommandSetPmk                   = 123
+	CommandDelPmk                   = 124
+	CommandPortAuthorized           = 125
+	CommandReloadRegdb              = 126
+	CommandExternalAuth             = 127
+	CommandStaOpmodeChanged         = 128
+	CommandControlPortFrame         = 129
+	CommandGetFtmResponderStats     = 130
+	CommandPeerMeasurementStart     = 131
+	CommandPeerMeasurementResult    = 132
+	CommandPeerMeasurementComplete  = 133
+	CommandNotifyRadar              = 134
+	CommandUpdateOweInfo            = 135
+	CommandProbeMeshLink            = 136
+	CommandSetTidConfig             = 137
+	CommandUnprotBeacon             = 138
+	CommandControlPortFrameTxStatus = 139
+	CommandSetSarSpecs              = 140
+	CommandObssColorCollision       = 141
+	CommandColorChangeRequest       = 142
+	CommandColorChangeStarted       = 143
+	CommandColorChangeAborted       = 144
+	CommandColorChangeCompleted     = 145
+	CommandSetFilsAad               = 146
+	CommandAssocComeback            = 147
+	CommandAddLink                  = 148
+	CommandRemoveLink               = 149
+	CommandAddLinkSta               = 150
+	CommandModifyLinkSta            = 151
+	CommandRemoveLinkSta            = 152
+	CommandMax                      = 152
+)
+
+// Macros.
+const (
+	CommandGetMeshParams     = 28
+	CommandSetMeshParams     = 29
+	MeshSetupVendorPathSelIe = 3
+)
+
+// enum nl80211_attrs
+const (
+	AttrUnspec                       = 0
+	AttrWiphy                        = 1
+	AttrWiphyName                    = 2
+	AttrIfindex                      = 3
+	AttrIfname                       = 4
+	AttrIftype                       = 5
+	AttrMac                          = 6
+	AttrKeyData                      = 7
+	AttrKeyIdx                       = 8
+	AttrKeyCipher                    = 9
+	AttrKeySeq                       = 10
+	AttrKeyDefault                   = 11
+	AttrBeaconInterval               = 12
+	AttrDtimPeriod                   = 13
+	AttrBeaconHead                   = 14
+	AttrBeaconTail                   = 15
+	AttrStaAid                       = 16
+	AttrStaFlags                     = 17
+	AttrStaListenInterval            = 18
+	AttrStaSupportedRates            = 19
+	AttrStaVlan                      = 20
+	AttrStaInfo                      = 21
+	AttrWiphyBands                   = 22
+	AttrMntrFlags                    = 23
+	AttrMeshId                       = 24
+	AttrStaPlinkAction               = 25
+	AttrMpathNextHop                 = 26
+	AttrMpathInfo                    = 27
+	AttrBssCtsProt                   = 28
+	AttrBssShortPreamble             = 29
+	AttrBssShortSlotTime             = 30
+	AttrHtCapability                 = 31
+	AttrSupportedIftypes             = 32
+	AttrRegAlpha2                    = 33
+	AttrRegRules                     = 34
+	AttrMeshConfig                   = 35
+	AttrBssBasicRates                = 36
+	AttrWiphyTxqParams               = 37
+	AttrWiphyFreq                    = 38
+	AttrWiphyChannelType             = 39
+	AttrKeyDefaultMgmt               = 40
+	AttrMgmtSubtype                  = 41
+	AttrIe                           = 42
+	AttrMaxNumScanSsids              = 43
+	AttrScanFrequencies              = 44
+	AttrScanSsids                    = 45
+	AttrGeneration                   = 46
+	AttrBss                          = 47
+	AttrRegInitiator                 = 48
+	AttrRegType                      = 49
+	AttrSupportedCommands            = 50
+	AttrFrame                        = 51
+	AttrSsid                         = 52
+	AttrAuthType                     = 53
+	AttrReasonCode                   = 54
+	AttrKeyType                      = 55
+	AttrMaxScanIeLen                 = 56
+	AttrCipherSuites                 = 57
+	AttrFreqBefore                   = 58
+	AttrFreqAfter                    = 59
+	AttrFreqFixed                    = 60
+	AttrWiphyRetryShort              = 61
+	AttrWiphyRetryLong               = 62
+	AttrWiphyFragThreshold           = 63
+	AttrWiphyRtsThreshold            = 64
+	AttrTimedOut                     = 65
+	AttrUseMfp                       = 66
+	AttrStaFlags2                    = 67
+	AttrControlPort                  = 68
+	AttrTestdata                     = 69
+	AttrPrivacy                      = 70
+	AttrDisconnectedByAp             = 71
+	AttrStatusCode                   = 72
+	AttrCipherSuitesPairwise         = 73
+	AttrCipherSuiteGroup             = 74
+	AttrWpaVersions                  = 75
+	AttrAkmSuites                    = 76
+	AttrReqIe                        = 77
+	AttrRespIe                       = 78
+	AttrPrevBssid                    = 79
+	AttrKey                          = 80
+	AttrKeys                         = 81
+	AttrPid                          = 82
+	Attr4addr                        = 83
+	AttrSurveyInfo                   = 84
+	AttrPmkid                        = 85
+	AttrMaxNumPmkids                 = 86
+	AttrDuration                     = 87
+	AttrCookie                       = 88
+	AttrWiphyCoverageClass           = 89
+	AttrTxRates                      = 90
+	AttrFrameMatch                   = 91
+	AttrAck                          = 92
+	AttrPsState                      = 93
+	AttrCqm                          = 94
+	AttrLocalStateChange             = 95
+	AttrApIsolate                    = 96
+	AttrWiphyTxPowerSetting          = 97
+	AttrWiphyTxPowerLevel            = 98
+	AttrTxFrameTypes                 = 99
+	AttrRxFrameTypes                 = 100
+	AttrFrameType                    = 101
+	AttrControlPortEthertype         = 102
+	AttrControlPortNoEncrypt         = 103
+	AttrSupportIbssRsn               = 104
+	AttrWiphyAntennaTx               = 105
+	AttrWiphyAntennaRx               = 106
+	AttrMcastRate                    = 107
+	AttrOffchannelTxOk               = 108
+	AttrBssHtOpmode                  = 109
+	AttrKeyDefaultTypes              = 110
+	AttrMaxRemainOnChannelDuration   = 111
+	AttrMeshSetup                    = 112
+	AttrWiphyAntennaAvailTx          = 113
+	AttrWiphyAntennaAvailRx          = 114
+	AttrSupportMeshAuth              = 115
+	AttrStaPlinkState                = 116
+	AttrWowlanTriggers               = 117
+	AttrWowlanTriggersSupported      = 118
+	AttrSchedScanInterval            = 119
+	AttrInterfaceCombinations        = 120
+	AttrSoftwareIftypes              = 121
+	AttrRekeyData                    = 122
+	AttrMaxNumSchedScanSsids         = 123
+	AttrMaxSchedScanIeLen            = 124
+	AttrScanSuppRates                = 125
+	AttrHiddenSsid                   = 126
+	AttrIeProbeResp                  = 127
+	AttrIeAssocResp                  = 128
+	AttrStaWme                       = 129
+	AttrSupportApUapsd               = 130
+	AttrRoamSupport                  = 131
+	AttrSchedScanMatch               = 132
+	AttrMaxMatchSets                 = 133
+	AttrPmksaCandidate               = 134
+	AttrTxNoCckRate                  = 135
+	AttrTdlsAction                   = 136
+	AttrTdlsDialogToken              = 137
+	AttrTdlsOperation                = 138
+	AttrTdlsSupport                  = 139
+	AttrTdlsExternalSetup            = 140
+	AttrDeviceApSme                  = 141
+	AttrDontWaitForAck               = 142
+	AttrFeatureFlags                 = 143
+	AttrProbeRespOffload             = 144
+	AttrProbeResp                    = 145
+	AttrDfsRegion                    = 146
+	AttrDisableHt                    = 147
+	AttrHtCapabilityMask             = 148
+	AttrNoackMap                     = 149
+	AttrInactivityTimeout            = 150
+	AttrRxSignalDbm                  = 151
+	AttrBgScanPeriod                 = 152
+	AttrWdev                         = 153
+	AttrUserRegHintType              = 154
+	AttrConnFailedReason             = 155
+	AttrAuthData                     = 156
+	AttrVhtCapability                = 157
+	AttrScanFlags                    = 158
+	AttrChannelWidth                 = 159
+	AttrCenterFreq1                  = 160
+	AttrCenterFreq2                  = 161
+	AttrP2pCtwindow                  = 162
+	AttrP2pOppps                     = 163
+	AttrLocalMeshPowerMode           = 164
+	AttrAclPolicy                    = 165
+	AttrMacAddrs                     = 166
+	AttrMacAclMax                    = 167
+	AttrRadarEvent                   = 168
+	AttrExtCapa                      = 169
+	AttrExtCapaMask                  = 170
+	AttrStaCapability                = 171
+	AttrStaExtCapability             = 172
+	AttrProtocolFeatures             = 173
+	AttrSplitWiphyDump               = 174
+	AttrDisableVht                   = 175
+	AttrVhtCapabilityMask            = 176
+	AttrMdid                         = 177
+	AttrIeRic                        = 178
+	AttrCritProtId                   = 179
+	AttrMaxCritProtDuration          = 180
+	AttrPeerAid                      = 181
+	AttrCoalesceRule                 = 182
+	AttrChSwitchCount                = 183
+	AttrChSwitchBlockTx              = 184
+	AttrCsaIes                       = 185
+	AttrCntdwnOffsBeacon             = 186
+	AttrCntdwnOffsPresp              = 187
+	AttrRxmgmtFlags                  = 188
+	AttrStaSupportedChannels         = 189
+	AttrStaSupportedOperClasses      = 190
+	AttrHandleDfs                    = 191
+	AttrSupport5Mhz                  = 192
+	AttrSupport10Mhz                 = 193
+	AttrOpmodeNotif                  = 194
+	AttrVendorId                     = 195
+	AttrVendorSubcmd                 = 196
+	AttrVendorData                   = 197
+	AttrVendorEvents                 = 198
+	AttrQosMap                       = 199
+	AttrMacHint                      = 200
+	AttrWiphyFreqHint                = 201
+	AttrMaxApAssocSta                = 202
+	AttrTdlsPeerCapability           = 203
+	AttrSocketOwner                  = 204
+	AttrCsaCOffsetsTx                = 205
+	AttrMaxCsaCounters               = 206
+	AttrTdlsInitiator                = 207
+	AttrUseRrm                       = 208
+	AttrWiphyDynAck                  = 209
+	AttrTsid                         = 210
+	AttrUserPrio                     = 211
+	AttrAdmittedTime                 = 212
+	AttrSmpsMode                     = 213
+	AttrOperClass                    = 214
+	AttrMacMask                      = 215
+	AttrWiphySelfManagedReg          = 216
+	AttrExtFeatures                  = 217
+	AttrSurveyRadioStats             = 218
+	AttrNetnsFd                      = 219
+	AttrSchedScanDelay               = 220
+	AttrRegIndoor                    = 221
+	AttrMaxNumSchedScanPlans         = 222
+	AttrMaxScanPlanInterval          = 223
+	AttrMaxScanPlanIterations        = 224
+	AttrSchedScanPlans               = 225
+	AttrPbss                         = 226
+	AttrBssSelect                    = 227
+	AttrStaSupportP2pPs              = 228
+	AttrPad                          = 229
+	AttrIftypeExtCapa                = 230
+	AttrMuMimoGroupData              = 231
+	AttrMuMimoFollowMacAddr          = 232
+	AttrScanStartTimeTsf             = 233
+	AttrScanStartTimeTsfBssid        = 234
+	AttrMeasurementDuration          = 235
+	AttrMeasurementDurationMandatory = 236
+	AttrMeshPeerAid                  = 237
+	AttrNanMasterPref                = 238
+	AttrBands                        = 239
+	AttrNanFunc                      = 240
+	AttrNanMatch                     = 241
+	AttrFilsKek                      = 242
+	AttrFilsNonces                   = 243
+	AttrMulticastToUnicastEnabled    = 244
+	AttrBssid                        = 245
+	AttrSchedScanRelativeRssi        = 246
+	AttrSchedScanRssiAdjust          = 247
+	AttrTimeoutReason                = 248
+	AttrFilsErpUsername              = 249
+	AttrFilsErpRealm                 = 250
+	AttrFilsErpNextSeqNum            = 251
+	AttrFilsErpRrk                   = 252
+	AttrFilsCacheId                  = 253
+	AttrPmk                          = 254
+	AttrSchedScanMulti               = 255
+	AttrSchedScanMaxReqs             = 256
+	AttrWant1x4wayHs                 = 257
+	AttrPmkr0Name                    = 258
+	AttrPortAuthorized               = 259
+	AttrExternalAuthAction           = 260
+	AttrExternalAuthSupport          = 261
+	AttrNss                          = 262
+	AttrAckSignal                    = 263
+	AttrControlPortOverNl80211       = 264
+	AttrTxqStats                     = 265
+	AttrTxqLimit                     = 266
+	AttrTxqMemoryLimit               = 267
+	AttrTxqQuantum                   = 268
+	AttrHeCapability                 = 269
+	AttrFtmResponder                 = 270
+	AttrFtmResponderStats            = 271
+	AttrTimeout                      = 272
+	AttrPeerMeasurements             = 273
+	AttrAirtimeWeight                = 274
+	AttrStaTxPowerSetting            = 275
+	AttrStaTxPower                   = 276
+	AttrSaePassword                  = 277
+	AttrTwtResponder                 = 278
+	AttrHeObssPd                     = 279
+	AttrWiphyEdmgChannels            = 280
+	AttrWiphyEdmgBwConfig            = 281
+	AttrVlanId                       = 282
+	AttrHeBssColor                   = 283
+	AttrIftypeAkmSuites              = 284
+	AttrTidConfig                    = 285
+	AttrControlPortNoPreauth         = 286
+	AttrPmkLifetime                  = 287
+	AttrPmkReauthThreshold           = 288
+	AttrReceiveMulticast             = 289
+	AttrWiphyFreqOffset              = 290
+	AttrCenterFreq1Offset            = 291
+	AttrScanFreqKhz                  = 292
+	AttrHe6ghzCapability             = 293
+	AttrFilsDiscovery                = 294
+	AttrUnsolBcastProbeResp          = 295
+	AttrS1gCapability                = 296
+	AttrS1gCapabilityMask            = 297
+	AttrSaePwe                       = 298
+	AttrReconnectRequested           = 299
+	AttrSarSpec                      = 300
+	AttrDisableHe                    = 301
+	AttrObssColorBitmap              = 302
+	AttrColorChangeCount             = 303
+	AttrColorChangeColor             = 304
+	AttrColorChangeElems             = 305
+	AttrMbssidConfig                 = 306
+	AttrMbssidElems                  = 307
+	AttrRadarBackground              = 308
+	AttrApSettingsFlags              = 309
+	AttrEhtCapability                = 310
+	AttrDisableEht                   = 311
+	AttrMloLinks                     = 312
+	AttrMloLinkId                    = 313
+	AttrMldAddr                      = 314
+	AttrMloSupport                   = 315
+	AttrMaxNumAkmSuites              = 316
+	AttrEmlCapability                = 317
+	AttrMldCapaAndOps                = 318
+	AttrTxHwTimestamp                = 319
+	AttrRxHwTimestamp                = 320
+	AttrMax                          = 320
+)
+
+// Macros.
+const (
+	AttrScanGeneration     = 46
+	AttrMeshParams         = 35
+	AttrIfaceSocketOwner   = 204
+	AttrSaeData            = 156
+	AttrCsaCOffBeacon      = 186
+	AttrCsaCOffPresp       = 187
+	WiphyNameMaxlen        = 64
+	MaxSuppRates           = 32
+	MaxSuppHtRates         = 77
+	MaxSuppRegRules        = 128
+	TkipDataOffsetEncrKey  = 0
+	TkipDataOffsetTxMicKey = 16
+	TkipDataOffsetRxMicKey = 24
+	HtCapabilityLen        = 26
+	VhtCapabilityLen       = 12
+	HeMinCapabilityLen     = 16
+	HeMaxCapabilityLen     = 54
+	MaxNrCipherSuites      = 5
+	MaxNrAkmSuites         = 2
+	EhtMinCapabilityLen    = 13
+	EhtMaxCapabilityLen    = 51
+	MinRemainOnChannelTime = 10
+	ScanRssiTholdOff       = -300
+	CqmTxeMaxIntvl         = 1800
+)
+
+// enum nl80211_iftype
+const (
+	IftypeUnspecified = 0
+	IftypeAdhoc       = 1
+	IftypeStation     = 2
+	IftypeAp          = 3
+	IftypeApVlan      = 4
+	IftypeWds         = 5
+	IftypeMonitor     = 6
+	IftypeMeshPoint   = 7
+	IftypeP2pClient   = 8
+	IftypeP2pGo       = 9
+	IftypeP2pDevice   = 10
+	IftypeOcb         = 11
+	IftypeNan         = 12
+	IftypeMax         = 12
+)
+
+// enum nl80211_sta_flags
+const (
+	StaFlagAuthorized    = 1
+	StaFlagShortPreamble = 2
+	StaFlagWme           = 3
+	StaFlagMfp           = 4
+	StaFlagAuthenticated = 5
+	StaFlagTdlsPeer      = 6
+	StaFlagAssociated    = 7
+	StaFlagMax           = 7
+)
+
+// enum nl80211_sta_p2p_ps_status
+const (
+	P2pPsUnsupported = 0
+	P2pPsSupported   = 1
+)
+
+// Macros.
+const (
+	StaFlagMaxOldApi = 6
+)
+
+// enum nl80211_he_gi
+const (
+	RateInfoHeGi08 = 0
+	RateInfoHeGi16 = 1
+	RateInfoHeGi32 = 2
+)
+
+// enum nl80211_he_ltf
+const (
+	RateInfoHe1xltf = 0
+	RateInfoHe2xltf = 1
+	RateInfoHe4xltf = 2
+)
+
+// enum nl80211_he_ru_alloc
+const (
+	RateInfoHeRuAlloc26    = 0
+	RateInfoHeRuAlloc52    = 1
+	RateInfoHeRuAlloc106   = 2
+	RateInfoHeRuAlloc242   = 3
+	RateInfoHeRuAlloc484   = 4
+	RateInfoHeRuAlloc996   = 5
+	RateInfoHeRuAlloc2x996 = 6
+)
+
+// enum nl80211_eht_gi
+const (
+	RateInfoEhtGi08 = 0
+	RateInfoEhtGi16 = 1
+	RateInfoEhtGi32 = 2
+)
+
+// enum nl80211_eht_ru_alloc
+const (
+	RateInfoEhtRuAlloc26          = 0
+	RateInfoEhtRuAlloc52          = 1
+	RateInfoEhtRuAlloc52p26       = 2
+	RateInfoEhtRuAlloc106         = 3
+	RateInfoEhtRuAlloc106p26      = 4
+	RateInfoEhtRuAlloc242         = 5
+	RateInfoEhtRuAlloc484         = 6
+	RateInfoEhtRuAlloc484p242     = 7
+	RateInfoEhtRuAlloc996         = 8
+	RateInfoEhtRuAlloc996p484     = 9
+	RateInfoEhtRuAlloc996p484p242 = 10
+	RateInfoEhtRuAlloc2x996       = 11
+	RateInfoEhtRuAlloc2x996p484   = 12
+	RateInfoEhtRuAlloc3x996       = 13
+	RateInfoEhtRuAlloc3x996p484   = 14
+	RateInfoEhtRuAlloc4x996       = 15
+)
+
+// enum nl80211_rate_info
+const (
+	RateInfoBitrate       = 1
+	RateInfoMcs           = 2
+	RateInfo40MhzWidth    = 3
+	RateInfoShortGi       = 4
+	RateInfoBitrate32     = 5
+	RateInfoVhtMcs        = 6
+	RateInfoVhtNss        = 7
+	RateInfo80MhzWidth    = 8
+	RateInfo80p80MhzWidth = 9
+	RateInfo160MhzWidth   = 10
+	RateInfo10MhzWidth    = 11
+	RateInfo5MhzWidth     = 12
+	RateInfoHeMcs         = 13
+	RateInfoHeNss         = 14
+	RateInfoHeGi          = 15
+	RateInfoHeDcm         = 16
+	RateInfoHeRuAlloc     = 17
+	RateInfo320MhzWidth   = 18
+	RateInfoEhtMcs        = 19
+	RateInfoEhtNss        = 20
+	RateInfoEhtGi         = 21
+	RateInfoEhtRuAlloc    = 22
+	RateInfoMax           = 22
+)
+
+// enum nl80211_sta_bss_param
+const (
+	StaBssParamCtsProt        = 1
+	StaBssParamShortPreamble  = 2
+	StaBssParamShortSlotTime  = 3
+	StaBssParamDtimPeriod     = 4
+	StaBssParamBeaconInterval = 5
+	StaBssParamMax            = 5
+)
+
+// enum nl80211_sta_info
+const (
+	StaInfoInactiveTime       = 1
+	StaInfoRxBytes            = 2
+	StaInfoTxBytes            = 3
+	StaInfoLlid               = 4
+	StaInfoPlid               = 5
+	StaInfoPlinkState         = 6
+	StaInfoSignal             = 7
+	StaInfoTxBitrate          = 8
+	StaInfoRxPackets          = 9
+	StaInfoTxPackets          = 10
+	StaInfoTxRetries          = 11
+	StaInfoTxFailed           = 12
+	StaInfoSignalAvg          = 13
+	StaInfoRxBitrate          = 14
+	StaInfoBssParam           = 15
+	StaInfoConnectedTime      = 16
+	StaInfoStaFlags           = 17
+	StaInfoBeaconLoss         = 18
+	StaInfoTOffset            = 19
+	StaInfoLocalPm            = 20
+	StaInfoPeerPm             = 21
+	StaInfoNonpeerPm          = 22
+	StaInfoRxBytes64          = 23
+	StaInfoTxBytes64          = 24
+	StaInfoChainSignal        = 25
+	StaInfoChainSignalAvg     = 26
+	StaInfoExpectedThroughput = 27
+	StaInfoRxDropMisc         = 28
+	StaInfoBeaconRx           = 29
+	StaInfoBeaconSignalAvg    = 30
+	StaInfoTidStats           = 31
+	StaInfoRxDuration         = 32
+	StaInfoPad                = 33
+	StaInfoAckSignal          = 34
+	StaInfoAckSignalAvg       = 35
+	StaInfoRxMpdus            = 36
+	StaInfoFcsErrorCount      = 37
+	StaInfoConnectedToGate    = 38
+	StaInfoTxDuration         = 39
+	StaInfoAirtimeWeight      = 40
+	StaInfoAirtimeLinkMetric  = 41
+	StaInfoAssocAtBoottime    = 42
+	StaInfoConnectedToAs      = 43
+	StaInfoMax                = 43
+)
+
+// Macros.
+const (
+	StaInfoDataAckSignalAvg = 35
+)
+
+// enum nl80211_tid_stats
+const (
+	TidStatsRxMsdu        = 1
+	TidStatsTxMsdu        = 2
+	TidStatsTxMsduRetries = 3
+	TidStatsTxMsduFailed  = 4
+	TidStatsPad           = 5
+	TidStatsTxqStats      = 6
+	TidStatsMax           = 6
+)
+
+// enum nl80211_txq_stats
+const (
+	TxqStatsBacklogBytes   = 1
+	TxqStatsBacklogPackets = 2
+	TxqStatsFlows          = 3
+	TxqStatsDrops          = 4
+	TxqStatsEcnMarks       = 5
+	TxqStatsOverlimit      = 6
+	TxqStatsOvermemory     = 7
+	TxqStatsCollisions     = 8
+	TxqStatsTxBytes        = 9
+	TxqStatsTxPackets      = 10
+	TxqStatsMaxFlows       = 11
+	TxqStatsMax            = 11
+)
+
+// enum nl80211_mpath_flags
+const (
+	MpathFlagActive    = 1
+	MpathFlagResolving = 2
+	MpathFlagSnValid   = 4
+	MpathFlagFixed     = 8
+	MpathFlagResolved  = 16
+)
+
+// enum nl80211_mpath_info
+const (
+	MpathInfoFrameQlen        = 1
+	MpathInfoSn               = 2
+	MpathInfoMetric           = 3
+	MpathInfoExptime          = 4
+	MpathInfoFlags            = 5
+	MpathInfoDiscoveryTimeout = 6
+	MpathInfoDiscoveryRetries = 7
+	MpathInfoHopCount         = 8
+	MpathInfoPathChange       = 9
+	MpathInfoMax              = 9
+)
+
+// enum nl80211_band_iftype_attr
+const (
+	BandIftypeAttrIftypes      = 1
+	BandIftypeAttrHeCapMac     = 2
+	BandIftypeAttrHeCapPhy     = 3
+	BandIftypeAttrHeCapMcsSet  = 4
+	BandIftypeAttrHeCapPpe     = 5
+	BandIftypeAttrHe6ghzCapa   = 6
+	BandIftypeAttrVendorElems  = 7
+	BandIftypeAttrEhtCapMac    = 8
+	BandIftypeAttrEhtCapPhy    = 9
+	BandIftypeAttrEhtCapMcsSet = 10
+	BandIftypeAttrEhtCapPpe    = 11
+	BandIftypeAttrMax          = 11
+)
+
+// enum nl80211_band_attr
+const (
+	BandAttrFreqs          = 1
+	BandAttrRates          = 2
+	BandAttrHtMcsSet       = 3
+	BandAttrHtCapa         = 4
+	BandAttrHtAmpduFactor  = 5
+	BandAttrHtAmpduDensity = 6
+	BandAttrVhtMcsSet      = 7
+	BandAttrVhtCapa        = 8
+	BandAttrIftypeData     = 9
+	BandAttrEdmgChannels   = 10
+	BandAttrEdmgBwConfig   = 11
+	BandAttrMax            = 11
+)
+
+// enum nl80211_wmm_rule
+const (
+	WmmrCwMin = 1
+	WmmrCwMax = 2
+	WmmrAifsn = 3
+	WmmrTxop  = 4
+	WmmrMax   = 4
+)
+
+// enum nl80211_frequency_attr
+const (
+	FrequencyAttrFreq         = 1
+	FrequencyAttrDisabled     = 2
+	FrequencyAttrNoIr         = 3
+	FrequencyAttrRadar        = 5
+	FrequencyAttrMaxTxPower   = 6
+	FrequencyAttrDfsState     = 7
+	FrequencyAttrDfsTime      = 8
+	FrequencyAttrNoHt40Minus  = 9
+	FrequencyAttrNoHt40Plus   = 10
+	FrequencyAttrNo80mhz      = 11
+	FrequencyAttrNo160mhz     = 12
+	FrequencyAttrDfsCacTime   = 13
+	FrequencyAttrIndoorOnly   = 14
+	FrequencyAttrIrConcurrent = 15
+	FrequencyAttrNo20mhz      = 16
+	FrequencyAttrNo10mhz      = 17
+	FrequencyAttrWmm          = 18
+	FrequencyAttrNoHe         = 19
+	FrequencyAttrOffset       = 20
+	FrequencyAttr1mhz         = 21
+	FrequencyAttr2mhz         = 22
+	FrequencyAttr4mhz         = 23
+	FrequencyAttr8mhz         = 24
+	FrequencyAttr16mhz        = 25
+	FrequencyAttrNo320mhz     = 26
+	FrequencyAttrNoEht        = 27
+	FrequencyAttrMax          = 27
+)
+
+// Macros.
+const (
+	FrequencyAttrPassiveScan = 3
+	FrequencyAttrNoIbss      = 3
+)
+
+// enum nl80211_bitrate_attr
+const (
+	BitrateAttrRate              = 1
+	BitrateAttr2ghzShortpreamble = 2
+	BitrateAttrMax               = 2
+)
+
+// enum nl80211_reg_initiator
+const (
+	RegdomSetByCore      = 0
+	RegdomSetByUser      = 1
+	RegdomSetByDriver    = 2
+	RegdomSetByCountryIe = 3
+)
+
+// enum nl80211_reg_type
+const (
+	RegdomTypeCountry      = 0
+	RegdomTypeWorld        = 1
+	RegdomTypeCustomWorld  = 2
+	RegdomTypeIntersection = 3
+)
+
+// enum nl80211_reg_rule_attr
+const (
+	AttrRegRuleFlags        = 1
+	AttrFreqRangeStart      = 2
+	AttrFreqRangeEnd        = 3
+	AttrFreqRangeMaxBw      = 4
+	AttrPowerRuleMaxAntGain = 5
+	AttrPowerRuleMaxEirp    = 6
+	AttrDfsCacTime          = 7
+	RegRuleAttrMax          = 7
+)
+
+// enum nl80211_sched_scan_match_attr
+const (
+	SchedScanMatchAttrSsid         = 1
+	SchedScanMatchAttrRssi         = 2
+	SchedScanMatchAttrRelativeRssi = 3
+	SchedScanMatchAttrRssiAdjust   = 4
+	SchedScanMatchAttrBssid        = 5
+	SchedScanMatchPerBandRssi      = 6
+	SchedScanMatchAttrMax          = 6
+)
+
+// Macros.
+const (
+	AttrSchedScanMatchSsid = 1
+)
+
+// enum nl80211_reg_rule_flags
+const (
+	RrfNoOfdm       = 1
+	RrfNoCck        = 2
+	RrfNoIndoor     = 4
+	RrfNoOutdoor    = 8
+	RrfDfs          = 16
+	RrfPtpOnly      = 32
+	RrfPtmpOnly     = 64
+	RrfNoIr         = 128
+	RrfAutoBw       = 2048
+	RrfIrConcurrent = 4096
+	RrfNoHt40minus  = 8192
+	RrfNoHt40plus   = 16384
+	RrfNo80mhz      = 32768
+	RrfNo160mhz     = 65536
+	RrfNoHe         = 131072
+	RrfNo320mhz     = 262144
+)
+
+// Macros.
+const (
+	RrfPassiveScan  = 128
+	RrfNoIbss       = 128
+	RrfGoConcurrent = 4096
+	RrfNoIrAll      = 384
+)
+
+// enum nl80211_dfs_regions
+const (
+	DfsUnset = 0
+	DfsFcc   = 1
+	DfsEtsi  = 2
+	DfsJp    = 3
+)
+
+// enum nl80211_user_reg_hint_type
+const (
+	UserRegHintUser     = 0
+	UserRegHintCellBase = 1
+	UserRegHintIndoor   = 2
+)
+
+// enum nl80211_survey_info
+const (
+	SurveyInfoFrequency       = 1
+	SurveyInfoNoise           = 2
+	SurveyInfoInUse           = 3
+	SurveyInfoTime            = 4
+	SurveyInfoTimeBusy        = 5
+	SurveyInfoTimeExtBusy     = 6
+	SurveyInfoTimeRx          = 7
+	SurveyInfoTimeTx          = 8
+	SurveyInfoTimeScan        = 9
+	SurveyInfoPad             = 10
+	SurveyInfoTimeBssRx       = 11
+	SurveyInfoFrequencyOffset = 12
+	SurveyInfoMax             = 12
+)
+
+// Macros.
+const (
+	SurveyInfoChannelTime        = 4
+	SurveyInfoChannelTimeBusy    = 5
+	SurveyInfoChannelTimeExtBusy = 6
+	SurveyInfoChannelTimeRx      = 7
+	SurveyInfoChannelTimeTx      = 8
+)
+
+// enum nl80211_mntr_flags
+const (
+	MntrFlagFcsfail    = 1
+	MntrFlagPlcpfail   = 2
+	MntrFlagControl    = 3
+	MntrFlagOtherBss   = 4
+	MntrFlagCookFrames = 5
+	MntrFlagActive     = 6
+	MntrFlagMax        = 6
+)
+
+// enum nl80211_mesh_power_mode
+const (
+	MeshPowerUnknown    = 0
+	MeshPowerActive     = 1
+	MeshPowerLightSleep = 2
+	MeshPowerDeepSleep  = 3
+	MeshPowerMax        = 3
+)
+
+// enum nl80211_meshconf_params
+const (
+	MeshconfRetryTimeout             = 1
+	MeshconfConfirmTimeout           = 2
+	MeshconfHoldingTimeout           = 3
+	MeshconfMaxPeerLinks             = 4
+	MeshconfMaxRetries               = 5
+	MeshconfTtl                      = 6
+	MeshconfAutoOpenPlinks           = 7
+	MeshconfHwmpMaxPreqRetries       = 8
+	MeshconfPathRefreshTime          = 9
+	MeshconfMinDiscoveryTimeout      = 10
+	MeshconfHwmpActivePathTimeout    = 11
+	MeshconfHwmpPreqMinInterval      = 12
+	MeshconfHwmpNetDiamTrvsTime      = 13
+	MeshconfHwmpRootmode             = 14
+	MeshconfElementTtl               = 15
+	MeshconfHwmpRannInterval         = 16
+	MeshconfGateAnnouncements        = 17
+	MeshconfHwmpPerrMinInterval      = 18
+	MeshconfForwarding               = 19
+	MeshconfRssiThreshold            = 20
+	MeshconfSyncOffsetMaxNeighbor    = 21
+	MeshconfHtOpmode                 = 22
+	MeshconfHwmpPathToRootTimeout    = 23
+	MeshconfHwmpRootInterval         = 24
+	MeshconfHwmpConfirmationInterval = 25
+	MeshconfPowerMode                = 26
+	MeshconfAwakeWindow              = 27
+	MeshconfPlinkTimeout             = 28
+	MeshconfConnectedToGate          = 29
+	MeshconfNolearn                  = 30
+	MeshconfConnectedToAs            = 31
+	MeshconfAttrMax                  = 31
+)
+
+// enum nl80211_mesh_setup_params
+const (
+	MeshSetupEnableVendorPathSel = 1
+	MeshSetupEnableVendorMetric  = 2
+	MeshSetupIe                  = 3
+	MeshSetupUserspaceAuth       = 4
+	MeshSetupUserspaceAmpe       = 5
+	MeshSetupEnableVendorSync    = 6
+	MeshSetupUserspaceMpm        = 7
+	MeshSetupAuthProtocol        = 8
+	MeshSetupAttrMax             = 8
+)
+
+// enum nl80211_txq_attr
+const (
+	TxqAttrAc    = 1
+	TxqAttrTxop  = 2
+	TxqAttrCwmin = 3
+	TxqAttrCwmax = 4
+	TxqAttrAifs  = 5
+	TxqAttrMax   = 5
+)
+
+// enum nl80211_ac
+const (
+	AcVo   = 0
+	AcVi   = 1
+	AcBe   = 2
+	AcBk   = 3
+	NumAcs = 4
+)
+
+// Macros.
+const (
+	TxqAttrQueue = 1
+	TxqQVo       = 0
+	TxqQVi       = 1
+	TxqQBe       = 2
+	TxqQBk       = 3
+)
+
+// enum nl80211_channel_type
+const (
+	ChanNoHt      = 0
+	ChanHt20      = 1
+	ChanHt40minus = 2
+	ChanHt40plus  = 3
+)
+
+// enum nl80211_key_mode
+const (
+	KeyRxTx  = 0
+	KeyNoTx  = 1
+	KeySetTx = 2
+)
+
+// enum nl80211_chan_width
+const (
+	ChanWidth20Noht = 0
+	ChanWidth20     = 1
+	ChanWidth40     = 2
+	ChanWidth80     = 3
+	ChanWidth80p80  = 4
+	ChanWidth160    = 5
+	ChanWidth5      = 6
+	ChanWidth10     = 7
+	ChanWidth1      = 8
+	ChanWidth2      = 9
+	ChanWidth4      = 10
+	ChanWidth8      = 11
+	ChanWidth16     = 12
+	ChanWidth320    = 13
+)
+
+// enum nl80211_bss_scan_width
+const (
+	BssChanWidth20 = 0
+	BssChanWidth10 = 1
+	BssChanWidth5  = 2
+	BssChanWidth1  = 3
+	BssChanWidth2  = 4
+)
+
+// enum nl80211_bss
+const (
+	BssBssid               = 1
+	BssFrequency           = 2
+	BssTsf                 = 3
+	BssBeaconInterval      = 4
+	BssCapability          = 5
+	BssInformationElements = 6
+	BssSignalMbm           = 7
+	BssSignalUnspec        = 8
+	BssStatus              = 9
+	BssSeenMsAgo           = 10
+	BssBeaconIes           = 11
+	BssChanWidth           = 12
+	BssBeaconTsf           = 13
+	BssPrespData           = 14
+	BssLastSeenBoottime    = 15
+	BssPad                 = 16
+	BssParentTsf           = 17
+	BssParentBssid         = 18
+	BssChainSignal         = 19
+	BssFrequencyOffset     = 20
+	BssMloLinkId           = 21
+	BssMldAddr             = 22
+	BssMax                 = 22
+)
+
+// enum nl80211_bss_status
+const (
+	BssStatusAuthenticated = 0
+	BssStatusAssociated    = 1
+	BssStatusIbssJoined    = 2
+)
+
+// enum nl80211_auth_type
+const (
+	AuthtypeOpenSystem = 0
+	AuthtypeSharedKey  = 1
+	AuthtypeFt         = 2
+	AuthtypeNetworkEap = 3
+	AuthtypeSae        = 4
+	AuthtypeFilsSk     = 5
+	AuthtypeFilsSkPfs  = 6
+	AuthtypeFilsPk     = 7
+	AuthtypeMax        = 7
+	AuthtypeAutomatic  = 8
+)
+
+// enum nl80211_key_type
+const (
+	KeytypeGroup    = 0
+	KeytypePairwise = 1
+	KeytypePeerkey  = 2
+)
+
+// enum nl80211_mfp
+const (
+	MfpNo       = 0
+	MfpRequired = 1
+	MfpOptional = 2
+)
+
+// enum nl80211_wpa_versions
+const (
+	WpaVersion1 = 1
+	WpaVersion2 = 2
+	WpaVersion3 = 4
+)
+
+// enum nl80211_key_default_types
+const (
+	KeyDefaultTypeUnicast   = 1
+	KeyDefaultTypeMulticast = 2
+)
+
+// enum nl80211_key_attributes
+const (
+	KeyData          = 1
+	KeyIdx           = 2
+	KeyCipher        = 3
+	KeySeq           = 4
+	KeyDefault       = 5
+	KeyDefaultMgmt   = 6
+	KeyType          = 7
+	KeyDefaultTypes  = 8
+	KeyMode          = 9
+	KeyDefaultBeacon = 10
+	KeyMax           = 10
+)
+
+// enum nl80211_tx_rate_attributes
+const (
+	TxrateLegacy = 1
+	TxrateHt     = 2
+	TxrateVht    = 3
+	TxrateGi     = 4
+	TxrateHe     = 5
+	TxrateHeGi   = 6
+	TxrateHeLtf  = 7
+	TxrateMax    = 7
+)
+
+// Macros.
+const (
+	TxrateMcs = 2
+	VhtNssMax = 8
+	HeNssMax  = 8
+)
+
+// enum nl80211_txrate_gi
+const (
+	TxrateDefaultGi = 0
+	TxrateForceSgi  = 1
+	TxrateForceLgi  = 2
+)
+
+// enum nl80211_band
+const (
+	Band2ghz  = 0
+	Band5ghz  = 1
+	Band60ghz = 2
+	Band6ghz  = 3
+	BandS1ghz = 4
+	BandLc    = 5
+)
+
+// enum nl80211_ps_state
+const (
+	PsDisabled = 0
+	PsEnabled  = 1
+)
+
+// enum nl80211_attr_cqm
+const (
+	AttrCqmRssiThold          = 1
+	AttrCqmRssiHyst           = 2
+	AttrCqmRssiThresholdEvent = 3
+	AttrCqmPktLossEvent       = 4
+	AttrCqmTxeRate            = 5
+	AttrCqmTxePkts            = 6
+	AttrCqmTxeIntvl           = 7
+	AttrCqmBeaconLossEvent    = 8
+	AttrCqmRssiLevel          = 9
+	AttrCqmMax                = 9
+)
+
+// enum nl80211_cqm_rssi_threshold_event
+const (
+	CqmRssiThresholdEventLow  = 0
+	CqmRssiThresholdEventHigh = 1
+	CqmRssiBeaconLossEvent    = 2
+)
+
+// enum nl80211_tx_power_setting
+const (
+	TxPowerAutomatic = 0
+	TxPowerLimited   = 1
+	TxPowerFixed     = 2
+)
+
+// enum nl80211_tid_config
+const (
+	TidConfigEnable  = 0
+	TidConfigDisable = 1
+)
+
+// enum nl80211_tx_rate_setting
+const (
+	TxRateAutomatic = 0
+	TxRateLimited   = 1
+	TxRateFixed     = 2
+)
+
+// enum nl80211_tid_config_attr
+const (
+	TidConfigAttrPad        = 1
+	TidConfigAttrVifSupp    = 2
+	TidConfigAttrPeerSupp   = 3
+	TidConfigAttrOverride   = 4
+	TidConfigAttrTids       = 5
+	TidConfigAttrNoack      = 6
+	TidConfigAttrRetryShort = 7
+	TidConfigAttrRetryLong  = 8
+	TidConfigAttrAmpduCtrl  = 9
+	TidConfigAttrRtsctsCtrl = 10
+	TidConfigAttrAmsduCtrl  = 11
+	TidConfigAttrTxRateType = 12
+	TidConfigAttrTxRate     = 13
+	TidConfigAttrMax        = 13
+)
+
+// enum nl80211_packet_pattern_attr
+const (
+	PktpatMask    = 1
+	PktpatPattern = 2
+	PktpatOffset  = 3
+)
+
+// Macros.
+const (
+	WowlanPktpatMask    = 1
+	WowlanPktpatPattern = 2
+	WowlanPktpatOffset  = 3
+)
+
+// enum nl80211_wowlan_triggers
+const (
+	WowlanTrigAny                   = 1
+	WowlanTrigDisconnect            = 2
+	WowlanTrigMagicPkt              = 3
+	WowlanTrigPktPattern            = 4
+	WowlanTrigGtkRekeySupported     = 5
+	WowlanTrigGtkRekeyFailure       = 6
+	WowlanTrigEapIdentRequest       = 7
+	WowlanTrig4wayHandshake         = 8
+	WowlanTrigRfkillRelease         = 9
+	WowlanTrigWakeupPkt80211        = 10
+	WowlanTrigWakeupPkt80211Len     = 11
+	WowlanTrigWakeupPkt8023         = 12
+	WowlanTrigWakeupPkt8023Len      = 13
+	WowlanTrigTcpConnection         = 14
+	WowlanTrigWakeupTcpMatch        = 15
+	WowlanTrigWakeupTcpConnlost     = 16
+	WowlanTrigWakeupTcpNomoretokens = 17
+	WowlanTrigNetDetect             = 18
+	WowlanTrigNetDetectResults      = 19
+)
+
+// enum nl80211_wowlan_tcp_attrs
+const (
+	WowlanTcpSrcIpv4          = 1
+	WowlanTcpDstIpv4          = 2
+	WowlanTcpDstMac           = 3
+	WowlanTcpSrcPort          = 4
+	WowlanTcpDstPort          = 5
+	WowlanTcpDataPayload      = 6
+	WowlanTcpDataPayloadSeq   = 7
+	WowlanTcpDataPayloadToken = 8
+	WowlanTcpDataInterval     = 9
+	WowlanTcpWakePayload      = 10
+	WowlanTcpWakeMask         = 11
+)
+
+// enum nl80211_attr_coalesce_rule
+const (
+	AttrCoalesceRuleDelay      = 1
+	AttrCoalesceRuleCondition  = 2
+	AttrCoalesceRulePktPattern = 3
+	AttrCoalesceRuleMax        = 3
+)
+
+// enum nl80211_coalesce_condition
+const (
+	CoalesceConditionMatch   = 0
+	CoalesceConditionNoMatch = 1
+)
+
+// enum nl80211_iface_limit_attrs
+const (
+	IfaceLimitUnspec = 0
+	IfaceLimitMax    = 1
+	IfaceLimitTypes  = 2
+)
+
+// enum nl80211_if_combination_attrs
+const (
+	IfaceCombUnspec             = 0
+	IfaceCombLimits             = 1
+	IfaceCombMaxnum             = 2
+	IfaceCombStaApBiMatch       = 3
+	IfaceCombNumChannels        = 4
+	IfaceCombRadarDetectWidths  = 5
+	IfaceCombRadarDetectRegions = 6
+	IfaceCombBiMinGcd           = 7
+)
+
+// enum nl80211_plink_state
+const (
+	PlinkListen  = 0
+	PlinkOpnSnt  = 1
+	PlinkOpnRcvd = 2
+	PlinkCnfRcvd = 3
+	PlinkEstab   = 4
+	PlinkHolding = 5
+	PlinkBlocked = 6
+)
+
+// enum plink_actions
+const (
+	PlinkActionNoAction = 0
+	PlinkActionOpen     = 1
+	PlinkActionBlock    = 2
+)
+
+// Macros.
+const (
+	KckLen       = 16
+	KekLen       = 16
+	KckExtLen    = 24
+	KekExtLen    = 32
+	ReplayCtrLen = 8
+)
+
+// enum nl80211_rekey_data
+const (
+	RekeyDataKek       = 1
+	RekeyDataKck       = 2
+	RekeyDataReplayCtr = 3
+	RekeyDataAkm       = 4
+)
+
+// enum nl80211_hidden_ssid
+const (
+	HiddenSsidNotInUse     = 0
+	HiddenSsidZeroLen      = 1
+	HiddenSsidZeroContents = 2
+)
+
+// enum nl80211_sta_wme_attr
+const (
+	StaWmeUapsdQueues = 1
+	StaWmeMaxSp       = 2
+	StaWmeMax         = 2
+)
+
+// enum nl80211_pmksa_candidate_attr
+const (
+	PmksaCandidateIndex   = 1
+	PmksaCandidateBssid   = 2
+	PmksaCandidatePreauth = 3
+)
+
+// enum nl80211_tdls_operation
+const (
+	TdlsDiscoveryReq = 0
+	TdlsSetup        = 1
+	TdlsTeardown     = 2
+	TdlsEnableLink   = 3
+	TdlsDisableLink  = 4
+)
+
+// enum nl80211_ap_sme_features
+const (
+	ApSmeSaQueryOffload = 1
+)
+
+// enum nl80211_feature_flags
+const (
+	FeatureSkTxStatus             = 1
+	FeatureHtIbss                 = 2
+	FeatureInactivityTimer        = 4
+	FeatureCellBaseRegHints       = 8
+	FeatureP2pDeviceNeedsChannel  = 16
+	FeatureSae                    = 32
+	FeatureLowPriorityScan        = 64
+	FeatureScanFlush              = 128
+	FeatureApScan                 = 256
+	FeatureVifTxpower             = 512
+	FeatureNeedObssScan           = 1024
+	FeatureP2pGoCtwin             = 2048
+	FeatureP2pGoOppps             = 4096
+	FeatureAdvertiseChanLimits    = 16384
+	FeatureFullApClientState      = 32768
+	FeatureUserspaceMpm           = 65536
+	FeatureActiveMonitor          = 131072
+	FeatureApModeChanWidthChange  = 262144
+	FeatureDsParamSetIeInProbes   = 524288
+	FeatureWfaTpcIeInProbes       = 1048576
+	FeatureQuiet                  = 2097152
+	FeatureTxPowerInsertion       = 4194304
+	FeatureAcktoEstimation        = 8388608
+	FeatureStaticSmps             = 16777216
+	FeatureDynamicSmps            = 33554432
+	FeatureSupportsWmmAdmission   = 67108864
+	FeatureMacOnCreate            = 134217728
+	FeatureTdlsChannelSwitch      = 268435456
+	FeatureScanRandomMacAddr      = 536870912
+	FeatureSchedScanRandomMacAddr = 1073741824
+	FeatureNdRandomMacAddr        = 2147483648
+)
+
+// enum nl80211_ext_feature_index
+const (
+	ExtFeatureVhtIbss                        = 0
+	ExtFeatureRrm                            = 1
+	ExtFeatureMuMimoAirSniffer               = 2
+	ExtFeatureScanStartTime                  = 3
+	ExtFeatureBssParentTsf                   = 4
+	ExtFeatureSetScanDwell                   = 5
+	ExtFeatureBeaconRateLegacy               = 6
+	ExtFeatureBeaconRateHt                   = 7
+	ExtFeatureBeaconRateVht                  = 8
+	ExtFeatureFilsSta                        = 9
+	ExtFeatureMgmtTxRandomTa                 = 10
+	ExtFeatureMgmtTxRandomTaConnected        = 11
+	ExtFeatureSchedScanRelativeRssi          = 12
+	ExtFeatureCqmRssiList                    = 13
+	ExtFeatureFilsSkOffload                  = 14
+	ExtFeature4wayHandshakeStaPsk            = 15
+	ExtFeature4wayHandshakeSta1x             = 16
+	ExtFeatureFilsMaxChannelTime             = 17
+	ExtFeatureAcceptBcastProbeResp           = 18
+	ExtFeatureOceProbeReqHighTxRate          = 19
+	ExtFeatureOceProbeReqDeferralSuppression = 20
+	ExtFeatureMfpOptional                    = 21
+	ExtFeatureLowSpanScan                    = 22
+	ExtFeatureLowPowerScan                   = 23
+	ExtFeatureHighAccuracyScan               = 24
+	ExtFeatureDfsOffload                     = 25
+	ExtFeatureControlPortOverNl80211         = 26
+	ExtFeatureAckSignalSupport               = 27
+	ExtFeatureDataAckSignalSupport           = 27
+	ExtFeatureTxqs                           = 28
+	ExtFeatureScanRandomSn                   = 29
+	ExtFeatureScanMinPreqContent             = 30
+	ExtFeatureCanReplacePtk0                 = 31
+	ExtFeatureEnableFtmResponder             = 32
+	ExtFeatureAirtimeFairness                = 33
+	ExtFeatureApPmksaCaching                 = 34
+	ExtFeatureSchedScanBandSpecificRssiThold = 35
+	ExtFeatureExtKeyId                       = 36
+	ExtFeatureStaTxPwr                       = 37
+	ExtFeatureSaeOffload                     = 38
+	ExtFeatureVlanOffload                    = 39
+	ExtFeatureAql                            = 40
+	ExtFeatureBeaconProtection               = 41
+	ExtFeatureControlPortNoPreauth           = 42
+	ExtFeatureProtectedTwt                   = 43
+	ExtFeatureDelIbssSta                     = 44
+	ExtFeatureMulticastRegistrations         = 45
+	ExtFeatureBeaconProtectionClient         = 46
+	ExtFeatureScanFreqKhz                    = 47
+	ExtFeatureControlPortOverNl80211TxStatus = 48
+	ExtFeatureOperatingChannelValidation     = 49
+	ExtFeature4wayHandshakeApPsk             = 50
+	ExtFeatureSaeOffloadAp                   = 51
+	ExtFeatureFilsDiscovery                  = 52
+	ExtFeatureUnsolBcastProbeResp            = 53
+	ExtFeatureBeaconRateHe                   = 54
+	ExtFeatureSecureLtf                      = 55
+	ExtFeatureSecureRtt                      = 56
+	ExtFeatureProtRangeNegoAndMeasure        = 57
+	ExtFeatureBssColor                       = 58
+	ExtFeatureFilsCryptoOffload              = 59
+	ExtFeatureRadarBackground                = 60
+	ExtFeaturePoweredAddrChange              = 61
+)
+
+// enum nl80211_probe_resp_offload_support_attr
+const (
+	ProbeRespOffloadSupportWps    = 1
+	ProbeRespOffloadSupportWps2   = 2
+	ProbeRespOffloadSupportP2p    = 4
+	ProbeRespOffloadSupport80211u = 8
+)
+
+// enum nl80211_connect_failed_reason
+const (
+	ConnFailMaxClients    = 0
+	ConnFailBlockedClient = 1
+)
+
+// enum nl80211_timeout_reason
+const (
+	TimeoutUnspecified = 0
+	TimeoutScan        = 1
+	TimeoutAuth        = 2
+	TimeoutAssoc       = 3
+)
+
+// enum nl80211_scan_flags
+const (
+	ScanFlagLowPriority                    = 1
+	ScanFlagFlush                          = 2
+	ScanFlagAp                             = 4
+	ScanFlagRandomAddr                     = 8
+	ScanFlagFilsMaxChannelTime             = 16
+	ScanFlagAcceptBcastProbeResp           = 32
+	ScanFlagOceProbeReqHighTxRate          = 64
+	ScanFlagOceProbeReqDeferralSuppression = 128
+	ScanFlagLowSpan                        = 256
+	ScanFlagLowPower                       = 512
+	ScanFlagHighAccuracy                   = 1024
+	ScanFlagRandomSn                       = 2048
+	ScanFlagMinPreqContent                 = 4096
+	ScanFlagFreqKhz                        = 8192
+	ScanFlagColocated6ghz                  = 16384
+)
+
+// enum nl80211_acl_policy
+const (
+	AclPolicyAcceptUnlessListed = 0
+	AclPolicyDenyUnlessListed   = 1
+)
+
+// enum nl80211_smps_mode
+const (
+	SmpsOff     = 0
+	SmpsStatic  = 1
+	SmpsDynamic = 2
+	SmpsMax     = 2
+)
+
+// enum nl80211_radar_event
+const (
+	RadarDetected      = 0
+	RadarCacFinished   = 1
+	RadarCacAborted    = 2
+	RadarNopFinished   = 3
+	RadarPreCacExpired = 4
+	RadarCacStarted    = 5
+)
+
+// enum nl80211_dfs_state
+const (
+	DfsUsable      = 0
+	DfsUnavailable = 1
+	DfsAvailable   = 2
+)
+
+// enum nl80211_protocol_features
+const (
+	ProtocolFeatureSplitWiphyDump = 1
+)
+
+// enum nl80211_crit_proto_id
+const (
+	CritProtoUnspec = 0
+	CritProtoDhcp   = 1
+	CritProtoEapol  = 2
+	CritProtoApipa  = 3
+)
+
+// Macros.
+const (
+	CritProtoMaxDuration = 5000
+)
+
+// enum nl80211_rxmgmt_flags
+const (
+	RxmgmtFlagAnswered     = 1
+	RxmgmtFlagExternalAuth = 2
+)
+
+// Macros.
+const (
+	VendorIdIsLinux = 2147483648
+)
+
+// enum nl80211_tdls_peer_capability
+const (
+	TdlsPeerHt  = 1
+	TdlsPeerVht = 2
+	TdlsPeerWmm = 4
+	TdlsPeerHe  = 8
+)
+
+// enum nl80211_sched_scan_plan
+const (
+	SchedScanPlanInterval   = 1
+	SchedScanPlanIterations = 2
+	SchedScanPlanMax        = 2
+)
+
+// enum nl80211_bss_select_attr
+const (
+	BssSelectAttrRssi       = 1
+	BssSelectAttrBandPref   = 2
+	BssSelectAttrRssiAdjust = 3
+	BssSelectAttrMax        = 3
+)
+
+// enum nl80211_nan_function_type
+const (
+	NanFuncPublish   = 0
+	NanFuncSubscribe = 1
+	NanFuncFollowUp  = 2
+	NanFuncMaxType   = 2
+)
+
+// enum nl80211_nan_publish_type
+const (
+	NanSolicitedPublish   = 1
+	NanUnsolicitedPublish = 2
+)
+
+// enum nl80211_nan_func_term_reason
+const (
+	NanFuncTermReasonUserRequest = 0
+	NanFuncTermReasonTtlExpired  = 1
+	NanFuncTermReasonError       = 2
+)
+
+// Macros.
+const (
+	NanFuncServiceIdLen          = 6
+	NanFuncServiceSpecInfoMaxLen = 255
+	NanFuncSrfMaxLen             = 255
+)
+
+// enum nl80211_nan_func_attributes
+const (
+	NanFuncType            = 1
+	NanFuncServiceId       = 2
+	NanFuncPublishType     = 3
+	NanFuncPublishBcast    = 4
+	NanFuncSubscribeActive = 5
+	NanFuncFollowUpId      = 6
+	NanFuncFollowUpReqId   = 7
+	NanFuncFollowUpDest    = 8
+	NanFuncCloseRange      = 9
+	NanFuncTtl             = 10
+	NanFuncServiceInfo     = 11
+	NanFuncSrf             = 12
+	NanFuncRxMatchFilter   = 13
+	NanFuncTxMatchFilter   = 14
+	NanFuncInstanceId      = 15
+	NanFuncTermReason      = 16
+	NanFuncAttrMax         = 16
+)
+
+// enum nl80211_nan_srf_attributes
+const (
+	NanSrfInclude  = 1
+	NanSrfBf       = 2
+	NanSrfBfIdx    = 3
+	NanSrfMacAddrs = 4
+	NanSrfAttrMax  = 4
+)
+
+// enum nl80211_nan_match_attributes
+const (
+	NanMatchFuncLocal = 1
+	NanMatchFuncPeer  = 2
+	NanMatchAttrMax   = 2
+)
+
+// enum nl80211_external_auth_action
+const (
+	ExternalAuthStart = 0
+	ExternalAuthAbort = 1
+)
+
+// enum nl80211_ftm_responder_attributes
+const (
+	FtmRespAttrEnabled  = 1
+	FtmRespAttrLci      = 2
+	FtmRespAttrCivicloc = 3
+	FtmRespAttrMax      = 3
+)
+
+// enum nl80211_ftm_responder_stats
+const (
+	FtmStatsSuccessNum             = 1
+	FtmStatsPartialNum             = 2
+	FtmStatsFailedNum              = 3
+	FtmStatsAsapNum                = 4
+	FtmStatsNonAsapNum             = 5
+	FtmStatsTotalDurationMsec      = 6
+	FtmStatsUnknownTriggersNum     = 7
+	FtmStatsRescheduleRequestsNum  = 8
+	FtmStatsOutOfWindowTriggersNum = 9
+	FtmStatsPad                    = 10
+	FtmStatsMax                    = 10
+)
+
+// enum nl80211_preamble
+const (
+	PreambleLegacy = 0
+	PreambleHt     = 1
+	PreambleVht    = 2
+	PreambleDmg    = 3
+	PreambleHe     = 4
+)
+
+// enum nl80211_peer_measurement_type
+const (
+	PmsrTypeInvalid = 0
+	PmsrTypeFtm     = 1
+	PmsrTypeMax     = 1
+)
+
+// enum nl80211_peer_measurement_status
+const (
+	PmsrStatusSuccess = 0
+	PmsrStatusRefused = 1
+	PmsrStatusTimeout = 2
+	PmsrStatusFailure = 3
+)
+
+// enum nl80211_peer_measurement_req
+const (
+	PmsrReqAttrData     = 1
+	PmsrReqAttrGetApTsf = 2
+	PmsrReqAttrMax      = 2
+)
+
+// enum nl80211_peer_measurement_resp
+const (
+	PmsrRespAttrData     = 1
+	PmsrRespAttrStatus   = 2
+	PmsrRespAttrHostTime = 3
+	PmsrRespAttrApTsf    = 4
+	PmsrRespAttrFinal    = 5
+	PmsrRespAttrPad      = 6
+	PmsrRespAttrMax      = 6
+)
+
+// enum nl80211_peer_measurement_peer_attrs
+const (
+	PmsrPeerAttrAddr = 1
+	PmsrPeerAttrChan = 2
+	PmsrPeerAttrReq  = 3
+	PmsrPeerAttrResp = 4
+	PmsrPeerAttrMax  = 4
+)
+
+// enum nl80211_peer_measurement_attrs
+const (
+	PmsrAttrMaxPeers         = 1
+	PmsrAttrReportApTsf      = 2
+	PmsrAttrRandomizeMacAddr = 3
+	PmsrAttrTypeCapa         = 4
+	PmsrAttrPeers            = 5
+	PmsrAttrMax              = 5
+)
+
+// enum nl80211_peer_measurement_ftm_capa
+const (
+	PmsrFtmCapaAttrAsap              = 1
+	PmsrFtmCapaAttrNonAsap           = 2
+	PmsrFtmCapaAttrReqLci            = 3
+	PmsrFtmCapaAttrReqCivicloc       = 4
+	PmsrFtmCapaAttrPreambles         = 5
+	PmsrFtmCapaAttrBandwidths        = 6
+	PmsrFtmCapaAttrMaxBurstsExponent = 7
+	PmsrFtmCapaAttrMaxFtmsPerBurst   = 8
+	PmsrFtmCapaAttrTriggerBased      = 9
+	PmsrFtmCapaAttrNonTriggerBased   = 10
+	PmsrFtmCapaAttrMax               = 10
+)
+
+// enum nl80211_peer_measurement_ftm_req
+const (
+	PmsrFtmReqAttrAsap            = 1
+	PmsrFtmReqAttrPreamble        = 2
+	PmsrFtmReqAttrNumBurstsExp    = 3
+	PmsrFtmReqAttrBurstPeriod     = 4
+	PmsrFtmReqAttrBurstDuration   = 5
+	PmsrFtmReqAttrFtmsPerBurst    = 6
+	PmsrFtmReqAttrNumFtmrRetries  = 7
+	PmsrFtmReqAttrRequestLci      = 8
+	PmsrFtmReqAttrRequestCivicloc = 9
+	PmsrFtmReqAttrTriggerBased    = 10
+	PmsrFtmReqAttrNonTriggerBased = 11
+	PmsrFtmReqAttrLmrFeedback     = 12
+	PmsrFtmReqAttrBssColor        = 13
+	PmsrFtmReqAttrMax             = 13
+)
+
+// enum nl80211_peer_measurement_ftm_failure_reasons
+const (
+	PmsrFtmFailureUnspecified      = 0
+	PmsrFtmFailureNoResponse       = 1
+	PmsrFtmFailureRejected         = 2
+	PmsrFtmFailureWrongChannel     = 3
+	PmsrFtmFailurePeerNotCapable   = 4
+	PmsrFtmFailureInvalidTimestamp = 5
+	PmsrFtmFailurePeerBusy         = 6
+	PmsrFtmFailureBadChangedParams = 7
+)
+
+// enum nl80211_peer_measurement_ftm_resp
+const (
+	PmsrFtmRespAttrFailReason       = 1
+	PmsrFtmRespAttrBurstIndex       = 2
+	PmsrFtmRespAttrNumFtmrAttempts  = 3
+	PmsrFtmRespAttrNumFtmrSuccesses = 4
+	PmsrFtmRespAttrBusyRetryTime    = 5
+	PmsrFtmRespAttrNumBurstsExp     = 6
+	PmsrFtmRespAttrBurstDuration    = 7
+	PmsrFtmRespAttrFtmsPerBurst     = 8
+	PmsrFtmRespAttrRssiAvg          = 9
+	PmsrFtmRespAttrRssiSpread       = 10
+	PmsrFtmRespAttrTxRate           = 11
+	PmsrFtmRespAttrRxRate           = 12
+	PmsrFtmRespAttrRttAvg           = 13
+	PmsrFtmRespAttrRttVariance      = 14
+	PmsrFtmRespAttrRttSpread        = 15
+	PmsrFtmRespAttrDistAvg          = 16
+	PmsrFtmRespAttrDistVariance     = 17
+	PmsrFtmRespAttrDistSpread       = 18
+	PmsrFtmRespAttrLci              = 19
+	PmsrFtmRespAttrCivicloc         = 20
+	PmsrFtmRespAttrPad              = 21
+	PmsrFtmRespAttrMax              = 21
+)
+
+// enum nl80211_obss_pd_attributes
+const (
+	HeObssPdAttrMinOffset          = 1
+	HeObssPdAttrMaxOffset          = 2
+	HeObssPdAttrNonSrgMaxOffset    = 3
+	HeObssPdAttrBssColorBitmap     = 4
+	HeObssPdAttrPartialBssidBitmap = 5
+	HeObssPdAttrSrCtrl             = 6
+	HeObssPdAttrMax                = 6
+)
+
+// enum nl80211_bss_color_attributes
+const (
+	HeBssColorAttrColor    = 1
+	HeBssColorAttrDisabled = 2
+	HeBssColorAttrPartial  = 3
+	HeBssColorAttrMax      = 3
+)
+
+// enum nl80211_iftype_akm_attributes
+const (
+	IftypeAkmAttrIftypes = 1
+	IftypeAkmAttrSuites  = 2
+	IftypeAkmAttrMax     = 2
+)
+
+// enum nl80211_fils_discovery_attributes
+const (
+	FilsDiscoveryAttrIntMin = 1
+	FilsDiscoveryAttrIntMax = 2
+	FilsDiscoveryAttrTmpl   = 3
+	FilsDiscoveryAttrMax    = 3
+)
+
+// Macros.
+const (
+	FilsDiscoveryTmplMinLen = 42
+)
+
+// enum nl80211_unsol_bcast_probe_resp_attributes
+const (
+	UnsolBcastProbeRespAttrInt  = 1
+	UnsolBcastProbeRespAttrTmpl = 2
+	UnsolBcastProbeRespAttrMax  = 2
+)
+
+// enum nl80211_sae_pwe_mechanism
+const (
+	SaePweUnspecified   = 0
+	SaePweHuntAndPeck   = 1
+	SaePweHashToElement = 2
+	SaePweBoth          = 3
+)
+
+// enum nl80211_sar_type
+const (
+	SarTypePower = 0
+)
+
+// enum nl80211_sar_attrs
+const (
+	SarAttrType  = 1
+	SarAttrSpecs = 2
+	SarAttrMax   = 2
+)
+
+// enum nl80211_sar_specs_attrs
+const (
+	SarAttrSpecsPower      = 1
+	SarAttrSpecsRangeIndex = 2
+	SarAttrSpecsStartFreq  = 3
+	SarAttrSpecsEndFreq    = 4
+	SarAttrSpecsMax        = 4
+)
+
+// enum nl80211_mbssid_config_attributes
+const (
+	MbssidConfigAttrMaxInterfaces            = 1
+	MbssidConfigAttrMaxEmaProfilePeriodicity = 2
+	MbssidConfigAttrIndex                    = 3
+	MbssidConfigAttrTxIfindex                = 4
+	MbssidConfigAttrEma                      = 5
+	MbssidConfigAttrMax                      = 5
+)
+
+// enum nl80211_ap_settings_flags
+const (
+	ApSettingsExternalAuthSupport   = 1
+	ApSettingsSaQueryOffloadSupport = 2
+)
@@ -0,0 +1,24 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nl80211 provides the constants of the nl80211 generic netlink
+// family, generated from the kernel uapi header.
+//
+// To pick up new kernel features, point the generator at a newer
+// linux/nl80211.h and run go generate.
+package nl80211
+
+//go:generate go run ./gen -header /usr/include/linux/nl80211.h -o const.go
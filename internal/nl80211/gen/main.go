@@ -0,0 +1,338 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command gen produces Go constants for the nl80211 generic netlink family
+// from the kernel uapi header linux/nl80211.h.
+//
+// Every NL80211_* enumerator and #define in the header becomes an untyped
+// constant, named like the ones in github.com/xlab/nl80211 so callers can
+// switch packages without renaming: the NL80211_ prefix is dropped, CMD_
+// becomes Command and the rest is CamelCased (NL80211_ATTR_WIPHY_FREQ is
+// AttrWiphyFreq).
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// entry is a constant found in the header. Enumerators without an explicit
+// value take the value of the previous enumerator plus one.
+type entry struct {
+	name  string
+	expr  string
+	str   bool
+	prev  *entry
+	group string
+}
+
+var (
+	blockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	lineComment  = regexp.MustCompile(`//[^\n]*`)
+	enumStart    = regexp.MustCompile(`^enum\s+(\w+)\s*\{`)
+	define       = regexp.MustCompile(`^#define\s+(NL80211_\w+)\s+(.+)$`)
+	unsigned     = regexp.MustCompile(`\b(0[xX][0-9a-fA-F]+|[0-9]+)[uUlL]+\b`)
+)
+
+// parseHeader returns the constants declared in src, in header order.
+func parseHeader(src string) []*entry {
+	src = blockComment.ReplaceAllString(src, " ")
+	src = lineComment.ReplaceAllString(src, "")
+
+	var entries []*entry
+	var group string
+	var body strings.Builder
+	inEnum := false
+
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if m := enumStart.FindStringSubmatch(line); m != nil {
+			inEnum = true
+			group = m[1]
+			body.Reset()
+			line = line[len(m[0]):]
+		}
+
+		if inEnum {
+			// Enumerator values may span several lines, so the body is
+			// split on commas only once the closing brace is found.
+			end := strings.Index(line, "}")
+			if end < 0 {
+				body.WriteString(line + " ")
+				continue
+			}
+			body.WriteString(line[:end])
+			entries = append(entries, parseEnum(group, body.String())...)
+			inEnum = false
+			continue
+		}
+
+		if m := define.FindStringSubmatch(line); m != nil {
+			value := strings.TrimSpace(m[2])
+			if value == m[1] {
+				// Self-referencing defines only mark an enumerator as
+				// present for #ifdef checks.
+				continue
+			}
+			entries = append(entries, &entry{
+				name:  m[1],
+				expr:  value,
+				str:   strings.HasPrefix(value, `"`),
+				group: "defines",
+			})
+		}
+	}
+
+	return entries
+}
+
+// parseEnum returns the enumerators declared in body.
+func parseEnum(group, body string) []*entry {
+	var entries []*entry
+	var prev *entry
+	for _, part := range strings.Split(body, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		e := &entry{name: part, group: group}
+		if i := strings.Index(part, "="); i >= 0 {
+			e.name = strings.TrimSpace(part[:i])
+			e.expr = strings.TrimSpace(part[i+1:])
+		} else {
+			e.prev = prev
+		}
+		entries = append(entries, e)
+		prev = e
+	}
+	return entries
+}
+
+// resolver evaluates entry values, following references to other entries.
+type resolver struct {
+	entries map[string]*entry
+	values  map[*entry]constant.Value
+	busy    map[*entry]bool
+}
+
+func newResolver(entries []*entry) *resolver {
+	r := &resolver{
+		entries: make(map[string]*entry),
+		values:  make(map[*entry]constant.Value),
+		busy:    make(map[*entry]bool),
+	}
+	for _, e := range entries {
+		if _, ok := r.entries[e.name]; !ok {
+			r.entries[e.name] = e
+		}
+	}
+	return r
+}
+
+func (r *resolver) value(e *entry) (constant.Value, error) {
+	if v, ok := r.values[e]; ok {
+		return v, nil
+	}
+	if r.busy[e] {
+		return nil, fmt.Errorf("%s: circular definition", e.name)
+	}
+	r.busy[e] = true
+	defer delete(r.busy, e)
+
+	var v constant.Value
+	switch {
+	case e.str:
+		v = constant.MakeFromLiteral(e.expr, token.STRING, 0)
+	case e.expr == "" && e.prev == nil:
+		v = constant.MakeInt64(0)
+	case e.expr == "":
+		p, err := r.value(e.prev)
+		if err != nil {
+			return nil, err
+		}
+		v = constant.BinaryOp(p, token.ADD, constant.MakeInt64(1))
+	default:
+		x, err := parser.ParseExpr(unsigned.ReplaceAllString(e.expr, "$1"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.name, err)
+		}
+		if v, err = r.eval(x); err != nil {
+			return nil, fmt.Errorf("%s: %v", e.name, err)
+		}
+	}
+	if v.Kind() == constant.Unknown {
+		return nil, fmt.Errorf("%s: cannot evaluate %q", e.name, e.expr)
+	}
+
+	r.values[e] = v
+	return v, nil
+}
+
+func (r *resolver) eval(x ast.Expr) (constant.Value, error) {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if x.Kind != token.INT {
+			return nil, fmt.Errorf("unsupported literal %s", x.Value)
+		}
+		return constant.MakeFromLiteral(x.Value, token.INT, 0), nil
+	case *ast.Ident:
+		e, ok := r.entries[x.Name]
+		if !ok {
+			return nil, fmt.Errorf("undefined: %s", x.Name)
+		}
+		return r.value(e)
+	case *ast.ParenExpr:
+		return r.eval(x.X)
+	case *ast.UnaryExpr:
+		v, err := r.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		return constant.UnaryOp(x.Op, v, 0), nil
+	case *ast.BinaryExpr:
+		a, err := r.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.eval(x.Y)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Op {
+		case token.SHL, token.SHR:
+			s, ok := constant.Uint64Val(b)
+			if !ok {
+				return nil, errors.New("invalid shift count")
+			}
+			return constant.Shift(a, x.Op, uint(s)), nil
+		case token.QUO:
+			// C division on integers truncates.
+			return constant.BinaryOp(a, token.QUO_ASSIGN, b), nil
+		}
+		return constant.BinaryOp(a, x.Op, b), nil
+	}
+	return nil, fmt.Errorf("unsupported expression %T", x)
+}
+
+// goName converts a header name to its Go constant name.
+func goName(name string) string {
+	name = strings.TrimPrefix(name, "NL80211_")
+	prefix := ""
+	if strings.HasPrefix(name, "CMD_") {
+		prefix = "Command"
+		name = strings.TrimPrefix(name, "CMD_")
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		word = strings.ToLower(word)
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// generate renders the Go source for the constants declared in header.
+func generate(header, source string) ([]byte, error) {
+	entries := parseHeader(header)
+	r := newResolver(entries)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen from %s; DO NOT EDIT.\n\n", source)
+	buf.WriteString("package nl80211\n")
+
+	seen := make(map[string]bool)
+	group := ""
+	for _, e := range entries {
+		if !strings.HasPrefix(e.name, "NL80211_") {
+			continue
+		}
+		name := goName(e.name)
+		if seen[name] || !token.IsIdentifier(name) {
+			continue
+		}
+
+		v, err := r.value(e)
+		if err != nil {
+			if e.group == "defines" {
+				// Macros that are not plain constants (e.g. sizes built
+				// from struct layouts) have no Go equivalent.
+				continue
+			}
+			return nil, err
+		}
+		seen[name] = true
+
+		if e.group != group {
+			if group != "" {
+				buf.WriteString(")\n")
+			}
+			group = e.group
+			if group == "defines" {
+				buf.WriteString("\n// Macros.\nconst (\n")
+			} else {
+				fmt.Fprintf(&buf, "\n// enum %s\nconst (\n", group)
+			}
+		}
+		fmt.Fprintf(&buf, "\t%s = %s\n", name, v.ExactString())
+	}
+	if group != "" {
+		buf.WriteString(")\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	header := flag.String("header", "/usr/include/linux/nl80211.h", "path to linux/nl80211.h")
+	output := flag.String("o", "const.go", "output file")
+	flag.Parse()
+
+	src, err := ioutil.ReadFile(*header)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err := generate(string(src), "linux/nl80211.h")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := ioutil.WriteFile(*output, out, 0644); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
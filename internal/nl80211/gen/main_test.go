@@ -0,0 +1,136 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testHeader = `
+#define NL80211_GENL_NAME "nl80211"
+#define NL80211_MAX_SUPP_RATES			32
+#define NL80211_MAX_NR_CIPHER_SUITES	5 /* comment */
+
+/**
+ * enum nl80211_commands - supported nl80211 commands
+ */
+enum nl80211_commands {
+	NL80211_CMD_UNSPEC,
+
+	NL80211_CMD_GET_WIPHY,		/* can dump */
+	NL80211_CMD_SET_WIPHY,
+
+	NL80211_CMD_GET_INTERFACE = 5,
+	NL80211_CMD_SET_INTERFACE,
+
+	/* add new commands above here */
+
+	/* used to define NL80211_CMD_MAX below */
+	__NL80211_CMD_AFTER_LAST,
+	NL80211_CMD_MAX = __NL80211_CMD_AFTER_LAST - 1
+};
+
+#define NL80211_CMD_SET_INTERFACE NL80211_CMD_SET_INTERFACE
+#define NL80211_CMD_GET_IFACE NL80211_CMD_GET_INTERFACE
+
+enum nl80211_rate_info {
+	__NL80211_RATE_INFO_INVALID,
+	NL80211_RATE_INFO_BITRATE,
+
+	__NL80211_RATE_INFO_AFTER_LAST,
+	NL80211_RATE_INFO_MAX =
+		__NL80211_RATE_INFO_AFTER_LAST - 1
+};
+
+enum nl80211_feature_flags {
+	NL80211_FEATURE_SK_TX_STATUS		= 1 << 0,
+	NL80211_FEATURE_HT_IBSS			= 1 << 1,
+	NL80211_FEATURE_ND_RANDOM_MAC_ADDR	= 1U << 31,
+};
+`
+
+func TestGenerate(t *testing.T) {
+	out, err := generate(testHeader, "test.h")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"GenlName", `"nl80211"`},
+		{"MaxSuppRates", "32"},
+		{"MaxNrCipherSuites", "5"},
+		{"CommandUnspec", "0"},
+		{"CommandSetWiphy", "2"},
+		{"CommandGetInterface", "5"},
+		{"CommandSetInterface", "6"},
+		{"CommandMax", "6"},
+		{"CommandGetIface", "5"},
+		{"RateInfoBitrate", "1"},
+		{"RateInfoMax", "1"},
+		{"FeatureHtIbss", "2"},
+		{"FeatureNdRandomMacAddr", "2147483648"},
+	}
+
+	got := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[1] == "=" {
+			if _, ok := got[fields[0]]; ok {
+				t.Fatalf("%s declared twice", fields[0])
+			}
+			got[fields[0]] = fields[2]
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got[tt.name] != tt.want {
+				t.Fatalf("%s:\n- want: %v\n-  got: %v", tt.name, tt.want, got[tt.name])
+			}
+		})
+	}
+
+	if strings.Contains(string(out), "AfterLast") {
+		t.Fatalf("internal enumerators were exported:\n%s", out)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"NL80211_ATTR_WIPHY_FREQ", "AttrWiphyFreq"},
+		{"NL80211_ATTR_4ADDR", "Attr4addr"},
+		{"NL80211_CMD_TRIGGER_SCAN", "CommandTriggerScan"},
+		{"NL80211_CHAN_WIDTH_20_NOHT", "ChanWidth20Noht"},
+		{"NL80211_CHAN_WIDTH_320", "ChanWidth320"},
+		{"NL80211_BAND_6GHZ", "Band6ghz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := goName(tt.input); got != tt.want {
+				t.Fatalf("goName(%v):\n- want: %v\n-  got: %v", tt.input, tt.want, got)
+			}
+		})
+	}
+}
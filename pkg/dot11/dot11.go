@@ -0,0 +1,192 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dot11 is a lightweight parser for radiotap and 802.11 headers.
+//
+// It only decodes what chopper needs to be aware of the traffic on a
+// channel: frame types and addresses, beacon bodies and their information
+// elements, and the EAPOL-Key frames of WPA handshakes.
+package dot11
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// Frame types.
+const (
+	TypeManagement = 0
+	TypeControl    = 1
+	TypeData       = 2
+)
+
+// Management frame subtypes.
+const (
+	SubtypeAssociationRequest   = 0
+	SubtypeAssociationResponse  = 1
+	SubtypeReassociationRequest = 2
+	SubtypeProbeRequest         = 4
+	SubtypeProbeResponse        = 5
+	SubtypeBeacon               = 8
+	SubtypeDisassociation       = 10
+	SubtypeAuthentication       = 11
+	SubtypeDeauthentication     = 12
+	SubtypeAction               = 13
+)
+
+// Data frame subtypes.
+const (
+	SubtypeData    = 0
+	SubtypeNull    = 4
+	SubtypeQoSData = 8
+)
+
+var (
+	ErrShortFrame      = errors.New("dot11: frame too short")
+	ErrRadiotapVersion = errors.New("dot11: unsupported radiotap version")
+)
+
+// Frame is a decoded 802.11 MAC header.
+type Frame struct {
+	Type      uint8
+	Subtype   uint8
+	ToDS      bool
+	FromDS    bool
+	Protected bool
+
+	Addr1 net.HardwareAddr
+	Addr2 net.HardwareAddr
+	Addr3 net.HardwareAddr
+	Addr4 net.HardwareAddr
+
+	// Body is the frame payload, without header and FCS.
+	Body []byte
+}
+
+// Packet is a frame received on a monitor interface.
+type Packet struct {
+	Radiotap Radiotap
+	Frame    Frame
+}
+
+// Decode parses a radiotap header followed by an 802.11 frame, as delivered
+// by monitor interfaces. The FCS is stripped if present.
+func Decode(b []byte) (Packet, error) {
+	var p Packet
+
+	rt, err := ParseRadiotap(b)
+	if err != nil {
+		return p, err
+	}
+	p.Radiotap = rt
+
+	b = b[rt.Length:]
+	if rt.Flags&FlagFCS != 0 {
+		if len(b) < 4 {
+			return p, ErrShortFrame
+		}
+		b = b[:len(b)-4]
+	}
+
+	p.Frame, err = Parse(b)
+	return p, err
+}
+
+// Parse decodes a raw 802.11 frame without FCS.
+func Parse(b []byte) (Frame, error) {
+	var f Frame
+
+	if len(b) < 10 {
+		return f, ErrShortFrame
+	}
+	fc := binary.LittleEndian.Uint16(b[0:2])
+	f.Type = uint8(fc>>2) & 0x3
+	f.Subtype = uint8(fc>>4) & 0xf
+	f.ToDS = fc&0x0100 != 0
+	f.FromDS = fc&0x0200 != 0
+	f.Protected = fc&0x4000 != 0
+	f.Addr1 = net.HardwareAddr(b[4:10])
+
+	// Control frames have a variable format, the first address is enough
+	if f.Type == TypeControl {
+		if len(b) >= 16 {
+			f.Addr2 = net.HardwareAddr(b[10:16])
+		}
+		return f, nil
+	}
+
+	length := 24
+	if f.Type == TypeData && f.ToDS && f.FromDS {
+		length += 6
+	}
+	if f.Type == TypeData && f.Subtype&SubtypeQoSData != 0 {
+		length += 2
+	}
+	if len(b) < length {
+		return f, ErrShortFrame
+	}
+
+	f.Addr2 = net.HardwareAddr(b[10:16])
+	f.Addr3 = net.HardwareAddr(b[16:22])
+	if f.Type == TypeData && f.ToDS && f.FromDS {
+		f.Addr4 = net.HardwareAddr(b[24:30])
+	}
+	f.Body = b[length:]
+
+	return f, nil
+}
+
+// BSSID returns the BSSID of a management or data frame, or nil if it
+// cannot be determined.
+func (f Frame) BSSID() net.HardwareAddr {
+	switch {
+	case f.Type == TypeManagement:
+		return f.Addr3
+	case f.Type != TypeData:
+		return nil
+	case f.ToDS && !f.FromDS:
+		return f.Addr1
+	case f.FromDS && !f.ToDS:
+		return f.Addr2
+	case !f.ToDS && !f.FromDS:
+		return f.Addr3
+	}
+
+	return nil
+}
+
+// Station returns the address of the non-AP station of a data frame
+// exchanged with an AP, or nil.
+func (f Frame) Station() net.HardwareAddr {
+	if f.Type != TypeData {
+		return nil
+	}
+
+	switch {
+	case f.ToDS && !f.FromDS:
+		return f.Addr2
+	case f.FromDS && !f.ToDS:
+		return f.Addr1
+	}
+
+	return nil
+}
+
+// IsGroup reports whether addr is a group (multicast or broadcast) address.
+func IsGroup(addr net.HardwareAddr) bool {
+	return len(addr) > 0 && addr[0]&0x1 != 0
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	testBSSID   = []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testStation = []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+)
+
+func testHeader(fc0 byte, fc1 byte, addr1 []byte, addr2 []byte, addr3 []byte) []byte {
+	b := []byte{fc0, fc1, 0, 0}
+	b = append(b, addr1...)
+	b = append(b, addr2...)
+	b = append(b, addr3...)
+	return append(b, 0, 0)
+}
+
+func TestParse(t *testing.T) {
+	broadcast := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+	tests := []struct {
+		name    string
+		input   []byte
+		typ     uint8
+		subtype uint8
+		bssid   []byte
+		station []byte
+		body    []byte
+	}{
+		{
+			name:    "beacon",
+			input:   append(testHeader(0x80, 0x00, broadcast, testBSSID, testBSSID), 1, 2, 3),
+			typ:     TypeManagement,
+			subtype: SubtypeBeacon,
+			bssid:   testBSSID,
+			body:    []byte{1, 2, 3},
+		},
+		{
+			name:    "data_to_ds",
+			input:   testHeader(0x08, 0x01, testBSSID, testStation, broadcast),
+			typ:     TypeData,
+			subtype: SubtypeData,
+			bssid:   testBSSID,
+			station: testStation,
+			body:    []byte{},
+		},
+		{
+			name:    "qos_data_from_ds",
+			input:   append(testHeader(0x88, 0x02, testStation, testBSSID, testBSSID), 0, 0, 0xaa),
+			typ:     TypeData,
+			subtype: SubtypeQoSData,
+			bssid:   testBSSID,
+			station: testStation,
+			body:    []byte{0xaa},
+		},
+		{
+			name:    "control_ack",
+			input:   []byte{0xd4, 0x00, 0, 0, 0x02, 0, 0, 0, 0, 1},
+			typ:     TypeControl,
+			subtype: 13,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if f.Type != tt.typ || f.Subtype != tt.subtype {
+				t.Fatalf("Parse(%v) type:\n- want: %v/%v\n-  got: %v/%v", tt.input, tt.typ, tt.subtype, f.Type, f.Subtype)
+			}
+			if want, got := tt.bssid, f.BSSID(); !bytes.Equal(want, got) {
+				t.Fatalf("Parse(%v) bssid:\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+			if want, got := tt.station, f.Station(); !bytes.Equal(want, got) {
+				t.Fatalf("Parse(%v) station:\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+			if want, got := tt.body, f.Body; !bytes.Equal(want, got) {
+				t.Fatalf("Parse(%v) body:\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	// Flags with FCS, then a 4 bytes FCS after the frame
+	rt := []byte{0, 0, 9, 0, 0x02, 0, 0, 0, FlagFCS}
+	b := append(rt, testHeader(0x80, 0x00, testBSSID, testBSSID, testBSSID)...)
+	b = append(b, 0xde, 0xad, 0xbe, 0xef)
+
+	p, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, len(p.Frame.Body); want != got {
+		t.Fatalf("Decode body length:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestParseShort(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name:  "empty",
+			input: nil,
+		},
+		{
+			name:  "truncated_header",
+			input: []byte{0x80, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7},
+		},
+		{
+			name:  "truncated_four_address",
+			input: testHeader(0x08, 0x03, testBSSID, testBSSID, testBSSID),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.input); err != ErrShortFrame {
+				t.Fatalf("Parse(%v):\n- want: %v\n-  got: %v", tt.input, ErrShortFrame, err)
+			}
+		})
+	}
+}
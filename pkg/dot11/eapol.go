@@ -0,0 +1,119 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNotEAPOLKey is returned for data frames that do not carry an EAPOL-Key
+// frame.
+var ErrNotEAPOLKey = errors.New("dot11: not an EAPOL-Key frame")
+
+// llcEAPOL is the LLC/SNAP header of EAPOL frames.
+var llcEAPOL = []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x88, 0x8e}
+
+const eapolTypeKey = 3
+
+// Key information bits.
+const (
+	keyInfoPairwise = 0x0008
+	keyInfoInstall  = 0x0040
+	keyInfoAck      = 0x0080
+	keyInfoMIC      = 0x0100
+	keyInfoSecure   = 0x0200
+)
+
+// EAPOLKey is an EAPOL-Key frame of a 4-way handshake.
+type EAPOLKey struct {
+	KeyInfo       uint16
+	ReplayCounter uint64
+	Nonce         []byte
+	MIC           []byte
+	KeyData       []byte
+}
+
+// ParseEAPOLKey decodes the EAPOL-Key frame in the body of an unprotected
+// data frame.
+func ParseEAPOLKey(body []byte) (EAPOLKey, error) {
+	var k EAPOLKey
+
+	if len(body) < len(llcEAPOL)+4 || !bytes.Equal(body[:len(llcEAPOL)], llcEAPOL) || body[len(llcEAPOL)+1] != eapolTypeKey {
+		return k, ErrNotEAPOLKey
+	}
+	b := body[len(llcEAPOL)+4:]
+
+	// Descriptor type, key information, key length, replay counter, nonce,
+	// IV, RSC, reserved, MIC and key data length
+	if len(b) < 1+2+2+8+32+16+8+8+16+2 {
+		return k, ErrShortFrame
+	}
+	k.KeyInfo = binary.BigEndian.Uint16(b[1:3])
+	k.ReplayCounter = binary.BigEndian.Uint64(b[5:13])
+	k.Nonce = b[13:45]
+	k.MIC = b[77:93]
+	length := int(binary.BigEndian.Uint16(b[93:95]))
+	if len(b) < 95+length {
+		return k, ErrShortFrame
+	}
+	k.KeyData = b[95 : 95+length]
+
+	return k, nil
+}
+
+// Message returns the number of the message in the 4-way handshake, from 1
+// to 4, or 0 for group key handshakes.
+func (k EAPOLKey) Message() int {
+	if k.KeyInfo&keyInfoPairwise == 0 {
+		return 0
+	}
+
+	ack, mic := k.KeyInfo&keyInfoAck != 0, k.KeyInfo&keyInfoMIC != 0
+	switch {
+	case ack && !mic:
+		return 1
+	case ack && k.KeyInfo&keyInfoInstall != 0:
+		return 3
+	case ack, !mic:
+		return 0
+	case k.KeyInfo&keyInfoSecure == 0 || len(k.KeyData) > 0:
+		// Some stations set the secure bit in message 2, but only message
+		// 2 carries the RSN element
+		return 2
+	}
+	return 4
+}
+
+// pmkidKDE is the OUI and data type of the PMKID key data encapsulation.
+var pmkidKDE = []byte{0x00, 0x0f, 0xac, 0x04}
+
+// PMKID returns the PMKID in the key data of message 1, if the AP sent one.
+func (k EAPOLKey) PMKID() ([]byte, bool) {
+	// Key data is a list of elements, KDEs are vendor specific elements
+	for _, e := range Elements(k.KeyData) {
+		if e.ID == ElementVendorSpecific && len(e.Data) == len(pmkidKDE)+16 && bytes.Equal(e.Data[:len(pmkidKDE)], pmkidKDE) {
+			pmkid := e.Data[len(pmkidKDE):]
+			// Some APs send an empty PMKID
+			if !bytes.Equal(pmkid, make([]byte, 16)) {
+				return pmkid, true
+			}
+		}
+	}
+	return nil, false
+}
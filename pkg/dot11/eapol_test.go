@@ -0,0 +1,127 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testEAPOLKey returns the body of a data frame carrying an EAPOL-Key frame.
+func testEAPOLKey(keyInfo uint16, replay uint64, keyData []byte) []byte {
+	key := make([]byte, 95)
+	key[0] = 2 // RSN descriptor
+	binary.BigEndian.PutUint16(key[1:], keyInfo)
+	binary.BigEndian.PutUint16(key[3:], 16)
+	binary.BigEndian.PutUint64(key[5:], replay)
+	key[13] = 0xaa // nonce
+	binary.BigEndian.PutUint16(key[93:], uint16(len(keyData)))
+	key = append(key, keyData...)
+
+	b := append([]byte{}, llcEAPOL...)
+	b = append(b, 2, eapolTypeKey, 0, 0)
+	binary.BigEndian.PutUint16(b[len(b)-2:], uint16(len(key)))
+	return append(b, key...)
+}
+
+func TestParseEAPOLKey(t *testing.T) {
+	rsn := []byte{ElementRSN, 2, 1, 0}
+
+	tests := []struct {
+		name    string
+		keyInfo uint16
+		keyData []byte
+		message int
+	}{
+		{"message_1", 0x008a, nil, 1},
+		{"message_2", 0x010a, rsn, 2},
+		{"message_3", 0x13ca, make([]byte, 56), 3},
+		{"message_4", 0x030a, nil, 4},
+		{"message_2_secure", 0x030a, rsn, 2},
+		{"group", 0x0382, make([]byte, 40), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseEAPOLKey(testEAPOLKey(tt.keyInfo, 7, tt.keyData))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k.KeyInfo != tt.keyInfo || k.ReplayCounter != 7 || k.Nonce[0] != 0xaa || len(k.KeyData) != len(tt.keyData) {
+				t.Fatalf("ParseEAPOLKey: %+v", k)
+			}
+			if got := k.Message(); got != tt.message {
+				t.Fatalf("Message():\n- want: %v\n-  got: %v", tt.message, got)
+			}
+		})
+	}
+}
+
+func TestParseEAPOLKeyInvalid(t *testing.T) {
+	key := testEAPOLKey(0x008a, 1, []byte{1, 2, 3})
+
+	tests := []struct {
+		name  string
+		input []byte
+		err   error
+	}{
+		{"empty", nil, ErrNotEAPOLKey},
+		{"ip", append([]byte{0xaa, 0xaa, 0x03, 0, 0, 0, 0x08, 0x00}, key[8:]...), ErrNotEAPOLKey},
+		{"eap_packet", append(append(append([]byte{}, key[:9]...), 0), key[10:]...), ErrNotEAPOLKey},
+		{"truncated_key", key[:50], ErrShortFrame},
+		{"truncated_key_data", key[:len(key)-1], ErrShortFrame},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseEAPOLKey(tt.input); err != tt.err {
+				t.Fatalf("ParseEAPOLKey:\n- want: %v\n-  got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestEAPOLKeyPMKID(t *testing.T) {
+	pmkid := bytes.Repeat([]byte{0xab}, 16)
+	kde := append([]byte{ElementVendorSpecific, 20, 0x00, 0x0f, 0xac, 0x04}, pmkid...)
+
+	tests := []struct {
+		name    string
+		keyData []byte
+		pmkid   []byte
+	}{
+		{"pmkid", kde, pmkid},
+		{"after_rsn", append([]byte{ElementRSN, 2, 1, 0}, kde...), pmkid},
+		{"zero", append(append([]byte{}, kde[:6]...), make([]byte, 16)...), nil},
+		{"other_kde", append([]byte{ElementVendorSpecific, 20, 0x00, 0x0f, 0xac, 0x01}, pmkid...), nil},
+		{"none", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseEAPOLKey(testEAPOLKey(0x008a, 1, tt.keyData))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := k.PMKID()
+			if !bytes.Equal(got, tt.pmkid) || ok != (tt.pmkid != nil) {
+				t.Fatalf("PMKID():\n- want: %x\n-  got: %x, %v", tt.pmkid, got, ok)
+			}
+		})
+	}
+}
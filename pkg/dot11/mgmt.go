@@ -0,0 +1,275 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Information element IDs.
+const (
+	ElementSSID           = 0
+	ElementSupportedRates = 1
+	ElementDSParameterSet = 3
+	ElementTIM            = 5
+	ElementCountry        = 7
+	ElementBSSLoad        = 11
+	ElementHTCapabilities = 45
+	ElementRSN            = 48
+	ElementHTOperation    = 61
+	ElementVendorSpecific = 221
+)
+
+// Element is a single information element.
+type Element struct {
+	ID   uint8
+	Data []byte
+}
+
+// Elements splits b into information elements. A truncated element ends the
+// list.
+func Elements(b []byte) []Element {
+	ret := make([]Element, 0, 16)
+	for len(b) >= 2 {
+		id, length := b[0], int(b[1])
+		if len(b) < 2+length {
+			break
+		}
+
+		ret = append(ret, Element{ID: id, Data: b[2 : 2+length]})
+		b = b[2+length:]
+	}
+
+	return ret
+}
+
+// FindElement returns the data of the first element with the given ID.
+func FindElement(elements []Element, id uint8) ([]byte, bool) {
+	for _, e := range elements {
+		if e.ID == id {
+			return e.Data, true
+		}
+	}
+
+	return nil, false
+}
+
+// SSID returns the SSID found in elements, if any.
+func SSID(elements []Element) string {
+	data, _ := FindElement(elements, ElementSSID)
+	return string(data)
+}
+
+// Beacon is the body of a beacon or probe response frame.
+type Beacon struct {
+	Timestamp  uint64
+	Interval   uint16
+	Capability uint16
+	Elements   []Element
+}
+
+// ParseBeacon decodes the body of a beacon or probe response.
+func ParseBeacon(body []byte) (Beacon, error) {
+	var b Beacon
+
+	if len(body) < 12 {
+		return b, ErrShortFrame
+	}
+	b.Timestamp = binary.LittleEndian.Uint64(body[0:8])
+	b.Interval = binary.LittleEndian.Uint16(body[8:10])
+	b.Capability = binary.LittleEndian.Uint16(body[10:12])
+	b.Elements = Elements(body[12:])
+
+	return b, nil
+}
+
+// SSID returns the advertised SSID.
+func (b Beacon) SSID() string {
+	return SSID(b.Elements)
+}
+
+// Channel returns the channel in the DS Parameter Set, or 0 if missing.
+func (b Beacon) Channel() int {
+	data, ok := FindElement(b.Elements, ElementDSParameterSet)
+	if !ok || len(data) != 1 {
+		return 0
+	}
+
+	return int(data[0])
+}
+
+// BSSLoad is the BSS Load element (QBSS Load) of a QoS access point.
+type BSSLoad struct {
+	// Stations is the number of stations associated to the BSS.
+	Stations int
+	// Utilization is the fraction of the time the access point sensed the
+	// medium busy, from 0 to 1.
+	Utilization float64
+	// AdmissionCapacity is the medium time still available for admission
+	// control, in units of 32 µs per second.
+	AdmissionCapacity int
+}
+
+// BSSLoad returns the BSS Load element, if the beacon has a valid one.
+func (b Beacon) BSSLoad() (BSSLoad, bool) {
+	data, ok := FindElement(b.Elements, ElementBSSLoad)
+	if !ok || len(data) != 5 {
+		return BSSLoad{}, false
+	}
+
+	return BSSLoad{
+		Stations:          int(binary.LittleEndian.Uint16(data[0:2])),
+		Utilization:       float64(data[2]) / 255,
+		AdmissionCapacity: int(binary.LittleEndian.Uint16(data[3:5])),
+	}, true
+}
+
+// Privacy reports whether the BSS requires encryption.
+func (b Beacon) Privacy() bool {
+	return b.Capability&0x0010 != 0
+}
+
+// ParseAssociationRequest returns the elements of the body of an
+// association request, or of a reassociation request if reassociation is
+// set.
+func ParseAssociationRequest(body []byte, reassociation bool) ([]Element, error) {
+	// Capability and listen interval, then the current AP address
+	length := 4
+	if reassociation {
+		length += 6
+	}
+	if len(body) < length {
+		return nil, ErrShortFrame
+	}
+	return Elements(body[length:]), nil
+}
+
+// rsnElement is the decoded part of an RSN element.
+type rsnElement struct {
+	akms   []uint32
+	pmkids [][]byte
+}
+
+// parseRSN decodes the AKM suites and PMKIDs of an RSN element, stopping
+// at the first truncated field.
+func parseRSN(b []byte) rsnElement {
+	var rsn rsnElement
+
+	// Version and group cipher suite
+	if len(b) < 6 {
+		return rsn
+	}
+	b = b[6:]
+
+	// Pairwise cipher and AKM suite lists
+	for i := 0; i < 2; i++ {
+		if len(b) < 2 {
+			return rsn
+		}
+		count := int(binary.LittleEndian.Uint16(b))
+		if len(b) < 2+4*count {
+			return rsn
+		}
+		if i == 1 {
+			for j := 0; j < count; j++ {
+				rsn.akms = append(rsn.akms, binary.BigEndian.Uint32(b[2+4*j:]))
+			}
+		}
+		b = b[2+4*count:]
+	}
+
+	// RSN capabilities, then the PMKID list
+	if len(b) < 4 {
+		return rsn
+	}
+	count := int(binary.LittleEndian.Uint16(b[2:]))
+	b = b[4:]
+	for i := 0; i < count && len(b) >= 16; i++ {
+		rsn.pmkids = append(rsn.pmkids, b[:16])
+		b = b[16:]
+	}
+	return rsn
+}
+
+// RSNPMKIDs returns the PMKIDs listed in an RSN element.
+func RSNPMKIDs(rsn []byte) [][]byte {
+	return parseRSN(rsn).pmkids
+}
+
+// AKM suites, with the 00-0f-ac OUI.
+const (
+	akm8021X       = 0x000fac01
+	akmPSK         = 0x000fac02
+	akmFT8021X     = 0x000fac03
+	akmFTPSK       = 0x000fac04
+	akm8021XSHA256 = 0x000fac05
+	akmPSKSHA256   = 0x000fac06
+	akmSAE         = 0x000fac08
+	akmFTSAE       = 0x000fac09
+	akmSuiteB      = 0x000fac0b
+	akmSuiteB192   = 0x000fac0c
+	akmOWE         = 0x000fac12
+)
+
+// wpaOUI is the OUI and type of the WPA vendor specific element.
+var wpaOUI = []byte{0x00, 0x50, 0xf2, 0x01}
+
+// Security returns the security advertised by the BSS: Open, WEP, WPA,
+// WPA2, WPA3, WPA2/WPA3, OWE, or WPA2-EAP and WPA3-EAP for enterprise
+// networks.
+func (b Beacon) Security() string {
+	if data, ok := FindElement(b.Elements, ElementRSN); ok {
+		var psk, sae, eap, suiteB, owe bool
+		for _, akm := range parseRSN(data).akms {
+			switch akm {
+			case akmPSK, akmFTPSK, akmPSKSHA256:
+				psk = true
+			case akmSAE, akmFTSAE:
+				sae = true
+			case akm8021X, akmFT8021X, akm8021XSHA256:
+				eap = true
+			case akmSuiteB, akmSuiteB192:
+				suiteB = true
+			case akmOWE:
+				owe = true
+			}
+		}
+		switch {
+		case psk && sae:
+			return "WPA2/WPA3"
+		case sae:
+			return "WPA3"
+		case suiteB:
+			return "WPA3-EAP"
+		case eap:
+			return "WPA2-EAP"
+		case owe:
+			return "OWE"
+		}
+		return "WPA2"
+	}
+	for _, e := range b.Elements {
+		if e.ID == ElementVendorSpecific && len(e.Data) >= len(wpaOUI) && bytes.Equal(e.Data[:len(wpaOUI)], wpaOUI) {
+			return "WPA"
+		}
+	}
+	if b.Privacy() {
+		return "WEP"
+	}
+	return "Open"
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSSID(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		output string
+	}{
+		{
+			name:   "ssid_first",
+			input:  []byte{0, 4, 't', 'e', 's', 't', 1, 1, 0x82},
+			output: "test",
+		},
+		{
+			name:   "ssid_after_rates",
+			input:  []byte{1, 1, 0x82, 0, 3, 'a', 'b', 'c'},
+			output: "abc",
+		},
+		{
+			name:   "hidden",
+			input:  []byte{0, 0},
+			output: "",
+		},
+		{
+			name:   "truncated",
+			input:  []byte{0, 10, 'a'},
+			output: "",
+		},
+		{
+			name:   "empty",
+			input:  nil,
+			output: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := tt.output, SSID(Elements(tt.input)); want != got {
+				t.Fatalf("SSID(%v):\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+		})
+	}
+}
+
+func TestParseBeacon(t *testing.T) {
+	body := []byte{
+		1, 0, 0, 0, 0, 0, 0, 0, 0x64, 0x00, 0x11, 0x04,
+		0, 4, 't', 'e', 's', 't',
+		ElementDSParameterSet, 1, 11,
+	}
+
+	b, err := ParseBeacon(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := uint16(100), b.Interval; want != got {
+		t.Fatalf("ParseBeacon interval:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := "test", b.SSID(); want != got {
+		t.Fatalf("ParseBeacon ssid:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 11, b.Channel(); want != got {
+		t.Fatalf("ParseBeacon channel:\n- want: %v\n-  got: %v", want, got)
+	}
+	if !b.Privacy() {
+		t.Fatalf("ParseBeacon privacy:\n- want: true\n-  got: false")
+	}
+
+	if _, err := ParseBeacon(body[:11]); err != ErrShortFrame {
+		t.Fatalf("ParseBeacon short:\n- want: %v\n-  got: %v", ErrShortFrame, err)
+	}
+}
+
+func TestBeaconBSSLoad(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		output BSSLoad
+		ok     bool
+	}{
+		{
+			name:   "load",
+			input:  []byte{ElementBSSLoad, 5, 0x0c, 0x01, 51, 0x10, 0x27},
+			output: BSSLoad{Stations: 268, Utilization: 0.2, AdmissionCapacity: 10000},
+			ok:     true,
+		},
+		{
+			name:  "truncated",
+			input: []byte{ElementBSSLoad, 4, 0x0c, 0x01, 51, 0x10},
+		},
+		{
+			name:  "missing",
+			input: []byte{ElementDSParameterSet, 1, 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Beacon{Elements: Elements(tt.input)}
+			got, ok := b.BSSLoad()
+			if ok != tt.ok || got != tt.output {
+				t.Fatalf("BSSLoad(%v):\n- want: %+v, %v\n-  got: %+v, %v", tt.input, tt.output, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestRSNPMKIDs(t *testing.T) {
+	pmkid := bytes.Repeat([]byte{0xab}, 16)
+	// Version, CCMP group cipher, one CCMP pairwise cipher, one PSK AKM
+	base := []byte{1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 2}
+
+	tests := []struct {
+		name   string
+		input  []byte
+		output [][]byte
+	}{
+		{"no_capabilities", base, nil},
+		{"no_pmkid", append(append([]byte{}, base...), 0, 0), nil},
+		{"empty_list", append(append([]byte{}, base...), 0, 0, 0, 0), nil},
+		{"pmkid", append(append(append([]byte{}, base...), 0, 0, 1, 0), pmkid...), [][]byte{pmkid}},
+		{"truncated_pmkid", append(append(append([]byte{}, base...), 0, 0, 1, 0), pmkid[:8]...), nil},
+		{"truncated_suites", base[:10], nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RSNPMKIDs(tt.input)
+			if len(got) != len(tt.output) {
+				t.Fatalf("RSNPMKIDs(%v):\n- want: %x\n-  got: %x", tt.input, tt.output, got)
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tt.output[i]) {
+					t.Fatalf("RSNPMKIDs(%v):\n- want: %x\n-  got: %x", tt.input, tt.output, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAssociationRequest(t *testing.T) {
+	elements := []byte{0, 4, 't', 'e', 's', 't'}
+	body := append([]byte{0x11, 0x04, 0x0a, 0x00}, elements...)
+
+	e, err := ParseAssociationRequest(body, false)
+	if err != nil || SSID(e) != "test" {
+		t.Fatalf("ParseAssociationRequest: %v, %v", e, err)
+	}
+	reassociation := append(append([]byte{0x11, 0x04, 0x0a, 0x00}, testBSSID...), elements...)
+	if e, err := ParseAssociationRequest(reassociation, true); err != nil || SSID(e) != "test" {
+		t.Fatalf("ParseAssociationRequest reassociation: %v, %v", e, err)
+	}
+	if _, err := ParseAssociationRequest(body[:3], false); err != ErrShortFrame {
+		t.Fatalf("ParseAssociationRequest short:\n- want: %v\n-  got: %v", ErrShortFrame, err)
+	}
+}
+
+func TestBeaconSecurity(t *testing.T) {
+	rsn := func(akms ...byte) Element {
+		b := []byte{1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 4, byte(len(akms)), 0}
+		for _, akm := range akms {
+			b = append(b, 0x00, 0x0f, 0xac, akm)
+		}
+		return Element{ID: ElementRSN, Data: append(b, 0, 0)}
+	}
+	wpa := Element{ID: ElementVendorSpecific, Data: []byte{0x00, 0x50, 0xf2, 0x01, 1, 0}}
+
+	tests := []struct {
+		name       string
+		capability uint16
+		elements   []Element
+		output     string
+	}{
+		{"open", 0x0001, nil, "Open"},
+		{"wep", 0x0011, nil, "WEP"},
+		{"wpa", 0x0011, []Element{wpa}, "WPA"},
+		{"wpa2", 0x0011, []Element{rsn(2)}, "WPA2"},
+		{"wpa2_wpa", 0x0011, []Element{wpa, rsn(2)}, "WPA2"},
+		{"wpa3", 0x0011, []Element{rsn(8)}, "WPA3"},
+		{"transition", 0x0011, []Element{rsn(2, 8)}, "WPA2/WPA3"},
+		{"enterprise", 0x0011, []Element{rsn(1)}, "WPA2-EAP"},
+		{"suite_b", 0x0011, []Element{rsn(12)}, "WPA3-EAP"},
+		{"owe", 0x0011, []Element{rsn(18)}, "OWE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Beacon{Capability: tt.capability, Elements: tt.elements}
+			if got := b.Security(); got != tt.output {
+				t.Fatalf("Security():\n- want: %v\n-  got: %v", tt.output, got)
+			}
+		})
+	}
+}
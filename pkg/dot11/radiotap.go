@@ -0,0 +1,144 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"encoding/binary"
+)
+
+// Radiotap field indexes in the present bitmap.
+// See https://www.radiotap.org/fields/defined
+const (
+	radiotapTSFT = iota
+	radiotapFlags
+	radiotapRate
+	radiotapChannel
+	radiotapFHSS
+	radiotapAntennaSignal
+	radiotapAntennaNoise
+	radiotapLockQuality
+	radiotapTxAttenuation
+	radiotapDBTxAttenuation
+	radiotapDBmTxPower
+	radiotapAntenna
+)
+
+const (
+	radiotapPresentExt = 1 << 31
+
+	// FlagFCS is set when the frame includes the FCS at the end.
+	FlagFCS = 0x10
+	// FlagBadFCS is set when the frame failed the FCS check.
+	FlagBadFCS = 0x40
+)
+
+// radiotapFields lists alignment and size of the fields we know how to skip,
+// indexed by their bit in the present bitmap.
+var radiotapFields = [...]struct {
+	align int
+	size  int
+}{
+	radiotapTSFT:            {8, 8},
+	radiotapFlags:           {1, 1},
+	radiotapRate:            {1, 1},
+	radiotapChannel:         {2, 4},
+	radiotapFHSS:            {1, 2},
+	radiotapAntennaSignal:   {1, 1},
+	radiotapAntennaNoise:    {1, 1},
+	radiotapLockQuality:     {2, 2},
+	radiotapTxAttenuation:   {2, 2},
+	radiotapDBTxAttenuation: {2, 2},
+	radiotapDBmTxPower:      {1, 1},
+	radiotapAntenna:         {1, 1},
+}
+
+// Radiotap is the subset of a radiotap header chopper uses.
+type Radiotap struct {
+	// Length is the length of the whole radiotap header.
+	Length int
+
+	Flags        uint8
+	Frequency    int
+	ChannelFlags uint16
+
+	// Signal is the antenna signal in dBm, valid if HasSignal is set.
+	Signal    int
+	HasSignal bool
+
+	Antenna int
+}
+
+// ParseRadiotap decodes the radiotap header at the start of b. Fields after
+// the antenna index are not decoded.
+func ParseRadiotap(b []byte) (Radiotap, error) {
+	var rt Radiotap
+
+	if len(b) < 8 {
+		return rt, ErrShortFrame
+	}
+	if b[0] != 0 {
+		return rt, ErrRadiotapVersion
+	}
+	rt.Length = int(binary.LittleEndian.Uint16(b[2:4]))
+	if rt.Length < 8 || len(b) < rt.Length {
+		return rt, ErrShortFrame
+	}
+	header := b[:rt.Length]
+
+	// Skip the extended present bitmaps
+	present := binary.LittleEndian.Uint32(header[4:8])
+	offset := 8
+	for word := present; word&radiotapPresentExt != 0; {
+		if len(header) < offset+4 {
+			return rt, ErrShortFrame
+		}
+		word = binary.LittleEndian.Uint32(header[offset : offset+4])
+		offset += 4
+	}
+
+	for bit := range radiotapFields {
+		if present&(1<<uint(bit)) == 0 {
+			continue
+		}
+
+		field := radiotapFields[bit]
+		if rem := offset % field.align; rem != 0 {
+			offset += field.align - rem
+		}
+		if len(header) < offset+field.size {
+			return rt, ErrShortFrame
+		}
+		data := header[offset : offset+field.size]
+
+		switch bit {
+		case radiotapFlags:
+			rt.Flags = data[0]
+		case radiotapChannel:
+			rt.Frequency = int(binary.LittleEndian.Uint16(data[0:2]))
+			rt.ChannelFlags = binary.LittleEndian.Uint16(data[2:4])
+		case radiotapAntennaSignal:
+			rt.Signal = int(int8(data[0]))
+			rt.HasSignal = true
+		case radiotapAntenna:
+			rt.Antenna = int(data[0])
+		}
+
+		offset += field.size
+	}
+
+	return rt, nil
+}
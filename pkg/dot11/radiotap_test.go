@@ -0,0 +1,94 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dot11
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRadiotap(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		output Radiotap
+		err    error
+	}{
+		{
+			name:   "no_fields",
+			input:  []byte{0, 0, 8, 0, 0, 0, 0, 0},
+			output: Radiotap{Length: 8},
+		},
+		{
+			// Flags, rate, channel (aligned to 2), signal, antenna
+			name: "common_fields",
+			input: []byte{
+				0, 0, 16, 0, 0x2e, 0x08, 0, 0,
+				0x10, 0x02, 0x85, 0x09, 0xa0, 0x00, 0xd6, 0x01,
+			},
+			output: Radiotap{
+				Length:       16,
+				Flags:        FlagFCS,
+				Frequency:    2437,
+				ChannelFlags: 0xa0,
+				Signal:       -42,
+				HasSignal:    true,
+				Antenna:      1,
+			},
+		},
+		{
+			// TSFT is aligned to 8 after an extended bitmap
+			name: "extended_bitmap",
+			input: []byte{
+				0, 0, 24, 0, 0x01, 0, 0, 0x80, 0, 0, 0, 0, 0, 0, 0, 0,
+				1, 0, 0, 0, 0, 0, 0, 0,
+			},
+			output: Radiotap{Length: 24},
+		},
+		{
+			name:  "bad_version",
+			input: []byte{1, 0, 8, 0, 0, 0, 0, 0},
+			err:   ErrRadiotapVersion,
+		},
+		{
+			name:  "bad_length",
+			input: []byte{0, 0, 0xff, 0, 0, 0, 0, 0},
+			err:   ErrShortFrame,
+		},
+		{
+			name:  "missing_field",
+			input: []byte{0, 0, 8, 0, 0x08, 0, 0, 0},
+			err:   ErrShortFrame,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := ParseRadiotap(tt.input)
+			if err != tt.err {
+				t.Fatalf("ParseRadiotap(%v) error:\n- want: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if want, got := tt.output, rt; !reflect.DeepEqual(want, got) {
+				t.Fatalf("ParseRadiotap(%v):\n- want: %+v\n-  got: %+v", tt.input, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package events defines the machine-readable events emitted by chopper and
+// their NDJSON encoding.
+//
+// Every event carries the schema version in the "v" field. Fields may be
+// added within a version; removing or changing the meaning of a field bumps
+// SchemaVersion.
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the version of the event schema.
+const SchemaVersion = 1
+
+// Event types.
+const (
+	TypeStart    = "start"
+	TypeHop      = "hop"
+	TypeCycle    = "cycle"
+	TypeBSS      = "bss"
+	TypePMKID    = "pmkid"
+	TypeAlert    = "alert"
+	TypeSpectral = "spectral"
+	TypeConflict = "conflict"
+	TypePower    = "power"
+	TypeError    = "error"
+	TypeStop     = "stop"
+)
+
+// Event is a single hop or status event.
+type Event struct {
+	Version   int       `json:"v"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Interface string    `json:"interface,omitempty"`
+	// Agent is set by chopper controller on events relayed from an agent.
+	Agent string `json:"agent,omitempty"`
+
+	// Hop and conflict events, the frequency of the latter is the one
+	// another process moved the radio to.
+	Channel   int `json:"channel,omitempty"`
+	Frequency int `json:"frequency,omitempty"`
+	// Conflict events
+	Conflicts int `json:"conflicts,omitempty"`
+
+	// Hop events are numbered from 1 in every run, see HopSequence, and
+	// carry the channel the radio left, 0 on the first hop. A jump of Seq
+	// means hop events were lost, see Gaps.
+	Seq      uint64 `json:"seq,omitempty"`
+	Previous int    `json:"previous,omitempty"`
+
+	// Cycle events
+	Cycle int `json:"cycle,omitempty"`
+
+	// Start events
+	Channels []int `json:"channels,omitempty"`
+	DelayMs  int64 `json:"delay_ms,omitempty"`
+
+	// BSS, PMKID, alert and spectral events
+	BSSID  string  `json:"bssid,omitempty"`
+	SSID   string  `json:"ssid,omitempty"`
+	Signal float64 `json:"signal,omitempty"`
+
+	// PMKID events
+	Station string `json:"station,omitempty"`
+	PMKID   string `json:"pmkid,omitempty"`
+
+	// Alert events
+	Alert  string  `json:"alert,omitempty"`
+	Frames int     `json:"frames,omitempty"`
+	Rate   float64 `json:"rate,omitempty"`
+
+	// Spectral events
+	Samples   int     `json:"samples,omitempty"`
+	Noise     float64 `json:"noise,omitempty"`
+	Magnitude int     `json:"magnitude,omitempty"`
+
+	// Power events: the battery charge in percent, the hottest thermal zone
+	// in degrees Celsius and the resulting throttle (normal, slow or idle).
+	Battery     int     `json:"battery,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+	Throttle    string  `json:"throttle,omitempty"`
+
+	// Error events
+	Error string `json:"error,omitempty"`
+}
+
+// New returns an event of the given type with the version and time set.
+func New(typ string) Event {
+	return Event{
+		Version: SchemaVersion,
+		Type:    typ,
+		Time:    time.Now().UTC(),
+	}
+}
+
+// Encoder writes events as newline-delimited JSON. It is safe for
+// concurrent use.
+type Encoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{enc: json.NewEncoder(w)}
+}
+
+// Encode writes a single event followed by a newline.
+func (e *Encoder) Encode(event Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.enc.Encode(event)
+}
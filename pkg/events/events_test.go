@@ -0,0 +1,52 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+
+	hop := New(TypeHop)
+	hop.Time = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	hop.Interface = "wlan0mon"
+	hop.Channel = 6
+	hop.Frequency = 2437
+	hop.Seq = 3
+	hop.Previous = 1
+
+	stop := New(TypeStop)
+	stop.Time = hop.Time
+
+	for _, event := range []Event{hop, stop} {
+		if err := enc.Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `{"v":1,"type":"hop","time":"2021-01-01T00:00:00Z","interface":"wlan0mon","channel":6,"frequency":2437,"seq":3,"previous":1}
+{"v":1,"type":"stop","time":"2021-01-01T00:00:00Z"}
+`
+	if got := buf.String(); want != got {
+		t.Fatalf("Encode:\n- want: %v\n-  got: %v", want, got)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import "sync"
+
+// HopSequence numbers the hop events of a radio. It is safe for concurrent
+// use.
+type HopSequence struct {
+	mu       sync.Mutex
+	seq      uint64
+	previous int
+}
+
+// Next sets the sequence number and the previous channel of a hop event.
+func (s *HopSequence) Next(event *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	event.Seq = s.seq
+	event.Previous = s.previous
+	s.previous = event.Channel
+}
+
+// Gap describes the hop events lost before a hop event.
+type Gap struct {
+	// Missed is the number of hop events lost. The radio was on the
+	// Previous channel of the event at the end of the gap.
+	Missed uint64
+	// Restarted is set when the numbering started again, as a new run of
+	// chopper does. Missed is then the hops of the new run lost.
+	Restarted bool
+}
+
+// Gaps follows the hop events of a stream, per agent and interface, to
+// report the ones lost, e.g. by a slow consumer or a reconnect. The zero
+// value is ready to use.
+type Gaps struct {
+	last map[string]uint64
+}
+
+// Observe returns the hop events lost before event since the previous one
+// of the same agent and interface. A start event resets the numbering; the
+// first hop event observed without one has no gap. Other events and hop
+// events without sequence numbers are ignored.
+func (g *Gaps) Observe(event Event) Gap {
+	if g.last == nil {
+		g.last = make(map[string]uint64)
+	}
+	key := event.Agent + "\x00" + event.Interface
+
+	switch {
+	case event.Type == TypeStart:
+		g.last[key] = 0
+		return Gap{}
+	case event.Type != TypeHop || event.Seq == 0:
+		return Gap{}
+	}
+
+	last, ok := g.last[key]
+	g.last[key] = event.Seq
+	switch {
+	case !ok:
+		return Gap{}
+	case event.Seq <= last:
+		return Gap{Missed: event.Seq - 1, Restarted: true}
+	}
+	return Gap{Missed: event.Seq - last - 1}
+}
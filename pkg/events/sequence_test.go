@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"sync"
+	"testing"
+)
+
+func hopEvent(iface string, channel int, seq uint64) Event {
+	event := New(TypeHop)
+	event.Interface = iface
+	event.Channel = channel
+	event.Seq = seq
+	return event
+}
+
+func TestHopSequence(t *testing.T) {
+	var s HopSequence
+	var got [][2]int
+	for _, channel := range []int{1, 6, 11} {
+		event := hopEvent("wlan0mon", channel, 0)
+		s.Next(&event)
+		if event.Seq != uint64(len(got)+1) {
+			t.Errorf("hop %v: seq %v", channel, event.Seq)
+		}
+		got = append(got, [2]int{event.Previous, event.Channel})
+	}
+	want := [][2]int{{0, 1}, {1, 6}, {6, 11}}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("previous, channel = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestHopSequenceConcurrent(t *testing.T) {
+	var s HopSequence
+	var wg sync.WaitGroup
+	seen := make([]bool, 101)
+	var mu sync.Mutex
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			event := hopEvent("wlan0mon", 6, 0)
+			s.Next(&event)
+			mu.Lock()
+			seen[event.Seq] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for seq := 1; seq <= 100; seq++ {
+		if !seen[seq] {
+			t.Fatalf("seq %v was not used", seq)
+		}
+	}
+}
+
+func TestGaps(t *testing.T) {
+	relayed := hopEvent("wlan0mon", 6, 2)
+	relayed.Agent = "roof"
+
+	tests := []struct {
+		event Event
+		want  Gap
+	}{
+		{hopEvent("wlan0mon", 1, 5), Gap{}},
+		{hopEvent("wlan0mon", 6, 6), Gap{}},
+		{New(TypeCycle), Gap{}},
+		{hopEvent("wlan0mon", 1, 9), Gap{Missed: 2}},
+		// Other interfaces and agents are numbered separately
+		{hopEvent("wlan1mon", 36, 1), Gap{}},
+		{relayed, Gap{}},
+		{hopEvent("wlan1mon", 40, 2), Gap{}},
+		// The old hops have no numbers
+		{hopEvent("wlan0mon", 1, 0), Gap{}},
+		{hopEvent("wlan0mon", 6, 10), Gap{}},
+		// A new run
+		{hopEvent("wlan0mon", 1, 3), Gap{Missed: 2, Restarted: true}},
+		{func() Event { e := New(TypeStart); e.Interface = "wlan0mon"; return e }(), Gap{}},
+		{hopEvent("wlan0mon", 1, 1), Gap{}},
+		{func() Event { e := New(TypeStart); e.Interface = "wlan1mon"; return e }(), Gap{}},
+		{hopEvent("wlan1mon", 36, 4), Gap{Missed: 3}},
+	}
+
+	var g Gaps
+	for i, test := range tests {
+		if got := g.Observe(test.event); got != test.want {
+			t.Errorf("event %v (%v seq %v): %+v, want %+v", i, test.event.Interface, test.event.Seq, got, test.want)
+		}
+	}
+}
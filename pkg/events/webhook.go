@@ -0,0 +1,243 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebhookOptions configures a Webhook. Zero values select the defaults.
+type WebhookOptions struct {
+	// BatchSize is the number of events sent per request, 100 by default.
+	// A full batch is sent without waiting for the interval.
+	BatchSize int
+	// Interval is the longest time events are held before being sent, 5s
+	// by default.
+	Interval time.Duration
+	// Retries is the number of times a failed request is retried, with
+	// exponential backoff starting at one second. 3 by default.
+	Retries int
+	// MaxPending is the number of events kept while the endpoint is
+	// unreachable, older events are dropped first. 10000 by default.
+	MaxPending int
+}
+
+// Webhook sends events in batches to an HTTP endpoint. Each request is a
+// POST with one event per line, like Encoder. It is safe for concurrent use.
+type Webhook struct {
+	url     string
+	options WebhookOptions
+	client  *http.Client
+	full    chan struct{}
+	backoff time.Duration
+
+	mu      sync.Mutex
+	pending []Event
+	// shifted counts the events dropped from the front of pending, dropped
+	// the ones not reported yet.
+	shifted int
+	dropped int
+}
+
+// NewWebhook returns a Webhook posting to url. Events are only sent while
+// Run is running.
+func NewWebhook(url string, options WebhookOptions) *Webhook {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+	if options.Interval <= 0 {
+		options.Interval = 5 * time.Second
+	}
+	if options.Retries < 0 {
+		options.Retries = 0
+	} else if options.Retries == 0 {
+		options.Retries = 3
+	}
+	if options.MaxPending <= 0 {
+		options.MaxPending = 10000
+	}
+
+	return &Webhook{
+		url:     url,
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		full:    make(chan struct{}, 1),
+		backoff: time.Second,
+	}
+}
+
+// Encode queues an event. It never blocks on the network.
+func (w *Webhook) Encode(event Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) >= w.options.MaxPending {
+		w.pending = w.pending[1:]
+		w.shifted++
+		w.dropped++
+	}
+	w.pending = append(w.pending, event)
+
+	if len(w.pending) >= w.options.BatchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run sends the queued events every interval, or as soon as a batch is
+// full, until ctx is done. It then tries to send the remaining events for
+// up to 10 seconds. Errors are passed to onError if not nil.
+func (w *Webhook) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			report(w.Flush(final))
+			cancel()
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+
+		// Events left over when stopping are sent by the final flush
+		if err := w.Flush(ctx); ctx.Err() == nil {
+			report(err)
+		}
+	}
+}
+
+// Flush sends all the queued events. Batches that cannot be sent after the
+// retries stay queued, batches rejected by the endpoint are dropped.
+func (w *Webhook) Flush(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.options.BatchSize {
+			n = w.options.BatchSize
+		}
+		batch := append([]Event(nil), w.pending[:n]...)
+		shifted := w.shifted
+		w.mu.Unlock()
+
+		if len(batch) == 0 {
+			break
+		}
+		retry, err := w.send(ctx, batch)
+		if err != nil && retry {
+			return err
+		}
+
+		w.mu.Lock()
+		// Encode may have dropped some of the batch meanwhile
+		done := n - (w.shifted - shifted)
+		if done > len(w.pending) {
+			done = len(w.pending)
+		}
+		if done > 0 {
+			w.pending = w.pending[done:]
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+	if dropped > 0 {
+		return fmt.Errorf("webhook: dropped %v events while the endpoint was unreachable", dropped)
+	}
+	return nil
+}
+
+// send posts a batch, retrying on network errors and server errors. It
+// reports whether a failed batch may be sent again later.
+func (w *Webhook) send(ctx context.Context, batch []Event) (bool, error) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range batch {
+		if err := enc.Encode(event); err != nil {
+			return false, err
+		}
+	}
+
+	backoff := w.backoff
+	var err error
+	for attempt := 0; attempt <= w.options.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return true, fmt.Errorf("%v (%v)", err, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = w.post(ctx, body.Bytes())
+		if err == nil || !retry {
+			return retry, err
+		}
+	}
+
+	return true, err
+}
+
+// post sends a single request and reports whether it may be retried.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return true, fmt.Errorf("webhook: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook: %v", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,184 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records the channels of the hop events it receives and
+// replies with the given status codes, then 200.
+type webhookServer struct {
+	mu       sync.Mutex
+	statuses []int
+	batches  [][]int
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var batch []int
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, event.Channel)
+	}
+	s.batches = append(s.batches, batch)
+}
+
+func hop(channel int) Event {
+	event := New(TypeHop)
+	event.Channel = channel
+	return event
+}
+
+func TestWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  WebhookOptions
+		statuses []int
+		events   int
+		batches  [][]int
+		err      bool
+	}{
+		{
+			name:    "batches",
+			options: WebhookOptions{BatchSize: 2},
+			events:  5,
+			batches: [][]int{{1, 2}, {3, 4}, {5}},
+		},
+		{
+			name:     "retry",
+			options:  WebhookOptions{BatchSize: 2},
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			events:   3,
+			batches:  [][]int{{1, 2}, {3}},
+		},
+		{
+			name:     "retries exhausted",
+			options:  WebhookOptions{BatchSize: 2, Retries: 1},
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway},
+			events:   3,
+			batches:  [][]int{{1, 2}, {3}},
+			err:      true,
+		},
+		{
+			name:     "rejected",
+			options:  WebhookOptions{BatchSize: 2},
+			statuses: []int{http.StatusBadRequest},
+			events:   3,
+			batches:  [][]int{{3}},
+			err:      true,
+		},
+		{
+			name:    "max pending",
+			options: WebhookOptions{BatchSize: 10, MaxPending: 2},
+			events:  5,
+			batches: [][]int{{4, 5}},
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &webhookServer{statuses: tt.statuses}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			w := NewWebhook(server.URL, tt.options)
+			w.backoff = time.Millisecond
+			for i := 1; i <= tt.events; i++ {
+				_ = w.Encode(hop(i))
+			}
+
+			err := w.Flush(context.Background())
+			if (err != nil) != tt.err {
+				t.Fatalf("Flush:\n- want error: %v\n-  got: %v", tt.err, err)
+			}
+			if tt.err {
+				// Rejected batches are dropped, the others stay queued
+				_ = w.Flush(context.Background())
+			}
+			if !reflect.DeepEqual(tt.batches, handler.batches) {
+				t.Fatalf("Flush batches:\n- want: %v\n-  got: %v", tt.batches, handler.batches)
+			}
+		})
+	}
+}
+
+func TestWebhookRun(t *testing.T) {
+	handler := &webhookServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	w := NewWebhook(server.URL, WebhookOptions{BatchSize: 2, Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, func(err error) { t.Error(err) })
+		close(done)
+	}()
+
+	// A full batch is sent without waiting for the interval
+	_ = w.Encode(hop(1))
+	_ = w.Encode(hop(2))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		w.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("full batch not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The rest is sent when stopping
+	_ = w.Encode(hop(3))
+	cancel()
+	<-done
+
+	if want := [][]int{{1, 2}, {3}}; !reflect.DeepEqual(want, handler.batches) {
+		t.Fatalf("Run batches:\n- want: %v\n-  got: %v", want, handler.batches)
+	}
+}
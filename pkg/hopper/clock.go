@@ -0,0 +1,189 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a Hopper. It can be replaced to test the
+// scheduling deterministically or to simulate hopping faster than real time.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// RealClock returns the system clock.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// FakeClock is a Clock that only moves when told to.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+	// auto advances the clock to the deadline of a timer as soon as it is
+	// set, see NewSimulatedClock.
+	auto bool
+}
+
+// NewFakeClock returns a clock set to start that is moved with Advance.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// NewSimulatedClock returns a clock that jumps to the deadline of every
+// timer as soon as it is set, so a Hopper runs as fast as the Tuner allows
+// while seeing simulated time pass.
+func NewSimulatedClock(start time.Time) *FakeClock {
+	c := NewFakeClock(start)
+	c.auto = true
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer creates a timer firing when the clock reaches Now()+d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+
+	return t
+}
+
+// Advance moves the clock forward, firing the timers that expire.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.active && !t.deadline.After(c.now) {
+			t.fire(c.now)
+		}
+	}
+}
+
+// BlockUntil waits until n timers are pending, e.g. until the hopper is
+// dwelling on a channel.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.pending() < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *FakeClock) pending() int {
+	n := 0
+	for _, t := range c.timers {
+		if t.active {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// fire must be called with the clock lock held.
+func (t *fakeTimer) fire(now time.Time) {
+	t.active = false
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wasActive := t.active
+	t.active = true
+	t.deadline = c.now.Add(d)
+	if c.auto && t.deadline.After(c.now) {
+		c.now = t.deadline
+	}
+	if !t.deadline.After(c.now) {
+		t.fire(c.now)
+	}
+
+	c.cond.Broadcast()
+	return wasActive
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
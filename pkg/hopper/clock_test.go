@@ -0,0 +1,109 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncRecorder is a recorder safe to read while the hopper runs.
+type syncRecorder struct {
+	mu sync.Mutex
+	recorder
+}
+
+func (r *syncRecorder) SetChannel(channel int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recorder.SetChannel(channel)
+}
+
+func (r *syncRecorder) tuned() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.channels...)
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	tuner := &syncRecorder{}
+
+	h, err := New(tuner, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Minute,
+		Clock:    clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- h.Run(ctx)
+	}()
+
+	for i, want := range [][]int{{1}, {1, 6}, {1, 6, 11}, {1, 6, 11, 1}} {
+		clock.BlockUntil(1)
+		if got := tuner.tuned(); !reflect.DeepEqual(want, got) {
+			t.Fatalf("after %v minutes:\n- want: %v\n-  got: %v", i, want, got)
+		}
+
+		// Not yet time to hop
+		clock.Advance(30 * time.Second)
+		clock.BlockUntil(1)
+		if got := tuner.tuned(); !reflect.DeepEqual(want, got) {
+			t.Fatalf("after %v minutes and a half:\n- want: %v\n-  got: %v", i, want, got)
+		}
+		clock.Advance(30 * time.Second)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulatedClock(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	tuner := &recorder{}
+
+	h, err := New(tuner, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Hour,
+		Clock:    clock,
+		OnCycle:  stopAfter(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	if want, got := 6*time.Hour, clock.Now().Sub(start); want != got {
+		t.Fatalf("simulated time:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 2*time.Hour, h.Stats().Channels[6].Dwell; want != got {
+		t.Fatalf("dwell on 6:\n- want: %v\n-  got: %v", want, got)
+	}
+}
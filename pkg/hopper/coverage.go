@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import "time"
+
+// coverage tracks when the channels of the plan were last visited, see
+// Config.MaxGap.
+type coverage struct {
+	maxGap time.Duration
+	// last is when each channel of the plan was last visited, or the plan
+	// was applied if it was not visited since.
+	last map[int]time.Time
+	// starved are the channels already reported for their current gap.
+	starved map[int]bool
+}
+
+// track starts tracking plan at now, keeping the last visit of the
+// channels that were already in it.
+func (c *coverage) track(plan []int, now time.Time) {
+	last := make(map[int]time.Time, len(plan))
+	starved := make(map[int]bool)
+	for _, channel := range plan {
+		if t, ok := c.last[channel]; ok {
+			last[channel] = t
+			starved[channel] = c.starved[channel]
+		} else {
+			last[channel] = now
+		}
+	}
+	c.last = last
+	c.starved = starved
+}
+
+// visit records a visit of channel at now and returns the gap since the
+// previous one, or zero if channel is not in the plan.
+func (c *coverage) visit(channel int, now time.Time) time.Duration {
+	last, ok := c.last[channel]
+	if !ok {
+		return 0
+	}
+	c.last[channel] = now
+	c.starved[channel] = false
+	return now.Sub(last)
+}
+
+// check calls starve once for every channel whose gap exceeds maxGap at
+// now, and returns the channel with the longest such gap.
+func (c *coverage) check(now time.Time, starve func(channel int, gap time.Duration)) (int, bool) {
+	longest := -1
+	var longestGap time.Duration
+	for channel, last := range c.last {
+		gap := now.Sub(last)
+		if gap <= c.maxGap {
+			continue
+		}
+		if !c.starved[channel] {
+			c.starved[channel] = true
+			starve(channel, gap)
+		}
+		if gap > longestGap || gap == longestGap && channel < longest {
+			longest, longestGap = channel, gap
+		}
+	}
+	return longest, longest >= 0
+}
+
+// insert returns a copy of rotation with channel inserted at idx.
+func insert(rotation []int, idx int, channel int) []int {
+	next := make([]int, 0, len(rotation)+1)
+	next = append(next, rotation[:idx]...)
+	next = append(next, channel)
+	return append(next, rotation[idx:]...)
+}
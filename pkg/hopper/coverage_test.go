@@ -0,0 +1,45 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoverageTrack(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &coverage{maxGap: time.Second}
+	c.track([]int{1, 6}, start)
+	c.visit(1, start.Add(time.Second))
+
+	// Channels kept in the new plan keep their last visit
+	c.track([]int{1, 11}, start.Add(2*time.Second))
+	var starved []int
+	channel, ok := c.check(start.Add(3500*time.Millisecond), func(channel int, gap time.Duration) {
+		starved = append(starved, channel)
+	})
+	if !ok || channel != 1 || len(starved) != 2 {
+		t.Fatalf("check = %v, %v, starved %v", channel, ok, starved)
+	}
+	if gap := c.visit(6, start.Add(4*time.Second)); gap != 0 {
+		t.Fatalf("visit of a channel out of the plan returned %v", gap)
+	}
+	if gap := c.visit(11, start.Add(4*time.Second)); gap != 2*time.Second {
+		t.Fatalf("visit of 11 returned %v", gap)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"math/rand"
+	"time"
+)
+
+// DwellController decides how long to stay on each channel, while the
+// Strategy decides which channel comes next, so that both can be combined
+// freely. Dwell is called with the channel and the current delay once the
+// radio was retuned to it and Verify passed, before the dwell timer starts.
+// With an AsyncTuner it is called once the retune is sent.
+type DwellController interface {
+	Dwell(channel int, delay time.Duration) time.Duration
+}
+
+// DwellFunc adapts an ordinary function to the DwellController interface.
+type DwellFunc func(channel int, delay time.Duration) time.Duration
+
+// Dwell calls f(channel, delay).
+func (f DwellFunc) Dwell(channel int, delay time.Duration) time.Duration {
+	return f(channel, delay)
+}
+
+// FixedDwell stays for the delay on every channel.
+type FixedDwell struct{}
+
+// Dwell returns delay.
+func (FixedDwell) Dwell(channel int, delay time.Duration) time.Duration {
+	return delay
+}
+
+// JitteredDwell randomizes every dwell around the delay, so that hops do
+// not stay in phase with periodic traffic such as beacons.
+type JitteredDwell struct {
+	Rand *rand.Rand
+	// Jitter is the largest change as a fraction of the delay, from 0
+	// to 1.
+	Jitter float64
+}
+
+// Dwell returns delay changed by up to j.Jitter of it either way.
+func (j JitteredDwell) Dwell(channel int, delay time.Duration) time.Duration {
+	change := (2*j.Rand.Float64() - 1) * j.Jitter
+	return delay + time.Duration(change*float64(delay))
+}
+
+// ProportionalDwell stays longer on the channels with more traffic: the
+// dwell is the delay scaled by the traffic of the channel relative to the
+// average of the plan, so a cycle takes about as long as with the delay.
+type ProportionalDwell struct {
+	// Traffic returns the traffic of the last visit to channel, e.g. in
+	// packets per second.
+	Traffic func(channel int) float64
+	// Min and Max bound the dwell, a quarter and four times the delay if
+	// zero.
+	Min time.Duration
+	Max time.Duration
+
+	traffic map[int]float64
+}
+
+// Dwell returns delay scaled by the traffic of channel, or delay until
+// some traffic was seen.
+func (p *ProportionalDwell) Dwell(channel int, delay time.Duration) time.Duration {
+	if p.traffic == nil {
+		p.traffic = make(map[int]float64)
+	}
+	p.traffic[channel] = p.Traffic(channel)
+
+	total := 0.0
+	for _, traffic := range p.traffic {
+		total += traffic
+	}
+	if total <= 0 {
+		return delay
+	}
+	average := total / float64(len(p.traffic))
+	dwell := time.Duration(float64(delay) * p.traffic[channel] / average)
+
+	min, max := p.Min, p.Max
+	if min == 0 {
+		min = delay / 4
+	}
+	if max == 0 {
+		max = 4 * delay
+	}
+	switch {
+	case dwell < min:
+		return min
+	case dwell > max:
+		return max
+	}
+	return dwell
+}
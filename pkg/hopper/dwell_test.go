@@ -0,0 +1,100 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestJitteredDwell(t *testing.T) {
+	j := JitteredDwell{Rand: rand.New(rand.NewSource(1)), Jitter: 0.2}
+	varied := false
+	for i := 0; i < 100; i++ {
+		dwell := j.Dwell(1, 100*time.Millisecond)
+		if dwell < 80*time.Millisecond || dwell > 120*time.Millisecond {
+			t.Fatalf("dwell %v out of 100ms ±20%%", dwell)
+		}
+		varied = varied || dwell != 100*time.Millisecond
+	}
+	if !varied {
+		t.Fatal("dwell never changed")
+	}
+}
+
+func TestProportionalDwell(t *testing.T) {
+	traffic := map[int]float64{}
+	p := &ProportionalDwell{
+		Traffic: func(channel int) float64 { return traffic[channel] },
+		Max:     250 * time.Millisecond,
+	}
+	delay := 100 * time.Millisecond
+
+	// No traffic yet
+	for _, channel := range []int{1, 6, 11} {
+		if got := p.Dwell(channel, delay); got != delay {
+			t.Fatalf("dwell on %v without traffic = %v", channel, got)
+		}
+	}
+
+	traffic[1], traffic[6], traffic[11] = 10, 50, 0
+	// The average is over the latest visits of every channel
+	for _, channel := range []int{1, 6, 11} {
+		p.Dwell(channel, delay)
+	}
+	tests := []struct {
+		channel int
+		want    time.Duration
+	}{
+		{1, 50 * time.Millisecond},
+		{6, 250 * time.Millisecond},
+		{11, 25 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := p.Dwell(tt.channel, delay); got != tt.want {
+			t.Errorf("dwell on %v:\n- want: %v\n-  got: %v", tt.channel, tt.want, got)
+		}
+	}
+}
+
+func TestRunDwellController(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+
+	// Config.Dwell takes precedence over a DwellStrategy
+	h, err := New(&recorder{}, Config{
+		Channels: []int{1, 6},
+		Delay:    time.Second,
+		Clock:    clock,
+		Strategy: &Focus{Activity: func(int) float64 { return 0 }, Sweep: time.Millisecond},
+		Dwell: DwellFunc(func(channel int, delay time.Duration) time.Duration {
+			return time.Duration(channel) * delay
+		}),
+		OnCycle: stopAfter(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	if want, got := 14*time.Second, clock.Now().Sub(start); want != got {
+		t.Fatalf("simulated time:\n- want: %v\n-  got: %v", want, got)
+	}
+}
@@ -0,0 +1,565 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package hopper cycles a radio through a channel plan.
+//
+// The radio is abstracted by the Tuner interface so the hopping logic can be
+// reused with any backend, while the order in which channels are visited is
+// decided by a Strategy and the time spent on each by a DwellController.
+package hopper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoChannels is returned by New when the plan is empty.
+var ErrNoChannels = errors.New("hopper: no channels to hop")
+
+// Tuner changes the channel of a radio.
+type Tuner interface {
+	SetChannel(channel int) error
+}
+
+// AsyncTuner is a Tuner that can send a retune request without waiting for
+// its result, which is delivered on the returned channel. When the Tuner
+// given to New implements it, SetChannelAsync is used instead of
+// SetChannel.
+type AsyncTuner interface {
+	Tuner
+	SetChannelAsync(channel int) (<-chan error, error)
+}
+
+// TunerFunc adapts an ordinary function to the Tuner interface.
+type TunerFunc func(channel int) error
+
+// SetChannel calls f(channel).
+func (f TunerFunc) SetChannel(channel int) error {
+	return f(channel)
+}
+
+// HopError is returned by Run when the radio cannot be tuned.
+type HopError struct {
+	Channel int
+	Err     error
+}
+
+func (e *HopError) Error() string {
+	return fmt.Sprintf("cannot set channel %v: %v", e.Channel, e.Err)
+}
+
+func (e *HopError) Unwrap() error {
+	return e.Err
+}
+
+// Config configures a Hopper.
+type Config struct {
+	// Channels is the channel plan.
+	Channels []int
+	// Delay is the time spent on each channel, it can be changed while
+	// running with SetDelay.
+	Delay time.Duration
+	// Strategy decides the rotation of every cycle, Sequential by default.
+	Strategy Strategy
+	// Dwell decides the time spent on each channel. If nil, the Strategy
+	// does when it is a DwellStrategy, otherwise it is the delay.
+	Dwell DwellController
+	// Clock is the source of time, RealClock by default.
+	Clock Clock
+
+	// BeforeHop, if set, is called before every retune with the channel
+	// about to be set.
+	BeforeHop func(channel int)
+	// OnHop, if set, is called after every successful retune.
+	OnHop func(channel int)
+	// OnCycle, if set, is called after every full cycle with the number of
+	// cycles completed so far. Returning an error stops the hopper.
+	OnCycle func(cycle int) error
+	// OnError, if set, is called with errors that do not stop the hopper.
+	OnError func(err error)
+	// Verify, if set, is called after every acknowledged retune to check
+	// that the radio is on the channel. An error fails the hop like one
+	// from the Tuner, and the dwell starts once it returns.
+	Verify func(channel int) error
+	// CompensateLatency shortens the dwell by the time the retune took,
+	// down to half the dwell, so that every channel takes the same time
+	// per cycle whatever its retune latency. The dwell of an AsyncTuner
+	// always starts when the retune is sent.
+	CompensateLatency bool
+
+	// MaxGap, if set, bounds the time between two visits of every channel
+	// of the plan, which strategies reordering or repeating channels, or
+	// dwelling longer on some, could otherwise leave unvisited.
+	MaxGap time.Duration
+	// OnStarved, if set, is called when a channel of the plan has not been
+	// visited for more than MaxGap, once per gap.
+	OnStarved func(channel int, gap time.Duration)
+	// ForceVisits visits the channel unvisited for the longest time at the
+	// next hop once it exceeds MaxGap, before resuming the rotation.
+	ForceVisits bool
+}
+
+// Hopper cycles a Tuner through a channel plan. The plan can be changed
+// while running, changes are applied at the next hop.
+type Hopper struct {
+	tuner  Tuner
+	config Config
+
+	mu       sync.Mutex
+	channels []int
+	changed  bool
+	restart  bool
+	delay    time.Duration
+	// resumed is closed by Resume, it is nil while running
+	resumed chan struct{}
+
+	stats stats
+}
+
+// New creates a Hopper for the given tuner.
+func New(tuner Tuner, config Config) (*Hopper, error) {
+	if len(config.Channels) == 0 {
+		return nil, ErrNoChannels
+	}
+	if config.Delay <= 0 {
+		return nil, fmt.Errorf("hopper: invalid delay %v", config.Delay)
+	}
+	if config.Strategy == nil {
+		config.Strategy = Sequential{}
+	}
+	if config.Clock == nil {
+		config.Clock = RealClock()
+	}
+
+	channels := make([]int, len(config.Channels))
+	copy(channels, config.Channels)
+	config.Channels = nil
+
+	return &Hopper{
+		tuner:    tuner,
+		config:   config,
+		channels: channels,
+		delay:    config.Delay,
+	}, nil
+}
+
+// Delay returns the time spent on each channel.
+func (h *Hopper) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.delay
+}
+
+// SetDelay changes the time spent on each channel, starting from the next
+// hop.
+func (h *Hopper) SetDelay(delay time.Duration) error {
+	if delay <= 0 {
+		return fmt.Errorf("hopper: invalid delay %v", delay)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.delay = delay
+	return nil
+}
+
+// Channels returns the current plan.
+func (h *Hopper) Channels() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	channels := make([]int, len(h.channels))
+	copy(channels, h.channels)
+	return channels
+}
+
+// SetChannels replaces the plan.
+func (h *Hopper) SetChannels(channels []int) error {
+	if len(channels) == 0 {
+		return ErrNoChannels
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.channels = make([]int, len(channels))
+	copy(h.channels, channels)
+	h.changed = true
+	return nil
+}
+
+// AddChannel appends a channel to the plan.
+func (h *Hopper) AddChannel(channel int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	channels := make([]int, len(h.channels), len(h.channels)+1)
+	copy(channels, h.channels)
+	h.channels = append(channels, channel)
+	h.changed = true
+}
+
+// RemoveChannel removes every occurrence of a channel from the plan. The
+// last channel cannot be removed.
+func (h *Hopper) RemoveChannel(channel int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	channels := make([]int, 0, len(h.channels))
+	for _, c := range h.channels {
+		if c != channel {
+			channels = append(channels, c)
+		}
+	}
+	if len(channels) == len(h.channels) {
+		return fmt.Errorf("hopper: channel %v is not in the plan", channel)
+	}
+	if len(channels) == 0 {
+		return ErrNoChannels
+	}
+
+	h.channels = channels
+	h.changed = true
+	return nil
+}
+
+// Restart starts the rotation over from its first channel at the next hop,
+// e.g. after the system resumed from suspend.
+func (h *Hopper) Restart() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.restart = true
+}
+
+// Pause stops hopping before the next hop until Resume is called, leaving
+// the radio on its current channel.
+func (h *Hopper) Pause() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.resumed == nil {
+		h.resumed = make(chan struct{})
+	}
+}
+
+// Resume resumes hopping after Pause.
+func (h *Hopper) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.resumed != nil {
+		close(h.resumed)
+		h.resumed = nil
+	}
+}
+
+// Paused reports whether the hopper is paused.
+func (h *Hopper) Paused() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.resumed != nil
+}
+
+// waitResumed waits until the hopper is not paused or ctx is done, and
+// reports whether it was paused.
+func (h *Hopper) waitResumed(ctx context.Context) bool {
+	h.mu.Lock()
+	resumed := h.resumed
+	h.mu.Unlock()
+
+	if resumed == nil {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+	case <-resumed:
+	}
+	return true
+}
+
+// restarted reports whether Restart was called since the last call.
+func (h *Hopper) restarted() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	restart := h.restart
+	h.restart = false
+	return restart
+}
+
+// plan returns the current plan without copying it, so that Run does not
+// allocate every cycle. The plan is replaced instead of modified in place,
+// and the capacity is capped so that appending to it copies it.
+func (h *Hopper) plan() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.channels[:len(h.channels):len(h.channels)]
+}
+
+// planChanged returns the new plan if it changed since the last call.
+func (h *Hopper) planChanged() ([]int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.changed {
+		return nil, false
+	}
+	h.changed = false
+
+	return h.channels[:len(h.channels):len(h.channels)], true
+}
+
+// resume returns the index in rotation of the hop following channel, or
+// idx wrapped to the rotation if channel is not in it anymore.
+func resume(rotation []int, channel int, idx int) int {
+	for i, c := range rotation {
+		if c == channel {
+			return (i + 1) % len(rotation)
+		}
+	}
+	return idx % len(rotation)
+}
+
+// dwell returns the time to spend on channel, as decided by
+// Config.Dwell, a DwellStrategy or the delay.
+func (h *Hopper) dwell(channel int) time.Duration {
+	delay := h.Delay()
+	if h.config.Dwell != nil {
+		return h.config.Dwell.Dwell(channel, delay)
+	}
+	if d, ok := h.config.Strategy.(DwellStrategy); ok {
+		return d.Dwell(channel, delay)
+	}
+	return delay
+}
+
+// starve records a channel unvisited for more than Config.MaxGap.
+func (h *Hopper) starve(channel int, gap time.Duration) {
+	h.stats.starve(channel)
+	if h.config.OnStarved != nil {
+		h.config.OnStarved(channel, gap)
+	}
+}
+
+func (h *Hopper) warn(err error) {
+	if h.config.OnError != nil {
+		h.config.OnError(err)
+	}
+}
+
+// tune retunes the radio and starts the dwell timer. With an AsyncTuner
+// the timer is started as soon as the request is sent, so the reply
+// round-trip overlaps the dwell instead of lengthening it. It returns when
+// the radio is tuned, or a zero time if ctx is done first.
+func (h *Hopper) tune(ctx context.Context, channel int, timer Timer) (time.Time, error) {
+	clock := h.config.Clock
+	start := clock.Now()
+
+	async, ok := h.tuner.(AsyncTuner)
+	if !ok {
+		err := h.tuner.SetChannel(channel)
+		acked := clock.Now()
+		tuned := acked
+		if err == nil {
+			tuned, err = h.verify(channel, start, acked)
+		}
+		h.stats.hop(channel, start, acked.Sub(start), err)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		dwell := h.dwell(channel)
+		if h.config.CompensateLatency {
+			dwell = compensate(dwell, tuned.Sub(start))
+		}
+		timer.Reset(dwell)
+		return tuned, nil
+	}
+
+	reply, err := async.SetChannelAsync(channel)
+	if err == nil {
+		timer.Reset(h.dwell(channel))
+		select {
+		case <-ctx.Done():
+			return time.Time{}, nil
+		case err = <-reply:
+		}
+	}
+
+	acked := clock.Now()
+	tuned := acked
+	if err == nil {
+		tuned, err = h.verify(channel, start, acked)
+	}
+	h.stats.hop(channel, start, acked.Sub(start), err)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return tuned, nil
+}
+
+// compensate shortens dwell by the latency of the retune, keeping at least
+// half of it.
+func compensate(dwell time.Duration, latency time.Duration) time.Duration {
+	if latency > dwell/2 {
+		return dwell - dwell/2
+	}
+	return dwell - latency
+}
+
+// verify checks a retune sent at start and acknowledged at acked with
+// Config.Verify, returning when the radio was confirmed on channel.
+func (h *Hopper) verify(channel int, start time.Time, acked time.Time) (time.Time, error) {
+	if h.config.Verify == nil {
+		return acked, nil
+	}
+	if err := h.config.Verify(channel); err != nil {
+		return time.Time{}, err
+	}
+	verified := h.config.Clock.Now()
+	h.stats.verify(verified.Sub(start))
+	return verified, nil
+}
+
+// Run hops until ctx is done or the radio cannot be tuned. It returns nil
+// when stopped through ctx.
+func (h *Hopper) Run(ctx context.Context) error {
+	h.planChanged()
+	rotation, err := h.config.Strategy.Rotation(h.plan())
+	if err != nil {
+		return err
+	}
+	if len(rotation) == 0 {
+		return ErrNoChannels
+	}
+
+	clock := h.config.Clock
+	timer := clock.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C()
+
+	var cover *coverage
+	if h.config.MaxGap > 0 {
+		cover = &coverage{maxGap: h.config.MaxGap}
+		cover.track(h.plan(), clock.Now())
+	}
+
+	idx := 0
+	cycles := 0
+	for ctx.Err() == nil {
+		if h.waitResumed(ctx) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// The channels were not starved while paused
+			if cover != nil {
+				cover = &coverage{maxGap: h.config.MaxGap}
+				cover.track(h.plan(), clock.Now())
+			}
+		}
+		if cover != nil {
+			starved, ok := cover.check(clock.Now(), h.starve)
+			if ok && h.config.ForceVisits && starved != rotation[idx] {
+				rotation = insert(rotation, idx, starved)
+			}
+		}
+		channel := rotation[idx]
+		if h.config.BeforeHop != nil {
+			h.config.BeforeHop(channel)
+		}
+		tuned, err := h.tune(ctx, channel, timer)
+		if err != nil {
+			return &HopError{Channel: channel, Err: err}
+		}
+		if tuned.IsZero() {
+			return nil
+		}
+		if cover != nil {
+			h.stats.gap(channel, cover.visit(channel, tuned))
+		}
+		if h.config.OnHop != nil {
+			h.config.OnHop(channel)
+		}
+
+		// Delay
+		select {
+		case <-ctx.Done():
+			h.stats.dwell(channel, clock.Now().Sub(tuned))
+			return nil
+		case <-timer.C():
+		}
+		h.stats.dwell(channel, clock.Now().Sub(tuned))
+
+		// Apply plan changes at the hop boundary
+		if channels, ok := h.planChanged(); ok {
+			if cover != nil {
+				cover.track(channels, clock.Now())
+			}
+			next, err := h.config.Strategy.Rotation(channels)
+			if err != nil {
+				h.warn(err)
+			} else if len(next) > 0 {
+				rotation = next
+				if pos := resume(rotation, channel, idx+1); pos == 0 {
+					idx = len(rotation) - 1
+				} else {
+					idx = pos - 1
+				}
+			}
+		}
+
+		if h.restarted() {
+			next, err := h.config.Strategy.Rotation(h.plan())
+			if err != nil {
+				h.warn(err)
+			} else if len(next) > 0 {
+				rotation = next
+			}
+			idx = 0
+			continue
+		}
+
+		// Increase counter
+		idx++
+		if idx >= len(rotation) {
+			idx = 0
+			cycles++
+
+			if h.config.OnCycle != nil {
+				if err := h.config.OnCycle(cycles); err != nil {
+					return err
+				}
+			}
+
+			next, err := h.config.Strategy.Rotation(h.plan())
+			if err != nil {
+				h.warn(err)
+			} else if len(next) > 0 {
+				rotation = next
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,854 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+var errStop = errors.New("stop")
+
+// recorder is a Tuner that records every channel it is tuned to.
+type recorder struct {
+	channels []int
+	fail     int
+}
+
+func (r *recorder) SetChannel(channel int) error {
+	if channel == r.fail {
+		return errors.New("unsupported")
+	}
+
+	r.channels = append(r.channels, channel)
+	return nil
+}
+
+// stopAfter returns an OnCycle callback that stops the hopper after n cycles.
+func stopAfter(n int) func(int) error {
+	return func(cycle int) error {
+		if cycle >= n {
+			return errStop
+		}
+		return nil
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&recorder{}, Config{Delay: time.Millisecond}); err != ErrNoChannels {
+		t.Fatalf("New without channels:\n- want: %v\n-  got: %v", ErrNoChannels, err)
+	}
+	if _, err := New(&recorder{}, Config{Channels: []int{1}}); err == nil {
+		t.Fatalf("New without delay: expected error")
+	}
+}
+
+func TestRunSequential(t *testing.T) {
+	tuner := &recorder{}
+	hops := 0
+
+	h, err := New(tuner, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Millisecond,
+		OnHop:    func(int) { hops++ },
+		OnCycle:  stopAfter(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	if want, got := []int{1, 6, 11, 1, 6, 11}, tuner.channels; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Run channels:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 6, hops; want != got {
+		t.Fatalf("Run hops:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestRunRestart(t *testing.T) {
+	tuner := &recorder{}
+	var h *Hopper
+	restarted := false
+
+	h, err := New(tuner, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Millisecond,
+		OnHop: func(channel int) {
+			if channel == 6 && !restarted {
+				restarted = true
+				h.Restart()
+			}
+		},
+		OnCycle: stopAfter(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	if want, got := []int{1, 6, 1, 6, 11}, tuner.channels; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Run channels:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestRunAllocations(t *testing.T) {
+	// Once warmed up, hopping must not allocate, to keep the GC idle on
+	// routers with little memory.
+	const warmup, hops = 2000, 1000
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var before, after runtime.MemStats
+	n := 0
+	h, err := New(TunerFunc(func(int) error { return nil }), Config{
+		Channels: []int{1, 6, 11, 36},
+		Delay:    100 * time.Millisecond,
+		Clock:    NewSimulatedClock(time.Unix(1633089600, 0)),
+		OnHop: func(int) {
+			n++
+			switch n {
+			case warmup:
+				runtime.ReadMemStats(&before)
+			case warmup + hops:
+				runtime.ReadMemStats(&after)
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The count is process-wide, tolerate the odd allocation of the runtime
+	// but not one per cycle
+	if allocs := after.Mallocs - before.Mallocs; allocs > hops/100 {
+		t.Fatalf("Run allocated %v times in %v hops", allocs, hops)
+	}
+}
+
+func TestRunHookOrder(t *testing.T) {
+	var calls []string
+	tuner := TunerFunc(func(channel int) error {
+		calls = append(calls, fmt.Sprintf("tune %v", channel))
+		return nil
+	})
+
+	h, err := New(tuner, Config{
+		Channels:  []int{1, 6},
+		Delay:     time.Millisecond,
+		BeforeHop: func(channel int) { calls = append(calls, fmt.Sprintf("before %v", channel)) },
+		OnHop:     func(channel int) { calls = append(calls, fmt.Sprintf("after %v", channel)) },
+		OnCycle:   stopAfter(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	want := []string{"before 1", "tune 1", "after 1", "before 6", "tune 6", "after 6"}
+	if !reflect.DeepEqual(want, calls) {
+		t.Fatalf("Run calls:\n- want: %v\n-  got: %v", want, calls)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h, err := New(&recorder{}, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Hour,
+		OnHop:    func(int) { cancel() },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(ctx); err != nil {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", nil, err)
+	}
+}
+
+func TestRunPause(t *testing.T) {
+	hops := make(chan int, 16)
+	var h *Hopper
+	h, err := New(&recorder{}, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Millisecond,
+		OnHop: func(channel int) {
+			if channel == 6 {
+				h.Pause()
+			}
+			hops <- channel
+		},
+		OnCycle: stopAfter(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- h.Run(context.Background()) }()
+
+	for _, want := range []int{1, 6} {
+		if got := <-hops; got != want {
+			t.Fatalf("Run hop:\n- want: %v\n-  got: %v", want, got)
+		}
+	}
+	select {
+	case channel := <-hops:
+		t.Fatalf("Run hopped to %v while paused", channel)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !h.Paused() {
+		t.Fatal("Paused() = false")
+	}
+
+	h.Resume()
+	if got := <-hops; got != 11 {
+		t.Fatalf("Run hop after Resume:\n- want: 11\n-  got: %v", got)
+	}
+	if err := <-done; err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+
+	// Cancelling stops a paused hopper
+	ctx, cancel := context.WithCancel(context.Background())
+	h.Pause()
+	go func() { done <- h.Run(ctx) }()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", nil, err)
+	}
+}
+
+func TestRunHopError(t *testing.T) {
+	h, err := New(&recorder{fail: 6}, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hopErr *HopError
+	if err := h.Run(context.Background()); !errors.As(err, &hopErr) || hopErr.Channel != 6 {
+		t.Fatalf("Run:\n- want: HopError on 6\n-  got: %v", err)
+	}
+}
+
+func TestRanked(t *testing.T) {
+	surveys := [][]nl80211util.SurveyInfo{
+		{
+			{Frequency: 2412, Time: 100, TimeBusy: 0},
+			{Frequency: 2437, Time: 100, TimeBusy: 0},
+		},
+		{
+			{Frequency: 2412, Time: 200, TimeBusy: 10},
+			{Frequency: 2437, Time: 200, TimeBusy: 90},
+		},
+	}
+
+	r := &Ranked{
+		Survey: func() ([]nl80211util.SurveyInfo, error) {
+			survey := surveys[0]
+			surveys = surveys[1:]
+			return survey, nil
+		},
+		Every: 2,
+	}
+
+	channels := []int{1, 6}
+	for i, want := range [][]int{{1, 6}, {1, 6}, {6, 1, 6, 6}} {
+		got, err := r.Rotation(channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("Rotation #%v:\n- want: %v\n-  got: %v", i, want, got)
+		}
+	}
+}
+
+func TestQuietest(t *testing.T) {
+	surveys := [][]nl80211util.SurveyInfo{
+		{
+			{Frequency: 2412, Time: 100},
+			{Frequency: 2437, Time: 100},
+			{Frequency: 2462, Time: 100},
+		},
+		{
+			// 1 is busy, 11 is idle but noisy
+			{Frequency: 2412, Time: 200, TimeBusy: 90, Noise: -95},
+			{Frequency: 2437, Time: 200, TimeBusy: 10, Noise: -95},
+			{Frequency: 2462, Time: 200, TimeBusy: 0, Noise: -70},
+		},
+	}
+
+	q := &Quietest{
+		Survey: func() ([]nl80211util.SurveyInfo, error) {
+			survey := surveys[0]
+			surveys = surveys[1:]
+			return survey, nil
+		},
+	}
+
+	channels := []int{1, 6, 11}
+	for i, want := range [][]int{{1, 6, 11}, {6, 11, 1, 6, 11, 6}} {
+		got, err := q.Rotation(channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("Rotation #%v:\n- want: %v\n-  got: %v", i, want, got)
+		}
+	}
+}
+
+func TestQuietness(t *testing.T) {
+	for _, test := range []struct {
+		busy  float64
+		noise int
+		want  float64
+	}{
+		{0, 0, 1},
+		{0.25, -100, 0.75},
+		{0, -60, 0},
+		{0.5, -50, 0},
+	} {
+		if got := quietness(test.busy, test.noise); got != test.want {
+			t.Errorf("quietness(%v, %v) = %v, want %v", test.busy, test.noise, got, test.want)
+		}
+	}
+}
+
+func TestEscalating(t *testing.T) {
+	active := map[int]bool{6: true}
+	e := &Escalating{
+		Active: func(channel int) bool { return active[channel] },
+		Max:    350 * time.Millisecond,
+	}
+
+	delay := 100 * time.Millisecond
+	var got []time.Duration
+	for i := 0; i < 4; i++ {
+		got = append(got, e.Dwell(6, delay))
+	}
+	active[6] = false
+	for i := 0; i < 3; i++ {
+		got = append(got, e.Dwell(6, delay))
+	}
+	want := []time.Duration{200, 350, 350, 350, 200, 100, 100}
+	for i := range want {
+		want[i] *= time.Millisecond
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("Dwell:\n- want: %v\n-  got: %v", want, got)
+	}
+	if got := e.Dwell(1, delay); got != delay {
+		t.Fatalf("Dwell on a quiet channel:\n- want: %v\n-  got: %v", delay, got)
+	}
+
+	// No escalation with a cap below the delay
+	e = &Escalating{Active: func(int) bool { return true }, Max: time.Millisecond}
+	if got := e.Dwell(6, delay); got != delay {
+		t.Fatalf("Dwell with a low cap:\n- want: %v\n-  got: %v", delay, got)
+	}
+}
+
+func TestRunDwellStrategy(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+
+	h, err := New(&recorder{}, Config{
+		Channels: []int{1, 6},
+		Delay:    time.Second,
+		Clock:    clock,
+		Strategy: &Escalating{
+			Active: func(channel int) bool { return channel == 6 },
+			Max:    time.Minute,
+		},
+		OnCycle: stopAfter(3),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	stats := h.Stats()
+	if want, got := 3*time.Second, stats.Channels[1].Dwell; want != got {
+		t.Fatalf("dwell on 1:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 14*time.Second, stats.Channels[6].Dwell; want != got {
+		t.Fatalf("dwell on 6:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+// starving is a Strategy that never visits the last channel of the plan.
+type starving struct{}
+
+func (starving) Rotation(channels []int) ([]int, error) {
+	return channels[:len(channels)-1], nil
+}
+
+func TestRunMaxGap(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		t.Run(fmt.Sprintf("force=%v", force), func(t *testing.T) {
+			clock := NewSimulatedClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+			var gaps []time.Duration
+			r := &recorder{}
+			h, err := New(r, Config{
+				Channels: []int{1, 6, 11},
+				Delay:    100 * time.Millisecond,
+				Clock:    clock,
+				Strategy: starving{},
+				OnCycle:  stopAfter(4),
+				MaxGap:   350 * time.Millisecond,
+				OnStarved: func(channel int, gap time.Duration) {
+					if channel != 11 {
+						t.Errorf("channel %v starved", channel)
+					}
+					gaps = append(gaps, gap)
+				},
+				ForceVisits: force,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := h.Run(context.Background()); err != errStop {
+				t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+			}
+			stats := h.Stats()
+			if !force {
+				// Reported once per gap
+				if want := []time.Duration{400 * time.Millisecond}; !reflect.DeepEqual(want, gaps) {
+					t.Fatalf("gaps:\n- want: %v\n-  got: %v", want, gaps)
+				}
+				if stats.Starved != 1 || stats.Channels[11].Hops != 0 {
+					t.Fatalf("starved %v times, %v visits of 11", stats.Starved, stats.Channels[11].Hops)
+				}
+				return
+			}
+
+			if want := []int{1, 6, 1, 6, 11, 1, 6, 1, 11, 6}; !reflect.DeepEqual(want, r.channels) {
+				t.Fatalf("channels:\n- want: %v\n-  got: %v", want, r.channels)
+			}
+			if stats.Starved != 2 || stats.Channels[11].Starved != 2 {
+				t.Fatalf("starved %v times, 11 %v times", stats.Starved, stats.Channels[11].Starved)
+			}
+			if want, got := 400*time.Millisecond, stats.Channels[11].LongestGap; want != got {
+				t.Fatalf("longest gap of 11:\n- want: %v\n-  got: %v", want, got)
+			}
+			if want, got := 300*time.Millisecond, stats.Channels[6].LongestGap; want != got {
+				t.Fatalf("longest gap of 6:\n- want: %v\n-  got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestShuffled(t *testing.T) {
+	channels := []int{1, 6, 11, 36, 40, 44}
+	a := Shuffled{Rand: rand.New(rand.NewSource(42))}
+	b := Shuffled{Rand: rand.New(rand.NewSource(42))}
+	for i := 0; i < 3; i++ {
+		got, _ := a.Rotation(channels)
+		want, _ := b.Rotation(channels)
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("Rotation #%v with the same seed:\n- want: %v\n-  got: %v", i, want, got)
+		}
+		sorted := append([]int(nil), got...)
+		sort.Ints(sorted)
+		if !reflect.DeepEqual(channels, sorted) {
+			t.Fatalf("Rotation #%v is not a permutation: %v", i, got)
+		}
+	}
+	if !reflect.DeepEqual([]int{1, 6, 11, 36, 40, 44}, channels) {
+		t.Fatalf("Rotation modified the plan: %v", channels)
+	}
+}
+
+func TestFocus(t *testing.T) {
+	activity := map[int]float64{1: 5, 6: 20, 11: 1}
+	f := &Focus{
+		Activity: func(channel int) float64 { return activity[channel] },
+		Top:      2,
+		Sweep:    10 * time.Millisecond,
+		Every:    2,
+	}
+
+	channels := []int{1, 6, 11, 36}
+	delay := 100 * time.Millisecond
+	steps := []struct {
+		channels []int
+		rotation []int
+		dwell    time.Duration
+	}{
+		{channels, []int{1, 6, 11, 36}, 10 * time.Millisecond},
+		{channels, []int{6, 1}, delay},
+		{channels, []int{6, 1}, delay},
+		// Sweep again after Every focused cycles
+		{channels, []int{1, 6, 11, 36}, 10 * time.Millisecond},
+		{channels, []int{6, 1}, delay},
+		// A new plan starts with a sweep
+		{[]int{11, 36}, []int{11, 36}, 10 * time.Millisecond},
+		{[]int{11, 36}, []int{11}, delay},
+	}
+	for i, step := range steps {
+		got, err := f.Rotation(step.channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(step.rotation, got) {
+			t.Fatalf("Rotation #%v:\n- want: %v\n-  got: %v", i, step.rotation, got)
+		}
+		if got := f.Dwell(got[0], delay); got != step.dwell {
+			t.Fatalf("Dwell #%v:\n- want: %v\n-  got: %v", i, step.dwell, got)
+		}
+	}
+
+	// Without activity the whole plan is kept
+	f = &Focus{Activity: func(int) float64 { return 0 }, Top: 2, Every: 1}
+	f.Rotation(channels)
+	if got, _ := f.Rotation(channels); !reflect.DeepEqual(channels, got) {
+		t.Fatalf("Rotation without activity:\n- want: %v\n-  got: %v", channels, got)
+	}
+}
+
+func TestScored(t *testing.T) {
+	scores := []map[int]float64{
+		{1: 1, 6: 9},
+		{1: 9},
+	}
+
+	s := &Scored{
+		Activity: func() (map[int]float64, error) {
+			score := scores[0]
+			scores = scores[1:]
+			return score, nil
+		},
+		Every: 2,
+	}
+
+	channels := []int{1, 6}
+	for i, want := range [][]int{{6, 1, 6, 6}, {6, 1, 6, 6}, {1, 6, 1, 1}} {
+		got, err := s.Rotation(channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("Rotation #%v:\n- want: %v\n-  got: %v", i, want, got)
+		}
+	}
+}
+
+func TestRunPlanChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(h *Hopper) error
+		output []int
+	}{
+		{
+			name:   "add",
+			change: func(h *Hopper) error { h.AddChannel(13); return nil },
+			output: []int{1, 6, 11, 13, 1, 6, 11, 13},
+		},
+		{
+			name:   "remove",
+			change: func(h *Hopper) error { return h.RemoveChannel(6) },
+			output: []int{1, 11, 1, 11},
+		},
+		{
+			name:   "set",
+			change: func(h *Hopper) error { return h.SetChannels([]int{36, 40}) },
+			output: []int{1, 40, 36, 40},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tuner := &recorder{}
+			var h *Hopper
+			var changeErr error
+			hops := 0
+
+			h, err := New(tuner, Config{
+				Channels: []int{1, 6, 11},
+				Delay:    time.Millisecond,
+				OnHop: func(int) {
+					hops++
+					if hops == 1 {
+						changeErr = tt.change(h)
+					}
+				},
+				OnCycle: stopAfter(2),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := h.Run(context.Background()); err != errStop {
+				t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+			}
+			if changeErr != nil {
+				t.Fatal(changeErr)
+			}
+			if want, got := tt.output, tuner.channels; !reflect.DeepEqual(want, got) {
+				t.Fatalf("channels:\n- want: %v\n-  got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestRemoveChannel(t *testing.T) {
+	h, err := New(&recorder{}, Config{Channels: []int{1, 6, 1}, Delay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.RemoveChannel(11); err == nil {
+		t.Fatalf("RemoveChannel(11): expected error")
+	}
+	if err := h.RemoveChannel(1); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []int{6}, h.Channels(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("Channels:\n- want: %v\n-  got: %v", want, got)
+	}
+	if err := h.RemoveChannel(6); err != ErrNoChannels {
+		t.Fatalf("RemoveChannel(6):\n- want: %v\n-  got: %v", ErrNoChannels, err)
+	}
+}
+
+func TestSetDelay(t *testing.T) {
+	h, err := New(&recorder{}, Config{Channels: []int{1}, Delay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.SetDelay(0); err == nil {
+		t.Fatalf("SetDelay(0): expected error")
+	}
+	if err := h.SetDelay(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := time.Second, h.Delay(); want != got {
+		t.Fatalf("Delay:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+// asyncRecorder is an AsyncTuner replying immediately.
+type asyncRecorder struct {
+	recorder
+	async int
+}
+
+func (r *asyncRecorder) SetChannelAsync(channel int) (<-chan error, error) {
+	r.async++
+	reply := make(chan error, 1)
+	reply <- r.SetChannel(channel)
+	return reply, nil
+}
+
+func TestRunAsync(t *testing.T) {
+	tuner := &asyncRecorder{recorder: recorder{fail: 11}}
+
+	h, err := New(tuner, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hopErr *HopError
+	if err := h.Run(context.Background()); !errors.As(err, &hopErr) || hopErr.Channel != 11 {
+		t.Fatalf("Run:\n- want: HopError on 11\n-  got: %v", err)
+	}
+	if want, got := 3, tuner.async; want != got {
+		t.Fatalf("async retunes:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := []int{1, 6}, tuner.channels; !reflect.DeepEqual(want, got) {
+		t.Fatalf("channels:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+// slowTuner takes latency of clock time to retune.
+type slowTuner struct {
+	clock   *FakeClock
+	latency time.Duration
+}
+
+func (t slowTuner) SetChannel(channel int) error {
+	t.clock.Advance(t.latency)
+	return nil
+}
+
+func TestRunVerify(t *testing.T) {
+	clock := NewSimulatedClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	h, err := New(slowTuner{clock: clock, latency: time.Millisecond}, Config{
+		Channels: []int{1, 6, 11},
+		Delay:    time.Second,
+		Clock:    clock,
+		Verify: func(channel int) error {
+			clock.Advance(2 * time.Millisecond)
+			if channel == 11 {
+				return errors.New("radio is on 2437 MHz")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hopErr *HopError
+	if err := h.Run(context.Background()); !errors.As(err, &hopErr) || hopErr.Channel != 11 {
+		t.Fatalf("Run: expected HopError on 11, got %v", err)
+	}
+
+	stats := h.Stats()
+	if stats.Hops != 2 || stats.Failures != 1 {
+		t.Errorf("Stats: %v hops, %v failures", stats.Hops, stats.Failures)
+	}
+	if want, got := time.Millisecond, stats.Latency.Max; want != got {
+		t.Errorf("Latency:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 3*time.Millisecond, stats.Verified.Max; want != got {
+		t.Errorf("Verified:\n- want: %v\n-  got: %v", want, got)
+	}
+	// The dwell starts once the retune is verified
+	if want, got := time.Second, stats.Channels[1].Dwell; want != got {
+		t.Errorf("dwell on 1:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestRunCompensateLatency(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	h, err := New(slowTuner{clock: clock, latency: 10 * time.Millisecond}, Config{
+		Channels:          []int{1, 6, 11},
+		Delay:             100 * time.Millisecond,
+		Clock:             clock,
+		OnCycle:           stopAfter(2),
+		CompensateLatency: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Run(context.Background()); err != errStop {
+		t.Fatalf("Run:\n- want: %v\n-  got: %v", errStop, err)
+	}
+	// Retunes are part of the dwell instead of adding to it
+	if want, got := 600*time.Millisecond, clock.Now().Sub(start); want != got {
+		t.Errorf("simulated time:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 180*time.Millisecond, h.Stats().Channels[6].Dwell; want != got {
+		t.Errorf("dwell on 6:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestCompensate(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{10 * time.Millisecond, 90 * time.Millisecond},
+		{50 * time.Millisecond, 50 * time.Millisecond},
+		{time.Second, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := compensate(100*time.Millisecond, tt.latency); got != tt.want {
+			t.Errorf("compensate(100ms, %v):\n- want: %v\n-  got: %v", tt.latency, tt.want, got)
+		}
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := 0
+	h, err := New(TunerFunc(func(int) error { return nil }), Config{
+		Channels: []int{1, 6, 11, 36},
+		Delay:    100 * time.Millisecond,
+		Clock:    NewSimulatedClock(time.Unix(1633089600, 0)),
+		OnHop: func(int) {
+			n++
+			if n == b.N {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	if err := h.Run(ctx); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkRotation(b *testing.B) {
+	channels := []int{1, 6, 11, 36, 40, 44, 48, 149, 153, 157, 161}
+	strategies := []struct {
+		name     string
+		strategy Strategy
+	}{
+		{"sequential", Sequential{}},
+		{"shuffled", Shuffled{Rand: rand.New(rand.NewSource(1))}},
+		// Ranks the plan every cycle
+		{"scored", &Scored{Activity: func() (map[int]float64, error) {
+			return map[int]float64{6: 10, 36: 1}, nil
+		}}},
+	}
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.strategy.Rotation(channels); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is how many retune latencies are kept for percentiles.
+const latencySamples = 1024
+
+// LatencyBuckets are the upper bounds of the buckets of Stats.Histogram.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// ChannelStats are the counters of a single channel.
+type ChannelStats struct {
+	// Hops is the number of successful retunes to the channel.
+	Hops int
+	// Failures is the number of failed retunes to the channel.
+	Failures int
+	// Dwell is the total time spent on the channel.
+	Dwell time.Duration
+	// LastVisit is when the channel was last tuned to.
+	LastVisit time.Time
+	// LongestGap is the longest time between two visits, and Starved the
+	// number of times it exceeded Config.MaxGap. They are only tracked
+	// with a MaxGap.
+	LongestGap time.Duration
+	Starved    int
+}
+
+// Latency are percentiles of the time taken by the Tuner to retune, over
+// the most recent hops.
+type Latency struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Stats is a snapshot of the hopper counters.
+type Stats struct {
+	Hops     int
+	Failures int
+	// Starved is the number of times a channel was not visited within
+	// Config.MaxGap.
+	Starved  int
+	Channels map[int]ChannelStats
+	// Latency is the time from sending a retune to its acknowledgement.
+	Latency Latency
+	// Verified is the time from sending a retune to Config.Verify
+	// confirming it, zero without Verify.
+	Verified Latency
+	// Histogram counts all the successful hops by Latency: Histogram[i]
+	// is the number of hops within LatencyBuckets[i] and above the
+	// previous bound, the last entry counts the slower ones.
+	Histogram []int
+}
+
+// samples keeps the most recent latencies.
+type samples struct {
+	values []time.Duration
+	next   int
+}
+
+func (s *samples) add(latency time.Duration) {
+	if s.values == nil {
+		s.values = make([]time.Duration, 0, latencySamples)
+	}
+	if len(s.values) < latencySamples {
+		s.values = append(s.values, latency)
+	} else {
+		s.values[s.next] = latency
+		s.next = (s.next + 1) % latencySamples
+	}
+}
+
+func (s *samples) percentiles() Latency {
+	sorted := make([]time.Duration, len(s.values))
+	copy(sorted, s.values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return Latency{
+		P50: percentile(sorted, 50),
+		P90: percentile(sorted, 90),
+		P99: percentile(sorted, 99),
+		Max: percentile(sorted, 100),
+	}
+}
+
+// stats collects the counters returned by Hopper.Stats.
+type stats struct {
+	mu        sync.Mutex
+	hops      int
+	failures  int
+	starved   int
+	channels  map[int]*ChannelStats
+	latencies samples
+	verified  samples
+	histogram []int
+}
+
+func (s *stats) channel(channel int) *ChannelStats {
+	if s.channels == nil {
+		s.channels = make(map[int]*ChannelStats)
+	}
+
+	c, ok := s.channels[channel]
+	if !ok {
+		c = &ChannelStats{}
+		s.channels[channel] = c
+	}
+	return c
+}
+
+// hop records a retune attempt that started at start and took latency.
+func (s *stats) hop(channel int, start time.Time, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.channel(channel)
+	if err != nil {
+		s.failures++
+		c.Failures++
+		return
+	}
+
+	s.hops++
+	c.Hops++
+	c.LastVisit = start
+
+	s.latencies.add(latency)
+	if s.histogram == nil {
+		s.histogram = make([]int, len(LatencyBuckets)+1)
+	}
+	bucket := 0
+	for bucket < len(LatencyBuckets) && latency > LatencyBuckets[bucket] {
+		bucket++
+	}
+	s.histogram[bucket]++
+}
+
+// verify records the time taken by a retune until it was verified.
+func (s *stats) verify(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.verified.add(latency)
+}
+
+// gap records the time between two visits of channel.
+func (s *stats) gap(channel int, gap time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c := s.channel(channel); gap > c.LongestGap {
+		c.LongestGap = gap
+	}
+}
+
+// starve records a channel unvisited for more than Config.MaxGap.
+func (s *stats) starve(channel int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.starved++
+	s.channel(channel).Starved++
+}
+
+func (s *stats) dwell(channel int, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channel(channel).Dwell += d
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := Stats{
+		Hops:     s.hops,
+		Failures: s.failures,
+		Starved:  s.starved,
+		Channels: make(map[int]ChannelStats, len(s.channels)),
+	}
+	for channel, c := range s.channels {
+		ret.Channels[channel] = *c
+	}
+
+	ret.Latency = s.latencies.percentiles()
+	ret.Verified = s.verified.percentiles()
+	ret.Histogram = make([]int, len(LatencyBuckets)+1)
+	copy(ret.Histogram, s.histogram)
+
+	return ret
+}
+
+// Stats returns the counters of the hopper. It is safe to call while
+// running.
+func (h *Hopper) Stats() Stats {
+	return h.stats.snapshot()
+}
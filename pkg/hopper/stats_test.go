@@ -0,0 +1,160 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	h, err := New(&recorder{fail: 11}, Config{
+		Channels: []int{1, 6, 1, 11},
+		Delay:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hopErr *HopError
+	if err := h.Run(context.Background()); !errors.As(err, &hopErr) {
+		t.Fatalf("Run: expected HopError, got %v", err)
+	}
+
+	stats := h.Stats()
+	if want, got := 3, stats.Hops; want != got {
+		t.Fatalf("Hops:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 1, stats.Failures; want != got {
+		t.Fatalf("Failures:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	tests := []struct {
+		channel  int
+		hops     int
+		failures int
+	}{
+		{1, 2, 0},
+		{6, 1, 0},
+		{11, 0, 1},
+	}
+	for _, tt := range tests {
+		c := stats.Channels[tt.channel]
+		if c.Hops != tt.hops || c.Failures != tt.failures {
+			t.Fatalf("Channels[%v]:\n- want: %v hops, %v failures\n-  got: %v hops, %v failures", tt.channel, tt.hops, tt.failures, c.Hops, c.Failures)
+		}
+		if tt.hops > 0 && (c.Dwell < time.Duration(tt.hops)*time.Millisecond || c.LastVisit.IsZero()) {
+			t.Fatalf("Channels[%v]: dwell %v, last visit %v", tt.channel, c.Dwell, c.LastVisit)
+		}
+	}
+
+	if stats.Latency.P50 > stats.Latency.P99 || stats.Latency.P99 > stats.Latency.Max {
+		t.Fatalf("Latency: percentiles out of order: %+v", stats.Latency)
+	}
+}
+
+func TestStatsHistogram(t *testing.T) {
+	var s stats
+	for _, latency := range []time.Duration{
+		50 * time.Microsecond,
+		100 * time.Microsecond,
+		101 * time.Microsecond,
+		3 * time.Millisecond,
+		time.Second,
+	} {
+		s.hop(1, time.Time{}, latency, nil)
+	}
+	s.hop(1, time.Time{}, time.Millisecond, errors.New("failed"))
+
+	want := make([]int, len(LatencyBuckets)+1)
+	want[0] = 2
+	want[1] = 1
+	want[5] = 1
+	want[len(LatencyBuckets)] = 1
+	if got := s.snapshot().Histogram; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Histogram:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+
+	tests := []struct {
+		p    int
+		want time.Duration
+	}{
+		{50, 50},
+		{90, 90},
+		{99, 99},
+		{100, 100},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Fatalf("percentile(1..100, %v):\n- want: %v\n-  got: %v", tt.p, tt.want, got)
+		}
+	}
+
+	if got := percentile(nil, 50); got != 0 {
+		t.Fatalf("percentile(nil, 50): %v", got)
+	}
+}
+
+func TestStatsHopAllocations(t *testing.T) {
+	var s stats
+	start := time.Unix(1633089600, 0)
+	// Fill the latency samples, then recording a hop must not allocate
+	for i := 0; i < latencySamples; i++ {
+		s.hop(6, start, time.Millisecond, nil)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		s.hop(6, start, time.Millisecond, nil)
+		s.verify(2 * time.Millisecond)
+		s.dwell(6, 100*time.Millisecond)
+	})
+	if allocs > 0 {
+		t.Fatalf("recording a hop allocated %v times", allocs)
+	}
+}
+
+func BenchmarkStatsHop(b *testing.B) {
+	var s stats
+	start := time.Unix(1633089600, 0)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.hop(6, start, time.Duration(i%1000)*time.Microsecond, nil)
+	}
+}
+
+func BenchmarkStatsSnapshot(b *testing.B) {
+	var s stats
+	start := time.Unix(1633089600, 0)
+	for i := 0; i < latencySamples; i++ {
+		s.hop(i%11+1, start, time.Duration(i)*time.Microsecond, nil)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = s.snapshot()
+	}
+}
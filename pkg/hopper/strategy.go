@@ -0,0 +1,368 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hopper
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+)
+
+// Strategy decides the order in which channels are visited.
+type Strategy interface {
+	// Rotation is called before every cycle and returns the channels to
+	// visit during it, given the plan. It must not modify channels, which
+	// is shared with the Hopper.
+	Rotation(channels []int) ([]int, error)
+}
+
+// DwellStrategy is a Strategy that also decides how long to stay on each
+// channel, for strategies whose dwell depends on their rotation. When the
+// Strategy of a Hopper implements it and Config.Dwell is nil, it is used as
+// the DwellController.
+type DwellStrategy interface {
+	Strategy
+	Dwell(channel int, delay time.Duration) time.Duration
+}
+
+// Sequential visits the channels in plan order.
+type Sequential struct{}
+
+// Rotation returns channels unchanged.
+func (Sequential) Rotation(channels []int) ([]int, error) {
+	return channels, nil
+}
+
+// Shuffled visits the channels in a new random order every cycle. Hoppers
+// sharing the plan and the seed of Rand visit the same orders.
+type Shuffled struct {
+	Rand *rand.Rand
+}
+
+// Rotation returns a shuffled copy of channels.
+func (s Shuffled) Rotation(channels []int) ([]int, error) {
+	rotation := append([]int(nil), channels...)
+	s.Rand.Shuffle(len(rotation), func(i, j int) {
+		rotation[i], rotation[j] = rotation[j], rotation[i]
+	})
+	return rotation, nil
+}
+
+// surveyRanking weighs the plan with a score computed from the survey
+// counters of the radio, for Ranked and Quietest.
+type surveyRanking struct {
+	started  bool
+	cycles   int
+	last     []nl80211util.SurveyInfo
+	plan     []int
+	rotation []int
+}
+
+// surveyScore scores channels, higher is visited more often, given the
+// previous and the current survey.
+type surveyScore func(channels []int, last []nl80211util.SurveyInfo, current []nl80211util.SurveyInfo) map[int]float64
+
+// rank takes a baseline survey on the first call, then re-ranks the channels
+// with score every every cycles or when the plan changes.
+func (r *surveyRanking) rank(channels []int, survey func() ([]nl80211util.SurveyInfo, error), every int, score surveyScore) ([]int, error) {
+	if !r.started {
+		current, err := survey()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get survey: %v", err)
+		}
+
+		r.started = true
+		r.last = current
+		r.plan = channels
+		r.rotation = channels
+		return channels, nil
+	}
+
+	if reflect.DeepEqual(channels, r.plan) {
+		r.cycles++
+		if every > 1 && r.cycles%every != 0 {
+			return r.rotation, nil
+		}
+	}
+
+	current, err := survey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get survey: %v", err)
+	}
+
+	r.rotation = plan.Weighted(channels, score(channels, r.last, current))
+	r.plan = channels
+	r.last = current
+	return r.rotation, nil
+}
+
+// Ranked reorders the plan by observed activity and visits busy channels
+// more often, according to plan.Weighted.
+type Ranked struct {
+	// Survey returns the current survey counters of the radio.
+	Survey func() ([]nl80211util.SurveyInfo, error)
+	// Every is the number of cycles between re-rankings.
+	Every int
+
+	ranking surveyRanking
+}
+
+// Rotation takes a baseline survey on the first call, then re-ranks the
+// channels every r.Every cycles or when the plan changes.
+func (r *Ranked) Rotation(channels []int) ([]int, error) {
+	return r.ranking.rank(channels, r.Survey, r.Every, surveyActivity)
+}
+
+// surveyActivity scores channels by their busy ratio since the last survey.
+func surveyActivity(channels []int, last []nl80211util.SurveyInfo, current []nl80211util.SurveyInfo) map[int]float64 {
+	byFrequency := nl80211util.Activity(last, current)
+	activity := make(map[int]float64, len(channels))
+	for _, channel := range channels {
+		if value, ok := byFrequency[plan.Frequency(channel)]; ok {
+			activity[channel] = value
+		}
+	}
+	return activity
+}
+
+// Noise floors bounding the quietness of a channel, in dBm. Channels at
+// quietNoise or below are not penalized, channels at loudNoise or above
+// are as bad as fully busy ones.
+const (
+	quietNoise = -95
+	loudNoise  = -60
+)
+
+// quietness returns how quiet a channel is, from 0 to 1, given its busy
+// ratio and noise floor. A noise of 0 is unknown.
+func quietness(busy float64, noise int) float64 {
+	q := 1 - busy
+	if noise != 0 && noise > quietNoise {
+		q *= float64(loudNoise-noise) / float64(loudNoise-quietNoise)
+	}
+	if q < 0 {
+		return 0
+	}
+	return q
+}
+
+// Quietest reorders the plan by survey busy time and noise floor and visits
+// the quietest channels first and more often, to help picking a channel for
+// a link rather than capturing traffic.
+type Quietest struct {
+	// Survey returns the current survey counters of the radio.
+	Survey func() ([]nl80211util.SurveyInfo, error)
+	// Every is the number of cycles between re-rankings.
+	Every int
+
+	ranking surveyRanking
+}
+
+// Rotation takes a baseline survey on the first call, then re-ranks the
+// channels every q.Every cycles or when the plan changes.
+func (q *Quietest) Rotation(channels []int) ([]int, error) {
+	return q.ranking.rank(channels, q.Survey, q.Every, surveyQuietness)
+}
+
+// surveyQuietness scores channels by their quietness since the last survey.
+func surveyQuietness(channels []int, last []nl80211util.SurveyInfo, current []nl80211util.SurveyInfo) map[int]float64 {
+	byFrequency := nl80211util.Activity(last, current)
+	noise := make(map[int]int, len(current))
+	for _, info := range current {
+		noise[info.Frequency] = info.Noise
+	}
+	quiet := make(map[int]float64, len(channels))
+	for _, channel := range channels {
+		frequency := plan.Frequency(channel)
+		if busy, ok := byFrequency[frequency]; ok {
+			quiet[channel] = quietness(busy, noise[frequency])
+		}
+	}
+	return quiet
+}
+
+// Scored visits busy channels more often, like Ranked, with the activity of
+// each channel reported by an external source such as a Kismet server.
+type Scored struct {
+	// Activity returns a score for each channel, higher is busier.
+	Activity func() (map[int]float64, error)
+	// Every is the number of cycles between re-rankings.
+	Every int
+
+	started  bool
+	cycles   int
+	plan     []int
+	rotation []int
+}
+
+// Rotation ranks the channels on the first call, then every s.Every cycles
+// or when the plan changes.
+func (s *Scored) Rotation(channels []int) ([]int, error) {
+	if s.started && reflect.DeepEqual(channels, s.plan) {
+		s.cycles++
+		if s.Every > 1 && s.cycles%s.Every != 0 {
+			return s.rotation, nil
+		}
+	}
+
+	activity, err := s.Activity()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get activity: %v", err)
+	}
+
+	s.started = true
+	s.rotation = plan.Weighted(channels, activity)
+	s.plan = channels
+	return s.rotation, nil
+}
+
+// Escalating visits the channels in plan order and doubles the dwell on a
+// channel on every consecutive visit after which Active reports traffic, up
+// to Max. It halves it back to the delay when the channel is quiet, so that
+// attention converges onto live channels.
+type Escalating struct {
+	// Active reports whether the last visit to channel had traffic above
+	// the threshold.
+	Active func(channel int) bool
+	// Max caps the dwell, no escalation happens if it is not above the
+	// delay.
+	Max time.Duration
+
+	factors map[int]int
+}
+
+// Rotation returns channels unchanged.
+func (e *Escalating) Rotation(channels []int) ([]int, error) {
+	return channels, nil
+}
+
+// Dwell returns delay multiplied by the current factor of the channel.
+func (e *Escalating) Dwell(channel int, delay time.Duration) time.Duration {
+	if e.factors == nil {
+		e.factors = make(map[int]int)
+	}
+
+	factor := e.factors[channel]
+	if factor == 0 {
+		factor = 1
+	}
+	if e.Active(channel) {
+		if delay*time.Duration(factor) < e.Max {
+			factor *= 2
+		}
+	} else if factor > 1 {
+		factor /= 2
+	}
+	e.factors[channel] = factor
+
+	dwell := delay * time.Duration(factor)
+	if dwell > e.Max && e.Max > delay {
+		return e.Max
+	}
+	return dwell
+}
+
+// Focus alternates between a fast sweep of the whole plan, with a dwell of
+// Sweep, and a slower rotation at the normal delay over the Top channels
+// with the most activity during the sweep. The sweep is repeated every
+// Every focused cycles, or when the plan changes, to catch new networks.
+type Focus struct {
+	// Activity returns the activity of the last visit to channel, higher
+	// is busier.
+	Activity func(channel int) float64
+	// Top is the number of channels to focus on.
+	Top int
+	// Sweep is the dwell during sweeps.
+	Sweep time.Duration
+	// Every is the number of focused cycles between sweeps.
+	Every int
+
+	started  bool
+	sweeping bool
+	cycles   int
+	plan     []int
+	rotation []int
+}
+
+// Rotation returns the whole plan for a sweep, then the busiest channels
+// once it is over.
+func (f *Focus) Rotation(channels []int) ([]int, error) {
+	if !f.started || !reflect.DeepEqual(channels, f.plan) {
+		f.started = true
+		return f.sweep(channels), nil
+	}
+
+	if f.sweeping {
+		f.sweeping = false
+		f.cycles = 0
+		f.rotation = f.busiest(channels)
+		return f.rotation, nil
+	}
+
+	f.cycles++
+	if f.cycles >= f.Every {
+		return f.sweep(channels), nil
+	}
+	return f.rotation, nil
+}
+
+func (f *Focus) sweep(channels []int) []int {
+	f.sweeping = true
+	f.plan = channels
+	f.rotation = channels
+	return channels
+}
+
+// busiest returns the Top channels with activity, busiest first, or the
+// whole plan if none had any.
+func (f *Focus) busiest(channels []int) []int {
+	activity := make(map[int]float64, len(channels))
+	active := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		if _, ok := activity[channel]; ok {
+			continue
+		}
+		activity[channel] = f.Activity(channel)
+		if activity[channel] > 0 {
+			active = append(active, channel)
+		}
+	}
+	if len(active) == 0 {
+		return channels
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		return activity[active[i]] > activity[active[j]]
+	})
+	if f.Top > 0 && len(active) > f.Top {
+		active = active[:f.Top]
+	}
+	return active
+}
+
+// Dwell returns Sweep during sweeps and delay otherwise.
+func (f *Focus) Dwell(channel int, delay time.Duration) time.Duration {
+	if f.sweeping && f.Sweep > 0 {
+		return f.Sweep
+	}
+	return delay
+}
@@ -0,0 +1,85 @@
+//go:build !nohttp
+// +build !nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWriterHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Token secret" {
+			t.Errorf("Authorization: %q", auth)
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	w, err := Open(server.URL+"/api/v2/write?org=o&bucket=b", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Server errors keep the points for the next flush
+	status = http.StatusServiceUnavailable
+	_ = w.Write(point(1))
+	if err := w.Flush(context.Background()); err == nil {
+		t.Fatal("no error on 503")
+	}
+	status = http.StatusNoContent
+	_ = w.Write(point(2))
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rejected points are dropped
+	status = http.StatusBadRequest
+	_ = w.Write(point(3))
+	if err := w.Flush(context.Background()); err == nil {
+		t.Fatal("no error on 400")
+	}
+	status = http.StatusNoContent
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"m v=1i\n", "m v=1i\nm v=2i\n", "m v=3i\n"}
+	if len(bodies) != len(want) {
+		t.Fatalf("requests:\n- want: %q\n-  got: %q", want, bodies)
+	}
+	for i := range want {
+		if bodies[i] != want[i] {
+			t.Fatalf("requests:\n- want: %q\n-  got: %q", want, bodies)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package influx writes measurements in the InfluxDB line protocol to a
+// file, a UDP listener or the InfluxDB HTTP write API.
+package influx
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point is a single measurement. Field values are int, int64, uint64,
+// float64, bool or string. A zero Time lets the server set it.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// Line returns the point in line protocol, without the trailing newline.
+// Tags and fields are sorted by key, tags with an empty value are left out
+// as InfluxDB rejects them.
+func (p Point) Line() (string, error) {
+	if p.Measurement == "" {
+		return "", fmt.Errorf("point has no measurement")
+	}
+	if len(p.Fields) == 0 {
+		return "", fmt.Errorf("point %v has no fields", p.Measurement)
+	}
+
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+	for _, key := range sortedKeys(p.Tags) {
+		if p.Tags[key] == "" {
+			continue
+		}
+		b.WriteString(",")
+		b.WriteString(keyEscaper.Replace(key))
+		b.WriteString("=")
+		b.WriteString(keyEscaper.Replace(p.Tags[key]))
+	}
+
+	keys := make([]string, 0, len(p.Fields))
+	for key := range p.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(",")
+		}
+		value, err := formatField(p.Fields[key])
+		if err != nil {
+			return "", fmt.Errorf("field %v of %v: %v", key, p.Measurement, err)
+		}
+		b.WriteString(keyEscaper.Replace(key))
+		b.WriteString("=")
+		b.WriteString(value)
+	}
+
+	if !p.Time.IsZero() {
+		b.WriteString(" ")
+		b.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+	}
+	return b.String(), nil
+}
+
+func formatField(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i", nil
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", nil
+	case uint64:
+		// InfluxDB 1.x rejects unsigned integers by default
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10) + "u", nil
+		}
+		return strconv.FormatUint(v, 10) + "i", nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("invalid value %v", v)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`, nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
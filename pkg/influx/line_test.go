@@ -0,0 +1,68 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influx
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLine(t *testing.T) {
+	at := time.Unix(1633089600, 5)
+	tests := []struct {
+		name   string
+		point  Point
+		output string
+		err    bool
+	}{
+		{
+			"hop",
+			Point{"chopper_hop", map[string]string{"interface": "wlan0mon", "channel": "6"}, map[string]interface{}{"frequency": 2437}, at},
+			"chopper_hop,channel=6,interface=wlan0mon frequency=2437i 1633089600000000005",
+			false,
+		},
+		{
+			"types",
+			Point{"m", nil, map[string]interface{}{"a": 1.5, "b": true, "c": "x", "d": uint64(7), "e": int64(-2)}, time.Time{}},
+			`m a=1.5,b=true,c="x",d=7i,e=-2i`,
+			false,
+		},
+		{
+			"escaping",
+			Point{"my m,x", map[string]string{"ssid": "my wifi,=", "empty": ""}, map[string]interface{}{"msg": `say "hi" \o/`}, time.Time{}},
+			`my\ m\,x,ssid=my\ wifi\,\= msg="say \"hi\" \\o/"`,
+			false,
+		},
+		{"no fields", Point{"m", nil, nil, at}, "", true},
+		{"no measurement", Point{"", nil, map[string]interface{}{"a": 1}, at}, "", true},
+		{"unsupported", Point{"m", nil, map[string]interface{}{"a": []int{1}}, at}, "", true},
+		{"nan", Point{"m", nil, map[string]interface{}{"a": math.NaN()}, at}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.point.Line()
+			if (err != nil) != tt.err {
+				t.Fatalf("Line():\n- want error: %v\n-  got: %v", tt.err, err)
+			}
+			if got != tt.output {
+				t.Fatalf("Line():\n- want: %v\n-  got: %v", tt.output, got)
+			}
+		})
+	}
+}
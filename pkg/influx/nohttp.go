@@ -0,0 +1,31 @@
+//go:build nohttp
+// +build nohttp
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"errors"
+)
+
+// httpSender fails in builds without HTTP support, files and UDP still
+// work.
+func httpSender(url, token string) (func(ctx context.Context, lines []string) error, error) {
+	return nil, errors.New("the HTTP API is not supported by this build (-tags nohttp)")
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDatagram is the largest UDP payload sent, small enough to avoid IP
+// fragmentation on most links.
+const maxDatagram = 1400
+
+// Writer buffers points and sends them in batches. It is safe for
+// concurrent use.
+type Writer struct {
+	send  func(ctx context.Context, lines []string) error
+	close func() error
+
+	// maxPending is the number of lines kept while the destination is
+	// unreachable, older lines are dropped first.
+	maxPending int
+
+	mu      sync.Mutex
+	pending []string
+	dropped int
+}
+
+// Open returns a Writer for the destination in rawurl:
+//
+//	file:///var/log/chopper.lp (or a plain path) appends to a file
+//	udp://localhost:8089 sends datagrams to a UDP listener
+//	http://localhost:8086/api/v2/write?org=o&bucket=b posts to the HTTP API
+//
+// token is sent as "Authorization: Token <token>" to the HTTP API if set.
+func Open(rawurl, token string) (*Writer, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{maxPending: 100000}
+	switch u.Scheme {
+	case "", "file":
+		path := u.Path
+		if u.Scheme == "" {
+			path = rawurl
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w.send = func(_ context.Context, lines []string) error {
+			_, err := io.WriteString(f, strings.Join(lines, "\n")+"\n")
+			return err
+		}
+		w.close = f.Close
+	case "udp":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		w.send = func(_ context.Context, lines []string) error {
+			return sendDatagrams(conn, lines)
+		}
+		w.close = conn.Close
+	case "http", "https":
+		send, err := httpSender(u.String(), token)
+		if err != nil {
+			return nil, err
+		}
+		w.send = send
+		w.close = func() error { return nil }
+	default:
+		return nil, fmt.Errorf("unsupported scheme %v", u.Scheme)
+	}
+	return w, nil
+}
+
+// Write queues points. It never blocks on the destination. Points that
+// cannot be encoded are reported and not queued.
+func (w *Writer) Write(points ...Point) error {
+	lines := make([]string, 0, len(points))
+	for _, point := range points {
+		line, err := point.Line()
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, lines...)
+	if extra := len(w.pending) - w.maxPending; extra > 0 {
+		w.pending = w.pending[extra:]
+		w.dropped += extra
+	}
+	return nil
+}
+
+// Flush sends the queued points. They are kept for the next flush if
+// sending fails, unless the server rejected them.
+func (w *Writer) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	lines := w.pending
+	w.pending = nil
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+
+	if len(lines) == 0 {
+		if dropped > 0 {
+			return fmt.Errorf("dropped %v points", dropped)
+		}
+		return nil
+	}
+
+	err := w.send(ctx, lines)
+	var rejected *rejectedError
+	if err != nil && !errors.As(err, &rejected) {
+		w.mu.Lock()
+		w.pending = append(lines, w.pending...)
+		if extra := len(w.pending) - w.maxPending; extra > 0 {
+			w.pending = w.pending[extra:]
+			dropped += extra
+		}
+		w.mu.Unlock()
+	}
+	if dropped > 0 {
+		if err != nil {
+			return fmt.Errorf("%v (dropped %v points)", err, dropped)
+		}
+		return fmt.Errorf("dropped %v points", dropped)
+	}
+	return err
+}
+
+// Run flushes the queued points every interval until ctx is done, then
+// flushes once more for up to 10 seconds. Errors are passed to onError if
+// not nil.
+func (w *Writer) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			report(w.Flush(final))
+			cancel()
+			return
+		case <-ticker.C:
+			if err := w.Flush(ctx); ctx.Err() == nil {
+				report(err)
+			}
+		}
+	}
+}
+
+// Close closes the destination. Queued points that were not flushed are
+// lost.
+func (w *Writer) Close() error {
+	return w.close()
+}
+
+// sendDatagrams packs lines into datagrams of up to maxDatagram bytes.
+func sendDatagrams(conn net.Conn, lines []string) error {
+	var buf []byte
+	for _, line := range lines {
+		if len(buf) > 0 && len(buf)+len(line)+1 > maxDatagram {
+			if _, err := conn.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	if len(buf) > 0 {
+		_, err := conn.Write(buf)
+		return err
+	}
+	return nil
+}
+
+// rejectedError is returned when the server refuses points, which are then
+// dropped instead of being retried.
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string {
+	return "points rejected: " + e.err.Error()
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func point(value int) Point {
+	return Point{Measurement: "m", Fields: map[string]interface{}{"v": value}}
+}
+
+func TestWriterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.lp")
+	w, err := Open(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := w.Write(point(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "m v=1i\nm v=2i\n"; string(data) != want {
+		t.Fatalf("file:\n- want: %q\n-  got: %q", want, data)
+	}
+}
+
+func TestWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := Open("udp://"+conn.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 300 lines of 7 bytes do not fit in a single datagram
+	for i := 100; i < 400; i++ {
+		_ = w.Write(point(i))
+	}
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines int
+	buf := make([]byte, 65536)
+	for lines < 300 {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > maxDatagram {
+			t.Fatalf("datagram of %v bytes", n)
+		}
+		lines += strings.Count(string(buf[:n]), "\n")
+	}
+	if lines != 300 {
+		t.Fatalf("lines:\n- want: 300\n-  got: %v", lines)
+	}
+}
+
+func TestWriterMaxPending(t *testing.T) {
+	w := &Writer{maxPending: 2, send: func(_ context.Context, lines []string) error {
+		if len(lines) != 2 || lines[0] != "m v=2i" {
+			t.Errorf("lines: %q", lines)
+		}
+		return nil
+	}}
+	for i := 1; i <= 3; i++ {
+		_ = w.Write(point(i))
+	}
+	if err := w.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "dropped 1 points") {
+		t.Fatalf("Flush: %v", err)
+	}
+}
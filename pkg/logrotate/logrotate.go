@@ -0,0 +1,175 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package logrotate implements an io.Writer to a file that is rotated by
+// size and age, keeping a bounded number of old files.
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to rotated files. It sorts lexicographically.
+const backupTimeFormat = "20060102T150405.000"
+
+// Options configures when a Writer rotates.
+type Options struct {
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// disables size-based rotation.
+	MaxSize int64
+	// MaxAge is how long a file is written to before being rotated. Zero
+	// disables time-based rotation.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files to keep. Zero keeps all.
+	MaxBackups int
+}
+
+// Writer writes to a file, rotating it according to its Options. It is safe
+// for concurrent use.
+type Writer struct {
+	path    string
+	options Options
+	now     func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// Open opens (or creates) the file at path for appending.
+func Open(path string, options Options) (*Writer, error) {
+	return openWithClock(path, options, time.Now)
+}
+
+func openWithClock(path string, options Options, now func() time.Time) (*Writer, error) {
+	w := &Writer{
+		path:    path,
+		options: options,
+		now:     now,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.opened = w.now()
+	return nil
+}
+
+func (w *Writer) shouldRotate(n int) bool {
+	if w.options.MaxSize > 0 && w.size > 0 && w.size+int64(n) > w.options.MaxSize {
+		return true
+	}
+	if w.options.MaxAge > 0 && w.now().Sub(w.opened) >= w.options.MaxAge {
+		return true
+	}
+
+	return false
+}
+
+// Write writes p to the current file, rotating it first if needed. A
+// single write is never split across files.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate forces a rotation of the current file.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rotate()
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s", w.path, w.now().UTC().Format(backupTimeFormat))
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	return w.prune()
+}
+
+// prune removes the oldest backups beyond MaxBackups.
+func (w *Writer) prune() error {
+	if w.options.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+
+	for len(backups) > w.options.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}
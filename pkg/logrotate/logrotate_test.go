@@ -0,0 +1,115 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logrotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeNow returns a clock that advances by step on every call.
+func fakeNow(step time.Duration) func() time.Time {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func openTest(t *testing.T, options Options, step time.Duration) (*Writer, string) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "hops.log")
+	w, err := openWithClock(path, options, fakeNow(step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	return w, path
+}
+
+func TestRotateBySize(t *testing.T) {
+	w, path := openTest(t, Options{MaxSize: 10, MaxBackups: 2}, time.Second)
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("12345678\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(backups); want != got {
+		t.Fatalf("backups:\n- want: %v\n-  got: %v (%v)", want, got, backups)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "12345678\n", string(b); want != got {
+		t.Fatalf("current file:\n- want: %q\n-  got: %q", want, got)
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	w, path := openTest(t, Options{MaxAge: time.Hour}, 40*time.Minute)
+
+	// The clock advances 40 minutes per call (opening included), the
+	// second write happens 80 minutes after opening.
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("x\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(backups); want != got {
+		t.Fatalf("backups:\n- want: %v\n-  got: %v (%v)", want, got, backups)
+	}
+}
+
+func TestNoRotation(t *testing.T) {
+	w, path := openTest(t, Options{}, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write([]byte("x\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, len(backups); want != got {
+		t.Fatalf("backups:\n- want: %v\n-  got: %v", want, got)
+	}
+}
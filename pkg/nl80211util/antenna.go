@@ -0,0 +1,92 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+// Antenna holds a pair of antenna bitmasks, one bit per antenna chain.
+type Antenna struct {
+	TX uint32
+	RX uint32
+}
+
+func (a Antenna) String() string {
+	return fmt.Sprintf("tx=%#x,rx=%#x", a.TX, a.RX)
+}
+
+// Antennas returns the antennas in use by the radio and the ones it has.
+func (c *Client) Antennas(phy int) (current Antenna, available Antenna, err error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrWiphy, uint32(phy))
+	data, err := ae.Encode()
+	if err != nil {
+		return Antenna{}, Antenna{}, err
+	}
+
+	msgs, err := c.execute(nl80211.CommandGetWiphy, netlink.Request, data)
+	if err != nil {
+		return Antenna{}, Antenna{}, err
+	}
+	if len(msgs) == 0 {
+		return Antenna{}, Antenna{}, fmt.Errorf("no reply for phy%d", phy)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(msgs[0].Data)
+	if err != nil {
+		return Antenna{}, Antenna{}, err
+	}
+	current, available = parseAntennas(ad)
+	return current, available, ad.Err()
+}
+
+func parseAntennas(ad *netlink.AttributeDecoder) (current Antenna, available Antenna) {
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrWiphyAntennaTx:
+			current.TX = ad.Uint32()
+		case nl80211.AttrWiphyAntennaRx:
+			current.RX = ad.Uint32()
+		case nl80211.AttrWiphyAntennaAvailTx:
+			available.TX = ad.Uint32()
+		case nl80211.AttrWiphyAntennaAvailRx:
+			available.RX = ad.Uint32()
+		}
+	}
+
+	return current, available
+}
+
+// SetAntenna selects the antennas used by the radio. Many drivers only
+// accept this while all interfaces of the radio are down.
+func (c *Client) SetAntenna(phy int, antenna Antenna) error {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrWiphy, uint32(phy))
+	ae.Uint32(nl80211.AttrWiphyAntennaTx, antenna.TX)
+	ae.Uint32(nl80211.AttrWiphyAntennaRx, antenna.RX)
+	data, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandSetWiphy, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
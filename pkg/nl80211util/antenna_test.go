@@ -0,0 +1,50 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func TestParseAntennas(t *testing.T) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrWiphy, 1)
+	ae.Uint32(nl80211.AttrWiphyAntennaAvailTx, 0x3)
+	ae.Uint32(nl80211.AttrWiphyAntennaAvailRx, 0x7)
+	ae.Uint32(nl80211.AttrWiphyAntennaTx, 0x1)
+	ae.Uint32(nl80211.AttrWiphyAntennaRx, 0x5)
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, available := parseAntennas(ad)
+	if want := (Antenna{TX: 0x1, RX: 0x5}); current != want {
+		t.Fatalf("parseAntennas current:\n- want: %v\n-  got: %v", want, current)
+	}
+	if want := (Antenna{TX: 0x3, RX: 0x7}); available != want {
+		t.Fatalf("parseAntennas available:\n- want: %v\n-  got: %v", want, available)
+	}
+}
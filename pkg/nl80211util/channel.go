@@ -0,0 +1,149 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// ChannelChange is a channel change of an interface announced by nl80211.
+type ChannelChange struct {
+	// Command is the notification, e.g. nl80211.CommandChSwitchNotify.
+	Command   uint8
+	Ifindex   int
+	PHY       int
+	Frequency int
+}
+
+// Reason describes the notification of the change.
+func (c ChannelChange) Reason() string {
+	switch c.Command {
+	case nl80211.CommandChSwitchNotify:
+		return "channel switch"
+	case nl80211.CommandChSwitchStartedNotify:
+		return "channel switch started"
+	case nl80211.CommandNewInterface:
+		return "interface changed"
+	}
+	return fmt.Sprintf("command %d", c.Command)
+}
+
+// ChannelEvents receives the notifications of the nl80211 "mlme" and
+// "config" multicast groups announcing channel changes. cfg80211 does not
+// announce the retunes of monitor interfaces, so they have to be polled.
+type ChannelEvents struct {
+	conn       *genetlink.Conn
+	readBuffer int
+}
+
+// ChannelEvents subscribes to channel change notifications.
+func (c *Client) ChannelEvents() (*ChannelEvents, error) {
+	_, family := c.current()
+	var groups []uint32
+	for _, name := range []string{"mlme", "config"} {
+		id, err := findMulticastGroup(family, name)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, id)
+	}
+
+	conn, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to Netlink socket: %v", err)
+	}
+	if err := c.options.apply(conn, c.options.ReadBuffer); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	for _, id := range groups {
+		if err := conn.JoinGroup(id); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return &ChannelEvents{conn: conn, readBuffer: c.options.ReadBuffer}, nil
+}
+
+// Next blocks until a channel change is announced. It returns
+// ErrEventsLost when notifications were dropped, and an error once the
+// events are closed.
+func (e *ChannelEvents) Next() (ChannelChange, error) {
+	for {
+		msgs, _, err := e.conn.Receive()
+		if errors.Is(err, errNoBuffers) {
+			e.readBuffer = grownBuffer(e.readBuffer)
+			_ = e.conn.SetReadBuffer(e.readBuffer)
+			return ChannelChange{}, ErrEventsLost
+		} else if err != nil {
+			return ChannelChange{}, err
+		}
+
+		for _, msg := range msgs {
+			change, ok, err := parseChannelChange(msg)
+			if err != nil {
+				return ChannelChange{}, err
+			}
+			if ok {
+				return change, nil
+			}
+		}
+	}
+}
+
+// parseChannelChange decodes a notification, reporting whether it is a
+// channel change.
+func parseChannelChange(msg genetlink.Message) (ChannelChange, bool, error) {
+	switch msg.Header.Command {
+	case nl80211.CommandChSwitchNotify, nl80211.CommandChSwitchStartedNotify, nl80211.CommandNewInterface:
+	default:
+		return ChannelChange{}, false, nil
+	}
+
+	ad, err := netlink.NewAttributeDecoder(msg.Data)
+	if err != nil {
+		return ChannelChange{}, false, err
+	}
+	change := ChannelChange{Command: msg.Header.Command}
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrIfindex:
+			change.Ifindex = int(ad.Uint32())
+		case nl80211.AttrWiphy:
+			change.PHY = int(ad.Uint32())
+		case nl80211.AttrWiphyFreq:
+			change.Frequency = int(ad.Uint32())
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return ChannelChange{}, false, err
+	}
+
+	// Interface notifications without a frequency are not retunes
+	return change, change.Frequency != 0, nil
+}
+
+// Close closes the notification socket, unblocking Next.
+func (e *ChannelEvents) Close() error {
+	return e.conn.Close()
+}
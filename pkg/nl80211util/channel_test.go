@@ -0,0 +1,71 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+func TestParseChannelChange(t *testing.T) {
+	encode := func(frequency int) []byte {
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint32(nl80211.AttrWiphy, 1)
+		ae.Uint32(nl80211.AttrIfindex, 4)
+		if frequency != 0 {
+			ae.Uint32(nl80211.AttrWiphyFreq, uint32(frequency))
+		}
+		b, err := ae.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		command   uint8
+		frequency int
+		ok        bool
+	}{
+		{nl80211.CommandChSwitchNotify, 5180, true},
+		{nl80211.CommandNewInterface, 2437, true},
+		// Interfaces created down have no channel
+		{nl80211.CommandNewInterface, 0, false},
+		{nl80211.CommandNewScanResults, 2437, false},
+	}
+	for _, tt := range tests {
+		msg := genetlink.Message{
+			Header: genetlink.Header{Command: tt.command},
+			Data:   encode(tt.frequency),
+		}
+		change, ok, err := parseChannelChange(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.ok {
+			t.Errorf("parseChannelChange(%v, %v): ok = %v", tt.command, tt.frequency, ok)
+			continue
+		}
+		want := ChannelChange{Command: tt.command, Ifindex: 4, PHY: 1, Frequency: tt.frequency}
+		if ok && change != want {
+			t.Errorf("parseChannelChange:\n- want: %+v\n-  got: %+v", want, change)
+		}
+	}
+}
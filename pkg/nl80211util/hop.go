@@ -0,0 +1,272 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// errHopClosed is delivered to pending retunes when the client is closed.
+var errHopClosed = errors.New("nl80211: connection closed")
+
+// hopConn is a dedicated connection for retunes whose reply is not waited
+// for by the sender. Replies arrive in request order, so they are matched
+// to the oldest pending request.
+type hopConn struct {
+	conn   *netlink.Conn
+	family genetlink.Family
+
+	mu      sync.Mutex
+	pending []chan error
+	closed  bool
+	// broken is set when replies were lost, the connection must be
+	// replaced
+	broken bool
+	done   chan struct{}
+}
+
+func dialHopConn(family genetlink.Family, options SocketOptions, readBuffer int) (*hopConn, error) {
+	conn, err := netlink.Dial(netlinkGeneric, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := options.apply(conn, readBuffer); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	h := &hopConn{conn: conn, family: family, done: make(chan struct{})}
+	go h.receive()
+	return h, nil
+}
+
+// send sends a command. With ack, the returned channel receives the result
+// once the kernel replies. Without ack the kernel only replies on failure
+// and such errors are dropped.
+func (h *hopConn) send(command uint8, data []byte, ack bool) (<-chan error, error) {
+	flags := netlink.Request
+	if ack {
+		flags |= netlink.Acknowledge
+	}
+	msg, err := encodeRequest(h.family, command, data, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	var result chan error
+	if ack {
+		result = make(chan error, 1)
+
+		// Queue before sending, the reply may be received first
+		h.mu.Lock()
+		if h.closed || h.broken {
+			h.mu.Unlock()
+			return nil, errHopClosed
+		}
+		h.pending = append(h.pending, result)
+		h.mu.Unlock()
+	}
+
+	_, err = h.conn.Send(msg)
+	if err != nil {
+		if ack {
+			h.dequeue(result)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// encodeRequest wraps the attributes of a command in the generic netlink
+// and netlink headers of a request to family.
+func encodeRequest(family genetlink.Family, command uint8, data []byte, flags netlink.HeaderFlags) (netlink.Message, error) {
+	b, err := (&genetlink.Message{
+		Header: genetlink.Header{Command: command, Version: family.Version},
+		Data:   data,
+	}).MarshalBinary()
+	if err != nil {
+		return netlink.Message{}, err
+	}
+
+	return netlink.Message{
+		Header: netlink.Header{Type: netlink.HeaderType(family.ID), Flags: flags},
+		Data:   b,
+	}, nil
+}
+
+func (h *hopConn) dequeue(result chan error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, c := range h.pending {
+		if c == result {
+			h.pending = append(h.pending[:i], h.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliver completes the oldest pending request.
+func (h *hopConn) deliver(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.pending) == 0 {
+		return
+	}
+	h.pending[0] <- err
+	h.pending = h.pending[1:]
+}
+
+// fail completes every pending request with err after the socket failed:
+// replies may have been lost, so later ones cannot be matched anymore.
+func (h *hopConn) fail(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.broken = true
+	for _, c := range h.pending {
+		c <- err
+	}
+	h.pending = nil
+}
+
+func (h *hopConn) isBroken() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.broken
+}
+
+func (h *hopConn) receive() {
+	defer close(h.done)
+
+	for {
+		msgs, err := h.conn.Receive()
+		if err != nil {
+			h.mu.Lock()
+			closed := h.closed
+			h.mu.Unlock()
+			if closed {
+				return
+			}
+			if socketError(err) {
+				h.fail(err)
+				return
+			}
+
+			// Error replies are reported as receive errors
+			h.deliver(err)
+			continue
+		}
+
+		for _, m := range msgs {
+			if m.Header.Type == netlink.Error {
+				h.deliver(nil)
+			}
+		}
+	}
+}
+
+func (h *hopConn) close() error {
+	h.mu.Lock()
+	h.closed = true
+	pending := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+
+	for _, c := range pending {
+		c <- errHopClosed
+	}
+
+	err := h.conn.Close()
+	<-h.done
+	return err
+}
+
+// hopConn returns the dedicated retune connection, dialing it on first use
+// and again after its socket failed.
+func (c *Client) hopConn() (*hopConn, error) {
+	c.hopMu.Lock()
+	defer c.hopMu.Unlock()
+
+	if c.hop != nil && c.hop.isBroken() {
+		_ = c.hop.close()
+		c.hop = nil
+		c.hopBuffer = grownBuffer(c.hopBuffer)
+	}
+	if c.hop == nil {
+		_, family := c.current()
+		h, err := dialHopConn(family, c.options, c.hopBuffer)
+		if err != nil {
+			return nil, err
+		}
+		c.hop = h
+	}
+	return c.hop, nil
+}
+
+// SetFrequencyNoAck is like SetFrequency, but does not ask for nor wait for
+// the kernel reply. It has the lowest possible latency, but failures to
+// retune are not reported.
+func (c *Client) SetFrequencyNoAck(ifindex int, frequency int) error {
+	return c.SetFrequencyKHzNoAck(ifindex, frequency*1000)
+}
+
+// SetFrequencyKHzNoAck is like SetFrequencyNoAck with the frequency in kHz.
+func (c *Client) SetFrequencyKHzNoAck(ifindex int, frequency int) error {
+	data, err := setFrequencyAttributes(ifindex, frequency)
+	if err != nil {
+		return err
+	}
+
+	h, err := c.hopConn()
+	if err != nil {
+		return err
+	}
+
+	_, err = h.send(nl80211.CommandSetChannel, data, false)
+	return err
+}
+
+// SetFrequencyAsync is like SetFrequency, but returns as soon as the request
+// is sent. The result is delivered on the returned channel once the kernel
+// replies.
+func (c *Client) SetFrequencyAsync(ifindex int, frequency int) (<-chan error, error) {
+	return c.SetFrequencyKHzAsync(ifindex, frequency*1000)
+}
+
+// SetFrequencyKHzAsync is like SetFrequencyAsync with the frequency in kHz.
+func (c *Client) SetFrequencyKHzAsync(ifindex int, frequency int) (<-chan error, error) {
+	data, err := setFrequencyAttributes(ifindex, frequency)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := c.hopConn()
+	if err != nil {
+		return nil, err
+	}
+
+	return h.send(nl80211.CommandSetChannel, data, true)
+}
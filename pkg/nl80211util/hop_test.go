@@ -0,0 +1,160 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestHopConnDeliver(t *testing.T) {
+	h := &hopConn{}
+	first, second, third := make(chan error, 1), make(chan error, 1), make(chan error, 1)
+	h.pending = []chan error{first, second, third}
+
+	errBusy := errors.New("device busy")
+	h.dequeue(second)
+	h.deliver(nil)
+	h.deliver(errBusy)
+
+	if err := <-first; err != nil {
+		t.Fatalf("first reply:\n- want: %v\n-  got: %v", nil, err)
+	}
+	if err := <-third; err != errBusy {
+		t.Fatalf("third reply:\n- want: %v\n-  got: %v", errBusy, err)
+	}
+	if len(second) != 0 {
+		t.Fatalf("dequeued request received a reply")
+	}
+
+	// Replies without pending requests are dropped
+	h.deliver(errBusy)
+}
+
+func TestHopConnFail(t *testing.T) {
+	h := &hopConn{}
+	first, second := make(chan error, 1), make(chan error, 1)
+	h.pending = []chan error{first, second}
+
+	overrun := os.NewSyscallError("recvmsg", unix.ENOBUFS)
+	h.fail(overrun)
+	for i, c := range []chan error{first, second} {
+		if err := <-c; err != overrun {
+			t.Fatalf("reply #%v:\n- want: %v\n-  got: %v", i, overrun, err)
+		}
+	}
+	if !h.isBroken() {
+		t.Fatalf("connection is not broken after failing")
+	}
+	if _, err := h.send(0, nil, true); err != errHopClosed {
+		t.Fatalf("send on a broken connection:\n- want: %v\n-  got: %v", errHopClosed, err)
+	}
+}
+
+func TestSocketError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{os.NewSyscallError("recvmsg", unix.ENOBUFS), true},
+		{fmt.Errorf("receive: %w", os.NewSyscallError("recvmsg", unix.EBADF)), true},
+		{unix.EBUSY, false},
+		{errors.New("unsupported"), false},
+	}
+	for _, tt := range tests {
+		if got := socketError(tt.err); got != tt.want {
+			t.Errorf("socketError(%v):\n- want: %v\n-  got: %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestGrownBuffer(t *testing.T) {
+	size := 0
+	var sizes []int
+	for i := 0; i < 7; i++ {
+		size = grownBuffer(size)
+		sizes = append(sizes, size)
+	}
+	want := []int{512 << 10, 1 << 20, 2 << 20, 4 << 20, 8 << 20, 8 << 20, 8 << 20}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Fatalf("grownBuffer:\n- want: %v\n-  got: %v", want, sizes)
+		}
+	}
+
+	// Buffers configured above the cap are kept
+	if got := grownBuffer(16 << 20); got != 16<<20 {
+		t.Fatalf("grownBuffer(16 MiB):\n- want: %v\n-  got: %v", 16<<20, got)
+	}
+}
+
+func TestEncodeRequest(t *testing.T) {
+	family := genetlink.Family{ID: 28, Version: 1}
+	data, err := setFrequencyAttributes(3, 2437)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := encodeRequest(family, nl80211.CommandSetChannel, data, netlink.Request|netlink.Acknowledge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Type != 28 || msg.Header.Flags != netlink.Request|netlink.Acknowledge {
+		t.Fatalf("header: %+v", msg.Header)
+	}
+	var got genetlink.Message
+	if err := got.UnmarshalBinary(msg.Data); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Command != nl80211.CommandSetChannel || got.Header.Version != 1 || !bytes.Equal(got.Data, data) {
+		t.Fatalf("generic netlink message: %+v", got)
+	}
+
+	// Every retune is wrapped, only the returned buffer may be allocated
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = encodeRequest(family, nl80211.CommandSetChannel, data, netlink.Request)
+	})
+	if allocs > 1 {
+		t.Fatalf("encodeRequest allocated %v times, want at most 1", allocs)
+	}
+}
+
+func BenchmarkEncodeRequest(b *testing.B) {
+	family := genetlink.Family{ID: 28, Version: 1}
+	data, err := setFrequencyAttributes(3, 2437)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := encodeRequest(family, nl80211.CommandSetChannel, data, netlink.Request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+// InterfaceType is the nl80211 interface type (NL80211_IFTYPE_*).
+type InterfaceType int
+
+const (
+	InterfaceTypeUnspecified InterfaceType = nl80211.IftypeUnspecified
+	InterfaceTypeAdhoc       InterfaceType = nl80211.IftypeAdhoc
+	InterfaceTypeStation     InterfaceType = nl80211.IftypeStation
+	InterfaceTypeAP          InterfaceType = nl80211.IftypeAp
+	InterfaceTypeAPVLAN      InterfaceType = nl80211.IftypeApVlan
+	InterfaceTypeWDS         InterfaceType = nl80211.IftypeWds
+	InterfaceTypeMonitor     InterfaceType = nl80211.IftypeMonitor
+	InterfaceTypeMeshPoint   InterfaceType = nl80211.IftypeMeshPoint
+)
+
+func (t InterfaceType) String() string {
+	switch t {
+	case InterfaceTypeAdhoc:
+		return "adhoc"
+	case InterfaceTypeStation:
+		return "managed"
+	case InterfaceTypeAP:
+		return "AP"
+	case InterfaceTypeAPVLAN:
+		return "AP/VLAN"
+	case InterfaceTypeWDS:
+		return "WDS"
+	case InterfaceTypeMonitor:
+		return "monitor"
+	case InterfaceTypeMeshPoint:
+		return "mesh point"
+	}
+	return fmt.Sprintf("type %d", int(t))
+}
+
+// Interface is a wireless network interface.
+type Interface struct {
+	Index        int
+	Name         string
+	HardwareAddr net.HardwareAddr
+	PHY          int
+	Type         InterfaceType
+	// Frequency is the current frequency in MHz, 0 if unknown.
+	Frequency int
+	// FourAddr reports whether 4-address (WDS) frames are used.
+	FourAddr bool
+	// TxPower is the current transmit power in mBm, 0 if unknown.
+	TxPower int
+}
+
+// Interfaces dumps the wireless interfaces with NL80211_CMD_GET_INTERFACE.
+func (c *Client) Interfaces() ([]Interface, error) {
+	msgs, err := c.execute(nl80211.CommandGetInterface, netlink.Request|netlink.Dump, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make([]Interface, 0, len(msgs))
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		iface := parseInterface(ad)
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+
+		// Skip interfaces without a netdev, e.g. P2P devices
+		if iface.Index == 0 {
+			continue
+		}
+		interfaces = append(interfaces, iface)
+	}
+
+	return interfaces, nil
+}
+
+func parseInterface(ad *netlink.AttributeDecoder) Interface {
+	var iface Interface
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrIfindex:
+			iface.Index = int(ad.Uint32())
+		case nl80211.AttrIfname:
+			iface.Name = ad.String()
+		case nl80211.AttrMac:
+			iface.HardwareAddr = net.HardwareAddr(ad.Bytes())
+		case nl80211.AttrWiphy:
+			iface.PHY = int(ad.Uint32())
+		case nl80211.AttrIftype:
+			iface.Type = InterfaceType(ad.Uint32())
+		case nl80211.AttrWiphyFreq:
+			iface.Frequency = int(ad.Uint32())
+		case nl80211.Attr4addr:
+			iface.FourAddr = ad.Uint8() != 0
+		case nl80211.AttrWiphyTxPowerLevel:
+			iface.TxPower = int(ad.Uint32())
+		}
+	}
+
+	return iface
+}
+
+// InterfaceFrequency returns the frequency the interface is tuned to, in
+// MHz, or 0 if the driver does not report it.
+func (c *Client) InterfaceFrequency(ifindex int) (int, error) {
+	data, err := ifindexAttribute(ifindex)
+	if err != nil {
+		return 0, err
+	}
+
+	msgs, err := c.execute(nl80211.CommandGetInterface, netlink.Request, data)
+	if err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, fmt.Errorf("no reply for interface %d", ifindex)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(msgs[0].Data)
+	if err != nil {
+		return 0, err
+	}
+	iface := parseInterface(ad)
+	return iface.Frequency, ad.Err()
+}
+
+// InterfaceByName looks up a wireless interface by name.
+func (c *Client) InterfaceByName(name string) (*Interface, error) {
+	interfaces, err := c.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range interfaces {
+		if interfaces[i].Name == name {
+			return &interfaces[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot find %v", name)
+}
+
+// MonitorInterface looks up an interface by name and checks that it is in
+// monitor mode.
+func (c *Client) MonitorInterface(name string) (*Interface, error) {
+	iface, err := c.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if iface.Type != InterfaceTypeMonitor {
+		return nil, fmt.Errorf("%v is not in monitor mode", name)
+	}
+	return iface, nil
+}
+
+// MonitorInterface is like Client.MonitorInterface, using a temporary
+// connection.
+func MonitorInterface(name string) (*Interface, error) {
+	client, err := Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return client.MonitorInterface(name)
+}
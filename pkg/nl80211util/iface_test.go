@@ -0,0 +1,63 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func TestParseInterface(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrIfindex, 7)
+	ae.String(nl80211.AttrIfname, "wlan0mon")
+	ae.Bytes(nl80211.AttrMac, mac)
+	ae.Uint32(nl80211.AttrWiphy, 1)
+	ae.Uint32(nl80211.AttrIftype, nl80211.IftypeMonitor)
+	ae.Uint32(nl80211.AttrWiphyFreq, 2437)
+	ae.Uint8(nl80211.Attr4addr, 1)
+	ae.Uint32(nl80211.AttrWiphyTxPowerLevel, 2000)
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Interface{
+		Index:        7,
+		Name:         "wlan0mon",
+		HardwareAddr: mac,
+		PHY:          1,
+		Type:         InterfaceTypeMonitor,
+		Frequency:    2437,
+		FourAddr:     true,
+		TxPower:      2000,
+	}
+	if got := parseInterface(ad); !reflect.DeepEqual(want, got) {
+		t.Fatalf("parseInterface:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+// LinkChange is a network interface added, changed or removed, announced
+// by rtnetlink.
+type LinkChange struct {
+	Ifindex int
+	Name    string
+	// Radiotap is set for interfaces delivering radiotap frames: the
+	// monitor interfaces of 802.11 radios.
+	Radiotap bool
+	Up       bool
+	Removed  bool
+}
+
+// LinkEvents receives the notifications of the rtnetlink link group.
+type LinkEvents struct {
+	conn       *netlink.Conn
+	readBuffer int
+}
+
+// LinkEvents subscribes to network interface notifications.
+func (c *Client) LinkEvents() (*LinkEvents, error) {
+	conn, err := netlink.Dial(netlinkRoute, &netlink.Config{Groups: rtmgrpLink})
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to rtnetlink socket: %v", err)
+	}
+	if err := c.options.apply(conn, c.options.ReadBuffer); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &LinkEvents{conn: conn, readBuffer: c.options.ReadBuffer}, nil
+}
+
+// Next blocks until a network interface changes. It returns ErrEventsLost
+// when notifications were dropped, and an error once the events are closed.
+func (e *LinkEvents) Next() (LinkChange, error) {
+	for {
+		msgs, err := e.conn.Receive()
+		if errors.Is(err, errNoBuffers) {
+			e.readBuffer = grownBuffer(e.readBuffer)
+			_ = e.conn.SetReadBuffer(e.readBuffer)
+			return LinkChange{}, ErrEventsLost
+		} else if err != nil {
+			return LinkChange{}, err
+		}
+
+		for _, msg := range msgs {
+			change, ok, err := parseLinkChange(msg)
+			if err != nil {
+				return LinkChange{}, err
+			}
+			if ok {
+				return change, nil
+			}
+		}
+	}
+}
+
+// parseLinkChange decodes a notification, reporting whether it is a link
+// change.
+func parseLinkChange(msg netlink.Message) (LinkChange, bool, error) {
+	switch msg.Header.Type {
+	case rtmNewLink, rtmDelLink:
+	default:
+		return LinkChange{}, false, nil
+	}
+
+	// struct ifinfomsg, then the attributes
+	if len(msg.Data) < sizeofIfInfomsg {
+		return LinkChange{}, false, errors.New("rtnetlink: short link message")
+	}
+	change := LinkChange{
+		Ifindex:  int(nlenc.Int32(msg.Data[4:8])),
+		Radiotap: nlenc.Uint16(msg.Data[2:4]) == arphrdRadiotap,
+		Up:       nlenc.Uint32(msg.Data[8:12])&iffUp != 0,
+		Removed:  msg.Header.Type == rtmDelLink,
+	}
+
+	ad, err := netlink.NewAttributeDecoder(msg.Data[sizeofIfInfomsg:])
+	if err != nil {
+		return LinkChange{}, false, err
+	}
+	for ad.Next() {
+		if ad.Type() == iflaIfname {
+			change.Name = ad.String()
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return LinkChange{}, false, err
+	}
+	return change, true, nil
+}
+
+// Close closes the notification socket, unblocking Next.
+func (e *LinkEvents) Close() error {
+	return e.conn.Close()
+}
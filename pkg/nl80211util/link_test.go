@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+)
+
+func linkMessage(t *testing.T, typ netlink.HeaderType, ifindex int, arphrd uint16, flags uint32, name string) netlink.Message {
+	t.Helper()
+
+	data := make([]byte, unix.SizeofIfInfomsg)
+	nlenc.PutUint16(data[2:4], arphrd)
+	nlenc.PutInt32(data[4:8], int32(ifindex))
+	nlenc.PutUint32(data[8:12], flags)
+
+	ae := netlink.NewAttributeEncoder()
+	ae.String(unix.IFLA_IFNAME, name)
+	attrs, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return netlink.Message{Header: netlink.Header{Type: typ}, Data: append(data, attrs...)}
+}
+
+func TestParseLinkChange(t *testing.T) {
+	change, ok, err := parseLinkChange(linkMessage(t, unix.RTM_NEWLINK, 7, unix.ARPHRD_IEEE80211_RADIOTAP, unix.IFF_UP, "wlan1mon"))
+	if err != nil || !ok {
+		t.Fatalf("parseLinkChange = %v, %v", ok, err)
+	}
+	if want := (LinkChange{Ifindex: 7, Name: "wlan1mon", Radiotap: true, Up: true}); change != want {
+		t.Fatalf("parseLinkChange:\n- want: %+v\n-  got: %+v", want, change)
+	}
+
+	change, ok, err = parseLinkChange(linkMessage(t, unix.RTM_DELLINK, 3, unix.ARPHRD_ETHER, 0, "eth0"))
+	if err != nil || !ok || !change.Removed || change.Radiotap || change.Up {
+		t.Fatalf("parseLinkChange of a removed link = %+v, %v, %v", change, ok, err)
+	}
+
+	if _, ok, err := parseLinkChange(netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWADDR}}); ok || err != nil {
+		t.Fatalf("parseLinkChange of an address = %v, %v", ok, err)
+	}
+	if _, _, err := parseLinkChange(netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWLINK}, Data: []byte{0}}); err == nil {
+		t.Fatal("parseLinkChange accepted a short message")
+	}
+}
@@ -0,0 +1,319 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nl80211util wraps the nl80211 generic Netlink commands used by
+// chopper: retuning, scans, surveys and the creation of monitor
+// interfaces, with the rtnetlink requests bringing them up and down.
+package nl80211util
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+// ErrNotAvailable is returned by Dial when the kernel does not expose the
+// nl80211 family.
+var ErrNotAvailable = errors.New("nl80211 not available")
+
+// ErrUnsupported is returned by Dial on platforms other than Linux. The
+// rest of the package builds everywhere, so that tools embedding it can be
+// developed on any system.
+var ErrUnsupported = errors.New("nl80211 is only available on Linux")
+
+// maxReadBuffer caps the growth of the receive buffer of the sockets, which
+// is doubled every time it overruns.
+const maxReadBuffer = 8 << 20
+
+// SocketOptions configures the Netlink sockets of a Client.
+type SocketOptions struct {
+	// ReadBuffer is the receive buffer of the sockets in bytes, 0 for the
+	// kernel default. The kernel caps it to net.core.rmem_max.
+	ReadBuffer int
+	// StrictCheck enables NETLINK_GET_STRICT_CHK, so that the kernel
+	// rejects malformed requests instead of ignoring what it does not
+	// expect. It requires Linux 4.20.
+	StrictCheck bool
+}
+
+// configurable is a Netlink socket of the package.
+type configurable interface {
+	SetReadBuffer(bytes int) error
+	SetOption(option netlink.ConnOption, enable bool) error
+}
+
+// apply configures conn, with a receive buffer of readBuffer bytes.
+func (o SocketOptions) apply(conn configurable, readBuffer int) error {
+	if readBuffer > 0 {
+		if err := conn.SetReadBuffer(readBuffer); err != nil {
+			return fmt.Errorf("cannot set the receive buffer: %v", err)
+		}
+	}
+	if o.StrictCheck {
+		if err := conn.SetOption(netlink.GetStrictCheck, true); err != nil {
+			return fmt.Errorf("cannot enable strict checking: %v", err)
+		}
+	}
+	return nil
+}
+
+// Client is a connection to the nl80211 generic Netlink family.
+type Client struct {
+	options SocketOptions
+
+	mu     sync.RWMutex
+	conn   *genetlink.Conn
+	family genetlink.Family
+	// readBuffer is the receive buffer of new sockets, 0 for the default
+	readBuffer int
+
+	hopMu     sync.Mutex
+	hop       *hopConn
+	hopBuffer int
+
+	// route is the rtnetlink socket of SetLinkUp and SetLinkDown, dialed
+	// on first use
+	routeMu sync.Mutex
+	route   *netlink.Conn
+}
+
+// Dial connects to generic Netlink and resolves the nl80211 family.
+func Dial() (*Client, error) {
+	return DialOptions(SocketOptions{})
+}
+
+// DialOptions is like Dial, configuring the sockets with options.
+func DialOptions(options SocketOptions) (*Client, error) {
+	conn, family, err := dial(options, options.ReadBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		options:    options,
+		conn:       conn,
+		family:     family,
+		readBuffer: options.ReadBuffer,
+		hopBuffer:  options.ReadBuffer,
+	}, nil
+}
+
+func dial(options SocketOptions, readBuffer int) (*genetlink.Conn, genetlink.Family, error) {
+	if !supported {
+		return nil, genetlink.Family{}, ErrUnsupported
+	}
+	conn, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, genetlink.Family{}, fmt.Errorf("cannot connect to Netlink socket: %v", err)
+	}
+	if err := options.apply(conn, readBuffer); err != nil {
+		_ = conn.Close()
+		return nil, genetlink.Family{}, err
+	}
+
+	family, err := conn.GetFamily("nl80211")
+	if err != nil {
+		// TODO: Print families for debugging purposes
+		_ = conn.Close()
+		return nil, genetlink.Family{}, ErrNotAvailable
+	}
+	return conn, family, nil
+}
+
+// Redial replaces the Netlink sockets with new ones, e.g. after the system
+// resumed from suspend. Requests in flight on the old sockets fail.
+func (c *Client) Redial() error {
+	c.mu.RLock()
+	readBuffer := c.readBuffer
+	c.mu.RUnlock()
+	conn, family, err := dial(c.options, readBuffer)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	old := c.conn
+	c.conn = conn
+	c.family = family
+	c.mu.Unlock()
+	_ = old.Close()
+
+	// The retune and rtnetlink connections are dialed again on first use
+	c.closeHop()
+	c.closeRoute()
+	return nil
+}
+
+// socketError reports whether err is a failure of the socket rather than
+// an error reply of the kernel: the receive buffer overran, dropping
+// replies, or the socket was closed.
+func socketError(err error) bool {
+	return errors.Is(err, errNoBuffers) || errors.Is(err, errBadFD)
+}
+
+// recover redials after the socket failed with err, doubling the receive
+// buffer first if it overran.
+func (c *Client) recover(err error) error {
+	if errors.Is(err, errNoBuffers) {
+		c.mu.Lock()
+		c.readBuffer = grownBuffer(c.readBuffer)
+		c.mu.Unlock()
+	}
+	return c.Redial()
+}
+
+// grownBuffer returns the receive buffer to use after size overran.
+func grownBuffer(size int) int {
+	if size == 0 {
+		// The kernel default, net.core.rmem_default, is usually 208 KiB
+		size = 256 << 10
+	}
+	if size >= maxReadBuffer {
+		return size
+	}
+	if size *= 2; size > maxReadBuffer {
+		size = maxReadBuffer
+	}
+	return size
+}
+
+// current returns the connection and the nl80211 family.
+func (c *Client) current() (*genetlink.Conn, genetlink.Family) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.conn, c.family
+}
+
+func (c *Client) closeHop() {
+	c.hopMu.Lock()
+	hop := c.hop
+	c.hop = nil
+	c.hopMu.Unlock()
+
+	if hop != nil {
+		_ = hop.close()
+	}
+}
+
+func (c *Client) closeRoute() {
+	c.routeMu.Lock()
+	defer c.routeMu.Unlock()
+
+	if c.route != nil {
+		_ = c.route.Close()
+		c.route = nil
+	}
+}
+
+// Close closes the underlying Netlink sockets.
+func (c *Client) Close() error {
+	c.closeHop()
+	c.closeRoute()
+	conn, _ := c.current()
+	return conn.Close()
+}
+
+// execute sends a nl80211 command with the given attributes and waits for
+// the reply. If the socket fails, the command is sent again once on a new
+// one.
+func (c *Client) execute(command uint8, flags netlink.HeaderFlags, data []byte) ([]genetlink.Message, error) {
+	msgs, err := c.executeOnce(command, flags, data)
+	if err == nil || !socketError(err) {
+		return msgs, err
+	}
+	if rerr := c.recover(err); rerr != nil {
+		return nil, err
+	}
+	return c.executeOnce(command, flags, data)
+}
+
+func (c *Client) executeOnce(command uint8, flags netlink.HeaderFlags, data []byte) ([]genetlink.Message, error) {
+	conn, family := c.current()
+	return conn.Execute(genetlink.Message{
+		Header: genetlink.Header{
+			Command: command,
+			Version: family.Version,
+		},
+		Data: data,
+	}, family.ID, flags)
+}
+
+// SetFrequency tunes the interface to a 20 MHz channel on the given
+// frequency (in MHz).
+func (c *Client) SetFrequency(ifindex int, frequency int) error {
+	return c.SetFrequencyKHz(ifindex, frequency*1000)
+}
+
+// SetFrequencyKHz is like SetFrequency with the frequency in kHz, for
+// channels whose center is not on a MHz boundary (kernel 5.8 or later).
+func (c *Client) SetFrequencyKHz(ifindex int, frequency int) error {
+	data, err := setFrequencyAttributes(ifindex, frequency)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandSetChannel, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
+
+// setFrequencyAttributes encodes the arguments of SetFrequencyKHz. The
+// offset is only sent when there is one, older kernels reject it.
+func setFrequencyAttributes(ifindex int, frequency int) ([]byte, error) {
+	attrs := [...]netlink.Attribute{
+		{
+			Type: nl80211.AttrIfindex,
+			Data: nlenc.Uint32Bytes(uint32(ifindex)),
+		},
+		{
+			Type: nl80211.AttrWiphyFreq,
+			Data: nlenc.Uint32Bytes(uint32(frequency / 1000)),
+		},
+
+		// TODO: Add support for HT20, HT40+, HT40-
+		{
+			Type: nl80211.AttrChannelWidth,
+			Data: nlenc.Uint32Bytes(uint32(nl80211.ChanWidth20Noht)),
+		},
+		{
+			Type: nl80211.AttrWiphyChannelType,
+			Data: nlenc.Uint32Bytes(uint32(nl80211.ChanHt20)),
+		},
+		{
+			Type: nl80211.AttrWiphyFreqOffset,
+			Data: nlenc.Uint32Bytes(uint32(frequency % 1000)),
+		},
+	}
+	n := len(attrs)
+	if frequency%1000 == 0 {
+		n--
+	}
+
+	return netlink.MarshalAttributes(attrs[:n])
+}
+
+// ifindexAttribute encodes an attribute list holding only the interface
+// index, which many commands take as their sole argument.
+func ifindexAttribute(ifindex int) ([]byte, error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrIfindex, uint32(ifindex))
+	return ae.Encode()
+}
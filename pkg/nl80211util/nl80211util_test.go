@@ -0,0 +1,136 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+)
+
+// fakeSocket records the options set on it.
+type fakeSocket struct {
+	readBuffer int
+	options    map[netlink.ConnOption]bool
+	err        error
+}
+
+func (s *fakeSocket) SetReadBuffer(bytes int) error {
+	s.readBuffer = bytes
+	return nil
+}
+
+func (s *fakeSocket) SetOption(option netlink.ConnOption, enable bool) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.options == nil {
+		s.options = make(map[netlink.ConnOption]bool)
+	}
+	s.options[option] = enable
+	return nil
+}
+
+func TestSocketOptionsApply(t *testing.T) {
+	// Nothing is changed by default
+	s := &fakeSocket{}
+	if err := (SocketOptions{}).apply(s, 0); err != nil || s.readBuffer != 0 || s.options != nil {
+		t.Fatalf("apply of the defaults: %v, %+v", err, s)
+	}
+
+	s = &fakeSocket{}
+	if err := (SocketOptions{ReadBuffer: 1 << 20, StrictCheck: true}).apply(s, 2<<20); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[netlink.ConnOption]bool{netlink.GetStrictCheck: true}; s.readBuffer != 2<<20 || !reflect.DeepEqual(want, s.options) {
+		t.Fatalf("apply:\n- want: %v %v\n-  got: %v %v", 2<<20, want, s.readBuffer, s.options)
+	}
+
+	// Kernels before 4.20 do not know the option
+	s = &fakeSocket{err: unix.ENOPROTOOPT}
+	if err := (SocketOptions{StrictCheck: true}).apply(s, 0); err == nil {
+		t.Fatalf("apply on a kernel without strict checking: expected error")
+	}
+}
+
+func TestSetFrequencyAttributes(t *testing.T) {
+	data, err := setFrequencyAttributes(3, 2437000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := netlink.UnmarshalAttributes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[uint16]uint32, len(attrs))
+	for _, attr := range attrs {
+		got[attr.Type] = nlenc.Uint32(attr.Data)
+	}
+	want := map[uint16]uint32{
+		nl80211.AttrIfindex:          3,
+		nl80211.AttrWiphyFreq:        2437,
+		nl80211.AttrChannelWidth:     nl80211.ChanWidth20Noht,
+		nl80211.AttrWiphyChannelType: nl80211.ChanHt20,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("attributes:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// Every retune encodes them, only the returned buffer may be allocated
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = setFrequencyAttributes(3, 2437000)
+	})
+	if allocs > 1 {
+		t.Fatalf("setFrequencyAttributes allocated %v times, want at most 1", allocs)
+	}
+}
+
+func TestSetFrequencyAttributesOffset(t *testing.T) {
+	data, err := setFrequencyAttributes(3, 902500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := netlink.UnmarshalAttributes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[uint16]uint32, len(attrs))
+	for _, attr := range attrs {
+		got[attr.Type] = nlenc.Uint32(attr.Data)
+	}
+	if got[nl80211.AttrWiphyFreq] != 902 || got[nl80211.AttrWiphyFreqOffset] != 500 {
+		t.Fatalf("frequency %v MHz + %v kHz, want 902 MHz + 500 kHz", got[nl80211.AttrWiphyFreq], got[nl80211.AttrWiphyFreqOffset])
+	}
+}
+
+func BenchmarkSetFrequencyAttributes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := setFrequencyAttributes(3, 2437000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/giacomoferretti/chopper-go/pkg/wifichan"
+	"github.com/mdlayher/netlink"
+)
+
+// BandID is an nl80211 band (NL80211_BAND_*).
+type BandID int
+
+const (
+	Band2GHz  BandID = nl80211.Band2ghz
+	Band5GHz  BandID = nl80211.Band5ghz
+	Band60GHz BandID = nl80211.Band60ghz
+	Band6GHz  BandID = nl80211.Band6ghz
+	BandS1GHz BandID = nl80211.BandS1ghz
+)
+
+func (b BandID) String() string {
+	switch b {
+	case Band2GHz:
+		return "2.4GHz"
+	case Band5GHz:
+		return "5GHz"
+	case Band60GHz:
+		return "60GHz"
+	case Band6GHz:
+		return "6GHz"
+	case BandS1GHz:
+		return "S1GHz"
+	}
+	return fmt.Sprintf("band %d", int(b))
+}
+
+// ChannelWidth is an nl80211 channel width (NL80211_CHAN_WIDTH_*).
+type ChannelWidth int
+
+const (
+	Width20    ChannelWidth = nl80211.ChanWidth20
+	Width40    ChannelWidth = nl80211.ChanWidth40
+	Width80    ChannelWidth = nl80211.ChanWidth80
+	Width80P80 ChannelWidth = nl80211.ChanWidth80p80
+	Width160   ChannelWidth = nl80211.ChanWidth160
+)
+
+func (w ChannelWidth) String() string {
+	switch w {
+	case Width20:
+		return "20MHz"
+	case Width40:
+		return "40MHz"
+	case Width80:
+		return "80MHz"
+	case Width80P80:
+		return "80+80MHz"
+	case Width160:
+		return "160MHz"
+	}
+	return fmt.Sprintf("width %d", int(w))
+}
+
+// DFSState is the state of a radar channel (NL80211_DFS_*).
+type DFSState int
+
+const (
+	// DFSUsable channels need a channel availability check before
+	// transmitting.
+	DFSUsable DFSState = nl80211.DfsUsable
+	// DFSUnavailable channels had a radar detected recently.
+	DFSUnavailable DFSState = nl80211.DfsUnavailable
+	// DFSAvailable channels passed the check and can be transmitted on.
+	DFSAvailable DFSState = nl80211.DfsAvailable
+)
+
+func (s DFSState) String() string {
+	switch s {
+	case DFSUsable:
+		return "usable"
+	case DFSUnavailable:
+		return "unavailable"
+	case DFSAvailable:
+		return "available"
+	}
+	return fmt.Sprintf("state %d", int(s))
+}
+
+// Channel is a channel of a band, with the restrictions of the current
+// regulatory domain.
+type Channel struct {
+	WiphyFrequency
+	// Number is the channel number within the band, 0 if unknown.
+	Number int
+	// FrequencyOffset is added to Frequency, in kHz, for S1G channels.
+	FrequencyOffset int
+	// DFSState is only meaningful for Radar channels.
+	DFSState DFSState
+
+	NoHT40Minus bool
+	NoHT40Plus  bool
+	No80MHz     bool
+	No160MHz    bool
+	IndoorOnly  bool
+	NoHE        bool
+}
+
+// Band is a band supported by a radio, with its channels and the
+// capabilities of the radio in it.
+type Band struct {
+	ID       BandID
+	Channels []Channel
+
+	// HTCapabilities is the HT Capabilities Info field, if HT is set.
+	HT             bool
+	HTCapabilities uint16
+	// VHTCapabilities is the VHT Capabilities Info field, if VHT is set.
+	VHT             bool
+	VHTCapabilities uint32
+	// HEPHYCapabilities are the HE PHY Capabilities of the first
+	// interface type with HE, if HE is set.
+	HE                bool
+	HEPHYCapabilities []byte
+}
+
+// Capability bits deciding the channel widths of a band.
+const (
+	htCapWidth20_40       = 1 << 1
+	vhtCapWidthMask       = 3 << 2
+	vhtCapWidth160        = 1 << 2
+	vhtCapWidth160_80P80  = 2 << 2
+	hePHYWidth40In2GHz    = 1 << 1
+	hePHYWidth40_80In5GHz = 1 << 2
+	hePHYWidth160In5GHz   = 1 << 3
+	hePHYWidth80P80In5GHz = 1 << 4
+)
+
+// Widths returns the channel widths the radio supports in the band.
+func (b Band) Widths() []ChannelWidth {
+	var he byte
+	if len(b.HEPHYCapabilities) > 0 {
+		he = b.HEPHYCapabilities[0]
+	}
+	vhtWidth := b.VHTCapabilities & vhtCapWidthMask
+
+	widths := []ChannelWidth{Width20}
+	if (b.HT && b.HTCapabilities&htCapWidth20_40 != 0) || he&(hePHYWidth40In2GHz|hePHYWidth40_80In5GHz) != 0 {
+		widths = append(widths, Width40)
+	}
+	if b.VHT || he&hePHYWidth40_80In5GHz != 0 {
+		widths = append(widths, Width80)
+	}
+	if (b.VHT && vhtWidth == vhtCapWidth160_80P80) || he&hePHYWidth80P80In5GHz != 0 {
+		widths = append(widths, Width80P80)
+	}
+	if (b.VHT && (vhtWidth == vhtCapWidth160 || vhtWidth == vhtCapWidth160_80P80)) || he&hePHYWidth160In5GHz != 0 {
+		widths = append(widths, Width160)
+	}
+	return widths
+}
+
+// ChannelWidths returns the widths of the band the regulatory domain allows
+// on c, none if it is disabled.
+func (b Band) ChannelWidths(c Channel) []ChannelWidth {
+	if c.Disabled {
+		return nil
+	}
+
+	var widths []ChannelWidth
+	for _, w := range b.Widths() {
+		switch {
+		case w == Width40 && c.NoHT40Minus && c.NoHT40Plus:
+		case (w == Width80 || w == Width80P80) && c.No80MHz:
+		case w == Width160 && c.No160MHz:
+		default:
+			widths = append(widths, w)
+		}
+	}
+	return widths
+}
+
+// Phy describes a radio: its bands, channels and capabilities, as
+// reported by the wiphy dump.
+type Phy struct {
+	Index        int
+	Name         string
+	Capabilities WiphyCapabilities
+	Bands        []Band
+}
+
+// Band returns the band id of the radio, nil if it does not support it.
+func (p *Phy) Band(id BandID) *Band {
+	for i := range p.Bands {
+		if p.Bands[i].ID == id {
+			return &p.Bands[i]
+		}
+	}
+	return nil
+}
+
+// Channels returns the channels of all the bands.
+func (p *Phy) Channels() []Channel {
+	var channels []Channel
+	for _, band := range p.Bands {
+		channels = append(channels, band.Channels...)
+	}
+	return channels
+}
+
+// Phy describes a radio.
+func (c *Client) Phy(phy int) (*Phy, error) {
+	phys, err := c.phys(phy)
+	if err != nil {
+		return nil, err
+	}
+	for i := range phys {
+		if phys[i].Index == phy {
+			return &phys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("phy%d not found", phy)
+}
+
+// Phys describes all the radios.
+func (c *Client) Phys() ([]Phy, error) {
+	return c.phys(-1)
+}
+
+func (c *Client) phys(phy int) ([]Phy, error) {
+	msgs, err := c.dumpWiphy(phy)
+	if err != nil {
+		return nil, err
+	}
+
+	var p phyParser
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		p.parse(ad)
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return p.phys, nil
+}
+
+// phyParser merges the messages of a split wiphy dump, where the bands,
+// and even the channels of a band, are spread over several messages.
+type phyParser struct {
+	phys []Phy
+}
+
+func (p *phyParser) phy(index int) *Phy {
+	for i := range p.phys {
+		if p.phys[i].Index == index {
+			return &p.phys[i]
+		}
+	}
+	p.phys = append(p.phys, Phy{Index: index, Name: fmt.Sprintf("phy%d", index)})
+	return &p.phys[len(p.phys)-1]
+}
+
+// parse adds a wiphy message to the radio it describes. The radio index
+// comes first in every message.
+func (p *phyParser) parse(ad *netlink.AttributeDecoder) {
+	var phy *Phy
+	for ad.Next() {
+		if ad.Type() == nl80211.AttrWiphy {
+			phy = p.phy(int(ad.Uint32()))
+			continue
+		}
+		if phy == nil {
+			continue
+		}
+
+		switch ad.Type() {
+		case nl80211.AttrWiphyName:
+			phy.Name = ad.String()
+		case nl80211.AttrSupportedIftypes:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					phy.Capabilities.Iftypes = append(phy.Capabilities.Iftypes, InterfaceType(nad.Type()))
+				}
+				return nil
+			})
+		case nl80211.AttrFeatureFlags:
+			phy.Capabilities.Features = ad.Uint32()
+		case nl80211.AttrWiphyBands:
+			ad.Nested(func(bands *netlink.AttributeDecoder) error {
+				for bands.Next() {
+					id := BandID(bands.Type())
+					band := phy.Band(id)
+					if band == nil {
+						phy.Bands = append(phy.Bands, Band{ID: id})
+						band = &phy.Bands[len(phy.Bands)-1]
+					}
+					bands.Nested(func(bad *netlink.AttributeDecoder) error {
+						parseBand(bad, band)
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+	}
+}
+
+func parseBand(ad *netlink.AttributeDecoder, b *Band) {
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.BandAttrFreqs:
+			ad.Nested(func(freqs *netlink.AttributeDecoder) error {
+				for freqs.Next() {
+					freqs.Nested(func(nad *netlink.AttributeDecoder) error {
+						c := parseChannel(nad)
+						_, c.Number = wifichan.ChannelOf(c.Frequency)
+						b.Channels = append(b.Channels, c)
+						return nil
+					})
+				}
+				return nil
+			})
+		case nl80211.BandAttrHtCapa:
+			b.HT = true
+			b.HTCapabilities = ad.Uint16()
+		case nl80211.BandAttrVhtCapa:
+			b.VHT = true
+			b.VHTCapabilities = ad.Uint32()
+		case nl80211.BandAttrIftypeData:
+			ad.Nested(func(iftypes *netlink.AttributeDecoder) error {
+				for iftypes.Next() {
+					iftypes.Nested(func(nad *netlink.AttributeDecoder) error {
+						for nad.Next() {
+							if nad.Type() == nl80211.BandIftypeAttrHeCapPhy && !b.HE {
+								b.HE = true
+								b.HEPHYCapabilities = append([]byte(nil), nad.Bytes()...)
+							}
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+	}
+}
+
+func parseChannel(ad *netlink.AttributeDecoder) Channel {
+	var c Channel
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.FrequencyAttrFreq:
+			c.Frequency = int(ad.Uint32())
+		case nl80211.FrequencyAttrOffset:
+			c.FrequencyOffset = int(ad.Uint32())
+		case nl80211.FrequencyAttrDisabled:
+			c.Disabled = true
+		case nl80211.FrequencyAttrNoIr:
+			c.NoIR = true
+		case nl80211.FrequencyAttrRadar:
+			c.Radar = true
+		case nl80211.FrequencyAttrMaxTxPower:
+			c.MaxTxPower = int(ad.Uint32())
+		case nl80211.FrequencyAttrDfsState:
+			c.DFSState = DFSState(ad.Uint32())
+		case nl80211.FrequencyAttrNoHt40Minus:
+			c.NoHT40Minus = true
+		case nl80211.FrequencyAttrNoHt40Plus:
+			c.NoHT40Plus = true
+		case nl80211.FrequencyAttrNo80mhz:
+			c.No80MHz = true
+		case nl80211.FrequencyAttrNo160mhz:
+			c.No160MHz = true
+		case nl80211.FrequencyAttrIndoorOnly:
+			c.IndoorOnly = true
+		case nl80211.FrequencyAttrNoHe:
+			c.NoHE = true
+		}
+	}
+	return c
+}
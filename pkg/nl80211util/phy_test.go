@@ -0,0 +1,233 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func decodePhyMessages(t *testing.T, msgs ...func(ae *netlink.AttributeEncoder)) []Phy {
+	t.Helper()
+
+	var p phyParser
+	for _, msg := range msgs {
+		ae := netlink.NewAttributeEncoder()
+		msg(ae)
+		b, err := ae.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ad, err := netlink.NewAttributeDecoder(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.parse(ad)
+		if err := ad.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p.phys
+}
+
+func encodeBand(ae *netlink.AttributeEncoder, id BandID, band func(bae *netlink.AttributeEncoder)) {
+	ae.Nested(nl80211.AttrWiphyBands, func(nae *netlink.AttributeEncoder) error {
+		nae.Nested(uint16(id), func(bae *netlink.AttributeEncoder) error {
+			band(bae)
+			return nil
+		})
+		return nil
+	})
+}
+
+func encodeChannels(bae *netlink.AttributeEncoder, channels ...func(e *netlink.AttributeEncoder)) {
+	bae.Nested(nl80211.BandAttrFreqs, func(fae *netlink.AttributeEncoder) error {
+		for i, channel := range channels {
+			fae.Nested(uint16(i), func(e *netlink.AttributeEncoder) error {
+				channel(e)
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func TestParsePhy(t *testing.T) {
+	phys := decodePhyMessages(t,
+		func(ae *netlink.AttributeEncoder) {
+			ae.Uint32(nl80211.AttrWiphy, 0)
+			ae.String(nl80211.AttrWiphyName, "phy0")
+			ae.Nested(nl80211.AttrSupportedIftypes, func(nae *netlink.AttributeEncoder) error {
+				nae.Flag(uint16(InterfaceTypeMonitor), true)
+				return nil
+			})
+		},
+		func(ae *netlink.AttributeEncoder) {
+			ae.Uint32(nl80211.AttrWiphy, 0)
+			encodeBand(ae, Band2GHz, func(bae *netlink.AttributeEncoder) {
+				bae.Uint16(nl80211.BandAttrHtCapa, htCapWidth20_40)
+				encodeChannels(bae, func(e *netlink.AttributeEncoder) {
+					e.Uint32(nl80211.FrequencyAttrFreq, 2412)
+					e.Flag(nl80211.FrequencyAttrNoHt40Minus, true)
+				})
+			})
+		},
+		func(ae *netlink.AttributeEncoder) {
+			// The rest of the channels of the band come in another message
+			ae.Uint32(nl80211.AttrWiphy, 0)
+			encodeBand(ae, Band2GHz, func(bae *netlink.AttributeEncoder) {
+				encodeChannels(bae, func(e *netlink.AttributeEncoder) {
+					e.Uint32(nl80211.FrequencyAttrFreq, 2484)
+					e.Flag(nl80211.FrequencyAttrDisabled, true)
+				})
+			})
+		},
+		func(ae *netlink.AttributeEncoder) {
+			ae.Uint32(nl80211.AttrWiphy, 0)
+			encodeBand(ae, Band5GHz, func(bae *netlink.AttributeEncoder) {
+				bae.Uint16(nl80211.BandAttrHtCapa, htCapWidth20_40)
+				bae.Uint32(nl80211.BandAttrVhtCapa, vhtCapWidth160)
+				encodeChannels(bae, func(e *netlink.AttributeEncoder) {
+					e.Uint32(nl80211.FrequencyAttrFreq, 5260)
+					e.Flag(nl80211.FrequencyAttrNoIr, true)
+					e.Flag(nl80211.FrequencyAttrRadar, true)
+					e.Uint32(nl80211.FrequencyAttrDfsState, uint32(DFSAvailable))
+					e.Flag(nl80211.FrequencyAttrNo160mhz, true)
+					e.Uint32(nl80211.FrequencyAttrMaxTxPower, 2300)
+				})
+			})
+		},
+		func(ae *netlink.AttributeEncoder) {
+			ae.Uint32(nl80211.AttrWiphy, 1)
+			ae.String(nl80211.AttrWiphyName, "phy1")
+			encodeBand(ae, Band6GHz, func(bae *netlink.AttributeEncoder) {
+				bae.Nested(nl80211.BandAttrIftypeData, func(iae *netlink.AttributeEncoder) error {
+					iae.Nested(0, func(e *netlink.AttributeEncoder) error {
+						e.Bytes(nl80211.BandIftypeAttrHeCapPhy, []byte{hePHYWidth40_80In5GHz | hePHYWidth160In5GHz, 0})
+						return nil
+					})
+					return nil
+				})
+				encodeChannels(bae, func(e *netlink.AttributeEncoder) {
+					e.Uint32(nl80211.FrequencyAttrFreq, 5955)
+					e.Flag(nl80211.FrequencyAttrIndoorOnly, true)
+				})
+			})
+		},
+	)
+
+	want := []Phy{
+		{
+			Index:        0,
+			Name:         "phy0",
+			Capabilities: WiphyCapabilities{Iftypes: []InterfaceType{InterfaceTypeMonitor}},
+			Bands: []Band{
+				{
+					ID: Band2GHz,
+					Channels: []Channel{
+						{WiphyFrequency: WiphyFrequency{Frequency: 2412}, Number: 1, NoHT40Minus: true},
+						{WiphyFrequency: WiphyFrequency{Frequency: 2484, Disabled: true}, Number: 14},
+					},
+					HT:             true,
+					HTCapabilities: htCapWidth20_40,
+				},
+				{
+					ID: Band5GHz,
+					Channels: []Channel{
+						{
+							WiphyFrequency: WiphyFrequency{Frequency: 5260, NoIR: true, Radar: true, MaxTxPower: 2300},
+							Number:         52,
+							DFSState:       DFSAvailable,
+							No160MHz:       true,
+						},
+					},
+					HT:              true,
+					HTCapabilities:  htCapWidth20_40,
+					VHT:             true,
+					VHTCapabilities: vhtCapWidth160,
+				},
+			},
+		},
+		{
+			Index: 1,
+			Name:  "phy1",
+			Bands: []Band{
+				{
+					ID: Band6GHz,
+					Channels: []Channel{
+						{WiphyFrequency: WiphyFrequency{Frequency: 5955}, Number: 1, IndoorOnly: true},
+					},
+					HE:                true,
+					HEPHYCapabilities: []byte{hePHYWidth40_80In5GHz | hePHYWidth160In5GHz, 0},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(phys, want) {
+		t.Fatalf("phys:\n- want: %+v\n-  got: %+v", want, phys)
+	}
+
+	if got := len(phys[0].Channels()); got != 3 {
+		t.Errorf("phy0 has %v channels, want 3", got)
+	}
+	if phys[1].Band(Band2GHz) != nil {
+		t.Errorf("phy1 has a 2.4GHz band")
+	}
+}
+
+func TestBandWidths(t *testing.T) {
+	for _, test := range []struct {
+		band Band
+		want []ChannelWidth
+	}{
+		{Band{}, []ChannelWidth{Width20}},
+		{Band{HT: true}, []ChannelWidth{Width20}},
+		{Band{HT: true, HTCapabilities: htCapWidth20_40}, []ChannelWidth{Width20, Width40}},
+		{Band{HT: true, HTCapabilities: htCapWidth20_40, VHT: true}, []ChannelWidth{Width20, Width40, Width80}},
+		{Band{VHT: true, VHTCapabilities: vhtCapWidth160}, []ChannelWidth{Width20, Width80, Width160}},
+		{Band{VHT: true, VHTCapabilities: vhtCapWidth160_80P80}, []ChannelWidth{Width20, Width80, Width80P80, Width160}},
+		{Band{HE: true, HEPHYCapabilities: []byte{hePHYWidth40In2GHz}}, []ChannelWidth{Width20, Width40}},
+		{Band{HE: true, HEPHYCapabilities: []byte{hePHYWidth40_80In5GHz | hePHYWidth160In5GHz}}, []ChannelWidth{Width20, Width40, Width80, Width160}},
+	} {
+		if got := test.band.Widths(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: Widths() = %v, want %v", test.band, got, test.want)
+		}
+	}
+}
+
+func TestBandChannelWidths(t *testing.T) {
+	band := Band{HT: true, HTCapabilities: htCapWidth20_40, VHT: true, VHTCapabilities: vhtCapWidth160_80P80}
+
+	for _, test := range []struct {
+		channel Channel
+		want    []ChannelWidth
+	}{
+		{Channel{}, []ChannelWidth{Width20, Width40, Width80, Width80P80, Width160}},
+		{Channel{WiphyFrequency: WiphyFrequency{Disabled: true}}, nil},
+		{Channel{NoHT40Minus: true}, []ChannelWidth{Width20, Width40, Width80, Width80P80, Width160}},
+		{Channel{NoHT40Minus: true, NoHT40Plus: true}, []ChannelWidth{Width20, Width80, Width80P80, Width160}},
+		{Channel{No160MHz: true}, []ChannelWidth{Width20, Width40, Width80, Width80P80}},
+		{Channel{No80MHz: true, No160MHz: true}, []ChannelWidth{Width20, Width40}},
+	} {
+		if got := band.ChannelWidths(test.channel); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: ChannelWidths() = %v, want %v", test.channel, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/giacomoferretti/chopper-go/pkg/dot11"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// ScanTimeout is how long WaitScan waits for the firmware to report a
+// finished scan.
+const ScanTimeout = 10 * time.Second
+
+// ErrScanAborted is returned by WaitScan when the kernel aborts a scan.
+var ErrScanAborted = errors.New("scan aborted")
+
+// ErrEventsLost is returned by ScanEvents.Wait, ChannelEvents.Next and
+// LinkEvents.Next when notifications were dropped because the socket
+// overran. Its receive buffer is grown, but the awaited event may have been
+// one of the dropped ones.
+var ErrEventsLost = errors.New("netlink notifications lost")
+
+// BSS is a single BSS reported by a scan.
+type BSS struct {
+	BSSID     net.HardwareAddr
+	Frequency int
+	// Signal is expressed in dBm
+	Signal float64
+	SSID   string
+}
+
+func encodeScanRequest(ifindex int, frequencies []int, extra func(ae *netlink.AttributeEncoder)) ([]byte, error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrIfindex, uint32(ifindex))
+	if extra != nil {
+		extra(ae)
+	}
+	ae.Nested(nl80211.AttrScanFrequencies, func(nae *netlink.AttributeEncoder) error {
+		for i, frequency := range frequencies {
+			nae.Uint32(uint16(i), uint32(frequency))
+		}
+		return nil
+	})
+
+	return ae.Encode()
+}
+
+// TriggerScan asks the firmware to scan the given frequencies. The end of
+// the scan is reported through ScanEvents.
+func (c *Client) TriggerScan(ifindex int, frequencies []int) error {
+	data, err := encodeScanRequest(ifindex, frequencies, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandTriggerScan, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
+
+// StartSchedScan lets the firmware scan the given frequencies every
+// interval on its own.
+func (c *Client) StartSchedScan(ifindex int, frequencies []int, interval time.Duration) error {
+	data, err := encodeScanRequest(ifindex, frequencies, func(ae *netlink.AttributeEncoder) {
+		ae.Uint32(nl80211.AttrSchedScanInterval, uint32(interval/time.Millisecond))
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandStartSchedScan, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
+
+// StopSchedScan stops a scheduled scan started by StartSchedScan.
+func (c *Client) StopSchedScan(ifindex int) error {
+	data, err := ifindexAttribute(ifindex)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandStopSchedScan, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
+
+// ScanResults dumps the BSSes known to the kernel for the interface.
+func (c *Client) ScanResults(ifindex int) ([]BSS, error) {
+	data, err := ifindexAttribute(ifindex)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := c.execute(nl80211.CommandGetScan, netlink.Request|netlink.Dump, data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BSS, 0, len(msgs))
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		for ad.Next() {
+			if ad.Type() != nl80211.AttrBss {
+				continue
+			}
+
+			result, err := parseBSS(ad.Bytes())
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// parseBSS decodes the nested NL80211_ATTR_BSS attribute.
+func parseBSS(b []byte) (BSS, error) {
+	var result BSS
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return result, err
+	}
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.BssBssid:
+			result.BSSID = net.HardwareAddr(ad.Bytes())
+		case nl80211.BssFrequency:
+			result.Frequency = int(ad.Uint32())
+		case nl80211.BssSignalMbm:
+			result.Signal = float64(ad.Int32()) / 100
+		case nl80211.BssInformationElements:
+			result.SSID = dot11.SSID(dot11.Elements(ad.Bytes()))
+		}
+	}
+
+	return result, ad.Err()
+}
+
+// ScanEvents receives the notifications of the nl80211 "scan" multicast
+// group. It uses its own socket so notifications do not interleave with
+// replies to commands.
+type ScanEvents struct {
+	conn       *genetlink.Conn
+	readBuffer int
+}
+
+// ScanEvents subscribes to scan notifications.
+func (c *Client) ScanEvents() (*ScanEvents, error) {
+	_, family := c.current()
+	groupID, err := findMulticastGroup(family, "scan")
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to Netlink socket: %v", err)
+	}
+	if err := c.options.apply(conn, c.options.ReadBuffer); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if err := conn.JoinGroup(groupID); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &ScanEvents{conn: conn, readBuffer: c.options.ReadBuffer}, nil
+}
+
+func findMulticastGroup(family genetlink.Family, name string) (uint32, error) {
+	for _, group := range family.Groups {
+		if group.Name == name {
+			return group.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("multicast group %v not found in %v", name, family.Name)
+}
+
+// Wait blocks until one of the given commands is reported for ifindex or
+// the deadline expires, and returns the command received.
+func (e *ScanEvents) Wait(ifindex int, deadline time.Time, commands ...uint8) (uint8, error) {
+	if err := e.conn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	for {
+		msgs, _, err := e.conn.Receive()
+		if errors.Is(err, errNoBuffers) {
+			e.readBuffer = grownBuffer(e.readBuffer)
+			_ = e.conn.SetReadBuffer(e.readBuffer)
+			return 0, ErrEventsLost
+		} else if err != nil {
+			return 0, err
+		}
+
+		for _, msg := range msgs {
+			wanted := false
+			for _, command := range commands {
+				if msg.Header.Command == command {
+					wanted = true
+					break
+				}
+			}
+			if !wanted {
+				continue
+			}
+
+			ad, err := netlink.NewAttributeDecoder(msg.Data)
+			if err != nil {
+				return 0, err
+			}
+			index := -1
+			for ad.Next() {
+				if ad.Type() == nl80211.AttrIfindex {
+					index = int(ad.Uint32())
+				}
+			}
+			if err := ad.Err(); err != nil {
+				return 0, err
+			}
+
+			if index == ifindex {
+				return msg.Header.Command, nil
+			}
+		}
+	}
+}
+
+// WaitScan blocks until the kernel reports the end of a scan triggered on
+// ifindex, for at most ScanTimeout.
+func (e *ScanEvents) WaitScan(ifindex int) error {
+	command, err := e.Wait(ifindex, time.Now().Add(ScanTimeout),
+		nl80211.CommandNewScanResults, nl80211.CommandScanAborted)
+	if err != nil {
+		return err
+	}
+
+	if command == nl80211.CommandScanAborted {
+		return ErrScanAborted
+	}
+	return nil
+}
+
+// Close closes the notification socket.
+func (e *ScanEvents) Close() error {
+	return e.conn.Close()
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func TestParseBSS(t *testing.T) {
+	bssid := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(nl80211.BssBssid, bssid)
+	ae.Uint32(nl80211.BssFrequency, 2437)
+	ae.Int32(nl80211.BssSignalMbm, -4250)
+	ae.Bytes(nl80211.BssInformationElements, []byte{0, 4, 't', 'e', 's', 't'})
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := parseBSS(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := BSS{
+		BSSID:     bssid,
+		Frequency: 2437,
+		Signal:    -42.5,
+		SSID:      "test",
+	}
+	if !reflect.DeepEqual(want, result) {
+		t.Fatalf("parseBSS:\n- want: %+v\n-  got: %+v", want, result)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+// SurveyInfo holds the counters reported by NL80211_CMD_GET_SURVEY for a
+// single frequency. Times are cumulative and expressed in ms.
+type SurveyInfo struct {
+	Frequency int
+	Noise     int
+	InUse     bool
+	Time      uint64
+	TimeBusy  uint64
+	TimeRx    uint64
+}
+
+// Survey dumps the survey counters of the interface.
+func (c *Client) Survey(ifindex int) ([]SurveyInfo, error) {
+	data, err := ifindexAttribute(ifindex)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := c.execute(nl80211.CommandGetSurvey, netlink.Request|netlink.Dump, data)
+	if err != nil {
+		return nil, err
+	}
+
+	surveys := make([]SurveyInfo, 0, len(msgs))
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		for ad.Next() {
+			if ad.Type() != nl80211.AttrSurveyInfo {
+				continue
+			}
+
+			var info SurveyInfo
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				info = parseSurveyInfo(nad)
+				return nil
+			})
+			surveys = append(surveys, info)
+		}
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return surveys, nil
+}
+
+func parseSurveyInfo(ad *netlink.AttributeDecoder) SurveyInfo {
+	var info SurveyInfo
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.SurveyInfoFrequency:
+			info.Frequency = int(ad.Uint32())
+		case nl80211.SurveyInfoNoise:
+			info.Noise = int(ad.Int8())
+		case nl80211.SurveyInfoInUse:
+			info.InUse = true
+		case nl80211.SurveyInfoTime:
+			info.Time = ad.Uint64()
+		case nl80211.SurveyInfoTimeBusy:
+			info.TimeBusy = ad.Uint64()
+		case nl80211.SurveyInfoTimeRx:
+			info.TimeRx = ad.Uint64()
+		}
+	}
+
+	return info
+}
+
+// Activity returns the busy ratio (0-1) of every frequency observed
+// between the previous and the current survey, keyed by frequency.
+func Activity(previous []SurveyInfo, current []SurveyInfo) map[int]float64 {
+	old := make(map[int]SurveyInfo, len(previous))
+	for _, info := range previous {
+		old[info.Frequency] = info
+	}
+
+	activity := make(map[int]float64, len(current))
+	for _, info := range current {
+		before := old[info.Frequency]
+		if info.Time <= before.Time || info.TimeBusy < before.TimeBusy {
+			continue
+		}
+		activity[info.Frequency] = float64(info.TimeBusy-before.TimeBusy) / float64(info.Time-before.Time)
+	}
+
+	return activity
+}
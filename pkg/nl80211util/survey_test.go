@@ -0,0 +1,76 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func TestActivity(t *testing.T) {
+	previous := []SurveyInfo{
+		{Frequency: 2412, Time: 100, TimeBusy: 10},
+		{Frequency: 2437, Time: 100, TimeBusy: 50},
+		{Frequency: 2472, Time: 100, TimeBusy: 50},
+	}
+	current := []SurveyInfo{
+		{Frequency: 2412, Time: 200, TimeBusy: 60},
+		{Frequency: 2437, Time: 200, TimeBusy: 60},
+		{Frequency: 2462, Time: 100, TimeBusy: 25},
+		{Frequency: 2472, Time: 100, TimeBusy: 50},
+	}
+
+	result := Activity(previous, current)
+	want := map[int]float64{2412: 0.5, 2437: 0.1, 2462: 0.25}
+	if !reflect.DeepEqual(want, result) {
+		t.Fatalf("Activity:\n- want: %v\n-  got: %v", want, result)
+	}
+}
+
+func TestParseSurveyInfo(t *testing.T) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.SurveyInfoFrequency, 2437)
+	ae.Int8(nl80211.SurveyInfoNoise, -95)
+	ae.Flag(nl80211.SurveyInfoInUse, true)
+	ae.Uint64(nl80211.SurveyInfoTime, 1000)
+	ae.Uint64(nl80211.SurveyInfoTimeBusy, 250)
+	ae.Uint64(nl80211.SurveyInfoTimeRx, 100)
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := SurveyInfo{
+		Frequency: 2437,
+		Noise:     -95,
+		InUse:     true,
+		Time:      1000,
+		TimeBusy:  250,
+		TimeRx:    100,
+	}
+	if got := parseSurveyInfo(ad); !reflect.DeepEqual(want, got) {
+		t.Fatalf("parseSurveyInfo:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import "golang.org/x/sys/unix"
+
+// supported reports whether the platform has Netlink.
+const supported = true
+
+// Netlink and rtnetlink constants, which golang.org/x/sys/unix only
+// defines on Linux.
+const (
+	netlinkGeneric  = unix.NETLINK_GENERIC
+	netlinkRoute    = unix.NETLINK_ROUTE
+	rtmgrpLink      = unix.RTMGRP_LINK
+	rtmNewLink      = unix.RTM_NEWLINK
+	rtmDelLink      = unix.RTM_DELLINK
+	sizeofIfInfomsg = unix.SizeofIfInfomsg
+	arphrdRadiotap  = unix.ARPHRD_IEEE80211_RADIOTAP
+	iffUp           = unix.IFF_UP
+	iflaIfname      = unix.IFLA_IFNAME
+	afUnspec        = unix.AF_UNSPEC
+)
+
+var (
+	errNoBuffers error = unix.ENOBUFS
+	errBadFD     error = unix.EBADF
+)
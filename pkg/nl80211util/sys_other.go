@@ -0,0 +1,43 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import "errors"
+
+const supported = false
+
+// The values of Linux, so that messages are built the same everywhere.
+const (
+	netlinkGeneric  = 0x10
+	netlinkRoute    = 0x0
+	rtmgrpLink      = 0x1
+	rtmNewLink      = 0x10
+	rtmDelLink      = 0x11
+	sizeofIfInfomsg = 0x10
+	arphrdRadiotap  = 0x323
+	iffUp           = 0x1
+	iflaIfname      = 0x3
+	afUnspec        = 0x0
+)
+
+var (
+	errNoBuffers = errors.New("no buffer space available")
+	errBadFD     = errors.New("bad file descriptor")
+)
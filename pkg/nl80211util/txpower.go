@@ -0,0 +1,79 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+// TxPowerSetting selects how the driver picks the transmit power.
+type TxPowerSetting uint32
+
+const (
+	// TxPowerAutomatic lets the driver choose the transmit power.
+	TxPowerAutomatic TxPowerSetting = nl80211.TxPowerAutomatic
+	// TxPowerLimited caps the transmit power at the given level.
+	TxPowerLimited TxPowerSetting = nl80211.TxPowerLimited
+	// TxPowerFixed sets the transmit power to the given level.
+	TxPowerFixed TxPowerSetting = nl80211.TxPowerFixed
+)
+
+// TxPower is a transmit power setting. Level is in mBm (1/100 dBm) and is
+// ignored for TxPowerAutomatic.
+type TxPower struct {
+	Setting TxPowerSetting
+	Level   int
+}
+
+func (p TxPower) String() string {
+	switch p.Setting {
+	case TxPowerAutomatic:
+		return "auto"
+	case TxPowerLimited:
+		return fmt.Sprintf("limit %.2f dBm", float64(p.Level)/100)
+	case TxPowerFixed:
+		return fmt.Sprintf("fixed %.2f dBm", float64(p.Level)/100)
+	}
+	return fmt.Sprintf("TxPowerSetting(%d)", uint32(p.Setting))
+}
+
+// SetTxPower changes the transmit power of the radio the interface belongs
+// to. Some drivers reset it when retuning, so it may need to be applied
+// again after every channel change.
+func (c *Client) SetTxPower(ifindex int, power TxPower) error {
+	data, err := txPowerAttributes(ifindex, power)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandSetWiphy, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
+
+// txPowerAttributes encodes the arguments of SetTxPower.
+func txPowerAttributes(ifindex int, power TxPower) ([]byte, error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrIfindex, uint32(ifindex))
+	ae.Uint32(nl80211.AttrWiphyTxPowerSetting, uint32(power.Setting))
+	if power.Setting != TxPowerAutomatic {
+		ae.Uint32(nl80211.AttrWiphyTxPowerLevel, uint32(power.Level))
+	}
+	return ae.Encode()
+}
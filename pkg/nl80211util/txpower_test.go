@@ -0,0 +1,77 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func TestTxPowerAttributes(t *testing.T) {
+	tests := []struct {
+		power TxPower
+		want  map[uint16]uint32
+	}{
+		{
+			power: TxPower{Setting: TxPowerAutomatic, Level: 2000},
+			want: map[uint16]uint32{
+				nl80211.AttrIfindex:             3,
+				nl80211.AttrWiphyTxPowerSetting: nl80211.TxPowerAutomatic,
+			},
+		},
+		{
+			power: TxPower{Setting: TxPowerFixed, Level: 500},
+			want: map[uint16]uint32{
+				nl80211.AttrIfindex:             3,
+				nl80211.AttrWiphyTxPowerSetting: nl80211.TxPowerFixed,
+				nl80211.AttrWiphyTxPowerLevel:   500,
+			},
+		},
+		{
+			power: TxPower{Setting: TxPowerLimited, Level: 2000},
+			want: map[uint16]uint32{
+				nl80211.AttrIfindex:             3,
+				nl80211.AttrWiphyTxPowerSetting: nl80211.TxPowerLimited,
+				nl80211.AttrWiphyTxPowerLevel:   2000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.power.String(), func(t *testing.T) {
+			b, err := txPowerAttributes(3, tt.power)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ad, err := netlink.NewAttributeDecoder(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[uint16]uint32)
+			for ad.Next() {
+				got[ad.Type()] = ad.Uint32()
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Fatalf("txPowerAttributes(%v):\n- want: %v\n-  got: %v", tt.power, tt.want, got)
+			}
+		})
+	}
+}
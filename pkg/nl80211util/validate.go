@@ -0,0 +1,225 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/pkg/wifichan"
+)
+
+// Reason is why Validate rejects a channel.
+type Reason int
+
+const (
+	// ReasonNotChannel frequencies are not the center of a channel.
+	ReasonNotChannel Reason = iota + 1
+	// ReasonUnsupported channels are not supported by the hardware.
+	ReasonUnsupported
+	// ReasonDisabled channels are disabled by the regulatory domain.
+	ReasonDisabled
+	// ReasonDFS channels need radar detection, excluded by the options or,
+	// when transmitting, not cleared yet.
+	ReasonDFS
+	// ReasonNoIR channels cannot be transmitted on first.
+	ReasonNoIR
+	// ReasonWidth channels cannot be used with the width.
+	ReasonWidth
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonNotChannel:
+		return "not a channel"
+	case ReasonUnsupported:
+		return "unsupported by the hardware"
+	case ReasonDisabled:
+		return "disabled by the regulatory domain"
+	case ReasonDFS:
+		return "DFS"
+	case ReasonNoIR:
+		return "no initiating radiation"
+	case ReasonWidth:
+		return "width unavailable"
+	}
+	return fmt.Sprintf("reason %d", int(r))
+}
+
+// ChannelError explains why Validate rejects a frequency.
+type ChannelError struct {
+	// Frequency in MHz.
+	Frequency int
+	Reason    Reason
+	// Detail is a sentence fragment with the precise cause, e.g. "the
+	// radio does not support the 6GHz band".
+	Detail string
+}
+
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("%v MHz: %v", e.Frequency, e.Detail)
+}
+
+// ValidateOptions are the requirements Validate checks channels against.
+type ValidateOptions struct {
+	// Width is the channel width, 20 MHz if 0. The primary 80 MHz segment
+	// is checked for Width80P80.
+	Width ChannelWidth
+	// NoDFS rejects the channels needing radar detection.
+	NoDFS bool
+	// Transmit rejects the channels that cannot be transmitted on: NO_IR
+	// ones and radar ones not cleared by a channel availability check.
+	// Monitoring needs neither.
+	Transmit bool
+}
+
+// channel returns the channel of the radio on frequency and its band.
+func (p *Phy) channel(frequency int) (*Band, *Channel) {
+	for i := range p.Bands {
+		band := &p.Bands[i]
+		for j := range band.Channels {
+			if band.Channels[j].Frequency == frequency {
+				return band, &band.Channels[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Validate checks that the radio can use the channel on frequency, in MHz,
+// with opts. The returned error is a *ChannelError.
+func (p *Phy) Validate(frequency int, opts ValidateOptions) error {
+	reject := func(reason Reason, format string, args ...interface{}) error {
+		return &ChannelError{Frequency: frequency, Reason: reason, Detail: fmt.Sprintf(format, args...)}
+	}
+
+	bandID, number := wifichan.ChannelOf(frequency)
+	if bandID == wifichan.BandUnknown {
+		return reject(ReasonNotChannel, "no channel is centered on it")
+	}
+	band, c := p.channel(frequency)
+	if c == nil {
+		for _, b := range p.Bands {
+			if b.ID.band() == bandID {
+				return reject(ReasonUnsupported, "the radio does not support it")
+			}
+		}
+		return reject(ReasonUnsupported, "the radio does not support the %v band", bandID)
+	}
+	if err := p.validateChannel(c, opts); err != nil {
+		err.Frequency = frequency
+		return err
+	}
+
+	width := widthMHz(opts.Width)
+	if width == wifichan.Width20 {
+		return nil
+	}
+	if !hasWidth(band.Widths(), opts.Width) {
+		return reject(ReasonWidth, "the radio does not support %v channels in the %v band", opts.Width.String(), bandID)
+	}
+	if !hasWidth(band.ChannelWidths(*c), opts.Width) {
+		return reject(ReasonWidth, "the regulatory domain does not allow %v on it", opts.Width.String())
+	}
+
+	center, err := wifichan.CenterChannel(bandID, number, width)
+	if err != nil {
+		return reject(ReasonWidth, "no %v channel contains it", width)
+	}
+	centerFrequency := wifichan.Frequency(bandID, center)
+	if width == wifichan.Width40 {
+		if centerFrequency > frequency && c.NoHT40Plus {
+			return reject(ReasonWidth, "the regulatory domain does not allow a secondary channel above it")
+		}
+		if centerFrequency < frequency && c.NoHT40Minus {
+			return reject(ReasonWidth, "the regulatory domain does not allow a secondary channel below it")
+		}
+	}
+
+	// Every 20 MHz channel of the wider one must be usable too
+	for f := centerFrequency - int(width)/2 + 10; f < centerFrequency+int(width)/2; f += 20 {
+		if f == frequency {
+			continue
+		}
+		_, sub := p.channel(f)
+		if sub == nil {
+			return reject(ReasonWidth, "%v channel %v includes %v MHz, which the radio does not support", width, center, f)
+		}
+		if err := p.validateChannel(sub, opts); err != nil {
+			return reject(err.Reason, "%v channel %v includes %v MHz, %v", width, center, f, err.Detail)
+		}
+	}
+	return nil
+}
+
+// validateChannel checks the flags of a 20 MHz channel.
+func (p *Phy) validateChannel(c *Channel, opts ValidateOptions) *ChannelError {
+	reject := func(reason Reason, detail string) *ChannelError {
+		return &ChannelError{Frequency: c.Frequency, Reason: reason, Detail: detail}
+	}
+
+	switch {
+	case c.Disabled:
+		return reject(ReasonDisabled, "the regulatory domain disables it")
+	case c.Radar && opts.NoDFS:
+		return reject(ReasonDFS, "it needs radar detection (DFS)")
+	case opts.Transmit && c.NoIR:
+		return reject(ReasonNoIR, "the regulatory domain forbids initiating transmissions on it")
+	case opts.Transmit && c.Radar && c.DFSState == DFSUnavailable:
+		return reject(ReasonDFS, "a radar was detected on it recently")
+	case opts.Transmit && c.Radar && c.DFSState != DFSAvailable:
+		return reject(ReasonDFS, "it needs a channel availability check before transmitting")
+	}
+	return nil
+}
+
+// band returns the wifichan band of b.
+func (b BandID) band() wifichan.Band {
+	switch b {
+	case Band2GHz:
+		return wifichan.Band2GHz
+	case Band5GHz:
+		return wifichan.Band5GHz
+	case Band6GHz:
+		return wifichan.Band6GHz
+	case Band60GHz:
+		return wifichan.Band60GHz
+	}
+	return wifichan.BandUnknown
+}
+
+// widthMHz returns the width in MHz of w, of its primary segment for
+// Width80P80.
+func widthMHz(w ChannelWidth) wifichan.Width {
+	switch w {
+	case Width40:
+		return wifichan.Width40
+	case Width80, Width80P80:
+		return wifichan.Width80
+	case Width160:
+		return wifichan.Width160
+	}
+	return wifichan.Width20
+}
+
+func hasWidth(widths []ChannelWidth, w ChannelWidth) bool {
+	for _, width := range widths {
+		if width == w {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testPhy() *Phy {
+	band2 := Band{ID: Band2GHz, HT: true, HTCapabilities: htCapWidth20_40}
+	for f := 2412; f <= 2472; f += 5 {
+		band2.Channels = append(band2.Channels, Channel{WiphyFrequency: WiphyFrequency{Frequency: f}})
+	}
+	band2.Channels = append(band2.Channels, Channel{WiphyFrequency: WiphyFrequency{Frequency: 2484, Disabled: true}})
+	// Channel 11 cannot have its secondary above
+	band2.Channels[10].NoHT40Plus = true
+
+	band5 := Band{ID: Band5GHz, HT: true, HTCapabilities: htCapWidth20_40, VHT: true, VHTCapabilities: vhtCapWidth160}
+	for f := 5180; f <= 5320; f += 20 {
+		c := Channel{WiphyFrequency: WiphyFrequency{Frequency: f}}
+		if f >= 5260 {
+			c.Radar = true
+			c.NoIR = true
+		}
+		band5.Channels = append(band5.Channels, c)
+	}
+	band5.Channels[4].DFSState = DFSAvailable
+	band5.Channels[5].DFSState = DFSUnavailable
+	band5.Channels = append(band5.Channels,
+		Channel{WiphyFrequency: WiphyFrequency{Frequency: 5745}},
+		Channel{WiphyFrequency: WiphyFrequency{Frequency: 5765, Disabled: true}},
+		Channel{WiphyFrequency: WiphyFrequency{Frequency: 5785}, No160MHz: true},
+		Channel{WiphyFrequency: WiphyFrequency{Frequency: 5805}},
+		Channel{WiphyFrequency: WiphyFrequency{Frequency: 5825}, No80MHz: true},
+	)
+
+	return &Phy{Name: "phy0", Bands: []Band{band2, band5}}
+}
+
+func TestValidate(t *testing.T) {
+	phy := testPhy()
+
+	tests := []struct {
+		frequency int
+		opts      ValidateOptions
+		reason    Reason
+		detail    string
+	}{
+		{2412, ValidateOptions{}, 0, ""},
+		{2437, ValidateOptions{Width: Width20}, 0, ""},
+		{2413, ValidateOptions{}, ReasonNotChannel, "no channel"},
+		{900, ValidateOptions{}, ReasonNotChannel, "no channel"},
+		{2484, ValidateOptions{}, ReasonDisabled, "regulatory domain disables it"},
+		{5500, ValidateOptions{}, ReasonUnsupported, "does not support it"},
+		{5955, ValidateOptions{}, ReasonUnsupported, "does not support the 6GHz band"},
+
+		{5260, ValidateOptions{}, 0, ""},
+		{5260, ValidateOptions{NoDFS: true}, ReasonDFS, "radar detection"},
+		{5180, ValidateOptions{Transmit: true}, 0, ""},
+		{5260, ValidateOptions{Transmit: true}, ReasonNoIR, "initiating transmissions"},
+		{5745, ValidateOptions{Transmit: true}, 0, ""},
+
+		{2412, ValidateOptions{Width: Width40}, 0, ""},
+		{2462, ValidateOptions{Width: Width40}, 0, ""},
+		{2437, ValidateOptions{Width: Width80}, ReasonWidth, "does not support 80MHz channels in the 2.4GHz band"},
+		{5180, ValidateOptions{Width: Width80}, 0, ""},
+		{5180, ValidateOptions{Width: Width160}, 0, ""},
+		{5180, ValidateOptions{Width: Width160, NoDFS: true}, ReasonDFS, "160MHz channel 50 includes 5260 MHz, it needs radar detection"},
+		{5180, ValidateOptions{Width: Width80P80}, ReasonWidth, "does not support 80+80MHz channels"},
+		{5260, ValidateOptions{Width: Width80}, 0, ""},
+		{5745, ValidateOptions{Width: Width40}, ReasonDisabled, "40MHz channel 151 includes 5765 MHz, the regulatory domain disables it"},
+		{5785, ValidateOptions{Width: Width160}, ReasonWidth, "does not allow 160MHz on it"},
+		{5825, ValidateOptions{Width: Width40}, ReasonWidth, "40MHz channel 167 includes 5845 MHz, which the radio does not support"},
+		{5825, ValidateOptions{Width: Width80}, ReasonWidth, "does not allow 80MHz on it"},
+	}
+
+	for _, test := range tests {
+		err := phy.Validate(test.frequency, test.opts)
+		if test.reason == 0 {
+			if err != nil {
+				t.Errorf("Validate(%v, %+v) = %v", test.frequency, test.opts, err)
+			}
+			continue
+		}
+
+		var e *ChannelError
+		if !errors.As(err, &e) {
+			t.Errorf("Validate(%v, %+v) = %v, want a *ChannelError", test.frequency, test.opts, err)
+			continue
+		}
+		if e.Frequency != test.frequency || e.Reason != test.reason || !strings.Contains(e.Detail, test.detail) {
+			t.Errorf("Validate(%v, %+v) = %v (%v), want %v: ...%v...", test.frequency, test.opts, e, e.Reason, test.reason, test.detail)
+		}
+	}
+}
+
+func TestValidateDFSStates(t *testing.T) {
+	phy := testPhy()
+	// Only NO_IR blocks transmitting on the radar channels
+	for i := range phy.Bands[1].Channels {
+		phy.Bands[1].Channels[i].NoIR = false
+	}
+
+	for frequency, want := range map[int]string{
+		5260: "",
+		5280: "a radar was detected",
+		5300: "channel availability check",
+	} {
+		err := phy.Validate(frequency, ValidateOptions{Transmit: true})
+		if want == "" {
+			if err != nil {
+				t.Errorf("Validate(%v) = %v", frequency, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate(%v) = %v, want %q", frequency, err, want)
+		}
+	}
+}
+
+func TestValidateSecondaryChannel(t *testing.T) {
+	phy := testPhy()
+
+	// Channel 7 takes its secondary above, 11 below
+	phy.Bands[0].Channels[6].NoHT40Plus = true
+	if err := phy.Validate(2442, ValidateOptions{Width: Width40}); err == nil || !strings.Contains(err.Error(), "secondary channel above") {
+		t.Errorf("Validate(2442) = %v", err)
+	}
+	phy.Bands[0].Channels[10].NoHT40Minus = true
+	if err := phy.Validate(2462, ValidateOptions{Width: Width40}); err == nil {
+		t.Errorf("Validate(2462) did not fail with neither secondary channel")
+	}
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+// MonitorFlags selects which frames a monitor interface receives and how
+// it behaves. With no flags the driver default is used.
+type MonitorFlags uint32
+
+const (
+	// MonitorFCSFail passes frames with a bad FCS.
+	MonitorFCSFail MonitorFlags = 1 << nl80211.MntrFlagFcsfail
+	// MonitorPLCPFail passes frames with a bad PLCP.
+	MonitorPLCPFail MonitorFlags = 1 << nl80211.MntrFlagPlcpfail
+	// MonitorControl passes control frames.
+	MonitorControl MonitorFlags = 1 << nl80211.MntrFlagControl
+	// MonitorOtherBSS disables BSSID filtering.
+	MonitorOtherBSS MonitorFlags = 1 << nl80211.MntrFlagOtherBss
+	// MonitorCookFrames reports frames after processing by the stack.
+	MonitorCookFrames MonitorFlags = 1 << nl80211.MntrFlagCookFrames
+	// MonitorActive ACKs unicast frames sent to the interface address.
+	MonitorActive MonitorFlags = 1 << nl80211.MntrFlagActive
+)
+
+var monitorFlagNames = map[string]MonitorFlags{
+	"fcsfail":  MonitorFCSFail,
+	"plcpfail": MonitorPLCPFail,
+	"control":  MonitorControl,
+	"otherbss": MonitorOtherBSS,
+	"cook":     MonitorCookFrames,
+	"active":   MonitorActive,
+}
+
+// ParseMonitorFlags parses a comma-separated list of monitor flags, named
+// like in iw: fcsfail, plcpfail, control, otherbss, cook and active.
+func ParseMonitorFlags(input string) (MonitorFlags, error) {
+	var flags MonitorFlags
+	for _, name := range strings.Split(input, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		flag, ok := monitorFlagNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown monitor flag %v", name)
+		}
+		flags |= flag
+	}
+
+	return flags, nil
+}
+
+func (f MonitorFlags) String() string {
+	var names []string
+	for name, flag := range monitorFlagNames {
+		if f&flag != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// CreateMonitorInterface adds a monitor interface called name to the radio
+// and brings it up.
+func (c *Client) CreateMonitorInterface(phy int, name string, flags MonitorFlags) (*Interface, error) {
+	data, err := monitorInterfaceAttributes(phy, name, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := c.execute(nl80211.CommandNewInterface, netlink.Request, data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %v: %v", name, err)
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("cannot create %v: no reply", name)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(msgs[0].Data)
+	if err != nil {
+		return nil, err
+	}
+	iface := parseInterface(ad)
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := c.SetLinkUp(iface.Index); err != nil {
+		_ = c.DeleteInterface(iface.Index)
+		return nil, fmt.Errorf("cannot bring %v up: %v", name, err)
+	}
+	return &iface, nil
+}
+
+// monitorInterfaceAttributes encodes the arguments of
+// CreateMonitorInterface.
+func monitorInterfaceAttributes(phy int, name string, flags MonitorFlags) ([]byte, error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrWiphy, uint32(phy))
+	ae.String(nl80211.AttrIfname, name)
+	ae.Uint32(nl80211.AttrIftype, nl80211.IftypeMonitor)
+	encodeMonitorFlags(ae, flags)
+	return ae.Encode()
+}
+
+// encodeMonitorFlags adds NL80211_ATTR_MNTR_FLAGS, unless flags are 0.
+func encodeMonitorFlags(ae *netlink.AttributeEncoder, flags MonitorFlags) {
+	if flags == 0 {
+		return
+	}
+	ae.Nested(nl80211.AttrMntrFlags, func(nae *netlink.AttributeEncoder) error {
+		for flag := uint16(1); flag <= nl80211.MntrFlagMax; flag++ {
+			if flags&(1<<flag) != 0 {
+				nae.Flag(flag, true)
+			}
+		}
+		return nil
+	})
+}
+
+// SetInterfaceType changes the type of a wireless interface, e.g. from
+// managed to monitor, with flags for monitor interfaces. Most drivers
+// refuse while the interface is up: bring it down with SetLinkDown first
+// and up again with SetLinkUp.
+func (c *Client) SetInterfaceType(ifindex int, t InterfaceType, flags MonitorFlags) error {
+	data, err := interfaceTypeAttributes(ifindex, t, flags)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandSetInterface, netlink.Request|netlink.Acknowledge, data)
+	if err != nil {
+		return fmt.Errorf("cannot switch ifindex %v to %v: %v", ifindex, t, err)
+	}
+	return nil
+}
+
+// interfaceTypeAttributes encodes the arguments of SetInterfaceType.
+func interfaceTypeAttributes(ifindex int, t InterfaceType, flags MonitorFlags) ([]byte, error) {
+	if flags != 0 && t != InterfaceTypeMonitor {
+		return nil, fmt.Errorf("monitor flags %v given for a %v interface", flags, t)
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrIfindex, uint32(ifindex))
+	ae.Uint32(nl80211.AttrIftype, uint32(t))
+	encodeMonitorFlags(ae, flags)
+	return ae.Encode()
+}
+
+// DeleteInterface removes a wireless interface.
+func (c *Client) DeleteInterface(ifindex int) error {
+	data, err := ifindexAttribute(ifindex)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(nl80211.CommandDelInterface, netlink.Request|netlink.Acknowledge, data)
+	return err
+}
+
+// SetLinkUp brings a network interface up with rtnetlink.
+func (c *Client) SetLinkUp(ifindex int) error {
+	return c.setLinkState(ifindex, true)
+}
+
+// SetLinkDown brings a network interface down with rtnetlink.
+func (c *Client) SetLinkDown(ifindex int) error {
+	return c.setLinkState(ifindex, false)
+}
+
+func (c *Client) setLinkState(ifindex int, up bool) error {
+	c.routeMu.Lock()
+	defer c.routeMu.Unlock()
+
+	if c.route == nil {
+		conn, err := netlink.Dial(netlinkRoute, nil)
+		if err != nil {
+			return fmt.Errorf("cannot connect to rtnetlink socket: %v", err)
+		}
+		c.route = conn
+	}
+	_, err := c.route.Execute(linkStateMessage(ifindex, up))
+	if socketError(err) {
+		// Dialed again on next use
+		_ = c.route.Close()
+		c.route = nil
+	}
+	return err
+}
+
+// SetLinkState brings a network interface up or down with rtnetlink, on a
+// socket of its own. A Client reuses one with SetLinkUp and SetLinkDown.
+func SetLinkState(ifindex int, up bool) error {
+	if !supported {
+		return ErrUnsupported
+	}
+	conn, err := netlink.Dial(netlinkRoute, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Execute(linkStateMessage(ifindex, up))
+	return err
+}
+
+// linkStateMessage is the RTM_NEWLINK request changing IFF_UP.
+func linkStateMessage(ifindex int, up bool) netlink.Message {
+	// struct ifinfomsg
+	data := make([]byte, sizeofIfInfomsg)
+	data[0] = afUnspec
+	nlenc.PutInt32(data[4:8], int32(ifindex))
+	if up {
+		nlenc.PutUint32(data[8:12], iffUp)
+	}
+	// Only change IFF_UP
+	nlenc.PutUint32(data[12:16], iffUp)
+
+	return netlink.Message{
+		Header: netlink.Header{
+			Type:  rtmNewLink,
+			Flags: netlink.Request | netlink.Acknowledge,
+		},
+		Data: data,
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+func TestParseMonitorFlags(t *testing.T) {
+	tests := []struct {
+		input  string
+		output MonitorFlags
+		err    bool
+	}{
+		{"", 0, false},
+		{"otherbss", MonitorOtherBSS, false},
+		{"control, FCSFail,otherbss", MonitorControl | MonitorFCSFail | MonitorOtherBSS, false},
+		{"active,cook,plcpfail", MonitorActive | MonitorCookFrames | MonitorPLCPFail, false},
+		{"promisc", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMonitorFlags(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseMonitorFlags(%v):\n- want error: %v\n-  got: %v", tt.input, tt.err, err)
+			}
+			if got != tt.output {
+				t.Fatalf("ParseMonitorFlags(%v):\n- want: %v\n-  got: %v", tt.input, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestMonitorInterfaceAttributes(t *testing.T) {
+	b, err := monitorInterfaceAttributes(1, "mon0", MonitorOtherBSS|MonitorFCSFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var flags []uint16
+	var name string
+	var phy, iftype uint32
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrWiphy:
+			phy = ad.Uint32()
+		case nl80211.AttrIfname:
+			name = ad.String()
+		case nl80211.AttrIftype:
+			iftype = ad.Uint32()
+		case nl80211.AttrMntrFlags:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					flags = append(flags, nad.Type())
+				}
+				return nil
+			})
+		}
+	}
+	if err := ad.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if phy != 1 || name != "mon0" || iftype != nl80211.IftypeMonitor {
+		t.Fatalf("monitorInterfaceAttributes: got phy %v, name %v, type %v", phy, name, iftype)
+	}
+	want := []uint16{nl80211.MntrFlagFcsfail, nl80211.MntrFlagOtherBss}
+	if !reflect.DeepEqual(want, flags) {
+		t.Fatalf("monitorInterfaceAttributes flags:\n- want: %v\n-  got: %v", want, flags)
+	}
+}
+
+func TestInterfaceTypeAttributes(t *testing.T) {
+	b, err := interfaceTypeAttributes(4, InterfaceTypeMonitor, MonitorControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ifindex, iftype uint32
+	var flags []uint16
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrIfindex:
+			ifindex = ad.Uint32()
+		case nl80211.AttrIftype:
+			iftype = ad.Uint32()
+		case nl80211.AttrMntrFlags:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					flags = append(flags, nad.Type())
+				}
+				return nil
+			})
+		}
+	}
+	if err := ad.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ifindex != 4 || iftype != nl80211.IftypeMonitor || !reflect.DeepEqual(flags, []uint16{nl80211.MntrFlagControl}) {
+		t.Fatalf("interfaceTypeAttributes: got ifindex %v, type %v, flags %v", ifindex, iftype, flags)
+	}
+
+	if _, err := interfaceTypeAttributes(4, InterfaceTypeStation, MonitorControl); err == nil {
+		t.Fatal("interfaceTypeAttributes accepted monitor flags for a managed interface")
+	}
+}
+
+func TestLinkStateMessage(t *testing.T) {
+	for _, up := range []bool{true, false} {
+		msg := linkStateMessage(9, up)
+		if msg.Header.Type != rtmNewLink || msg.Header.Flags != netlink.Request|netlink.Acknowledge {
+			t.Fatalf("linkStateMessage(9, %v) header: %+v", up, msg.Header)
+		}
+		if len(msg.Data) != sizeofIfInfomsg {
+			t.Fatalf("linkStateMessage(9, %v): %v bytes", up, len(msg.Data))
+		}
+		ifindex := nlenc.Int32(msg.Data[4:8])
+		flags := nlenc.Uint32(msg.Data[8:12])
+		change := nlenc.Uint32(msg.Data[12:16])
+		if ifindex != 9 || (flags&iffUp != 0) != up || change != iffUp {
+			t.Fatalf("linkStateMessage(9, %v): ifindex %v, flags %#x, change %#x", up, ifindex, flags, change)
+		}
+	}
+}
+
+// Switching a managed interface to monitor mode, like
+// "ip link set wlan0 down; iw wlan0 set monitor otherbss; ip link set wlan0 up".
+func ExampleClient_SetInterfaceType() {
+	client, err := Dial()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	iface, err := client.InterfaceByName("wlan0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := client.SetLinkDown(iface.Index); err != nil {
+		log.Fatal(err)
+	}
+	if err := client.SetInterfaceType(iface.Index, InterfaceTypeMonitor, MonitorOtherBSS); err != nil {
+		log.Fatal(err)
+	}
+	if err := client.SetLinkUp(iface.Index); err != nil {
+		log.Fatal(err)
+	}
+}
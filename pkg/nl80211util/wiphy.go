@@ -0,0 +1,217 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// WiphyFrequency is a frequency supported by a radio, with the
+// restrictions of the current regulatory domain.
+type WiphyFrequency struct {
+	Frequency int
+	// Disabled frequencies cannot be tuned to.
+	Disabled bool
+	// NoIR frequencies may only be used passively, which is enough to
+	// monitor them.
+	NoIR bool
+	// Radar frequencies require DFS before transmitting.
+	Radar bool
+	// MaxTxPower is in mBm.
+	MaxTxPower int
+}
+
+// WiphyCapabilities are the interface types and features advertised by a
+// radio.
+type WiphyCapabilities struct {
+	// Iftypes are the interface types the radio supports.
+	Iftypes []InterfaceType
+	// Features are the NL80211_FEATURE_* flags.
+	Features uint32
+}
+
+// SupportsIftype reports whether interfaces of type t can be created.
+func (c WiphyCapabilities) SupportsIftype(t InterfaceType) bool {
+	for _, iftype := range c.Iftypes {
+		if iftype == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveMonitor reports whether monitor interfaces can ACK the frames sent
+// to their address, see MonitorActive.
+func (c WiphyCapabilities) ActiveMonitor() bool {
+	return c.Features&nl80211.FeatureActiveMonitor != 0
+}
+
+// FourAddrAP reports whether the radio can serve 4-address stations, which
+// get an AP/VLAN interface each. The kernel does not advertise 4-address
+// support of stations, their interfaces fail to be set up instead.
+func (c WiphyCapabilities) FourAddrAP() bool {
+	return c.SupportsIftype(InterfaceTypeAPVLAN)
+}
+
+// CheckMonitor returns an error naming what the radio lacks to create a
+// monitor interface with flags, rather than the EINVAL of the kernel.
+func (c WiphyCapabilities) CheckMonitor(flags MonitorFlags) error {
+	if !c.SupportsIftype(InterfaceTypeMonitor) {
+		return errors.New("the radio does not support monitor interfaces")
+	}
+	if flags&MonitorActive != 0 && !c.ActiveMonitor() {
+		return errors.New("the radio does not support active monitor interfaces, remove the active flag")
+	}
+	return nil
+}
+
+// WiphyCapabilities returns the interface types and features of the radio.
+func (c *Client) WiphyCapabilities(phy int) (WiphyCapabilities, error) {
+	msgs, err := c.dumpWiphy(phy)
+	if err != nil {
+		return WiphyCapabilities{}, err
+	}
+
+	var capabilities WiphyCapabilities
+	found := false
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return WiphyCapabilities{}, err
+		}
+		if parseWiphyCapabilities(ad, phy, &capabilities) {
+			found = true
+		}
+		if err := ad.Err(); err != nil {
+			return WiphyCapabilities{}, err
+		}
+	}
+	if !found {
+		return WiphyCapabilities{}, fmt.Errorf("no capabilities reported for phy%d", phy)
+	}
+	return capabilities, nil
+}
+
+// parseWiphyCapabilities adds the capabilities in a wiphy message of phy
+// to c, reporting whether the message was one of phy.
+func parseWiphyCapabilities(ad *netlink.AttributeDecoder, phy int, c *WiphyCapabilities) bool {
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrWiphy:
+			if int(ad.Uint32()) != phy {
+				return false
+			}
+		case nl80211.AttrSupportedIftypes:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					c.Iftypes = append(c.Iftypes, InterfaceType(nad.Type()))
+				}
+				return nil
+			})
+		case nl80211.AttrFeatureFlags:
+			c.Features = ad.Uint32()
+		}
+	}
+	return true
+}
+
+// dumpWiphy dumps the description of a radio, or of all of them if phy is
+// negative, split in several messages.
+func (c *Client) dumpWiphy(phy int) ([]genetlink.Message, error) {
+	ae := netlink.NewAttributeEncoder()
+	if phy >= 0 {
+		ae.Uint32(nl80211.AttrWiphy, uint32(phy))
+	}
+	// Bands do not fit in a single message of the legacy format
+	ae.Flag(nl80211.AttrSplitWiphyDump, true)
+	data, err := ae.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.execute(nl80211.CommandGetWiphy, netlink.Request|netlink.Dump, data)
+}
+
+// WiphyFrequencies returns the frequencies of all the bands of the radio.
+func (c *Client) WiphyFrequencies(phy int) ([]WiphyFrequency, error) {
+	msgs, err := c.dumpWiphy(phy)
+	if err != nil {
+		return nil, err
+	}
+
+	var frequencies []WiphyFrequency
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		frequencies = append(frequencies, parseWiphyBands(ad, phy)...)
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(frequencies) == 0 {
+		return nil, fmt.Errorf("no frequencies reported for phy%d", phy)
+	}
+	return frequencies, nil
+}
+
+// parseWiphyBands returns the frequencies in a wiphy message of phy. Other
+// radios are skipped, as some kernels ignore the filter of a dump.
+func parseWiphyBands(ad *netlink.AttributeDecoder, phy int) []WiphyFrequency {
+	var frequencies []WiphyFrequency
+	for ad.Next() {
+		switch ad.Type() {
+		case nl80211.AttrWiphy:
+			if int(ad.Uint32()) != phy {
+				return nil
+			}
+		case nl80211.AttrWiphyBands:
+			ad.Nested(func(bands *netlink.AttributeDecoder) error {
+				for bands.Next() {
+					bands.Nested(func(band *netlink.AttributeDecoder) error {
+						for band.Next() {
+							if band.Type() == nl80211.BandAttrFreqs {
+								band.Nested(func(freqs *netlink.AttributeDecoder) error {
+									for freqs.Next() {
+										freqs.Nested(func(nad *netlink.AttributeDecoder) error {
+											frequencies = append(frequencies, parseWiphyFrequency(nad))
+											return nil
+										})
+									}
+									return nil
+								})
+							}
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+	}
+	return frequencies
+}
+
+func parseWiphyFrequency(ad *netlink.AttributeDecoder) WiphyFrequency {
+	return parseChannel(ad).WiphyFrequency
+}
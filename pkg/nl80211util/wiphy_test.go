@@ -0,0 +1,155 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nl80211util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giacomoferretti/chopper-go/internal/nl80211"
+	"github.com/mdlayher/netlink"
+)
+
+func encodeWiphyBands(t *testing.T, phy uint32, bands [][]WiphyFrequency) []byte {
+	t.Helper()
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrWiphy, phy)
+	ae.Nested(nl80211.AttrWiphyBands, func(nae *netlink.AttributeEncoder) error {
+		for i, band := range bands {
+			nae.Nested(uint16(i), func(bae *netlink.AttributeEncoder) error {
+				bae.Nested(nl80211.BandAttrFreqs, func(fae *netlink.AttributeEncoder) error {
+					for j, f := range band {
+						f := f
+						fae.Nested(uint16(j), func(e *netlink.AttributeEncoder) error {
+							e.Uint32(nl80211.FrequencyAttrFreq, uint32(f.Frequency))
+							e.Flag(nl80211.FrequencyAttrDisabled, f.Disabled)
+							e.Flag(nl80211.FrequencyAttrNoIr, f.NoIR)
+							e.Flag(nl80211.FrequencyAttrRadar, f.Radar)
+							e.Uint32(nl80211.FrequencyAttrMaxTxPower, uint32(f.MaxTxPower))
+							return nil
+						})
+					}
+					return nil
+				})
+				return nil
+			})
+		}
+		return nil
+	})
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseWiphyBands(t *testing.T) {
+	bands := [][]WiphyFrequency{
+		{{Frequency: 2412, MaxTxPower: 2000}, {Frequency: 2484, Disabled: true}},
+		{{Frequency: 5260, NoIR: true, Radar: true, MaxTxPower: 2300}},
+	}
+
+	ad, err := netlink.NewAttributeDecoder(encodeWiphyBands(t, 1, bands))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := parseWiphyBands(ad, 1)
+	want := append(append([]WiphyFrequency(nil), bands[0]...), bands[1]...)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseWiphyBands:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	ad, err = netlink.NewAttributeDecoder(encodeWiphyBands(t, 0, bands))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseWiphyBands(ad, 1); got != nil {
+		t.Fatalf("parseWiphyBands of another radio: %+v", got)
+	}
+}
+
+func encodeWiphyCapabilities(t *testing.T, phy uint32, iftypes []InterfaceType, features uint32) []byte {
+	t.Helper()
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nl80211.AttrWiphy, phy)
+	ae.Nested(nl80211.AttrSupportedIftypes, func(nae *netlink.AttributeEncoder) error {
+		for _, iftype := range iftypes {
+			nae.Flag(uint16(iftype), true)
+		}
+		return nil
+	})
+	ae.Uint32(nl80211.AttrFeatureFlags, features)
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseWiphyCapabilities(t *testing.T) {
+	iftypes := []InterfaceType{InterfaceTypeStation, InterfaceTypeAP, InterfaceTypeAPVLAN, InterfaceTypeMonitor}
+	ad, err := netlink.NewAttributeDecoder(encodeWiphyCapabilities(t, 1, iftypes, nl80211.FeatureActiveMonitor))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got WiphyCapabilities
+	if !parseWiphyCapabilities(ad, 1, &got) {
+		t.Fatal("parseWiphyCapabilities ignored the radio")
+	}
+	want := WiphyCapabilities{Iftypes: iftypes, Features: nl80211.FeatureActiveMonitor}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseWiphyCapabilities:\n- want: %+v\n-  got: %+v", want, got)
+	}
+	if !got.ActiveMonitor() || !got.FourAddrAP() || got.SupportsIftype(InterfaceTypeWDS) {
+		t.Fatalf("unexpected capabilities of %+v", got)
+	}
+
+	ad, err = netlink.NewAttributeDecoder(encodeWiphyCapabilities(t, 0, iftypes, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var other WiphyCapabilities
+	if parseWiphyCapabilities(ad, 1, &other) || other.Iftypes != nil {
+		t.Fatalf("parseWiphyCapabilities of another radio: %+v", other)
+	}
+}
+
+func TestCheckMonitor(t *testing.T) {
+	passive := WiphyCapabilities{Iftypes: []InterfaceType{InterfaceTypeStation, InterfaceTypeMonitor}}
+	active := WiphyCapabilities{Iftypes: passive.Iftypes, Features: nl80211.FeatureActiveMonitor}
+	station := WiphyCapabilities{Iftypes: []InterfaceType{InterfaceTypeStation}}
+
+	tests := []struct {
+		name         string
+		capabilities WiphyCapabilities
+		flags        MonitorFlags
+		ok           bool
+	}{
+		{"passive", passive, MonitorOtherBSS, true},
+		{"active", active, MonitorActive, true},
+		{"active unsupported", passive, MonitorActive | MonitorOtherBSS, false},
+		{"no monitor", station, 0, false},
+	}
+	for _, tt := range tests {
+		if err := tt.capabilities.CheckMonitor(tt.flags); (err == nil) != tt.ok {
+			t.Errorf("%v: CheckMonitor = %v", tt.name, err)
+		}
+	}
+}
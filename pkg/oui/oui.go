@@ -0,0 +1,174 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package oui maps MAC addresses to the vendor that registered their
+// organizationally unique identifier.
+//
+// It reads the Wireshark manuf file, including its /28 and /36 blocks, and
+// the IEEE oui.txt registry. A small list of common vendors is bundled.
+package oui
+
+import (
+	"bufio"
+	// Bundled OUIs
+	_ "embed"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+//go:embed oui.txt
+var bundled string
+
+// prefix is the first bits of a MAC address, in the low bits.
+type prefix struct {
+	bits  int
+	value uint64
+}
+
+// DB is a vendor database. It is safe for concurrent lookups once loaded.
+type DB struct {
+	vendors map[prefix]string
+	// lengths are the prefix lengths in vendors, longest first
+	lengths []int
+}
+
+// New returns an empty database.
+func New() *DB {
+	return &DB{vendors: make(map[prefix]string)}
+}
+
+// Bundled returns a database of the bundled OUIs.
+func Bundled() *DB {
+	db := New()
+	if err := db.Read(strings.NewReader(bundled)); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// Read adds the entries of a Wireshark manuf file or an IEEE oui.txt file,
+// replacing the known ones. Lines that are neither are ignored.
+func (db *DB) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// IEEE: "00-00-0C   (hex)		CISCO SYSTEMS, INC."
+		if i := strings.Index(line, "(hex)"); i > 0 {
+			p, err := parsePrefix(strings.TrimSpace(line[:i]))
+			if err != nil {
+				return fmt.Errorf("line %v: %v", n, err)
+			}
+			db.add(p, strings.TrimSpace(line[i+len("(hex)"):]))
+			continue
+		}
+
+		// Wireshark: "00:00:0C	Cisco	Cisco Systems, Inc", the long name
+		// is optional
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || strings.Contains(fields[0], " ") {
+			continue
+		}
+		p, err := parsePrefix(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %v: %v", n, err)
+		}
+		name := strings.TrimSpace(fields[1])
+		if len(fields) > 2 && strings.TrimSpace(fields[2]) != "" {
+			name = strings.TrimSpace(fields[2])
+		}
+		db.add(p, name)
+	}
+	return scanner.Err()
+}
+
+func (db *DB) add(p prefix, vendor string) {
+	db.vendors[p] = vendor
+	for i, bits := range db.lengths {
+		if bits == p.bits {
+			return
+		}
+		if bits < p.bits {
+			db.lengths = append(db.lengths[:i], append([]int{p.bits}, db.lengths[i:]...)...)
+			return
+		}
+	}
+	db.lengths = append(db.lengths, p.bits)
+}
+
+// parsePrefix parses an OUI like 00:00:0C or 00-00-0C, or a block like
+// 00:1B:C5:00:00:00/36.
+func parsePrefix(s string) (prefix, error) {
+	bits := 0
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		var err error
+		if bits, err = strconv.Atoi(s[i+1:]); err != nil || bits <= 0 || bits > 48 {
+			return prefix{}, fmt.Errorf("invalid prefix length in %q", s)
+		}
+		s = s[:i]
+	}
+
+	hex := strings.NewReplacer(":", "", "-", "", ".", "").Replace(s)
+	length := 4 * len(hex)
+	if bits == 0 {
+		bits = length
+	}
+	if len(hex) < 6 || len(hex) > 12 || len(hex)%2 != 0 || bits > length {
+		return prefix{}, fmt.Errorf("invalid prefix %q", s)
+	}
+	value, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil {
+		return prefix{}, fmt.Errorf("invalid prefix %q", s)
+	}
+	return prefix{bits: bits, value: value >> uint(length-bits)}, nil
+}
+
+// Lookup returns the vendor of mac, or an empty string if it is unknown.
+// The longest registered block wins.
+func (db *DB) Lookup(mac net.HardwareAddr) string {
+	if len(mac) != 6 {
+		return ""
+	}
+	var addr uint64
+	for _, b := range mac {
+		addr = addr<<8 | uint64(b)
+	}
+	for _, bits := range db.lengths {
+		if vendor, ok := db.vendors[prefix{bits: bits, value: addr >> uint(48-bits)}]; ok {
+			return vendor
+		}
+	}
+	return ""
+}
+
+// Len returns the number of entries.
+func (db *DB) Len() int {
+	return len(db.vendors)
+}
+
+// LocallyAdministered reports whether mac is not globally unique, as set
+// by MAC address randomization.
+func LocallyAdministered(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x02 != 0
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oui
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	manuf := "# Wireshark manuf\n" +
+		"00:00:0C\tCisco\tCisco Systems, Inc\n" +
+		"00:1B:C5\tIeeeRegi\tIEEE Registration Authority\n" +
+		"00:1B:C5:00:00:00/36\tConvergi\tConverging Systems Inc.\n" +
+		"00:55:DA:00:00:00/28\tShinkoTe\n" +
+		"\n"
+	ieee := "OUI/MA-L                                                    Organization\n" +
+		"company_id                                                  Organization\n" +
+		"                                                            Address\n" +
+		"\n" +
+		"00-22-72   (hex)\t\tAmerican Micro-Fuel Device Corp.\n" +
+		"002272     (base 16)\t\tAmerican Micro-Fuel Device Corp.\n" +
+		"\t\t\t\t2181 Buchanan Loop\n"
+
+	db := New()
+	if err := db.Read(strings.NewReader(manuf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Read(strings.NewReader(ieee)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mac    string
+		vendor string
+	}{
+		{"00:00:0c:12:34:56", "Cisco Systems, Inc"},
+		{"00:1b:c5:00:00:01", "Converging Systems Inc."},
+		{"00:1b:c5:00:10:01", "IEEE Registration Authority"},
+		{"00:55:da:0f:ff:ff", "ShinkoTe"},
+		{"00:55:da:10:00:00", ""},
+		{"00:22:72:aa:bb:cc", "American Micro-Fuel Device Corp."},
+		{"02:00:00:00:00:01", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mac, func(t *testing.T) {
+			mac, err := net.ParseMAC(tt.mac)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := db.Lookup(mac); got != tt.vendor {
+				t.Fatalf("Lookup(%v):\n- want: %q\n-  got: %q", tt.mac, tt.vendor, got)
+			}
+		})
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, input := range []string{"00:00\tShort\n", "00:00:0C/30\tLong\n", "ZZ:00:0C\tHex\n", "00-00-XX   (hex)\t\tHex\n"} {
+		if err := New().Read(strings.NewReader(input)); err == nil {
+			t.Fatalf("Read(%q): no error", input)
+		}
+	}
+}
+
+func TestBundled(t *testing.T) {
+	db := Bundled()
+	if db.Len() < 50 {
+		t.Fatalf("bundled: %v entries", db.Len())
+	}
+	mac, _ := net.ParseMAC("b8:27:eb:00:00:01")
+	if vendor := db.Lookup(mac); vendor != "Raspberry Pi" {
+		t.Fatalf("Lookup(%v): %q", mac, vendor)
+	}
+}
+
+func TestLocallyAdministered(t *testing.T) {
+	for mac, local := range map[string]bool{"02:00:00:00:00:01": true, "da:a1:19:00:00:01": true, "00:11:22:33:44:55": false} {
+		addr, _ := net.ParseMAC(mac)
+		if got := LocallyAdministered(addr); got != local {
+			t.Fatalf("LocallyAdministered(%v):\n- want: %v\n-  got: %v", mac, local, got)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package pcapng writes capture files in the pcapng format, with the block
+// types needed to store frames captured on several channels: a section
+// header, interface descriptions, enhanced packet blocks and interface
+// statistics.
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Link types of interface descriptions.
+const (
+	LinkTypeIEEE80211         = 105
+	LinkTypeIEEE80211Radiotap = 127
+)
+
+// Block types.
+const (
+	blockSectionHeader  = 0x0a0d0d0a
+	blockInterface      = 0x00000001
+	blockStatistics     = 0x00000005
+	blockEnhancedPacket = 0x00000006
+)
+
+// Option codes.
+const (
+	optEnd         = 0
+	optComment     = 1
+	optShbUserAppl = 4
+	optIfName      = 2
+	optIfDesc      = 3
+	optIfTsResol   = 9
+	optIsbStart    = 2
+	optIsbEnd      = 3
+	optIsbIfRecv   = 4
+)
+
+const byteOrderMagic = 0x1a2b3c4d
+
+// Interface describes a capture interface. Packets reference it by the id
+// returned by Writer.AddInterface.
+type Interface struct {
+	LinkType    uint16
+	SnapLen     uint32
+	Name        string
+	Description string
+}
+
+// Writer writes a single section. Timestamps are stored with nanosecond
+// resolution. It is not safe for concurrent use.
+type Writer struct {
+	w          io.Writer
+	interfaces int
+}
+
+// NewWriter writes a section header to w, naming application as the
+// program that wrote it.
+func NewWriter(w io.Writer, application string) (*Writer, error) {
+	var body []byte
+	body = appendUint32(body, byteOrderMagic)
+	body = appendUint16(body, 1) // major version
+	body = appendUint16(body, 0) // minor version
+	// Section length is unknown
+	body = appendUint32(body, 0xffffffff)
+	body = appendUint32(body, 0xffffffff)
+	var options []byte
+	if application != "" {
+		options = appendOption(options, optShbUserAppl, []byte(application))
+	}
+	body = append(body, endOptions(options)...)
+
+	writer := &Writer{w: w}
+	return writer, writer.writeBlock(blockSectionHeader, body)
+}
+
+// AddInterface writes an interface description and returns its id.
+func (w *Writer) AddInterface(iface Interface) (int, error) {
+	var body []byte
+	body = appendUint16(body, iface.LinkType)
+	body = appendUint16(body, 0) // reserved
+	body = appendUint32(body, iface.SnapLen)
+	var options []byte
+	if iface.Name != "" {
+		options = appendOption(options, optIfName, []byte(iface.Name))
+	}
+	if iface.Description != "" {
+		options = appendOption(options, optIfDesc, []byte(iface.Description))
+	}
+	options = appendOption(options, optIfTsResol, []byte{9})
+	body = append(body, endOptions(options)...)
+
+	if err := w.writeBlock(blockInterface, body); err != nil {
+		return 0, err
+	}
+	w.interfaces++
+	return w.interfaces - 1, nil
+}
+
+// WritePacket writes a frame captured on interface id at ts. length is the
+// original length of the frame, data may be shorter if it was truncated.
+// comment is stored with the packet if not empty.
+func (w *Writer) WritePacket(id int, ts time.Time, data []byte, length int, comment string) error {
+	if id < 0 || id >= w.interfaces {
+		return fmt.Errorf("unknown interface %v", id)
+	}
+
+	var body []byte
+	body = appendUint32(body, uint32(id))
+	body = appendTimestamp(body, ts)
+	body = appendUint32(body, uint32(len(data)))
+	body = appendUint32(body, uint32(length))
+	body = append(body, data...)
+	body = pad(body)
+	if comment != "" {
+		body = append(body, endOptions(appendOption(nil, optComment, []byte(comment)))...)
+	}
+
+	return w.writeBlock(blockEnhancedPacket, body)
+}
+
+// Statistics describe what an interface captured between Start and End.
+type Statistics struct {
+	Start    time.Time
+	End      time.Time
+	Received uint64
+	Comment  string
+}
+
+// WriteStatistics writes the statistics of interface id, taken at End.
+// Wireshark lists them with their comment in the capture file properties.
+func (w *Writer) WriteStatistics(id int, stats Statistics) error {
+	if id < 0 || id >= w.interfaces {
+		return fmt.Errorf("unknown interface %v", id)
+	}
+
+	var body []byte
+	body = appendUint32(body, uint32(id))
+	body = appendTimestamp(body, stats.End)
+	var options []byte
+	if stats.Comment != "" {
+		options = appendOption(options, optComment, []byte(stats.Comment))
+	}
+	if !stats.Start.IsZero() {
+		options = appendOption(options, optIsbStart, appendTimestamp(nil, stats.Start))
+	}
+	options = appendOption(options, optIsbEnd, appendTimestamp(nil, stats.End))
+	options = appendOption(options, optIsbIfRecv, appendUint64(nil, stats.Received))
+	body = append(body, endOptions(options)...)
+
+	return w.writeBlock(blockStatistics, body)
+}
+
+// writeBlock writes a block, body must be padded to 32 bits.
+func (w *Writer) writeBlock(typ uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	block := make([]byte, 0, length)
+	block = appendUint32(block, typ)
+	block = appendUint32(block, length)
+	block = append(block, body...)
+	block = appendUint32(block, length)
+
+	_, err := w.w.Write(block)
+	return err
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = appendUint16(b, code)
+	b = appendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return pad(b)
+}
+
+// endOptions terminates a non-empty option list.
+func endOptions(options []byte) []byte {
+	if len(options) == 0 {
+		return nil
+	}
+	return appendOption(options, optEnd, nil)
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// appendTimestamp appends ts in nanoseconds, high 32 bits first.
+func appendTimestamp(b []byte, ts time.Time) []byte {
+	ns := uint64(ts.UnixNano())
+	b = appendUint32(b, uint32(ns>>32))
+	return appendUint32(b, uint32(ns))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+type block struct {
+	typ  uint32
+	body []byte
+}
+
+// readBlocks splits a pcapng file into blocks, checking both lengths.
+func readBlocks(t *testing.T, data []byte) []block {
+	t.Helper()
+
+	var blocks []block
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated block: %x", data)
+		}
+		typ := binary.LittleEndian.Uint32(data)
+		length := binary.LittleEndian.Uint32(data[4:])
+		if length%4 != 0 || int(length) > len(data) {
+			t.Fatalf("invalid block length %v", length)
+		}
+		if trailer := binary.LittleEndian.Uint32(data[length-4:]); trailer != length {
+			t.Fatalf("block lengths differ: %v and %v", length, trailer)
+		}
+		blocks = append(blocks, block{typ, data[8 : length-4]})
+		data = data[length:]
+	}
+	return blocks
+}
+
+// readOptions returns the options starting at b.
+func readOptions(t *testing.T, b []byte) map[uint16][]byte {
+	t.Helper()
+
+	options := make(map[uint16][]byte)
+	for len(b) >= 4 {
+		code := binary.LittleEndian.Uint16(b)
+		length := int(binary.LittleEndian.Uint16(b[2:]))
+		if code == optEnd {
+			return options
+		}
+		options[code] = b[4 : 4+length]
+		b = b[4+(length+3)/4*4:]
+	}
+	t.Fatalf("options are not terminated")
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "chopper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := w.AddInterface(Interface{LinkType: LinkTypeIEEE80211Radiotap, SnapLen: 65535, Name: "wlan0mon", Description: "channel 6"})
+	if err != nil || id != 0 {
+		t.Fatalf("AddInterface: %v, %v", id, err)
+	}
+	if id, err := w.AddInterface(Interface{LinkType: LinkTypeIEEE80211Radiotap, Name: "wlan0mon"}); err != nil || id != 1 {
+		t.Fatalf("AddInterface: %v, %v", id, err)
+	}
+	ts := time.Unix(1633089600, 123456789)
+	if err := w.WritePacket(1, ts, []byte{1, 2, 3, 4, 5}, 10, "retune"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(2, ts, nil, 0, ""); err == nil {
+		t.Fatal("no error for an unknown interface")
+	}
+
+	blocks := readBlocks(t, buf.Bytes())
+	if len(blocks) != 4 {
+		t.Fatalf("blocks:\n- want: 4\n-  got: %v", len(blocks))
+	}
+
+	shb := blocks[0]
+	if shb.typ != blockSectionHeader || binary.LittleEndian.Uint32(shb.body) != byteOrderMagic {
+		t.Fatalf("section header: %x", shb.body)
+	}
+	if appl := readOptions(t, shb.body[16:])[optShbUserAppl]; string(appl) != "chopper" {
+		t.Fatalf("shb_userappl: %q", appl)
+	}
+
+	idb := blocks[1]
+	if idb.typ != blockInterface || binary.LittleEndian.Uint16(idb.body) != LinkTypeIEEE80211Radiotap || binary.LittleEndian.Uint32(idb.body[4:]) != 65535 {
+		t.Fatalf("interface: %x", idb.body)
+	}
+	options := readOptions(t, idb.body[8:])
+	if string(options[optIfName]) != "wlan0mon" || string(options[optIfDesc]) != "channel 6" || !bytes.Equal(options[optIfTsResol], []byte{9}) {
+		t.Fatalf("interface options: %q", options)
+	}
+
+	epb := blocks[3]
+	if epb.typ != blockEnhancedPacket {
+		t.Fatalf("enhanced packet type: %x", epb.typ)
+	}
+	body := epb.body
+	ns := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+	if id := binary.LittleEndian.Uint32(body); id != 1 || ns != uint64(ts.UnixNano()) {
+		t.Fatalf("enhanced packet: interface %v, timestamp %v", id, ns)
+	}
+	if captured, length := binary.LittleEndian.Uint32(body[12:]), binary.LittleEndian.Uint32(body[16:]); captured != 5 || length != 10 {
+		t.Fatalf("enhanced packet lengths: %v, %v", captured, length)
+	}
+	if !bytes.Equal(body[20:25], []byte{1, 2, 3, 4, 5}) {
+		t.Fatalf("enhanced packet data: %x", body[20:25])
+	}
+	if comment := readOptions(t, body[28:])[optComment]; string(comment) != "retune" {
+		t.Fatalf("opt_comment: %q", comment)
+	}
+}
+
+func TestWriteStatistics(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.AddInterface(Interface{LinkType: LinkTypeIEEE80211Radiotap}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1633089600, 0)
+	end := start.Add(250 * time.Millisecond)
+	if err := w.WriteStatistics(0, Statistics{Start: start, End: end, Received: 42, Comment: "channel 6"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteStatistics(1, Statistics{End: end}); err == nil {
+		t.Fatal("no error for an unknown interface")
+	}
+
+	blocks := readBlocks(t, buf.Bytes())
+	isb := blocks[2]
+	if isb.typ != blockStatistics || binary.LittleEndian.Uint32(isb.body) != 0 {
+		t.Fatalf("statistics: %x", isb.body)
+	}
+	timestamp := func(b []byte) int64 {
+		return int64(binary.LittleEndian.Uint32(b))<<32 | int64(binary.LittleEndian.Uint32(b[4:]))
+	}
+	if ns := timestamp(isb.body[4:]); ns != end.UnixNano() {
+		t.Fatalf("statistics timestamp: %v", ns)
+	}
+	options := readOptions(t, isb.body[12:])
+	if string(options[optComment]) != "channel 6" {
+		t.Fatalf("opt_comment: %q", options[optComment])
+	}
+	if ns := timestamp(options[optIsbStart]); ns != start.UnixNano() {
+		t.Fatalf("isb_starttime: %v", ns)
+	}
+	if ns := timestamp(options[optIsbEnd]); ns != end.UnixNano() {
+		t.Fatalf("isb_endtime: %v", ns)
+	}
+	if received := binary.LittleEndian.Uint64(options[optIsbIfRecv]); received != 42 {
+		t.Fatalf("isb_ifrecv: %v", received)
+	}
+}
@@ -0,0 +1,135 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Fuzz targets need Go 1.18, run them with e.g.
+// go test -fuzz FuzzParse ./pkg/plan
+
+var parseSeeds = []string{
+	"",
+	"1,6,11",
+	" 1 , 6 ,11,",
+	"ch1,ch6",
+	"6g37,6g2,149",
+	"1x3,6x3,11x3,rest",
+	"0,00,1",
+	"99999999999",
+	"6g,6gx,x",
+	"1,,,6",
+	"rest",
+	"6+500k,6g37+250kx2,11+k",
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range parseSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		channels, _ := Parse(input)
+		for _, channel := range channels {
+			if channel <= 0 {
+				t.Fatalf("Parse(%q) returned channel %v", input, channel)
+			}
+		}
+
+		// Formatted plans parse back to the same channels
+		formatted := Format(channels)
+		again, err := Parse(formatted)
+		if err != nil {
+			t.Fatalf("Parse(%q): unexpected error %v", formatted, err)
+		}
+		if !reflect.DeepEqual(channels, again) {
+			t.Fatalf("Parse(Format(%v)) = %v", channels, again)
+		}
+	})
+}
+
+func FuzzParseMultipliers(f *testing.F) {
+	for _, seed := range parseSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		supported := Default()
+		channels, err := ParseMultipliers(input, supported)
+		if err != nil {
+			return
+		}
+
+		// Every channel comes from a term or from rest
+		listed := make(map[int]bool)
+		for _, term := range strings.Split(input, ",") {
+			term = strings.TrimSpace(term)
+			if term == "" || term == Rest {
+				continue
+			}
+			match := multiplierTerm.FindStringSubmatch(term)
+			if match == nil {
+				t.Fatalf("ParseMultipliers(%q) accepted term %q", input, term)
+			}
+			channel, _ := ParseChannel(match[1])
+			listed[channel] = true
+		}
+		for _, channel := range channels {
+			if channel <= 0 {
+				t.Fatalf("ParseMultipliers(%q) returned channel %v", input, channel)
+			}
+			if !listed[channel] && !contains(supported, channel) {
+				t.Fatalf("ParseMultipliers(%q) returned unexpected channel %v", input, channel)
+			}
+		}
+		if len(channels) > len(listed)*MaxMultiplier+len(supported) {
+			t.Fatalf("ParseMultipliers(%q) returned %v channels", input, len(channels))
+		}
+	})
+}
+
+func FuzzChannelOf(f *testing.F) {
+	for _, frequency := range []int{0, 2412, 2484, 2485, 5180, 5935, 5955, 7115, -5, 1 << 40} {
+		f.Add(frequency)
+	}
+	f.Fuzz(func(t *testing.T, frequency int) {
+		channel := ChannelOf(frequency)
+		if channel == 0 {
+			return
+		}
+		if got := Frequency(channel); got != frequency {
+			t.Fatalf("Frequency(ChannelOf(%v)) = %v", frequency, got)
+		}
+		// Channels with a frequency are written in a form parsed back
+		if got, err := ParseChannel(FormatChannel(channel)); err != nil || got != channel {
+			t.Fatalf("ParseChannel(FormatChannel(%v)) = %v, %v", channel, got, err)
+		}
+	})
+}
+
+func contains(channels []int, channel int) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
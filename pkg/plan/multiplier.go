@@ -0,0 +1,139 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxMultiplier is how many times per cycle ParseMultipliers accepts a
+// channel to be visited.
+const MaxMultiplier = 100
+
+// Rest is the ParseMultipliers term standing for all the channels that are
+// not listed explicitly.
+const Rest = "rest"
+
+var multiplierTerm = regexp.MustCompile(`^((?:6g)?[0-9]+(?:\+[0-9]+k)?)(?:x([0-9]+))?$`)
+
+// HasMultipliers reports whether input uses the syntax of ParseMultipliers
+// rather than the one of Parse.
+func HasMultipliers(input string) bool {
+	return strings.Contains(input, "x") || strings.Contains(input, Rest)
+}
+
+// ParseMultipliers parses a plan like "1x3,6x3,11x3,rest": channels 1, 6
+// and 11 are visited three times per cycle and the other supported channels
+// once. Repetitions are spread evenly across the cycle.
+func ParseMultipliers(input string, supported []int) ([]int, error) {
+	var order []int
+	weights := make(map[int]int)
+	add := func(channel int, weight int) {
+		if _, ok := weights[channel]; !ok {
+			order = append(order, channel)
+		}
+		weights[channel] += weight
+	}
+
+	rest := false
+	for _, term := range strings.Split(input, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if term == Rest {
+			rest = true
+			continue
+		}
+
+		match := multiplierTerm.FindStringSubmatch(term)
+		if match == nil {
+			return nil, fmt.Errorf("invalid plan term %q", term)
+		}
+
+		channel, err := ParseChannel(match[1])
+		if err != nil || channel == 0 {
+			return nil, fmt.Errorf("invalid channel in %q", term)
+		}
+		weight := 1
+		if match[2] != "" {
+			weight, err = strconv.Atoi(match[2])
+			if err != nil || weight == 0 {
+				return nil, fmt.Errorf("invalid multiplier in %q", term)
+			}
+		}
+		add(channel, weight)
+		// Repeated terms add up, spreading is quadratic in the total
+		if weights[channel] > MaxMultiplier {
+			return nil, fmt.Errorf("channel %v is visited more than %v times per cycle", FormatChannel(channel), MaxMultiplier)
+		}
+	}
+
+	if rest {
+		for _, channel := range supported {
+			if _, ok := weights[channel]; !ok {
+				add(channel, 1)
+			}
+		}
+	}
+
+	return spread(order, weights), nil
+}
+
+// spread returns a cycle where every channel appears weights[channel]
+// times. The most repeated channels are placed first, every total/weight
+// slots, and the others fill the gaps in plan order.
+func spread(channels []int, weights map[int]int) []int {
+	total := 0
+	for _, channel := range channels {
+		total += weights[channel]
+	}
+
+	byWeight := make([]int, len(channels))
+	copy(byWeight, channels)
+	sort.SliceStable(byWeight, func(i, j int) bool {
+		return weights[byWeight[i]] > weights[byWeight[j]]
+	})
+
+	slots := make([]int, total)
+	used := make([]bool, total)
+	free := func(from int) int {
+		for i := 0; i < total; i++ {
+			if slot := (from + i) % total; !used[slot] {
+				return slot
+			}
+		}
+		return -1
+	}
+
+	for _, channel := range byWeight {
+		w := weights[channel]
+		start := free(0)
+		for k := 0; k < w; k++ {
+			// round(k*total/w) slots after the first copy
+			slot := free(start + (2*k*total+w)/(2*w))
+			slots[slot] = channel
+			used[slot] = true
+		}
+	}
+
+	return slots
+}
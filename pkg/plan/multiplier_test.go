@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMultipliers(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		supported []int
+		output    []int
+		err       bool
+	}{
+		{
+			name:   "plain",
+			input:  "1,6,11",
+			output: []int{1, 6, 11},
+		},
+		{
+			name:   "pinned",
+			input:  "1x2,6",
+			output: []int{1, 6, 1},
+		},
+		{
+			name:      "rest",
+			input:     "1x3,6x3,11x3,rest",
+			supported: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+			output:    []int{1, 6, 11, 2, 3, 4, 1, 6, 11, 5, 7, 1, 6, 11, 8, 9, 10},
+		},
+		{
+			name:      "rest first",
+			input:     "rest, 6x2",
+			supported: []int{1, 6, 11},
+			output:    []int{6, 1, 6, 11},
+		},
+		{
+			name:   "offset",
+			input:  "6+500kx2,11",
+			output: []int{WithOffset(6, 500), 11, WithOffset(6, 500)},
+		},
+		{
+			name:   "repeated term",
+			input:  "1,1,6",
+			output: []int{1, 6, 1},
+		},
+		{
+			name:  "invalid term",
+			input: "1x,6",
+			err:   true,
+		},
+		{
+			name:  "zero multiplier",
+			input: "1x0",
+			err:   true,
+		},
+		{
+			name:  "huge multiplier",
+			input: "1x1000000000",
+			err:   true,
+		},
+		{
+			name:  "repeated multipliers",
+			input: "1x60,6,1x60",
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseMultipliers(tt.input, tt.supported)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseMultipliers(%v): unexpected error %v", tt.input, err)
+			}
+			if want, got := tt.output, result; !tt.err && !reflect.DeepEqual(want, got) {
+				t.Fatalf("ParseMultipliers(%v):\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+		})
+	}
+}
+
+func TestSpreadCounts(t *testing.T) {
+	weights := map[int]int{1: 3, 6: 3, 11: 3, 2: 1, 7: 1}
+	result := spread([]int{1, 6, 11, 2, 7}, weights)
+
+	counts := make(map[int]int)
+	for i, channel := range result {
+		counts[channel]++
+		if i > 0 && result[i-1] == channel {
+			t.Fatalf("spread: %v is repeated back to back in %v", channel, result)
+		}
+	}
+	if !reflect.DeepEqual(weights, counts) {
+		t.Fatalf("spread counts:\n- want: %v\n-  got: %v", weights, counts)
+	}
+}
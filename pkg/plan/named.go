@@ -0,0 +1,64 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	// Bundled plans
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed named.txt
+var namedPlans string
+
+// named parses the bundled plans.
+func named() map[string]string {
+	plans := make(map[string]string)
+	for _, line := range strings.Split(namedPlans, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		plans[fields[0]] = fields[1]
+	}
+
+	return plans
+}
+
+// Names returns the names of the bundled plans, sorted.
+func Names() []string {
+	plans := named()
+	names := make([]string, 0, len(plans))
+	for name := range plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Named returns a bundled plan, e.g. "us-2.4" or "all-6ghz-psc".
+func Named(name string) ([]int, error) {
+	spec, ok := named()[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown plan %v, available plans: %v", name, strings.Join(Names(), ", "))
+	}
+
+	return Parse(spec)
+}
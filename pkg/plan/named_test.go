@@ -0,0 +1,61 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			channels, err := Named(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(channels) == 0 {
+				t.Fatalf("Named(%v): empty plan", name)
+			}
+
+			for _, channel := range channels {
+				if Frequency(channel) == 0 {
+					t.Fatalf("Named(%v): unknown channel %v", name, channel)
+				}
+			}
+		})
+	}
+
+	if _, err := Named("mars-2.4"); err == nil {
+		t.Fatalf("Named: expected error")
+	}
+}
+
+func TestNamed6GHz(t *testing.T) {
+	channels, err := Named("all-6ghz-psc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frequencies := Frequencies(channels[:3])
+	if want, got := []int{5975, 6055, 6135}, frequencies; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Frequencies(%v):\n- want: %v\n-  got: %v", channels[:3], want, got)
+	}
+	if want, got := "6g5,6g21,6g37", Format(channels[:3]); want != got {
+		t.Fatalf("Format(%v):\n- want: %v\n-  got: %v", channels[:3], want, got)
+	}
+}
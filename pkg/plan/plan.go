@@ -0,0 +1,365 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package plan parses and transforms channel plans, the ordered list of
+// channels a hopper cycles through.
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giacomoferretti/chopper-go/pkg/wifichan"
+)
+
+// MaxWeight is how many times the busiest channel is visited per cycle by
+// Weighted.
+const MaxWeight = 3
+
+// Band6GHzBase is added to 6 GHz channel numbers, which overlap with the
+// 2.4 GHz and 5 GHz ones, to represent them in a plan. They are written as
+// 6g<number>, e.g. 6g37.
+const Band6GHzBase = 1000
+
+// OffsetUnit multiplies the frequency offset in kHz added to a channel to
+// represent it in a plan, below it is the channel itself. Such channels are
+// written <channel>+<offset>k, e.g. 6+500k for 2437.5 MHz.
+const OffsetUnit = 1 << 16
+
+// MaxOffset is the largest frequency offset in kHz, above it the channel
+// center frequency is a different MHz.
+const MaxOffset = 999
+
+var (
+	nonDigits  = regexp.MustCompile("[^0-9]+")
+	sixGHzTerm = regexp.MustCompile("^6g([0-9]+)$")
+	offsetTerm = regexp.MustCompile(`^(.*)\+\s*([0-9]+)k$`)
+)
+
+// Default returns the default plan, alternating between distant 2.4 GHz
+// channels.
+func Default() []int {
+	return []int{1, 8, 2, 9, 3, 10, 4, 11, 5, 12, 6, 13, 7}
+}
+
+// Band is a frequency band.
+type Band int
+
+const (
+	BandUnknown Band = iota
+	Band2GHz
+	Band5GHz
+	Band6GHz
+)
+
+func (b Band) String() string {
+	switch b {
+	case Band2GHz:
+		return "2.4GHz"
+	case Band5GHz:
+		return "5GHz"
+	case Band6GHz:
+		return "6GHz"
+	}
+	return "unknown"
+}
+
+// BandOf returns the band of a channel.
+func BandOf(channel int) Band {
+	channel = BaseChannel(channel)
+	switch {
+	case channel >= 1 && channel <= 14:
+		return Band2GHz
+	case channel >= 32 && channel <= 177:
+		return Band5GHz
+	case channel > Band6GHzBase && channel <= Band6GHzBase+233:
+		return Band6GHz
+	}
+	return BandUnknown
+}
+
+// Channel6GHz returns the plan channel of a 6 GHz channel number.
+func Channel6GHz(number int) int {
+	return Band6GHzBase + number
+}
+
+// WithOffset returns the plan channel of channel with its center moved by
+// offset kHz, from 0 to MaxOffset.
+func WithOffset(channel int, offset int) int {
+	return BaseChannel(channel) + offset*OffsetUnit
+}
+
+// BaseChannel returns a channel without its frequency offset.
+func BaseChannel(channel int) int {
+	return channel % OffsetUnit
+}
+
+// Offset returns the frequency offset of a channel in kHz.
+func Offset(channel int) int {
+	return channel / OffsetUnit
+}
+
+// FrequencyKHz returns the center frequency in kHz of a channel, including
+// its offset, or 0 if the channel is unknown.
+func FrequencyKHz(channel int) int {
+	frequency := Frequency(channel)
+	if frequency == 0 {
+		return 0
+	}
+	return frequency*1000 + Offset(channel)
+}
+
+// Frequency returns the center frequency in MHz of a channel, or 0 if the
+// channel is unknown. The offset of the channel is ignored, see
+// FrequencyKHz.
+func Frequency(channel int) int {
+	channel = BaseChannel(channel)
+	switch BandOf(channel) {
+	case Band2GHz:
+		return wifichan.Frequency(wifichan.Band2GHz, channel)
+	case Band5GHz:
+		return wifichan.Frequency(wifichan.Band5GHz, channel)
+	case Band6GHz:
+		return wifichan.Frequency(wifichan.Band6GHz, channel-Band6GHzBase)
+	}
+
+	return 0
+}
+
+// ChannelOf returns the channel of a center frequency in MHz, or 0 if the
+// frequency is not the center of a 20 MHz channel.
+func ChannelOf(frequency int) int {
+	var channel int
+	switch band, number := wifichan.ChannelOf(frequency); band {
+	case wifichan.Band2GHz, wifichan.Band5GHz:
+		channel = number
+	case wifichan.Band6GHz:
+		channel = Channel6GHz(number)
+	}
+
+	if channel == 0 || Frequency(channel) != frequency {
+		return 0
+	}
+	return channel
+}
+
+// Frequencies converts channels to frequencies, skipping unknown channels.
+func Frequencies(channels []int) []int {
+	frequencies := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		if frequency := Frequency(channel); frequency != 0 {
+			frequencies = append(frequencies, frequency)
+		}
+	}
+
+	return frequencies
+}
+
+// Parse parses a comma-separated list of channels. Non-digits are ignored,
+// except for the 6g prefix of 6 GHz channels, and zeros are skipped. Values
+// that cannot be parsed are skipped and the first such error is returned
+// along with the other channels.
+func Parse(input string) ([]int, error) {
+	ret := make([]int, 0)
+
+	// Split on comma
+	var firstErr error
+	for _, part := range strings.Split(input, ",") {
+		value, err := ParseChannel(part)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if value == 0 {
+			continue
+		}
+
+		ret = append(ret, value)
+	}
+
+	return ret, firstErr
+}
+
+// ParseChannel parses one channel of a plan, e.g. 6, 6g37 or 6+500k,
+// returning 0 if there is none.
+func ParseChannel(part string) (int, error) {
+	if match := offsetTerm.FindStringSubmatch(strings.TrimSpace(part)); match != nil {
+		offset, err := strconv.Atoi(match[2])
+		if err != nil || offset > MaxOffset {
+			return 0, fmt.Errorf("invalid frequency offset %vk, the maximum is %vk", match[2], MaxOffset)
+		}
+		channel, err := ParseChannel(match[1])
+		if err != nil || channel == 0 {
+			return channel, err
+		}
+		if Offset(channel) != 0 {
+			return 0, fmt.Errorf("cannot parse channel %v: more than one offset", part)
+		}
+		return WithOffset(channel, offset), nil
+	}
+
+	base := 0
+	if match := sixGHzTerm.FindStringSubmatch(strings.TrimSpace(part)); match != nil {
+		base = Band6GHzBase
+		part = match[1]
+	}
+
+	// Remove all non-digits
+	part = nonDigits.ReplaceAllString(part, "")
+	if part == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(part, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse channel %v: %v", part, err)
+	}
+	if value == 0 {
+		return 0, nil
+	}
+	if base+int(value) >= OffsetUnit {
+		return 0, fmt.Errorf("channel %v out of range", part)
+	}
+
+	return base + int(value), nil
+}
+
+// Format returns channels as a comma-separated list accepted by Parse.
+func Format(channels []int) string {
+	parts := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		parts = append(parts, FormatChannel(channel))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// FormatChannel returns a channel as accepted by Parse.
+func FormatChannel(channel int) string {
+	if offset := Offset(channel); offset != 0 {
+		return fmt.Sprintf("%v+%dk", FormatChannel(BaseChannel(channel)), offset)
+	}
+	if BandOf(channel) == Band6GHz {
+		return fmt.Sprintf("6g%d", channel-Band6GHzBase)
+	}
+	return strconv.Itoa(channel)
+}
+
+// Weighted sorts the channels by activity and repeats the busiest ones (up
+// to MaxWeight times) so they are visited more often within a cycle.
+// Repetitions are spread across the cycle.
+func Weighted(channels []int, activity map[int]float64) []int {
+	ranked := make([]int, len(channels))
+	copy(ranked, channels)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return activity[ranked[i]] > activity[ranked[j]]
+	})
+
+	highest := 0.0
+	for _, channel := range ranked {
+		if activity[channel] > highest {
+			highest = activity[channel]
+		}
+	}
+
+	weights := make(map[int]int, len(ranked))
+	for _, channel := range ranked {
+		weights[channel] = 1
+		if highest > 0 {
+			weights[channel] += int(activity[channel]/highest*(MaxWeight-1) + 0.5)
+		}
+	}
+
+	ret := make([]int, 0, len(ranked)*MaxWeight)
+	for round := 0; round < MaxWeight; round++ {
+		for _, channel := range ranked {
+			if weights[channel] > round {
+				ret = append(ret, channel)
+			}
+		}
+	}
+
+	return ret
+}
+
+// Rotate returns the plan starting at position offset, wrapping around.
+func Rotate(channels []int, offset int) []int {
+	ret := make([]int, 0, len(channels))
+	if len(channels) == 0 {
+		return ret
+	}
+
+	offset %= len(channels)
+	if offset < 0 {
+		offset += len(channels)
+	}
+	ret = append(ret, channels[offset:]...)
+	ret = append(ret, channels[:offset]...)
+
+	return ret
+}
+
+// StartAt returns the plan rotated to start at the first occurrence of
+// channel.
+func StartAt(channels []int, channel int) ([]int, error) {
+	for i, c := range channels {
+		if c == channel {
+			return Rotate(channels, i), nil
+		}
+	}
+
+	return nil, fmt.Errorf("channel %v is not in the plan", channel)
+}
+
+// Interleave alternates the channels of the 2.4 GHz and 5 GHz bands, e.g.
+// 1,36,6,40,11,44, so that neither band goes dark for long stretches. When a
+// band has more channels, the other one is spread evenly across the cycle.
+// The order within a band is kept and other channels are appended.
+func Interleave(channels []int) []int {
+	var low, high, other []int
+	for _, channel := range channels {
+		switch BandOf(channel) {
+		case Band2GHz:
+			low = append(low, channel)
+		case Band5GHz:
+			high = append(high, channel)
+		default:
+			other = append(other, channel)
+		}
+	}
+
+	// Merge on the ideal position of each channel within the cycle,
+	// (i+0.5)/n, preferring 2.4 GHz on ties.
+	ret := make([]int, 0, len(channels))
+	i, j := 0, 0
+	for i < len(low) || j < len(high) {
+		if j >= len(high) || (i < len(low) && (2*i+1)*len(high) <= (2*j+1)*len(low)) {
+			ret = append(ret, low[i])
+			i++
+		} else {
+			ret = append(ret, high[j])
+			j++
+		}
+	}
+
+	return append(ret, other...)
+}
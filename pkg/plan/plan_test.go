@@ -0,0 +1,366 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output []int
+	}{
+		{
+			name:   "correct",
+			input:  "1,2,3",
+			output: []int{1, 2, 3},
+		},
+		{
+			name:   "invalid_value",
+			input:  "0",
+			output: []int{},
+		},
+		{
+			name:   "commas_suffix",
+			input:  "1,,",
+			output: []int{1},
+		},
+		{
+			name:   "commas_prefix",
+			input:  ",,3",
+			output: []int{3},
+		},
+		{
+			name:   "words_prefix",
+			input:  "asd1,2,3",
+			output: []int{1, 2, 3},
+		},
+		{
+			name:   "words_suffix",
+			input:  "1asd,2,3",
+			output: []int{1, 2, 3},
+		},
+		{
+			name:   "words_in_between",
+			input:  "1a,sd2,3",
+			output: []int{1, 2, 3},
+		},
+		{
+			name:   "commas_only",
+			input:  ",,",
+			output: []int{},
+		},
+		{
+			name:   "spaces",
+			input:  "1 2 3",
+			output: []int{123},
+		},
+		{
+			name:   "6ghz",
+			input:  "1, 6g37,37",
+			output: []int{1, 1037, 37},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := Parse(tt.input)
+
+			if want, got := tt.output, result; !reflect.DeepEqual(want, got) {
+				t.Fatalf("Parse(%v):\n- want: %v\n-  got: %v", tt.input, want, got)
+			}
+		})
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	tests := []struct {
+		channel   int
+		frequency int
+	}{
+		{
+			channel:   1,
+			frequency: 2412,
+		},
+		{
+			channel:   2,
+			frequency: 2417,
+		},
+		{
+			channel:   3,
+			frequency: 2422,
+		},
+		{
+			channel:   4,
+			frequency: 2427,
+		},
+		{
+			channel:   5,
+			frequency: 2432,
+		},
+		{
+			channel:   6,
+			frequency: 2437,
+		},
+		{
+			channel:   7,
+			frequency: 2442,
+		},
+		{
+			channel:   8,
+			frequency: 2447,
+		},
+		{
+			channel:   9,
+			frequency: 2452,
+		},
+		{
+			channel:   10,
+			frequency: 2457,
+		},
+		{
+			channel:   11,
+			frequency: 2462,
+		},
+		{
+			channel:   12,
+			frequency: 2467,
+		},
+		{
+			channel:   13,
+			frequency: 2472,
+		},
+		{
+			channel:   14,
+			frequency: 2484,
+		},
+		{
+			channel:   15,
+			frequency: 0,
+		},
+		{
+			channel:   31,
+			frequency: 0,
+		},
+		{
+			channel:   36,
+			frequency: 5180,
+		},
+		{
+			channel:   165,
+			frequency: 5825,
+		},
+		{
+			channel:   177,
+			frequency: 5885,
+		},
+		{
+			channel:   178,
+			frequency: 0,
+		},
+		{
+			channel:   -1,
+			frequency: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.channel), func(t *testing.T) {
+			if want, got := tt.frequency, Frequency(tt.channel); want != got {
+				t.Fatalf("Frequency(%v):\n- want: %v\n-  got: %v", tt.channel, want, got)
+			}
+		})
+	}
+}
+
+func TestChannelOf(t *testing.T) {
+	tests := []struct {
+		frequency int
+		channel   int
+	}{
+		{2412, 1},
+		{2472, 13},
+		{2484, 14},
+		{2414, 0},
+		{5180, 36},
+		{5885, 177},
+		{5935, Channel6GHz(2)},
+		{5955, Channel6GHz(1)},
+		{6135, Channel6GHz(37)},
+		{7115, Channel6GHz(233)},
+		{7120, 0},
+		{60480, 0},
+		{0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.frequency), func(t *testing.T) {
+			if want, got := tt.channel, ChannelOf(tt.frequency); want != got {
+				t.Fatalf("ChannelOf(%v):\n- want: %v\n-  got: %v", tt.frequency, want, got)
+			}
+		})
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []int
+		activity map[int]float64
+		output   []int
+	}{
+		{
+			name:     "no_activity",
+			channels: []int{1, 6, 11},
+			activity: map[int]float64{},
+			output:   []int{1, 6, 11},
+		},
+		{
+			name:     "one_busy",
+			channels: []int{1, 6, 11},
+			activity: map[int]float64{11: 0.8},
+			output:   []int{11, 1, 6, 11, 11},
+		},
+		{
+			name:     "graded",
+			channels: []int{1, 6, 11},
+			activity: map[int]float64{1: 0.2, 6: 0.4, 11: 0.1},
+			output:   []int{6, 1, 11, 6, 1, 11, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := tt.output, Weighted(tt.channels, tt.activity); !reflect.DeepEqual(want, got) {
+				t.Fatalf("Weighted(%v, %v):\n- want: %v\n-  got: %v", tt.channels, tt.activity, want, got)
+			}
+		})
+	}
+}
+
+func TestParseOutOfRange(t *testing.T) {
+	result, err := Parse("1,99999999999,6")
+	if err == nil {
+		t.Fatalf("Parse: expected error")
+	}
+	if want, got := []int{1, 6}, result; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Parse:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	result, err := Parse("1,6+500k,6g37+250k,11+1000k,36 + 5k")
+	if err == nil {
+		t.Fatalf("Parse: expected error")
+	}
+	want := []int{1, WithOffset(6, 500), WithOffset(Channel6GHz(37), 250), WithOffset(36, 5)}
+	if !reflect.DeepEqual(want, result) {
+		t.Fatalf("Parse:\n- want: %v\n-  got: %v", want, result)
+	}
+	if want, got := "1,6+500k,6g37+250k,36+5k", Format(result); want != got {
+		t.Fatalf("Format:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	for channel, frequency := range map[int]int{
+		WithOffset(6, 500):               2437500,
+		WithOffset(Channel6GHz(37), 250): 6135250,
+		36:                               5180000,
+		WithOffset(200, 500):             0,
+	} {
+		if got := FrequencyKHz(channel); got != frequency {
+			t.Errorf("FrequencyKHz(%v) = %v, want %v", FormatChannel(channel), got, frequency)
+		}
+	}
+	if got := BandOf(WithOffset(6, 500)); got != Band2GHz {
+		t.Errorf("BandOf(6+500k) = %v", got)
+	}
+
+	for _, input := range []string{"6+5k+5k", "70000", "6g65000"} {
+		if _, err := ParseChannel(input); err == nil {
+			t.Errorf("ParseChannel(%q): expected error", input)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if want, got := "1,6,11", Format([]int{1, 6, 11}); want != got {
+		t.Fatalf("Format:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []int
+		offset   int
+		output   []int
+	}{
+		{"zero", []int{1, 6, 11}, 0, []int{1, 6, 11}},
+		{"one", []int{1, 6, 11}, 1, []int{6, 11, 1}},
+		{"wrap", []int{1, 6, 11}, 4, []int{6, 11, 1}},
+		{"negative", []int{1, 6, 11}, -1, []int{11, 1, 6}},
+		{"empty", []int{}, 3, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := tt.output, Rotate(tt.channels, tt.offset); !reflect.DeepEqual(want, got) {
+				t.Fatalf("Rotate(%v, %v):\n- want: %v\n-  got: %v", tt.channels, tt.offset, want, got)
+			}
+		})
+	}
+}
+
+func TestStartAt(t *testing.T) {
+	result, err := StartAt([]int{1, 6, 11, 6}, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []int{6, 11, 6, 1}, result; !reflect.DeepEqual(want, got) {
+		t.Fatalf("StartAt:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	if _, err := StartAt([]int{1, 6, 11}, 3); err == nil {
+		t.Fatalf("StartAt: expected error")
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []int
+		output   []int
+	}{
+		{"even", []int{1, 6, 11, 36, 40, 44}, []int{1, 36, 6, 40, 11, 44}},
+		{"mixed input", []int{36, 1, 40, 44, 6, 11}, []int{1, 36, 6, 40, 11, 44}},
+		{"more 5GHz", []int{1, 6, 36, 40, 44, 48, 52, 56}, []int{36, 1, 40, 44, 48, 6, 52, 56}},
+		{"single band", []int{1, 6, 11}, []int{1, 6, 11}},
+		{"unknown", []int{1, 200, 36}, []int{1, 36, 200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := tt.output, Interleave(tt.channels); !reflect.DeepEqual(want, got) {
+				t.Fatalf("Interleave(%v):\n- want: %v\n-  got: %v", tt.channels, want, got)
+			}
+		})
+	}
+}
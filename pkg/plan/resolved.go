@@ -0,0 +1,360 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/wifichan"
+)
+
+// FileVersion is the version of the plan file format of Resolved.
+const FileVersion = 1
+
+// FileFormats are the encodings of plan files.
+var FileFormats = []string{"json", "yaml"}
+
+// Resolved is a plan as hopped: the channels in order with the width and
+// dwell of every hop, the strategy ordering them and the seed of its random
+// choices. It is the content of plan files, so plans can be generated by
+// other tools and shared exactly.
+type Resolved struct {
+	Version  int    `json:"version"`
+	Strategy string `json:"strategy,omitempty"`
+	// Seed is 0 when a new one is picked every run.
+	Seed int64 `json:"seed,omitempty"`
+	Hops []Hop `json:"hops"`
+}
+
+// Hop is a channel of a Resolved plan.
+type Hop struct {
+	Channel int
+	// Width is the channel width in MHz.
+	Width int
+	Dwell time.Duration
+}
+
+// hopJSON is the encoding of a Hop. The channel is written as in Format,
+// numbers are accepted too. Either the channel or the frequency is enough
+// when decoding.
+type hopJSON struct {
+	Channel   json.RawMessage `json:"channel,omitempty"`
+	Frequency int             `json:"frequency,omitempty"`
+	Width     int             `json:"width,omitempty"`
+	DwellMS   int64           `json:"dwell_ms"`
+}
+
+// NewResolved returns the plan hopping on channels, 20 MHz wide, for dwell
+// each.
+func NewResolved(channels []int, dwell time.Duration, strategy string, seed int64) *Resolved {
+	r := &Resolved{Version: FileVersion, Strategy: strategy, Seed: seed, Hops: make([]Hop, 0, len(channels))}
+	for _, channel := range channels {
+		r.Hops = append(r.Hops, Hop{Channel: channel, Width: int(wifichan.Width20), Dwell: dwell})
+	}
+	return r
+}
+
+// Channels returns the channels of the hops.
+func (r *Resolved) Channels() []int {
+	channels := make([]int, 0, len(r.Hops))
+	for _, hop := range r.Hops {
+		channels = append(channels, hop.Channel)
+	}
+	return channels
+}
+
+// MarshalJSON encodes the hop with its channel as in Format and its center
+// frequency.
+func (h Hop) MarshalJSON() ([]byte, error) {
+	channel, err := json.Marshal(FormatChannel(h.Channel))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(hopJSON{
+		Channel:   channel,
+		Frequency: Frequency(h.Channel),
+		Width:     h.Width,
+		DwellMS:   h.Dwell.Milliseconds(),
+	})
+}
+
+// UnmarshalJSON decodes a hop, defaulting to 20 MHz.
+func (h *Hop) UnmarshalJSON(b []byte) error {
+	var j hopJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	var channel int
+	if len(j.Channel) > 0 {
+		var name string
+		if err := json.Unmarshal(j.Channel, &name); err != nil {
+			if err := json.Unmarshal(j.Channel, &channel); err != nil {
+				return fmt.Errorf("invalid channel %s", j.Channel)
+			}
+		} else if channel, err = ParseChannel(name); err != nil {
+			return err
+		}
+	}
+	switch {
+	case channel == 0 && j.Frequency == 0:
+		return fmt.Errorf("hop without channel or frequency")
+	case channel == 0:
+		if channel = ChannelOf(j.Frequency); channel == 0 {
+			return fmt.Errorf("no channel on %v MHz", j.Frequency)
+		}
+	case Frequency(channel) == 0:
+		return fmt.Errorf("unknown channel %v", FormatChannel(channel))
+	case j.Frequency != 0 && j.Frequency != Frequency(channel):
+		return fmt.Errorf("channel %v is on %v MHz, not %v MHz", FormatChannel(channel), Frequency(channel), j.Frequency)
+	}
+
+	width := j.Width
+	switch wifichan.Width(width) {
+	case 0:
+		width = int(wifichan.Width20)
+	case wifichan.Width20, wifichan.Width40, wifichan.Width80, wifichan.Width160, wifichan.Width320:
+	default:
+		return fmt.Errorf("invalid width %v MHz", width)
+	}
+	if j.DwellMS <= 0 {
+		return fmt.Errorf("channel %v has no dwell", FormatChannel(channel))
+	}
+
+	*h = Hop{Channel: channel, Width: width, Dwell: time.Duration(j.DwellMS) * time.Millisecond}
+	return nil
+}
+
+// Encode writes the plan in format, one of FileFormats.
+func (r *Resolved) Encode(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case "yaml":
+		return r.encodeYAML(w)
+	}
+	return fmt.Errorf("unknown plan format %v, expected one of %v", format, strings.Join(FileFormats, ", "))
+}
+
+func (r *Resolved) encodeYAML(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version: %d\n", r.Version)
+	if r.Strategy != "" {
+		fmt.Fprintf(&b, "strategy: %s\n", strconv.Quote(r.Strategy))
+	}
+	if r.Seed != 0 {
+		fmt.Fprintf(&b, "seed: %d\n", r.Seed)
+	}
+	b.WriteString("hops:\n")
+	for _, hop := range r.Hops {
+		fmt.Fprintf(&b, "  - channel: %s\n", strconv.Quote(FormatChannel(hop.Channel)))
+		fmt.Fprintf(&b, "    frequency: %d\n", Frequency(hop.Channel))
+		fmt.Fprintf(&b, "    width: %d\n", hop.Width)
+		fmt.Fprintf(&b, "    dwell_ms: %d\n", hop.Dwell.Milliseconds())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Decode parses a plan file, in JSON or in the block style YAML written by
+// Encode.
+func Decode(data []byte) (*Resolved, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		doc, err := parseYAML(string(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var r Resolved
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Version != FileVersion {
+		return nil, fmt.Errorf("unsupported plan file version %v, expected %v", r.Version, FileVersion)
+	}
+	if len(r.Hops) == 0 {
+		return nil, fmt.Errorf("the plan has no hops")
+	}
+	return &r, nil
+}
+
+// parseYAML parses the subset of YAML plan files use: a mapping of scalars
+// and block sequences of mappings of scalars.
+func parseYAML(input string) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	var (
+		listKey    string
+		items      []interface{}
+		item       map[string]interface{}
+		itemIndent int
+	)
+
+	for n, line := range strings.Split(input, "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", n+1)
+		}
+		indent := len(line) - len(text)
+
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: unexpected list item", n+1)
+			}
+			item = make(map[string]interface{})
+			items = append(items, item)
+			doc[listKey] = items
+
+			rest := strings.TrimLeft(text[1:], " ")
+			if rest == "" {
+				// The keys of the item start on the next line
+				itemIndent = -1
+				continue
+			}
+			itemIndent = indent + len(text) - len(rest)
+			if err := parseYAMLEntry(rest, item); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n+1, err)
+			}
+			continue
+		}
+
+		if item != nil && indent > 0 {
+			if itemIndent == -1 {
+				itemIndent = indent
+			}
+			if indent != itemIndent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", n+1)
+			}
+			if err := parseYAMLEntry(text, item); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n+1, err)
+			}
+			continue
+		}
+		if indent != 0 {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n+1)
+		}
+
+		listKey, items, item = "", nil, nil
+		key, value, err := splitYAMLEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if value == "" {
+			listKey = key
+			doc[key] = []interface{}{}
+			continue
+		}
+		if doc[key], err = parseYAMLScalar(value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func parseYAMLEntry(text string, m map[string]interface{}) error {
+	key, value, err := splitYAMLEntry(text)
+	if err != nil {
+		return err
+	}
+	m[key], err = parseYAMLScalar(value)
+	return err
+}
+
+func splitYAMLEntry(text string) (string, string, error) {
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", fmt.Errorf("expected key: value, got %q", text)
+		}
+		i = len(text) - 1
+	}
+
+	key := strings.TrimSpace(text[:i])
+	if unquoted, err := strconv.Unquote(key); err == nil {
+		key = unquoted
+	}
+	return key, strings.TrimSpace(text[i+1:]), nil
+}
+
+func parseYAMLScalar(value string) (interface{}, error) {
+	switch {
+	case value == "~" || value == "null":
+		return nil, nil
+	case value == "true" || value == "false":
+		return value == "true", nil
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return nil, fmt.Errorf("unterminated string %v", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case strings.ContainsAny(value[:1], "[{&*!|>%@`"):
+		return nil, fmt.Errorf("unsupported YAML %v", value)
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, nil
+	}
+	return value, nil
+}
+
+// stripYAMLComment removes a # comment outside of quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			// Skip the escaped character
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
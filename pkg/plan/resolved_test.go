@@ -0,0 +1,236 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolvedRoundTrip(t *testing.T) {
+	r := NewResolved([]int{1, 36, Channel6GHz(37), WithOffset(6, 500)}, 100*time.Millisecond, "shuffle", 42)
+	r.Hops[1].Dwell = 250 * time.Millisecond
+	r.Hops[1].Width = 80
+
+	for _, format := range FileFormats {
+		var b bytes.Buffer
+		if err := r.Encode(&b, format); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", format, err, b.String())
+		}
+		if !reflect.DeepEqual(got, r) {
+			t.Errorf("%v:\n- want: %+v\n-  got: %+v", format, r, got)
+		}
+	}
+}
+
+func TestResolvedEncode(t *testing.T) {
+	r := NewResolved([]int{6, Channel6GHz(5)}, 100*time.Millisecond, "sequential", 7)
+
+	var b bytes.Buffer
+	if err := r.Encode(&b, "json"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": 1,
+  "strategy": "sequential",
+  "seed": 7,
+  "hops": [
+    {
+      "channel": "6",
+      "frequency": 2437,
+      "width": 20,
+      "dwell_ms": 100
+    },
+    {
+      "channel": "6g5",
+      "frequency": 5975,
+      "width": 20,
+      "dwell_ms": 100
+    }
+  ]
+}
+`
+	if b.String() != want {
+		t.Errorf("json:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := r.Encode(&b, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	want = `version: 1
+strategy: "sequential"
+seed: 7
+hops:
+  - channel: "6"
+    frequency: 2437
+    width: 20
+    dwell_ms: 100
+  - channel: "6g5"
+    frequency: 5975
+    width: 20
+    dwell_ms: 100
+`
+	if b.String() != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	if err := r.Encode(&b, "toml"); err == nil {
+		t.Errorf("Encode(toml) did not fail")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *Resolved
+		err   string
+	}{
+		{
+			name:  "json_numbers_and_frequencies",
+			input: `{"version": 1, "hops": [{"channel": 11, "dwell_ms": 200}, {"frequency": 5180, "dwell_ms": 150}]}`,
+			want: &Resolved{Version: 1, Hops: []Hop{
+				{Channel: 11, Width: 20, Dwell: 200 * time.Millisecond},
+				{Channel: 36, Width: 20, Dwell: 150 * time.Millisecond},
+			}},
+		},
+		{
+			name: "yaml_handwritten",
+			input: `# Generated by survey.py
+version: 1
+strategy: 'shuffle' # same order on every sensor
+seed: 1234567890123
+hops:
+- channel: 1
+  dwell_ms: 100
+-
+  channel: "6g37"
+  width: 40
+  dwell_ms: 300
+- frequency: 2437
+  dwell_ms: 100
+`,
+			want: &Resolved{Version: 1, Strategy: "shuffle", Seed: 1234567890123, Hops: []Hop{
+				{Channel: 1, Width: 20, Dwell: 100 * time.Millisecond},
+				{Channel: Channel6GHz(37), Width: 40, Dwell: 300 * time.Millisecond},
+				{Channel: 6, Width: 20, Dwell: 100 * time.Millisecond},
+			}},
+		},
+		{
+			name:  "version",
+			input: `{"version": 2, "hops": [{"channel": 1, "dwell_ms": 100}]}`,
+			err:   "version 2",
+		},
+		{
+			name:  "no_hops",
+			input: "version: 1\nhops:\n",
+			err:   "no hops",
+		},
+		{
+			name:  "no_dwell",
+			input: `{"version": 1, "hops": [{"channel": 1}]}`,
+			err:   "no dwell",
+		},
+		{
+			name:  "no_channel",
+			input: `{"version": 1, "hops": [{"width": 20, "dwell_ms": 100}]}`,
+			err:   "without channel",
+		},
+		{
+			name:  "unknown_channel",
+			input: `{"version": 1, "hops": [{"channel": "200", "dwell_ms": 100}]}`,
+			err:   "unknown channel 200",
+		},
+		{
+			name:  "frequency_mismatch",
+			input: `{"version": 1, "hops": [{"channel": 1, "frequency": 2437, "dwell_ms": 100}]}`,
+			err:   "not 2437 MHz",
+		},
+		{
+			name:  "not_a_channel",
+			input: `{"version": 1, "hops": [{"frequency": 2413, "dwell_ms": 100}]}`,
+			err:   "no channel on 2413",
+		},
+		{
+			name:  "width",
+			input: `{"version": 1, "hops": [{"channel": 1, "width": 30, "dwell_ms": 100}]}`,
+			err:   "invalid width 30",
+		},
+		{
+			name:  "yaml_flow",
+			input: "version: 1\nhops: [{channel: 1}]\n",
+			err:   "unsupported YAML",
+		},
+		{
+			name:  "yaml_indentation",
+			input: "version: 1\nhops:\n  - channel: 1\n     dwell_ms: 100\n",
+			err:   "line 4: unexpected indentation",
+		},
+		{
+			name:  "yaml_item_outside_list",
+			input: "version: 1\n- channel: 1\n",
+			err:   "line 2: unexpected list item",
+		},
+		{
+			name:  "yaml_tabs",
+			input: "version: 1\nhops:\n\t- channel: 1\n",
+			err:   "tabs",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode([]byte(test.input))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Decode() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Decode():\n- want: %+v\n-  got: %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestStripYAMLComment(t *testing.T) {
+	tests := map[string]string{
+		"seed: 1 # random":         "seed: 1 ",
+		"# comment":                "",
+		`strategy: "a # b" # c`:    `strategy: "a # b" `,
+		`strategy: "a \" # b" # c`: `strategy: "a \" # b" `,
+		"strategy: 'it''s' # x":    "strategy: 'it''s' ",
+		"channel: 6g37#x":          "channel: 6g37#x",
+	}
+	for line, want := range tests {
+		if got := stripYAMLComment(line); got != want {
+			t.Errorf("stripYAMLComment(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
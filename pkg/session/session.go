@@ -0,0 +1,217 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package session stores the events and survey snapshots of chopper runs in
+// a SQLite database, so they can be queried after the run.
+//
+// Every run is a row of the sessions table. Hops, errors, discovered BSSs
+// and survey snapshots reference it, and the channel_stats and survey_stats
+// views aggregate them per channel. Times are stored as UTC text in
+// timeFormat, which SQLite date functions understand.
+package session
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+	"github.com/giacomoferretti/chopper-go/pkg/plan"
+
+	// SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY,
+	interface TEXT,
+	started TEXT NOT NULL,
+	stopped TEXT,
+	channels TEXT,
+	delay_ms INTEGER
+);
+CREATE TABLE IF NOT EXISTS hops (
+	session INTEGER NOT NULL REFERENCES sessions(id),
+	time TEXT NOT NULL,
+	channel INTEGER NOT NULL,
+	frequency INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS hops_session_channel ON hops(session, channel);
+CREATE TABLE IF NOT EXISTS errors (
+	session INTEGER NOT NULL REFERENCES sessions(id),
+	time TEXT NOT NULL,
+	message TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS bss (
+	session INTEGER NOT NULL REFERENCES sessions(id),
+	time TEXT NOT NULL,
+	bssid TEXT NOT NULL,
+	ssid TEXT,
+	frequency INTEGER,
+	signal REAL
+);
+CREATE TABLE IF NOT EXISTS pmkids (
+	session INTEGER NOT NULL REFERENCES sessions(id),
+	time TEXT NOT NULL,
+	bssid TEXT NOT NULL,
+	station TEXT NOT NULL,
+	ssid TEXT,
+	channel INTEGER,
+	pmkid TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS surveys (
+	session INTEGER NOT NULL REFERENCES sessions(id),
+	time TEXT NOT NULL,
+	frequency INTEGER NOT NULL,
+	channel INTEGER NOT NULL,
+	noise INTEGER,
+	in_use INTEGER NOT NULL,
+	active_ms INTEGER,
+	busy_ms INTEGER,
+	rx_ms INTEGER
+);
+CREATE INDEX IF NOT EXISTS surveys_session_frequency ON surveys(session, frequency);
+CREATE VIEW IF NOT EXISTS channel_stats AS
+	SELECT session, channel, frequency, COUNT(*) AS hops,
+		MIN(time) AS first_hop, MAX(time) AS last_hop
+	FROM hops GROUP BY session, channel;
+CREATE VIEW IF NOT EXISTS survey_stats AS
+	SELECT session, channel, frequency, COUNT(*) AS snapshots,
+		AVG(noise) AS noise,
+		MAX(active_ms) - MIN(active_ms) AS active_ms,
+		MAX(busy_ms) - MIN(busy_ms) AS busy_ms,
+		MAX(rx_ms) - MIN(rx_ms) AS rx_ms
+	FROM surveys GROUP BY session, frequency;
+`
+
+// DB is a session database. It is safe for concurrent use.
+type DB struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	session int64
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection keeps the writes of a session in order.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("cannot create schema of %v: %v", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database, marking the current session as stopped.
+func (d *DB) Close() error {
+	if err := d.stop(time.Now()); err != nil {
+		_ = d.db.Close()
+		return err
+	}
+	return d.db.Close()
+}
+
+// Session returns the id of the current session, or 0 if none is running.
+func (d *DB) Session() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.session
+}
+
+// Encode stores an event. A start event begins a new session and a stop
+// event ends it. Other events begin a session if none is running, as scans
+// do not emit start events.
+func (d *DB) Encode(event events.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if event.Type == events.TypeStop && d.session == 0 {
+		return nil
+	}
+	at := event.Time.UTC().Format(timeFormat)
+	if event.Type == events.TypeStart || d.session == 0 {
+		result, err := d.db.Exec(`INSERT INTO sessions (interface, started, channels, delay_ms) VALUES (?, ?, ?, ?)`,
+			event.Interface, at, plan.Format(event.Channels), event.DelayMs)
+		if err != nil {
+			return err
+		}
+		if d.session, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch event.Type {
+	case events.TypeHop:
+		_, err = d.db.Exec(`INSERT INTO hops (session, time, channel, frequency) VALUES (?, ?, ?, ?)`,
+			d.session, at, event.Channel, event.Frequency)
+	case events.TypeError:
+		_, err = d.db.Exec(`INSERT INTO errors (session, time, message) VALUES (?, ?, ?)`,
+			d.session, at, event.Error)
+	case events.TypeBSS:
+		_, err = d.db.Exec(`INSERT INTO bss (session, time, bssid, ssid, frequency, signal) VALUES (?, ?, ?, ?, ?, ?)`,
+			d.session, at, event.BSSID, event.SSID, event.Frequency, event.Signal)
+	case events.TypePMKID:
+		_, err = d.db.Exec(`INSERT INTO pmkids (session, time, bssid, station, ssid, channel, pmkid) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			d.session, at, event.BSSID, event.Station, event.SSID, event.Channel, event.PMKID)
+	case events.TypeStop:
+		_, err = d.db.Exec(`UPDATE sessions SET stopped = ? WHERE id = ?`, at, d.session)
+		d.session = 0
+	}
+	return err
+}
+
+// stop ends the current session if one is running.
+func (d *DB) stop(at time.Time) error {
+	return d.Encode(events.Event{Type: events.TypeStop, Time: at})
+}
+
+// Survey stores a snapshot of the survey counters of the radio.
+func (d *DB) Survey(at time.Time, surveys []nl80211util.SurveyInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.session == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	stamp := at.UTC().Format(timeFormat)
+	for _, s := range surveys {
+		_, err := tx.Exec(`INSERT INTO surveys (session, time, frequency, channel, noise, in_use, active_ms, busy_ms, rx_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.session, stamp, s.Frequency, plan.ChannelOf(s.Frequency), s.Noise, s.InUse, s.Time, s.TimeBusy, s.TimeRx)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
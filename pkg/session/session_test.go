@@ -0,0 +1,210 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giacomoferretti/chopper-go/pkg/events"
+	"github.com/giacomoferretti/chopper-go/pkg/nl80211util"
+)
+
+func event(typ string, at time.Time) events.Event {
+	e := events.New(typ)
+	e.Time = at
+	return e
+}
+
+func TestSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	hop := func(channel, frequency int, offset time.Duration) events.Event {
+		e := event(events.TypeHop, base.Add(offset))
+		e.Channel = channel
+		e.Frequency = frequency
+		return e
+	}
+
+	start := event(events.TypeStart, base)
+	start.Interface = "wlan0mon"
+	start.Channels = []int{1, 6}
+	start.DelayMs = 100
+	failure := event(events.TypeError, base.Add(250*time.Millisecond))
+	failure.Error = "device busy"
+	for _, e := range []events.Event{
+		start,
+		hop(1, 2412, 0),
+		hop(6, 2437, 100*time.Millisecond),
+		hop(1, 2412, 200*time.Millisecond),
+		failure,
+	} {
+		if err := db.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, busy := range []uint64{100, 160} {
+		surveys := []nl80211util.SurveyInfo{{Frequency: 2412, Noise: -90 - i*2, Time: 1000 * uint64(i+1), TimeBusy: busy}}
+		if err := db.Survey(base.Add(time.Duration(i)*time.Second), surveys); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := db.Session()
+	if session == 0 {
+		t.Fatal("no session after start event")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var iface, channels string
+	var stopped sql.NullString
+	err = conn.QueryRow(`SELECT interface, channels, stopped FROM sessions WHERE id = ?`, session).Scan(&iface, &channels, &stopped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iface != "wlan0mon" || channels != "1,6" || !stopped.Valid {
+		t.Fatalf("session: interface %q, channels %q, stopped %v", iface, channels, stopped)
+	}
+
+	rows, err := conn.Query(`SELECT channel, hops, first_hop FROM channel_stats WHERE session = ? ORDER BY channel`, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type stat struct {
+		channel, hops int
+		first         string
+	}
+	var got []stat
+	for rows.Next() {
+		var s stat
+		if err := rows.Scan(&s.channel, &s.hops, &s.first); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	want := []stat{{1, 2, "2021-10-01T12:00:00.000Z"}, {6, 1, "2021-10-01T12:00:00.100Z"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("channel_stats:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	var message string
+	if err := conn.QueryRow(`SELECT message FROM errors WHERE session = ?`, session).Scan(&message); err != nil || message != "device busy" {
+		t.Fatalf("errors: %q, %v", message, err)
+	}
+
+	var snapshots, busy int
+	var noise float64
+	err = conn.QueryRow(`SELECT snapshots, noise, busy_ms FROM survey_stats WHERE session = ? AND channel = 1`, session).Scan(&snapshots, &noise, &busy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshots != 2 || noise != -91 || busy != 60 {
+		t.Fatalf("survey_stats: %v snapshots, noise %v, busy %v", snapshots, noise, busy)
+	}
+}
+
+func TestImplicitSession(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "session.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	bss := events.New(events.TypeBSS)
+	bss.Interface = "wlan0"
+	bss.BSSID = "00:11:22:33:44:55"
+	bss.SSID = "test"
+	if err := db.Encode(bss); err != nil {
+		t.Fatal(err)
+	}
+	session := db.Session()
+	if session == 0 {
+		t.Fatal("no session after bss event")
+	}
+
+	var iface, ssid string
+	err = db.db.QueryRow(`SELECT s.interface, b.ssid FROM bss b JOIN sessions s ON s.id = b.session WHERE b.session = ?`, session).Scan(&iface, &ssid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iface != "wlan0" || ssid != "test" {
+		t.Fatalf("bss: interface %q, ssid %q", iface, ssid)
+	}
+}
+
+func TestPMKID(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "session.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pmkid := events.New(events.TypePMKID)
+	pmkid.BSSID = "00:11:22:33:44:55"
+	pmkid.Station = "02:00:00:00:00:01"
+	pmkid.Channel = 6
+	pmkid.PMKID = "abababababababababababababababab"
+	if err := db.Encode(pmkid); err != nil {
+		t.Fatal(err)
+	}
+
+	var station, value string
+	var channel int
+	err = db.db.QueryRow(`SELECT station, channel, pmkid FROM pmkids WHERE session = ?`, db.Session()).Scan(&station, &channel, &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if station != pmkid.Station || channel != 6 || value != pmkid.PMKID {
+		t.Fatalf("pmkids: station %q, channel %v, pmkid %q", station, channel, value)
+	}
+}
+
+func TestSessionsAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.db")
+	var sessions []int64
+	for i := 0; i < 2; i++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Encode(events.New(events.TypeStart)); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, db.Session())
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sessions[0] == sessions[1] {
+		t.Fatalf("sessions were not appended: %v", sessions)
+	}
+}
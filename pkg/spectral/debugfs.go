@@ -0,0 +1,83 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spectral
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// debugfs is the directory of the mac80211 radios in debugfs.
+var debugfs = "/sys/kernel/debug/ieee80211"
+
+// drivers are the drivers exposing a spectral scan, in the order they are
+// probed.
+var drivers = []string{"ath9k", "ath10k"}
+
+// ErrUnsupported is returned by Open when the radio has no spectral scan.
+var ErrUnsupported = errors.New("spectral scan not supported")
+
+// Scanner controls the spectral scan of a radio.
+type Scanner struct {
+	// Driver is ath9k or ath10k.
+	Driver string
+	dir    string
+}
+
+// Open returns the Scanner of phy. debugfs must be mounted.
+func Open(phy int) (*Scanner, error) {
+	dir := filepath.Join(debugfs, fmt.Sprintf("phy%d", phy))
+	for _, driver := range drivers {
+		if _, err := os.Stat(filepath.Join(dir, driver, "spectral_scan_ctl")); err == nil {
+			return &Scanner{Driver: driver, dir: filepath.Join(dir, driver)}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no ath9k or ath10k spectral_scan_ctl in %v (is debugfs mounted?)", ErrUnsupported, dir)
+}
+
+func (s *Scanner) control(command string) error {
+	if err := os.WriteFile(filepath.Join(s.dir, "spectral_scan_ctl"), []byte(command), 0600); err != nil {
+		return fmt.Errorf("cannot %v spectral scan: %w", command, err)
+	}
+	return nil
+}
+
+// Start puts the scan in background mode, sampling the current channel
+// continuously, and triggers it.
+func (s *Scanner) Start() error {
+	if err := s.control("background"); err != nil {
+		return err
+	}
+	return s.Trigger()
+}
+
+// Trigger restarts the scan, after the radio changed channel.
+func (s *Scanner) Trigger() error {
+	return s.control("trigger")
+}
+
+// Stop disables the scan.
+func (s *Scanner) Stop() error {
+	return s.control("disable")
+}
+
+// Samples opens the relay file the samples are read from.
+func (s *Scanner) Samples() (*os.File, error) {
+	return os.Open(filepath.Join(s.dir, "spectral_scan0"))
+}
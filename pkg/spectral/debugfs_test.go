@@ -0,0 +1,76 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spectral
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	root := t.TempDir()
+	defer func(old string) { debugfs = old }(debugfs)
+	debugfs = root
+
+	if _, err := Open(0); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("got %v, want ErrUnsupported", err)
+	}
+
+	dir := filepath.Join(root, "phy1", "ath10k")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	ctl := filepath.Join(dir, "spectral_scan_ctl")
+	if err := os.WriteFile(ctl, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "spectral_scan0"), []byte{1, 2}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Driver != "ath10k" {
+		t.Errorf("driver %v, want ath10k", s.Driver)
+	}
+
+	for _, step := range []struct {
+		run  func() error
+		want string
+	}{{s.Start, "trigger"}, {s.Stop, "disable"}} {
+		if err := step.run(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(ctl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != step.want {
+			t.Errorf("spectral_scan_ctl %q, want %q", got, step.want)
+		}
+	}
+
+	f, err := s.Samples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+}
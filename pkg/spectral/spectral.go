@@ -0,0 +1,238 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package spectral decodes the FFT samples of the spectral scan of Atheros
+// radios (ath9k and ath10k) and controls the scan through debugfs.
+//
+// Samples are read from the spectral_scan0 relay file as a stream of
+// type-length-value records, in the layout of the kernel's
+// spectral_common.h. Unknown record types are skipped.
+package spectral
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Sample types of spectral_common.h.
+const (
+	TypeHT20   = 1
+	TypeHT2040 = 2
+	TypeAth10k = 3
+)
+
+const (
+	// tlvLength is the size of the record header: type and big-endian
+	// length of the payload.
+	tlvLength = 3
+
+	ht20Length   = 73
+	ht2040Length = 152
+	// ath10kLength is the fixed part of an ath10k payload, followed by
+	// the bins.
+	ath10kLength = 26
+)
+
+// ErrShortSample is returned for a record too short for its type.
+var ErrShortSample = errors.New("spectral: sample too short for its type")
+
+// Sample is a single FFT sample.
+type Sample struct {
+	Type int
+	// Frequency is the center frequency in MHz of the primary channel.
+	Frequency int
+	// RSSI is relative to Noise, in dB.
+	RSSI int
+	// Noise is the noise floor in dBm.
+	Noise        int
+	MaxMagnitude int
+	MaxIndex     int
+	TSF          uint64
+	// Bins are the FFT bins, in the scale of the driver.
+	Bins []byte
+}
+
+// Signal returns the power of the sample in dBm.
+func (s Sample) Signal() int {
+	return s.Noise + s.RSSI
+}
+
+// Decode decodes the complete records at the start of data and returns the
+// samples and the number of bytes consumed. The rest of data is the start
+// of an incomplete record.
+func Decode(data []byte) ([]Sample, int, error) {
+	var samples []Sample
+	n := 0
+	for len(data)-n >= tlvLength {
+		typ := int(data[n])
+		length := int(binary.BigEndian.Uint16(data[n+1:]))
+		if len(data)-n-tlvLength < length {
+			break
+		}
+		payload := data[n+tlvLength : n+tlvLength+length]
+		n += tlvLength + length
+
+		var sample Sample
+		var err error
+		switch typ {
+		case TypeHT20:
+			sample, err = decodeHT20(payload)
+		case TypeHT2040:
+			sample, err = decodeHT2040(payload)
+		case TypeAth10k:
+			sample, err = decodeAth10k(payload)
+		default:
+			continue
+		}
+		if err != nil {
+			return samples, n, err
+		}
+		sample.Type = typ
+		samples = append(samples, sample)
+	}
+
+	return samples, n, nil
+}
+
+func decodeHT20(p []byte) (Sample, error) {
+	if len(p) < ht20Length {
+		return Sample{}, ErrShortSample
+	}
+	return Sample{
+		Frequency:    int(binary.BigEndian.Uint16(p[1:])),
+		RSSI:         int(int8(p[3])),
+		Noise:        int(int8(p[4])),
+		MaxMagnitude: int(binary.BigEndian.Uint16(p[5:])),
+		MaxIndex:     int(p[7]),
+		TSF:          binary.BigEndian.Uint64(p[9:]),
+		Bins:         append([]byte(nil), p[17:ht20Length]...),
+	}, nil
+}
+
+// decodeHT2040 decodes a HT40 sample, reporting the strongest of its two
+// halves.
+func decodeHT2040(p []byte) (Sample, error) {
+	if len(p) < ht2040Length {
+		return Sample{}, ErrShortSample
+	}
+	sample := Sample{
+		Frequency:    int(binary.BigEndian.Uint16(p[1:])),
+		RSSI:         int(int8(p[3])),
+		Noise:        int(int8(p[13])),
+		MaxMagnitude: int(binary.BigEndian.Uint16(p[15:])),
+		MaxIndex:     int(p[19]),
+		TSF:          binary.BigEndian.Uint64(p[5:]),
+		Bins:         append([]byte(nil), p[24:ht2040Length]...),
+	}
+	if upper := int(binary.BigEndian.Uint16(p[17:])); upper > sample.MaxMagnitude {
+		sample.MaxMagnitude = upper
+		sample.MaxIndex = int(p[20])
+	}
+	if upper := int(int8(p[4])); upper > sample.RSSI {
+		sample.RSSI = upper
+	}
+	return sample, nil
+}
+
+func decodeAth10k(p []byte) (Sample, error) {
+	if len(p) < ath10kLength {
+		return Sample{}, ErrShortSample
+	}
+	return Sample{
+		Frequency:    int(binary.BigEndian.Uint16(p[1:])),
+		Noise:        int(int16(binary.BigEndian.Uint16(p[5:]))),
+		MaxMagnitude: int(binary.BigEndian.Uint16(p[7:])),
+		TSF:          binary.BigEndian.Uint64(p[13:]),
+		MaxIndex:     int(int8(p[21])),
+		RSSI:         int(p[22]),
+		Bins:         append([]byte(nil), p[ath10kLength:]...),
+	}, nil
+}
+
+// Reader reads samples from a stream of records.
+type Reader struct {
+	r   io.Reader
+	buf []byte
+	n   int
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r, buf: make([]byte, 65536)}
+}
+
+// Read reads the next chunk of r and returns its complete samples. Records
+// split across reads are kept until they are complete. The relay file
+// returns io.EOF when no sample is pending, Read then returns it as well.
+func (r *Reader) Read() ([]Sample, error) {
+	read, err := r.r.Read(r.buf[r.n:])
+	r.n += read
+	samples, n, decodeErr := Decode(r.buf[:r.n])
+	r.n = copy(r.buf, r.buf[n:r.n])
+	if decodeErr != nil {
+		// Drop the rest, it cannot be realigned
+		r.n = 0
+		return samples, decodeErr
+	}
+	if r.n == len(r.buf) {
+		// A record longer than the buffer cannot be decoded
+		r.n = 0
+	}
+	if read > 0 {
+		err = nil
+	}
+	return samples, err
+}
+
+// Summary aggregates samples.
+type Summary struct {
+	Samples      int
+	MaxSignal    int
+	MaxMagnitude int
+
+	signal int
+	noise  int
+}
+
+// Add accounts a sample.
+func (s *Summary) Add(sample Sample) {
+	if s.Samples == 0 || sample.Signal() > s.MaxSignal {
+		s.MaxSignal = sample.Signal()
+	}
+	if sample.MaxMagnitude > s.MaxMagnitude {
+		s.MaxMagnitude = sample.MaxMagnitude
+	}
+	s.Samples++
+	s.signal += sample.Signal()
+	s.noise += sample.Noise
+}
+
+// Signal returns the average power of the samples in dBm.
+func (s Summary) Signal() float64 {
+	if s.Samples == 0 {
+		return 0
+	}
+	return float64(s.signal) / float64(s.Samples)
+}
+
+// Noise returns the average noise floor of the samples in dBm.
+func (s Summary) Noise() float64 {
+	if s.Samples == 0 {
+		return 0
+	}
+	return float64(s.noise) / float64(s.Samples)
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spectral
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// ht20 returns a HT20 record.
+func ht20(frequency int, rssi, noise int8, magnitude int) []byte {
+	p := make([]byte, ht20Length)
+	binary.BigEndian.PutUint16(p[1:], uint16(frequency))
+	p[3] = byte(rssi)
+	p[4] = byte(noise)
+	binary.BigEndian.PutUint16(p[5:], uint16(magnitude))
+	p[7] = 12
+	binary.BigEndian.PutUint64(p[9:], 1000)
+	p[17] = 7
+	return append([]byte{TypeHT20, 0, ht20Length}, p...)
+}
+
+func ath10k(frequency int, rssi uint8, noise int16, bins int) []byte {
+	p := make([]byte, ath10kLength+bins)
+	binary.BigEndian.PutUint16(p[1:], uint16(frequency))
+	binary.BigEndian.PutUint16(p[5:], uint16(noise))
+	binary.BigEndian.PutUint16(p[7:], 300)
+	binary.BigEndian.PutUint64(p[13:], 2000)
+	p[21] = 5
+	p[22] = rssi
+	return append([]byte{TypeAth10k, 0, byte(len(p))}, p...)
+}
+
+func TestDecode(t *testing.T) {
+	var data []byte
+	data = append(data, ht20(2437, 20, -95, 150)...)
+	// Unknown types are skipped
+	data = append(data, 9, 0, 2, 0xaa, 0xbb)
+	data = append(data, ath10k(5180, 30, -100, 64)...)
+	partial := ht20(2412, 1, -90, 1)
+	data = append(data, partial[:10]...)
+
+	samples, n, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data)-10 {
+		t.Errorf("consumed %v bytes, want %v", n, len(data)-10)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("got %v samples, want 2", len(samples))
+	}
+
+	s := samples[0]
+	if s.Type != TypeHT20 || s.Frequency != 2437 || s.RSSI != 20 || s.Noise != -95 || s.MaxMagnitude != 150 || s.MaxIndex != 12 || s.TSF != 1000 {
+		t.Errorf("HT20 sample %+v", s)
+	}
+	if len(s.Bins) != 56 || s.Bins[0] != 7 || s.Signal() != -75 {
+		t.Errorf("HT20 bins %v, signal %v", len(s.Bins), s.Signal())
+	}
+
+	s = samples[1]
+	if s.Type != TypeAth10k || s.Frequency != 5180 || s.RSSI != 30 || s.Noise != -100 || s.MaxMagnitude != 300 || s.MaxIndex != 5 || s.TSF != 2000 || len(s.Bins) != 64 {
+		t.Errorf("ath10k sample %+v", s)
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	if _, _, err := Decode([]byte{TypeHT20, 0, 2, 0, 0}); err != ErrShortSample {
+		t.Errorf("got %v, want ErrShortSample", err)
+	}
+}
+
+// chunkReader returns a chunk per Read, then io.EOF.
+type chunkReader struct {
+	chunks [][]byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestReader(t *testing.T) {
+	record := ht20(2412, 10, -96, 50)
+	data := append(append([]byte{}, record...), record...)
+	r := NewReader(&chunkReader{chunks: [][]byte{data[:100], data[100:]}})
+
+	samples, err := r.Read()
+	if err != nil || len(samples) != 1 {
+		t.Fatalf("first read: %v samples, %v", len(samples), err)
+	}
+	samples, err = r.Read()
+	if err != nil || len(samples) != 1 || samples[0].Frequency != 2412 {
+		t.Fatalf("second read: %v samples, %v", len(samples), err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+
+	r = NewReader(bytes.NewReader([]byte{TypeHT20, 0, 1, 0}))
+	if _, err := r.Read(); err != ErrShortSample {
+		t.Errorf("got %v, want ErrShortSample", err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	var s Summary
+	if s.Signal() != 0 || s.Noise() != 0 {
+		t.Errorf("empty summary %v %v", s.Signal(), s.Noise())
+	}
+	s.Add(Sample{RSSI: 10, Noise: -95, MaxMagnitude: 100})
+	s.Add(Sample{RSSI: 30, Noise: -93, MaxMagnitude: 50})
+
+	if s.Samples != 2 || s.MaxSignal != -63 || s.MaxMagnitude != 100 {
+		t.Errorf("summary %+v", s)
+	}
+	if s.Signal() != -74 || s.Noise() != -94 {
+		t.Errorf("signal %v noise %v, want -74 and -94", s.Signal(), s.Noise())
+	}
+}
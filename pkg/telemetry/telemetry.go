@@ -0,0 +1,418 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package telemetry records traces and metrics and exports them to an
+// OpenTelemetry collector using OTLP over HTTP with JSON encoding.
+//
+// It implements the small subset of OpenTelemetry chopper needs (spans,
+// counters and histograms) with the standard library only, so instrumented
+// builds stay small.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSpans bounds the spans buffered between two exports.
+	maxSpans = 4096
+
+	scopeName = "github.com/giacomoferretti/chopper-go"
+
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	statusOK    = 1
+	statusError = 2
+
+	temporalityCumulative = 2
+)
+
+// DefaultBounds are the histogram bucket bounds, in milliseconds.
+var DefaultBounds = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// Attribute is a key/value pair attached to spans and data points. Value
+// must be a string, an int, a float64 or a bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Exporter buffers spans and aggregates metrics until they are flushed to
+// the collector. It is safe for concurrent use.
+type Exporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+	start    time.Time
+
+	mu         sync.Mutex
+	spans      []*Span
+	counters   map[string]*counter
+	histograms map[string]*histogram
+}
+
+type counter struct {
+	name  string
+	attrs []Attribute
+	value int64
+}
+
+type histogram struct {
+	name   string
+	attrs  []Attribute
+	count  uint64
+	sum    float64
+	counts []uint64
+}
+
+// NewExporter returns an exporter sending to the OTLP/HTTP endpoint, e.g.
+// http://localhost:4318.
+func NewExporter(endpoint string, service string) *Exporter {
+	return &Exporter{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		service:    service,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		start:      time.Now(),
+		counters:   make(map[string]*counter),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// seriesKey identifies a metric stream by name and attributes.
+func seriesKey(name string, attrs []Attribute) string {
+	parts := make([]string, 0, len(attrs)+1)
+	parts = append(parts, name)
+	for _, attr := range attrs {
+		parts = append(parts, fmt.Sprintf("%s=%v", attr.Key, attr.Value))
+	}
+	sort.Strings(parts[1:])
+
+	return strings.Join(parts, ",")
+}
+
+// Add increments a monotonic counter.
+func (e *Exporter) Add(name string, value int64, attrs ...Attribute) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := seriesKey(name, attrs)
+	c, ok := e.counters[key]
+	if !ok {
+		c = &counter{name: name, attrs: attrs}
+		e.counters[key] = c
+	}
+	c.value += value
+}
+
+// Record adds a value (in milliseconds) to a histogram using DefaultBounds.
+func (e *Exporter) Record(name string, value float64, attrs ...Attribute) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := seriesKey(name, attrs)
+	h, ok := e.histograms[key]
+	if !ok {
+		h = &histogram{name: name, attrs: attrs, counts: make([]uint64, len(DefaultBounds)+1)}
+		e.histograms[key] = h
+	}
+
+	h.count++
+	h.sum += value
+	bucket := sort.SearchFloat64s(DefaultBounds, value)
+	h.counts[bucket]++
+}
+
+// Span is a timed operation, part of a trace.
+type Span struct {
+	exporter *Exporter
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []Attribute
+	err      error
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// StartSpan starts a span. If parent is nil it starts a new trace.
+func (e *Exporter) StartSpan(name string, parent *Span, attrs ...Attribute) *Span {
+	s := &Span{
+		exporter: e,
+		spanID:   randomID(8),
+		name:     name,
+		kind:     spanKindInternal,
+		start:    time.Now(),
+		attrs:    attrs,
+	}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomID(16)
+	}
+
+	return s
+}
+
+// StartClientSpan starts a span for a call to another component, such as
+// the kernel.
+func (e *Exporter) StartClientSpan(name string, parent *Span, attrs ...Attribute) *Span {
+	s := e.StartSpan(name, parent, attrs...)
+	s.kind = spanKindClient
+	return s
+}
+
+// End ends the span, marking it as failed if err is not nil, and returns
+// its duration.
+func (s *Span) End(err error) time.Duration {
+	s.end = time.Now()
+	s.err = err
+
+	e := s.exporter
+	e.mu.Lock()
+	if len(e.spans) >= maxSpans {
+		e.spans = e.spans[1:]
+	}
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+
+	return s.end.Sub(s.start)
+}
+
+// Run flushes the exporter every interval until ctx is done, then flushes
+// one last time. Export errors are passed to onError if not nil.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := e.Flush(); err != nil && onError != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+			if err := e.Flush(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Flush exports the buffered spans and the current value of all metrics.
+func (e *Exporter) Flush() error {
+	now := time.Now()
+
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	traces := e.tracesPayload(spans)
+	metrics := e.metricsPayload(now)
+	hasMetrics := len(e.counters)+len(e.histograms) > 0
+	e.mu.Unlock()
+
+	if len(spans) > 0 {
+		if err := e.post("/v1/traces", traces); err != nil {
+			return err
+		}
+	}
+	if hasMetrics {
+		if err := e.post("/v1/metrics", metrics); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Exporter) post(path string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint+path, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: %v returned %v", path, resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding. 64 bit integers are encoded as strings.
+type jsonObject map[string]interface{}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func encodeAttributes(attrs []Attribute) []jsonObject {
+	ret := make([]jsonObject, 0, len(attrs))
+	for _, attr := range attrs {
+		var value jsonObject
+		switch v := attr.Value.(type) {
+		case string:
+			value = jsonObject{"stringValue": v}
+		case int:
+			value = jsonObject{"intValue": strconv.Itoa(v)}
+		case float64:
+			value = jsonObject{"doubleValue": v}
+		case bool:
+			value = jsonObject{"boolValue": v}
+		default:
+			value = jsonObject{"stringValue": fmt.Sprint(v)}
+		}
+		ret = append(ret, jsonObject{"key": attr.Key, "value": value})
+	}
+
+	return ret
+}
+
+func (e *Exporter) resource() jsonObject {
+	return jsonObject{"attributes": encodeAttributes([]Attribute{String("service.name", e.service)})}
+}
+
+func (e *Exporter) tracesPayload(spans []*Span) jsonObject {
+	encoded := make([]jsonObject, 0, len(spans))
+	for _, s := range spans {
+		status := jsonObject{"code": statusOK}
+		if s.err != nil {
+			status = jsonObject{"code": statusError, "message": s.err.Error()}
+		}
+
+		span := jsonObject{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": unixNano(s.start),
+			"endTimeUnixNano":   unixNano(s.end),
+			"attributes":        encodeAttributes(s.attrs),
+			"status":            status,
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		encoded = append(encoded, span)
+	}
+
+	return jsonObject{"resourceSpans": []jsonObject{{
+		"resource": e.resource(),
+		"scopeSpans": []jsonObject{{
+			"scope": jsonObject{"name": scopeName},
+			"spans": encoded,
+		}},
+	}}}
+}
+
+func (e *Exporter) metricsPayload(now time.Time) jsonObject {
+	byName := make(map[string][]jsonObject)
+	kinds := make(map[string]string)
+	names := make([]string, 0)
+
+	add := func(name string, kind string, point jsonObject) {
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], point)
+		kinds[name] = kind
+	}
+
+	for _, c := range e.counters {
+		add(c.name, "sum", jsonObject{
+			"attributes":        encodeAttributes(c.attrs),
+			"startTimeUnixNano": unixNano(e.start),
+			"timeUnixNano":      unixNano(now),
+			"asInt":             strconv.FormatInt(c.value, 10),
+		})
+	}
+	for _, h := range e.histograms {
+		counts := make([]string, len(h.counts))
+		for i, n := range h.counts {
+			counts[i] = strconv.FormatUint(n, 10)
+		}
+		add(h.name, "histogram", jsonObject{
+			"attributes":        encodeAttributes(h.attrs),
+			"startTimeUnixNano": unixNano(e.start),
+			"timeUnixNano":      unixNano(now),
+			"count":             strconv.FormatUint(h.count, 10),
+			"sum":               h.sum,
+			"bucketCounts":      counts,
+			"explicitBounds":    DefaultBounds,
+		})
+	}
+	sort.Strings(names)
+
+	metrics := make([]jsonObject, 0, len(names))
+	for _, name := range names {
+		data := jsonObject{
+			"aggregationTemporality": temporalityCumulative,
+			"dataPoints":             byName[name],
+		}
+		metric := jsonObject{"name": name}
+		if kinds[name] == "sum" {
+			data["isMonotonic"] = true
+			metric["sum"] = data
+		} else {
+			metric["unit"] = "ms"
+			metric["histogram"] = data
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return jsonObject{"resourceMetrics": []jsonObject{{
+		"resource": e.resource(),
+		"scopeMetrics": []jsonObject{{
+			"scope":   jsonObject{"name": scopeName},
+			"metrics": metrics,
+		}},
+	}}}
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collector records the payloads posted to each OTLP path.
+type collector struct {
+	mu       sync.Mutex
+	payloads map[string][]map[string]interface{}
+}
+
+func newCollector(t *testing.T) (*collector, string) {
+	c := &collector{payloads: make(map[string][]map[string]interface{})}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Errorf("%v: invalid JSON: %v", r.URL.Path, err)
+		}
+
+		c.mu.Lock()
+		c.payloads[r.URL.Path] = append(c.payloads[r.URL.Path], payload)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	return c, server.URL
+}
+
+// dig walks a decoded JSON document following keys and array indexes.
+func dig(v interface{}, path ...interface{}) interface{} {
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[k]
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || k >= len(a) {
+				return nil
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func TestFlushTraces(t *testing.T) {
+	c, url := newCollector(t)
+	e := NewExporter(url, "test")
+
+	parent := e.StartSpan("hop", nil, Int("wifi.channel", 6))
+	child := e.StartClientSpan("nl80211.set_frequency", parent)
+	child.End(errors.New("device busy"))
+	parent.End(nil)
+
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	traces := c.payloads["/v1/traces"]
+	if want, got := 1, len(traces); want != got {
+		t.Fatalf("trace exports:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	spans := dig(traces[0], "resourceSpans", 0, "scopeSpans", 0, "spans").([]interface{})
+	if want, got := 2, len(spans); want != got {
+		t.Fatalf("spans:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	tests := []struct {
+		name string
+		path []interface{}
+		want interface{}
+	}{
+		{"service", []interface{}{"resourceSpans", 0, "resource", "attributes", 0, "value", "stringValue"}, "test"},
+		{"child name", []interface{}{0, "name"}, "nl80211.set_frequency"},
+		{"child kind", []interface{}{0, "kind"}, float64(spanKindClient)},
+		{"child status", []interface{}{0, "status", "code"}, float64(statusError)},
+		{"child message", []interface{}{0, "status", "message"}, "device busy"},
+		{"parent status", []interface{}{1, "status", "code"}, float64(statusOK)},
+		{"parent attribute", []interface{}{1, "attributes", 0, "value", "intValue"}, "6"},
+		{"same trace", []interface{}{0, "traceId"}, dig(spans, 1, "traceId")},
+		{"parent id", []interface{}{0, "parentSpanId"}, dig(spans, 1, "spanId")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{}
+			if tt.path[0] == "resourceSpans" {
+				got = dig(traces[0], tt.path...)
+			} else {
+				got = dig(spans, tt.path...)
+			}
+			if got != tt.want {
+				t.Fatalf("%v:\n- want: %v\n-  got: %v", tt.path, tt.want, got)
+			}
+		})
+	}
+
+	// Spans are only exported once
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(c.payloads["/v1/traces"]); want != got {
+		t.Fatalf("trace exports after second flush:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestFlushMetrics(t *testing.T) {
+	c, url := newCollector(t)
+	e := NewExporter(url, "test")
+
+	e.Add("chopper.hops", 1, Int("wifi.channel", 1))
+	e.Add("chopper.hops", 1, Int("wifi.channel", 1))
+	e.Add("chopper.hops", 1, Int("wifi.channel", 6))
+	e.Record("chopper.hop.latency", 0.5)
+	e.Record("chopper.hop.latency", 7)
+	e.Record("chopper.hop.latency", 2000)
+
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := dig(c.payloads["/v1/metrics"][0], "resourceMetrics", 0, "scopeMetrics", 0, "metrics").([]interface{})
+	if want, got := 2, len(metrics); want != got {
+		t.Fatalf("metrics:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// Metrics are sorted by name
+	latency, hops := metrics[0], metrics[1]
+
+	points := dig(hops, "sum", "dataPoints").([]interface{})
+	total := map[string]string{}
+	for _, p := range points {
+		total[dig(p, "attributes", 0, "value", "intValue").(string)] = dig(p, "asInt").(string)
+	}
+	if want, got := "2", total["1"]; want != got {
+		t.Fatalf("hops on channel 1:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := "1", total["6"]; want != got {
+		t.Fatalf("hops on channel 6:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	buckets := dig(latency, "histogram", "dataPoints", 0, "bucketCounts").([]interface{})
+	if want, got := len(DefaultBounds)+1, len(buckets); want != got {
+		t.Fatalf("buckets:\n- want: %v\n-  got: %v", want, got)
+	}
+	for i, want := range map[int]string{0: "1", 3: "1", len(DefaultBounds): "1"} {
+		if got := buckets[i]; got != want {
+			t.Fatalf("bucket %v:\n- want: %v\n-  got: %v", i, want, got)
+		}
+	}
+}
+
+func TestFlushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	e := NewExporter(server.URL, "test")
+	e.Add("chopper.hops", 1)
+	if err := e.Flush(); err == nil {
+		t.Fatal("Flush() succeeded with a failing collector")
+	}
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package wifichan converts between IEEE 802.11 channel numbers and
+// frequencies, and computes the center of 40, 80, 160 and 320 MHz channels.
+//
+// Channel numbers are only unique within a band: channel 1 is 2412 MHz in
+// the 2.4 GHz band and 5955 MHz in the 6 GHz band.
+package wifichan
+
+import "fmt"
+
+// Band is a frequency band.
+type Band int
+
+const (
+	BandUnknown Band = iota
+	Band2GHz
+	Band5GHz
+	Band6GHz
+	Band60GHz
+)
+
+func (b Band) String() string {
+	switch b {
+	case Band2GHz:
+		return "2.4GHz"
+	case Band5GHz:
+		return "5GHz"
+	case Band6GHz:
+		return "6GHz"
+	case Band60GHz:
+		return "60GHz"
+	}
+	return "unknown"
+}
+
+// Width is a channel width in MHz.
+type Width int
+
+const (
+	Width20  Width = 20
+	Width40  Width = 40
+	Width80  Width = 80
+	Width160 Width = 160
+	Width320 Width = 320
+)
+
+func (w Width) String() string {
+	return fmt.Sprintf("%dMHz", int(w))
+}
+
+// segment is a range of 20 MHz channels, spaced by 4, wider channels are
+// aligned to.
+type segment struct {
+	first, last int
+}
+
+var (
+	segments5GHz = []segment{{36, 64}, {100, 144}, {149, 177}}
+	segments6GHz = []segment{{1, 233}}
+)
+
+// BandOf returns the band of a frequency in MHz.
+func BandOf(frequency int) Band {
+	switch {
+	case frequency >= 2400 && frequency < 2500:
+		return Band2GHz
+	case frequency >= 4900 && frequency < 5925:
+		return Band5GHz
+	case frequency >= 5925 && frequency <= 7125:
+		return Band6GHz
+	case frequency >= 57000 && frequency <= 71000:
+		return Band60GHz
+	}
+	return BandUnknown
+}
+
+// Frequency returns the center frequency in MHz of a channel of band, or 0
+// if the band has no such channel. The channel can be the center of a wider
+// channel, e.g. 42 in the 5 GHz band.
+func Frequency(band Band, channel int) int {
+	switch band {
+	case Band2GHz:
+		switch {
+		case channel == 14:
+			return 2484
+		case channel >= 1 && channel <= 13:
+			return 2407 + channel*5
+		}
+	case Band5GHz:
+		switch {
+		// 4.9 GHz channels of Japan and public safety
+		case channel >= 182 && channel <= 196:
+			return 4000 + channel*5
+		case channel >= 1 && channel <= 181:
+			return 5000 + channel*5
+		}
+	case Band6GHz:
+		switch {
+		case channel == 2:
+			return 5935
+		case channel >= 1 && channel <= 233:
+			return 5950 + channel*5
+		}
+	case Band60GHz:
+		if channel >= 1 && channel <= 6 {
+			return 56160 + channel*2160
+		}
+	}
+	return 0
+}
+
+// ChannelOf returns the band and channel number of a center frequency in
+// MHz, or BandUnknown and 0 if no channel is centered on it.
+func ChannelOf(frequency int) (Band, int) {
+	band := BandOf(frequency)
+
+	var channel int
+	switch band {
+	case Band2GHz:
+		if frequency == 2484 {
+			return band, 14
+		}
+		channel = (frequency - 2407) / 5
+	case Band5GHz:
+		if frequency < 5000 {
+			channel = (frequency - 4000) / 5
+		} else {
+			channel = (frequency - 5000) / 5
+		}
+	case Band6GHz:
+		if frequency == 5935 {
+			return band, 2
+		}
+		channel = (frequency - 5950) / 5
+	case Band60GHz:
+		channel = (frequency - 56160) / 2160
+	}
+
+	if channel == 0 || Frequency(band, channel) != frequency {
+		return BandUnknown, 0
+	}
+	return band, channel
+}
+
+// Channels returns the 20 MHz channels of band, in frequency order.
+func Channels(band Band) []int {
+	var channels []int
+	switch band {
+	case Band2GHz:
+		for channel := 1; channel <= 14; channel++ {
+			channels = append(channels, channel)
+		}
+	case Band5GHz:
+		for _, s := range segments5GHz {
+			for channel := s.first; channel <= s.last; channel += 4 {
+				channels = append(channels, channel)
+			}
+		}
+	case Band6GHz:
+		channels = append(channels, 2)
+		for channel := 1; channel <= 233; channel += 4 {
+			channels = append(channels, channel)
+		}
+	case Band60GHz:
+		for channel := 1; channel <= 6; channel++ {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// CenterChannel returns the center channel number of the channel of width
+// containing the 20 MHz primary channel.
+//
+// In the 2.4 GHz band 40 MHz channels overlap, the secondary channel is
+// above the primary one up to channel 7 and below from channel 8, so that
+// they fit in the 11 channels allowed everywhere. In the 6 GHz band 320 MHz
+// channels follow the 320-1 channelization, centered on 31, 95 and 159.
+func CenterChannel(band Band, primary int, width Width) (int, error) {
+	if width == Width20 {
+		if Frequency(band, primary) == 0 {
+			return 0, fmt.Errorf("no channel %v in the %v band", primary, band)
+		}
+		return primary, nil
+	}
+
+	switch band {
+	case Band2GHz:
+		if width != Width40 {
+			return 0, fmt.Errorf("no %v channels in the %v band", width, band)
+		}
+		switch {
+		case primary >= 1 && primary <= 7:
+			return primary + 2, nil
+		case primary >= 8 && primary <= 13:
+			return primary - 2, nil
+		}
+		return 0, fmt.Errorf("channel %v has no %v channel", primary, width)
+	case Band5GHz:
+		if width > Width160 {
+			return 0, fmt.Errorf("no %v channels in the %v band", width, band)
+		}
+		return segmentCenter(segments5GHz, primary, width)
+	case Band6GHz:
+		return segmentCenter(segments6GHz, primary, width)
+	}
+	return 0, fmt.Errorf("no %v channels in the %v band", width, band)
+}
+
+// segmentCenter returns the center of the channel of width aligned to the
+// segment containing primary.
+func segmentCenter(segments []segment, primary int, width Width) (int, error) {
+	switch width {
+	case Width40, Width80, Width160, Width320:
+	default:
+		return 0, fmt.Errorf("invalid width %v", width)
+	}
+
+	// Channel numbers are 5 MHz apart
+	span := int(width) / 5
+	for _, s := range segments {
+		if primary < s.first || primary > s.last {
+			continue
+		}
+		if (primary-s.first)%4 != 0 {
+			break
+		}
+
+		first := s.first + (primary-s.first)/span*span
+		if first+span-4 <= s.last {
+			return first + (span-4)/2, nil
+		}
+	}
+	return 0, fmt.Errorf("channel %v has no %v channel", primary, width)
+}
+
+// CenterFrequency returns the center frequency in MHz of the channel of
+// width containing the 20 MHz primary channel, see CenterChannel.
+func CenterFrequency(band Band, primary int, width Width) (int, error) {
+	center, err := CenterChannel(band, primary, width)
+	if err != nil {
+		return 0, err
+	}
+	return Frequency(band, center), nil
+}
+
+// Bounds returns the lowest and highest frequency in MHz covered by the
+// channel of width containing the 20 MHz primary channel.
+func Bounds(band Band, primary int, width Width) (int, int, error) {
+	center, err := CenterFrequency(band, primary, width)
+	if err != nil {
+		return 0, 0, err
+	}
+	return center - int(width)/2, center + int(width)/2, nil
+}
@@ -0,0 +1,324 @@
+/*
+ * Copyright 2021 Giacomo Ferretti
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package wifichan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrequency(t *testing.T) {
+	tests := []struct {
+		band      Band
+		channel   int
+		frequency int
+	}{
+		{Band2GHz, 0, 0},
+		{Band2GHz, 1, 2412},
+		{Band2GHz, 6, 2437},
+		{Band2GHz, 11, 2462},
+		{Band2GHz, 13, 2472},
+		{Band2GHz, 14, 2484},
+		{Band2GHz, 15, 0},
+		{Band2GHz, 36, 0},
+		{Band5GHz, 0, 0},
+		{Band5GHz, 7, 5035},
+		{Band5GHz, 32, 5160},
+		{Band5GHz, 36, 5180},
+		{Band5GHz, 42, 5210},
+		{Band5GHz, 64, 5320},
+		{Band5GHz, 100, 5500},
+		{Band5GHz, 144, 5720},
+		{Band5GHz, 149, 5745},
+		{Band5GHz, 165, 5825},
+		{Band5GHz, 177, 5885},
+		{Band5GHz, 181, 5905},
+		{Band5GHz, 182, 4910},
+		{Band5GHz, 184, 4920},
+		{Band5GHz, 196, 4980},
+		{Band5GHz, 197, 0},
+		{Band6GHz, 0, 0},
+		{Band6GHz, 1, 5955},
+		{Band6GHz, 2, 5935},
+		{Band6GHz, 5, 5975},
+		{Band6GHz, 37, 6135},
+		{Band6GHz, 233, 7115},
+		{Band6GHz, 234, 0},
+		{Band60GHz, 0, 0},
+		{Band60GHz, 1, 58320},
+		{Band60GHz, 2, 60480},
+		{Band60GHz, 6, 69120},
+		{Band60GHz, 7, 0},
+		{BandUnknown, 1, 0},
+	}
+
+	for _, test := range tests {
+		if got := Frequency(test.band, test.channel); got != test.frequency {
+			t.Errorf("Frequency(%v, %v) = %v, want %v", test.band, test.channel, got, test.frequency)
+		}
+	}
+}
+
+func TestChannelOf(t *testing.T) {
+	tests := []struct {
+		frequency int
+		band      Band
+		channel   int
+	}{
+		{0, BandUnknown, 0},
+		{2407, BandUnknown, 0},
+		{2412, Band2GHz, 1},
+		{2417, Band2GHz, 2},
+		{2414, BandUnknown, 0},
+		{2472, Band2GHz, 13},
+		{2477, BandUnknown, 0},
+		{2484, Band2GHz, 14},
+		{4910, Band5GHz, 182},
+		{4920, Band5GHz, 184},
+		{4985, BandUnknown, 0},
+		{5000, BandUnknown, 0},
+		{5180, Band5GHz, 36},
+		{5182, BandUnknown, 0},
+		{5210, Band5GHz, 42},
+		{5825, Band5GHz, 165},
+		{5905, Band5GHz, 181},
+		{5910, BandUnknown, 0},
+		{5935, Band6GHz, 2},
+		{5940, BandUnknown, 0},
+		{5950, BandUnknown, 0},
+		{5955, Band6GHz, 1},
+		{5960, BandUnknown, 0},
+		{5965, Band6GHz, 3},
+		{7115, Band6GHz, 233},
+		{7120, BandUnknown, 0},
+		{58320, Band60GHz, 1},
+		{58321, BandUnknown, 0},
+		{69120, Band60GHz, 6},
+		{900, BandUnknown, 0},
+	}
+
+	for _, test := range tests {
+		band, channel := ChannelOf(test.frequency)
+		if band != test.band || channel != test.channel {
+			t.Errorf("ChannelOf(%v) = %v, %v, want %v, %v", test.frequency, band, channel, test.band, test.channel)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, band := range []Band{Band2GHz, Band5GHz, Band6GHz, Band60GHz} {
+		for channel := 1; channel <= 256; channel++ {
+			frequency := Frequency(band, channel)
+			if frequency == 0 {
+				continue
+			}
+			if got := BandOf(frequency); got != band {
+				t.Errorf("BandOf(%v) = %v, want %v", frequency, got, band)
+			}
+			if gotBand, got := ChannelOf(frequency); gotBand != band || got != channel {
+				t.Errorf("ChannelOf(Frequency(%v, %v)) = %v, %v", band, channel, gotBand, got)
+			}
+		}
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		band  Band
+		count int
+		first int
+		last  int
+	}{
+		{Band2GHz, 14, 1, 14},
+		{Band5GHz, 8 + 12 + 8, 36, 177},
+		{Band6GHz, 1 + 59, 2, 233},
+		{Band60GHz, 6, 1, 6},
+	}
+
+	for _, test := range tests {
+		channels := Channels(test.band)
+		if len(channels) != test.count || channels[0] != test.first || channels[len(channels)-1] != test.last {
+			t.Errorf("Channels(%v) = %v", test.band, channels)
+		}
+
+		previous := 0
+		for _, channel := range channels {
+			frequency := Frequency(test.band, channel)
+			if frequency <= previous {
+				t.Errorf("Channels(%v): %v (%v MHz) out of order", test.band, channel, frequency)
+			}
+			previous = frequency
+		}
+	}
+
+	if channels := Channels(BandUnknown); channels != nil {
+		t.Errorf("Channels(BandUnknown) = %v", channels)
+	}
+}
+
+func TestCenterChannel(t *testing.T) {
+	tests := []struct {
+		band    Band
+		primary int
+		width   Width
+		center  int
+	}{
+		{Band2GHz, 1, Width20, 1},
+		{Band2GHz, 14, Width20, 14},
+		{Band2GHz, 1, Width40, 3},
+		{Band2GHz, 7, Width40, 9},
+		{Band2GHz, 8, Width40, 6},
+		{Band2GHz, 13, Width40, 11},
+		{Band2GHz, 14, Width40, 0},
+		{Band2GHz, 6, Width80, 0},
+		{Band2GHz, 15, Width20, 0},
+
+		{Band5GHz, 36, Width40, 38},
+		{Band5GHz, 40, Width40, 38},
+		{Band5GHz, 44, Width40, 46},
+		{Band5GHz, 64, Width40, 62},
+		{Band5GHz, 100, Width40, 102},
+		{Band5GHz, 140, Width40, 142},
+		{Band5GHz, 144, Width40, 142},
+		{Band5GHz, 149, Width40, 151},
+		{Band5GHz, 165, Width40, 167},
+		{Band5GHz, 177, Width40, 175},
+		{Band5GHz, 36, Width80, 42},
+		{Band5GHz, 48, Width80, 42},
+		{Band5GHz, 52, Width80, 58},
+		{Band5GHz, 100, Width80, 106},
+		{Band5GHz, 116, Width80, 122},
+		{Band5GHz, 132, Width80, 138},
+		{Band5GHz, 144, Width80, 138},
+		{Band5GHz, 149, Width80, 155},
+		{Band5GHz, 165, Width80, 171},
+		{Band5GHz, 36, Width160, 50},
+		{Band5GHz, 64, Width160, 50},
+		{Band5GHz, 100, Width160, 114},
+		{Band5GHz, 128, Width160, 114},
+		{Band5GHz, 132, Width160, 0},
+		{Band5GHz, 149, Width160, 163},
+		{Band5GHz, 177, Width160, 163},
+		{Band5GHz, 36, Width320, 0},
+		{Band5GHz, 68, Width40, 0},
+		{Band5GHz, 38, Width40, 0},
+		{Band5GHz, 68, Width20, 68},
+
+		{Band6GHz, 1, Width40, 3},
+		{Band6GHz, 5, Width40, 3},
+		{Band6GHz, 9, Width40, 11},
+		{Band6GHz, 229, Width40, 227},
+		{Band6GHz, 233, Width40, 0},
+		{Band6GHz, 1, Width80, 7},
+		{Band6GHz, 17, Width80, 23},
+		{Band6GHz, 213, Width80, 215},
+		{Band6GHz, 229, Width80, 0},
+		{Band6GHz, 1, Width160, 15},
+		{Band6GHz, 37, Width160, 47},
+		{Band6GHz, 193, Width160, 207},
+		{Band6GHz, 225, Width160, 0},
+		{Band6GHz, 1, Width320, 31},
+		{Band6GHz, 65, Width320, 95},
+		{Band6GHz, 189, Width320, 159},
+		{Band6GHz, 193, Width320, 0},
+		{Band6GHz, 2, Width20, 2},
+		{Band6GHz, 2, Width40, 0},
+		{Band6GHz, 3, Width40, 0},
+
+		{Band60GHz, 1, Width20, 1},
+		{Band60GHz, 1, Width40, 0},
+		{BandUnknown, 1, Width20, 0},
+		{Band5GHz, 36, Width(60), 0},
+	}
+
+	for _, test := range tests {
+		center, err := CenterChannel(test.band, test.primary, test.width)
+		if test.center == 0 {
+			if err == nil {
+				t.Errorf("CenterChannel(%v, %v, %v) = %v, want an error", test.band, test.primary, test.width, center)
+			}
+			continue
+		}
+		if err != nil || center != test.center {
+			t.Errorf("CenterChannel(%v, %v, %v) = %v, %v, want %v", test.band, test.primary, test.width, center, err, test.center)
+		}
+	}
+}
+
+func TestCenterChannelCoversPrimary(t *testing.T) {
+	for _, band := range []Band{Band2GHz, Band5GHz, Band6GHz} {
+		for _, width := range []Width{Width20, Width40, Width80, Width160, Width320} {
+			for _, primary := range Channels(band) {
+				low, high, err := Bounds(band, primary, width)
+				if err != nil {
+					continue
+				}
+				if high-low != int(width) {
+					t.Errorf("Bounds(%v, %v, %v) = %v, %v", band, primary, width, low, high)
+				}
+				if frequency := Frequency(band, primary); frequency-10 < low || frequency+10 > high {
+					t.Errorf("Bounds(%v, %v, %v) = %v, %v, do not contain %v", band, primary, width, low, high, frequency)
+				}
+			}
+		}
+	}
+}
+
+func TestCenterFrequency(t *testing.T) {
+	tests := []struct {
+		band      Band
+		primary   int
+		width     Width
+		frequency int
+	}{
+		{Band2GHz, 6, Width20, 2437},
+		{Band2GHz, 6, Width40, 2447},
+		{Band2GHz, 11, Width40, 2452},
+		{Band5GHz, 36, Width80, 5210},
+		{Band5GHz, 100, Width160, 5570},
+		{Band6GHz, 37, Width320, 6105},
+	}
+
+	for _, test := range tests {
+		frequency, err := CenterFrequency(test.band, test.primary, test.width)
+		if err != nil || frequency != test.frequency {
+			t.Errorf("CenterFrequency(%v, %v, %v) = %v, %v, want %v", test.band, test.primary, test.width, frequency, err, test.frequency)
+		}
+	}
+
+	if _, err := CenterFrequency(Band2GHz, 14, Width40); err == nil {
+		t.Errorf("CenterFrequency(2.4GHz, 14, 40MHz) did not fail")
+	}
+}
+
+func TestBounds(t *testing.T) {
+	low, high, err := Bounds(Band5GHz, 36, Width80)
+	if err != nil || low != 5170 || high != 5250 {
+		t.Errorf("Bounds(5GHz, 36, 80MHz) = %v, %v, %v", low, high, err)
+	}
+	if _, _, err := Bounds(Band5GHz, 200, Width20); err == nil {
+		t.Errorf("Bounds(5GHz, 200, 20MHz) did not fail")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	got := []string{Band2GHz.String(), Band60GHz.String(), BandUnknown.String(), Width80.String()}
+	want := []string{"2.4GHz", "60GHz", "unknown", "80MHz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+}